@@ -4,10 +4,15 @@ import (
 	"bitback/internal/config"
 	repoImpl "bitback/internal/connectors/sql"
 	"bitback/internal/database"
+	"bitback/internal/events"
 	appRouter "bitback/internal/http/handlers"
 	appServer "bitback/internal/http/server"
 	"bitback/internal/interfaces"
+	"bitback/internal/logging"
+	"bitback/internal/metrics"
+	"bitback/internal/ratelimit"
 	"bitback/internal/services"
+	"bitback/internal/workers"
 	"context"
 	"errors"
 	"fmt"
@@ -24,6 +29,7 @@ import (
 type Application struct {
 	apiServer interfaces.ApiServer
 	database  interfaces.SQLDatabase
+	workers   []interfaces.BackgroundWorker
 	cfg       *config.Config
 }
 
@@ -60,28 +66,85 @@ func NewApplication(ctx context.Context) (*Application, error) {
 	userRepo := repoImpl.NewUserRepository(db)
 	subscriptionRepo := repoImpl.NewSubscriptionRepository(db)
 	hostRepo := repoImpl.NewHostRepository(db)
+	keyAssignmentRepo := repoImpl.NewKeyAssignmentRepository(db)
+	keyGenerationRepo := repoImpl.NewKeyGenerationRepository(db)
+	hostCheckRepo := repoImpl.NewHostCheckRepository(db)
+	planRepo := repoImpl.NewPlanRepository(db)
+	promoCodeRepo := repoImpl.NewPromoCodeRepository(db)
 	slog.Info("Repositories initialized successfully.")
 
+	// Initialize Prometheus metrics. Services and workers record metrics only when they are enabled.
+	var appMetrics *metrics.Metrics
+	var keyMetrics interfaces.KeyMetrics
+	var hostMetrics interfaces.HostMetrics
+	if cfg.MetricsEnabled {
+		sqlDB, err := db.GetGormClient().DB()
+		if err != nil {
+			slog.Warn("Database connection pool metrics are unavailable.", "error", err)
+		}
+		appMetrics = metrics.New(sqlDB, cfg.DBName)
+		keyMetrics, hostMetrics = appMetrics, appMetrics
+	}
+
+	// Initialize the domain event publisher. Events are only logged until a message broker is configured.
+	eventPublisher := events.NewLogPublisher()
+
 	// Initialize services.
-	userService := services.NewUserService(userRepo)
-	subscriptionService := services.NewSubscriptionService(subscriptionRepo, userRepo) // SubscriptionService also requires userRepo.
-	hostService := services.NewHostService(hostRepo)
-	keyService := services.NewKeyService(userRepo, hostRepo, subscriptionRepo) // KeyService requires userRepo and hostRepo.
+	userService := services.NewUserService(userRepo, subscriptionRepo, db, cfg)                                                                             // UserService requires subscriptionRepo to guard purges.
+	subscriptionService := services.NewSubscriptionService(subscriptionRepo, userRepo, planRepo, promoCodeRepo, keyAssignmentRepo, eventPublisher, db, cfg) // SubscriptionService also requires userRepo, planRepo, promoCodeRepo and an event publisher.
+	onboardingService := services.NewOnboardingService(userService, subscriptionService, db)                                                                // OnboardingService runs both services in one transaction.
+	hostService := services.NewHostService(hostRepo, hostCheckRepo, db, cfg)
+	planService := services.NewPlanService(planRepo)
+	promoCodeService := services.NewPromoCodeService(promoCodeRepo)
+	keyService := services.NewKeyService(userRepo, hostRepo, subscriptionRepo, keyAssignmentRepo, keyGenerationRepo, keyMetrics, services.NewHostSelector(nil), cfg) // KeyService requires userRepo and hostRepo.
+	reportService := services.NewReportService(userRepo, subscriptionRepo, hostRepo, keyGenerationRepo)
 	slog.Info("Services initialized successfully.")
 
 	// Initialize HTTP handlers.
-	userHandler := appRouter.NewUserHandler(userService)
-	subscriptionHandler := appRouter.NewSubscriptionHandler(subscriptionService)
-	hostHandler := appRouter.NewHostHandler(hostService)
-	keyManagerHandler := appRouter.NewKeyHandler(keyService)
+	userHandler := appRouter.NewUserHandler(userService, cfg)
+	subscriptionHandler := appRouter.NewSubscriptionHandler(subscriptionService, cfg)
+	onboardingHandler := appRouter.NewOnboardingHandler(onboardingService)
+	hostHandler := appRouter.NewHostHandler(hostService, cfg)
+	planHandler := appRouter.NewPlanHandler(planService, cfg)
+	promoCodeHandler := appRouter.NewPromoCodeHandler(promoCodeService)
+	keyManagerHandler := appRouter.NewKeyHandler(keyService, cfg)
+	reportHandler := appRouter.NewReportHandler(reportService)
+	healthHandler := appRouter.NewHealthHandler(db)
+	authHandler := appRouter.NewAuthHandler()
+	authMiddleware := appRouter.NewAuthMiddleware(userService, cfg.AuthHeaderSecret)
 	slog.Info("HTTP handlers initialized successfully.")
 
+	// Rate-limit the key generation routes, which include the unauthenticated free key endpoint.
+	var keyRouteMiddlewares []appRouter.Middleware
+	var keyRateLimiter *ratelimit.MemoryLimiter
+	if cfg.KeyRateLimitPerMinute > 0 {
+		keyRateLimiter = ratelimit.NewMemoryLimiter(cfg.KeyRateLimitPerMinute)
+		keyRouteMiddlewares = append(keyRouteMiddlewares, appRouter.RateLimit(keyRateLimiter, cfg.TrustedProxies))
+	} else {
+		slog.Info("Key generation rate limiting is disabled.")
+	}
+
 	// Configure the HTTP router and register routes for each handler.
 	router := appRouter.NewRouter() // router will be of type *appRouter.Router.
+	router.Use(buildMiddlewareChain(cfg, authMiddleware)...)
 	router.RegisterUserRoutes(userHandler)
 	router.RegisterSubscriptionRoutes(subscriptionHandler)
+	router.RegisterOnboardingRoutes(onboardingHandler)
 	router.RegisterHostRoutes(hostHandler)
-	router.RegisterKeyRoutes(keyManagerHandler)
+	router.RegisterPlanRoutes(planHandler)
+	router.RegisterPromoCodeRoutes(promoCodeHandler)
+	router.RegisterKeyRoutes(keyManagerHandler, keyRouteMiddlewares...)
+	router.RegisterReportRoutes(reportHandler)
+	router.RegisterAuthRoutes(authHandler)
+	router.RegisterHealthRoutes(healthHandler)
+	if appMetrics != nil {
+		router.EnableMetrics(appMetrics, appMetrics.Handler())
+		slog.Info("Prometheus metrics enabled at /metrics.")
+	}
+	router.EnableOpenAPI()
+	for _, pattern := range router.UndocumentedRoutes() {
+		slog.Warn("Route is missing from the OpenAPI document", "pattern", pattern)
+	}
 	slog.Info("Router configured successfully.")
 
 	// Create and prepare the API server.
@@ -89,9 +152,37 @@ func NewApplication(ctx context.Context) (*Application, error) {
 	preparedApiServer := apiHttpServer.CreateAndPrepare()
 	slog.Info("API server prepared successfully.")
 
+	// Initialize background workers.
+	var backgroundWorkers []interfaces.BackgroundWorker
+	if cfg.RenewalCheckInterval > 0 {
+		backgroundWorkers = append(backgroundWorkers, workers.NewRenewalWorker(subscriptionService, cfg.RenewalCheckInterval))
+	} else {
+		slog.Info("Subscription auto-renewal worker is disabled.")
+	}
+	if cfg.SubscriptionActivationInterval > 0 {
+		backgroundWorkers = append(backgroundWorkers, workers.NewSubscriptionActivationWorker(subscriptionService, cfg.SubscriptionActivationInterval))
+	} else {
+		slog.Info("Subscription activation worker is disabled.")
+	}
+	if cfg.HostCheckInterval > 0 {
+		backgroundWorkers = append(backgroundWorkers, workers.NewHostHealthWorker(hostService, hostMetrics, cfg))
+	} else {
+		slog.Info("Host health-check worker is disabled.")
+	}
+	if cfg.UserPurgeInterval > 0 {
+		backgroundWorkers = append(backgroundWorkers, workers.NewUserPurgeWorker(userService, cfg.UserPurgeInterval))
+	} else {
+		slog.Info("Soft-deleted user purge worker is disabled.")
+	}
+	if keyRateLimiter != nil {
+		backgroundWorkers = append(backgroundWorkers, workers.NewPeriodicWorker("rate-limit-cleanup", ratelimit.CleanupInterval, keyRateLimiter.Cleanup))
+	}
+	slog.Info("Background workers initialized successfully.", "count", len(backgroundWorkers))
+
 	application := &Application{
 		apiServer: preparedApiServer,
 		database:  db,
+		workers:   backgroundWorkers,
 		cfg:       cfg,
 	}
 
@@ -99,6 +190,25 @@ func NewApplication(ctx context.Context) (*Application, error) {
 	return application, nil
 }
 
+// buildMiddlewareChain returns the middlewares applied to every request, outermost first.
+// The request ID is assigned first so that every later log record carries it, and the access log
+// wraps panic recovery so that recovered panics are logged with their 500 status. CORS preflight requests
+// are answered before authentication, as browsers send them without credentials.
+func buildMiddlewareChain(cfg *config.Config, authMiddleware *appRouter.AuthMiddleware) []appRouter.Middleware {
+	chain := []appRouter.Middleware{appRouter.RequestID()}
+	if cfg.AccessLogEnabled {
+		chain = append(chain, appRouter.AccessLog())
+	}
+	chain = append(chain,
+		appRouter.Recover(),
+		appRouter.CORS(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders),
+		appRouter.LimitURILength(cfg.MaxURILength),
+		appRouter.EnforceHTTPS(cfg.HTTPSEnforcement),
+		authMiddleware.Authenticate,
+	)
+	return chain
+}
+
 // setupGlobalLogger configures the global slog logger instance.
 func setupGlobalLogger(_ context.Context, cfg *config.Config) error {
 	logLevel := cfg.GetSlogLevel()
@@ -106,7 +216,8 @@ func setupGlobalLogger(_ context.Context, cfg *config.Config) error {
 		AddSource: true,     // Include source file and line number in logs.
 		Level:     logLevel, // Set the minimum log level.
 	})
-	slog.SetDefault(slog.New(jsonHandler))
+	// Records logged with a request context carry its request ID.
+	slog.SetDefault(slog.New(logging.NewContextHandler(jsonHandler)))
 	return nil
 }
 
@@ -118,6 +229,11 @@ func (app *Application) Start() {
 		"log_level", app.cfg.LogLevel,
 	)
 
+	// Start background workers.
+	for _, worker := range app.workers {
+		worker.Start(context.Background())
+	}
+
 	// Channel to listen for server errors.
 	serverErrors := make(chan error, 1)
 	go func() {
@@ -168,6 +284,15 @@ func (app *Application) Shutdown() {
 		}
 	}
 
+	// Stop background workers before closing the database they depend on.
+	if len(app.workers) > 0 {
+		slog.Info("Stopping background workers...", "count", len(app.workers))
+		for _, worker := range app.workers {
+			worker.Stop()
+		}
+		slog.Info("Background workers stopped successfully.")
+	}
+
 	// Close the database connection.
 	if app.database != nil {
 		slog.Info("Closing database connection...")
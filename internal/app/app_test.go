@@ -0,0 +1,56 @@
+package app
+
+import (
+	"bitback/internal/config"
+	appRouter "bitback/internal/http/handlers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareChainAnswersPreflightBeforeAuthentication(t *testing.T) {
+	const adminOrigin = "https://admin.example.com"
+	cfg := &config.Config{
+		CORSAllowedOrigins: []string{adminOrigin},
+		CORSAllowedMethods: []string{"GET", "PATCH"},
+		CORSAllowedHeaders: []string{"Content-Type"},
+		AuthHeaderSecret:   "secret",
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		preflight  bool
+		wantStatus int
+	}{
+		// The malformed user ID would be rejected by authentication, which preflight requests never reach.
+		{name: "preflight", method: http.MethodOptions, preflight: true, wantStatus: http.StatusNoContent},
+		{name: "actual request", method: http.MethodPatch, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := appRouter.NewRouter()
+			router.Use(buildMiddlewareChain(cfg, appRouter.NewAuthMiddleware(nil, cfg.AuthHeaderSecret))...)
+			router.HandleFunc("PATCH /v1/hosts/{hostID}/tier", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/v1/hosts/1/tier", nil)
+			req.Header.Set("Origin", adminOrigin)
+			req.Header.Set("X-User-ID", "not-a-uuid")
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			}
+			rec := httptest.NewRecorder()
+			router.GetHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			// Errors carry the CORS headers too, so that the admin panel can read them.
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != adminOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, adminOrigin)
+			}
+		})
+	}
+}
@@ -1,15 +1,42 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	gormLogger "gorm.io/gorm/logger"
 	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// HTTPS enforcement modes for requests that reach the service as plain HTTP behind a TLS-terminating proxy.
+const (
+	HTTPSEnforcementOff      = "off"      // Plain HTTP requests are served as usual.
+	HTTPSEnforcementRedirect = "redirect" // Plain HTTP requests are redirected to HTTPS with 301.
+	HTTPSEnforcementReject   = "reject"   // Plain HTTP requests are rejected with 400.
+)
+
+// List endpoints whose default page size can be configured via PAGE_SIZE_BY_ENDPOINT.
+const (
+	PageSizeEndpointUsers                 = "users"                  // GET /v1/users
+	PageSizeEndpointHosts                 = "hosts"                  // GET /v1/hosts
+	PageSizeEndpointHostChecks            = "host_checks"            // GET /v1/hosts/{hostID}/checks
+	PageSizeEndpointSubscriptions         = "subscriptions"          // GET /v1/subscriptions
+	PageSizeEndpointUserSubscriptions     = "user_subscriptions"     // GET /v1/users/{userID}/subscriptions
+	PageSizeEndpointExpiringSubscriptions = "expiring_subscriptions" // GET /v1/reports/expiring-subscriptions
+	PageSizeEndpointPlanSubscriptions     = "plan_subscriptions"     // GET /v1/reports/active-by-plan
+	PageSizeEndpointPlans                 = "plans"                  // GET /v1/plans
+	PageSizeEndpointInactiveUsers         = "inactive_users"         // GET /v1/reports/inactive-users
+	PageSizeEndpointSubscriptionEvents    = "subscription_events"    // GET /v1/subscriptions/{subscriptionID}/events
+	PageSizeEndpointHostCountryUsers      = "host_country_users"     // GET /v1/reports/users-by-host-country
+)
+
+// maxDefaultPageSize caps configured default page sizes to the maximum page size accepted by list endpoints.
+const maxDefaultPageSize = 100
+
 // Config stores all application configuration parameters.
 type Config struct {
 	LogLevel            string        // Global logging level for slog (e.g., "debug", "info", "warn", "error").
@@ -19,6 +46,9 @@ type Config struct {
 	DBPassword          string        // Database password.
 	DBName              string        // Database name.
 	DBSslMode           string        // SSL mode for database connection (e.g., "disable", "require").
+	DBSslRootCert       string        // Optional: Path to the CA certificate the database server certificate is verified against.
+	DBSslCert           string        // Optional: Path to the client certificate; requires DBSslKey.
+	DBSslKey            string        // Optional: Path to the client certificate's private key; requires DBSslCert.
 	DBMaxOpenConns      int           // Maximum number of open connections to the database.
 	DBMaxIdleConns      int           // Maximum number of connections in the idle connection pool.
 	DBConnMaxLifetime   time.Duration // Maximum amount of time a connection may be reused.
@@ -32,8 +62,65 @@ type Config struct {
 	IdleTimeout       time.Duration // Maximum amount of time to wait for the next request when keep-alives are enabled.
 	ReadHeaderTimeout time.Duration // Amount of time allowed to read request headers.
 	ShutdownTimeout   time.Duration // Graceful shutdown period for the server.
+	HTTPSEnforcement  string        // How plain HTTP requests (per X-Forwarded-Proto) are handled: "off", "redirect" or "reject".
+	MetricsEnabled    bool          // Whether Prometheus metrics are collected and exposed at /metrics.
+	AccessLogEnabled  bool          // Whether every handled HTTP request is logged.
+	MaxURILength      int           // Maximum length in bytes of a request's path and query string; longer requests get 414. 0 disables the limit.
+
+	CORSAllowedOrigins []string // Origins browsers may call the API from ("*" allows any origin); empty disables CORS.
+	CORSAllowedMethods []string // Methods allowed in cross-origin requests, announced in preflight responses.
+	CORSAllowedHeaders []string // Request headers allowed in cross-origin requests, announced in preflight responses.
+
+	AllowedHostProtocols     []string      // Protocols hosts may be created with (e.g., vless, vmess, trojan); compared case-insensitively.
+	EnforceUniqueHostNames   bool          // Whether non-empty host names must be unique (case-insensitively) among non-deleted hosts.
+	RequireHostSecurity      bool          // Whether hosts need a complete security configuration (e.g., a public key for Reality) to be added or go online.
+	MaxFreeKeyBatchSize      int           // Maximum number of free keys generated by a single batch request.
+	MaxKeyCountries          int           // Maximum number of countries, and so keys, returned by a single multi-country key request.
+	MaxRemarksLength         int           // Maximum length in characters of the remarks embedded in generated keys. 0 disables the limit.
+	KeyDefaultRemarks        string        // Remarks embedded in user keys when the client requests none.
+	KeyFreeDefaultRemarks    string        // Remarks embedded in free keys when the client requests none.
+	FreeKeyTTL               time.Duration // Lifetime reported as expires_at for free keys; 0 omits it.
+	HostAvailabilityCacheTTL time.Duration // How long per-country host availability summaries are cached; 0 disables caching.
+	AllowFreeFallback        bool          // Whether subscribed users are served a free host when no paid host is available; can be overridden per request.
+
+	KeyRequestDedupWindow time.Duration  // Identical key requests of a user within this window get the same key instead of a new assignment; 0 disables deduplication.
+	KeyRateLimitPerMinute int            // Key generation requests allowed per minute per user (or per client IP when anonymous); 0 disables rate limiting.
+	TrustedProxies        []netip.Prefix // Addresses of reverse proxies whose X-Real-IP header is trusted as the client IP; empty trusts none.
+
+	DefaultPageSize    int            // Page size used by list endpoints when 'pageSize' is omitted and no per-endpoint default is set.
+	PageSizeByEndpoint map[string]int // Per-endpoint default page sizes, keyed by the PageSizeEndpoint* constants.
 
 	InstanceConnectionName string // Cloud SQL instance connection name (for Cloud Run)
+
+	DefaultCurrency   string            // Currency used for subscriptions when none is provided and none can be inferred.
+	CurrencyByCountry map[string]string // Maps upper-case ISO 3166-1 alpha-2 country codes to ISO 4217 currency codes.
+	PlanNameAliases   map[string]string // Maps plan name variants (e.g., "pro plan") to their canonical plan name; variants match case-insensitively.
+
+	PaymentWebhookSecret string // Shared secret the payment provider signs webhook payloads with (HMAC-SHA256); empty disables the payment webhook.
+	AuthHeaderSecret     string // Shared secret the gateway signs the X-User-ID header with (HMAC-SHA256); empty disables authentication.
+	TrojanPasswordSecret string // Secret users' trojan passwords are derived from (HMAC-SHA256 of the user ID); empty disables trojan keys.
+
+	RenewalCheckInterval time.Duration // How often the auto-renewal worker runs; 0 disables the worker.
+	RenewalWindow        time.Duration // Subscriptions ending within this window from now are renewed.
+	RenewalBatchSize     int           // Maximum number of subscriptions renewed per run.
+	RenewalPaymentGrace  time.Duration // Renewals still unpaid this long after they were extended are deactivated; should exceed RenewalWindow; 0 disables deactivation.
+
+	MaxSubscriptionStartAhead      time.Duration // How far in the future a new subscription may start; 0 disables the limit.
+	SubscriptionActivationInterval time.Duration // How often paid subscriptions whose start date has arrived are activated; 0 disables the worker.
+
+	TrialEnabled      bool   // Whether newly registered users get a trial subscription; users who registered before never get another one.
+	TrialDurationDays int    // Length of the trial subscription in days.
+	TrialPlanName     string // Plan name of the trial subscription.
+
+	HostCheckInterval    time.Duration // How often hosts are health-checked by dialing Address:Port; 0 disables the health-check worker.
+	HostCheckTimeout     time.Duration // Dial timeout for a single host health check.
+	HostCheckConcurrency int           // Maximum number of hosts checked at the same time.
+	HostCheckSkipPrivate bool          // Whether private hosts are excluded from health checks.
+	HostCheckStartupRamp time.Duration // Window over which the first health-check run after startup spreads its checks; 0 checks all hosts at once.
+
+	UserPurgeInterval   time.Duration // How often soft-deleted users are purged; 0 disables the retention worker.
+	UserRetentionPeriod time.Duration // Soft-deleted users older than this are permanently removed.
+	UserPurgeBatchSize  int           // Maximum number of users purged per run.
 }
 
 // LoadConfig loads configuration from environment variables, applying default values if not set.
@@ -41,24 +128,65 @@ type Config struct {
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
 		// Default values
-		LogLevel:            "info",
-		DBHost:              "localhost",
-		DBPort:              5432,
-		DBUser:              "admin",
-		DBPassword:          "truapp00", // I apologize to myself for this.
-		DBName:              "bitcloud",
-		DBSslMode:           "disable",
-		DBMaxOpenConns:      25,
-		DBMaxIdleConns:      25,
-		DBConnMaxLifetime:   5 * time.Minute,
-		DBGormLogLevel:      "warn",
-		DBGormSlowThreshold: 200 * time.Millisecond,
-		ApiPort:             9080, // API_HOST defaults to "" (empty string), meaning http.Server will use localhost.
-		ReadTimeout:         10 * time.Second,
-		WriteTimeout:        10 * time.Second,
-		IdleTimeout:         120 * time.Second,
-		ReadHeaderTimeout:   5 * time.Second,
-		ShutdownTimeout:     15 * time.Second,
+		LogLevel:                 "info",
+		DBHost:                   "localhost",
+		DBPort:                   5432,
+		DBUser:                   "admin",
+		DBPassword:               "truapp00", // I apologize to myself for this.
+		DBName:                   "bitcloud",
+		DBSslMode:                "disable",
+		DBMaxOpenConns:           25,
+		DBMaxIdleConns:           25,
+		DBConnMaxLifetime:        5 * time.Minute,
+		DBGormLogLevel:           "warn",
+		DBGormSlowThreshold:      200 * time.Millisecond,
+		ApiPort:                  9080, // API_HOST defaults to "" (empty string), meaning http.Server will use localhost.
+		ReadTimeout:              10 * time.Second,
+		WriteTimeout:             10 * time.Second,
+		IdleTimeout:              120 * time.Second,
+		ReadHeaderTimeout:        5 * time.Second,
+		ShutdownTimeout:          15 * time.Second,
+		HTTPSEnforcement:         HTTPSEnforcementOff,
+		AccessLogEnabled:         true,
+		MaxURILength:             8192,
+		CORSAllowedMethods:       []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		CORSAllowedHeaders:       []string{"Authorization", "Content-Type", "Idempotency-Key", "If-None-Match", "X-API-Version", "X-Request-ID"},
+		AllowedHostProtocols:     []string{"vless", "vmess", "trojan"},
+		RequireHostSecurity:      true,
+		MaxFreeKeyBatchSize:      100,
+		MaxKeyCountries:          20,
+		MaxRemarksLength:         64,
+		KeyDefaultRemarks:        "BittenVPN",
+		KeyFreeDefaultRemarks:    "BittenVPN-Free",
+		FreeKeyTTL:               24 * time.Hour,
+		HostAvailabilityCacheTTL: 30 * time.Second,
+		KeyRequestDedupWindow:    10 * time.Second,
+		KeyRateLimitPerMinute:    30,
+		DefaultPageSize:          10,
+		PageSizeByEndpoint:       map[string]int{},
+		PlanNameAliases:          map[string]string{},
+		DefaultCurrency:          "USD",
+		CurrencyByCountry: map[string]string{
+			"US": "USD",
+			"GB": "GBP",
+			"DE": "EUR",
+			"NL": "EUR",
+			"FR": "EUR",
+			"RU": "RUB",
+		},
+		RenewalCheckInterval:           1 * time.Hour,
+		RenewalWindow:                  24 * time.Hour,
+		RenewalBatchSize:               100,
+		RenewalPaymentGrace:            72 * time.Hour,
+		SubscriptionActivationInterval: 5 * time.Minute,
+		TrialDurationDays:              7,
+		TrialPlanName:                  "Trial",
+		HostCheckTimeout:               5 * time.Second,
+		HostCheckConcurrency:           10,
+		HostCheckStartupRamp:           30 * time.Second,
+		UserPurgeInterval:              24 * time.Hour,
+		UserRetentionPeriod:            30 * 24 * time.Hour,
+		UserPurgeBatchSize:             100,
 	}
 
 	// Load global slog logging level.
@@ -99,6 +227,13 @@ func LoadConfig() (*Config, error) {
 	if dbSslMode := os.Getenv("DB_SSLMODE"); dbSslMode != "" {
 		cfg.DBSslMode = dbSslMode
 	}
+	cfg.DBSslRootCert = os.Getenv("DB_SSL_ROOT_CERT")
+	cfg.DBSslCert = os.Getenv("DB_SSL_CERT")
+	cfg.DBSslKey = os.Getenv("DB_SSL_KEY")
+	if (cfg.DBSslCert == "") != (cfg.DBSslKey == "") {
+		slog.Error("DB_SSL_CERT and DB_SSL_KEY environment variables must be set together.")
+		return nil, errors.New("invalid database client certificate: DB_SSL_CERT and DB_SSL_KEY must be set together")
+	}
 
 	// Load database connection pool settings.
 	if dbMaxOpenConnsStr := os.Getenv("DB_MAX_OPEN_CONNS"); dbMaxOpenConnsStr != "" {
@@ -157,9 +292,288 @@ func LoadConfig() (*Config, error) {
 		cfg.ApiPort = apiPort
 	}
 
+	if httpsEnforcementEnv := os.Getenv("HTTPS_ENFORCEMENT"); httpsEnforcementEnv != "" {
+		cfg.HTTPSEnforcement = strings.ToLower(strings.TrimSpace(httpsEnforcementEnv))
+		if !isValidHTTPSEnforcement(cfg.HTTPSEnforcement) {
+			slog.Warn("Invalid HTTPS_ENFORCEMENT environment variable. Using default.", "value", httpsEnforcementEnv, "default", HTTPSEnforcementOff)
+			cfg.HTTPSEnforcement = HTTPSEnforcementOff
+		}
+	}
+
+	if metricsEnabledStr := os.Getenv("METRICS_ENABLED"); metricsEnabledStr != "" {
+		val, err := strconv.ParseBool(metricsEnabledStr)
+		if err == nil {
+			cfg.MetricsEnabled = val
+		} else {
+			slog.Warn("Invalid METRICS_ENABLED environment variable. Using default.", "value", metricsEnabledStr, "default", cfg.MetricsEnabled, "error", err)
+		}
+	}
+
+	if accessLogEnabledStr := os.Getenv("ACCESS_LOG_ENABLED"); accessLogEnabledStr != "" {
+		val, err := strconv.ParseBool(accessLogEnabledStr)
+		if err == nil {
+			cfg.AccessLogEnabled = val
+		} else {
+			slog.Warn("Invalid ACCESS_LOG_ENABLED environment variable. Using default.", "value", accessLogEnabledStr, "default", cfg.AccessLogEnabled, "error", err)
+		}
+	}
+
+	if maxURILengthStr := os.Getenv("MAX_URI_LENGTH"); maxURILengthStr != "" {
+		val, err := strconv.Atoi(maxURILengthStr)
+		if err == nil && val >= 0 {
+			cfg.MaxURILength = val
+		} else {
+			slog.Warn("Invalid MAX_URI_LENGTH environment variable. Using default.", "value", maxURILengthStr, "default", cfg.MaxURILength, "error", err)
+		}
+	}
+
+	if enforceUniqueHostNamesStr := os.Getenv("ENFORCE_UNIQUE_HOST_NAMES"); enforceUniqueHostNamesStr != "" {
+		val, err := strconv.ParseBool(enforceUniqueHostNamesStr)
+		if err == nil {
+			cfg.EnforceUniqueHostNames = val
+		} else {
+			slog.Warn("Invalid ENFORCE_UNIQUE_HOST_NAMES environment variable. Using default.", "value", enforceUniqueHostNamesStr, "default", cfg.EnforceUniqueHostNames, "error", err)
+		}
+	}
+
+	if requireHostSecurityStr := os.Getenv("REQUIRE_HOST_SECURITY"); requireHostSecurityStr != "" {
+		val, err := strconv.ParseBool(requireHostSecurityStr)
+		if err == nil {
+			cfg.RequireHostSecurity = val
+		} else {
+			slog.Warn("Invalid REQUIRE_HOST_SECURITY environment variable. Using default.", "value", requireHostSecurityStr, "default", cfg.RequireHostSecurity, "error", err)
+		}
+	}
+
+	if maxFreeKeyBatchSizeStr := os.Getenv("MAX_FREE_KEY_BATCH_SIZE"); maxFreeKeyBatchSizeStr != "" {
+		val, err := strconv.Atoi(maxFreeKeyBatchSizeStr)
+		if err == nil && val > 0 {
+			cfg.MaxFreeKeyBatchSize = val
+		} else {
+			slog.Warn("Invalid MAX_FREE_KEY_BATCH_SIZE environment variable. Using default.", "value", maxFreeKeyBatchSizeStr, "default", cfg.MaxFreeKeyBatchSize, "error", err)
+		}
+	}
+
+	if maxKeyCountriesStr := os.Getenv("MAX_KEY_COUNTRIES"); maxKeyCountriesStr != "" {
+		val, err := strconv.Atoi(maxKeyCountriesStr)
+		if err == nil && val > 0 {
+			cfg.MaxKeyCountries = val
+		} else {
+			slog.Warn("Invalid MAX_KEY_COUNTRIES environment variable. Using default.", "value", maxKeyCountriesStr, "default", cfg.MaxKeyCountries, "error", err)
+		}
+	}
+
+	if maxRemarksLengthStr := os.Getenv("MAX_REMARKS_LENGTH"); maxRemarksLengthStr != "" {
+		val, err := strconv.Atoi(maxRemarksLengthStr)
+		if err == nil && val >= 0 {
+			cfg.MaxRemarksLength = val
+		} else {
+			slog.Warn("Invalid MAX_REMARKS_LENGTH environment variable. Using default.", "value", maxRemarksLengthStr, "default", cfg.MaxRemarksLength, "error", err)
+		}
+	}
+
+	if keyDefaultRemarks := os.Getenv("KEY_DEFAULT_REMARKS"); keyDefaultRemarks != "" {
+		cfg.KeyDefaultRemarks = keyDefaultRemarks
+	}
+	if keyFreeDefaultRemarks := os.Getenv("KEY_FREE_DEFAULT_REMARKS"); keyFreeDefaultRemarks != "" {
+		cfg.KeyFreeDefaultRemarks = keyFreeDefaultRemarks
+	}
+	loadDurationFromEnv("FREE_KEY_TTL_HOURS", &cfg.FreeKeyTTL, time.Hour, cfg.FreeKeyTTL)
+
+	if allowFreeFallbackStr := os.Getenv("ALLOW_FREE_FALLBACK"); allowFreeFallbackStr != "" {
+		val, err := strconv.ParseBool(allowFreeFallbackStr)
+		if err == nil {
+			cfg.AllowFreeFallback = val
+		} else {
+			slog.Warn("Invalid ALLOW_FREE_FALLBACK environment variable. Using default.", "value", allowFreeFallbackStr, "default", cfg.AllowFreeFallback, "error", err)
+		}
+	}
+
+	loadDurationFromEnv("KEY_REQUEST_DEDUP_WINDOW_SECONDS", &cfg.KeyRequestDedupWindow, time.Second, cfg.KeyRequestDedupWindow)
+	if keyRateLimitStr := os.Getenv("KEY_RATE_LIMIT_PER_MINUTE"); keyRateLimitStr != "" {
+		val, err := strconv.Atoi(keyRateLimitStr)
+		if err == nil && val >= 0 {
+			cfg.KeyRateLimitPerMinute = val
+		} else {
+			slog.Warn("Invalid KEY_RATE_LIMIT_PER_MINUTE environment variable. Using default.", "value", keyRateLimitStr, "default", cfg.KeyRateLimitPerMinute, "error", err)
+		}
+	}
+
 	if instanceConnectionName := os.Getenv("INSTANCE_CONNECTION_NAME"); instanceConnectionName != "" {
 		cfg.InstanceConnectionName = instanceConnectionName
 	}
+	if strings.EqualFold(cfg.DBSslMode, "disable") && !cfg.isLocalDB() {
+		slog.Warn("Database SSL is disabled for a non-local database. Set DB_SSLMODE to 'require' or stricter.", "dbHost", cfg.DBHost, "instanceConnectionName", cfg.InstanceConnectionName)
+	}
+
+	if trustedProxiesStr := os.Getenv("TRUSTED_PROXIES"); trustedProxiesStr != "" {
+		trustedProxies, err := parseTrustedProxies(trustedProxiesStr)
+		if err != nil {
+			slog.Error("Invalid TRUSTED_PROXIES environment variable. Expected comma-separated IP addresses or CIDR ranges.", "value", trustedProxiesStr, "error", err)
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+		}
+		cfg.TrustedProxies = trustedProxies
+	}
+
+	if paymentWebhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET"); paymentWebhookSecret != "" {
+		cfg.PaymentWebhookSecret = paymentWebhookSecret
+	}
+
+	if authHeaderSecret := os.Getenv("AUTH_HEADER_SECRET"); authHeaderSecret != "" {
+		cfg.AuthHeaderSecret = authHeaderSecret
+	} else {
+		slog.Warn("AUTH_HEADER_SECRET is not set. X-User-ID headers are ignored and every request is unauthenticated.")
+	}
+
+	if trojanPasswordSecret := os.Getenv("TROJAN_PASSWORD_SECRET"); trojanPasswordSecret != "" {
+		cfg.TrojanPasswordSecret = trojanPasswordSecret
+	}
+
+	// Load subscription currency settings.
+	if defaultCurrency := os.Getenv("DEFAULT_CURRENCY"); defaultCurrency != "" {
+		cfg.DefaultCurrency = strings.ToUpper(strings.TrimSpace(defaultCurrency))
+	}
+	if currencyByCountryStr := os.Getenv("CURRENCY_BY_COUNTRY"); currencyByCountryStr != "" {
+		currencyByCountry, err := parseCountryCurrencyMap(currencyByCountryStr)
+		if err != nil {
+			slog.Error("Invalid CURRENCY_BY_COUNTRY environment variable. Expected format 'US:USD,DE:EUR'.", "value", currencyByCountryStr, "error", err)
+			return nil, fmt.Errorf("invalid CURRENCY_BY_COUNTRY: %w", err)
+		}
+		cfg.CurrencyByCountry = currencyByCountry
+	}
+	if planNameAliasesStr := os.Getenv("PLAN_NAME_ALIASES"); planNameAliasesStr != "" {
+		planNameAliases, err := parsePlanNameAliases(planNameAliasesStr)
+		if err != nil {
+			slog.Error("Invalid PLAN_NAME_ALIASES environment variable. Expected format 'pro plan:Pro,premium:Pro'.", "value", planNameAliasesStr, "error", err)
+			return nil, fmt.Errorf("invalid PLAN_NAME_ALIASES: %w", err)
+		}
+		cfg.PlanNameAliases = planNameAliases
+	}
+
+	// Load CORS settings. CORS stays disabled unless allowed origins are configured.
+	if corsAllowedOriginsStr := os.Getenv("CORS_ALLOWED_ORIGINS"); corsAllowedOriginsStr != "" {
+		cfg.CORSAllowedOrigins = splitCommaList(corsAllowedOriginsStr)
+	}
+	if corsAllowedMethodsStr := os.Getenv("CORS_ALLOWED_METHODS"); corsAllowedMethodsStr != "" {
+		if methods := splitCommaList(strings.ToUpper(corsAllowedMethodsStr)); len(methods) > 0 {
+			cfg.CORSAllowedMethods = methods
+		} else {
+			slog.Warn("Invalid CORS_ALLOWED_METHODS environment variable. Using default.", "value", corsAllowedMethodsStr, "default", cfg.CORSAllowedMethods)
+		}
+	}
+	if corsAllowedHeadersStr := os.Getenv("CORS_ALLOWED_HEADERS"); corsAllowedHeadersStr != "" {
+		if headers := splitCommaList(corsAllowedHeadersStr); len(headers) > 0 {
+			cfg.CORSAllowedHeaders = headers
+		} else {
+			slog.Warn("Invalid CORS_ALLOWED_HEADERS environment variable. Using default.", "value", corsAllowedHeadersStr, "default", cfg.CORSAllowedHeaders)
+		}
+	}
+
+	if allowedHostProtocolsStr := os.Getenv("ALLOWED_HOST_PROTOCOLS"); allowedHostProtocolsStr != "" {
+		var protocols []string
+		for _, protocol := range strings.Split(allowedHostProtocolsStr, ",") {
+			if protocol = strings.ToLower(strings.TrimSpace(protocol)); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+		if len(protocols) == 0 {
+			slog.Error("Invalid ALLOWED_HOST_PROTOCOLS environment variable. Expected a comma-separated list such as 'vless,vmess'.", "value", allowedHostProtocolsStr)
+			return nil, fmt.Errorf("invalid ALLOWED_HOST_PROTOCOLS: no protocols given")
+		}
+		cfg.AllowedHostProtocols = protocols
+	}
+
+	loadDurationFromEnv("HOST_AVAILABILITY_CACHE_TTL_SECONDS", &cfg.HostAvailabilityCacheTTL, time.Second, cfg.HostAvailabilityCacheTTL)
+
+	// Load list endpoint pagination defaults.
+	if defaultPageSizeStr := os.Getenv("DEFAULT_PAGE_SIZE"); defaultPageSizeStr != "" {
+		val, err := strconv.Atoi(defaultPageSizeStr)
+		if err == nil && val > 0 && val <= maxDefaultPageSize {
+			cfg.DefaultPageSize = val
+		} else {
+			slog.Warn("Invalid DEFAULT_PAGE_SIZE environment variable. Using default.", "value", defaultPageSizeStr, "default", cfg.DefaultPageSize, "max", maxDefaultPageSize, "error", err)
+		}
+	}
+	if pageSizeByEndpointStr := os.Getenv("PAGE_SIZE_BY_ENDPOINT"); pageSizeByEndpointStr != "" {
+		pageSizeByEndpoint, err := parsePageSizeMap(pageSizeByEndpointStr)
+		if err != nil {
+			slog.Error("Invalid PAGE_SIZE_BY_ENDPOINT environment variable. Expected format 'hosts:50,users:25'.", "value", pageSizeByEndpointStr, "error", err)
+			return nil, fmt.Errorf("invalid PAGE_SIZE_BY_ENDPOINT: %w", err)
+		}
+		cfg.PageSizeByEndpoint = pageSizeByEndpoint
+	}
+
+	// Load auto-renewal worker settings.
+	loadDurationFromEnv("RENEWAL_CHECK_INTERVAL_MINUTES", &cfg.RenewalCheckInterval, time.Minute, cfg.RenewalCheckInterval)
+	loadDurationFromEnv("RENEWAL_WINDOW_HOURS", &cfg.RenewalWindow, time.Hour, cfg.RenewalWindow)
+	loadDurationFromEnv("RENEWAL_PAYMENT_GRACE_HOURS", &cfg.RenewalPaymentGrace, time.Hour, cfg.RenewalPaymentGrace)
+	if renewalBatchSizeStr := os.Getenv("RENEWAL_BATCH_SIZE"); renewalBatchSizeStr != "" {
+		val, err := strconv.Atoi(renewalBatchSizeStr)
+		if err == nil && val > 0 {
+			cfg.RenewalBatchSize = val
+		} else {
+			slog.Warn("Invalid RENEWAL_BATCH_SIZE environment variable. Using default.", "value", renewalBatchSizeStr, "default", cfg.RenewalBatchSize, "error", err)
+		}
+	}
+
+	// Load settings for future-dated subscriptions.
+	loadDurationFromEnv("MAX_SUBSCRIPTION_START_AHEAD_DAYS", &cfg.MaxSubscriptionStartAhead, 24*time.Hour, cfg.MaxSubscriptionStartAhead)
+	loadDurationFromEnv("SUBSCRIPTION_ACTIVATION_INTERVAL_MINUTES", &cfg.SubscriptionActivationInterval, time.Minute, cfg.SubscriptionActivationInterval)
+
+	// Load trial subscription settings.
+	if trialEnabledStr := os.Getenv("TRIAL_ENABLED"); trialEnabledStr != "" {
+		val, err := strconv.ParseBool(trialEnabledStr)
+		if err == nil {
+			cfg.TrialEnabled = val
+		} else {
+			slog.Warn("Invalid TRIAL_ENABLED environment variable. Using default.", "value", trialEnabledStr, "default", cfg.TrialEnabled, "error", err)
+		}
+	}
+	if trialDurationDaysStr := os.Getenv("TRIAL_DURATION_DAYS"); trialDurationDaysStr != "" {
+		val, err := strconv.Atoi(trialDurationDaysStr)
+		if err == nil && val > 0 {
+			cfg.TrialDurationDays = val
+		} else {
+			slog.Warn("Invalid TRIAL_DURATION_DAYS environment variable. Using default.", "value", trialDurationDaysStr, "default", cfg.TrialDurationDays, "error", err)
+		}
+	}
+	if trialPlanName := strings.TrimSpace(os.Getenv("TRIAL_PLAN_NAME")); trialPlanName != "" {
+		cfg.TrialPlanName = trialPlanName
+	}
+
+	// Load host health-check worker settings. The worker is disabled unless an interval is configured,
+	// so deployments that report host status from an external monitor keep working unchanged.
+	loadDurationFromEnv("HOST_CHECK_INTERVAL_SECONDS", &cfg.HostCheckInterval, time.Second, cfg.HostCheckInterval)
+	loadDurationFromEnv("HOST_CHECK_TIMEOUT_SECONDS", &cfg.HostCheckTimeout, time.Second, cfg.HostCheckTimeout)
+	if hostCheckConcurrencyStr := os.Getenv("HOST_CHECK_CONCURRENCY"); hostCheckConcurrencyStr != "" {
+		val, err := strconv.Atoi(hostCheckConcurrencyStr)
+		if err == nil && val > 0 {
+			cfg.HostCheckConcurrency = val
+		} else {
+			slog.Warn("Invalid HOST_CHECK_CONCURRENCY environment variable. Using default.", "value", hostCheckConcurrencyStr, "default", cfg.HostCheckConcurrency, "error", err)
+		}
+	}
+	loadDurationFromEnv("HOST_CHECK_STARTUP_RAMP_SECONDS", &cfg.HostCheckStartupRamp, time.Second, cfg.HostCheckStartupRamp)
+	if hostCheckSkipPrivateStr := os.Getenv("HOST_CHECK_SKIP_PRIVATE"); hostCheckSkipPrivateStr != "" {
+		val, err := strconv.ParseBool(hostCheckSkipPrivateStr)
+		if err == nil {
+			cfg.HostCheckSkipPrivate = val
+		} else {
+			slog.Warn("Invalid HOST_CHECK_SKIP_PRIVATE environment variable. Using default.", "value", hostCheckSkipPrivateStr, "default", cfg.HostCheckSkipPrivate, "error", err)
+		}
+	}
+
+	// Load soft-deleted user retention settings.
+	loadDurationFromEnv("USER_PURGE_INTERVAL_MINUTES", &cfg.UserPurgeInterval, time.Minute, cfg.UserPurgeInterval)
+	loadDurationFromEnv("USER_RETENTION_DAYS", &cfg.UserRetentionPeriod, 24*time.Hour, cfg.UserRetentionPeriod)
+	if userPurgeBatchSizeStr := os.Getenv("USER_PURGE_BATCH_SIZE"); userPurgeBatchSizeStr != "" {
+		val, err := strconv.Atoi(userPurgeBatchSizeStr)
+		if err == nil && val > 0 {
+			cfg.UserPurgeBatchSize = val
+		} else {
+			slog.Warn("Invalid USER_PURGE_BATCH_SIZE environment variable. Using default.", "value", userPurgeBatchSizeStr, "default", cfg.UserPurgeBatchSize, "error", err)
+		}
+	}
 
 	// Load API server timeout settings using a helper function.
 	loadDurationFromEnv("API_READ_TIMEOUT_SECONDS", &cfg.ReadTimeout, time.Second, cfg.ReadTimeout)
@@ -191,15 +605,155 @@ func loadDurationFromEnv(envKey string, target *time.Duration, unit time.Duratio
 	}
 }
 
+// splitCommaList splits a comma-separated list, trimming whitespace and dropping empty items.
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges (e.g., "10.0.0.0/8,127.0.0.1").
+// A bare address is treated as a single-address range.
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	for _, item := range splitCommaList(value) {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("malformed CIDR range '%s': %w", item, err)
+			}
+			result = append(result, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("malformed IP address '%s': %w", item, err)
+		}
+		addr = addr.Unmap()
+		result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return result, nil
+}
+
+// parseCountryCurrencyMap parses a comma-separated list of COUNTRY:CURRENCY pairs (e.g., "US:USD,DE:EUR").
+// Both country and currency codes are normalized to upper case.
+func parseCountryCurrencyMap(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		country, currency, found := strings.Cut(pair, ":")
+		country = strings.ToUpper(strings.TrimSpace(country))
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if !found || country == "" || currency == "" {
+			return nil, fmt.Errorf("malformed country:currency pair '%s'", pair)
+		}
+		result[country] = currency
+	}
+	return result, nil
+}
+
+// parsePlanNameAliases parses a comma-separated list of ALIAS:CANONICAL pairs (e.g., "pro plan:Pro,premium:Pro").
+// Surrounding whitespace is trimmed; case is kept so that the canonical name is stored as written.
+func parsePlanNameAliases(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		alias, canonical, found := strings.Cut(pair, ":")
+		alias = strings.TrimSpace(alias)
+		canonical = strings.TrimSpace(canonical)
+		if !found || alias == "" || canonical == "" {
+			return nil, fmt.Errorf("malformed alias:canonical pair '%s'", pair)
+		}
+		result[alias] = canonical
+	}
+	return result, nil
+}
+
+// parsePageSizeMap parses a comma-separated list of ENDPOINT:SIZE pairs (e.g., "hosts:50,users:25").
+// Endpoint names must be one of the PageSizeEndpoint* constants and sizes must be between 1 and maxDefaultPageSize.
+func parsePageSizeMap(value string) (map[string]int, error) {
+	result := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		endpoint, sizeStr, found := strings.Cut(pair, ":")
+		endpoint = strings.ToLower(strings.TrimSpace(endpoint))
+		if !found || !isValidPageSizeEndpoint(endpoint) {
+			return nil, fmt.Errorf("malformed endpoint:size pair or unknown endpoint '%s'", pair)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(sizeStr))
+		if err != nil || size < 1 || size > maxDefaultPageSize {
+			return nil, fmt.Errorf("page size for endpoint '%s' must be between 1 and %d, got '%s'", endpoint, maxDefaultPageSize, sizeStr)
+		}
+		result[endpoint] = size
+	}
+	return result, nil
+}
+
+// GetDefaultPageSize returns the default page size for the given list endpoint (one of the PageSizeEndpoint* constants),
+// falling back to DefaultPageSize if no per-endpoint default is configured.
+func (c *Config) GetDefaultPageSize(endpoint string) int {
+	if size, ok := c.PageSizeByEndpoint[endpoint]; ok {
+		return size
+	}
+	return c.DefaultPageSize
+}
+
 // GetDBDSN returns the database connection string (Data Source Name).
+// The SSL mode and the optional certificate paths are included for both the Cloud SQL and the TCP connection.
+// Values are quoted, so passwords and paths may contain spaces and quotes.
 func (c *Config) GetDBDSN() string {
+	var dsn string
 	if c.InstanceConnectionName != "" {
-		return fmt.Sprintf("host=/cloudsql/%s user=%s password=%s dbname=%s sslmode=disable",
-			c.InstanceConnectionName, c.DBUser, c.DBPassword, c.DBName)
+		dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=%s",
+			quoteDSNValue("/cloudsql/"+c.InstanceConnectionName), quoteDSNValue(c.DBUser), quoteDSNValue(c.DBPassword),
+			quoteDSNValue(c.DBName), quoteDSNValue(c.DBSslMode))
+	} else {
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			quoteDSNValue(c.DBHost), c.DBPort, quoteDSNValue(c.DBUser), quoteDSNValue(c.DBPassword),
+			quoteDSNValue(c.DBName), quoteDSNValue(c.DBSslMode))
+	}
+
+	if c.DBSslRootCert != "" {
+		dsn += " sslrootcert=" + quoteDSNValue(c.DBSslRootCert)
+	}
+	if c.DBSslCert != "" {
+		dsn += " sslcert=" + quoteDSNValue(c.DBSslCert)
 	}
+	if c.DBSslKey != "" {
+		dsn += " sslkey=" + quoteDSNValue(c.DBSslKey)
+	}
+	return dsn
+}
 
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSslMode)
+// quoteDSNValue quotes a value for a keyword/value connection string, escaping backslashes and single quotes.
+func quoteDSNValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// isLocalDB reports whether the database is reached on the local machine or through the Cloud SQL connector's
+// Unix socket, where running without SSL is acceptable: the connector encrypts the connection itself.
+func (c *Config) isLocalDB() bool {
+	if c.InstanceConnectionName != "" {
+		return true
+	}
+	switch strings.ToLower(c.DBHost) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return strings.HasPrefix(c.DBHost, "/") // Unix domain socket directory.
 }
 
 // GetApiAddr returns the network address for the API server (e.g., "0.0.0.0:9080" or ":9080").
@@ -262,3 +816,25 @@ func isValidGormLogLevel(level string) bool {
 		return false
 	}
 }
+
+// isValidHTTPSEnforcement checks if the provided string is a supported HTTPS enforcement mode.
+func isValidHTTPSEnforcement(mode string) bool {
+	switch mode {
+	case HTTPSEnforcementOff, HTTPSEnforcementRedirect, HTTPSEnforcementReject:
+		return true
+	default:
+		return false
+	}
+}
+
+// isValidPageSizeEndpoint checks if the provided name is one of the PageSizeEndpoint* constants.
+func isValidPageSizeEndpoint(endpoint string) bool {
+	switch endpoint {
+	case PageSizeEndpointUsers, PageSizeEndpointHosts, PageSizeEndpointHostChecks, PageSizeEndpointSubscriptions,
+		PageSizeEndpointUserSubscriptions, PageSizeEndpointExpiringSubscriptions, PageSizeEndpointPlanSubscriptions, PageSizeEndpointPlans,
+		PageSizeEndpointInactiveUsers, PageSizeEndpointSubscriptionEvents, PageSizeEndpointHostCountryUsers:
+		return true
+	default:
+		return false
+	}
+}
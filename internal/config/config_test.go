@@ -0,0 +1,385 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPageSizes(t *testing.T) {
+	tests := []struct {
+		name            string
+		defaultPageSize string
+		byEndpoint      string
+		wantDefault     int
+		wantByEndpoint  map[string]int
+		wantErr         bool
+	}{
+		{name: "unset", wantDefault: 10, wantByEndpoint: map[string]int{}},
+		{name: "global default", defaultPageSize: "25", wantDefault: 25, wantByEndpoint: map[string]int{}},
+		{name: "global default out of range is ignored", defaultPageSize: "500", wantDefault: 10, wantByEndpoint: map[string]int{}},
+		{name: "per-endpoint defaults", byEndpoint: "hosts:50, Users:25", wantDefault: 10,
+			wantByEndpoint: map[string]int{PageSizeEndpointHosts: 50, PageSizeEndpointUsers: 25}},
+		{name: "unknown endpoint", byEndpoint: "widgets:5", wantErr: true},
+		{name: "size out of range", byEndpoint: "hosts:0", wantErr: true},
+		{name: "malformed pair", byEndpoint: "hosts", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_PAGE_SIZE", tt.defaultPageSize)
+			t.Setenv("PAGE_SIZE_BY_ENDPOINT", tt.byEndpoint)
+
+			cfg, err := LoadConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadConfig() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.DefaultPageSize != tt.wantDefault {
+				t.Errorf("DefaultPageSize = %d, want %d", cfg.DefaultPageSize, tt.wantDefault)
+			}
+			if !maps.Equal(cfg.PageSizeByEndpoint, tt.wantByEndpoint) {
+				t.Errorf("PageSizeByEndpoint = %v, want %v", cfg.PageSizeByEndpoint, tt.wantByEndpoint)
+			}
+		})
+	}
+}
+
+func TestGetDefaultPageSize(t *testing.T) {
+	cfg := &Config{DefaultPageSize: 10, PageSizeByEndpoint: map[string]int{PageSizeEndpointHosts: 50}}
+
+	tests := []struct {
+		endpoint string
+		want     int
+	}{
+		{endpoint: PageSizeEndpointHosts, want: 50},
+		{endpoint: PageSizeEndpointUsers, want: 10},
+		{endpoint: PageSizeEndpointExpiringSubscriptions, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			if got := cfg.GetDefaultPageSize(tt.endpoint); got != tt.want {
+				t.Errorf("GetDefaultPageSize(%q) = %d, want %d", tt.endpoint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMaxRemarksLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{name: "unset", want: 64},
+		{name: "custom", value: "32", want: 32},
+		{name: "disabled", value: "0", want: 0},
+		{name: "negative is ignored", value: "-1", want: 64},
+		{name: "not a number is ignored", value: "long", want: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_REMARKS_LENGTH", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.MaxRemarksLength != tt.want {
+				t.Errorf("MaxRemarksLength = %d, want %d", cfg.MaxRemarksLength, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAllowFreeFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "unset", want: false},
+		{name: "enabled", value: "true", want: true},
+		{name: "disabled", value: "false", want: false},
+		{name: "invalid is ignored", value: "sometimes", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOW_FREE_FALLBACK", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.AllowFreeFallback != tt.want {
+				t.Errorf("AllowFreeFallback = %t, want %t", cfg.AllowFreeFallback, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCORS(t *testing.T) {
+	defaultMethods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+	tests := []struct {
+		name        string
+		origins     string
+		methods     string
+		wantOrigins []string
+		wantMethods []string
+	}{
+		{name: "disabled by default", wantMethods: defaultMethods},
+		{name: "origins", origins: "https://admin.example.com, https://ops.example.com ,", wantOrigins: []string{"https://admin.example.com", "https://ops.example.com"},
+			wantMethods: defaultMethods},
+		{name: "any origin", origins: "*", wantOrigins: []string{"*"}, wantMethods: defaultMethods},
+		{name: "methods are upper-cased", methods: "get,patch", wantMethods: []string{"GET", "PATCH"}},
+		{name: "empty methods are ignored", methods: " , ", wantMethods: defaultMethods},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.origins)
+			t.Setenv("CORS_ALLOWED_METHODS", tt.methods)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if !slices.Equal(cfg.CORSAllowedOrigins, tt.wantOrigins) {
+				t.Errorf("CORSAllowedOrigins = %q, want %q", cfg.CORSAllowedOrigins, tt.wantOrigins)
+			}
+			if !slices.Equal(cfg.CORSAllowedMethods, tt.wantMethods) {
+				t.Errorf("CORSAllowedMethods = %q, want %q", cfg.CORSAllowedMethods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMaxSubscriptionStartAhead(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "unset disables the limit", want: 0},
+		{name: "custom", value: "30", want: 30 * 24 * time.Hour},
+		{name: "disabled", value: "0", want: 0},
+		{name: "negative is ignored", value: "-7", want: 0},
+		{name: "not a number is ignored", value: "month", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_SUBSCRIPTION_START_AHEAD_DAYS", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.MaxSubscriptionStartAhead != tt.want {
+				t.Errorf("MaxSubscriptionStartAhead = %v, want %v", cfg.MaxSubscriptionStartAhead, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigHostAvailabilityCacheTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "unset", want: 30 * time.Second},
+		{name: "custom", value: "120", want: 2 * time.Minute},
+		{name: "disabled", value: "0", want: 0},
+		{name: "negative is ignored", value: "-5", want: 30 * time.Second},
+		{name: "not a number is ignored", value: "soon", want: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOST_AVAILABILITY_CACHE_TTL_SECONDS", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.HostAvailabilityCacheTTL != tt.want {
+				t.Errorf("HostAvailabilityCacheTTL = %v, want %v", cfg.HostAvailabilityCacheTTL, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPlanNameAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "unset", want: map[string]string{}},
+		{name: "aliases", value: "pro plan:Pro,premium:Pro", want: map[string]string{"pro plan": "Pro", "premium": "Pro"}},
+		{name: "whitespace is trimmed and case kept", value: " Pro Plan : Pro , ,Basic Monthly:Basic", want: map[string]string{"Pro Plan": "Pro", "Basic Monthly": "Basic"}},
+		{name: "missing canonical name", value: "pro plan:", wantErr: true},
+		{name: "missing alias", value: ":Pro", wantErr: true},
+		{name: "malformed pair", value: "pro plan", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PLAN_NAME_ALIASES", tt.value)
+
+			cfg, err := LoadConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadConfig() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if !maps.Equal(cfg.PlanNameAliases, tt.want) {
+				t.Errorf("PlanNameAliases = %v, want %v", cfg.PlanNameAliases, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRequireHostSecurity(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "unset", want: true},
+		{name: "enabled", value: "true", want: true},
+		{name: "disabled", value: "false", want: false},
+		{name: "invalid is ignored", value: "maybe", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_HOST_SECURITY", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.RequireHostSecurity != tt.want {
+				t.Errorf("RequireHostSecurity = %t, want %t", cfg.RequireHostSecurity, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPaymentWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "unset disables the webhook", want: ""},
+		{name: "set", value: "s3cret", want: "s3cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PAYMENT_WEBHOOK_SECRET", tt.value)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.PaymentWebhookSecret != tt.want {
+				t.Errorf("PaymentWebhookSecret = %q, want %q", cfg.PaymentWebhookSecret, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDBDSN(t *testing.T) {
+	base := Config{DBHost: "db.internal", DBPort: 5432, DBUser: "bitback", DBPassword: "pw", DBName: "bitback", DBSslMode: "disable"}
+	with := func(change func(c *Config)) Config {
+		c := base
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "tcp", cfg: base,
+			want: `host='db.internal' port=5432 user='bitback' password='pw' dbname='bitback' sslmode='disable'`},
+		{name: "tcp with ssl", cfg: with(func(c *Config) {
+			c.DBSslMode, c.DBSslRootCert, c.DBSslCert, c.DBSslKey = "verify-full", "/certs/ca.pem", "/certs/client.pem", "/certs/client.key"
+		}), want: `host='db.internal' port=5432 user='bitback' password='pw' dbname='bitback' sslmode='verify-full' sslrootcert='/certs/ca.pem' sslcert='/certs/client.pem' sslkey='/certs/client.key'`},
+		{name: "cloud sql", cfg: with(func(c *Config) { c.InstanceConnectionName = "project:region:instance" }),
+			want: `host='/cloudsql/project:region:instance' user='bitback' password='pw' dbname='bitback' sslmode='disable'`},
+		{name: "cloud sql honors the ssl mode", cfg: with(func(c *Config) {
+			c.InstanceConnectionName, c.DBSslMode = "project:region:instance", "require"
+		}), want: `host='/cloudsql/project:region:instance' user='bitback' password='pw' dbname='bitback' sslmode='require'`},
+		{name: "cloud sql with ssl", cfg: with(func(c *Config) {
+			c.InstanceConnectionName, c.DBSslMode, c.DBSslRootCert, c.DBSslCert, c.DBSslKey = "project:region:instance", "verify-ca", "/certs/ca.pem", "/certs/client.pem", "/certs/client.key"
+		}), want: `host='/cloudsql/project:region:instance' user='bitback' password='pw' dbname='bitback' sslmode='verify-ca' sslrootcert='/certs/ca.pem' sslcert='/certs/client.pem' sslkey='/certs/client.key'`},
+		{name: "root certificate only", cfg: with(func(c *Config) { c.DBSslMode, c.DBSslRootCert = "verify-full", "/certs/ca.pem" }),
+			want: `host='db.internal' port=5432 user='bitback' password='pw' dbname='bitback' sslmode='verify-full' sslrootcert='/certs/ca.pem'`},
+		{name: "values are quoted", cfg: with(func(c *Config) { c.DBPassword = `p w'd\` }),
+			want: `host='db.internal' port=5432 user='bitback' password='p w\'d\\' dbname='bitback' sslmode='disable'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.GetDBDSN(); got != tt.want {
+				t.Errorf("GetDBDSN() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDatabaseSSL(t *testing.T) {
+	tests := []struct {
+		name             string
+		env              map[string]string
+		wantErr          bool
+		wantInsecureWarn bool
+	}{
+		{name: "local database without ssl", env: map[string]string{"DB_HOST": "localhost", "DB_SSLMODE": "disable"}},
+		{name: "unix socket without ssl", env: map[string]string{"DB_HOST": "/var/run/postgresql", "DB_SSLMODE": "disable"}},
+		{name: "cloud sql socket without ssl", env: map[string]string{"INSTANCE_CONNECTION_NAME": "project:region:instance", "DB_SSLMODE": "disable"}},
+		{name: "remote database without ssl", env: map[string]string{"DB_HOST": "db.internal", "DB_SSLMODE": "disable"}, wantInsecureWarn: true},
+		{name: "remote database with ssl", env: map[string]string{"DB_HOST": "db.internal", "DB_SSLMODE": "require"}},
+		{name: "client certificate", env: map[string]string{"DB_HOST": "db.internal", "DB_SSLMODE": "verify-full",
+			"DB_SSL_ROOT_CERT": "/certs/ca.pem", "DB_SSL_CERT": "/certs/client.pem", "DB_SSL_KEY": "/certs/client.key"}},
+		{name: "certificate without key", env: map[string]string{"DB_HOST": "db.internal", "DB_SSL_CERT": "/certs/client.pem"}, wantErr: true},
+		{name: "key without certificate", env: map[string]string{"DB_HOST": "db.internal", "DB_SSL_KEY": "/certs/client.key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_HOST", "DB_SSLMODE", "INSTANCE_CONNECTION_NAME", "DB_SSL_ROOT_CERT", "DB_SSL_CERT", "DB_SSL_KEY"} {
+				t.Setenv(key, tt.env[key])
+			}
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			cfg, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cfg.DBSslRootCert != tt.env["DB_SSL_ROOT_CERT"] || cfg.DBSslCert != tt.env["DB_SSL_CERT"] || cfg.DBSslKey != tt.env["DB_SSL_KEY"] {
+				t.Errorf("certificate settings = %q, %q, %q; want the environment's", cfg.DBSslRootCert, cfg.DBSslCert, cfg.DBSslKey)
+			}
+			if warned := strings.Contains(logs.String(), "Database SSL is disabled"); warned != tt.wantInsecureWarn {
+				t.Errorf("insecure database warning logged = %v, want %v:\n%s", warned, tt.wantInsecureWarn, logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/testutil/sqlfake"
+	"bitback/internal/testutil/testdb"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// fakeSQLDatabase is an interfaces.SQLDatabase serving a GORM client backed by sqlfake or an in-memory SQLite database.
+type fakeSQLDatabase struct {
+	interfaces.SQLDatabase
+
+	gorm *gorm.DB
+}
+
+func (d *fakeSQLDatabase) GetGormClient() *gorm.DB {
+	return d.gorm
+}
+
+// newFakeSQLDatabase returns a database whose statements are answered by respond, and the recorder of its statements.
+func newFakeSQLDatabase(t testing.TB, respond sqlfake.Responder) (*fakeSQLDatabase, *sqlfake.DB) {
+	t.Helper()
+	gormDB, fake, err := sqlfake.Open(respond)
+	if err != nil {
+		t.Fatalf("sqlfake.Open() error = %v", err)
+	}
+	return &fakeSQLDatabase{gorm: gormDB}, fake
+}
+
+// newSQLiteDatabase returns a database backed by a migrated, in-memory SQLite database, and its GORM client for
+// seeding and inspecting rows.
+func newSQLiteDatabase(t testing.TB) (*fakeSQLDatabase, *gorm.DB) {
+	t.Helper()
+	gormDB := testdb.Open(t)
+	return &fakeSQLDatabase{gorm: gormDB}, gormDB
+}
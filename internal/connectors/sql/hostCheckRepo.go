@@ -0,0 +1,71 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// hostCheckRepository implements the interfaces.HostCheckRepository for interacting with host check data in a SQL database.
+type hostCheckRepository struct {
+	db *gorm.DB
+}
+
+// NewHostCheckRepository creates a new instance of hostCheckRepository.
+func NewHostCheckRepository(sqlDB interfaces.SQLDatabase) interfaces.HostCheckRepository {
+	return &hostCheckRepository{
+		db: sqlDB.GetGormClient(),
+	}
+}
+
+// Create persists a new host check record to the database.
+func (r *hostCheckRepository) Create(ctx context.Context, check *models.HostCheck) error {
+	if check == nil {
+		return errors.New("host check to create cannot be nil")
+	}
+	return dbFromContext(ctx, r.db).Create(check).Error
+}
+
+// ListByHostID retrieves a paginated list of checks for a host, newest first.
+func (r *hostCheckRepository) ListByHostID(ctx context.Context, hostID uint, offset, limit int) ([]models.HostCheck, int64, error) {
+	var checks []models.HostCheck
+	var totalCount int64
+
+	baseQuery := dbFromContext(ctx, r.db).Model(&models.HostCheck{}).Where("host_id = ?", hostID)
+
+	if err := baseQuery.Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count host checks: %w", err)
+	}
+
+	if totalCount == 0 {
+		return []models.HostCheck{}, 0, nil // No checks recorded for this host.
+	}
+
+	query := baseQuery.Order("checked_at DESC").Offset(offset).Limit(limit)
+	if err := query.Find(&checks).Error; err != nil {
+		return nil, totalCount, fmt.Errorf("failed to list host checks: %w", err)
+	}
+	return checks, totalCount, nil
+}
+
+// CountSince counts the checks recorded for a host since the given time,
+// returning the total number of checks and the number of checks where the host was online.
+func (r *hostCheckRepository) CountSince(ctx context.Context, hostID uint, since time.Time) (int64, int64, error) {
+	var counts struct {
+		Total  int64
+		Online int64
+	}
+	err := dbFromContext(ctx, r.db).Model(&models.HostCheck{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE was_online) AS online").
+		Where("host_id = ? AND checked_at >= ?", hostID, since).
+		Scan(&counts).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count host checks since %s: %w", since, err)
+	}
+	return counts.Total, counts.Online, nil
+}
@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostCheckCountSince(t *testing.T) {
+	since := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		row        []driver.Value
+		wantTotal  int64
+		wantOnline int64
+	}{
+		{name: "checks", row: []driver.Value{int64(4), int64(3)}, wantTotal: 4, wantOnline: 3},
+		{name: "no checks", row: []driver.Value{int64(0), int64(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"total", "online"}, Rows: [][]driver.Value{tt.row}}
+			})
+
+			total, online, err := NewHostCheckRepository(db).CountSince(context.Background(), 7, since)
+			if err != nil {
+				t.Fatalf("CountSince() error = %v", err)
+			}
+			if total != tt.wantTotal || online != tt.wantOnline {
+				t.Errorf("CountSince() = %d, %d, want %d, %d", total, online, tt.wantTotal, tt.wantOnline)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			if !strings.Contains(queries[0].SQL, "COUNT(*) FILTER (WHERE was_online) AS online") ||
+				!strings.Contains(queries[0].SQL, "WHERE host_id = $1 AND checked_at >= $2") {
+				t.Errorf("unexpected query %q", queries[0].SQL)
+			}
+			if want := []any{int64(7), since}; !reflect.DeepEqual(queries[0].Args, want) {
+				t.Errorf("query args = %v, want %v", queries[0].Args, want)
+			}
+		})
+	}
+}
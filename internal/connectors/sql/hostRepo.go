@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // hostRepository implements the interfaces.HostRepository for interacting with host data in a SQL database.
@@ -30,14 +32,42 @@ func (r *hostRepository) Create(ctx context.Context, host *models.Host) error {
 		return errors.New("host to create cannot be nil")
 	}
 
-	return r.db.WithContext(ctx).Create(host).Error
+	if err := dbFromContext(ctx, r.db).Create(host).Error; err != nil {
+		if uniqueViolationConstraint(err) == models.HostNameUniqueIndex {
+			return fmt.Errorf("failed to create host: %w", interfaces.ErrHostNameTaken)
+		}
+		return err
+	}
+	return nil
+}
+
+// hostCreateBatchSize is the number of rows inserted per statement by CreateBatch.
+const hostCreateBatchSize = 100
+
+// CreateBatch persists multiple new host records to the database.
+// Inserts are grouped into multi-row statements and run within a single transaction,
+// so either all hosts are created or none are.
+func (r *hostRepository) CreateBatch(ctx context.Context, hosts []*models.Host) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(hosts, hostCreateBatchSize).Error; err != nil {
+			if uniqueViolationConstraint(err) == models.HostNameUniqueIndex {
+				return fmt.Errorf("failed to create hosts in batch: %w", interfaces.ErrHostNameTaken)
+			}
+			return fmt.Errorf("failed to create hosts in batch: %w", err)
+		}
+		return nil
+	})
 }
 
 // GetByID retrieves a host by its primary key ID.
 // Returns gorm.ErrRecordNotFound if no host is found.
 func (r *hostRepository) GetByID(ctx context.Context, id uint) (*models.Host, error) {
 	var host models.Host
-	if err := r.db.WithContext(ctx).First(&host, id).Error; err != nil {
+	if err := dbFromContext(ctx, r.db).First(&host, id).Error; err != nil {
 		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
 	}
 	return &host, nil
@@ -47,7 +77,7 @@ func (r *hostRepository) GetByID(ctx context.Context, id uint) (*models.Host, er
 // This is typically used to check for the existence of a host before creation.
 func (r *hostRepository) GetByAddressPortProtocolNetwork(ctx context.Context, address, port, protocol, network string) (*models.Host, error) {
 	var host models.Host
-	err := r.db.WithContext(ctx).
+	err := dbFromContext(ctx, r.db).
 		Where("address = ? AND port = ? AND protocol = ? AND network = ?", address, port, protocol, network).
 		First(&host).Error
 	if err != nil {
@@ -56,59 +86,98 @@ func (r *hostRepository) GetByAddressPortProtocolNetwork(ctx context.Context, ad
 	return &host, nil
 }
 
-// GetRandomActiveHost retrieves a random, active host from the database.
-// It prioritizes hosts that are online (is_online = true) and have a status of 'active'.
-// Optionally filters by country and free tier status.
-func (r *hostRepository) GetRandomActiveHost(ctx context.Context, country *string, isFreeTier *bool) (*models.Host, error) {
-	var host models.Host
-	var count int64
-
-	query := r.db.WithContext(ctx).Model(&models.Host{})
-
-	// Base conditions for active hosts
-	query = query.Where("is_online = ? AND status = ?", true, customTypes.StatusActive)
-
-	// Optional filter by country
-	if country != nil && *country != "" {
-		query = query.Where("LOWER(country) = LOWER(?)", *country)
+// ListAll retrieves every non-deleted host, ordered by ID.
+func (r *hostRepository) ListAll(ctx context.Context) ([]models.Host, error) {
+	var hosts []models.Host
+	if err := dbFromContext(ctx, r.db).Order("id ASC").Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list all hosts: %w", err)
 	}
+	return hosts, nil
+}
 
-	// Optional filter by free tier status
-	if isFreeTier != nil {
-		query = query.Where("is_free_tier = ?", *isFreeTier)
+// GetByHostName retrieves a host by its name, compared case-insensitively.
+// Returns gorm.ErrRecordNotFound if no host has the name.
+func (r *hostRepository) GetByHostName(ctx context.Context, hostName string) (*models.Host, error) {
+	var host models.Host
+	if err := dbFromContext(ctx, r.db).Where("lower(host_name) = lower(?)", hostName).First(&host).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if no matching host is found.
 	}
+	return &host, nil
+}
 
-	// Count hosts matching the primary criteria
-	err := query.Count(&count).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to count active hosts with specific criteria: %w", err)
+// AcquireLeastIssuedHost selects the online, active host with the lowest issued_count and increments
+// its counter in a single UPDATE ... RETURNING statement.
+// The candidate row is locked with FOR UPDATE SKIP LOCKED, so concurrent key requests pick different hosts
+// instead of all choosing the same one. Ties are broken by last_issued_at and then randomly.
+// If no host with the 'active' status matches, it falls back to any online host.
+// Optionally filters by country, tier, network and security type. Returns gorm.ErrRecordNotFound if no host matches.
+func (r *hostRepository) AcquireLeastIssuedHost(ctx context.Context, filter customTypes.HostSelectionFilter) (*models.Host, error) {
+	return r.AcquireLeastIssuedHostExcluding(ctx, filter, nil)
+}
+
+// AcquireLeastIssuedHostExcluding behaves like AcquireLeastIssuedHost but never selects a host whose ID is in excludeHostIDs.
+func (r *hostRepository) AcquireLeastIssuedHostExcluding(ctx context.Context, filter customTypes.HostSelectionFilter, excludeHostIDs []uint) (*models.Host, error) {
+	host, err := r.acquireLeastIssuedHost(ctx, filter, excludeHostIDs, true)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return host, err
 	}
+	// Fallback: no host with 'active' status; accept any online host.
+	return r.acquireLeastIssuedHost(ctx, filter, excludeHostIDs, false)
+}
 
-	if count > 0 {
-		err = query.Order("RANDOM()").First(&host).Error
-		if err != nil {
-			return nil, fmt.Errorf("failed to get random host with specific criteria: %w", err)
-		}
-		return &host, nil
+// acquireLeastIssuedHost performs a single acquisition attempt for AcquireLeastIssuedHostExcluding.
+// If requireActiveStatus is true, only hosts with the 'active' status are considered.
+func (r *hostRepository) acquireLeastIssuedHost(ctx context.Context, filter customTypes.HostSelectionFilter, excludeHostIDs []uint, requireActiveStatus bool) (*models.Host, error) {
+	candidate := applySelectableHostFilters(dbFromContext(ctx, r.db).Model(&models.Host{}).Select("id"), filter, requireActiveStatus)
+	if len(excludeHostIDs) > 0 {
+		candidate = candidate.Where("id NOT IN ?", excludeHostIDs)
 	}
+	candidate = candidate.
+		Order("issued_count ASC, last_issued_at ASC NULLS FIRST, RANDOM()").
+		Limit(1).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
 
-	if count == 0 {
+	var hosts []models.Host
+	result := dbFromContext(ctx, r.db).Model(&hosts).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", candidate).
+		UpdateColumns(map[string]interface{}{
+			"issued_count":   gorm.Expr("issued_count + 1"),
+			"last_issued_at": time.Now(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to acquire least issued host: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || len(hosts) == 0 {
 		return nil, gorm.ErrRecordNotFound
 	}
-
-	return nil, gorm.ErrRecordNotFound
+	return &hosts[0], nil
 }
 
-// Update saves changes to an existing host record in the database.
-// It uses db.Save(), which updates all fields and runs GORM hooks.
-func (r *hostRepository) Update(ctx context.Context, host *models.Host) error {
+// Update writes the changed columns of an existing host record, keyed by column name, along with updated_at.
+// Other columns are left untouched, so concurrent updates of them are preserved.
+// Returns gorm.ErrRecordNotFound if the host does not exist or was deleted.
+func (r *hostRepository) Update(ctx context.Context, host *models.Host, changes map[string]any) error {
 	if host == nil {
 		return errors.New("host to update cannot be nil")
 	}
 	if host.ID == 0 {
 		return errors.New("host ID is required for update")
 	}
-	return r.db.WithContext(ctx).Save(host).Error
+	if len(changes) == 0 {
+		return nil
+	}
+	result := dbFromContext(ctx, r.db).Model(host).Updates(changes)
+	if result.Error != nil {
+		if uniqueViolationConstraint(result.Error) == models.HostNameUniqueIndex {
+			return fmt.Errorf("failed to update host: %w", interfaces.ErrHostNameTaken)
+		}
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // Delete performs a soft delete on a host record by setting the DeletedAt timestamp.
@@ -117,7 +186,7 @@ func (r *hostRepository) Delete(ctx context.Context, id uint) error {
 	if id == 0 {
 		return errors.New("host ID is required for delete")
 	}
-	result := r.db.WithContext(ctx).Delete(&models.Host{}, id)
+	result := dbFromContext(ctx, r.db).Delete(&models.Host{}, id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -127,46 +196,97 @@ func (r *hostRepository) Delete(ctx context.Context, id uint) error {
 	return nil
 }
 
-// List retrieves a list of hosts with filtering, pagination, and sorting.
-func (r *hostRepository) List(ctx context.Context, params customTypes.ListHostsParams) ([]models.Host, int64, error) {
-	var hosts []models.Host
-	var totalCount int64
-
-	query := r.db.WithContext(ctx).Model(&models.Host{})
-
-	// Apply filters based on provided parameters.
-	if params.HostName != nil && *params.HostName != "" {
-		query = query.Where("LOWER(host_name) LIKE LOWER(?)", "%"+*params.HostName+"%")
-	}
-	if params.Address != nil && *params.Address != "" {
-		query = query.Where("LOWER(address) LIKE LOWER(?)", "%"+*params.Address+"%")
-	}
-	if params.Country != nil && *params.Country != "" {
-		query = query.Where("LOWER(country) = LOWER(?)", *params.Country)
-	}
-	if params.City != nil && *params.City != "" {
-		query = query.Where("LOWER(city) = LOWER(?)", *params.City)
+// CountByProvider counts hosts per distinct provider in a single GROUP BY query, optionally restricted to a country.
+// Providers are ordered by total host count, largest first, then by name.
+func (r *hostRepository) CountByProvider(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error) {
+	var counts []customTypes.ProviderHostCounts
+	query := dbFromContext(ctx, r.db).Model(&models.Host{}).
+		Select(`provider,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE is_online = TRUE) AS online,
+			COUNT(*) FILTER (WHERE status = ?) AS active`, customTypes.StatusActive)
+	if country != nil && *country != "" {
+		query = query.Where("LOWER(country) = LOWER(?)", *country)
 	}
-	if params.Protocol != nil && *params.Protocol != "" {
-		query = query.Where("LOWER(protocol) = LOWER(?)", *params.Protocol)
+	if err := query.Group("provider").Order("total DESC, provider ASC").Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count hosts by provider: %w", err)
 	}
-	if params.IsOnline != nil {
-		query = query.Where("is_online = ?", *params.IsOnline)
+	return counts, nil
+}
+
+// CountAvailableByCountry counts online hosts with the 'active' status per country and tier in a single
+// GROUP BY query. Countries are ordered by name.
+func (r *hostRepository) CountAvailableByCountry(ctx context.Context) ([]customTypes.CountryHostAvailability, error) {
+	var counts []customTypes.CountryHostAvailability
+	err := dbFromContext(ctx, r.db).Model(&models.Host{}).
+		Select(`country,
+			COUNT(*) FILTER (WHERE is_free_tier = TRUE) AS free,
+			COUNT(*) FILTER (WHERE is_free_tier = FALSE) AS paid`).
+		Where("is_online = ? AND status = ?", true, customTypes.StatusActive).
+		Group("country").
+		Order("country ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count available hosts by country: %w", err)
 	}
-	if params.IsPrivate != nil {
-		query = query.Where("is_private = ?", *params.IsPrivate)
+	return counts, nil
+}
+
+// AggregateAvailability summarizes the non-private hosts that keys can be generated for (see applySelectableHostFilters)
+// per country in a single GROUP BY query, optionally restricted to free or paid hosts. Countries are ordered by name.
+func (r *hostRepository) AggregateAvailability(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error) {
+	var availability []customTypes.CountryAvailability
+	query := applySelectableHostFilters(dbFromContext(ctx, r.db).Model(&models.Host{}), customTypes.HostSelectionFilter{IsFreeTier: isFreeTier}, true).
+		Where("is_private = ?", false)
+	err := query.
+		Select(`country,
+			COUNT(DISTINCT NULLIF(city, '')) AS city_count,
+			COUNT(*) AS online_host_count,
+			BOOL_OR(is_free_tier) AS has_free_tier`).
+		Group("country").
+		Order("country ASC").
+		Scan(&availability).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate host availability: %w", err)
 	}
-	if params.Network != nil && *params.Network != "" {
-		query = query.Where("LOWER(network) = LOWER(?)", *params.Network)
+	return availability, nil
+}
+
+// GetDeletedByID retrieves a soft-deleted host by its primary key ID.
+// Returns gorm.ErrRecordNotFound if the host does not exist or is not deleted.
+func (r *hostRepository) GetDeletedByID(ctx context.Context, id uint) (*models.Host, error) {
+	var host models.Host
+	if err := dbFromContext(ctx, r.db).Unscoped().Where("deleted_at IS NOT NULL").First(&host, id).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if no soft-deleted host matches.
 	}
-	if params.Status != nil {
-		statusValue := *params.Status
-		if statusValue.IsValid() {
-			query = query.Where("status = ?", statusValue)
+	return &host, nil
+}
+
+// Restore clears the DeletedAt timestamp of a soft-deleted host and returns the restored host.
+// Returns gorm.ErrRecordNotFound if the host does not exist or is not deleted, and
+// interfaces.ErrHostNameTaken if an active host now holds its name under the unique host name index.
+func (r *hostRepository) Restore(ctx context.Context, id uint) (*models.Host, error) {
+	result := dbFromContext(ctx, r.db).Unscoped().Model(&models.Host{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		if uniqueViolationConstraint(result.Error) == models.HostNameUniqueIndex {
+			return nil, fmt.Errorf("failed to restore host: %w", interfaces.ErrHostNameTaken)
 		}
+		return nil, fmt.Errorf("failed to restore host: %w", result.Error)
 	}
-	// Note: No direct filter for IsFreeTier in List, but can be added if needed in ListHostsParams
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound // No soft-deleted host with this ID.
+	}
+	return r.GetByID(ctx, id)
+}
 
+// List retrieves a list of hosts with filtering, pagination, and sorting.
+func (r *hostRepository) List(ctx context.Context, params customTypes.ListHostsParams) ([]models.Host, int64, error) {
+	var hosts []models.Host
+	var totalCount int64
+
+	query := applyHostListFilters(dbFromContext(ctx, r.db).Model(&models.Host{}), params)
 	// Count the total number of records matching the filters before applying pagination.
 	if err := query.Count(&totalCount).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count hosts: %w", err)
@@ -190,6 +310,8 @@ func (r *hostRepository) List(ctx context.Context, params customTypes.ListHostsP
 			"status":     "status",
 			"country":    "country",
 			"city":       "city",
+			"region":     "region",
+			"provider":   "provider",
 		}
 		sortByField := strings.ToLower(params.SortBy)
 		if dbColumn, ok := validSortableColumns[sortByField]; ok {
@@ -217,3 +339,183 @@ func (r *hostRepository) List(ctx context.Context, params customTypes.ListHostsP
 
 	return hosts, totalCount, nil
 }
+
+// ListAfter retrieves up to params.Limit hosts matching the filters in params, ordered by creation date and ID,
+// newest first, starting after the cursor. Unlike List, it seeks with a (created_at, id) comparison instead of
+// an OFFSET, so deep pages stay cheap; params.Offset and the sort parameters are ignored.
+func (r *hostRepository) ListAfter(ctx context.Context, params customTypes.ListHostsParams, after *customTypes.HostListCursor) ([]models.Host, error) {
+	var hosts []models.Host
+	query := applyHostListFilters(dbFromContext(ctx, r.db).Model(&models.Host{}), params).
+		Order("created_at DESC, id DESC").
+		Limit(params.Limit)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+	if err := query.Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list hosts after cursor: %w", err)
+	}
+	return hosts, nil
+}
+
+// applyHostListFilters applies the optional filters of ListHostsParams to a host query.
+func applyHostListFilters(query *gorm.DB, params customTypes.ListHostsParams) *gorm.DB {
+	if params.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if params.HostName != nil && *params.HostName != "" {
+		query = query.Where("LOWER(host_name) LIKE LOWER(?)", "%"+*params.HostName+"%")
+	}
+	if params.Address != nil && *params.Address != "" {
+		query = query.Where("LOWER(address) LIKE LOWER(?)", "%"+*params.Address+"%")
+	}
+	if params.Country != nil && *params.Country != "" {
+		query = query.Where("LOWER(country) = LOWER(?)", *params.Country)
+	}
+	if params.City != nil && *params.City != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", *params.City)
+	}
+	if params.Region != nil && *params.Region != "" {
+		query = query.Where("LOWER(region) = LOWER(?)", *params.Region)
+	}
+	if params.Provider != nil && *params.Provider != "" {
+		query = query.Where("LOWER(provider) = LOWER(?)", *params.Provider)
+	}
+	if params.Protocol != nil && *params.Protocol != "" {
+		query = query.Where("LOWER(protocol) = LOWER(?)", *params.Protocol)
+	}
+	if params.IsOnline != nil {
+		query = query.Where("is_online = ?", *params.IsOnline)
+	}
+	if params.IsPrivate != nil {
+		query = query.Where("is_private = ?", *params.IsPrivate)
+	}
+	if params.IsFreeTier != nil {
+		query = query.Where("is_free_tier = ?", *params.IsFreeTier)
+	}
+	if params.Network != nil && *params.Network != "" {
+		query = query.Where("LOWER(network) = LOWER(?)", *params.Network)
+	}
+	if params.Status != nil {
+		statusValue := *params.Status
+		if statusValue.IsValid() {
+			query = query.Where("status = ?", statusValue)
+		}
+	}
+	return query
+}
+
+// ListSelectableHosts retrieves up to limit online hosts eligible for key issuance that match the filter,
+// ordered by country and ID. If no host with the 'active' status matches, it falls back to any online host.
+func (r *hostRepository) ListSelectableHosts(ctx context.Context, filter customTypes.HostSelectionFilter, limit int) ([]models.Host, error) {
+	hosts, err := r.listSelectableHosts(ctx, filter, limit, true)
+	if err != nil || len(hosts) > 0 {
+		return hosts, err
+	}
+	// Fallback: no host with 'active' status; accept any online host.
+	return r.listSelectableHosts(ctx, filter, limit, false)
+}
+
+// listSelectableHosts performs a single query for ListSelectableHosts.
+// If requireActiveStatus is true, only hosts with the 'active' status are considered.
+func (r *hostRepository) listSelectableHosts(ctx context.Context, filter customTypes.HostSelectionFilter, limit int, requireActiveStatus bool) ([]models.Host, error) {
+	var hosts []models.Host
+	err := applySelectableHostFilters(dbFromContext(ctx, r.db).Model(&models.Host{}), filter, requireActiveStatus).
+		Order("country ASC, id ASC").
+		Limit(limit).
+		Find(&hosts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list selectable hosts: %w", err)
+	}
+	return hosts, nil
+}
+
+// ListCountryCandidates retrieves the best candidates among the selectable hosts of each distinct country matching
+// the filter, in a single query. Within a country, hosts with the 'active' status rank first, then the least issued
+// ones; only the hosts tied for the best rank are candidates, at most perCountry of them (lowest IDs first).
+// Hosts without a country are skipped and issue counters are not changed. Candidates of the first maxCountries
+// countries are returned, ordered by country and ID.
+func (r *hostRepository) ListCountryCandidates(ctx context.Context, filter customTypes.HostSelectionFilter, countries []string, perCountry, maxCountries int) ([]models.Host, error) {
+	ranked := applySelectableHostFilters(dbFromContext(ctx, r.db).Model(&models.Host{}), filter, false).
+		Where("COALESCE(country, '') <> ''")
+	if len(countries) > 0 {
+		lowered := make([]string, len(countries))
+		for i, country := range countries {
+			lowered[i] = strings.ToLower(country)
+		}
+		ranked = ranked.Where("LOWER(country) IN ?", lowered)
+	}
+	ranked = ranked.Select(`hosts.*,
+		DENSE_RANK() OVER (ORDER BY LOWER(country)) AS country_rank,
+		RANK() OVER (PARTITION BY LOWER(country) ORDER BY (status = @active) DESC, issued_count ASC) AS host_rank,
+		ROW_NUMBER() OVER (PARTITION BY LOWER(country) ORDER BY (status = @active) DESC, issued_count ASC, id ASC) AS host_row`,
+		map[string]any{"active": customTypes.StatusActive})
+
+	var hosts []models.Host
+	err := dbFromContext(ctx, r.db).Table("(?) AS candidates", ranked).
+		Where("host_rank = 1 AND host_row <= ? AND country_rank <= ?", perCountry, maxCountries).
+		Order("LOWER(country) ASC, id ASC").
+		Find(&hosts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list host candidates per country: %w", err)
+	}
+	return hosts, nil
+}
+
+// applySelectableHostFilters restricts a host query to online hosts eligible for key issuance.
+// Reality hosts without a public key cannot produce a usable key and are never eligible.
+// Country, network and security type are matched case-insensitively; nil or empty filters are not applied.
+func applySelectableHostFilters(query *gorm.DB, filter customTypes.HostSelectionFilter, requireActiveStatus bool) *gorm.DB {
+	query = query.Where("is_online = ?", true).
+		Where("NOT (LOWER(security_type) = 'reality' AND COALESCE(public_key, '') = '')")
+	if requireActiveStatus {
+		query = query.Where("status = ?", customTypes.StatusActive)
+	}
+	if filter.Country != nil && *filter.Country != "" {
+		query = query.Where("LOWER(country) = LOWER(?)", *filter.Country)
+	}
+	if filter.IsFreeTier != nil {
+		query = query.Where("is_free_tier = ?", *filter.IsFreeTier)
+	}
+	if filter.Network != nil && *filter.Network != "" {
+		query = query.Where("LOWER(network) = LOWER(?)", *filter.Network)
+	}
+	if filter.SecurityType != nil && *filter.SecurityType != "" {
+		query = query.Where("LOWER(security_type) = LOWER(?)", *filter.SecurityType)
+	}
+	if filter.Protocol != nil && *filter.Protocol != "" {
+		query = query.Where("LOWER(protocol) = LOWER(?)", *filter.Protocol)
+	}
+	if filter.AddressFamily != nil && *filter.AddressFamily != "" {
+		query = query.Where("address_family = ?", *filter.AddressFamily)
+	}
+	return query
+}
+
+// CountByStatus counts hosts per status in a single GROUP BY query, ordered by status.
+func (r *hostRepository) CountByStatus(ctx context.Context) ([]customTypes.HostStatusCount, error) {
+	var counts []customTypes.HostStatusCount
+	err := dbFromContext(ctx, r.db).Model(&models.Host{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Order("status ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count hosts by status: %w", err)
+	}
+	return counts, nil
+}
+
+// CountByCountry counts hosts per country in a single GROUP BY query.
+// Countries are ordered by host count, largest first, then by country code.
+func (r *hostRepository) CountByCountry(ctx context.Context) ([]customTypes.CountryHostCount, error) {
+	var counts []customTypes.CountryHostCount
+	err := dbFromContext(ctx, r.db).Model(&models.Host{}).
+		Select("country, COUNT(*) AS count").
+		Group("country").
+		Order("count DESC, country ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count hosts by country: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,1060 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// misconfiguredRealityExclusion is the condition excluding Reality hosts without a public key from selection.
+const misconfiguredRealityExclusion = "NOT (LOWER(security_type) = 'reality' AND COALESCE(public_key, '') = '')"
+
+func TestAcquireLeastIssuedHostExcluding(t *testing.T) {
+	hostRow := sqlfake.Result{
+		Columns: []string{"id", "address", "issued_count"},
+		Rows:    [][]driver.Value{{int64(4), "de1.example.com", int64(8)}},
+	}
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name           string
+		activeResult   sqlfake.Result // Outcome of the attempt restricted to hosts with the 'active' status.
+		fallbackResult sqlfake.Result // Outcome of the attempt accepting any online host.
+		exclude        []uint
+		wantHostID     uint
+		wantAttempts   int
+		wantErr        error
+	}{
+		{name: "active host", activeResult: hostRow, wantHostID: 4, wantAttempts: 1},
+		{name: "falls back to any online host", fallbackResult: hostRow, wantHostID: 4, wantAttempts: 2},
+		{name: "excluded hosts", activeResult: hostRow, exclude: []uint{1, 2}, wantHostID: 4, wantAttempts: 1},
+		{name: "no host", wantAttempts: 2, wantErr: gorm.ErrRecordNotFound},
+		{name: "database error does not fall back", activeResult: sqlfake.Result{Err: dbErr}, wantAttempts: 1, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if slices.Contains(stmt.Args, any(string(customTypes.StatusActive))) {
+					return tt.activeResult
+				}
+				return tt.fallbackResult
+			})
+			country, free := "DE", true
+
+			host, err := NewHostRepository(db).AcquireLeastIssuedHostExcluding(context.Background(), customTypes.HostSelectionFilter{Country: &country, IsFreeTier: &free}, tt.exclude)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("AcquireLeastIssuedHostExcluding() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (host.ID != tt.wantHostID || host.IssuedCount != 8) {
+				t.Errorf("host = %+v, want host %d as returned by the update", host, tt.wantHostID)
+			}
+
+			statements := fake.Queries()
+			if len(statements) != tt.wantAttempts {
+				t.Fatalf("got %d statements, want %d: %v", len(statements), tt.wantAttempts, fake.SQL())
+			}
+			for _, stmt := range statements {
+				for _, fragment := range []string{
+					`UPDATE "hosts" SET "issued_count"=issued_count + 1`,
+					"ORDER BY issued_count ASC, last_issued_at ASC NULLS FIRST, RANDOM() LIMIT",
+					"FOR UPDATE SKIP LOCKED)",
+					"RETURNING *",
+					misconfiguredRealityExclusion,
+				} {
+					if !strings.Contains(stmt.SQL, fragment) {
+						t.Errorf("statement %q does not contain %q", stmt.SQL, fragment)
+					}
+				}
+				if hasExclusion := strings.Contains(stmt.SQL, "id NOT IN"); hasExclusion != (len(tt.exclude) > 0) {
+					t.Errorf("statement %q excludes hosts = %v, want %v", stmt.SQL, hasExclusion, len(tt.exclude) > 0)
+				}
+			}
+		})
+	}
+}
+
+func TestAcquireLeastIssuedHostSQLite(t *testing.T) {
+	lastIssued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		hosts       []models.Host
+		exclude     []uint
+		wantHostIDs []uint // Hosts acquired by consecutive calls, in order.
+		wantErr     error
+	}{
+		{
+			name: "least issued active host first",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: true, Status: customTypes.StatusActive, IssuedCount: 3},
+				{ID: 2, Address: "b", IsOnline: true, Status: customTypes.StatusActive, IssuedCount: 1},
+				{ID: 3, Address: "c", IsOnline: true, Status: customTypes.StatusActive, IssuedCount: 2},
+			},
+			wantHostIDs: []uint{2, 3, 2, 1},
+		},
+		{
+			name: "never issued hosts win ties",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: true, Status: customTypes.StatusActive, LastIssuedAt: &lastIssued},
+				{ID: 2, Address: "b", IsOnline: true, Status: customTypes.StatusActive},
+			},
+			wantHostIDs: []uint{2, 1},
+		},
+		{
+			name: "skips offline, filtered and misconfigured hosts",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: false, Status: customTypes.StatusActive},
+				{ID: 2, Address: "b", IsOnline: true, Status: customTypes.StatusActive, Country: "FR"},
+				{ID: 3, Address: "c", IsOnline: true, Status: customTypes.StatusActive, SecurityType: "reality"},
+				{ID: 4, Address: "d", IsOnline: true, Status: customTypes.StatusActive, IssuedCount: 9},
+			},
+			wantHostIDs: []uint{4},
+		},
+		{
+			name: "excluded hosts",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: true, Status: customTypes.StatusActive},
+				{ID: 2, Address: "b", IsOnline: true, Status: customTypes.StatusActive, IssuedCount: 5},
+			},
+			exclude:     []uint{1},
+			wantHostIDs: []uint{2, 2},
+		},
+		{
+			name: "falls back to any online host",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: true, Status: customTypes.StatusMaintenance},
+			},
+			wantHostIDs: []uint{1},
+		},
+		{
+			name: "no host",
+			hosts: []models.Host{
+				{ID: 1, Address: "a", IsOnline: false, Status: customTypes.StatusActive},
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, gormDB := newSQLiteDatabase(t)
+			for i := range tt.hosts {
+				tt.hosts[i].Port, tt.hosts[i].Protocol = "443", "vless"
+				if tt.hosts[i].Country == "" {
+					tt.hosts[i].Country = "DE"
+				}
+			}
+			if err := gormDB.Create(&tt.hosts).Error; err != nil {
+				t.Fatalf("failed to seed hosts: %v", err)
+			}
+			repo := NewHostRepository(db)
+			country := "de"
+			filter := customTypes.HostSelectionFilter{Country: &country}
+
+			if tt.wantErr != nil {
+				if _, err := repo.AcquireLeastIssuedHostExcluding(context.Background(), filter, tt.exclude); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AcquireLeastIssuedHostExcluding() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			issued := make(map[uint]int64)
+			for _, host := range tt.hosts {
+				issued[host.ID] = host.IssuedCount
+			}
+			for i, wantID := range tt.wantHostIDs {
+				host, err := repo.AcquireLeastIssuedHostExcluding(context.Background(), filter, tt.exclude)
+				if err != nil {
+					t.Fatalf("call %d: AcquireLeastIssuedHostExcluding() error = %v", i, err)
+				}
+				issued[wantID]++
+				if host.ID != wantID || host.IssuedCount != issued[wantID] || host.LastIssuedAt == nil {
+					t.Fatalf("call %d: host = %d with issued_count %d, want %d with %d and last_issued_at set",
+						i, host.ID, host.IssuedCount, wantID, issued[wantID])
+				}
+			}
+
+			var stored []models.Host
+			if err := gormDB.Order("id").Find(&stored).Error; err != nil {
+				t.Fatalf("failed to read hosts: %v", err)
+			}
+			for _, host := range stored {
+				if host.IssuedCount != issued[host.ID] {
+					t.Errorf("host %d issued_count = %d, want %d", host.ID, host.IssuedCount, issued[host.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestListSelectableHostsFilters(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tier := func(free bool) *bool { return &free }
+	family := func(af customTypes.AddressFamily) *customTypes.AddressFamily { return &af }
+	hostRows := sqlfake.Result{
+		Columns: []string{"id", "country"},
+		Rows:    [][]driver.Value{{int64(1), "DE"}, {int64(2), "DE"}},
+	}
+
+	tests := []struct {
+		name         string
+		filter       customTypes.HostSelectionFilter
+		activeHosts  bool // Whether hosts with the 'active' status match; otherwise only the fallback finds hosts.
+		wantClauses  []string
+		wantArgs     []any // Filter arguments expected after the online and status arguments.
+		wantQueries  int
+		wantHostsLen int
+	}{
+		{name: "no filters", activeHosts: true, wantQueries: 1, wantHostsLen: 2},
+		{name: "country", filter: customTypes.HostSelectionFilter{Country: str("de")}, activeHosts: true, wantClauses: []string{"LOWER(country) = LOWER($"}, wantArgs: []any{"de"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "free tier", filter: customTypes.HostSelectionFilter{IsFreeTier: tier(true)}, activeHosts: true, wantClauses: []string{"is_free_tier = $"}, wantArgs: []any{true}, wantQueries: 1, wantHostsLen: 2},
+		{name: "paid tier", filter: customTypes.HostSelectionFilter{IsFreeTier: tier(false)}, activeHosts: true, wantClauses: []string{"is_free_tier = $"}, wantArgs: []any{false}, wantQueries: 1, wantHostsLen: 2},
+		{name: "country and tier", filter: customTypes.HostSelectionFilter{Country: str("DE"), IsFreeTier: tier(true)}, activeHosts: true, wantClauses: []string{"LOWER(country) = LOWER($", "is_free_tier = $"}, wantArgs: []any{"DE", true}, wantQueries: 1, wantHostsLen: 2},
+		{name: "blank country is ignored", filter: customTypes.HostSelectionFilter{Country: str("")}, activeHosts: true, wantQueries: 1, wantHostsLen: 2},
+		{name: "network", filter: customTypes.HostSelectionFilter{Network: str("ws")}, activeHosts: true, wantClauses: []string{"LOWER(network) = LOWER($"}, wantArgs: []any{"ws"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "security type", filter: customTypes.HostSelectionFilter{SecurityType: str("reality")}, activeHosts: true, wantClauses: []string{"LOWER(security_type) = LOWER($"}, wantArgs: []any{"reality"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "country and transport", filter: customTypes.HostSelectionFilter{Country: str("DE"), Network: str("grpc"), SecurityType: str("tls")}, activeHosts: true, wantClauses: []string{"LOWER(country) = LOWER($", "LOWER(network) = LOWER($", "LOWER(security_type) = LOWER($"}, wantArgs: []any{"DE", "grpc", "tls"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "blank transport is ignored", filter: customTypes.HostSelectionFilter{Network: str(""), SecurityType: str("")}, activeHosts: true, wantQueries: 1, wantHostsLen: 2},
+		{name: "address family", filter: customTypes.HostSelectionFilter{AddressFamily: family(customTypes.AddressFamilyIPv6)}, activeHosts: true, wantClauses: []string{"address_family = $"}, wantArgs: []any{"ipv6"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "protocol", filter: customTypes.HostSelectionFilter{Protocol: str("trojan")}, activeHosts: true, wantClauses: []string{"LOWER(protocol) = LOWER($"}, wantArgs: []any{"trojan"}, wantQueries: 1, wantHostsLen: 2},
+		{name: "blank protocol is ignored", filter: customTypes.HostSelectionFilter{Protocol: str("")}, activeHosts: true, wantQueries: 1, wantHostsLen: 2},
+		{name: "blank address family is ignored", filter: customTypes.HostSelectionFilter{AddressFamily: family("")}, activeHosts: true, wantQueries: 1, wantHostsLen: 2},
+		{name: "falls back to any online host", filter: customTypes.HostSelectionFilter{Country: str("DE"), IsFreeTier: tier(true)}, wantClauses: []string{"LOWER(country) = LOWER($", "is_free_tier = $"}, wantArgs: []any{"DE", true}, wantQueries: 2, wantHostsLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if !tt.activeHosts && slices.Contains(stmt.Args, any(string(customTypes.StatusActive))) {
+					return sqlfake.Result{Columns: hostRows.Columns}
+				}
+				return hostRows
+			})
+
+			hosts, err := NewHostRepository(db).ListSelectableHosts(context.Background(), tt.filter, 10)
+			if err != nil {
+				t.Fatalf("ListSelectableHosts() error = %v", err)
+			}
+			if len(hosts) != tt.wantHostsLen {
+				t.Errorf("got %d hosts, want %d", len(hosts), tt.wantHostsLen)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != tt.wantQueries {
+				t.Fatalf("got %d queries, want %d: %v", len(queries), tt.wantQueries, fake.SQL())
+			}
+			for i, query := range queries {
+				requireActiveStatus := i == 0
+				if !strings.Contains(query.SQL, misconfiguredRealityExclusion) {
+					t.Errorf("query %q does not exclude Reality hosts without a public key", query.SQL)
+				}
+				if hasStatus := strings.Contains(query.SQL, "status = $"); hasStatus != requireActiveStatus {
+					t.Errorf("query %d filters on status = %v, want %v", i, hasStatus, requireActiveStatus)
+				}
+				for _, clause := range tt.wantClauses {
+					if !strings.Contains(query.SQL, clause) {
+						t.Errorf("query %q does not contain %q", query.SQL, clause)
+					}
+				}
+				if hasCountry, want := strings.Contains(query.SQL, "LOWER(country)"), tt.filter.Country != nil && *tt.filter.Country != ""; hasCountry != want {
+					t.Errorf("query %q filters on country = %v, want %v", query.SQL, hasCountry, want)
+				}
+				if hasTier, want := strings.Contains(query.SQL, "is_free_tier"), tt.filter.IsFreeTier != nil; hasTier != want {
+					t.Errorf("query %q filters on tier = %v, want %v", query.SQL, hasTier, want)
+				}
+				if hasNetwork, want := strings.Contains(query.SQL, "LOWER(network)"), tt.filter.Network != nil && *tt.filter.Network != ""; hasNetwork != want {
+					t.Errorf("query %q filters on network = %v, want %v", query.SQL, hasNetwork, want)
+				}
+				if hasSecurity, want := strings.Contains(query.SQL, "LOWER(security_type) = LOWER"), tt.filter.SecurityType != nil && *tt.filter.SecurityType != ""; hasSecurity != want {
+					t.Errorf("query %q filters on security type = %v, want %v", query.SQL, hasSecurity, want)
+				}
+				if hasFamily, want := strings.Contains(query.SQL, "address_family"), tt.filter.AddressFamily != nil && *tt.filter.AddressFamily != ""; hasFamily != want {
+					t.Errorf("query %q filters on address family = %v, want %v", query.SQL, hasFamily, want)
+				}
+				// The arguments are is_online, then status on the first query, then the filters and the limit.
+				filterArgs := query.Args[1 : len(query.Args)-1]
+				if requireActiveStatus {
+					filterArgs = filterArgs[1:]
+				}
+				if len(filterArgs) != len(tt.wantArgs) || (len(filterArgs) > 0 && !reflect.DeepEqual(filterArgs, tt.wantArgs)) {
+					t.Errorf("query %d filter args = %v, want %v", i, filterArgs, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestHostNameUniqueViolation(t *testing.T) {
+	otherErr := &pgconn.PgError{Code: "23505", ConstraintName: "idx_hosts_endpoint"}
+
+	tests := []struct {
+		name    string
+		dbErr   error
+		wantErr error
+	}{
+		{name: "host name index", dbErr: &pgconn.PgError{Code: "23505", ConstraintName: models.HostNameUniqueIndex}, wantErr: interfaces.ErrHostNameTaken},
+		{name: "other unique index", dbErr: otherErr, wantErr: otherErr},
+		{name: "no violation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, "INSERT") || strings.HasPrefix(stmt.SQL, "UPDATE") {
+					return sqlfake.Result{RowsAffected: 1, Err: tt.dbErr}
+				}
+				return sqlfake.Result{}
+			})
+			repo := NewHostRepository(db)
+
+			createErr := repo.Create(context.Background(), &models.Host{HostName: "Frankfurt-1", Address: "de1.example.com", Port: "443", Protocol: "vless"})
+			updateErr := repo.Update(context.Background(), &models.Host{ID: 1}, map[string]any{"host_name": "Frankfurt-1"})
+			for op, err := range map[string]error{"Create": createErr, "Update": updateErr} {
+				if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+					t.Errorf("%s() error = %v, want %v", op, err, tt.wantErr)
+				}
+				if tt.wantErr == otherErr && errors.Is(err, interfaces.ErrHostNameTaken) {
+					t.Errorf("%s() reported a taken host name for another index", op)
+				}
+			}
+		})
+	}
+}
+
+func TestListHostsRegionProvider(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	tests := []struct {
+		name        string
+		params      customTypes.ListHostsParams
+		wantClauses []string
+		wantArgs    []any
+		wantOrder   string
+	}{
+		{name: "no filters", wantOrder: "ORDER BY created_at DESC"},
+		{name: "region", params: customTypes.ListHostsParams{Region: text("EU-Central")},
+			wantClauses: []string{"LOWER(region) = LOWER($1)"}, wantArgs: []any{"EU-Central"}, wantOrder: "ORDER BY created_at DESC"},
+		{name: "provider", params: customTypes.ListHostsParams{Provider: text("hetzner")},
+			wantClauses: []string{"LOWER(provider) = LOWER($1)"}, wantArgs: []any{"hetzner"}, wantOrder: "ORDER BY created_at DESC"},
+		{name: "region and provider", params: customTypes.ListHostsParams{Region: text("eu-west"), Provider: text("OVH")},
+			wantClauses: []string{"LOWER(region) = LOWER($1)", "LOWER(provider) = LOWER($2)"}, wantArgs: []any{"eu-west", "OVH"}, wantOrder: "ORDER BY created_at DESC"},
+		{name: "blank values are ignored", params: customTypes.ListHostsParams{Region: text(""), Provider: text("")}, wantOrder: "ORDER BY created_at DESC"},
+		{name: "sorted by region", params: customTypes.ListHostsParams{SortBy: "region"}, wantOrder: "ORDER BY region ASC"},
+		{name: "sorted by provider", params: customTypes.ListHostsParams{SortBy: "Provider", SortOrder: "desc"}, wantOrder: "ORDER BY provider DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "region", "provider"}, Rows: [][]driver.Value{{int64(1), "eu-central", "Hetzner"}}}
+			})
+			tt.params.Limit = 10
+
+			hosts, total, err := NewHostRepository(db).List(context.Background(), tt.params)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if total != 1 || len(hosts) != 1 {
+				t.Errorf("List() returned %d hosts of %d, want 1 of 1", len(hosts), total)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 2 {
+				t.Fatalf("got %d queries, want the count and the select: %v", len(queries), fake.SQL())
+			}
+			for _, query := range queries {
+				for _, clause := range tt.wantClauses {
+					if !strings.Contains(query.SQL, clause) {
+						t.Errorf("query %q does not contain %q", query.SQL, clause)
+					}
+				}
+				if hasRegion, want := strings.Contains(query.SQL, "LOWER(region)"), tt.params.Region != nil && *tt.params.Region != ""; hasRegion != want {
+					t.Errorf("query %q filters on region = %v, want %v", query.SQL, hasRegion, want)
+				}
+				if hasProvider, want := strings.Contains(query.SQL, "LOWER(provider)"), tt.params.Provider != nil && *tt.params.Provider != ""; hasProvider != want {
+					t.Errorf("query %q filters on provider = %v, want %v", query.SQL, hasProvider, want)
+				}
+				if len(tt.wantArgs) > 0 && !reflect.DeepEqual(query.Args[:len(tt.wantArgs)], tt.wantArgs) {
+					t.Errorf("query args = %v, want them to start with %v", query.Args, tt.wantArgs)
+				}
+			}
+			if selectQuery := queries[1].SQL; !strings.Contains(selectQuery, tt.wantOrder) {
+				t.Errorf("query %q does not contain %q", selectQuery, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestListAfterKeyset(t *testing.T) {
+	createdAt := time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		list     func(repoDB *fakeSQLDatabase) error
+		wantArgs []any // Arguments of the keyset comparison; nil on the first page.
+	}{
+		{
+			name: "hosts first page",
+			list: func(repoDB *fakeSQLDatabase) error {
+				_, err := NewHostRepository(repoDB).ListAfter(context.Background(), customTypes.ListHostsParams{Limit: 11}, nil)
+				return err
+			},
+		},
+		{
+			name: "hosts after cursor",
+			list: func(repoDB *fakeSQLDatabase) error {
+				_, err := NewHostRepository(repoDB).ListAfter(context.Background(), customTypes.ListHostsParams{Limit: 11, Offset: 500},
+					&customTypes.HostListCursor{CreatedAt: createdAt, ID: 42})
+				return err
+			},
+			wantArgs: []any{createdAt, int64(42)},
+		},
+		{
+			name: "users after cursor",
+			list: func(repoDB *fakeSQLDatabase) error {
+				_, err := NewUserRepository(repoDB).ListAfter(context.Background(), customTypes.ListUsersParams{Limit: 11, Offset: 500},
+					&customTypes.UserListCursor{CreatedAt: createdAt, ID: userID})
+				return err
+			},
+			wantArgs: []any{createdAt, userID.String()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return sqlfake.Result{Columns: []string{"id"}} })
+
+			if err := tt.list(db); err != nil {
+				t.Fatalf("ListAfter() error = %v", err)
+			}
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1 without a count: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			if !strings.HasSuffix(query.SQL, "ORDER BY created_at DESC, id DESC LIMIT $"+strconv.Itoa(len(query.Args))) || query.Args[len(query.Args)-1] != int64(11) {
+				t.Errorf("query %q with args %v does not return the newest 11 rows", query.SQL, query.Args)
+			}
+			if strings.Contains(query.SQL, "OFFSET") {
+				t.Errorf("query %q skips rows with OFFSET, want a keyset seek", query.SQL)
+			}
+			if hasSeek := strings.Contains(query.SQL, "(created_at, id) < ($1, $2)"); hasSeek != (tt.wantArgs != nil) {
+				t.Errorf("query %q seeks past a cursor = %v, want %v", query.SQL, hasSeek, tt.wantArgs != nil)
+			}
+			if tt.wantArgs != nil && !reflect.DeepEqual(query.Args[:len(tt.wantArgs)], tt.wantArgs) {
+				t.Errorf("query args = %v, want them to start with %v", query.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// BenchmarkListHostsDeepPage fetches a page 10 000 pages deep with both pagination modes. sqlfake answers instantly,
+// so the timings only cover the client side; the rows-skipped/op metric shows what the database has to read past
+// on every request: the offset for List, nothing for ListAfter.
+func BenchmarkListHostsDeepPage(b *testing.B) {
+	const pageSize, depth = 10, 10_000
+	hostRows := sqlfake.Result{Columns: []string{"id", "created_at"}}
+	for i := range pageSize {
+		hostRows.Rows = append(hostRows.Rows, []driver.Value{int64(i + 1), time.Now()})
+	}
+
+	benchmarks := []struct {
+		name string
+		list func(repo interfaces.HostRepository) error
+	}{
+		{name: "offset", list: func(repo interfaces.HostRepository) error {
+			_, _, err := repo.List(context.Background(), customTypes.ListHostsParams{Offset: depth * pageSize, Limit: pageSize})
+			return err
+		}},
+		{name: "keyset", list: func(repo interfaces.HostRepository) error {
+			_, err := repo.ListAfter(context.Background(), customTypes.ListHostsParams{Limit: pageSize},
+				&customTypes.HostListCursor{CreatedAt: time.Now(), ID: depth * pageSize})
+			return err
+		}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			db, fake := newFakeSQLDatabase(b, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(depth * pageSize * 2)}}}
+				}
+				return hostRows
+			})
+			repo := NewHostRepository(db)
+
+			for b.Loop() {
+				if err := bm.list(repo); err != nil {
+					b.Fatalf("list error = %v", err)
+				}
+			}
+
+			// GORM binds the offset as the last argument of the page query.
+			queries := fake.Queries()
+			page := queries[len(queries)-1]
+			var skipped int64
+			if strings.Contains(page.SQL, "OFFSET") {
+				skipped = page.Args[len(page.Args)-1].(int64)
+			}
+			b.ReportMetric(float64(skipped), "rows-skipped/op")
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name         string
+		updateResult sqlfake.Result
+		wantErr      error
+		wantHost     bool
+	}{
+		{name: "deleted host", updateResult: sqlfake.Result{RowsAffected: 1}, wantHost: true},
+		{name: "no deleted host", updateResult: sqlfake.Result{RowsAffected: 0}, wantErr: gorm.ErrRecordNotFound},
+		{name: "host name taken", updateResult: sqlfake.Result{Err: &pgconn.PgError{Code: "23505", ConstraintName: models.HostNameUniqueIndex}}, wantErr: interfaces.ErrHostNameTaken},
+		{name: "database error", updateResult: sqlfake.Result{Err: dbErr}, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, "UPDATE") {
+					return tt.updateResult
+				}
+				return sqlfake.Result{Columns: []string{"id", "address"}, Rows: [][]driver.Value{{int64(7), "de1.example.com"}}}
+			})
+
+			host, err := NewHostRepository(db).Restore(context.Background(), 7)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Restore() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantHost && (host == nil || host.ID != 7) {
+				t.Errorf("Restore() = %+v, want the restored host 7", host)
+			}
+
+			// A restored host is read back after the update.
+			wantQueries := 1
+			if tt.wantHost {
+				wantQueries = 2
+			}
+			queries := fake.Queries()
+			if len(queries) != wantQueries {
+				t.Fatalf("got %d queries, want %d: %v", len(queries), wantQueries, fake.SQL())
+			}
+			update := queries[0]
+			for _, fragment := range []string{`UPDATE "hosts" SET "deleted_at"=$1`, "id = $", "deleted_at IS NOT NULL"} {
+				if !strings.Contains(update.SQL, fragment) {
+					t.Errorf("statement %q does not contain %q", update.SQL, fragment)
+				}
+			}
+			if strings.Contains(update.SQL, `"hosts"."deleted_at" IS NULL`) {
+				t.Errorf("statement %q is scoped to non-deleted hosts, want it unscoped", update.SQL)
+			}
+			if update.Args[0] != nil {
+				t.Errorf("deleted_at set to %v, want NULL", update.Args[0])
+			}
+			if tt.wantHost && !strings.Contains(queries[1].SQL, `"hosts"."deleted_at" IS NULL`) {
+				t.Errorf("restored host read with %q, want it read as a non-deleted host", queries[1].SQL)
+			}
+		})
+	}
+}
+
+func TestGetDeletedByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantErr error
+	}{
+		{name: "deleted host", rows: [][]driver.Value{{int64(7), time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)}}},
+		{name: "missing or not deleted", wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"id", "deleted_at"}, Rows: tt.rows}
+			})
+
+			host, err := NewHostRepository(db).GetDeletedByID(context.Background(), 7)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetDeletedByID() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (host.ID != 7 || !host.DeletedAt.Valid) {
+				t.Errorf("GetDeletedByID() = %+v, want deleted host 7", host)
+			}
+			query := fake.Queries()[0].SQL
+			if !strings.Contains(query, "deleted_at IS NOT NULL") || strings.Contains(query, `"hosts"."deleted_at" IS NULL`) {
+				t.Errorf("query %q does not select only deleted hosts", query)
+			}
+		})
+	}
+}
+
+func TestCountByProvider(t *testing.T) {
+	country := func(c string) *string { return &c }
+	providerRows := sqlfake.Result{
+		Columns: []string{"provider", "total", "online", "active"},
+		Rows: [][]driver.Value{
+			{"Hetzner", int64(5), int64(4), int64(3)},
+			{"OVH", int64(2), int64(2), int64(2)},
+			{"", int64(1), int64(0), int64(0)},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		country     *string
+		wantCountry bool
+	}{
+		{name: "all countries"},
+		{name: "one country", country: country("de"), wantCountry: true},
+		{name: "blank country is ignored", country: country("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return providerRows })
+
+			counts, err := NewHostRepository(db).CountByProvider(context.Background(), tt.country)
+			if err != nil {
+				t.Fatalf("CountByProvider() error = %v", err)
+			}
+			want := []customTypes.ProviderHostCounts{
+				{Provider: "Hetzner", Total: 5, Online: 4, Active: 3},
+				{Provider: "OVH", Total: 2, Online: 2, Active: 2},
+				{Provider: "", Total: 1},
+			}
+			if !reflect.DeepEqual(counts, want) {
+				t.Errorf("CountByProvider() = %+v, want %+v", counts, want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{
+				"COUNT(*) AS total",
+				"COUNT(*) FILTER (WHERE is_online = TRUE) AS online",
+				"COUNT(*) FILTER (WHERE status = $1) AS active",
+				`"hosts"."deleted_at" IS NULL`,
+				"GROUP BY \"provider\" ORDER BY total DESC, provider ASC",
+			} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			wantArgs := []any{string(customTypes.StatusActive)}
+			if tt.wantCountry {
+				wantArgs = append(wantArgs, *tt.country)
+			}
+			if hasCountry := strings.Contains(query.SQL, "LOWER(country) = LOWER($2)"); hasCountry != tt.wantCountry {
+				t.Errorf("query %q filters on country = %v, want %v", query.SQL, hasCountry, tt.wantCountry)
+			}
+			if !reflect.DeepEqual(query.Args, wantArgs) {
+				t.Errorf("query args = %v, want %v", query.Args, wantArgs)
+			}
+		})
+	}
+}
+
+func TestUpdateWritesOnlyChangedColumns(t *testing.T) {
+	tests := []struct {
+		name         string
+		changes      map[string]any
+		rowsAffected int64
+		wantColumns  []string // Columns expected in the SET clause besides updated_at; nil if no statement is sent.
+		wantErr      error
+	}{
+		{name: "metadata edit", changes: map[string]any{"notes": "Reboot via panel"}, rowsAffected: 1, wantColumns: []string{"notes"}},
+		{name: "status update", changes: map[string]any{"is_online": true, "status": "active"}, rowsAffected: 1, wantColumns: []string{"is_online", "status"}},
+		{name: "no changes"},
+		{name: "host deleted concurrently", changes: map[string]any{"notes": "Reboot via panel"}, wantColumns: []string{"notes"}, wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return sqlfake.Result{RowsAffected: tt.rowsAffected} })
+			// The model carries stale values for every column; only the changed ones may be written.
+			host := &models.Host{ID: 7, HostName: "Frankfurt-1", Address: "de1.example.com", Port: "443", IsOnline: false, Status: "inactive", Notes: "Stale"}
+
+			err := NewHostRepository(db).Update(context.Background(), host, tt.changes)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			queries := fake.Queries()
+			if tt.wantColumns == nil {
+				if len(queries) != 0 {
+					t.Errorf("statements = %v, want none", fake.SQL())
+				}
+				return
+			}
+			if len(queries) != 1 {
+				t.Fatalf("got %d statements, want 1: %v", len(queries), fake.SQL())
+			}
+			update := queries[0].SQL
+			set, where, _ := strings.Cut(strings.TrimPrefix(update, `UPDATE "hosts" SET `), " WHERE ")
+			var columns []string
+			for _, assignment := range strings.Split(set, ",") {
+				column, _, _ := strings.Cut(assignment, "=")
+				if column = strings.Trim(column, `"`); column != "updated_at" {
+					columns = append(columns, column)
+				}
+			}
+			if !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("statement %q sets %v, want %v", update, columns, tt.wantColumns)
+			}
+			if !strings.Contains(where, `"hosts"."deleted_at" IS NULL`) || !strings.Contains(where, `"id" = $`) {
+				t.Errorf("statement %q does not target the non-deleted host by ID", update)
+			}
+		})
+	}
+}
+
+func TestCountAvailableByCountry(t *testing.T) {
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name    string
+		result  sqlfake.Result
+		want    []customTypes.CountryHostAvailability
+		wantErr error
+	}{
+		{
+			name: "counts",
+			result: sqlfake.Result{
+				Columns: []string{"country", "free", "paid"},
+				Rows:    [][]driver.Value{{"", int64(1), int64(0)}, {"DE", int64(1), int64(2)}, {"US", int64(0), int64(1)}},
+			},
+			want: []customTypes.CountryHostAvailability{{Country: "", Free: 1}, {Country: "DE", Free: 1, Paid: 2}, {Country: "US", Paid: 1}},
+		},
+		{name: "no available hosts", result: sqlfake.Result{Columns: []string{"country", "free", "paid"}}},
+		{name: "database error", result: sqlfake.Result{Err: dbErr}, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+
+			counts, err := NewHostRepository(db).CountAvailableByCountry(context.Background())
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CountAvailableByCountry() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("CountAvailableByCountry() = %+v, want %+v", counts, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{
+				"COUNT(*) FILTER (WHERE is_free_tier = TRUE) AS free",
+				"COUNT(*) FILTER (WHERE is_free_tier = FALSE) AS paid",
+				"is_online = $1 AND status = $2",
+				`"hosts"."deleted_at" IS NULL`,
+				`GROUP BY "country" ORDER BY country ASC`,
+			} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			if wantArgs := []any{true, string(customTypes.StatusActive)}; !reflect.DeepEqual(query.Args, wantArgs) {
+				t.Errorf("query args = %v, want %v", query.Args, wantArgs)
+			}
+		})
+	}
+}
+
+func TestListAllHosts(t *testing.T) {
+	db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+		return sqlfake.Result{Columns: []string{"id", "address"}, Rows: [][]driver.Value{{int64(1), "de1.example.com"}, {int64(2), "us1.example.com"}}}
+	})
+
+	hosts, err := NewHostRepository(db).ListAll(context.Background())
+	if err != nil {
+		t.Fatalf("ListAll() error = %v", err)
+	}
+	if len(hosts) != 2 || hosts[0].ID != 1 || hosts[1].Address != "us1.example.com" {
+		t.Errorf("ListAll() = %+v, want both hosts", hosts)
+	}
+	queries := fake.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("got %d queries, want 1", len(queries))
+	}
+	for _, fragment := range []string{`"hosts"."deleted_at" IS NULL`, "ORDER BY id ASC"} {
+		if !strings.Contains(queries[0].SQL, fragment) {
+			t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+		}
+	}
+	if strings.Contains(queries[0].SQL, "LIMIT") {
+		t.Errorf("query %q is limited, want every host", queries[0].SQL)
+	}
+}
+
+func TestListHostsTierFilter(t *testing.T) {
+	tier := func(free bool) *bool { return &free }
+
+	tests := []struct {
+		name     string
+		tier     *bool
+		wantArgs []any // Nil if the query must not filter on the tier.
+	}{
+		{name: "all tiers"},
+		{name: "free", tier: tier(true), wantArgs: []any{true}},
+		{name: "paid", tier: tier(false), wantArgs: []any{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "is_free_tier"}, Rows: [][]driver.Value{{int64(1), true}}}
+			})
+
+			hosts, _, err := NewHostRepository(db).List(context.Background(), customTypes.ListHostsParams{IsFreeTier: tt.tier, Limit: 10})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(hosts) != 1 || !hosts[0].IsFreeTier {
+				t.Errorf("List() = %+v, want the free host", hosts)
+			}
+			for _, query := range fake.Queries() {
+				if filtered := strings.Contains(query.SQL, "is_free_tier = $1"); filtered != (tt.wantArgs != nil) {
+					t.Errorf("query %q filters on the tier = %t, want %t", query.SQL, filtered, tt.wantArgs != nil)
+				}
+				if tt.wantArgs != nil && !reflect.DeepEqual(query.Args[:1], tt.wantArgs) {
+					t.Errorf("query args = %v, want them to start with %v", query.Args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregateAvailability(t *testing.T) {
+	free, paid := true, false
+
+	tests := []struct {
+		name       string
+		isFreeTier *bool
+		wantArgs   []any
+	}{
+		{name: "all tiers", wantArgs: []any{true, string(customTypes.StatusActive), false}},
+		{name: "free tier", isFreeTier: &free, wantArgs: []any{true, string(customTypes.StatusActive), true, false}},
+		{name: "paid tier", isFreeTier: &paid, wantArgs: []any{true, string(customTypes.StatusActive), false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{
+					Columns: []string{"country", "city_count", "online_host_count", "has_free_tier"},
+					Rows:    [][]driver.Value{{"DE", int64(2), int64(3), true}, {"NL", int64(1), int64(2), false}},
+				}
+			})
+
+			availability, err := NewHostRepository(db).AggregateAvailability(context.Background(), tt.isFreeTier)
+			if err != nil {
+				t.Fatalf("AggregateAvailability() error = %v", err)
+			}
+			want := []customTypes.CountryAvailability{
+				{Country: "DE", CityCount: 2, OnlineHostCount: 3, HasFreeTier: true},
+				{Country: "NL", CityCount: 1, OnlineHostCount: 2},
+			}
+			if !reflect.DeepEqual(availability, want) {
+				t.Errorf("AggregateAvailability() = %+v, want %+v", availability, want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{
+				"COUNT(DISTINCT NULLIF(city, '')) AS city_count",
+				"COUNT(*) AS online_host_count",
+				"BOOL_OR(is_free_tier) AS has_free_tier",
+				"NOT (LOWER(security_type) = 'reality' AND COALESCE(public_key, '') = '')",
+				"is_private = $",
+				`"hosts"."deleted_at" IS NULL`,
+				`GROUP BY "country" ORDER BY country ASC`,
+			} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			if hasTier := strings.Contains(query.SQL, "is_free_tier = $"); hasTier != (tt.isFreeTier != nil) {
+				t.Errorf("query %q filters on the tier = %t, want %t", query.SQL, hasTier, tt.isFreeTier != nil)
+			}
+			if !reflect.DeepEqual(query.Args, tt.wantArgs) {
+				t.Errorf("query args = %v, want %v", query.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestListHostsIncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		includeDeleted bool
+	}{
+		{name: "deleted hosts excluded by default"},
+		{name: "deleted hosts included", includeDeleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "deleted_at"}, Rows: [][]driver.Value{{int64(1), deletedAt}}}
+			})
+
+			hosts, _, err := NewHostRepository(db).List(context.Background(), customTypes.ListHostsParams{IncludeDeleted: tt.includeDeleted, Limit: 10})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(hosts) != 1 || !hosts[0].DeletedAt.Valid || !hosts[0].DeletedAt.Time.Equal(deletedAt) {
+				t.Errorf("List() = %+v, want the host with its deletion time", hosts)
+			}
+			for _, query := range fake.Queries() {
+				if excluded := strings.Contains(query.SQL, `"hosts"."deleted_at" IS NULL`); excluded == tt.includeDeleted {
+					t.Errorf("query %q excludes deleted hosts = %t, want %t", query.SQL, excluded, !tt.includeDeleted)
+				}
+			}
+		})
+	}
+}
+
+func TestHostOverviewCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     func(repo *hostRepository) (any, error)
+		result    sqlfake.Result
+		want      any
+		fragments []string
+	}{
+		{
+			name:   "by status",
+			count:  func(repo *hostRepository) (any, error) { return repo.CountByStatus(context.Background()) },
+			result: sqlfake.Result{Columns: []string{"status", "count"}, Rows: [][]driver.Value{{"active", int64(2)}, {"unknown", int64(1)}}},
+			want:   []customTypes.HostStatusCount{{Status: customTypes.StatusActive, Count: 2}, {Status: customTypes.StatusUnknown, Count: 1}},
+			fragments: []string{
+				"SELECT status, COUNT(*) AS count",
+				`"hosts"."deleted_at" IS NULL`,
+				`GROUP BY "status" ORDER BY status ASC`,
+			},
+		},
+		{
+			name:   "by country",
+			count:  func(repo *hostRepository) (any, error) { return repo.CountByCountry(context.Background()) },
+			result: sqlfake.Result{Columns: []string{"country", "count"}, Rows: [][]driver.Value{{"DE", int64(3)}, {"", int64(1)}}},
+			want:   []customTypes.CountryHostCount{{Country: "DE", Count: 3}, {Country: "", Count: 1}},
+			fragments: []string{
+				"SELECT country, COUNT(*) AS count",
+				`"hosts"."deleted_at" IS NULL`,
+				`GROUP BY "country" ORDER BY count DESC, country ASC`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+
+			got, err := tt.count(NewHostRepository(db).(*hostRepository))
+			if err != nil {
+				t.Fatalf("count error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("count = %+v, want %+v", got, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			for _, fragment := range tt.fragments {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestListCountryCandidates(t *testing.T) {
+	free := true
+	tests := []struct {
+		name        string
+		countries   []string
+		wantClauses []string
+		wantArgs    []any
+	}{
+		{
+			name:     "every country",
+			wantArgs: []any{"active", "active", true, true, int64(8), int64(5)},
+		},
+		{
+			name:        "requested countries",
+			countries:   []string{"DE", "Nl"},
+			wantClauses: []string{"LOWER(country) IN ($5,$6)"},
+			wantArgs:    []any{"active", "active", true, true, "de", "nl", int64(8), int64(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{
+					Columns: []string{"id", "country"},
+					Rows:    [][]driver.Value{{int64(1), "DE"}, {int64(4), "DE"}, {int64(2), "NL"}},
+				}
+			})
+
+			hosts, err := NewHostRepository(db).ListCountryCandidates(context.Background(), customTypes.HostSelectionFilter{IsFreeTier: &free}, tt.countries, 8, 5)
+			if err != nil {
+				t.Fatalf("ListCountryCandidates() error = %v", err)
+			}
+			if len(hosts) != 3 || hosts[0].ID != 1 || hosts[1].ID != 4 || hosts[2].ID != 2 {
+				t.Errorf("ListCountryCandidates() = %+v, want hosts 1, 4 and 2", hosts)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			if strings.Contains(query.SQL, "RANDOM()") {
+				t.Errorf("query %q still picks hosts in SQL", query.SQL)
+			}
+			for _, clause := range append([]string{
+				"DENSE_RANK() OVER (ORDER BY LOWER(country)) AS country_rank",
+				"RANK() OVER (PARTITION BY LOWER(country) ORDER BY (status = $1) DESC, issued_count ASC) AS host_rank",
+				"ROW_NUMBER() OVER (PARTITION BY LOWER(country) ORDER BY (status = $2) DESC, issued_count ASC, id ASC) AS host_row",
+				"COALESCE(country, '') <> ''",
+				"host_rank = 1 AND host_row <= $",
+				"AND country_rank <= $",
+				`"hosts"."deleted_at" IS NULL`,
+				"ORDER BY LOWER(country) ASC, id ASC",
+				misconfiguredRealityExclusion,
+			}, tt.wantClauses...) {
+				if !strings.Contains(query.SQL, clause) {
+					t.Errorf("query %q does not contain %q", query.SQL, clause)
+				}
+			}
+			if !reflect.DeepEqual(query.Args, tt.wantArgs) {
+				t.Errorf("query args = %#v, want %#v", query.Args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// keyAssignmentRepository implements the interfaces.KeyAssignmentRepository for interacting with key assignment data in a SQL database.
+type keyAssignmentRepository struct {
+	db *gorm.DB
+}
+
+// NewKeyAssignmentRepository creates a new instance of keyAssignmentRepository.
+func NewKeyAssignmentRepository(sqlDB interfaces.SQLDatabase) interfaces.KeyAssignmentRepository {
+	return &keyAssignmentRepository{
+		db: sqlDB.GetGormClient(),
+	}
+}
+
+// Create persists a new key assignment record to the database and increments its host's current_users
+// in the same transaction.
+func (r *keyAssignmentRepository) Create(ctx context.Context, assignment *models.KeyAssignment) error {
+	if assignment == nil {
+		return errors.New("key assignment to create cannot be nil")
+	}
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return createKeyAssignment(tx, assignment)
+	})
+}
+
+// Reassign deactivates all active key assignments of the assignment's user and creates the new assignment
+// in a single transaction. The previous assignments are locked while they are deactivated, and the
+// current_users counters of their hosts and of the new host are adjusted accordingly.
+func (r *keyAssignmentRepository) Reassign(ctx context.Context, assignment *models.KeyAssignment) ([]models.KeyAssignment, error) {
+	if assignment == nil {
+		return nil, errors.New("key assignment to create cannot be nil")
+	}
+
+	var previous []models.KeyAssignment
+	err := dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_active = ?", assignment.UserID, true).
+			Find(&previous).Error; err != nil {
+			return fmt.Errorf("failed to lock active key assignments of user %s: %w", assignment.UserID, err)
+		}
+
+		if len(previous) > 0 {
+			ids := make([]uuid.UUID, len(previous))
+			revokedPerHost := make(map[uint]int64)
+			for i := range previous {
+				ids[i] = previous[i].ID
+				revokedPerHost[previous[i].HostID]++
+			}
+			if err := tx.Model(&models.KeyAssignment{}).Where("id IN ?", ids).Update("is_active", false).Error; err != nil {
+				return fmt.Errorf("failed to deactivate key assignments of user %s: %w", assignment.UserID, err)
+			}
+			for hostID, revoked := range revokedPerHost {
+				if err := tx.Unscoped().Model(&models.Host{}).Where("id = ?", hostID).
+					UpdateColumn("current_users", gorm.Expr("GREATEST(current_users - ?, 0)", revoked)).Error; err != nil {
+					return fmt.Errorf("failed to decrement current users of host %d: %w", hostID, err)
+				}
+			}
+		}
+
+		return createKeyAssignment(tx, assignment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range previous {
+		previous[i].IsActive = false
+	}
+	return previous, nil
+}
+
+// GetLatestActiveByUserID retrieves the most recently created active key assignment for a user,
+// together with its host. Assignments whose host has been deleted are ignored.
+// Returns gorm.ErrRecordNotFound if the user has no such assignment.
+func (r *keyAssignmentRepository) GetLatestActiveByUserID(ctx context.Context, userID uuid.UUID) (*models.KeyAssignment, error) {
+	var assignment models.KeyAssignment
+	err := dbFromContext(ctx, r.db).
+		Joins("JOIN hosts ON hosts.id = key_assignments.host_id AND hosts.deleted_at IS NULL").
+		Preload("Host").
+		Where("key_assignments.user_id = ? AND key_assignments.is_active = ?", userID, true).
+		Order("key_assignments.created_at DESC").
+		First(&assignment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get latest key assignment for user %s: %w", userID, err)
+	}
+	return &assignment, nil
+}
+
+// createKeyAssignment creates an active key assignment within tx and increments its host's current_users.
+func createKeyAssignment(tx *gorm.DB, assignment *models.KeyAssignment) error {
+	if err := tx.Create(assignment).Error; err != nil {
+		return fmt.Errorf("failed to create key assignment: %w", err)
+	}
+	if !assignment.IsActive {
+		return nil
+	}
+	if err := tx.Model(&models.Host{}).Where("id = ?", assignment.HostID).
+		UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error; err != nil {
+		return fmt.Errorf("failed to increment current users of host %d: %w", assignment.HostID, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package sql
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReassign(t *testing.T) {
+	insertErr := errors.New("foreign key violation")
+
+	tests := []struct {
+		name         string
+		activeHosts  []int64 // Hosts of the user's active assignments.
+		insertErr    error
+		wantRevoked  int
+		wantCounters map[int64]int64 // Change of current_users by host ID.
+		wantEnd      string
+	}{
+		{name: "assignments on two hosts", activeHosts: []int64{1, 1, 2}, wantRevoked: 3,
+			wantCounters: map[int64]int64{1: -2, 2: -1, 3: 1}, wantEnd: "COMMIT"},
+		{name: "assignment on the target host", activeHosts: []int64{3}, wantRevoked: 1,
+			wantCounters: map[int64]int64{3: 0}, wantEnd: "COMMIT"},
+		{name: "no active assignments", wantCounters: map[int64]int64{3: 1}, wantEnd: "COMMIT"},
+		{name: "insert fails", activeHosts: []int64{1}, insertErr: insertErr, wantEnd: "ROLLBACK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				switch {
+				case strings.HasPrefix(stmt.SQL, `SELECT * FROM "key_assignments"`):
+					result := sqlfake.Result{Columns: []string{"id", "user_id", "host_id", "is_active"}}
+					for _, hostID := range tt.activeHosts {
+						result.Rows = append(result.Rows, []driver.Value{uuid.NewString(), userID.String(), hostID, true})
+					}
+					return result
+				case strings.HasPrefix(stmt.SQL, `INSERT INTO "key_assignments"`):
+					return sqlfake.Result{RowsAffected: 1, Err: tt.insertErr}
+				}
+				return sqlfake.Result{RowsAffected: 1}
+			})
+
+			previous, err := NewKeyAssignmentRepository(db).Reassign(context.Background(), &models.KeyAssignment{UserID: userID, HostID: 3, IsActive: true})
+			if !errors.Is(err, tt.insertErr) || (tt.insertErr == nil && err != nil) {
+				t.Fatalf("Reassign() error = %v, want %v", err, tt.insertErr)
+			}
+			statements := fake.SQL()
+			if got := statements[len(statements)-1]; got != tt.wantEnd {
+				t.Errorf("transaction ended with %q, want %q", got, tt.wantEnd)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(previous) != tt.wantRevoked {
+				t.Errorf("got %d previous assignments, want %d", len(previous), tt.wantRevoked)
+			}
+			for _, assignment := range previous {
+				if assignment.IsActive {
+					t.Errorf("previous assignment %s is still active", assignment.ID)
+				}
+			}
+			if !strings.Contains(fake.Queries()[0].SQL, "FOR UPDATE") {
+				t.Errorf("active assignments are not locked: %q", fake.Queries()[0].SQL)
+			}
+
+			counters := make(map[int64]int64)
+			for _, stmt := range fake.Queries() {
+				switch {
+				case strings.Contains(stmt.SQL, "GREATEST(current_users - $1, 0) WHERE id = $2"):
+					counters[stmt.Args[1].(int64)] -= stmt.Args[0].(int64)
+				case strings.Contains(stmt.SQL, `"current_users"=current_users + 1 WHERE id = $1`):
+					counters[stmt.Args[0].(int64)]++
+				}
+			}
+			if !maps.Equal(counters, tt.wantCounters) {
+				t.Errorf("current_users changes = %v, want %v; statements %v", counters, tt.wantCounters, statements)
+			}
+		})
+	}
+}
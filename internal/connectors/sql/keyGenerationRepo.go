@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// keyGenerationRepository implements the interfaces.KeyGenerationRepository for interacting with the key generation log in a SQL database.
+type keyGenerationRepository struct {
+	db *gorm.DB
+}
+
+// NewKeyGenerationRepository creates a new instance of keyGenerationRepository.
+func NewKeyGenerationRepository(sqlDB interfaces.SQLDatabase) interfaces.KeyGenerationRepository {
+	return &keyGenerationRepository{
+		db: sqlDB.GetGormClient(),
+	}
+}
+
+// Create persists a new key generation log entry to the database.
+func (r *keyGenerationRepository) Create(ctx context.Context, generation *models.KeyGeneration) error {
+	if generation == nil {
+		return errors.New("key generation to create cannot be nil")
+	}
+	return dbFromContext(ctx, r.db).Create(generation).Error
+}
+
+// CountByBucket counts the keys generated in [from, to) per bucket of the given width in a single GROUP BY query.
+// Buckets are numbered from 0 starting at from; buckets without any generated key are omitted.
+func (r *keyGenerationRepository) CountByBucket(ctx context.Context, from, to time.Time, bucket time.Duration) ([]customTypes.KeyGenerationBucketCounts, error) {
+	if bucket <= 0 {
+		return nil, errors.New("bucket width must be positive")
+	}
+	var counts []customTypes.KeyGenerationBucketCounts
+	err := dbFromContext(ctx, r.db).Model(&models.KeyGeneration{}).
+		Select(`FLOOR(EXTRACT(EPOCH FROM (created_at - CAST(? AS timestamptz))) / ?)::bigint AS bucket,
+			COUNT(*) FILTER (WHERE user_id IS NULL) AS free_keys,
+			COUNT(*) FILTER (WHERE user_id IS NOT NULL) AS user_keys`, from, bucket.Seconds()).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("bucket").
+		Order("bucket").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count key generations between %s and %s: %w", from, to, err)
+	}
+	return counts, nil
+}
+
+// CountConversionFunnel counts the users issued a free-tier key in [from, to) and how many of them created a
+// subscription, and a paid one, after their first free-tier key in that period. Subscriptions created after
+// the period still count, so recent free users have had less time to convert.
+func (r *keyGenerationRepository) CountConversionFunnel(ctx context.Context, from, to time.Time) (*customTypes.ConversionFunnelCounts, error) {
+	var counts customTypes.ConversionFunnelCounts
+	err := dbFromContext(ctx, r.db).Raw(`
+WITH free_users AS (
+	SELECT user_id, MIN(created_at) AS first_free_key_at
+	FROM key_generations
+	WHERE user_id IS NOT NULL AND is_free_tier AND created_at >= @from AND created_at < @to
+	GROUP BY user_id
+), conversions AS (
+	SELECT f.user_id,
+		BOOL_OR(s.id IS NOT NULL) AS subscribed,
+		BOOL_OR(s.payment_status = 'paid') AS paid
+	FROM free_users f
+	LEFT JOIN subscriptions s ON s.user_id = f.user_id AND s.created_at > f.first_free_key_at AND s.deleted_at IS NULL
+	GROUP BY f.user_id
+)
+SELECT COUNT(*) AS free_key_users,
+	COUNT(*) FILTER (WHERE subscribed) AS subscribed,
+	COUNT(*) FILTER (WHERE paid) AS paid
+FROM conversions`, map[string]interface{}{"from": from, "to": to}).Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count conversion funnel between %s and %s: %w", from, to, err)
+	}
+	return &counts, nil
+}
+
+// CountSince counts the keys generated at or after since.
+func (r *keyGenerationRepository) CountSince(ctx context.Context, since time.Time) (int64, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&models.KeyGeneration{}).
+		Where("created_at >= ?", since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count key generations since %s: %w", since, err)
+	}
+	return count, nil
+}
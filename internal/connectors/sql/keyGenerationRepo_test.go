@@ -0,0 +1,154 @@
+package sql
+
+import (
+	"bitback/internal/models/customTypes"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountByBucket(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		bucket time.Duration
+		rows   [][]driver.Value
+		want   []customTypes.KeyGenerationBucketCounts
+	}{
+		{
+			name:   "buckets with keys",
+			bucket: 5 * time.Minute,
+			rows:   [][]driver.Value{{int64(0), int64(2), int64(1)}, {int64(7), int64(0), int64(3)}},
+			want:   []customTypes.KeyGenerationBucketCounts{{Bucket: 0, FreeKeys: 2, UserKeys: 1}, {Bucket: 7, FreeKeys: 0, UserKeys: 3}},
+		},
+		{name: "no keys", bucket: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"bucket", "free_keys", "user_keys"}, Rows: tt.rows}
+			})
+
+			counts, err := NewKeyGenerationRepository(db).CountByBucket(context.Background(), from, to, tt.bucket)
+			if err != nil {
+				t.Fatalf("CountByBucket() error = %v", err)
+			}
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("CountByBucket() = %+v, want %+v", counts, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			for _, fragment := range []string{
+				"FLOOR(EXTRACT(EPOCH FROM (created_at - CAST($1 AS timestamptz))) / $2)::bigint AS bucket",
+				"COUNT(*) FILTER (WHERE user_id IS NULL) AS free_keys",
+				"COUNT(*) FILTER (WHERE user_id IS NOT NULL) AS user_keys",
+				"created_at >= $3 AND created_at < $4",
+				`GROUP BY "bucket" ORDER BY bucket`,
+			} {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+			if want := []any{from, tt.bucket.Seconds(), from, to}; !reflect.DeepEqual(queries[0].Args, want) {
+				t.Errorf("query args = %v, want %v", queries[0].Args, want)
+			}
+		})
+	}
+
+	t.Run("non-positive bucket", func(t *testing.T) {
+		db, fake := newFakeSQLDatabase(t, nil)
+		if _, err := NewKeyGenerationRepository(db).CountByBucket(context.Background(), from, to, 0); err == nil {
+			t.Error("CountByBucket() succeeded, want an error")
+		}
+		if queries := fake.Queries(); len(queries) != 0 {
+			t.Errorf("got queries %v, want none", queries)
+		}
+	})
+}
+
+func TestCountConversionFunnel(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name    string
+		result  sqlfake.Result
+		want    customTypes.ConversionFunnelCounts
+		wantErr error
+	}{
+		{
+			name:   "users at each stage",
+			result: sqlfake.Result{Columns: []string{"free_key_users", "subscribed", "paid"}, Rows: [][]driver.Value{{int64(7), int64(4), int64(3)}}},
+			want:   customTypes.ConversionFunnelCounts{FreeKeyUsers: 7, Subscribed: 4, Paid: 3},
+		},
+		{
+			name:   "no free keys",
+			result: sqlfake.Result{Columns: []string{"free_key_users", "subscribed", "paid"}, Rows: [][]driver.Value{{int64(0), int64(0), int64(0)}}},
+		},
+		{name: "query fails", result: sqlfake.Result{Err: dbErr}, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+
+			counts, err := NewKeyGenerationRepository(db).CountConversionFunnel(context.Background(), from, to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CountConversionFunnel() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && *counts != tt.want {
+				t.Errorf("CountConversionFunnel() = %+v, want %+v", *counts, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			for _, fragment := range []string{
+				// Free-tier keys of known users in the period, from each user's first one.
+				"WHERE user_id IS NOT NULL AND is_free_tier AND created_at >= $1 AND created_at < $2",
+				"MIN(created_at) AS first_free_key_at",
+				// Only their live subscriptions created after it count.
+				"LEFT JOIN subscriptions s ON s.user_id = f.user_id AND s.created_at > f.first_free_key_at AND s.deleted_at IS NULL",
+				"BOOL_OR(s.payment_status = 'paid') AS paid",
+				"COUNT(*) FILTER (WHERE subscribed) AS subscribed",
+			} {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+			if want := []any{from, to}; !reflect.DeepEqual(queries[0].Args, want) {
+				t.Errorf("query args = %v, want %v", queries[0].Args, want)
+			}
+		})
+	}
+}
+
+func TestCountSince(t *testing.T) {
+	since := time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)
+	db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+		return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(5)}}}
+	})
+
+	got, err := NewKeyGenerationRepository(db).CountSince(context.Background(), since)
+	if err != nil {
+		t.Fatalf("CountSince() error = %v", err)
+	}
+	if got != 5 {
+		t.Errorf("CountSince() = %d, want 5", got)
+	}
+	queries := fake.Queries()
+	if len(queries) != 1 || queries[0].SQL != `SELECT count(*) FROM "key_generations" WHERE created_at >= $1` || !reflect.DeepEqual(queries[0].Args, []any{since}) {
+		t.Errorf("queries = %v, want one count of the keys since %v", queries, since)
+	}
+}
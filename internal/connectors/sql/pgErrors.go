@@ -0,0 +1,20 @@
+package sql
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgUniqueViolationCode is the Postgres error code for unique constraint violations.
+const pgUniqueViolationCode = "23505"
+
+// uniqueViolationConstraint returns the name of the constraint or index violated by err,
+// or an empty string if err is not a unique violation.
+func uniqueViolationConstraint(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolationCode {
+		return ""
+	}
+	return pgErr.ConstraintName
+}
@@ -0,0 +1,105 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// planRepository implements the interfaces.PlanRepository for interacting with plan data in a SQL database.
+type planRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanRepository creates a new instance of planRepository.
+func NewPlanRepository(sqlDB interfaces.SQLDatabase) interfaces.PlanRepository {
+	return &planRepository{
+		db: sqlDB.GetGormClient(),
+	}
+}
+
+// Create persists a new plan record to the database.
+func (r *planRepository) Create(ctx context.Context, plan *models.Plan) error {
+	if plan == nil {
+		return errors.New("plan to create cannot be nil")
+	}
+	if err := dbFromContext(ctx, r.db).Create(plan).Error; err != nil {
+		return fmt.Errorf("failed to create plan: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a plan by its primary key ID.
+// Returns gorm.ErrRecordNotFound if no plan is found.
+func (r *planRepository) GetByID(ctx context.Context, id uint) (*models.Plan, error) {
+	var plan models.Plan
+	if err := dbFromContext(ctx, r.db).First(&plan, id).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
+	}
+	return &plan, nil
+}
+
+// GetByName retrieves a plan by its exact name.
+// Returns gorm.ErrRecordNotFound if no plan with the specified name is found.
+func (r *planRepository) GetByName(ctx context.Context, name string) (*models.Plan, error) {
+	var plan models.Plan
+	if err := dbFromContext(ctx, r.db).Where("name = ?", name).First(&plan).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
+	}
+	return &plan, nil
+}
+
+// Update saves changes to an existing plan record in the database.
+func (r *planRepository) Update(ctx context.Context, plan *models.Plan) error {
+	if plan == nil {
+		return errors.New("plan to update cannot be nil")
+	}
+	if plan.ID == 0 {
+		return errors.New("plan ID is required for update")
+	}
+	if err := dbFromContext(ctx, r.db).Save(plan).Error; err != nil {
+		return fmt.Errorf("failed to update plan: %w", err)
+	}
+	return nil
+}
+
+// Delete performs a soft delete on a plan by its ID.
+// Returns gorm.ErrRecordNotFound if no plan was found to delete.
+func (r *planRepository) Delete(ctx context.Context, id uint) error {
+	result := dbFromContext(ctx, r.db).Delete(&models.Plan{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete plan: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List retrieves a paginated list of plans ordered by name.
+// If activeOnly is true, only active plans are returned.
+func (r *planRepository) List(ctx context.Context, offset, limit int, activeOnly bool) ([]models.Plan, int64, error) {
+	var plans []models.Plan
+	var totalCount int64
+
+	baseQuery := dbFromContext(ctx, r.db).Model(&models.Plan{})
+	if activeOnly {
+		baseQuery = baseQuery.Where("is_active = ?", true)
+	}
+
+	if err := baseQuery.Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count plans: %w", err)
+	}
+	if totalCount == 0 {
+		return []models.Plan{}, 0, nil
+	}
+
+	if err := baseQuery.Order("name ASC").Offset(offset).Limit(limit).Find(&plans).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list plans: %w", err)
+	}
+	return plans, totalCount, nil
+}
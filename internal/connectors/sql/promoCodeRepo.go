@@ -0,0 +1,44 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// promoCodeRepository implements the interfaces.PromoCodeRepository for interacting with promo code data in a SQL database.
+type promoCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewPromoCodeRepository creates a new instance of promoCodeRepository.
+func NewPromoCodeRepository(sqlDB interfaces.SQLDatabase) interfaces.PromoCodeRepository {
+	return &promoCodeRepository{
+		db: sqlDB.GetGormClient(),
+	}
+}
+
+// Create persists a new promo code record to the database.
+func (r *promoCodeRepository) Create(ctx context.Context, promoCode *models.PromoCode) error {
+	if promoCode == nil {
+		return errors.New("promo code to create cannot be nil")
+	}
+	if err := dbFromContext(ctx, r.db).Create(promoCode).Error; err != nil {
+		return fmt.Errorf("failed to create promo code: %w", err)
+	}
+	return nil
+}
+
+// GetByCode retrieves a promo code by its code.
+// Returns gorm.ErrRecordNotFound if no promo code with the specified code is found.
+func (r *promoCodeRepository) GetByCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	var promoCode models.PromoCode
+	if err := dbFromContext(ctx, r.db).Where("code = ?", code).First(&promoCode).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
+	}
+	return &promoCode, nil
+}
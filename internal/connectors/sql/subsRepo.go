@@ -1,15 +1,19 @@
 package sql
 
 import (
+	"bitback/internal/database"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // subscriptionRepository implements the interfaces.SubscriptionRepository for interacting with subscription data in a SQL database.
@@ -24,62 +28,196 @@ func NewSubscriptionRepository(sqlDB interfaces.SQLDatabase) interfaces.Subscrip
 	}
 }
 
-// Create persists a new subscription record to the database.
+// Create persists a new subscription record to the database together with its creation event.
 // Fields like EndDate and IsActive should be determined by the service layer before calling Create.
-func (r *subscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+func (r *subscriptionRepository) Create(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
 	if subscription == nil {
 		return errors.New("subscription to create cannot be nil")
 	}
-	return r.db.WithContext(ctx).Create(subscription).Error
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		if err := tx.Create(subscription).Error; err != nil {
+			return uuid.Nil, err
+		}
+		return subscription.ID, nil
+	})
+}
+
+// CreateWithPromoCode persists a new subscription and increments the promo code's usage count in a single transaction.
+// The increment only succeeds while the code is active, within its validity window and below its maximum number of uses;
+// otherwise gorm.ErrRecordNotFound is returned and the subscription is not created.
+func (r *subscriptionRepository) CreateWithPromoCode(ctx context.Context, subscription *models.Subscription, promoCodeID uint, event *models.SubscriptionEvent) error {
+	if subscription == nil {
+		return errors.New("subscription to create cannot be nil")
+	}
+
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		now := time.Now()
+		result := tx.Model(&models.PromoCode{}).
+			Where("id = ? AND is_active = ?", promoCodeID, true).
+			Where("valid_from IS NULL OR valid_from <= ?", now).
+			Where("valid_until IS NULL OR valid_until >= ?", now).
+			Where("max_uses IS NULL OR used_count < max_uses").
+			Update("used_count", gorm.Expr("used_count + 1"))
+		if result.Error != nil {
+			return uuid.Nil, fmt.Errorf("failed to redeem promo code: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return uuid.Nil, gorm.ErrRecordNotFound // The code was exhausted or expired concurrently.
+		}
+
+		subscription.PromoCodeID = &promoCodeID
+		if err := tx.Create(subscription).Error; err != nil {
+			return uuid.Nil, fmt.Errorf("failed to create subscription: %w", err)
+		}
+		return subscription.ID, nil
+	})
 }
 
 // GetByID retrieves a subscription by its primary key (UUID).
 // Returns gorm.ErrRecordNotFound if no subscription is found.
 func (r *subscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
 	var subscription models.Subscription
-	if err := r.db.WithContext(ctx).First(&subscription, "id = ?", id).Error; err != nil {
+	if err := dbFromContext(ctx, r.db).First(&subscription, "id = ?", id).Error; err != nil {
 		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
 	}
 	return &subscription, nil
 }
 
-// Update saves changes to an existing subscription record in the database.
+// GetByIDForUpdate retrieves a subscription by its UUID with a row lock held until the surrounding transaction
+// ends, so concurrent writers of the same subscription are serialized.
+func (r *subscriptionRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	if database.TxFromContext(ctx) == nil {
+		return nil, errors.New("GetByIDForUpdate must be called within a transaction")
+	}
+	var subscription models.Subscription
+	if err := dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&subscription, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetByExternalPaymentID retrieves the subscription paid for by the given payment provider payment.
+// Returns gorm.ErrRecordNotFound if no subscription references the payment.
+func (r *subscriptionRepository) GetByExternalPaymentID(ctx context.Context, externalPaymentID string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := dbFromContext(ctx, r.db).First(&subscription, "external_payment_id = ?", externalPaymentID).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetByUserIDAndIdempotencyKey retrieves a subscription created for a user with the given idempotency key.
+// Returns gorm.ErrRecordNotFound if no such subscription exists.
+func (r *subscriptionRepository) GetByUserIDAndIdempotencyKey(ctx context.Context, userID uuid.UUID, idempotencyKey string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND idempotency_key = ?", userID, idempotencyKey).
+		First(&subscription).Error
+	if err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if no matching subscription is found.
+	}
+	return &subscription, nil
+}
+
+// Update saves changes to an existing subscription record in the database and records event in the same transaction.
 // It uses db.Save(), which updates all fields and runs GORM hooks.
-func (r *subscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+func (r *subscriptionRepository) Update(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
 	if subscription == nil {
 		return errors.New("subscription to update cannot be nil")
 	}
 	if subscription.ID == uuid.Nil {
 		return errors.New("subscription ID is required for update")
 	}
-	return r.db.WithContext(ctx).Save(subscription).Error
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		return subscription.ID, tx.Save(subscription).Error
+	})
 }
 
-// Delete performs a soft delete on a subscription record by its ID (uint).
+// Delete performs a soft delete on a subscription record by its ID and records event in the same transaction.
 // Returns gorm.ErrRecordNotFound if the subscription to delete is not found.
-func (r *subscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+func (r *subscriptionRepository) Delete(ctx context.Context, id uuid.UUID, event *models.SubscriptionEvent) error {
 	if id == uuid.Nil {
 		return errors.New("subscription ID for delete cannot be zero")
 	}
-	result := r.db.WithContext(ctx).Delete(&models.Subscription{}, id)
-	if result.Error != nil {
-		return result.Error
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		result := tx.Delete(&models.Subscription{}, id)
+		if result.Error != nil {
+			return uuid.Nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return uuid.Nil, gorm.ErrRecordNotFound // Subscription to delete was not found.
+		}
+		return id, nil
+	})
+}
+
+// ListEvents retrieves a paginated list of a subscription's history events in the order they happened.
+func (r *subscriptionRepository) ListEvents(ctx context.Context, subscriptionID uuid.UUID, offset, limit int) ([]models.SubscriptionEvent, int64, error) {
+	var events []models.SubscriptionEvent
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&models.SubscriptionEvent{}).Where("subscription_id = ?", subscriptionID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count subscription events: %w", err)
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound // Subscription to delete was not found.
+
+	if total == 0 {
+		return []models.SubscriptionEvent{}, 0, nil
 	}
-	return nil
+
+	if err := query.Order("created_at ASC, id ASC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list subscription events: %w", err)
+	}
+	return events, total, nil
 }
 
-// ListByUserID retrieves a paginated list of subscriptions for a specific user.
-// Subscriptions can be ordered, for example, by creation date or end date.
-func (r *subscriptionRepository) ListByUserID(ctx context.Context, userID uuid.UUID, offset, limit int) ([]models.Subscription, int64, error) {
+// withEvent runs fn in a transaction and appends event to the history of the subscription whose ID fn returns.
+// A nil event is rejected, so no mutation can be persisted without its history entry.
+func (r *subscriptionRepository) withEvent(ctx context.Context, event *models.SubscriptionEvent, fn func(tx *gorm.DB) (uuid.UUID, error)) error {
+	if event == nil {
+		return errors.New("subscription event cannot be nil")
+	}
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		subscriptionID, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		event.SubscriptionID = subscriptionID
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed to record subscription event: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByUserID retrieves a paginated list of subscriptions for a specific user, optionally filtered by
+// derived status, payment status and plan name. Subscriptions are ordered by params.SortBy, newest first by default.
+func (r *subscriptionRepository) ListByUserID(ctx context.Context, userID uuid.UUID, params customTypes.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
 	var subscriptions []models.Subscription
 	var totalCount int64
 
-	// Query to count the total number of subscriptions for the user.
-	countQuery := r.db.WithContext(ctx).Model(&models.Subscription{}).Where("user_id = ?", userID)
-	if err := countQuery.Count(&totalCount).Error; err != nil {
+	query := dbFromContext(ctx, r.db).Model(&models.Subscription{}).Where("user_id = ?", userID)
+	if params.Status != nil {
+		now := time.Now()
+		switch *params.Status {
+		case customTypes.SubscriptionListStatusActive:
+			query = query.Where("is_active = ? AND end_date >= ?", true, now)
+		case customTypes.SubscriptionListStatusExpired:
+			// The is_active flag may lag behind the deactivation worker, so expiry is judged by the end date alone.
+			query = query.Where("end_date < ?", now)
+		case customTypes.SubscriptionListStatusCancelled:
+			query = query.Where("is_active = ? AND auto_renew = ?", false, false)
+		}
+	}
+	if params.PaymentStatus != nil && *params.PaymentStatus != "" {
+		query = query.Where("payment_status = ?", *params.PaymentStatus)
+	}
+	if params.PlanName != nil && *params.PlanName != "" {
+		query = query.Where("LOWER(plan_name) = LOWER(?)", *params.PlanName)
+	}
+
+	// Count the total number of matching subscriptions before applying pagination.
+	if err := query.Count(&totalCount).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count user subscriptions: %w", err)
 	}
 
@@ -87,15 +225,23 @@ func (r *subscriptionRepository) ListByUserID(ctx context.Context, userID uuid.U
 		return []models.Subscription{}, 0, nil // No subscriptions found for this user.
 	}
 
-	// Query to retrieve a slice of subscriptions with pagination and ordering.
-	// Example orders: "created_at DESC" (newest first) or "end_date DESC".
-	listQuery := r.db.WithContext(ctx).
-		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limit)
+	// Whitelist valid sortable columns to prevent SQL injection.
+	validSortableColumns := map[string]string{
+		"created_at": "created_at",
+		"start_date": "start_date",
+		"end_date":   "end_date",
+	}
+	sortColumn, ok := validSortableColumns[strings.ToLower(params.SortBy)]
+	if !ok {
+		sortColumn = "created_at"
+	}
+	order := "DESC"
+	if strings.ToLower(params.SortOrder) == "asc" {
+		order = "ASC"
+	}
+	query = query.Order(fmt.Sprintf("%s %s, id %s", sortColumn, order, order))
 
-	if err := listQuery.Find(&subscriptions).Error; err != nil {
+	if err := query.Offset(params.Offset).Limit(params.Limit).Find(&subscriptions).Error; err != nil {
 		return nil, totalCount, fmt.Errorf("failed to list user subscriptions: %w", err)
 	}
 
@@ -109,7 +255,7 @@ func (r *subscriptionRepository) ListExpiringSoon(ctx context.Context, threshold
 	var totalCount int64
 
 	// Base query for counting and selecting expiring subscriptions.
-	baseQuery := r.db.WithContext(ctx).Model(&models.Subscription{}).
+	baseQuery := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
 		Where("is_active = ?", true).              // Only include active subscriptions.
 		Where("end_date >= ?", thresholdDateFrom). // Subscriptions that have not yet ended (or end today).
 		Where("end_date <= ?", thresholdDateTo)    // Subscriptions that end before or on the specified upper threshold date.
@@ -138,7 +284,7 @@ func (r *subscriptionRepository) ListActiveByPlanName(ctx context.Context, planN
 	var totalCount int64
 
 	// Base query for active subscriptions by plan name.
-	baseQuery := r.db.WithContext(ctx).Model(&models.Subscription{}).
+	baseQuery := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
 		Where("is_active = ?", true).
 		Where("plan_name = ?", planName)
 
@@ -159,10 +305,49 @@ func (r *subscriptionRepository) ListActiveByPlanName(ctx context.Context, planN
 	return subscriptions, totalCount, nil
 }
 
+// List retrieves a paginated list of subscriptions across all users, optionally filtered by payment status, active flag
+// and whether an expiry reminder has been sent.
+// Subscriptions are ordered by their creation date in descending order (newest first).
+func (r *subscriptionRepository) List(ctx context.Context, offset, limit int, filters customTypes.ListSubscriptionsFilters) ([]models.Subscription, int64, error) {
+	var subscriptions []models.Subscription
+	var totalCount int64
+
+	baseQuery := dbFromContext(ctx, r.db).Model(&models.Subscription{})
+	if filters.PaymentStatus != nil && *filters.PaymentStatus != "" {
+		baseQuery = baseQuery.Where("payment_status = ?", *filters.PaymentStatus)
+	}
+	if filters.IsActive != nil {
+		baseQuery = baseQuery.Where("is_active = ?", *filters.IsActive)
+	}
+	if filters.ReminderSent != nil {
+		if *filters.ReminderSent {
+			baseQuery = baseQuery.Where("last_expiry_notified_at IS NOT NULL")
+		} else {
+			baseQuery = baseQuery.Where("last_expiry_notified_at IS NULL")
+		}
+	}
+
+	// Count the total number of matching subscriptions.
+	if err := baseQuery.Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+
+	if totalCount == 0 {
+		return []models.Subscription{}, 0, nil // No subscriptions match the filters.
+	}
+
+	// Retrieve the paginated list.
+	query := baseQuery.Order("created_at DESC").Offset(offset).Limit(limit)
+	if err := query.Find(&subscriptions).Error; err != nil {
+		return nil, totalCount, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	return subscriptions, totalCount, nil
+}
+
 // CheckUserActiveSubscription checks if a user has any active subscription.
 func (r *subscriptionRepository) CheckUserActiveSubscription(ctx context.Context, userID uuid.UUID) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&models.Subscription{}).
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
 		Where("user_id = ? AND is_active = ? AND end_date > ?", userID, true, time.Now()).
 		Count(&count).Error
 	if err != nil {
@@ -170,3 +355,278 @@ func (r *subscriptionRepository) CheckUserActiveSubscription(ctx context.Context
 	}
 	return count > 0, nil
 }
+
+// ListActivePlanNames retrieves the distinct plan names of the user's active subscriptions, ordered by name.
+func (r *subscriptionRepository) ListActivePlanNames(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	planNames := []string{}
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Where("user_id = ? AND is_active = ? AND end_date > ?", userID, true, time.Now()).
+		Distinct("plan_name").
+		Order("plan_name ASC").
+		Pluck("plan_name", &planNames).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active plans for user %s: %w", userID, err)
+	}
+	return planNames, nil
+}
+
+// GetActiveByUserID retrieves the user's active subscription that ends last.
+// Returns gorm.ErrRecordNotFound if the user has no active subscription.
+func (r *subscriptionRepository) GetActiveByUserID(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND is_active = ? AND end_date > ?", userID, true, time.Now()).
+		Order("end_date DESC").
+		First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// CheckUserActivePaidSubscription checks if a user has any active subscription whose payment status is "paid".
+func (r *subscriptionRepository) CheckUserActivePaidSubscription(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Where("user_id = ? AND is_active = ? AND end_date > ? AND payment_status = ?", userID, true, time.Now(), "paid").
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check active paid subscription for user %s: %w", userID, err)
+	}
+	return count > 0, nil
+}
+
+// ListRenewalCandidates retrieves active, paid, auto-renewing subscriptions that end within the specified time window.
+// Subscriptions are ordered by their end date (soonest expiring first).
+func (r *subscriptionRepository) ListRenewalCandidates(ctx context.Context, thresholdDateFrom time.Time, thresholdDateTo time.Time, limit int) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	query := dbFromContext(ctx, r.db).
+		Where("auto_renew = ?", true).
+		Where("is_active = ?", true).
+		Where("payment_status = ?", "paid"). // Subscriptions whose payment later failed, or whose extension awaits payment, are skipped.
+		Where("end_date >= ?", thresholdDateFrom).
+		Where("end_date <= ?", thresholdDateTo).
+		Order("end_date ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list renewal candidates: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ListActivationCandidates retrieves paid or trial subscriptions that are not active yet although their term
+// contains now, ordered by start date (earliest first). Such subscriptions were created or paid before their
+// start date.
+func (r *subscriptionRepository) ListActivationCandidates(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	query := dbFromContext(ctx, r.db).
+		Where("payment_status IN ?", []string{"paid", "trial"}).
+		Where("is_active = ?", false).
+		Where("start_date <= ?", now).
+		Where("end_date > ?", now).
+		Order("start_date ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list activation candidates: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ExtendForRenewal extends an auto-renewing subscription in place to newEndDate, marks the extension as awaiting
+// payment and records the renewal time in last_renewed_at, together with event, within a single transaction.
+// The external payment ID of the previous period is cleared, so the payment for the extension can be applied.
+// The update only applies while the subscription is still an unchanged renewal candidate, which prevents double
+// extensions when several runs race. Returns gorm.ErrRecordNotFound if it no longer is.
+func (r *subscriptionRepository) ExtendForRenewal(ctx context.Context, subscription *models.Subscription, newEndDate time.Time, event *models.SubscriptionEvent) error {
+	if subscription == nil || subscription.ID == uuid.Nil {
+		return errors.New("subscription ID is required for renewal")
+	}
+
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		renewedAt := time.Now()
+		result := tx.Model(&models.Subscription{}).
+			Where("id = ? AND end_date = ?", subscription.ID, subscription.EndDate).
+			Where("auto_renew = ? AND is_active = ? AND payment_status = ?", true, true, "paid").
+			Updates(map[string]interface{}{
+				"end_date":            newEndDate,
+				"payment_status":      "pending",
+				"external_payment_id": nil,
+				"last_renewed_at":     renewedAt,
+			})
+		if result.Error != nil {
+			return uuid.Nil, fmt.Errorf("failed to extend subscription: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return uuid.Nil, gorm.ErrRecordNotFound // Already extended by another run, changed or deleted; record nothing.
+		}
+		subscription.EndDate = newEndDate
+		subscription.PaymentStatus = "pending"
+		subscription.ExternalPaymentID = nil
+		subscription.LastRenewedAt = &renewedAt
+		return subscription.ID, nil
+	})
+}
+
+// ListUnpaidRenewals retrieves active subscriptions that were extended by the renewal job at or before renewedBefore
+// and whose extension still awaits payment. Subscriptions are ordered by their renewal time (oldest first).
+func (r *subscriptionRepository) ListUnpaidRenewals(ctx context.Context, renewedBefore time.Time, limit int) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	query := dbFromContext(ctx, r.db).
+		Where("is_active = ?", true).
+		Where("payment_status = ?", "pending").
+		Where("last_renewed_at <= ?", renewedBefore).
+		Order("last_renewed_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list unpaid renewals: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// DeactivateUnpaidRenewal deactivates a subscription whose renewal still awaits payment, together with event,
+// within a single transaction. The payment status stays pending, so a late payment is still applied and
+// reactivates the subscription. The update only applies while the renewal is unchanged and unpaid, so a payment
+// arriving concurrently wins. Returns gorm.ErrRecordNotFound if it no longer is.
+func (r *subscriptionRepository) DeactivateUnpaidRenewal(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
+	if subscription == nil || subscription.ID == uuid.Nil || subscription.LastRenewedAt == nil {
+		return errors.New("renewed subscription ID is required for deactivation")
+	}
+
+	return r.withEvent(ctx, event, func(tx *gorm.DB) (uuid.UUID, error) {
+		result := tx.Model(&models.Subscription{}).
+			Where("id = ? AND last_renewed_at = ?", subscription.ID, *subscription.LastRenewedAt).
+			Where("is_active = ? AND payment_status = ?", true, "pending").
+			Update("is_active", false)
+		if result.Error != nil {
+			return uuid.Nil, fmt.Errorf("failed to deactivate subscription: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return uuid.Nil, gorm.ErrRecordNotFound // Paid, renewed again, changed or deleted; record nothing.
+		}
+		subscription.IsActive = false
+		return subscription.ID, nil
+	})
+}
+
+// AddUsage atomically adds usage.Quantity to the usage row for the subscription, metric and period,
+// inserting the row if it does not exist yet. usage is populated with the resulting row.
+func (r *subscriptionRepository) AddUsage(ctx context.Context, usage *models.SubscriptionUsage) error {
+	if usage == nil {
+		return errors.New("subscription usage to add cannot be nil")
+	}
+	err := dbFromContext(ctx, r.db).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "subscription_id"}, {Name: "period_start"}, {Name: "metric"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "quantity"}, Value: gorm.Expr("subscription_usages.quantity + EXCLUDED.quantity")},
+				{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("EXCLUDED.updated_at")},
+			},
+		},
+		clause.Returning{},
+	).Create(usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to add subscription usage: %w", err)
+	}
+	return nil
+}
+
+// ListUsage retrieves all usage rows of a subscription, oldest period first.
+func (r *subscriptionRepository) ListUsage(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionUsage, error) {
+	var usage []models.SubscriptionUsage
+	if err := dbFromContext(ctx, r.db).
+		Where("subscription_id = ?", subscriptionID).
+		Order("period_start ASC, metric ASC").
+		Find(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to list subscription usage: %w", err)
+	}
+	return usage, nil
+}
+
+// GetChurnCounts aggregates paid subscriptions for a churn period in a single query. Renewed subscriptions whose
+// extension awaits payment are included, as they were paid for the period before it; unpaid, failed and refunded
+// subscriptions are not. A subscription counts as renewed when the renewal job extended it within [from, to).
+// A subscription ending within [from, to) counts as cancelled if auto-renewal was disabled, or expired if it was not.
+func (r *subscriptionRepository) GetChurnCounts(ctx context.Context, from, to time.Time) (*customTypes.SubscriptionChurnCounts, error) {
+	var counts customTypes.SubscriptionChurnCounts
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Select(`COUNT(*) FILTER (WHERE start_date <= ? AND end_date > ?) AS active_at_start,
+			COUNT(*) FILTER (WHERE last_renewed_at >= ? AND last_renewed_at < ?) AS renewed,
+			COUNT(*) FILTER (WHERE end_date >= ? AND end_date < ? AND auto_renew = TRUE) AS expired,
+			COUNT(*) FILTER (WHERE end_date >= ? AND end_date < ? AND auto_renew = FALSE) AS cancelled`,
+			from, from, from, to, from, to, from, to).
+		Where("payment_status = ? OR (payment_status = ? AND last_renewed_at IS NOT NULL)", "paid", "pending").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscription churn: %w", err)
+	}
+	return &counts, nil
+}
+
+// GetCurrencyTotals aggregates subscriptions created within [from, to) per currency in a single query.
+// Currency codes are compared case-insensitively; NULL and blank currencies are grouped together.
+func (r *subscriptionRepository) GetCurrencyTotals(ctx context.Context, from, to time.Time) ([]customTypes.SubscriptionCurrencyTotals, error) {
+	var totals []customTypes.SubscriptionCurrencyTotals
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Select(`UPPER(TRIM(COALESCE(currency, ''))) AS currency,
+			COUNT(*) AS subscriptions,
+			COUNT(*) FILTER (WHERE payment_status = ?) AS paid,
+			COALESCE(SUM(price) FILTER (WHERE payment_status = ?), 0) AS revenue`, "paid", "paid").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("UPPER(TRIM(COALESCE(currency, '')))").
+		Order("currency ASC").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscriptions by currency: %w", err)
+	}
+	return totals, nil
+}
+
+// CountUsersWithActivePaidSubscription counts the distinct users with an active, paid subscription whose term
+// contains now.
+func (r *subscriptionRepository) CountUsersWithActivePaidSubscription(ctx context.Context, now time.Time) (int64, error) {
+	var count int64
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Where("is_active = ? AND payment_status = ? AND start_date <= ? AND end_date > ?", true, "paid", now, now).
+		Distinct("user_id").
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users with an active paid subscription: %w", err)
+	}
+	return count, nil
+}
+
+// CountByPaymentStatus counts subscriptions per payment status in a single GROUP BY query, ordered by payment status.
+func (r *subscriptionRepository) CountByPaymentStatus(ctx context.Context) ([]customTypes.PaymentStatusCount, error) {
+	var counts []customTypes.PaymentStatusCount
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Select("payment_status, COUNT(*) AS count").
+		Group("payment_status").
+		Order("payment_status ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count subscriptions by payment status: %w", err)
+	}
+	return counts, nil
+}
+
+// CountActiveByPlan counts active subscriptions whose term contains now per plan name in a single GROUP BY query.
+// Plans are ordered by count, largest first, then by name.
+func (r *subscriptionRepository) CountActiveByPlan(ctx context.Context, now time.Time) ([]customTypes.PlanSubscriptionCount, error) {
+	var counts []customTypes.PlanSubscriptionCount
+	err := dbFromContext(ctx, r.db).Model(&models.Subscription{}).
+		Select("plan_name, COUNT(*) AS count").
+		Where("is_active = ? AND start_date <= ? AND end_date > ?", true, now, now).
+		Group("plan_name").
+		Order("count DESC, plan_name ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active subscriptions by plan: %w", err)
+	}
+	return counts, nil
+}
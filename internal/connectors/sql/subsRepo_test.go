@@ -0,0 +1,892 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestGetChurnCounts(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	renewedAt := day(time.March, 10)
+	earlierRenewal := day(time.January, 10)
+
+	// sub returns a paid, active, auto-renewing subscription running from start to end, changed by mutate.
+	sub := func(start, end time.Time, mutate func(*models.Subscription)) models.Subscription {
+		s := models.Subscription{PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+			StartDate: start, EndDate: end, PaymentStatus: "paid", IsActive: true, AutoRenew: true}
+		if mutate != nil {
+			mutate(&s)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name string
+		subs []models.Subscription
+		want customTypes.SubscriptionChurnCounts
+	}{
+		{
+			name: "renewal awaiting payment",
+			subs: []models.Subscription{sub(day(time.February, 10), day(time.April, 10), func(s *models.Subscription) {
+				s.PaymentStatus, s.LastRenewedAt = "pending", &renewedAt
+			})},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1, Renewed: 1},
+		},
+		{
+			name: "renewal paid",
+			subs: []models.Subscription{sub(day(time.February, 10), day(time.April, 10), func(s *models.Subscription) { s.LastRenewedAt = &renewedAt })},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1, Renewed: 1},
+		},
+		{
+			name: "renewed before the period",
+			subs: []models.Subscription{sub(day(time.January, 1), day(time.March, 20), func(s *models.Subscription) { s.LastRenewedAt = &earlierRenewal })},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1, Expired: 1},
+		},
+		{
+			name: "auto-renewing but not renewed",
+			subs: []models.Subscription{sub(day(time.February, 15), day(time.March, 15), nil)},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1, Expired: 1},
+		},
+		{
+			name: "auto-renewal disabled",
+			subs: []models.Subscription{sub(day(time.February, 20), day(time.March, 20), func(s *models.Subscription) { s.AutoRenew = false })},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1, Cancelled: 1},
+		},
+		{
+			name: "ends on the period end",
+			subs: []models.Subscription{sub(day(time.February, 20), to, nil)},
+			want: customTypes.SubscriptionChurnCounts{ActiveAtStart: 1},
+		},
+		{
+			name: "starts within the period",
+			subs: []models.Subscription{sub(day(time.March, 5), day(time.March, 25), nil)},
+			want: customTypes.SubscriptionChurnCounts{Expired: 1},
+		},
+		{
+			name: "not paid",
+			subs: []models.Subscription{
+				sub(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) { s.PaymentStatus = "pending" }),
+				sub(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) { s.PaymentStatus = "failed" }),
+				sub(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) { s.PaymentStatus = "refunded" }),
+				sub(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) { s.PaymentStatus = "trial" }),
+			},
+		},
+		{
+			name: "soft-deleted",
+			subs: []models.Subscription{sub(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) {
+				s.DeletedAt = gorm.DeletedAt{Time: day(time.February, 2), Valid: true}
+			})},
+		},
+		{name: "no subscriptions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, gormDB := newSQLiteDatabase(t)
+			user := &models.User{Name: "Alice", Email: "alice@example.com"}
+			if err := gormDB.Create(user).Error; err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+			for i := range tt.subs {
+				tt.subs[i].UserID = user.ID
+			}
+			if len(tt.subs) > 0 {
+				if err := gormDB.Create(&tt.subs).Error; err != nil {
+					t.Fatalf("failed to seed subscriptions: %v", err)
+				}
+			}
+
+			counts, err := NewSubscriptionRepository(db).GetChurnCounts(context.Background(), from, to)
+			if err != nil {
+				t.Fatalf("GetChurnCounts() error = %v", err)
+			}
+			if *counts != tt.want {
+				t.Errorf("GetChurnCounts() = %+v, want %+v", *counts, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCurrencyTotals(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name    string
+		result  sqlfake.Result
+		want    []customTypes.SubscriptionCurrencyTotals
+		wantErr error
+	}{
+		{
+			name: "multiple currencies",
+			result: sqlfake.Result{
+				Columns: []string{"currency", "subscriptions", "paid", "revenue"},
+				Rows: [][]driver.Value{
+					{"", int64(2), int64(1), float64(3)},
+					{"EUR", int64(1), int64(1), float64(8)},
+					{"USD", int64(3), int64(2), float64(15.5)},
+				},
+			},
+			want: []customTypes.SubscriptionCurrencyTotals{
+				{Currency: "", Subscriptions: 2, Paid: 1, Revenue: 3},
+				{Currency: "EUR", Subscriptions: 1, Paid: 1, Revenue: 8},
+				{Currency: "USD", Subscriptions: 3, Paid: 2, Revenue: 15.5},
+			},
+		},
+		{name: "no subscriptions", result: sqlfake.Result{Columns: []string{"currency", "subscriptions", "paid", "revenue"}}},
+		{name: "database error", result: sqlfake.Result{Err: dbErr}, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+
+			totals, err := NewSubscriptionRepository(db).GetCurrencyTotals(context.Background(), from, to)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetCurrencyTotals() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(totals, tt.want) {
+				t.Errorf("GetCurrencyTotals() = %+v, want %+v", totals, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{
+				"UPPER(TRIM(COALESCE(currency, ''))) AS currency",
+				"COUNT(*) FILTER (WHERE payment_status = $1) AS paid",
+				"COALESCE(SUM(price) FILTER (WHERE payment_status = $2), 0) AS revenue",
+				`WHERE (created_at >= $3 AND created_at < $4) AND "subscriptions"."deleted_at" IS NULL`,
+				"GROUP BY UPPER(TRIM(COALESCE(currency, ''))) ORDER BY currency ASC",
+			} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			if wantArgs := []any{"paid", "paid", from, to}; !reflect.DeepEqual(query.Args, wantArgs) {
+				t.Errorf("query args = %v, want %v", query.Args, wantArgs)
+			}
+		})
+	}
+}
+func TestCreateWithPromoCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		redeemed     int64
+		wantErr      error
+		wantInserted bool
+		wantEnd      string
+	}{
+		{name: "redeemed", redeemed: 1, wantInserted: true, wantEnd: "COMMIT"},
+		{name: "no longer redeemable", redeemed: 0, wantErr: gorm.ErrRecordNotFound, wantEnd: "ROLLBACK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, "UPDATE") {
+					return sqlfake.Result{RowsAffected: tt.redeemed}
+				}
+				return sqlfake.Result{RowsAffected: 1}
+			})
+
+			sub := &models.Subscription{ID: uuid.New(), UserID: uuid.New(), PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1}
+			err := NewSubscriptionRepository(db).CreateWithPromoCode(context.Background(), sub, 7, &models.SubscriptionEvent{})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CreateWithPromoCode() error = %v, want %v", err, tt.wantErr)
+			}
+
+			queries := fake.Queries()
+			if len(queries) == 0 || !strings.HasPrefix(queries[0].SQL, `UPDATE "promo_codes" SET "used_count"=used_count + 1`) {
+				t.Fatalf("first query is not the promo code redemption: %v", fake.SQL())
+			}
+			for _, fragment := range []string{"is_active = $", "valid_from IS NULL OR valid_from <= $", "valid_until IS NULL OR valid_until >= $",
+				"max_uses IS NULL OR used_count < max_uses"} {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("redemption %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+			inserted := false
+			for _, query := range queries {
+				inserted = inserted || strings.HasPrefix(query.SQL, `INSERT INTO "subscriptions"`)
+			}
+			if inserted != tt.wantInserted {
+				t.Errorf("subscription inserted = %v, want %v: %v", inserted, tt.wantInserted, fake.SQL())
+			}
+			if statements := fake.SQL(); statements[len(statements)-1] != tt.wantEnd {
+				t.Errorf("transaction ended with %q, want %q", statements[len(statements)-1], tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestListReminderSentFilter(t *testing.T) {
+	sent := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name        string
+		filter      *bool
+		wantClause  string
+		wantMissing bool
+	}{
+		{name: "reminded", filter: sent(true), wantClause: "last_expiry_notified_at IS NOT NULL"},
+		{name: "not reminded", filter: sent(false), wantClause: "last_expiry_notified_at IS NULL"},
+		{name: "no filter", wantMissing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id"}, Rows: [][]driver.Value{{uuid.NewString()}}}
+			})
+
+			subs, total, err := NewSubscriptionRepository(db).List(context.Background(), 0, 10, customTypes.ListSubscriptionsFilters{ReminderSent: tt.filter})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if total != 1 || len(subs) != 1 {
+				t.Errorf("List() returned %d subscriptions of %d, want 1 of 1", len(subs), total)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 2 {
+				t.Fatalf("got %d queries, want the count and the select: %v", len(queries), fake.SQL())
+			}
+			for _, query := range queries {
+				if hasClause := strings.Contains(query.SQL, "last_expiry_notified_at"); hasClause == tt.wantMissing {
+					t.Errorf("query %q filters on last_expiry_notified_at = %v, want %v", query.SQL, hasClause, !tt.wantMissing)
+				}
+				if !tt.wantMissing && !strings.Contains(query.SQL, tt.wantClause) {
+					t.Errorf("query %q does not contain %q", query.SQL, tt.wantClause)
+				}
+			}
+		})
+	}
+}
+
+func TestListByUserIDFilters(t *testing.T) {
+	userID := uuid.New()
+	status := func(s customTypes.SubscriptionListStatus) *customTypes.SubscriptionListStatus { return &s }
+	text := func(s string) *string { return &s }
+
+	tests := []struct {
+		name           string
+		params         customTypes.ListUserSubscriptionsParams
+		wantClauses    []string
+		wantAbsent     []string
+		wantFilterArgs int // Number of filter arguments after the user ID.
+		wantOrder      string
+	}{
+		{name: "no filters", wantAbsent: []string{"is_active", "end_date <", "payment_status", "plan_name"}, wantOrder: "ORDER BY created_at DESC, id DESC"},
+		{name: "active", params: customTypes.ListUserSubscriptionsParams{Status: status(customTypes.SubscriptionListStatusActive)},
+			wantClauses: []string{"is_active = $2 AND end_date >= $3"}, wantFilterArgs: 2, wantOrder: "ORDER BY created_at DESC, id DESC"},
+		{name: "expired ignores the active flag", params: customTypes.ListUserSubscriptionsParams{Status: status(customTypes.SubscriptionListStatusExpired)},
+			wantClauses: []string{"end_date < $2"}, wantAbsent: []string{"is_active"}, wantFilterArgs: 1, wantOrder: "ORDER BY created_at DESC, id DESC"},
+		{name: "cancelled", params: customTypes.ListUserSubscriptionsParams{Status: status(customTypes.SubscriptionListStatusCancelled)},
+			wantClauses: []string{"is_active = $2 AND auto_renew = $3"}, wantFilterArgs: 2, wantOrder: "ORDER BY created_at DESC, id DESC"},
+		{name: "payment status and plan", params: customTypes.ListUserSubscriptionsParams{PaymentStatus: text("paid"), PlanName: text("Premium")},
+			wantClauses: []string{"payment_status = $2", "LOWER(plan_name) = LOWER($3)"}, wantFilterArgs: 2, wantOrder: "ORDER BY created_at DESC, id DESC"},
+		{name: "sorted by end date ascending", params: customTypes.ListUserSubscriptionsParams{SortBy: "end_date", SortOrder: "ASC"}, wantOrder: "ORDER BY end_date ASC, id ASC"},
+		{name: "sorted by start date", params: customTypes.ListUserSubscriptionsParams{SortBy: "start_date"}, wantOrder: "ORDER BY start_date DESC, id DESC"},
+		{name: "unknown sort column falls back", params: customTypes.ListUserSubscriptionsParams{SortBy: "price; DROP TABLE subscriptions"}, wantOrder: "ORDER BY created_at DESC, id DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "user_id"}, Rows: [][]driver.Value{{uuid.NewString(), userID.String()}}}
+			})
+			tt.params.Limit = 10
+
+			subs, total, err := NewSubscriptionRepository(db).ListByUserID(context.Background(), userID, tt.params)
+			if err != nil {
+				t.Fatalf("ListByUserID() error = %v", err)
+			}
+			if total != 1 || len(subs) != 1 {
+				t.Errorf("ListByUserID() returned %d subscriptions of %d, want 1 of 1", len(subs), total)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 2 {
+				t.Fatalf("got %d queries, want the count and the select: %v", len(queries), fake.SQL())
+			}
+			for _, query := range queries {
+				if !strings.Contains(query.SQL, "user_id = $1") || query.Args[0] != userID.String() {
+					t.Errorf("query %q with args %v is not restricted to user %s", query.SQL, query.Args, userID)
+				}
+				for _, clause := range tt.wantClauses {
+					if !strings.Contains(query.SQL, clause) {
+						t.Errorf("query %q does not contain %q", query.SQL, clause)
+					}
+				}
+				for _, absent := range tt.wantAbsent {
+					if strings.Contains(query.SQL, absent) {
+						t.Errorf("query %q contains %q", query.SQL, absent)
+					}
+				}
+			}
+			if count := queries[0]; len(count.Args) != 1+tt.wantFilterArgs {
+				t.Errorf("count query args = %v, want the user ID and %d filter arguments", count.Args, tt.wantFilterArgs)
+			}
+			if selectQuery := queries[1].SQL; !strings.Contains(selectQuery, tt.wantOrder) {
+				t.Errorf("query %q does not contain %q", selectQuery, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestAddUsage(t *testing.T) {
+	subID := uuid.New()
+	periodStart := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	dbErr := errors.New("connection reset")
+
+	tests := []struct {
+		name         string
+		result       sqlfake.Result
+		wantQuantity int64
+		wantErr      error
+	}{
+		{
+			name:         "total returned",
+			result:       sqlfake.Result{Columns: []string{"id", "quantity"}, Rows: [][]driver.Value{{int64(3), int64(12)}}},
+			wantQuantity: 12,
+		},
+		{name: "database error", result: sqlfake.Result{Err: dbErr}, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+			usage := &models.SubscriptionUsage{SubscriptionID: subID, Metric: "traffic_gb", Quantity: 5, PeriodStart: periodStart, PeriodEnd: periodStart.AddDate(0, 1, 0)}
+
+			err := NewSubscriptionRepository(db).AddUsage(context.Background(), usage)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("AddUsage() error = %v, want %v", err, tt.wantErr)
+			}
+			// The row returned by the upsert carries the period total, not the increment.
+			if err == nil && usage.Quantity != tt.wantQuantity {
+				t.Errorf("AddUsage() quantity = %d, want %d", usage.Quantity, tt.wantQuantity)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d statements, want 1: %v", len(queries), fake.SQL())
+			}
+			for _, fragment := range []string{
+				`INSERT INTO "subscription_usages"`,
+				`ON CONFLICT ("subscription_id","period_start","metric") DO UPDATE SET "quantity"=subscription_usages.quantity + EXCLUDED.quantity`,
+				"RETURNING *",
+			} {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("statement %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestListUsage(t *testing.T) {
+	subID := uuid.New()
+	db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+		return sqlfake.Result{Columns: []string{"id", "metric"}, Rows: [][]driver.Value{{int64(1), "api_calls"}, {int64(2), "traffic_gb"}}}
+	})
+
+	usage, err := NewSubscriptionRepository(db).ListUsage(context.Background(), subID)
+	if err != nil {
+		t.Fatalf("ListUsage() error = %v", err)
+	}
+	if len(usage) != 2 || usage[0].Metric != "api_calls" {
+		t.Errorf("ListUsage() = %+v, want the rows in query order", usage)
+	}
+	queries := fake.Queries()
+	if len(queries) != 1 || !strings.Contains(queries[0].SQL, "subscription_id = $1") || !strings.HasSuffix(queries[0].SQL, "ORDER BY period_start ASC, metric ASC") {
+		t.Fatalf("queries = %v, want the subscription's rows ordered by period and metric", fake.SQL())
+	}
+	if queries[0].Args[0] != subID.String() {
+		t.Errorf("query args = %v, want the subscription ID", queries[0].Args)
+	}
+}
+
+func TestListActivationCandidates(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		limit    int
+		wantArgs []any
+	}{
+		{name: "limited", limit: 50, wantArgs: []any{"paid", "trial", false, now, now, int64(50)}},
+		{name: "unlimited", wantArgs: []any{"paid", "trial", false, now, now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"id"}, Rows: [][]driver.Value{{uuid.NewString()}}}
+			})
+
+			subs, err := NewSubscriptionRepository(db).ListActivationCandidates(context.Background(), now, tt.limit)
+			if err != nil {
+				t.Fatalf("ListActivationCandidates() error = %v", err)
+			}
+			if len(subs) != 1 {
+				t.Errorf("ListActivationCandidates() returned %d subscriptions, want 1", len(subs))
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{
+				"payment_status IN ($1,$2) AND is_active = $3 AND start_date <= $4 AND end_date > $5",
+				`"subscriptions"."deleted_at" IS NULL`,
+				"ORDER BY start_date ASC",
+			} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			if hasLimit := strings.Contains(query.SQL, "LIMIT"); hasLimit != (tt.limit > 0) {
+				t.Errorf("query %q has a limit = %t, want %t", query.SQL, hasLimit, tt.limit > 0)
+			}
+			if !reflect.DeepEqual(query.Args, tt.wantArgs) {
+				t.Errorf("query args = %v, want %v", query.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestUpdateRecordsEvent(t *testing.T) {
+	insertErr := errors.New("insert failed")
+
+	tests := []struct {
+		name          string
+		event         *models.SubscriptionEvent
+		insertErr     error // Returned for the event insert; Update must return it.
+		wantErr       bool
+		wantStatement []string // Statement prefixes in order, including the transaction boundaries.
+	}{
+		{
+			name:          "event recorded with the update",
+			event:         &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventCancelled},
+			wantStatement: []string{"BEGIN", `UPDATE "subscriptions"`, `INSERT INTO "subscription_events"`, "COMMIT"},
+		},
+		{
+			name:          "failed event insert rolls back the update",
+			event:         &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventCancelled},
+			insertErr:     insertErr,
+			wantErr:       true,
+			wantStatement: []string{"BEGIN", `UPDATE "subscriptions"`, `INSERT INTO "subscription_events"`, "ROLLBACK"},
+		},
+		{name: "missing event is rejected", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, `INSERT INTO "subscription_events"`) && tt.insertErr != nil {
+					return sqlfake.Result{Err: tt.insertErr}
+				}
+				return sqlfake.Result{RowsAffected: 1}
+			})
+			sub := &models.Subscription{ID: uuid.New(), UserID: uuid.New(), DurationUnit: customTypes.UnitMonth, DurationValue: 1}
+
+			err := NewSubscriptionRepository(db).Update(context.Background(), sub, tt.event)
+			if (err != nil) != tt.wantErr || (tt.insertErr != nil && !errors.Is(err, tt.insertErr)) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			statements := fake.SQL()
+			if len(statements) != len(tt.wantStatement) {
+				t.Fatalf("statements = %v, want %v", statements, tt.wantStatement)
+			}
+			for i, prefix := range tt.wantStatement {
+				if !strings.HasPrefix(statements[i], prefix) {
+					t.Errorf("statement %d = %q, want it to start with %q", i, statements[i], prefix)
+				}
+			}
+			if tt.event != nil && tt.event.SubscriptionID != sub.ID {
+				t.Errorf("event subscription = %s, want %s", tt.event.SubscriptionID, sub.ID)
+			}
+		})
+	}
+}
+
+func TestListEvents(t *testing.T) {
+	subscriptionID := uuid.New()
+	created := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		total     int64
+		wantIDs   []uint
+		wantQuery int // Number of queries: the count, plus the select when there are events.
+	}{
+		{name: "events", total: 5, wantIDs: []uint{3, 4}, wantQuery: 2},
+		{name: "no events", total: 0, wantQuery: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{tt.total}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "subscription_id", "event_type", "created_at"}, Rows: [][]driver.Value{
+					{int64(3), subscriptionID.String(), "cancelled", created},
+					{int64(4), subscriptionID.String(), "autorenew_changed", created.Add(time.Hour)},
+				}}
+			})
+
+			events, total, err := NewSubscriptionRepository(db).ListEvents(context.Background(), subscriptionID, 2, 2)
+			if err != nil {
+				t.Fatalf("ListEvents() error = %v", err)
+			}
+			var ids []uint
+			for _, event := range events {
+				ids = append(ids, event.ID)
+			}
+			if total != tt.total || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ListEvents() = %v of %d, want %v of %d", ids, total, tt.wantIDs, tt.total)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != tt.wantQuery {
+				t.Fatalf("got %d queries, want %d: %v", len(queries), tt.wantQuery, fake.SQL())
+			}
+			for _, query := range queries {
+				if !strings.Contains(query.SQL, "WHERE subscription_id = $1") || query.Args[0] != subscriptionID.String() {
+					t.Errorf("query %q with args %v does not filter on the subscription", query.SQL, query.Args)
+				}
+			}
+			if tt.wantQuery == 2 && !strings.Contains(queries[1].SQL, "ORDER BY created_at ASC, id ASC LIMIT $2 OFFSET $3") {
+				t.Errorf("query %q is not ordered oldest first and paged", queries[1].SQL)
+			}
+		})
+	}
+}
+
+func TestGetByExternalPaymentID(t *testing.T) {
+	subID := uuid.New()
+
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantErr error
+	}{
+		{name: "found", rows: [][]driver.Value{{subID.String(), "pay_1"}}},
+		{name: "unknown payment", wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"id", "external_payment_id"}, Rows: tt.rows}
+			})
+
+			got, err := NewSubscriptionRepository(db).GetByExternalPaymentID(context.Background(), "pay_1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByExternalPaymentID() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (got.ID != subID || got.ExternalPaymentID == nil || *got.ExternalPaymentID != "pay_1") {
+				t.Errorf("GetByExternalPaymentID() = %+v, want subscription %s", got, subID)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 || !strings.Contains(queries[0].SQL, "external_payment_id = $1") || queries[0].Args[0] != "pay_1" {
+				t.Errorf("queries = %v, want one lookup by payment ID", queries)
+			}
+		})
+	}
+}
+
+func TestListActivePlanNames(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		rows [][]driver.Value
+		want []string
+	}{
+		{name: "active plans", rows: [][]driver.Value{{"Basic"}, {"Premium"}}, want: []string{"Basic", "Premium"}},
+		{name: "no active plans", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"plan_name"}, Rows: tt.rows}
+			})
+
+			got, err := NewSubscriptionRepository(db).ListActivePlanNames(context.Background(), userID)
+			if err != nil {
+				t.Fatalf("ListActivePlanNames() error = %v", err)
+			}
+			if got == nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListActivePlanNames() = %#v, want %#v", got, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			query := queries[0]
+			for _, fragment := range []string{"SELECT DISTINCT", "plan_name", "user_id = $1 AND is_active = $2 AND end_date > $3", "ORDER BY plan_name ASC"} {
+				if !strings.Contains(query.SQL, fragment) {
+					t.Errorf("query %q does not contain %q", query.SQL, fragment)
+				}
+			}
+			if query.Args[0] != userID.String() || query.Args[1] != true {
+				t.Errorf("query args = %v, want the user's active subscriptions", query.Args)
+			}
+			if endAfter, ok := query.Args[2].(time.Time); !ok || time.Since(endAfter) > time.Minute {
+				t.Errorf("end date bound = %v, want now", query.Args[2])
+			}
+		})
+	}
+}
+
+func TestSubscriptionOverviewCounts(t *testing.T) {
+	now := time.Date(2026, time.March, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		count     func(repo *subscriptionRepository) (any, error)
+		result    sqlfake.Result
+		want      any
+		fragments []string
+		wantArgs  []any
+	}{
+		{
+			name: "users with an active paid subscription",
+			count: func(repo *subscriptionRepository) (any, error) {
+				return repo.CountUsersWithActivePaidSubscription(context.Background(), now)
+			},
+			result: sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(2)}}},
+			want:   int64(2),
+			fragments: []string{
+				`SELECT COUNT(DISTINCT("user_id")) FROM "subscriptions"`,
+				"is_active = $1 AND payment_status = $2 AND start_date <= $3 AND end_date > $4",
+				`"subscriptions"."deleted_at" IS NULL`,
+			},
+			wantArgs: []any{true, "paid", now, now},
+		},
+		{
+			name: "subscriptions by payment status",
+			count: func(repo *subscriptionRepository) (any, error) {
+				return repo.CountByPaymentStatus(context.Background())
+			},
+			result: sqlfake.Result{Columns: []string{"payment_status", "count"}, Rows: [][]driver.Value{{"paid", int64(6)}, {"pending", int64(2)}}},
+			want:   []customTypes.PaymentStatusCount{{PaymentStatus: "paid", Count: 6}, {PaymentStatus: "pending", Count: 2}},
+			fragments: []string{
+				"SELECT payment_status, COUNT(*) AS count",
+				`"subscriptions"."deleted_at" IS NULL`,
+				`GROUP BY "payment_status" ORDER BY payment_status ASC`,
+			},
+		},
+		{
+			name: "active subscriptions by plan",
+			count: func(repo *subscriptionRepository) (any, error) {
+				return repo.CountActiveByPlan(context.Background(), now)
+			},
+			result: sqlfake.Result{Columns: []string{"plan_name", "count"}, Rows: [][]driver.Value{{"Premium", int64(3)}, {"Basic", int64(2)}}},
+			want:   []customTypes.PlanSubscriptionCount{{PlanName: "Premium", Count: 3}, {PlanName: "Basic", Count: 2}},
+			fragments: []string{
+				"SELECT plan_name, COUNT(*) AS count",
+				"is_active = $1 AND start_date <= $2 AND end_date > $3",
+				`"subscriptions"."deleted_at" IS NULL`,
+				`GROUP BY "plan_name" ORDER BY count DESC, plan_name ASC`,
+			},
+			wantArgs: []any{true, now, now},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result { return tt.result })
+
+			got, err := tt.count(NewSubscriptionRepository(db).(*subscriptionRepository))
+			if err != nil {
+				t.Fatalf("count error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("count = %+v, want %+v", got, tt.want)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+			}
+			for _, fragment := range tt.fragments {
+				if !strings.Contains(queries[0].SQL, fragment) {
+					t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+				}
+			}
+			if len(queries[0].Args) != len(tt.wantArgs) || len(tt.wantArgs) > 0 && !reflect.DeepEqual(queries[0].Args, tt.wantArgs) {
+				t.Errorf("query args = %v, want %v", queries[0].Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestScanInvalidDurationUnit(t *testing.T) {
+	userID := uuid.New()
+	validID, legacyID := uuid.New(), uuid.New()
+	valid := []driver.Value{validID.String(), userID.String(), "month"}
+	legacy := []driver.Value{legacyID.String(), userID.String(), "fortnight"}
+
+	tests := []struct {
+		name string
+		rows [][]driver.Value
+		read func(repo interfaces.SubscriptionRepository) ([]models.Subscription, error)
+		want []customTypes.DurationUnit
+	}{
+		{name: "get by ID", rows: [][]driver.Value{legacy}, want: []customTypes.DurationUnit{"fortnight"},
+			read: func(repo interfaces.SubscriptionRepository) ([]models.Subscription, error) {
+				sub, err := repo.GetByID(context.Background(), legacyID)
+				if err != nil {
+					return nil, err
+				}
+				return []models.Subscription{*sub}, nil
+			}},
+		{name: "list", rows: [][]driver.Value{valid, legacy}, want: []customTypes.DurationUnit{customTypes.UnitMonth, "fortnight"},
+			read: func(repo interfaces.SubscriptionRepository) ([]models.Subscription, error) {
+				subs, _, err := repo.List(context.Background(), 0, 10, customTypes.ListSubscriptionsFilters{})
+				return subs, err
+			}},
+		{name: "list by user", rows: [][]driver.Value{valid, legacy}, want: []customTypes.DurationUnit{customTypes.UnitMonth, "fortnight"},
+			read: func(repo interfaces.SubscriptionRepository) ([]models.Subscription, error) {
+				subs, _, err := repo.ListByUserID(context.Background(), userID, customTypes.ListUserSubscriptionsParams{Limit: 10})
+				return subs, err
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(len(tt.rows))}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "user_id", "duration_unit"}, Rows: tt.rows}
+			})
+
+			subs, err := tt.read(NewSubscriptionRepository(db))
+			if err != nil {
+				t.Fatalf("read error = %v", err)
+			}
+			var got []customTypes.DurationUnit
+			for _, sub := range subs {
+				got = append(got, sub.DurationUnit)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scanned duration units %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenewalPaymentLifecycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		paid       bool // Whether the renewal is paid before the grace period ends.
+		wantActive bool
+	}{
+		{name: "renewal paid", paid: true, wantActive: true},
+		{name: "renewal unpaid", wantActive: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, gormDB := newSQLiteDatabase(t)
+			repo := NewSubscriptionRepository(db)
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			user := &models.User{Name: "Alice", Email: "alice@example.com"}
+			if err := gormDB.Create(user).Error; err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+			firstPaymentID := "pay_first"
+			sub := &models.Subscription{UserID: user.ID, PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+				StartDate: now.AddDate(0, -1, 0), EndDate: now.Add(time.Hour), Price: 9.99, PaymentStatus: "paid",
+				ExternalPaymentID: &firstPaymentID, IsActive: true, AutoRenew: true}
+			if err := gormDB.Create(sub).Error; err != nil {
+				t.Fatalf("failed to seed subscription: %v", err)
+			}
+
+			candidates, err := repo.ListRenewalCandidates(ctx, now, now.Add(24*time.Hour), 10)
+			if err != nil || len(candidates) != 1 {
+				t.Fatalf("ListRenewalCandidates() = %d candidates, %v; want the subscription", len(candidates), err)
+			}
+			renewed := &candidates[0]
+			newEndDate := renewed.EndDate.AddDate(0, 1, 0)
+			if err := repo.ExtendForRenewal(ctx, renewed, newEndDate, &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventRenewed}); err != nil {
+				t.Fatalf("ExtendForRenewal() error = %v", err)
+			}
+			// The payment of the previous period no longer identifies the subscription, so the renewal can be paid.
+			if _, err := repo.GetByExternalPaymentID(ctx, firstPaymentID); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("GetByExternalPaymentID(previous payment) error = %v, want %v", err, gorm.ErrRecordNotFound)
+			}
+			stored, err := repo.GetByID(ctx, sub.ID)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if stored.PaymentStatus != "pending" || stored.ExternalPaymentID != nil || stored.LastRenewedAt == nil || !stored.EndDate.Equal(newEndDate) {
+				t.Fatalf("renewed subscription = status %q, payment %v, renewed at %v, end %v; want pending, none, set, %v",
+					stored.PaymentStatus, stored.ExternalPaymentID, stored.LastRenewedAt, stored.EndDate, newEndDate)
+			}
+
+			if tt.paid {
+				renewalPaymentID := "pay_renewal"
+				stored.PaymentStatus, stored.ExternalPaymentID = "paid", &renewalPaymentID
+				if err := repo.Update(ctx, stored, &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventPaymentReceived}); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+
+			if unpaid, err := repo.ListUnpaidRenewals(ctx, now.Add(-time.Hour), 10); err != nil || len(unpaid) != 0 {
+				t.Errorf("ListUnpaidRenewals(before the renewal) = %d, %v; want none", len(unpaid), err)
+			}
+			unpaid, err := repo.ListUnpaidRenewals(ctx, now.Add(time.Hour), 10)
+			if err != nil {
+				t.Fatalf("ListUnpaidRenewals() error = %v", err)
+			}
+			if listed := len(unpaid) == 1; listed == tt.paid || len(unpaid) > 1 {
+				t.Fatalf("ListUnpaidRenewals() = %d subscriptions, want the renewal listed = %v", len(unpaid), !tt.paid)
+			}
+			deactivated := &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventDeactivated}
+			if tt.paid {
+				// A renewal paid meanwhile is never deactivated.
+				if err := repo.DeactivateUnpaidRenewal(ctx, stored, deactivated); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("DeactivateUnpaidRenewal(paid) error = %v, want %v", err, gorm.ErrRecordNotFound)
+				}
+			} else {
+				if err := repo.DeactivateUnpaidRenewal(ctx, &unpaid[0], deactivated); err != nil {
+					t.Fatalf("DeactivateUnpaidRenewal() error = %v", err)
+				}
+				if err := repo.DeactivateUnpaidRenewal(ctx, &unpaid[0], deactivated); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("second DeactivateUnpaidRenewal() error = %v, want %v", err, gorm.ErrRecordNotFound)
+				}
+			}
+
+			_, err = repo.GetActiveByUserID(ctx, user.ID)
+			if (err == nil) != tt.wantActive {
+				t.Errorf("GetActiveByUserID() error = %v, want an active subscription = %v", err, tt.wantActive)
+			}
+			var events int64
+			if err := gormDB.Model(&models.SubscriptionEvent{}).Where("subscription_id = ?", sub.ID).Count(&events).Error; err != nil || events != 2 {
+				t.Errorf("recorded events = %d, %v; want the renewal and the payment or deactivation", events, err)
+			}
+		})
+	}
+}
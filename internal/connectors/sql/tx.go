@@ -0,0 +1,17 @@
+package sql
+
+import (
+	"bitback/internal/database"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// dbFromContext returns the handle a repository should query with: the transaction carried by ctx
+// if there is one, and db otherwise.
+func dbFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx := database.TxFromContext(ctx); tx != nil {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
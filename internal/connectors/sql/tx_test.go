@@ -0,0 +1,148 @@
+package sql
+
+import (
+	"bitback/internal/database"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestRepositoriesJoinContextTransaction(t *testing.T) {
+	failErr := errors.New("checkout aborted")
+	takenPaymentID := "pay_taken"
+
+	tests := []struct {
+		name          string
+		paymentID     *string // External payment ID of the new subscription; a taken one makes its insert fail.
+		failAfter     bool    // The transaction fails after both repositories wrote.
+		wantCommitted bool
+	}{
+		{name: "committed", wantCommitted: true},
+		{name: "subscription insert fails", paymentID: &takenPaymentID},
+		{name: "transaction fails after the writes", failAfter: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, gormDB := newSQLiteDatabase(t)
+			user := &models.User{Name: "Alice", Email: "alice@example.com"}
+			if err := gormDB.Create(user).Error; err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+			existing := &models.Subscription{UserID: user.ID, PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+				StartDate: time.Now(), EndDate: time.Now().AddDate(0, 1, 0), ExternalPaymentID: &takenPaymentID}
+			if err := gormDB.Create(existing).Error; err != nil {
+				t.Fatalf("failed to seed subscription: %v", err)
+			}
+			users, subs := NewUserRepository(db), NewSubscriptionRepository(db)
+
+			// As CreateSubscription does through SQLDatabase.WithTx: lock the user, change it and insert the subscription.
+			err := db.gorm.Transaction(func(tx *gorm.DB) error {
+				ctx := database.ContextWithTx(context.Background(), tx)
+				locked, err := users.GetByIDForUpdate(ctx, user.ID)
+				if err != nil {
+					return err
+				}
+				locked.Name = "Alice Renamed"
+				if err := users.Update(ctx, locked); err != nil {
+					return err
+				}
+				sub := &models.Subscription{UserID: user.ID, PlanName: "Pro", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+					StartDate: time.Now(), EndDate: time.Now().AddDate(0, 1, 0), ExternalPaymentID: tt.paymentID}
+				if err := subs.Create(ctx, sub, &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventCreated}); err != nil {
+					return err
+				}
+				if tt.failAfter {
+					return failErr
+				}
+				return nil
+			})
+			if (err == nil) != tt.wantCommitted {
+				t.Fatalf("transaction error = %v, want committed = %v", err, tt.wantCommitted)
+			}
+			if tt.failAfter && !errors.Is(err, failErr) {
+				t.Errorf("transaction error = %v, want %v", err, failErr)
+			}
+
+			// The writes of both repositories are kept or discarded together.
+			var stored models.User
+			if err := gormDB.First(&stored, "id = ?", user.ID).Error; err != nil {
+				t.Fatalf("failed to read user: %v", err)
+			}
+			var proCount, eventCount int64
+			if err := gormDB.Model(&models.Subscription{}).Where("plan_name = ?", "Pro").Count(&proCount).Error; err != nil {
+				t.Fatalf("failed to count subscriptions: %v", err)
+			}
+			if err := gormDB.Model(&models.SubscriptionEvent{}).Count(&eventCount).Error; err != nil {
+				t.Fatalf("failed to count events: %v", err)
+			}
+			wantName, wantCount := "Alice", int64(0)
+			if tt.wantCommitted {
+				wantName, wantCount = "Alice Renamed", 1
+			}
+			if stored.Name != wantName || proCount != wantCount || eventCount != wantCount {
+				t.Errorf("user name = %q, subscriptions = %d, events = %d; want %q, %d, %d",
+					stored.Name, proCount, eventCount, wantName, wantCount, wantCount)
+			}
+		})
+	}
+}
+
+func TestGetByIDForUpdateRequiresTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		get  func(db *fakeSQLDatabase) error
+	}{
+		{name: "user", get: func(db *fakeSQLDatabase) error {
+			_, err := NewUserRepository(db).GetByIDForUpdate(context.Background(), uuid.New())
+			return err
+		}},
+		{name: "subscription", get: func(db *fakeSQLDatabase) error {
+			_, err := NewSubscriptionRepository(db).GetByIDForUpdate(context.Background(), uuid.New())
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, nil)
+
+			if err := tt.get(db); err == nil {
+				t.Fatal("GetByIDForUpdate() error = nil outside a transaction")
+			}
+			if got := fake.SQL(); len(got) != 0 {
+				t.Errorf("statements = %q, want none", got)
+			}
+		})
+	}
+}
+
+func TestSubscriptionGetByIDForUpdateLocksRow(t *testing.T) {
+	subID := uuid.New()
+	db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+		return sqlfake.Result{Columns: []string{"id", "plan_name"}, Rows: [][]driver.Value{{subID.String(), "Basic"}}}
+	})
+
+	var got *models.Subscription
+	err := db.gorm.Transaction(func(tx *gorm.DB) error {
+		var err error
+		got, err = NewSubscriptionRepository(db).GetByIDForUpdate(database.ContextWithTx(context.Background(), tx), subID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetByIDForUpdate() error = %v", err)
+	}
+	if got.ID != subID {
+		t.Errorf("GetByIDForUpdate() = %s, want %s", got.ID, subID)
+	}
+	if stmts := fake.Queries(); len(stmts) != 1 || !strings.HasSuffix(stmts[0].SQL, "FOR UPDATE") {
+		t.Errorf("statements = %v, want one locking select", fake.SQL())
+	}
+}
@@ -1,14 +1,26 @@
 package sql
 
 import (
+	"bitback/internal/database"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// userEmailIndexName is the name of the unique index on lower(email) for non-deleted users.
+	userEmailIndexName = "idx_users_email_lower"
+	// userTelegramIDIndexName is the name of the unique index on telegram_id for non-deleted users with a Telegram ID.
+	userTelegramIDIndexName = "idx_users_telegram_id"
 )
 
 // userRepository implements the interfaces.UserRepository for interacting with user data in a SQL database.
@@ -29,8 +41,11 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 		return errors.New("user to create cannot be nil")
 	}
 	// GORM's Create method will also trigger BeforeCreate hooks on the user model.
-	err := r.db.WithContext(ctx).Create(user).Error
+	err := dbFromContext(ctx, r.db).Create(user).Error
 	if err != nil {
+		if takenErr := uniqueUserAttributeError(err); takenErr != nil {
+			return fmt.Errorf("failed to create user: %w", takenErr)
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
@@ -40,12 +55,25 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 // Returns gorm.ErrRecordNotFound if no user is found.
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+	if err := dbFromContext(ctx, r.db).First(&user, "id = ?", id).Error; err != nil {
 		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
 	}
 	return &user, nil
 }
 
+// GetByIDForUpdate retrieves a user by their UUID with a row lock held until the surrounding transaction ends,
+// so the user cannot be modified or deleted concurrently.
+func (r *userRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if database.TxFromContext(ctx) == nil {
+		return nil, errors.New("GetByIDForUpdate must be called within a transaction")
+	}
+	var user models.User
+	if err := dbFromContext(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // GetByIDs retrieves a list of users based on a slice of UUIDs.
 // If the ids slice is empty, it returns an empty list of users without querying the database.
 func (r *userRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]models.User, error) {
@@ -54,17 +82,56 @@ func (r *userRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model
 	}
 	var users []models.User
 	// GORM automatically handles constructing an IN query for a slice of IDs: WHERE id IN (...).
-	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+	if err := dbFromContext(ctx, r.db).Where("id IN ?", ids).Find(&users).Error; err != nil {
 		return nil, fmt.Errorf("failed to get users by IDs: %w", err)
 	}
 	return users, nil
 }
 
-// GetByEmail retrieves a user by their email address.
+// GetByEmail retrieves a user by their email address, ignoring case.
 // Returns gorm.ErrRecordNotFound if no user with the specified email is found.
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := dbFromContext(ctx, r.db).Where("lower(email) = lower(?)", email).First(&user).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
+	}
+	return &user, nil
+}
+
+// ExistsDeletedByEmailOrTelegramID reports whether a soft-deleted user has the email, ignoring case, or the
+// Telegram ID. Empty emails and zero Telegram IDs are not matched.
+func (r *userRepository) ExistsDeletedByEmailOrTelegramID(ctx context.Context, email string, telegramID int64) (bool, error) {
+	if email == "" && telegramID == 0 {
+		return false, nil
+	}
+	db := dbFromContext(ctx, r.db)
+	var match *gorm.DB
+	if email != "" {
+		match = db.Where("lower(email) = lower(?)", email)
+	}
+	if telegramID != 0 {
+		if match == nil {
+			match = db.Where("telegram_id = ?", telegramID)
+		} else {
+			match = match.Or("telegram_id = ?", telegramID)
+		}
+	}
+	var count int64
+	err := db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL").
+		Where(match).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to look up deleted users: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetByTelegramID retrieves a user by their Telegram ID.
+// Returns gorm.ErrRecordNotFound if no user with the specified Telegram ID is found.
+func (r *userRepository) GetByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	var user models.User
+	if err := dbFromContext(ctx, r.db).Where("telegram_id = ?", telegramID).First(&user).Error; err != nil {
 		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
 	}
 	return &user, nil
@@ -79,8 +146,11 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 		return errors.New("user ID is required for update")
 	}
 
-	err := r.db.WithContext(ctx).Updates(user).Error
+	err := dbFromContext(ctx, r.db).Updates(user).Error
 	if err != nil {
+		if takenErr := uniqueUserAttributeError(err); takenErr != nil {
+			return fmt.Errorf("failed to update user: %w", takenErr)
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 	return nil
@@ -94,7 +164,7 @@ func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 
 	// GORM's Delete method on a model with gorm.DeletedAt will perform a soft delete.
-	result := r.db.WithContext(ctx).Delete(&models.User{}, id)
+	result := dbFromContext(ctx, r.db).Delete(&models.User{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete user: %w", result.Error)
 	}
@@ -105,13 +175,15 @@ func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// List retrieves a paginated list of users, ordered by creation date (newest first).
-func (r *userRepository) List(ctx context.Context, offset, limit int) ([]models.User, int64, error) {
+// List retrieves a paginated list of users matching the filters in params.
+// The count applies the same filters, so it reflects the filtered result set.
+func (r *userRepository) List(ctx context.Context, params customTypes.ListUsersParams) ([]models.User, int64, error) {
 	var users []models.User
 	var total int64
 
-	// Count the total number of users (without pagination constraints) for pagination metadata.
-	if err := r.db.WithContext(ctx).Model(&models.User{}).Count(&total).Error; err != nil {
+	query := applyUserListFilters(dbFromContext(ctx, r.db).Model(&models.User{}), params)
+	// Count the total number of matching users (without pagination constraints) for pagination metadata.
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
@@ -119,11 +191,246 @@ func (r *userRepository) List(ctx context.Context, offset, limit int) ([]models.
 		return []models.User{}, 0, nil
 	}
 
-	// Retrieve the paginated slice of users.
-	query := r.db.WithContext(ctx).Offset(offset).Limit(limit).Order("created_at DESC")
+	// Whitelist valid sortable columns to prevent SQL injection.
+	validSortableColumns := map[string]string{
+		"created_at": "created_at",
+		"name":       "name",
+		"email":      "email",
+		"last_login": "last_login",
+	}
+	sortColumn, ok := validSortableColumns[strings.ToLower(params.SortBy)]
+	if !ok {
+		sortColumn = "created_at"
+	}
+	order := "DESC"
+	if strings.ToLower(params.SortOrder) == "asc" {
+		order = "ASC"
+	}
 
+	// Retrieve the paginated slice of users.
+	nulls := ""
+	if sortColumn == "last_login" {
+		nulls = " NULLS LAST" // Users who never logged in sort after everyone else in either direction.
+	}
+	query = query.Order(fmt.Sprintf("%s %s%s, id %s", sortColumn, order, nulls, order)).Offset(params.Offset).Limit(params.Limit)
 	if err := query.Find(&users).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to list users: %w", err)
 	}
 	return users, total, nil
 }
+
+// ListAfter retrieves up to params.Limit users matching the filters in params, ordered by creation date and ID,
+// newest first, starting after the cursor. Unlike List, it seeks with a (created_at, id) comparison instead of
+// an OFFSET, so deep pages stay cheap; params.Offset and the sort parameters are ignored.
+func (r *userRepository) ListAfter(ctx context.Context, params customTypes.ListUsersParams, after *customTypes.UserListCursor) ([]models.User, error) {
+	var users []models.User
+	query := applyUserListFilters(dbFromContext(ctx, r.db).Model(&models.User{}), params).
+		Order("created_at DESC, id DESC").
+		Limit(params.Limit)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users after cursor: %w", err)
+	}
+	return users, nil
+}
+
+// applyUserListFilters applies the optional filters of ListUsersParams to a user query.
+func applyUserListFilters(query *gorm.DB, params customTypes.ListUsersParams) *gorm.DB {
+	if params.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if params.Query != nil && *params.Query != "" {
+		pattern := "%" + strings.ToLower(*params.Query) + "%"
+		query = query.Where("(LOWER(name) LIKE ? OR LOWER(email) LIKE ?)", pattern, pattern)
+	}
+	if params.IsActive != nil {
+		query = query.Where("is_active = ?", *params.IsActive)
+	}
+	if params.HasTelegram != nil {
+		if *params.HasTelegram {
+			query = query.Where("telegram_id <> 0")
+		} else {
+			query = query.Where("telegram_id = 0")
+		}
+	}
+	if params.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *params.CreatedAfter)
+	}
+	if params.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *params.CreatedBefore)
+	}
+	return query
+}
+
+// UpdateLastLogin sets the last_login column of a user with a targeted UPDATE instead of Save,
+// so concurrent changes to other columns are not overwritten.
+// Returns gorm.ErrRecordNotFound if no non-deleted user has the ID.
+func (r *userRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := dbFromContext(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Update("last_login", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update last login: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListInactiveSince retrieves a paginated list of users whose last login is before since or who never logged in.
+// Users who never logged in come first, followed by the longest inactive.
+func (r *userRepository) ListInactiveSince(ctx context.Context, since time.Time, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := dbFromContext(ctx, r.db).Model(&models.User{}).Where("last_login IS NULL OR last_login < ?", since)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count inactive users: %w", err)
+	}
+
+	if total == 0 {
+		return []models.User{}, 0, nil
+	}
+
+	if err := query.Order("last_login ASC NULLS FIRST, created_at ASC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list inactive users: %w", err)
+	}
+	return users, total, nil
+}
+
+// ListWithActiveKeyInCountry retrieves a paginated list of users with at least one active key assignment
+// on a non-deleted host in the given country (case-insensitive). Each user is listed once, oldest first.
+func (r *userRepository) ListWithActiveKeyInCountry(ctx context.Context, country string, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	db := dbFromContext(ctx, r.db)
+	assigned := db.Model(&models.KeyAssignment{}).
+		Select("1").
+		Joins("JOIN hosts ON hosts.id = key_assignments.host_id AND hosts.deleted_at IS NULL").
+		Where("key_assignments.user_id = users.id AND key_assignments.is_active = ? AND LOWER(hosts.country) = LOWER(?)", true, country)
+
+	query := db.Model(&models.User{}).Where("EXISTS (?)", assigned)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users with active keys in country %q: %w", country, err)
+	}
+
+	if total == 0 {
+		return []models.User{}, 0, nil
+	}
+
+	if err := query.Order("created_at ASC, id ASC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users with active keys in country %q: %w", country, err)
+	}
+	return users, total, nil
+}
+
+// GetByIDUnscoped retrieves a user by their unique UUID, including users that have been soft-deleted.
+// Returns gorm.ErrRecordNotFound if no user is found.
+func (r *userRepository) GetByIDUnscoped(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := dbFromContext(ctx, r.db).Unscoped().First(&user, "id = ?", id).Error; err != nil {
+		return nil, err // err will be gorm.ErrRecordNotFound if the record is not found.
+	}
+	return &user, nil
+}
+
+// Purge permanently deletes a user together with their subscriptions and key assignments in a single transaction.
+// Soft-deleted rows are removed as well.
+func (r *userRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	if id == uuid.Nil {
+		return errors.New("user ID is required for purge")
+	}
+
+	return dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Active assignments stop counting towards their hosts' current users.
+		activePerHost := tx.Unscoped().Model(&models.KeyAssignment{}).
+			Select("host_id, COUNT(*) AS active_count").
+			Where("user_id = ? AND is_active = ? AND deleted_at IS NULL", id, true).
+			Group("host_id")
+		if err := tx.Exec(
+			"UPDATE hosts SET current_users = GREATEST(hosts.current_users - a.active_count, 0) FROM (?) AS a WHERE hosts.id = a.host_id",
+			activePerHost,
+		).Error; err != nil {
+			return fmt.Errorf("failed to release hosts of user %s: %w", id, err)
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.KeyAssignment{}).Error; err != nil {
+			return fmt.Errorf("failed to purge key assignments of user %s: %w", id, err)
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.Subscription{}).Error; err != nil {
+			return fmt.Errorf("failed to purge subscriptions of user %s: %w", id, err)
+		}
+
+		result := tx.Unscoped().Delete(&models.User{}, "id = ?", id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge user %s: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound // Roll back; nothing to purge.
+		}
+		return nil
+	})
+}
+
+// Restore clears the DeletedAt timestamp of a soft-deleted user and returns the restored user.
+// Returns gorm.ErrRecordNotFound if the user does not exist or is not deleted, and interfaces.ErrEmailTaken or
+// interfaces.ErrTelegramIDTaken if an active user now holds the same email or Telegram ID.
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	result := dbFromContext(ctx, r.db).Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		if takenErr := uniqueUserAttributeError(result.Error); takenErr != nil {
+			return nil, fmt.Errorf("failed to restore user: %w", takenErr)
+		}
+		return nil, fmt.Errorf("failed to restore user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound // No soft-deleted user with this ID.
+	}
+	return r.GetByID(ctx, id)
+}
+
+// ListDeletedBefore retrieves up to limit users whose soft deletion happened before the specified time.
+// Users are ordered by deletion time (oldest first).
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := dbFromContext(ctx, r.db).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users deleted before %s: %w", before, err)
+	}
+	return users, nil
+}
+
+// uniqueUserAttributeError translates a unique violation of the users' email or Telegram ID index
+// into interfaces.ErrEmailTaken or interfaces.ErrTelegramIDTaken. It returns nil for any other error.
+func uniqueUserAttributeError(err error) error {
+	switch uniqueViolationConstraint(err) {
+	case userEmailIndexName:
+		return interfaces.ErrEmailTaken
+	case userTelegramIDIndexName:
+		return interfaces.ErrTelegramIDTaken
+	}
+	return nil
+}
+
+// CountSummary counts all users, the active ones and those created within the 7 and 30 days before now
+// in a single query.
+func (r *userRepository) CountSummary(ctx context.Context, now time.Time) (*customTypes.UserCounts, error) {
+	var counts customTypes.UserCounts
+	err := dbFromContext(ctx, r.db).Model(&models.User{}).
+		Select(`COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE is_active = TRUE) AS active,
+			COUNT(*) FILTER (WHERE created_at >= ?) AS new_last7_days,
+			COUNT(*) FILTER (WHERE created_at >= ?) AS new_last30_days`, now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	return &counts, nil
+}
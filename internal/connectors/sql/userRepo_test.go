@@ -0,0 +1,255 @@
+package sql
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/testutil/sqlfake"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestGetByTelegramID(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantErr error
+	}{
+		{name: "found", rows: [][]driver.Value{{userID.String(), "Telegram User", int64(123456789)}}},
+		{name: "not found", wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"id", "name", "telegram_id"}, Rows: tt.rows}
+			})
+
+			user, err := NewUserRepository(db).GetByTelegramID(context.Background(), 123456789)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetByTelegramID() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (user.ID != userID || user.TelegramID != 123456789) {
+				t.Errorf("GetByTelegramID() = %+v, want user %s", user, userID)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 || !strings.Contains(queries[0].SQL, "telegram_id = $1") || !strings.Contains(queries[0].SQL, `"users"."deleted_at" IS NULL`) {
+				t.Fatalf("queries = %v, want a single lookup of non-deleted users by telegram_id", fake.SQL())
+			}
+			if queries[0].Args[0] != int64(123456789) {
+				t.Errorf("query args = %v, want the Telegram ID first", queries[0].Args)
+			}
+		})
+	}
+}
+
+func TestGetByEmail(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantErr error
+	}{
+		{name: "found", rows: [][]driver.Value{{userID.String(), "foo@bar.com"}}},
+		{name: "not found", wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+				return sqlfake.Result{Columns: []string{"id", "email"}, Rows: tt.rows}
+			})
+
+			user, err := NewUserRepository(db).GetByEmail(context.Background(), "Foo@Bar.com")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetByEmail() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.ID != userID {
+				t.Errorf("GetByEmail() = %+v, want user %s", user, userID)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != 1 || !strings.Contains(queries[0].SQL, "lower(email) = lower($1)") || !strings.Contains(queries[0].SQL, `"users"."deleted_at" IS NULL`) {
+				t.Fatalf("queries = %v, want a single case-insensitive lookup of non-deleted users by email", fake.SQL())
+			}
+			if queries[0].Args[0] != "Foo@Bar.com" {
+				t.Errorf("query args = %v, want the email first", queries[0].Args)
+			}
+		})
+	}
+}
+
+func TestCreateUserUniqueViolation(t *testing.T) {
+	otherErr := &pgconn.PgError{Code: "23505", ConstraintName: "users_pkey"}
+
+	tests := []struct {
+		name    string
+		dbErr   error
+		wantErr error
+	}{
+		{name: "email index", dbErr: &pgconn.PgError{Code: "23505", ConstraintName: userEmailIndexName}, wantErr: interfaces.ErrEmailTaken},
+		{name: "Telegram ID index", dbErr: &pgconn.PgError{Code: "23505", ConstraintName: userTelegramIDIndexName}, wantErr: interfaces.ErrTelegramIDTaken},
+		{name: "other unique index", dbErr: otherErr, wantErr: otherErr},
+		{name: "no violation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, "INSERT") {
+					return sqlfake.Result{RowsAffected: 1, Err: tt.dbErr}
+				}
+				return sqlfake.Result{}
+			})
+
+			err := NewUserRepository(db).Create(context.Background(), &models.User{ID: uuid.New(), Name: "Foo", Email: "foo@bar.com"})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == otherErr && (errors.Is(err, interfaces.ErrEmailTaken) || errors.Is(err, interfaces.ErrTelegramIDTaken)) {
+				t.Errorf("Create() reported a taken attribute for another index")
+			}
+		})
+	}
+}
+
+func TestListWithActiveKeyInCountry(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int64
+		wantQuery int // Number of queries: the count, plus the select if any user matches.
+	}{
+		{name: "users in country", total: 3, wantQuery: 2},
+		{name: "no users in country", total: 0, wantQuery: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{tt.total}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "name"}, Rows: [][]driver.Value{{userID.String(), "Berlin User"}}}
+			})
+
+			users, total, err := NewUserRepository(db).ListWithActiveKeyInCountry(context.Background(), "de", 2, 1)
+			if err != nil {
+				t.Fatalf("ListWithActiveKeyInCountry() error = %v", err)
+			}
+			if total != tt.total || (tt.total > 0 && (len(users) != 1 || users[0].ID != userID)) || (tt.total == 0 && users == nil) {
+				t.Errorf("ListWithActiveKeyInCountry() = %+v (total %d), want total %d", users, total, tt.total)
+			}
+
+			queries := fake.Queries()
+			if len(queries) != tt.wantQuery {
+				t.Fatalf("got %d queries, want %d: %v", len(queries), tt.wantQuery, fake.SQL())
+			}
+			for _, query := range queries {
+				for _, fragment := range []string{
+					"EXISTS (SELECT 1 FROM \"key_assignments\" JOIN hosts ON hosts.id = key_assignments.host_id AND hosts.deleted_at IS NULL",
+					"key_assignments.user_id = users.id AND key_assignments.is_active = $1 AND LOWER(hosts.country) = LOWER($2)",
+					`"users"."deleted_at" IS NULL`,
+				} {
+					if !strings.Contains(query.SQL, fragment) {
+						t.Errorf("query %q does not contain %q", query.SQL, fragment)
+					}
+				}
+				if len(query.Args) < 2 || query.Args[0] != true || query.Args[1] != "de" {
+					t.Errorf("query args = %v, want them to start with [true de]", query.Args)
+				}
+			}
+			if tt.wantQuery == 2 {
+				if selectQuery := queries[1].SQL; !strings.Contains(selectQuery, "ORDER BY created_at ASC, id ASC LIMIT $3 OFFSET $4") {
+					t.Errorf("query %q is not ordered oldest first and paged", selectQuery)
+				}
+			}
+		})
+	}
+}
+
+func TestListUsersIncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		includeDeleted bool
+	}{
+		{name: "deleted users excluded by default"},
+		{name: "deleted users included", includeDeleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeSQLDatabase(t, func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.Contains(stmt.SQL, "count(*)") {
+					return sqlfake.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}
+				}
+				return sqlfake.Result{Columns: []string{"id", "deleted_at"}, Rows: [][]driver.Value{{uuid.NewString(), deletedAt}}}
+			})
+
+			users, _, err := NewUserRepository(db).List(context.Background(), customTypes.ListUsersParams{IncludeDeleted: tt.includeDeleted, Limit: 10})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(users) != 1 || !users[0].DeletedAt.Valid || !users[0].DeletedAt.Time.Equal(deletedAt) {
+				t.Errorf("List() = %+v, want the user with its deletion time", users)
+			}
+			queries := fake.Queries()
+			if len(queries) != 2 {
+				t.Fatalf("got %d queries, want the count and the select: %v", len(queries), fake.SQL())
+			}
+			for _, query := range queries {
+				if excluded := strings.Contains(query.SQL, `"users"."deleted_at" IS NULL`); excluded == tt.includeDeleted {
+					t.Errorf("query %q excludes deleted users = %t, want %t", query.SQL, excluded, !tt.includeDeleted)
+				}
+			}
+		})
+	}
+}
+
+func TestCountSummary(t *testing.T) {
+	now := time.Date(2026, time.March, 31, 12, 0, 0, 0, time.UTC)
+
+	db, fake := newFakeSQLDatabase(t, func(sqlfake.Statement) sqlfake.Result {
+		return sqlfake.Result{
+			Columns: []string{"total", "active", "new_last7_days", "new_last30_days"},
+			Rows:    [][]driver.Value{{int64(4), int64(3), int64(2), int64(3)}},
+		}
+	})
+
+	got, err := NewUserRepository(db).CountSummary(context.Background(), now)
+	if err != nil {
+		t.Fatalf("CountSummary() error = %v", err)
+	}
+	if want := (customTypes.UserCounts{Total: 4, Active: 3, NewLast7Days: 2, NewLast30Days: 3}); *got != want {
+		t.Errorf("CountSummary() = %+v, want %+v", *got, want)
+	}
+
+	queries := fake.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("got %d queries, want 1: %v", len(queries), fake.SQL())
+	}
+	for _, fragment := range []string{
+		"COUNT(*) AS total",
+		"COUNT(*) FILTER (WHERE is_active = TRUE) AS active",
+		"COUNT(*) FILTER (WHERE created_at >= $1) AS new_last7_days",
+		"COUNT(*) FILTER (WHERE created_at >= $2) AS new_last30_days",
+		`FROM "users" WHERE "users"."deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(queries[0].SQL, fragment) {
+			t.Errorf("query %q does not contain %q", queries[0].SQL, fragment)
+		}
+	}
+	if want := []any{now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)}; !reflect.DeepEqual(queries[0].Args, want) {
+		t.Errorf("query args = %v, want %v", queries[0].Args, want)
+	}
+}
@@ -3,7 +3,9 @@ package database
 import (
 	"bitback/internal/config"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"context"
+	"errors"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -78,6 +80,13 @@ func NewPostgresDB(_ context.Context, cfg *config.Config) (*PostgresDB, error) {
 		&models.User{},
 		&models.Host{},
 		&models.Subscription{},
+		&models.KeyAssignment{},
+		&models.KeyGeneration{},
+		&models.HostCheck{},
+		&models.Plan{},
+		&models.PromoCode{},
+		&models.SubscriptionEvent{},
+		&models.SubscriptionUsage{},
 	)
 	if err != nil {
 		slog.Error("GORM auto-migration failed", "error", err)
@@ -85,17 +94,91 @@ func NewPostgresDB(_ context.Context, cfg *config.Config) (*PostgresDB, error) {
 		slog.Info("GORM auto-migrations completed successfully.")
 	}
 
+	if err := syncHostNameUniqueIndex(db, cfg.EnforceUniqueHostNames); err != nil {
+		slog.Error("Failed to sync host name unique index", "enforce", cfg.EnforceUniqueHostNames, "error", err)
+	}
+	if err := backfillHostAddressFamilies(db); err != nil {
+		slog.Error("Failed to classify host address families", "error", err)
+	}
+	if err := dropSubscriptionRenewalLinks(db); err != nil {
+		slog.Error("Failed to drop subscription renewal link columns", "error", err)
+	}
+
 	return &PostgresDB{
 		gorm: db,
 		cfg:  cfg,
 	}, nil
 }
 
+// syncHostNameUniqueIndex creates the partial unique index on host names when uniqueness is enforced
+// and drops it otherwise, so the flag can be toggled between deployments.
+// Creating the index fails if non-deleted hosts already share a name; such duplicates must be renamed first.
+func syncHostNameUniqueIndex(db *gorm.DB, enforce bool) error {
+	if !enforce {
+		return db.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", models.HostNameUniqueIndex)).Error
+	}
+	return db.Exec(fmt.Sprintf(
+		"CREATE UNIQUE INDEX IF NOT EXISTS %s ON hosts (lower(host_name)) WHERE host_name <> '' AND deleted_at IS NULL",
+		models.HostNameUniqueIndex,
+	)).Error
+}
+
+// backfillHostAddressFamilies classifies the addresses of hosts saved before address families were recorded,
+// including soft-deleted ones, so they can be restored with their family set.
+func backfillHostAddressFamilies(db *gorm.DB) error {
+	var hosts []models.Host
+	if err := db.Unscoped().Select("id", "address").Where("address_family IS NULL OR address_family = ''").Find(&hosts).Error; err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		family := customTypes.AddressFamilyOf(host.Address)
+		if err := db.Unscoped().Model(&models.Host{}).Where("id = ?", host.ID).UpdateColumn("address_family", family).Error; err != nil {
+			return err
+		}
+	}
+	if len(hosts) > 0 {
+		slog.Info("Classified host address families", "count", len(hosts))
+	}
+	return nil
+}
+
+// dropSubscriptionRenewalLinks drops the columns that linked a subscription to the one renewing it.
+// Renewals extend a subscription in place, so the links are no longer written or read.
+func dropSubscriptionRenewalLinks(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, column := range []string{"renewed_from_id", "renewed_to_id"} {
+		if !migrator.HasColumn(&models.Subscription{}, column) {
+			continue
+		}
+		if index := "idx_subscriptions_" + column; migrator.HasIndex(&models.Subscription{}, index) {
+			if err := migrator.DropIndex(&models.Subscription{}, index); err != nil {
+				return err
+			}
+		}
+		if err := migrator.DropColumn(&models.Subscription{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetGormClient returns the GORM database client instance.
 func (pg *PostgresDB) GetGormClient() *gorm.DB {
 	return pg.gorm
 }
 
+// WithTx runs fn inside a database transaction. The context passed to fn carries the transaction,
+// so repository calls made with it take part in the transaction. The transaction is committed if fn
+// returns nil and rolled back otherwise. If ctx already carries a transaction, fn joins it.
+func (pg *PostgresDB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if TxFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	return pg.gorm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(ContextWithTx(ctx, tx))
+	})
+}
+
 // closeGormDB attempts to close the GORM database connection if it exists.
 func closeGormDB(gormDB *gorm.DB) error {
 	if gormDB != nil {
@@ -111,24 +194,24 @@ func closeGormDB(gormDB *gorm.DB) error {
 }
 
 // Ping checks the database connection by sending a ping.
-func (pg *PostgresDB) Ping() {
-	slog.Info("Attempting to ping database...")
+// It returns an error if the connection is not initialized or the database is unreachable.
+func (pg *PostgresDB) Ping(ctx context.Context) error {
+	slog.DebugContext(ctx, "Attempting to ping database...")
 	if pg.gorm == nil {
-		slog.Error("Database connection (gorm.DB) is nil, cannot ping.")
-		return
+		slog.ErrorContext(ctx, "Database connection (gorm.DB) is nil, cannot ping.")
+		return errors.New("database connection is not initialized")
 	}
 	sqlDB, err := pg.gorm.DB()
 	if err != nil {
-		slog.Error("Failed to get underlying *sql.DB instance for ping", "error", err)
-		return
+		slog.ErrorContext(ctx, "Failed to get underlying *sql.DB instance for ping", "error", err)
+		return fmt.Errorf("failed to obtain underlying sql.DB: %w", err)
 	}
-	// Use a background context for the ping as it's a standalone check.
-	err = sqlDB.PingContext(context.Background())
-	if err != nil {
-		slog.Error("Failed to ping database", "error", err)
-	} else {
-		slog.Info("Database ping successful.")
+	if err := sqlDB.PingContext(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to ping database", "error", err)
+		return fmt.Errorf("database ping failed: %w", err)
 	}
+	slog.DebugContext(ctx, "Database ping successful.")
+	return nil
 }
 
 // Shutdown gracefully closes the connection to the PostgreSQL database.
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/testutil/sqlfake"
+	"bitback/internal/testutil/testdb"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSyncHostNameUniqueIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		enforce bool
+		want    string
+	}{
+		{name: "enforced", enforce: true,
+			want: "CREATE UNIQUE INDEX IF NOT EXISTS " + models.HostNameUniqueIndex + " ON hosts (lower(host_name)) WHERE host_name <> '' AND deleted_at IS NULL"},
+		{name: "not enforced", enforce: false, want: "DROP INDEX IF EXISTS " + models.HostNameUniqueIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake, err := sqlfake.Open(nil)
+			if err != nil {
+				t.Fatalf("sqlfake.Open() error = %v", err)
+			}
+			if err := syncHostNameUniqueIndex(db, tt.enforce); err != nil {
+				t.Fatalf("syncHostNameUniqueIndex() error = %v", err)
+			}
+			if got := fake.SQL(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("statements = %q, want [%q]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackfillHostAddressFamilies(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        [][]driver.Value
+		wantUpdates map[int64]string // Host ID to the family it is classified as.
+	}{
+		{name: "nothing to classify", wantUpdates: map[int64]string{}},
+		{
+			name:        "hosts are classified by address",
+			rows:        [][]driver.Value{{int64(1), "203.0.113.7"}, {int64(2), "2001:db8::1"}, {int64(3), "vpn.example.com"}},
+			wantUpdates: map[int64]string{1: "ipv4", 2: "ipv6", 3: "hostname"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake, err := sqlfake.Open(func(stmt sqlfake.Statement) sqlfake.Result {
+				if strings.HasPrefix(stmt.SQL, "SELECT") {
+					return sqlfake.Result{Columns: []string{"id", "address"}, Rows: tt.rows}
+				}
+				return sqlfake.Result{RowsAffected: 1}
+			})
+			if err != nil {
+				t.Fatalf("sqlfake.Open() error = %v", err)
+			}
+			if err := backfillHostAddressFamilies(db); err != nil {
+				t.Fatalf("backfillHostAddressFamilies() error = %v", err)
+			}
+
+			queries := fake.Queries()
+			if len(queries) == 0 || !strings.Contains(queries[0].SQL, "address_family IS NULL OR address_family = ''") || strings.Contains(queries[0].SQL, "deleted_at") {
+				t.Fatalf("queries = %v, want unclassified hosts selected including soft-deleted ones", queries)
+			}
+			got := map[int64]string{}
+			for _, query := range queries[1:] {
+				if !strings.HasPrefix(query.SQL, `UPDATE "hosts" SET "address_family"=$1`) || strings.Contains(query.SQL, "updated_at") {
+					t.Errorf("query %q does not set only the address family", query.SQL)
+					continue
+				}
+				got[query.Args[1].(int64)] = fmt.Sprint(query.Args[0])
+			}
+			if !reflect.DeepEqual(got, tt.wantUpdates) {
+				t.Errorf("classified families = %v, want %v", got, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+// subscriptionWithRenewalLinks is the subscription model with the renewal link columns it used to have.
+type subscriptionWithRenewalLinks struct {
+	models.Subscription
+	RenewedFromID *uuid.UUID `gorm:"type:uuid;index"`
+	RenewedToID   *uuid.UUID `gorm:"type:uuid;index"`
+}
+
+func (subscriptionWithRenewalLinks) TableName() string { return "subscriptions" }
+
+func TestDropSubscriptionRenewalLinks(t *testing.T) {
+	db := testdb.Open(t)
+	// Adds the columns as they were migrated before renewals extended subscriptions in place.
+	if err := db.AutoMigrate(&subscriptionWithRenewalLinks{}); err != nil {
+		t.Fatalf("failed to add renewal link columns: %v", err)
+	}
+	if !db.Migrator().HasIndex(&models.Subscription{}, "idx_subscriptions_renewed_to_id") {
+		t.Fatal("renewal link columns were not indexed")
+	}
+
+	// The second run finds nothing left to drop.
+	for range 2 {
+		if err := dropSubscriptionRenewalLinks(db); err != nil {
+			t.Fatalf("dropSubscriptionRenewalLinks() error = %v", err)
+		}
+	}
+	for _, column := range []string{"renewed_from_id", "renewed_to_id"} {
+		if db.Migrator().HasColumn(&models.Subscription{}, column) {
+			t.Errorf("column %s still exists", column)
+		}
+	}
+	if !db.Migrator().HasColumn(&models.Subscription{}, "last_renewed_at") {
+		t.Errorf("column last_renewed_at was dropped")
+	}
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// txContextKey is the context key under which the active transaction is stored.
+type txContextKey struct{}
+
+// ContextWithTx returns a copy of ctx carrying the given transaction.
+// Repositories that receive the returned context run their queries on tx.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction stored in ctx, or nil if ctx is not part of a transaction.
+func TxFromContext(ctx context.Context) *gorm.DB {
+	tx, _ := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/testutil/testdb"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// insertUser creates a user named name on the transaction carried by ctx.
+func insertUser(ctx context.Context, id uuid.UUID, name string) error {
+	tx := TxFromContext(ctx)
+	if tx == nil {
+		return errors.New("context carries no transaction")
+	}
+	return tx.Create(&models.User{ID: id, Name: name, Email: name + "@example.com"}).Error
+}
+
+// storedUserNames returns the names of the users in db, in alphabetical order.
+func storedUserNames(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var names []string
+	if err := db.Model(&models.User{}).Order("name").Pluck("name", &names).Error; err != nil {
+		t.Fatalf("failed to read users: %v", err)
+	}
+	return names
+}
+
+func TestWithTx(t *testing.T) {
+	fnErr := errors.New("subscription rejected")
+
+	tests := []struct {
+		name       string
+		fn         func(ctx context.Context, db *PostgresDB) error
+		wantErr    error // Compared with errors.Is; nil means success.
+		wantAnyErr bool  // The error comes from the database and is only required to be non-nil.
+		wantUsers  []string
+	}{
+		{
+			name:      "committed on success",
+			fn:        func(ctx context.Context, _ *PostgresDB) error { return insertUser(ctx, uuid.New(), "alice") },
+			wantUsers: []string{"alice"},
+		},
+		{
+			name: "rolled back when fn fails",
+			fn: func(ctx context.Context, _ *PostgresDB) error {
+				if err := insertUser(ctx, uuid.New(), "alice"); err != nil {
+					return err
+				}
+				return fnErr
+			},
+			wantErr: fnErr,
+		},
+		{
+			name: "rolled back when a statement fails",
+			fn: func(ctx context.Context, _ *PostgresDB) error {
+				if err := insertUser(ctx, uuid.New(), "alice"); err != nil {
+					return err
+				}
+				return TxFromContext(ctx).Exec("UPDATE missing_table SET name = 'bob'").Error
+			},
+			wantAnyErr: true,
+		},
+		{
+			name: "nested call joins the outer transaction",
+			fn: func(ctx context.Context, db *PostgresDB) error {
+				if err := insertUser(ctx, uuid.New(), "alice"); err != nil {
+					return err
+				}
+				outer := TxFromContext(ctx)
+				return db.WithTx(ctx, func(ctx context.Context) error {
+					if TxFromContext(ctx) != outer {
+						return errors.New("nested call started a new transaction")
+					}
+					return insertUser(ctx, uuid.New(), "bob")
+				})
+			},
+			wantUsers: []string{"alice", "bob"},
+		},
+		{
+			name: "nested failure rolls back the outer transaction",
+			fn: func(ctx context.Context, db *PostgresDB) error {
+				if err := insertUser(ctx, uuid.New(), "alice"); err != nil {
+					return err
+				}
+				return db.WithTx(ctx, func(ctx context.Context) error {
+					if err := insertUser(ctx, uuid.New(), "bob"); err != nil {
+						return err
+					}
+					return fnErr
+				})
+			},
+			wantErr: fnErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB := testdb.Open(t)
+			db := &PostgresDB{gorm: gormDB}
+
+			ctx := context.Background()
+			err := db.WithTx(ctx, func(ctx context.Context) error { return tt.fn(ctx, db) })
+			switch {
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("WithTx() error = nil, want the failed statement's error")
+				}
+			case !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil):
+				t.Fatalf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := storedUserNames(t, gormDB); !slices.Equal(got, tt.wantUsers) {
+				t.Errorf("stored users = %q, want %q", got, tt.wantUsers)
+			}
+			if TxFromContext(ctx) != nil {
+				t.Errorf("the caller's context carries a transaction after WithTx returned")
+			}
+		})
+	}
+}
+
+func TestWithTxPanic(t *testing.T) {
+	gormDB := testdb.Open(t)
+	db := &PostgresDB{gorm: gormDB}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("WithTx() swallowed the panic")
+			}
+		}()
+		_ = db.WithTx(context.Background(), func(ctx context.Context) error {
+			if err := insertUser(ctx, uuid.New(), "alice"); err != nil {
+				t.Errorf("insertUser() error = %v", err)
+			}
+			panic("boom")
+		})
+	}()
+	if got := storedUserNames(t, gormDB); len(got) != 0 {
+		t.Errorf("stored users = %q, want none after the panic rolled back the transaction", got)
+	}
+}
@@ -0,0 +1,22 @@
+package events
+
+import (
+	"bitback/internal/interfaces"
+	"context"
+	"log/slog"
+)
+
+// logPublisher is an in-process interfaces.EventPublisher that records every event in the application log.
+type logPublisher struct{}
+
+// NewLogPublisher creates an EventPublisher that logs events via slog.
+// It is the default publisher until events are forwarded to a message broker.
+func NewLogPublisher() interfaces.EventPublisher {
+	return &logPublisher{}
+}
+
+// Publish logs the event name and payload. It never fails.
+func (p *logPublisher) Publish(ctx context.Context, event interfaces.Event) error {
+	slog.InfoContext(ctx, "Domain event published", "event", event.EventName(), "payload", event)
+	return nil
+}
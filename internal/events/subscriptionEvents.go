@@ -0,0 +1,73 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Names under which subscription lifecycle events are published.
+const (
+	SubscriptionCreatedName   = "subscription.created"
+	SubscriptionCancelledName = "subscription.cancelled"
+	SubscriptionRenewedName   = "subscription.renewed"
+	PaymentStatusChangedName  = "subscription.payment_status_changed"
+)
+
+// SubscriptionCreated is published after a new subscription has been persisted.
+type SubscriptionCreated struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	PlanID         *uint     `json:"plan_id,omitempty"`
+	PlanName       string    `json:"plan_name"`
+	StartDate      time.Time `json:"start_date"`
+	EndDate        time.Time `json:"end_date"`
+	Price          float64   `json:"price"`
+	Currency       string    `json:"currency,omitempty"`
+	PaymentStatus  string    `json:"payment_status"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// EventName implements interfaces.Event.
+func (SubscriptionCreated) EventName() string { return SubscriptionCreatedName }
+
+// SubscriptionRenewed is published after an auto-renewing subscription has been extended by another period.
+// The extension awaits payment, so payment systems pick up renewals from this event.
+type SubscriptionRenewed struct {
+	SubscriptionID  uuid.UUID `json:"subscription_id"`
+	UserID          uuid.UUID `json:"user_id"`
+	PreviousEndDate time.Time `json:"previous_end_date"`
+	EndDate         time.Time `json:"end_date"`
+	Price           float64   `json:"price"`
+	Currency        string    `json:"currency,omitempty"`
+	PaymentStatus   string    `json:"payment_status"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+// EventName implements interfaces.Event.
+func (SubscriptionRenewed) EventName() string { return SubscriptionRenewedName }
+
+// SubscriptionCancelled is published after auto-renewal of a subscription has been disabled by a cancellation.
+type SubscriptionCancelled struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	CancelledBy    uuid.UUID `json:"cancelled_by"` // The user who requested the cancellation.
+	EndDate        time.Time `json:"end_date"`     // The subscription remains usable until this date.
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// EventName implements interfaces.Event.
+func (SubscriptionCancelled) EventName() string { return SubscriptionCancelledName }
+
+// PaymentStatusChanged is published after the payment status of a subscription has changed.
+type PaymentStatusChanged struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	OldStatus      string    `json:"old_status"`
+	NewStatus      string    `json:"new_status"`
+	IsActive       bool      `json:"is_active"` // Whether the subscription is active after the change.
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// EventName implements interfaces.Event.
+func (PaymentStatusChanged) EventName() string { return PaymentStatusChangedName }
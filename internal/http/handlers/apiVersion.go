@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// apiVersionHeader is the header through which clients select the schema version of create and update payloads.
+	apiVersionHeader = "X-API-Version"
+	// latestAPIVersion is the current payload schema version, assumed when a request does not select one.
+	latestAPIVersion = 2
+)
+
+// payloadConverter decodes a request body written against an older schema version and converts it into the
+// current request DTO. It receives the request to check the payload against path parameters.
+type payloadConverter[T any] func(r *http.Request, body io.Reader) (T, error)
+
+// requestAPIVersion returns the payload schema version selected by the X-API-Version header, which may be
+// given as "2" or "v2", or the latest version if the header is absent.
+func requestAPIVersion(r *http.Request) (int, error) {
+	raw := strings.TrimSpace(r.Header.Get(apiVersionHeader))
+	if raw == "" {
+		return latestAPIVersion, nil
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(raw), "v"))
+	if err != nil || version < 1 || version > latestAPIVersion {
+		return 0, fmt.Errorf("unsupported %s %q (supported versions are 1 to %d)", apiVersionHeader, raw, latestAPIVersion)
+	}
+	return version, nil
+}
+
+// decodeVersionedPayload decodes the request body into the current request DTO T according to the version
+// selected by the X-API-Version header. Bodies of older versions are decoded by the converter registered for
+// their version; versions without a converter share the current schema.
+func decodeVersionedPayload[T any](r *http.Request, converters map[int]payloadConverter[T]) (T, error) {
+	var req T
+	version, err := requestAPIVersion(r)
+	if err != nil {
+		return req, err
+	}
+	if convert, ok := converters[version]; ok {
+		return convert(r, r.Body)
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	return req, err
+}
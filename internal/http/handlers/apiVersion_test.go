@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestAPIVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		wantErr bool
+	}{
+		{header: "", want: latestAPIVersion},
+		{header: "1", want: 1},
+		{header: "2", want: 2},
+		{header: "v1", want: 1},
+		{header: " V2 ", want: 2},
+		{header: "3", wantErr: true},
+		{header: "0", wantErr: true},
+		{header: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(apiVersionHeader, tt.header)
+			}
+			got, err := requestAPIVersion(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("requestAPIVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("requestAPIVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeVersionedPayload(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+	converters := map[int]payloadConverter[payload]{
+		1: func(_ *http.Request, body io.Reader) (payload, error) {
+			raw, err := io.ReadAll(body)
+			return payload{Name: "converted " + string(raw)}, err
+		},
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		body    string
+		want    payload
+		wantErr bool
+	}{
+		{name: "latest by default", body: `{"name":"a"}`, want: payload{Name: "a"}},
+		{name: "current version", version: "2", body: `{"name":"a"}`, want: payload{Name: "a"}},
+		{name: "older version through its converter", version: "1", body: `old`, want: payload{Name: "converted old"}},
+		{name: "unsupported version", version: "3", body: `{"name":"a"}`, wantErr: true},
+		{name: "malformed body", version: "2", body: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.version != "" {
+				req.Header.Set(apiVersionHeader, tt.version)
+			}
+			got, err := decodeVersionedPayload(req, converters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeVersionedPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("decodeVersionedPayload() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionForUserAPIVersion(t *testing.T) {
+	userID := uuid.New()
+	const fields = `"plan_name":"Premium","duration_unit":"month","duration_value":1,"start_date":"2026-05-01T00:00:00Z","price":9.99,"currency":"USD"`
+	wantInput := serviceDTO.CreateSubscriptionInput{
+		UserID:        userID,
+		PlanName:      "Premium",
+		DurationUnit:  customTypes.UnitMonth,
+		DurationValue: 1,
+		StartDate:     time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC),
+		Price:         ptrTo(9.99),
+		Currency:      ptrTo("USD"),
+		ActorUserID:   &userID,
+		ActorRole:     customTypes.RoleUser,
+	}
+
+	tests := []struct {
+		name       string
+		version    string
+		body       string
+		wantStatus int
+	}{
+		{name: "v2 payload", version: "2", body: `{` + fields + `}`, wantStatus: http.StatusCreated},
+		{name: "v2 payload without the header", body: `{` + fields + `}`, wantStatus: http.StatusCreated},
+		{name: "v1 payload", version: "1", body: `{"user_id":"` + userID.String() + `",` + fields + `}`, wantStatus: http.StatusCreated},
+		{name: "v1 payload for another user", version: "v1", body: `{"user_id":"` + uuid.NewString() + `",` + fields + `}`, wantStatus: http.StatusBadRequest},
+		{name: "v1 payload with an invalid user_id", version: "1", body: `{"user_id":"nope",` + fields + `}`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported version", version: "3", body: `{` + fields + `}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotInput *serviceDTO.CreateSubscriptionInput
+			svc := &fakeSubscriptionService{
+				createSubscription: func(_ context.Context, input serviceDTO.CreateSubscriptionInput) (*models.Subscription, bool, error) {
+					gotInput = &input
+					return &models.Subscription{UserID: input.UserID, PlanName: input.PlanName}, true, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodPost, "/v1/users/"+userID.String()+"/subscriptions", strings.NewReader(tt.body)), userID, customTypes.RoleUser)
+			if tt.version != "" {
+				req.Header.Set(apiVersionHeader, tt.version)
+			}
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if gotInput != nil {
+					t.Errorf("service was called with %+v for a rejected payload", *gotInput)
+				}
+				return
+			}
+			if gotInput == nil {
+				t.Fatal("service was not called")
+			}
+			if !reflect.DeepEqual(*gotInput, wantInput) {
+				t.Errorf("service input = %+v, want %+v", *gotInput, wantInput)
+			}
+		})
+	}
+}
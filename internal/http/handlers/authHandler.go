@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models/customTypes"
+	"log/slog"
+	"net/http"
+)
+
+// userScopes are the scopes granted to every authenticated user. They cover the user's own resources.
+var userScopes = []string{
+	"users:self",
+	"subscriptions:self",
+	"keys:self",
+}
+
+// adminScopes are the scopes granted to administrators in addition to userScopes.
+// They correspond to the routes guarded by requireAdmin.
+var adminScopes = []string{
+	"users:admin",
+	"subscriptions:admin",
+	"hosts:admin",
+	"plans:admin",
+	"promo-codes:admin",
+	"keys:admin",
+	"reports:read",
+}
+
+// AuthHandler handles HTTP requests about the authenticated principal.
+type AuthHandler struct{}
+
+// NewAuthHandler creates a new instance of AuthHandler.
+func NewAuthHandler() *AuthHandler {
+	return &AuthHandler{}
+}
+
+// RegisterRoutes registers the HTTP routes for the AuthHandler.
+func (h *AuthHandler) RegisterRoutes(mux RouteRegistrar) {
+	mux.HandleFunc("GET /v1/auth/permissions", h.GetPermissions)
+}
+
+// GetPermissions handles the request to describe what the authenticated principal may do,
+// so that clients can hide actions that would be rejected.
+// Expected route: GET /api/v1/auth/permissions
+func (h *AuthHandler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "GetPermissions: unauthenticated request")
+		respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+		return
+	}
+	role := getRequestingUserRole(ctx)
+
+	respondWithJSON(w, http.StatusOK, dto.PermissionsResponse{
+		UserID:  userID.String(),
+		Role:    role,
+		IsAdmin: role.IsAdmin(),
+		Scopes:  scopesForRole(role),
+		// The gateway only forwards end-user identities via X-User-ID, so service principals and
+		// impersonation cannot occur yet; the fields keep the response shape stable for clients.
+		IsService:       false,
+		IsImpersonating: false,
+	})
+}
+
+// scopesForRole returns the scopes granted to the given role.
+func scopesForRole(role customTypes.UserRole) []string {
+	scopes := append([]string(nil), userScopes...)
+	if role.IsAdmin() {
+		scopes = append(scopes, adminScopes...)
+	}
+	return scopes
+}
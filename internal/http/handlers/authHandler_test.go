@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models/customTypes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetPermissions(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		role          customTypes.UserRole // Empty for anonymous callers.
+		wantStatus    int
+		wantAdmin     bool
+		wantScopes    []string
+		wantNotScopes []string
+	}{
+		{name: "user", role: customTypes.RoleUser, wantStatus: http.StatusOK, wantScopes: userScopes, wantNotScopes: adminScopes},
+		{name: "admin", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantAdmin: true, wantScopes: append(slices.Clone(userScopes), adminScopes...)},
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/auth/permissions", nil)
+			if tt.role != "" {
+				req = asPrincipal(req, userID, tt.role)
+			}
+
+			rec := serveRoutes(NewAuthHandler().RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got := decodeJSON[dto.PermissionsResponse](t, rec)
+			if got.UserID != userID.String() || got.Role != tt.role || got.IsAdmin != tt.wantAdmin {
+				t.Errorf("principal = (%s, %s, admin %v), want (%s, %s, admin %v)", got.UserID, got.Role, got.IsAdmin, userID, tt.role, tt.wantAdmin)
+			}
+			if !slices.Equal(got.Scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", got.Scopes, tt.wantScopes)
+			}
+			for _, scope := range tt.wantNotScopes {
+				if slices.Contains(got.Scopes, scope) {
+					t.Errorf("scopes %v include %q", got.Scopes, scope)
+				}
+			}
+			// Principals are always end users forwarded by the gateway.
+			if got.IsService || got.IsImpersonating {
+				t.Errorf("is_service = %v, is_impersonating = %v, want both false", got.IsService, got.IsImpersonating)
+			}
+		})
+	}
+}
+
+func TestScopesForRoleDoesNotShareBackingArray(t *testing.T) {
+	tests := []struct {
+		name string
+		role customTypes.UserRole
+	}{
+		{name: "user", role: customTypes.RoleUser},
+		{name: "admin", role: customTypes.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes := scopesForRole(tt.role)
+			scopes[0] = "tampered"
+			if userScopes[0] == "tampered" {
+				t.Fatal("modifying the returned scopes changed userScopes")
+			}
+		})
+	}
+}
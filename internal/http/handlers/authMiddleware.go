@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bitback/internal/httpctx"
+	"bitback/internal/interfaces"
+	"bitback/internal/models/customTypes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Headers of the gateway authentication contract. The gateway that authenticated the end user passes their ID in
+// X-User-ID, the current Unix time in seconds in X-Auth-Timestamp, and in X-Auth-Signature the hex-encoded
+// HMAC-SHA256 of "<user ID>.<timestamp>" under AUTH_HEADER_SECRET. The three headers must be stripped from
+// client requests by whatever sits in front of the service.
+const (
+	userIDHeader        = "X-User-ID"
+	authTimestampHeader = "X-Auth-Timestamp"
+	authSignatureHeader = "X-Auth-Signature"
+)
+
+// maxAuthSignatureAge bounds how far the signed timestamp may be from the current time, limiting replays.
+const maxAuthSignatureAge = 5 * time.Minute
+
+// AuthMiddleware resolves the requesting user from the request and stores them in the context as the httpctx.Principal.
+type AuthMiddleware struct {
+	userService   interfaces.UserService
+	signingSecret string
+}
+
+// NewAuthMiddleware creates a new instance of AuthMiddleware.
+// It takes a UserService as a dependency to resolve the role from the user record, and the secret the gateway
+// signs the user ID with. If the secret is empty, every request is treated as unauthenticated.
+func NewAuthMiddleware(us interfaces.UserService, signingSecret string) *AuthMiddleware {
+	return &AuthMiddleware{
+		userService:   us,
+		signingSecret: signingSecret,
+	}
+}
+
+// Authenticate is an HTTP middleware that reads the authenticated user's ID from the X-User-ID header,
+// verifies the gateway's signature over it, loads the user record to resolve their role, and injects both into
+// the request context. Requests without the header are passed through unauthenticated; handlers decide whether
+// that is acceptable. A user ID with a missing, stale or wrong signature is rejected with 401.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		userIDStr := strings.TrimSpace(r.Header.Get(userIDHeader))
+		if userIDStr == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if m.signingSecret == "" {
+			slog.WarnContext(ctx, "Authenticate: ignoring user ID header because AUTH_HEADER_SECRET is not set", "header", userIDHeader)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			slog.WarnContext(ctx, "Authenticate: invalid user ID format in header", "header", userIDHeader, "userID_str", userIDStr, "error", err)
+			respondWithError(w, http.StatusUnauthorized, "Invalid authentication credentials.")
+			return
+		}
+		if err := verifyAuthSignature(userIDStr, r.Header.Get(authTimestampHeader), r.Header.Get(authSignatureHeader), m.signingSecret, time.Now()); err != nil {
+			slog.WarnContext(ctx, "Authenticate: rejected user ID header", "userID", userID, "remoteAddr", r.RemoteAddr, "error", err)
+			respondWithError(w, http.StatusUnauthorized, "Invalid authentication credentials.")
+			return
+		}
+
+		user, err := m.userService.GetUser(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.WarnContext(ctx, "Authenticate: authenticated user not found", "userID", userID)
+				respondWithError(w, http.StatusUnauthorized, "Invalid authentication credentials.")
+				return
+			}
+			slog.ErrorContext(ctx, "Authenticate: failed to resolve authenticated user", "userID", userID, "error", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to authenticate request.")
+			return
+		}
+
+		ctx = httpctx.WithPrincipal(ctx, httpctx.Principal{UserID: user.ID, Role: user.Role})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifyAuthSignature checks that signature is the hex-encoded HMAC-SHA256 of "<userID>.<timestamp>" under secret
+// and that timestamp, in Unix seconds, is within maxAuthSignatureAge of now. The comparison takes constant time.
+func verifyAuthSignature(userID, timestamp, signature, secret string, now time.Time) error {
+	timestamp = strings.TrimSpace(timestamp)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or malformed auth timestamp")
+	}
+	if age := now.Sub(time.Unix(signedAt, 0)); age > maxAuthSignatureAge || age < -maxAuthSignatureAge {
+		return errors.New("auth timestamp is outside the accepted window")
+	}
+	got, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(got) != sha256.Size {
+		return errors.New("missing or malformed auth signature")
+	}
+	if !hmac.Equal(got, signAuthHeader(userID, timestamp, secret)) {
+		return errors.New("auth signature does not match")
+	}
+	return nil
+}
+
+// signAuthHeader returns the HMAC-SHA256 of "<userID>.<timestamp>" under secret, as the gateway computes it.
+func signAuthHeader(userID, timestamp, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID + "." + timestamp))
+	return mac.Sum(nil)
+}
+
+// requireAdmin wraps a handler so that it is only executed for authenticated users with the admin role.
+// It responds with 401 if the request is unauthenticated and 403 if the user is not an administrator.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestingUserID, err := getRequestingUserID(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "requireAdmin: unauthenticated request to admin route", "path", r.URL.Path)
+			respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+		role := getRequestingUserRole(ctx)
+		if !role.IsAdmin() {
+			slog.WarnContext(ctx, "requireAdmin: user is not an administrator", "userID", requestingUserID, "role", role, "path", r.URL.Path)
+			respondWithError(w, http.StatusForbidden, "Administrator role required.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAuthenticated wraps a handler so that it is only executed for authenticated users.
+// It responds with 401 if the request is unauthenticated; ownership checks are left to the handler or service.
+func requireAuthenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if _, err := getRequestingUserID(ctx); err != nil {
+			slog.WarnContext(ctx, "requireAuthenticated: unauthenticated request", "path", r.URL.Path)
+			respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireSelfOrAdmin wraps a handler for a route with a {userID} path parameter so that it is only executed for
+// that user or an administrator. It responds with 401 if the request is unauthenticated and 403 otherwise.
+// A malformed userID is passed through so the handler can report it.
+func requireSelfOrAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestingUserID, err := getRequestingUserID(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "requireSelfOrAdmin: unauthenticated request", "path", r.URL.Path)
+			respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+		targetUserID, err := uuid.Parse(r.PathValue("userID"))
+		if err == nil && targetUserID != requestingUserID && !isAdminRequest(ctx) {
+			slog.WarnContext(ctx, "requireSelfOrAdmin: user may not access another user", "userID", requestingUserID, "targetUserID", targetUserID, "path", r.URL.Path)
+			respondWithError(w, http.StatusForbidden, "Not allowed to access this user.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// getRequestingUserRole extracts the authenticated user's role from the request context.
+// It defaults to the least privileged role if no role is present.
+func getRequestingUserRole(ctx context.Context) customTypes.UserRole {
+	if role, ok := httpctx.Role(ctx); ok {
+		return role
+	}
+	return customTypes.RoleUser
+}
+
+// isAdminRequest reports whether the request was made by an authenticated administrator.
+func isAdminRequest(ctx context.Context) bool {
+	role := getRequestingUserRole(ctx)
+	return role.IsAdmin()
+}
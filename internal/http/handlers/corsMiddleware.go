@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// corsAnyOrigin in the allowed origins whitelists every origin.
+	corsAnyOrigin = "*"
+	// corsPreflightMaxAge is how long, in seconds, browsers may cache the result of a preflight request.
+	corsPreflightMaxAge = 600
+)
+
+// corsExposedHeaders are the response headers that cross-origin scripts are allowed to read.
+var corsExposedHeaders = []string{requestIDHeader, "Retry-After", "Content-Disposition", "ETag"}
+
+// CORS returns a middleware that allows browsers on the given origins to call the API.
+// The Origin header of whitelisted origins is echoed in Access-Control-Allow-Origin, and preflight
+// OPTIONS requests are answered with 204 and the allowed methods and headers without reaching the router.
+// Preflight requests from other origins get 403, while their other requests are served without CORS
+// headers, so the browser withholds the response. An empty allowedOrigins disables CORS entirely.
+func CORS(allowedOrigins, allowedMethods, allowedHeaders []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(allowedOrigins) == 0 {
+			return next
+		}
+
+		origins := make(map[string]struct{}, len(allowedOrigins))
+		for _, origin := range allowedOrigins {
+			origins[strings.ToLower(origin)] = struct{}{}
+		}
+		_, anyOrigin := origins[corsAnyOrigin]
+		methods := strings.Join(allowedMethods, ", ")
+		headers := strings.Join(allowedHeaders, ", ")
+		exposedHeaders := strings.Join(corsExposedHeaders, ", ")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			_, allowed := origins[strings.ToLower(origin)]
+			allowed = allowed || anyOrigin
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !allowed {
+				if preflight {
+					slog.WarnContext(r.Context(), "CORS: rejecting preflight request from disallowed origin", "origin", origin, "path", r.URL.Path)
+					respondWithError(w, http.StatusForbidden, "Origin is not allowed.")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if !preflight {
+				w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsPreflightMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	const adminOrigin = "https://admin.example.com"
+	methods := []string{"GET", "POST", "PATCH"}
+	headers := []string{"Authorization", "Content-Type"}
+
+	tests := []struct {
+		name        string
+		origins     []string
+		method      string
+		origin      string
+		preflight   bool // Whether the request carries Access-Control-Request-Method.
+		wantStatus  int
+		wantCalled  bool
+		wantAllowed string // Expected Access-Control-Allow-Origin; empty if none.
+	}{
+		{name: "preflight from allowed origin", origins: []string{adminOrigin}, method: http.MethodOptions, origin: adminOrigin, preflight: true,
+			wantStatus: http.StatusNoContent, wantAllowed: adminOrigin},
+		{name: "origin matched case-insensitively", origins: []string{adminOrigin}, method: http.MethodOptions, origin: "https://Admin.Example.com", preflight: true,
+			wantStatus: http.StatusNoContent, wantAllowed: "https://Admin.Example.com"},
+		{name: "preflight from disallowed origin", origins: []string{adminOrigin}, method: http.MethodOptions, origin: "https://evil.example.com", preflight: true,
+			wantStatus: http.StatusForbidden},
+		{name: "request from allowed origin", origins: []string{adminOrigin}, method: http.MethodGet, origin: adminOrigin,
+			wantStatus: http.StatusOK, wantCalled: true, wantAllowed: adminOrigin},
+		{name: "request from disallowed origin", origins: []string{adminOrigin}, method: http.MethodGet, origin: "https://evil.example.com",
+			wantStatus: http.StatusOK, wantCalled: true},
+		{name: "any origin", origins: []string{corsAnyOrigin}, method: http.MethodOptions, origin: "https://other.example.com", preflight: true,
+			wantStatus: http.StatusNoContent, wantAllowed: "https://other.example.com"},
+		{name: "same-origin request", origins: []string{adminOrigin}, method: http.MethodGet,
+			wantStatus: http.StatusOK, wantCalled: true},
+		{name: "OPTIONS without preflight header", origins: []string{adminOrigin}, method: http.MethodOptions, origin: adminOrigin,
+			wantStatus: http.StatusOK, wantCalled: true, wantAllowed: adminOrigin},
+		{name: "disabled", method: http.MethodOptions, origin: adminOrigin, preflight: true,
+			wantStatus: http.StatusOK, wantCalled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(tt.method, "/v1/hosts", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			}
+
+			rec := httptest.NewRecorder()
+			CORS(tt.origins, methods, headers)(next).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("next handler called = %v, want %v", called, tt.wantCalled)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowed)
+			}
+
+			wantPreflightHeaders := tt.preflight && tt.wantAllowed != ""
+			preflightHeaders := map[string]string{
+				"Access-Control-Allow-Methods": "GET, POST, PATCH",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			}
+			for name, want := range preflightHeaders {
+				if !wantPreflightHeaders {
+					want = ""
+				}
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if exposed := rec.Header().Get("Access-Control-Expose-Headers"); (exposed != "") != (tt.wantAllowed != "" && !tt.preflight) {
+				t.Errorf("Access-Control-Expose-Headers = %q, want it only on allowed non-preflight responses", exposed)
+			}
+			// Responses that depend on the origin must not be shared by caches across origins.
+			if varies := slices.Contains(rec.Header().Values("Vary"), "Origin"); varies != (tt.origin != "" && tt.origins != nil) {
+				t.Errorf("Vary = %v, want Origin only when CORS applies", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// cursorQueryParam is the query parameter that switches list endpoints to keyset pagination.
+// An empty value requests the first page.
+const cursorQueryParam = "cursor"
+
+// errInvalidCursor is returned when a client-supplied cursor cannot be decoded.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor builds the opaque cursor for the list item with the given creation time and ID.
+// Clients pass it back unchanged to fetch the page after that item.
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor extracts the creation time and ID from a cursor built by encodeCursor.
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errInvalidCursor
+	}
+	createdAtStr, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", errInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return time.Time{}, "", errInvalidCursor
+	}
+	return createdAt, id, nil
+}
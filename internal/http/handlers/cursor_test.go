@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		id        string
+	}{
+		{name: "host ID", createdAt: time.Date(2026, time.March, 4, 10, 30, 0, 123456789, time.UTC), id: "42"},
+		{name: "user UUID", createdAt: time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC), id: "3f0c1a9e-2b7d-4e6f-9a1b-5c8d7e6f4a3b"},
+		{name: "non-UTC time", createdAt: time.Date(2026, time.July, 1, 12, 0, 0, 500, time.FixedZone("CEST", 2*60*60)), id: "7"},
+		{name: "ID containing the separator", createdAt: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), id: "a|b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createdAt, id, err := decodeCursor(encodeCursor(tt.createdAt, tt.id))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !createdAt.Equal(tt.createdAt) || id != tt.id {
+				t.Errorf("decodeCursor() = (%v, %q), want (%v, %q)", createdAt, id, tt.createdAt, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("2026-03-04T10:30:00Z|42"))},
+		{name: "no separator", cursor: encode("2026-03-04T10:30:00Z")},
+		{name: "empty ID", cursor: encode("2026-03-04T10:30:00Z|")},
+		{name: "invalid time", cursor: encode("yesterday|42")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want %v", tt.cursor, err, errInvalidCursor)
+			}
+		})
+	}
+}
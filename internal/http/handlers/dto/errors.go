@@ -0,0 +1,10 @@
+package dto
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Code    string `json:"code"`    // Machine-readable error code, e.g. "not_found".
+	Message string `json:"message"` // Human-readable description of the error.
+	Error   string `json:"error"`   // Same as Message; kept for clients that read the legacy field.
+
+	RequestID string `json:"request_id,omitempty"` // ID of the failed request, to be quoted when reporting the error.
+}
@@ -11,8 +11,8 @@ type CreateHostRequest struct {
 	Country      string `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"` // Optional: ISO 3166-1 alpha-2 country code.
 	City         string `json:"city,omitempty"`                                          // Optional: City where the host is located.
 	Address      string `json:"address" validate:"required"`                             // Mandatory: IP address or domain name of the host.
-	Port         string `json:"port" validate:"required,numeric"`                        // Mandatory: Port number for the host service.
-	Protocol     string `json:"protocol" validate:"required"`                            // Mandatory: Protocol (e.g., http, https, tcp).
+	Port         string `json:"port" validate:"required,numeric"`                        // Mandatory: Port number for the host service (1-65535).
+	Protocol     string `json:"protocol" validate:"required"`                            // Mandatory: Protocol (e.g., vless, vmess, trojan).
 	Network      string `json:"network,omitempty" validate:"omitempty"`                  // Optional: Network type (e.g., tcp, ws, grpc); can have a default in the database or service.
 	PublicKey    string `json:"public_key,omitempty" validate:"omitempty"`               // Optional: Public key, used for certain security types like Reality.
 	Flow         string `json:"flow,omitempty"`                                          // Optional: Flow control mechanism.
@@ -21,8 +21,11 @@ type CreateHostRequest struct {
 	SNI          string `json:"sni,omitempty"`                                           // Optional: Server Name Indication for TLS.
 	Fingerprint  string `json:"fingerprint,omitempty"`                                   // Optional: TLS fingerprint.
 	IsPrivate    bool   `json:"is_private,omitempty"`                                    // Optional: Specifies if the host is private; defaults to false if omitted.
+	IsFreeTier   bool   `json:"is_free_tier,omitempty"`                                  // Optional: Specifies if the host serves free-tier keys; defaults to false (paid) if omitted.
+	MaxUsers     int64  `json:"max_users,omitempty" validate:"omitempty,gte=0"`          // Optional: Capacity in active key assignments; 0 or omitted if unknown or unlimited.
 	Region       string `json:"region,omitempty"`                                        // Optional: Geographical or logical region of the host.
 	Provider     string `json:"provider,omitempty"`                                      // Optional: Provider or owner of the host infrastructure.
+	Notes        string `json:"notes,omitempty"`                                         // Optional: Operator notes or runbook for the host.
 }
 
 // UpdateHostRequest defines the request body for updating an existing host.
@@ -42,8 +45,11 @@ type UpdateHostRequest struct {
 	SNI          *string `json:"sni,omitempty"`
 	Fingerprint  *string `json:"fingerprint,omitempty"`
 	IsPrivate    *bool   `json:"is_private,omitempty"`
+	IsFreeTier   *bool   `json:"is_free_tier,omitempty"`                         // Moves the host between the free and paid tiers.
+	MaxUsers     *int64  `json:"max_users,omitempty" validate:"omitempty,gte=0"` // Capacity in active key assignments; 0 clears it.
 	Region       *string `json:"region,omitempty"`
 	Provider     *string `json:"provider,omitempty"`
+	Notes        *string `json:"notes,omitempty"`
 }
 
 // UpdateHostStatusRequest defines the request body for updating a host's online status.
@@ -52,6 +58,11 @@ type UpdateHostStatusRequest struct {
 	Status   customTypes.HostStatus `json:"status" validate:"required"` // The new detailed status of the host; must be a valid HostStatus.
 }
 
+// SetHostTierRequest defines the request body for moving a host between the free and paid tiers.
+type SetHostTierRequest struct {
+	IsFreeTier *bool `json:"is_free_tier" validate:"required"` // True for the free tier, false for the paid tier.
+}
+
 // HostResponse defines the standard API response for a single host.
 type HostResponse struct {
 	ID            uint                   `json:"id"`
@@ -59,6 +70,7 @@ type HostResponse struct {
 	Country       string                 `json:"country,omitempty"`
 	City          string                 `json:"city,omitempty"`
 	Address       string                 `json:"address"`
+	AddressFamily string                 `json:"address_family,omitempty"` // Family of the address: ipv4, ipv6 or hostname.
 	Port          string                 `json:"port"`
 	Protocol      string                 `json:"protocol"`
 	Network       string                 `json:"network,omitempty"` // Network type.
@@ -69,20 +81,169 @@ type HostResponse struct {
 	SNI           string                 `json:"sni,omitempty"`
 	Fingerprint   string                 `json:"fingerprint,omitempty"`
 	IsPrivate     bool                   `json:"is_private"`
+	IsFreeTier    bool                   `json:"is_free_tier"` // Whether the host serves free-tier keys; paid otherwise.
+	MaxUsers      int64                  `json:"max_users"`    // Capacity in active key assignments; 0 if unknown or unlimited.
 	IsOnline      bool                   `json:"is_online"`
 	Status        customTypes.HostStatus `json:"status"` // HostStatus will be serialized to its string representation.
 	LastCheckedAt *time.Time             `json:"last_checked_at,omitempty"`
+	LatencyMs     *int                   `json:"latency_ms,omitempty"` // Latency measured by the last check; omitted if unknown or the host was unreachable.
 	Region        string                 `json:"region,omitempty"`
 	Provider      string                 `json:"provider,omitempty"`
+	Notes         string                 `json:"notes,omitempty"` // Operator notes; only populated for administrators.
 	CreatedAt     time.Time              `json:"created_at"`
 	UpdatedAt     time.Time              `json:"updated_at"`
+	DeletedAt     *time.Time             `json:"deleted_at,omitempty"` // Set only for soft-deleted hosts, which are listed with include_deleted.
+}
+
+// RecordHostCheckRequest defines the request body for recording a host health check result.
+type RecordHostCheckRequest struct {
+	IsOnline  bool                    `json:"is_online"`                                       // Whether the host was reachable.
+	Status    *customTypes.HostStatus `json:"status,omitempty"`                                // Optional: New detailed status of the host.
+	LatencyMs *int                    `json:"latency_ms,omitempty" validate:"omitempty,gte=0"` // Optional: Measured latency in milliseconds.
+	Error     string                  `json:"error,omitempty"`                                 // Optional: Error reported by the check.
+	CheckedAt *time.Time              `json:"checked_at,omitempty"`                            // Optional: When the check was performed; defaults to now.
+}
+
+// HostCheckResponse defines the API response for a single host health check.
+type HostCheckResponse struct {
+	ID        uint      `json:"id"`
+	HostID    uint      `json:"host_id"`
+	CheckedAt time.Time `json:"checked_at"`
+	WasOnline bool      `json:"was_online"`
+	LatencyMs *int      `json:"latency_ms,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// HostUptimeResponse defines the API response for a host's uptime over a time window.
+type HostUptimeResponse struct {
+	HostID       uint      `json:"host_id"`
+	Window       string    `json:"window"`        // The requested window (e.g., "24h0m0s").
+	From         time.Time `json:"from"`          // Start of the window.
+	To           time.Time `json:"to"`            // End of the window.
+	TotalChecks  int64     `json:"total_checks"`  // Number of checks recorded in the window.
+	OnlineChecks int64     `json:"online_checks"` // Number of checks in which the host was online.
+	UptimeRatio  *float64  `json:"uptime_ratio"`  // OnlineChecks divided by TotalChecks; null when no checks were recorded.
+}
+
+// HostUtilizationResponse defines the API response for a host's load and current health.
+type HostUtilizationResponse struct {
+	HostID             uint                   `json:"host_id"`
+	CurrentUsers       int64                  `json:"current_users"`       // Active key assignments on the host.
+	MaxUsers           int64                  `json:"max_users"`           // Capacity of the host; 0 if unknown or unlimited.
+	UtilizationPercent *float64               `json:"utilization_percent"` // CurrentUsers as a percentage of MaxUsers; null when MaxUsers is 0.
+	IsOnline           bool                   `json:"is_online"`
+	Status             customTypes.HostStatus `json:"status"`
+	LatencyMs          *int                   `json:"latency_ms,omitempty"` // Latency measured by the last check; omitted if unknown.
+	LastCheckedAt      *time.Time             `json:"last_checked_at,omitempty"`
+}
+
+// BulkHostResultResponse describes the outcome of one entry of a bulk host import.
+type BulkHostResultResponse struct {
+	Index  int    `json:"index"`             // Position of the entry in the submitted array.
+	Status string `json:"status"`            // "created", "duplicate", "invalid" or "rejected".
+	HostID *uint  `json:"host_id,omitempty"` // ID of the created host; present only when created.
+	Error  string `json:"error,omitempty"`   // Reason the entry was skipped.
+}
+
+// BulkCreateHostsResponse defines the API response for a bulk host import.
+type BulkCreateHostsResponse struct {
+	Results    []BulkHostResultResponse `json:"results"`    // Per-entry outcomes, in submission order.
+	Total      int                      `json:"total"`      // Number of submitted entries.
+	Created    int                      `json:"created"`    // Number of hosts created.
+	Duplicates int                      `json:"duplicates"` // Number of entries skipped as duplicates.
+	Invalid    int                      `json:"invalid"`    // Number of entries skipped as invalid.
+	Atomic     bool                     `json:"atomic"`     // Whether the import was all-or-nothing.
+	Committed  bool                     `json:"committed"`  // Whether the valid entries were created; false if an atomic import was rejected.
+}
+
+// HostBundle is a full snapshot of the host configuration, as exported for backup and accepted for restore.
+type HostBundle struct {
+	Version    int               `json:"version"`     // Format version of the bundle.
+	ExportedAt time.Time         `json:"exported_at"` // When the bundle was exported.
+	Hosts      []HostBundleEntry `json:"hosts"`       // The exported hosts, ordered by ID.
+}
+
+// HostBundleEntry holds the configuration of one host in a HostBundle, including sensitive fields.
+type HostBundleEntry struct {
+	ID           uint                   `json:"id,omitempty"` // ID of the exported host; informational only and ignored on import.
+	HostName     string                 `json:"host_name,omitempty"`
+	Country      string                 `json:"country,omitempty"`
+	City         string                 `json:"city,omitempty"`
+	Region       string                 `json:"region,omitempty"`
+	Provider     string                 `json:"provider,omitempty"`
+	Address      string                 `json:"address"`
+	Port         string                 `json:"port"`
+	Protocol     string                 `json:"protocol"`
+	Network      string                 `json:"network,omitempty"`
+	PublicKey    string                 `json:"public_key,omitempty"`
+	Flow         string                 `json:"flow,omitempty"`
+	RSID         string                 `json:"rsid,omitempty"`
+	SecurityType string                 `json:"security_type,omitempty"`
+	SNI          string                 `json:"sni,omitempty"`
+	Fingerprint  string                 `json:"fingerprint,omitempty"`
+	IsPrivate    bool                   `json:"is_private"`
+	IsFreeTier   bool                   `json:"is_free_tier"`
+	MaxUsers     int64                  `json:"max_users,omitempty"`
+	Status       customTypes.HostStatus `json:"status,omitempty"` // Applied only when the host is created by an import.
+	Notes        string                 `json:"notes,omitempty"`
+}
+
+// ImportHostResultResponse describes the outcome of one entry of a host bundle import.
+type ImportHostResultResponse struct {
+	Index  int    `json:"index"`             // Position of the entry in the bundle.
+	Status string `json:"status"`            // "created", "updated", "unchanged" or "invalid".
+	HostID *uint  `json:"host_id,omitempty"` // ID of the created or matched host; absent for invalid entries.
+	Error  string `json:"error,omitempty"`   // Reason the entry is invalid.
+}
+
+// ImportHostsResponse defines the API response for a host bundle import.
+type ImportHostsResponse struct {
+	Results   []ImportHostResultResponse `json:"results"`   // Per-entry outcomes, in bundle order.
+	Total     int                        `json:"total"`     // Number of entries in the bundle.
+	Created   int                        `json:"created"`   // Number of hosts created.
+	Updated   int                        `json:"updated"`   // Number of existing hosts updated.
+	Unchanged int                        `json:"unchanged"` // Number of existing hosts already matching the bundle.
+	Invalid   int                        `json:"invalid"`   // Number of invalid entries.
+	Committed bool                       `json:"committed"` // Whether the bundle was applied; false if any entry was invalid.
+}
+
+// ProviderHostCountsResponse DTO for the host counts of a single provider.
+type ProviderHostCountsResponse struct {
+	Provider string `json:"provider"` // Provider name; empty for hosts without a provider.
+	Total    int64  `json:"total"`    // Number of hosts of the provider.
+	Online   int64  `json:"online"`   // Hosts currently online.
+	Active   int64  `json:"active"`   // Hosts with the 'active' status.
+}
+
+// CountryAvailabilityResponse DTO for the number of available hosts in a single country.
+type CountryAvailabilityResponse struct {
+	Country   string `json:"country"`    // Empty for hosts without a country.
+	FreeCount int64  `json:"free_count"` // Online, active free-tier hosts.
+	PaidCount int64  `json:"paid_count"` // Online, active paid-tier hosts.
+}
+
+// CountryHostAvailabilityResponse DTO for the public hosts available for key generation in a single country.
+type CountryHostAvailabilityResponse struct {
+	Country         string `json:"country"`           // Empty for hosts without a country.
+	CityCount       int64  `json:"city_count"`        // Distinct cities with an available host.
+	OnlineHostCount int64  `json:"online_host_count"` // Available hosts.
+	HasFreeTier     bool   `json:"has_free_tier"`     // Whether any available host is in the free tier.
+}
+
+// HostAvailabilityResponse DTO for the list of countries with available hosts.
+type HostAvailabilityResponse struct {
+	Countries []CountryHostAvailabilityResponse `json:"countries"` // Ordered by country.
+}
+
+// HostAvailabilityReportResponse DTO for the report of available hosts per country.
+type HostAvailabilityReportResponse struct {
+	Countries []CountryAvailabilityResponse `json:"countries"` // Ordered by country.
+	TotalFree int64                         `json:"total_free"`
+	TotalPaid int64                         `json:"total_paid"`
 }
 
-// PaginatedHostsResponse defines the structure for a paginated list of hosts.
-type PaginatedHostsResponse struct {
-	Hosts       []HostResponse `json:"hosts"`        // Slice of host responses for the current page.
-	TotalItems  int64          `json:"total_items"`  // Total number of hosts matching the query.
-	TotalPages  int            `json:"total_pages"`  // Total number of pages available.
-	CurrentPage int            `json:"current_page"` // The current page number.
-	PageSize    int            `json:"page_size"`    // The number of items per page.
+// ProvidersReportResponse DTO for the report of host counts per provider.
+type ProvidersReportResponse struct {
+	Country   string                       `json:"country,omitempty"` // Country the report is restricted to, if any.
+	Providers []ProviderHostCountsResponse `json:"providers"`         // Host counts per provider, largest first.
 }
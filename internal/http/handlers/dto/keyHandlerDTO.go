@@ -1,9 +1,132 @@
 package dto
 
+import "time"
+
 // VlessKeyResponse defines the structure of the JSON response for a VLESS key.
 type VlessKeyResponse struct {
-	VlessKey              string `json:"vless_key"`                         // The generated VLESS key string.
-	UserID                string `json:"user_id,omitempty"`                 // The ID of the user for whom the key was generated.
-	Remarks               string `json:"remarks,omitempty"`                 // Optional remarks or a name for the key.
-	HasActiveSubscription *bool  `json:"has_active_subscription,omitempty"` // Indicates if the user has an active subscription. Pointer to omit if not applicable.
+	VlessKey              string           `json:"vless_key"`                         // The generated VLESS key string.
+	UserID                string           `json:"user_id,omitempty"`                 // The ID of the user for whom the key was generated.
+	Remarks               string           `json:"remarks,omitempty"`                 // Optional remarks or a name for the key.
+	HasActiveSubscription *bool            `json:"has_active_subscription,omitempty"` // Indicates if the user has an active subscription. Pointer to omit if not applicable.
+	Host                  *KeyHostResponse `json:"host,omitempty"`                    // Attributes of the host the key was issued on. Omitted for re-sent keys.
+	ServedTier            string           `json:"served_tier,omitempty"`             // Tier of the host the key was issued on: "paid" or "free". Omitted for re-sent keys.
+	Degraded              bool             `json:"degraded,omitempty"`                // True if a subscribed user was served a free host because no paid host was available.
+	ExpiresAt             *time.Time       `json:"expires_at,omitempty"`              // End of the user's active subscription, or the end of the configured TTL for free keys.
+}
+
+// TrojanKeyResponse defines the structure of the JSON response for a trojan key.
+type TrojanKeyResponse struct {
+	TrojanKey             string           `json:"trojan_key"` // The generated trojan key string.
+	UserID                string           `json:"user_id"`
+	Remarks               string           `json:"remarks,omitempty"`
+	HasActiveSubscription bool             `json:"has_active_subscription"`
+	Host                  *KeyHostResponse `json:"host"`                 // Attributes of the host the key was issued on.
+	ServedTier            string           `json:"served_tier"`          // Tier of the host the key was issued on: "paid" or "free".
+	Degraded              bool             `json:"degraded,omitempty"`   // True if a subscribed user was served a free host because no paid host was available.
+	ExpiresAt             *time.Time       `json:"expires_at,omitempty"` // End of the user's active subscription.
+}
+
+// CountryKeyResponse defines one key of a MultiCountryKeysResponse.
+type CountryKeyResponse struct {
+	VlessKey string          `json:"vless_key"` // The generated VLESS key string.
+	Host     KeyHostResponse `json:"host"`      // Attributes of the host the key was issued on.
+}
+
+// MultiCountryKeysResponse defines the JSON response for the keys generated for a user across countries.
+type MultiCountryKeysResponse struct {
+	UserID                string               `json:"user_id"`
+	Keys                  []CountryKeyResponse `json:"keys"`              // One key per country, ordered by country.
+	Count                 int                  `json:"count"`             // Number of keys.
+	Remarks               string               `json:"remarks,omitempty"` // Remarks embedded in every key.
+	HasActiveSubscription bool                 `json:"has_active_subscription"`
+	ExpiresAt             *time.Time           `json:"expires_at,omitempty"` // End of the user's active subscription.
+}
+
+// KeyGenerationBucketResponse defines the number of keys generated in one bucket of a KeyGenerationRateResponse.
+type KeyGenerationBucketResponse struct {
+	Start    time.Time `json:"start"`     // Start of the bucket.
+	FreeKeys int64     `json:"free_keys"` // Anonymous free keys.
+	UserKeys int64     `json:"user_keys"` // Keys generated for a user.
+}
+
+// ConversionFunnelResponse defines the API response for the free-to-paid conversion funnel report.
+type ConversionFunnelResponse struct {
+	From           time.Time `json:"from"`            // Start of the period (inclusive).
+	To             time.Time `json:"to"`              // End of the period (exclusive).
+	FreeKeyUsers   int64     `json:"free_key_users"`  // Users issued a free-tier key within the period.
+	Subscribed     int64     `json:"subscribed"`      // Of those, users who created a subscription after their first free-tier key.
+	Paid           int64     `json:"paid"`            // Of those, users with such a subscription that is paid.
+	ConversionRate float64   `json:"conversion_rate"` // Paid divided by free_key_users.
+}
+
+// KeyGenerationRateResponse defines the API response for the key generation rate report.
+type KeyGenerationRateResponse struct {
+	Window    string                        `json:"window"` // The requested window (e.g., "1h0m0s").
+	Bucket    string                        `json:"bucket"` // Width of each bucket (e.g., "5m0s").
+	From      time.Time                     `json:"from"`   // Start of the window.
+	To        time.Time                     `json:"to"`     // End of the window.
+	Buckets   []KeyGenerationBucketResponse `json:"buckets"`
+	TotalFree int64                         `json:"total_free"`
+	TotalUser int64                         `json:"total_user"`
+}
+
+// KeyHostResponse defines the attributes of the host a key was issued on, which may differ from the requested
+// ones when no host matched them.
+type KeyHostResponse struct {
+	Country       string `json:"country"`        // Country of the host.
+	Network       string `json:"network"`        // Transport type (e.g., tcp, ws, grpc).
+	SecurityType  string `json:"security_type"`  // Security type (e.g., none, tls, reality).
+	AddressFamily string `json:"address_family"` // Family of the host address: ipv4, ipv6 or hostname.
+}
+
+// VlessConfigResponse defines the structure of the JSON response for the decoded components of a VLESS key.
+type VlessConfigResponse struct {
+	Country  string `json:"country,omitempty"` // Country of the host.
+	Address  string `json:"address"`           // Host address (IP or domain).
+	Port     string `json:"port"`              // Host port.
+	UUID     string `json:"uuid"`              // VLESS user ID.
+	Network  string `json:"network"`           // Transport type (e.g., tcp, ws, grpc).
+	Security string `json:"security"`          // Security type (e.g., none, tls, reality).
+	SNI      string `json:"sni,omitempty"`     // Server Name Indication.
+	FP       string `json:"fp,omitempty"`      // TLS fingerprint.
+	PBK      string `json:"pbk,omitempty"`     // Reality public key.
+	SID      string `json:"sid,omitempty"`     // Reality short ID.
+	Flow     string `json:"flow,omitempty"`    // Flow control mechanism.
+	Remarks  string `json:"remarks,omitempty"` // Optional remarks or a name for the key.
+	VlessKey string `json:"vless_key"`         // The VLESS key string built from these components.
+
+	ServedTier string `json:"served_tier"`        // Tier of the host the key was issued on: "paid" or "free".
+	Degraded   bool   `json:"degraded,omitempty"` // True if a subscribed user was served a free host because no paid host was available.
+}
+
+// ReassignHostRequest defines the request body for moving a user's key to another host.
+// All fields are optional; without host_id a host of the user's tier is selected automatically.
+type ReassignHostRequest struct {
+	HostID  *uint   `json:"host_id,omitempty"` // Optional: Host to move the user to.
+	Country *string `json:"country,omitempty"` // Optional: Country for automatic selection; defaults to the current host's country.
+	Remarks *string `json:"remarks,omitempty"` // Optional: Remarks for the new key; defaults to the current key's remarks.
+}
+
+// ReassignHostResponse defines the JSON response after moving a user's key to another host.
+type ReassignHostResponse struct {
+	VlessKey       string `json:"vless_key"`         // The newly issued VLESS key.
+	UserID         string `json:"user_id"`           // The ID of the reassigned user.
+	Remarks        string `json:"remarks,omitempty"` // Remarks embedded in the new key.
+	HostID         uint   `json:"host_id"`           // The host the user was moved to.
+	PreviousHostID uint   `json:"previous_host_id"`  // The host the user was moved off.
+	RevokedCount   int    `json:"revoked_count"`     // Number of active key assignments that were revoked.
+}
+
+// FreeKeyBatchResponse defines the JSON response for a batch of generated free keys.
+type FreeKeyBatchResponse struct {
+	Keys    []FreeKeyResponse `json:"keys"`              // The generated keys.
+	Count   int               `json:"count"`             // Number of generated keys.
+	Hosts   int               `json:"hosts"`             // Number of distinct hosts the keys were issued on.
+	Remarks string            `json:"remarks,omitempty"` // Remarks embedded in every key.
+}
+
+// FreeKeyResponse defines one key in a FreeKeyBatchResponse.
+type FreeKeyResponse struct {
+	VlessKey string `json:"vless_key"` // The generated VLESS key string.
+	HostID   uint   `json:"host_id"`   // The free-tier host the key was issued on.
 }
@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"time"
+)
+
+// OnboardRequest defines the request body for creating a user together with their first subscription.
+type OnboardRequest struct {
+	User         CreateUserRequest          `json:"user"`
+	Subscription OnboardSubscriptionRequest `json:"subscription"`
+}
+
+// OnboardSubscriptionRequest defines the first subscription of an onboarded user.
+// The subscription is to a catalog plan, whose price the user pays after onboarding; it awaits payment until then.
+type OnboardSubscriptionRequest struct {
+	PlanID    *uint     `json:"plan_id" validate:"required"` // Catalog plan to subscribe to; its name, duration and price are copied into the subscription.
+	PlanName  string    `json:"plan_name,omitempty"`         // Optional: Must match the plan's name if given.
+	StartDate time.Time `json:"start_date" validate:"required"`
+	Currency  *string   `json:"currency,omitempty" validate:"omitempty,iso4217"`  // Optional: ISO 4217 currency code; the plan's or the default currency when omitted.
+	AutoRenew bool      `json:"auto_renew"`                                       // Flag for auto-renewal.
+	PromoCode *string   `json:"promo_code,omitempty" validate:"omitempty,max=64"` // Optional: Promo code whose discount is applied to the price.
+}
+
+// OnboardResponse defines the API response for an onboarded user.
+type OnboardResponse struct {
+	User         UserResponse         `json:"user"`
+	Subscription SubscriptionResponse `json:"subscription"`
+}
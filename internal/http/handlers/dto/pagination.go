@@ -0,0 +1,134 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Pagination holds the metadata shared by all paginated list responses.
+type Pagination struct {
+	TotalItems  int64 `json:"total_items"`         // Total number of items matching the query.
+	TotalPages  int   `json:"total_pages"`         // Total number of pages available.
+	CurrentPage int   `json:"current_page"`        // The current page number.
+	PageSize    int   `json:"page_size"`           // The number of items per page.
+	NextPage    *int  `json:"next_page,omitempty"` // The next page number; omitted on the last page.
+	PrevPage    *int  `json:"prev_page,omitempty"` // The previous page number; omitted on the first page.
+	HasNext     bool  `json:"has_next"`            // Whether a page follows the current one.
+	HasPrevious bool  `json:"has_previous"`        // Whether a page precedes the current one.
+}
+
+// NewPagination computes the pagination metadata for the given page of a result set.
+// For a page past the end, PrevPage points at the last page. HasNext and HasPrevious mirror whether
+// NextPage and PrevPage are set.
+func NewPagination(page, pageSize int, totalItems int64) Pagination {
+	totalPages := 0
+	if totalItems > 0 && pageSize > 0 {
+		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	}
+
+	pagination := Pagination{
+		TotalItems:  totalItems,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		PageSize:    pageSize,
+	}
+	if page < totalPages {
+		next := page + 1
+		pagination.NextPage = &next
+		pagination.HasNext = true
+	}
+	if page > 1 && totalPages > 0 {
+		prev := min(page-1, totalPages)
+		pagination.PrevPage = &prev
+		pagination.HasPrevious = true
+	}
+	return pagination
+}
+
+// Paginated is a page of list items together with its pagination metadata.
+// The items are serialized under ItemsKey (e.g., "users" or "hosts"), or "items" if it is empty,
+// followed by the pagination fields.
+type Paginated[T any] struct {
+	ItemsKey string
+	Items    []T
+	Pagination
+}
+
+// NewPaginated creates a Paginated response with the items serialized under itemsKey.
+func NewPaginated[T any](itemsKey string, items []T, pagination Pagination) Paginated[T] {
+	return Paginated[T]{
+		ItemsKey:   itemsKey,
+		Items:      items,
+		Pagination: pagination,
+	}
+}
+
+// MarshalJSON serializes the items under ItemsKey followed by the pagination fields.
+// Nil items are serialized as an empty array.
+func (p Paginated[T]) MarshalJSON() ([]byte, error) {
+	return marshalItemsEnvelope(p.ItemsKey, p.Items, p.Pagination)
+}
+
+// CursorPagination holds the metadata of a keyset-paginated list response.
+type CursorPagination struct {
+	PageSize   int    `json:"page_size"`             // The maximum number of items per page.
+	NextCursor string `json:"next_cursor,omitempty"` // Opaque cursor for the next page; omitted on the last page.
+}
+
+// CursorPaginated is a keyset-paginated page of list items together with its cursor metadata.
+// It is serialized like Paginated: the items under ItemsKey followed by the cursor fields.
+type CursorPaginated[T any] struct {
+	ItemsKey string
+	Items    []T
+	CursorPagination
+}
+
+// NewCursorPaginated creates a CursorPaginated response with the items serialized under itemsKey.
+func NewCursorPaginated[T any](itemsKey string, items []T, pagination CursorPagination) CursorPaginated[T] {
+	return CursorPaginated[T]{
+		ItemsKey:         itemsKey,
+		Items:            items,
+		CursorPagination: pagination,
+	}
+}
+
+// MarshalJSON serializes the items under ItemsKey followed by the cursor fields.
+// Nil items are serialized as an empty array.
+func (p CursorPaginated[T]) MarshalJSON() ([]byte, error) {
+	return marshalItemsEnvelope(p.ItemsKey, p.Items, p.CursorPagination)
+}
+
+// marshalItemsEnvelope serializes items under itemsKey, or "items" if it is empty, followed by the
+// fields of meta, which must serialize to a non-empty JSON object.
+func marshalItemsEnvelope[T any](itemsKey string, items []T, meta any) ([]byte, error) {
+	if itemsKey == "" {
+		itemsKey = "items"
+	}
+	if items == nil {
+		items = []T{}
+	}
+
+	keyJSON, err := json.Marshal(itemsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal items key: %w", err)
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal items: %w", err)
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pagination: %w", err)
+	}
+
+	// metaJSON is a non-empty object, so its opening brace is replaced by the items entry.
+	out := make([]byte, 0, len(keyJSON)+len(itemsJSON)+len(metaJSON)+2)
+	out = append(out, '{')
+	out = append(out, keyJSON...)
+	out = append(out, ':')
+	out = append(out, itemsJSON...)
+	out = append(out, ',')
+	out = append(out, metaJSON[1:]...)
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewPagination(t *testing.T) {
+	page := func(p int) *int { return &p }
+
+	tests := []struct {
+		name       string
+		page       int
+		pageSize   int
+		totalItems int64
+		want       Pagination
+	}{
+		{
+			name: "first page", page: 1, pageSize: 10, totalItems: 25,
+			want: Pagination{TotalItems: 25, TotalPages: 3, CurrentPage: 1, PageSize: 10, NextPage: page(2), HasNext: true},
+		},
+		{
+			name: "middle page", page: 2, pageSize: 10, totalItems: 25,
+			want: Pagination{TotalItems: 25, TotalPages: 3, CurrentPage: 2, PageSize: 10, NextPage: page(3), PrevPage: page(1), HasNext: true, HasPrevious: true},
+		},
+		{
+			name: "last page", page: 3, pageSize: 10, totalItems: 25,
+			want: Pagination{TotalItems: 25, TotalPages: 3, CurrentPage: 3, PageSize: 10, PrevPage: page(2), HasPrevious: true},
+		},
+		{
+			name: "only page", page: 1, pageSize: 10, totalItems: 10,
+			want: Pagination{TotalItems: 10, TotalPages: 1, CurrentPage: 1, PageSize: 10},
+		},
+		{
+			name: "no items", page: 1, pageSize: 10,
+			want: Pagination{CurrentPage: 1, PageSize: 10},
+		},
+		{
+			name: "past the end points back at the last page", page: 7, pageSize: 10, totalItems: 25,
+			want: Pagination{TotalItems: 25, TotalPages: 3, CurrentPage: 7, PageSize: 10, PrevPage: page(3), HasPrevious: true},
+		},
+		{
+			name: "past the end of an empty result", page: 2, pageSize: 10,
+			want: Pagination{CurrentPage: 2, PageSize: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPagination(tt.page, tt.pageSize, tt.totalItems)
+			if !equalPagination(got, tt.want) {
+				t.Errorf("NewPagination(%d, %d, %d) = %s, want %s", tt.page, tt.pageSize, tt.totalItems, describe(got), describe(tt.want))
+			}
+			if got.HasNext != (got.NextPage != nil) || got.HasPrevious != (got.PrevPage != nil) {
+				t.Errorf("flags do not mirror the page numbers: %s", describe(got))
+			}
+		})
+	}
+}
+
+func TestPaginatedMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		itemsKey string
+		items    []string
+		want     string
+	}{
+		{
+			name: "items under their key", itemsKey: "hosts", items: []string{"a", "b"},
+			want: `{"hosts":["a","b"],"total_items":3,"total_pages":2,"current_page":1,"page_size":2,"next_page":2,"has_next":true,"has_previous":false}`,
+		},
+		{
+			name: "default key and nil items",
+			want: `{"items":[],"total_items":3,"total_pages":2,"current_page":1,"page_size":2,"next_page":2,"has_next":true,"has_previous":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewPaginated(tt.itemsKey, tt.items, NewPagination(1, 2, 3)))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// equalPagination reports whether a and b hold the same metadata, comparing page numbers by value.
+func equalPagination(a, b Pagination) bool {
+	equalPage := func(x, y *int) bool { return (x == nil) == (y == nil) && (x == nil || *x == *y) }
+	return a.TotalItems == b.TotalItems && a.TotalPages == b.TotalPages && a.CurrentPage == b.CurrentPage &&
+		a.PageSize == b.PageSize && equalPage(a.NextPage, b.NextPage) && equalPage(a.PrevPage, b.PrevPage) &&
+		a.HasNext == b.HasNext && a.HasPrevious == b.HasPrevious
+}
+
+// describe renders pagination metadata with its page numbers dereferenced.
+func describe(p Pagination) string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"bitback/internal/models/customTypes"
+	"time"
+)
+
+// CreatePlanRequest defines the request body for creating a new subscription plan.
+type CreatePlanRequest struct {
+	Name          string                   `json:"name" validate:"required"`                        // Mandatory: Unique name of the plan.
+	Description   string                   `json:"description,omitempty"`                           // Optional: Description of the plan.
+	DurationUnit  customTypes.DurationUnit `json:"duration_unit" validate:"required"`               // Mandatory: Unit of the plan duration (day, month, year).
+	DurationValue int                      `json:"duration_value" validate:"required,gt=0"`         // Mandatory: Value of the plan duration.
+	Price         float64                  `json:"price" validate:"gte=0"`                          // Price of the plan.
+	Currency      string                   `json:"currency,omitempty" validate:"omitempty,iso4217"` // Optional: ISO 4217 currency code.
+	IsActive      *bool                    `json:"is_active,omitempty"`                             // Optional: Whether the plan is available for new subscriptions; defaults to true.
+	IsFreeTier    bool                     `json:"is_free_tier,omitempty"`                          // Optional: Whether the plan grants free tier access only.
+}
+
+// UpdatePlanRequest defines the request body for updating an existing plan.
+// Fields are pointers to allow partial updates; only provided fields are changed.
+type UpdatePlanRequest struct {
+	Name          *string                   `json:"name,omitempty"`
+	Description   *string                   `json:"description,omitempty"`
+	DurationUnit  *customTypes.DurationUnit `json:"duration_unit,omitempty"`
+	DurationValue *int                      `json:"duration_value,omitempty" validate:"omitempty,gt=0"`
+	Price         *float64                  `json:"price,omitempty" validate:"omitempty,gte=0"`
+	Currency      *string                   `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	IsActive      *bool                     `json:"is_active,omitempty"`
+	IsFreeTier    *bool                     `json:"is_free_tier,omitempty"`
+}
+
+// PlanResponse defines the standard API response for a single plan.
+type PlanResponse struct {
+	ID            uint                     `json:"id"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description,omitempty"`
+	DurationUnit  customTypes.DurationUnit `json:"duration_unit"`
+	DurationValue int                      `json:"duration_value"`
+	Price         float64                  `json:"price"`
+	Currency      string                   `json:"currency,omitempty"`
+	IsActive      bool                     `json:"is_active"`
+	IsFreeTier    bool                     `json:"is_free_tier"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+// CreatePromoCodeRequest defines the request body for creating a new promo code.
+// Exactly one of DiscountPercent and DiscountAmount must be provided.
+type CreatePromoCodeRequest struct {
+	Code            string     `json:"code" validate:"required,max=64"`                              // Mandatory: The code customers enter; case-insensitive.
+	DiscountPercent float64    `json:"discount_percent,omitempty" validate:"omitempty,gt=0,lte=100"` // Optional: Percentage taken off the price.
+	DiscountAmount  float64    `json:"discount_amount,omitempty" validate:"omitempty,gt=0"`          // Optional: Fixed amount taken off the price.
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`                                         // Optional: Start of the validity window.
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`                                        // Optional: End of the validity window.
+	MaxUses         *int       `json:"max_uses,omitempty" validate:"omitempty,gt=0"`                 // Optional: Maximum number of redemptions.
+	IsActive        *bool      `json:"is_active,omitempty"`                                          // Optional: Whether the code can be redeemed; defaults to true.
+}
+
+// PromoCodeResponse defines the API response for a promo code.
+type PromoCodeResponse struct {
+	ID              uint       `json:"id"`
+	Code            string     `json:"code"`
+	DiscountPercent float64    `json:"discount_percent,omitempty"`
+	DiscountAmount  float64    `json:"discount_amount,omitempty"`
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
+	MaxUses         *int       `json:"max_uses,omitempty"`
+	UsedCount       int        `json:"used_count"`
+	IsActive        bool       `json:"is_active"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
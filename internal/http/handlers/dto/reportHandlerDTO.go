@@ -0,0 +1,62 @@
+package dto
+
+import "time"
+
+// OverviewResponse defines the API response for the admin dashboard overview.
+type OverviewResponse struct {
+	GeneratedAt   time.Time                     `json:"generated_at"`
+	Users         OverviewUsersResponse         `json:"users"`
+	Subscriptions OverviewSubscriptionsResponse `json:"subscriptions"`
+	Hosts         OverviewHostsResponse         `json:"hosts"`
+	Keys          OverviewKeysResponse          `json:"keys"`
+}
+
+// OverviewUsersResponse DTO for the user counts of the overview.
+type OverviewUsersResponse struct {
+	Total                      int64 `json:"total"`
+	Active                     int64 `json:"active"`                        // Users whose account is active.
+	WithActivePaidSubscription int64 `json:"with_active_paid_subscription"` // Users with an active, paid subscription.
+	NewLast7Days               int64 `json:"new_last_7_days"`
+	NewLast30Days              int64 `json:"new_last_30_days"`
+}
+
+// OverviewSubscriptionsResponse DTO for the subscription counts of the overview.
+type OverviewSubscriptionsResponse struct {
+	ByPaymentStatus []PaymentStatusCountResponse `json:"by_payment_status"` // Ordered by payment status.
+	ActiveByPlan    []PlanCountResponse          `json:"active_by_plan"`    // Active subscriptions per plan, largest first.
+}
+
+// PaymentStatusCountResponse DTO for the number of subscriptions with one payment status.
+type PaymentStatusCountResponse struct {
+	PaymentStatus string `json:"payment_status"`
+	Count         int64  `json:"count"`
+}
+
+// PlanCountResponse DTO for the number of active subscriptions of one plan.
+type PlanCountResponse struct {
+	PlanName string `json:"plan_name"`
+	Count    int64  `json:"count"`
+}
+
+// OverviewHostsResponse DTO for the host counts of the overview.
+type OverviewHostsResponse struct {
+	ByStatus  []HostStatusCountResponse  `json:"by_status"`  // Ordered by status.
+	ByCountry []CountryHostCountResponse `json:"by_country"` // Largest first.
+}
+
+// HostStatusCountResponse DTO for the number of hosts with one status.
+type HostStatusCountResponse struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// CountryHostCountResponse DTO for the number of hosts in one country.
+type CountryHostCountResponse struct {
+	Country *string `json:"country"` // Country code; null for hosts without a country.
+	Count   int64   `json:"count"`
+}
+
+// OverviewKeysResponse DTO for the key generation counts of the overview.
+type OverviewKeysResponse struct {
+	GeneratedToday int64 `json:"generated_today"` // Keys generated since midnight UTC.
+}
@@ -2,24 +2,31 @@ package dto
 
 import (
 	"bitback/internal/models/customTypes"
+	"encoding/json"
 	"github.com/google/uuid"
 	"time"
 )
 
 // CreateSubscriptionRequest defines the request body for creating a new subscription.
 // The UserID in the path is used to identify the user for whom the subscription is created.
-// If UserID is also included in the request body, it should match the path parameter or be validated
-// to ensure the authenticated user has permission to create a subscription for the target UserID.
 type CreateSubscriptionRequest struct {
-	UserID        string                   `json:"user_id" validate:"required,uuid"` // UserID as a string; requires parsing and validation against path UserID.
-	PlanName      string                   `json:"plan_name" validate:"required"`
-	DurationUnit  customTypes.DurationUnit `json:"duration_unit" validate:"required"`
-	DurationValue int                      `json:"duration_value" validate:"required,gt=0"`
-	StartDate     time.Time                `json:"start_date" validate:"required"`                  // Consider adding validation to ensure the date is not in the past.
-	Price         *float64                 `json:"price,omitempty" validate:"omitempty,gte=0"`      // Optional: Price of the subscription.
-	Currency      *string                  `json:"currency,omitempty" validate:"omitempty,iso4217"` // Optional: ISO 4217 currency code.
-	PaymentStatus string                   `json:"payment_status" validate:"required"`              // E.g., "pending", "paid", "failed".
-	AutoRenew     bool                     `json:"auto_renew"`                                      // Flag for auto-renewal.
+	PlanID        *uint                    `json:"plan_id,omitempty"`                                                // Optional: Catalog plan to subscribe to; its name, duration and price are copied into the subscription.
+	PlanName      string                   `json:"plan_name" validate:"required_without=PlanID"`                     // Required unless plan_id is given; must match the plan's name if both are given.
+	DurationUnit  customTypes.DurationUnit `json:"duration_unit" validate:"required_without=PlanID"`                 // Ignored when plan_id is given.
+	DurationValue int                      `json:"duration_value" validate:"required_without=PlanID,omitempty,gt=0"` // Ignored when plan_id is given.
+	StartDate     time.Time                `json:"start_date" validate:"required"`                                   // Consider adding validation to ensure the date is not in the past.
+	Price         *float64                 `json:"price,omitempty" validate:"omitempty,gte=0"`                       // Optional: Price of the subscription.
+	Currency      *string                  `json:"currency,omitempty" validate:"omitempty,iso4217"`                  // Optional: ISO 4217 currency code; inferred from the country of the user's current host when omitted.
+	PaymentStatus string                   `json:"payment_status"`                                                   // E.g., "pending", "paid", "failed" but not "trial"; required from administrators, always "pending" for anyone else.
+	AutoRenew     bool                     `json:"auto_renew"`                                                       // Flag for auto-renewal.
+	PromoCode     *string                  `json:"promo_code,omitempty" validate:"omitempty,max=64"`                 // Optional: Promo code whose discount is applied to the price.
+}
+
+// CreateSubscriptionRequestV1 defines the version 1 request body for creating a new subscription,
+// which also named the user in the body. Version 2 dropped UserID in favor of the path parameter.
+type CreateSubscriptionRequestV1 struct {
+	UserID string `json:"user_id" validate:"required,uuid"` // Must match the user ID in the path.
+	CreateSubscriptionRequest
 }
 
 // UpdateSubscriptionPaymentRequest defines the request body for updating a subscription's payment status.
@@ -27,36 +34,133 @@ type UpdateSubscriptionPaymentRequest struct {
 	PaymentStatus string `json:"payment_status" validate:"required"` // The new payment status.
 }
 
+// PaymentWebhookRequest defines the provider-agnostic payload of a payment webhook.
+type PaymentWebhookRequest struct {
+	SubscriptionID    uuid.UUID `json:"subscription_id"`     // Subscription the payment is for.
+	Status            string    `json:"status"`              // Payment status; only "paid" events change anything.
+	Amount            float64   `json:"amount"`              // Amount paid.
+	Currency          string    `json:"currency"`            // ISO 4217 currency code of the amount.
+	ExternalPaymentID string    `json:"external_payment_id"` // Provider's ID of the payment; a payment is applied at most once.
+	Timestamp         time.Time `json:"timestamp"`           // When the provider recorded the payment.
+}
+
+// PaymentWebhookResponse defines the API response to a payment webhook.
+type PaymentWebhookResponse struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Result         string    `json:"result"` // "applied", "duplicate" (already applied) or "ignored" (not a "paid" event).
+}
+
 // SetSubscriptionAutoRenewRequest defines the request body for enabling or disabling auto-renewal for a subscription.
 type SetSubscriptionAutoRenewRequest struct {
 	AutoRenew bool `json:"auto_renew"` // The desired auto-renewal state.
 }
 
+// RecordUsageRequest defines the request body for reporting a usage increment for a metered subscription.
+type RecordUsageRequest struct {
+	Metric     string     `json:"metric" validate:"required,max=64"` // Name of the metered quantity (e.g., "traffic_gb").
+	Quantity   int64      `json:"quantity" validate:"required,gt=0"` // Amount to add to the period's total.
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`             // Optional: When the usage happened; defaults to now.
+}
+
+// SubscriptionUsageResponse defines the API response for the usage total of one metric in one billing period.
+type SubscriptionUsageResponse struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	Metric         string    `json:"metric"`
+	Quantity       int64     `json:"quantity"` // Total for the period, including the reported increment.
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// UsagePeriodResponse defines the API response for the usage of a subscription in one billing period.
+type UsagePeriodResponse struct {
+	PeriodStart time.Time        `json:"period_start"`
+	PeriodEnd   time.Time        `json:"period_end"`
+	Metrics     map[string]int64 `json:"metrics"` // Total quantity per metric.
+}
+
+// UsageReportResponse defines the API response summarizing a subscription's usage per billing period.
+type UsageReportResponse struct {
+	SubscriptionID uuid.UUID             `json:"subscription_id"`
+	Periods        []UsagePeriodResponse `json:"periods"` // Oldest period first; periods without usage are omitted.
+}
+
 // SubscriptionResponse defines the standard API response for a single subscription.
 type SubscriptionResponse struct {
-	ID            uuid.UUID                `json:"id"`
-	UserID        uuid.UUID                `json:"user_id"`
-	PlanName      string                   `json:"plan_name"`
-	DurationUnit  customTypes.DurationUnit `json:"duration_unit"`
-	DurationValue int                      `json:"duration_value"`
-	StartDate     time.Time                `json:"start_date"`
-	EndDate       time.Time                `json:"end_date"`
-	IsActive      bool                     `json:"is_active"`
-	Price         *float64                 `json:"price,omitempty"`
-	Currency      *string                  `json:"currency,omitempty"`
-	PaymentStatus string                   `json:"payment_status"`
-	AutoRenew     bool                     `json:"auto_renew"`
-	CreatedAt     time.Time                `json:"created_at"`
-	UpdatedAt     time.Time                `json:"updated_at"`
-}
-
-// PaginatedSubscriptionsResponse defines the structure for a paginated list of subscriptions.
-type PaginatedSubscriptionsResponse struct {
-	Subscriptions []SubscriptionResponse `json:"subscriptions"` // Slice of subscription responses for the current page.
-	TotalItems    int64                  `json:"total_items"`   // Total number of subscriptions matching the query.
-	TotalPages    int                    `json:"total_pages"`   // Total number of pages available.
-	CurrentPage   int                    `json:"current_page"`  // The current page number.
-	PageSize      int                    `json:"page_size"`     // The number of items per page.
+	ID                   uuid.UUID                `json:"id"`
+	UserID               uuid.UUID                `json:"user_id"`
+	PlanID               *uint                    `json:"plan_id,omitempty"`
+	PromoCodeID          *uint                    `json:"promo_code_id,omitempty"`
+	PlanName             string                   `json:"plan_name"`
+	DurationUnit         customTypes.DurationUnit `json:"duration_unit"`
+	DurationValue        int                      `json:"duration_value"`
+	StartDate            time.Time                `json:"start_date"`
+	EndDate              time.Time                `json:"end_date"`
+	IsActive             bool                     `json:"is_active"`
+	Price                *float64                 `json:"price,omitempty"`
+	Currency             *string                  `json:"currency,omitempty"`
+	PaymentStatus        string                   `json:"payment_status"`
+	ExternalPaymentID    *string                  `json:"external_payment_id,omitempty"` // Payment provider's ID of the payment that paid for the subscription.
+	AutoRenew            bool                     `json:"auto_renew"`
+	LastRenewedAt        *time.Time               `json:"last_renewed_at,omitempty"`
+	LastExpiryNotifiedAt *time.Time               `json:"last_expiry_notified_at,omitempty"` // When the user was last reminded that the subscription expires.
+	CreatedAt            time.Time                `json:"created_at"`
+	UpdatedAt            time.Time                `json:"updated_at"`
+	Invalid              bool                     `json:"invalid,omitempty"` // Set for legacy subscriptions whose stored duration unit is not a known one.
+}
+
+// SubscriptionEventResponse defines the API response for one entry of a subscription's history.
+type SubscriptionEventResponse struct {
+	ID          uint                              `json:"id"`
+	EventType   customTypes.SubscriptionEventType `json:"event_type"`
+	OldValue    json.RawMessage                   `json:"old_value,omitempty"`     // The changed fields before the change.
+	NewValue    json.RawMessage                   `json:"new_value,omitempty"`     // The changed fields after the change.
+	ActorUserID *uuid.UUID                        `json:"actor_user_id,omitempty"` // Omitted for changes made by system jobs.
+	CreatedAt   time.Time                         `json:"created_at"`
+}
+
+// RenewalRunResponse DTO for the summary of a manually triggered auto-renewal run.
+type RenewalRunResponse struct {
+	WindowStart time.Time `json:"window_start"` // Subscriptions ending between window_start and window_end were considered.
+	WindowEnd   time.Time `json:"window_end"`
+	Candidates  int       `json:"candidates"`  // Subscriptions found that were due for renewal.
+	Renewed     int       `json:"renewed"`     // Subscriptions renewed by this run.
+	Skipped     int       `json:"skipped"`     // Candidates already renewed by another run or that failed to renew.
+	Deactivated int       `json:"deactivated"` // Renewals deactivated because they were not paid within the grace period.
+}
+
+// ChurnReportResponse DTO for the subscription churn report over a period.
+type ChurnReportResponse struct {
+	From          time.Time `json:"from"`            // Start of the period (inclusive).
+	To            time.Time `json:"to"`              // End of the period (exclusive).
+	ActiveAtStart int64     `json:"active_at_start"` // Paid subscriptions active at the start of the period.
+	Renewed       int64     `json:"renewed"`         // Subscriptions that ended within the period and were renewed.
+	Expired       int64     `json:"expired"`         // Subscriptions that ended with auto-renewal enabled but were not renewed.
+	Cancelled     int64     `json:"cancelled"`       // Subscriptions that ended after auto-renewal was disabled.
+	Churned       int64     `json:"churned"`         // Expired plus cancelled subscriptions.
+	ChurnRate     float64   `json:"churn_rate"`      // Churned divided by active_at_start.
+}
+
+// SubscriptionsByCurrencyResponse DTO for the report of subscriptions and revenue per currency over a period.
+type SubscriptionsByCurrencyResponse struct {
+	From          time.Time                `json:"from"`          // Start of the period (inclusive).
+	To            time.Time                `json:"to"`            // End of the period (exclusive).
+	Subscriptions int64                    `json:"subscriptions"` // Subscriptions created within the period, in any currency.
+	Currencies    []CurrencyTotalsResponse `json:"currencies"`    // Totals per currency, ordered by currency code.
+}
+
+// CurrencyTotalsResponse DTO for the subscription totals of one currency.
+type CurrencyTotalsResponse struct {
+	Currency      *string `json:"currency"`      // Currency code; null for subscriptions without a currency.
+	Subscriptions int64   `json:"subscriptions"` // Subscriptions created within the period in this currency.
+	Paid          int64   `json:"paid"`          // Of those, subscriptions that were paid.
+	Revenue       float64 `json:"revenue"`       // Sum of the prices of the paid subscriptions.
+}
+
+// SubscriptionStatusResponse DTO telling whether a user currently has an active subscription.
+type SubscriptionStatusResponse struct {
+	HasActiveSubscription bool     `json:"has_active_subscription"`
+	ActivePlans           []string `json:"active_plans"` // Distinct plan names of the active subscriptions; empty if none.
 }
 
 // ExpiringSubscriptionItemResponse DTO for an item in the list of expiring subscriptions within a report.
@@ -75,12 +179,3 @@ type UserWithExpiringSubscriptionsResponse struct {
 	User                  UserResponse                       `json:"user"`                   // User details, using the existing UserResponse DTO.
 	ExpiringSubscriptions []ExpiringSubscriptionItemResponse `json:"expiring_subscriptions"` // List of the user's expiring subscriptions.
 }
-
-// PaginatedUserExpiringSubscriptionsResponse DTO for a paginated report of users and their expiring subscriptions.
-type PaginatedUserExpiringSubscriptionsResponse struct {
-	Data        []UserWithExpiringSubscriptionsResponse `json:"data"`         // The list of users with their expiring subscriptions for the current page.
-	TotalItems  int64                                   `json:"total_items"`  // Total number of expiring *subscriptions* across all users (or users, depending on report definition).
-	CurrentPage int                                     `json:"current_page"` // The current page number of the report.
-	PageSize    int                                     `json:"page_size"`    // The number of items (users with subscriptions) per page.
-	TotalPages  int                                     `json:"total_pages"`  // Total number of pages in the report.
-}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"bitback/internal/models/customTypes"
 	"github.com/google/uuid"
 	"time"
 )
@@ -22,6 +23,11 @@ type UpdateUserRequest struct {
 	IsActive   *bool   `json:"is_active,omitempty"`                               // New active status for the user.
 }
 
+// UpdateUserRoleRequest defines the request body for changing a user's role.
+type UpdateUserRoleRequest struct {
+	Role customTypes.UserRole `json:"role" validate:"required"` // The new role; must be a valid UserRole (e.g., "user", "admin").
+}
+
 // UserResponse defines the standard API response for a single user's details.
 type UserResponse struct {
 	ID         uuid.UUID  `json:"id"`
@@ -29,17 +35,25 @@ type UserResponse struct {
 	Email      string     `json:"email,omitempty"`
 	TelegramID int64      `json:"telegram_id,omitempty"`
 	IsActive   bool       `json:"is_active"`
-	Role       string     `json:"role,omitempty"`       // Optional: User's role within the system.
+	Role       string     `json:"role,omitempty"`       // User's role within the system; only shown to administrators and the user themself.
 	LastLogin  *time.Time `json:"last_login,omitempty"` // Optional: Timestamp of the user's last login.
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set only for soft-deleted users, which are listed with include_deleted.
+}
+
+// CreateUserResponse defines the API response for a newly registered user.
+type CreateUserResponse struct {
+	UserResponse
+	TrialSubscription *SubscriptionResponse `json:"trial_subscription,omitempty"` // The trial created on registration, if any.
 }
 
-// PaginatedUsersResponse defines the structure for a paginated list of users.
-type PaginatedUsersResponse struct {
-	Users       []UserResponse `json:"users"`        // Slice of user responses for the current page.
-	TotalItems  int64          `json:"total_items"`  // Total number of users matching the query.
-	TotalPages  int            `json:"total_pages"`  // Total number of pages available.
-	CurrentPage int            `json:"current_page"` // The current page number.
-	PageSize    int            `json:"page_size"`    // The number of items per page.
+// PermissionsResponse DTO describing the authenticated principal and what it may do.
+type PermissionsResponse struct {
+	UserID          string               `json:"user_id"`          // ID of the authenticated user.
+	Role            customTypes.UserRole `json:"role"`             // Role of the authenticated user.
+	IsAdmin         bool                 `json:"is_admin"`         // Whether the role grants administrator privileges.
+	Scopes          []string             `json:"scopes"`           // Scopes granted to the role.
+	IsService       bool                 `json:"is_service"`       // Whether the principal is a service account rather than a user.
+	IsImpersonating bool                 `json:"is_impersonating"` // Whether the principal acts on behalf of another user.
 }
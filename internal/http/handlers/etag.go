@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// respondWithCacheableJSON writes payload as a 200 OK JSON response tagged with an ETag, or 304 Not Modified
+// without a body if the request's If-None-Match header already names that ETag.
+// The ETag hashes the request URI, so it varies with filters and pagination, together with the response body,
+// so it changes whenever any field of the result does, including rows that were added or removed. Responses are
+// marked private and must be revalidated, as they may depend on the caller.
+func respondWithCacheableJSON(w http.ResponseWriter, r *http.Request, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, payload) // Reports the marshalling error.
+		return
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.URL.RequestURI()))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write JSON response to client", "error", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header value names etag. As required for If-None-Match,
+// entity tags are compared weakly, so a W/ prefix is ignored, and "*" matches any ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
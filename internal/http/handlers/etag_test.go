@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bitback/internal/models"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{ifNoneMatch: "", want: false},
+		{ifNoneMatch: `"abc"`, want: true},
+		{ifNoneMatch: `W/"abc"`, want: true},
+		{ifNoneMatch: `"def", "abc"`, want: true},
+		{ifNoneMatch: `*`, want: true},
+		{ifNoneMatch: `"def"`, want: false},
+		{ifNoneMatch: `abc`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ifNoneMatch, func(t *testing.T) {
+			if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+				t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostETags(t *testing.T) {
+	updatedAt := time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)
+	seed := func() []models.Host {
+		return []models.Host{
+			{ID: 1, Address: "de1.example.com", Port: "443", Protocol: "vless", UpdatedAt: updatedAt},
+			{ID: 2, Address: "nl1.example.com", Port: "443", Protocol: "vless", UpdatedAt: updatedAt},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		revalidate string // Path of the conditional request; defaults to path.
+		change     func(hosts []models.Host) []models.Host
+		weak       bool
+		wantStatus int
+	}{
+		{name: "unchanged list", path: "/v1/hosts", wantStatus: http.StatusNotModified},
+		{name: "unchanged list with a weak validator", path: "/v1/hosts", weak: true, wantStatus: http.StatusNotModified},
+		{name: "list with an updated host", path: "/v1/hosts", wantStatus: http.StatusOK,
+			change: func(hosts []models.Host) []models.Host { hosts[1].UpdatedAt = updatedAt.Add(time.Minute); return hosts }},
+		{name: "list with an added host", path: "/v1/hosts", wantStatus: http.StatusOK,
+			change: func(hosts []models.Host) []models.Host {
+				return append(hosts, models.Host{ID: 3, UpdatedAt: updatedAt})
+			}},
+		{name: "list with a removed host", path: "/v1/hosts", wantStatus: http.StatusOK,
+			change: func(hosts []models.Host) []models.Host { return hosts[:1] }},
+		{name: "same data on another page", path: "/v1/hosts?page=1", revalidate: "/v1/hosts?page=2", wantStatus: http.StatusOK},
+		{name: "same data with another filter", path: "/v1/hosts", revalidate: "/v1/hosts?country=DE", wantStatus: http.StatusOK},
+		{name: "unchanged host", path: "/v1/hosts/1", wantStatus: http.StatusNotModified},
+		{name: "updated host", path: "/v1/hosts/1", wantStatus: http.StatusOK,
+			change: func(hosts []models.Host) []models.Host { hosts[0].UpdatedAt = updatedAt.Add(time.Minute); return hosts }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts := seed()
+			svc := &fakeHostService{
+				listHosts: func(context.Context, serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+					return hosts, int64(len(hosts)), nil
+				},
+				getHostByID: func(_ context.Context, hostID uint) (*models.Host, error) {
+					host := hosts[hostID-1]
+					return &host, nil
+				},
+			}
+			handler := newTestHostHandler(svc)
+
+			first := serveRoutes(handler.RegisterRoutes, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("first response: status = %d, ETag = %q, want 200 with an ETag", first.Code, etag)
+			}
+			if got := first.Header().Get("Cache-Control"); got != "private, no-cache" {
+				t.Errorf("Cache-Control = %q, want %q", got, "private, no-cache")
+			}
+
+			if tt.change != nil {
+				hosts = tt.change(hosts)
+			}
+			path := tt.path
+			if tt.revalidate != "" {
+				path = tt.revalidate
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.weak {
+				req.Header.Set("If-None-Match", "W/"+etag)
+			} else {
+				req.Header.Set("If-None-Match", etag)
+			}
+			rec := serveRoutes(handler.RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("conditional response status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "private, no-cache" {
+				t.Errorf("conditional response Cache-Control = %q, want %q", got, "private, no-cache")
+			}
+			if tt.wantStatus == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 response has body %q", rec.Body.String())
+				}
+				if got := rec.Header().Get("ETag"); got != etag {
+					t.Errorf("304 response ETag = %q, want %q", got, etag)
+				}
+				return
+			}
+			if got := rec.Header().Get("ETag"); got == "" || got == etag {
+				t.Errorf("changed response ETag = %q, want a new ETag (previous %q)", got, etag)
+			}
+			if rec.Body.Len() == 0 {
+				t.Error("changed response has no body")
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bitback/internal/httpctx"
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The fake services below implement only the methods the tests exercise, through function fields;
+// calling any other method panics through the nil embedded interface.
+
+// fakeSubscriptionService is an interfaces.SubscriptionService for handler tests.
+type fakeSubscriptionService struct {
+	interfaces.SubscriptionService
+
+	listSubscriptions  func(ctx context.Context, filters customTypes.ListSubscriptionsFilters, page, pageSize int) ([]models.Subscription, int64, error)
+	deleteSubscription func(ctx context.Context, subscriptionID, requestingUserID uuid.UUID) error
+	listAllUserSubs    func(ctx context.Context, userID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) ([]models.Subscription, error)
+	markExpiryNotified func(ctx context.Context, subscriptionID uuid.UUID) (*models.Subscription, error)
+	listUserSubs       func(ctx context.Context, userID uuid.UUID, params serviceDTO.ListUserSubscriptionsParams) ([]models.Subscription, int64, error)
+	recordUsage        func(ctx context.Context, subscriptionID uuid.UUID, input serviceDTO.RecordUsageInput) (*models.SubscriptionUsage, error)
+	getUsageReport     func(ctx context.Context, subscriptionID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*serviceDTO.UsageReport, error)
+	byCurrency         func(ctx context.Context, from, to time.Time) (*serviceDTO.SubscriptionsByCurrency, error)
+	listEvents         func(ctx context.Context, subscriptionID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, page, pageSize int) ([]models.SubscriptionEvent, int64, error)
+	applyPayment       func(ctx context.Context, input serviceDTO.ApplyPaymentInput) (*models.Subscription, bool, error)
+	userSubStatus      func(ctx context.Context, userID uuid.UUID) (*serviceDTO.UserSubscriptionStatus, error)
+	createSubscription func(ctx context.Context, input serviceDTO.CreateSubscriptionInput) (*models.Subscription, bool, error)
+}
+
+func (f *fakeSubscriptionService) CreateSubscription(ctx context.Context, input serviceDTO.CreateSubscriptionInput) (*models.Subscription, bool, error) {
+	return f.createSubscription(ctx, input)
+}
+
+func (f *fakeSubscriptionService) GetUserSubscriptionStatus(ctx context.Context, userID uuid.UUID) (*serviceDTO.UserSubscriptionStatus, error) {
+	return f.userSubStatus(ctx, userID)
+}
+
+func (f *fakeSubscriptionService) ApplyPayment(ctx context.Context, input serviceDTO.ApplyPaymentInput) (*models.Subscription, bool, error) {
+	return f.applyPayment(ctx, input)
+}
+
+func (f *fakeSubscriptionService) ListSubscriptionEvents(ctx context.Context, subscriptionID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, page, pageSize int) ([]models.SubscriptionEvent, int64, error) {
+	return f.listEvents(ctx, subscriptionID, requestingUserID, requestingUserRole, page, pageSize)
+}
+
+func (f *fakeSubscriptionService) GetSubscriptionsByCurrency(ctx context.Context, from, to time.Time) (*serviceDTO.SubscriptionsByCurrency, error) {
+	return f.byCurrency(ctx, from, to)
+}
+
+func (f *fakeSubscriptionService) RecordUsage(ctx context.Context, subscriptionID uuid.UUID, input serviceDTO.RecordUsageInput) (*models.SubscriptionUsage, error) {
+	return f.recordUsage(ctx, subscriptionID, input)
+}
+
+func (f *fakeSubscriptionService) GetUsageReport(ctx context.Context, subscriptionID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*serviceDTO.UsageReport, error) {
+	return f.getUsageReport(ctx, subscriptionID, requestingUserID, requestingUserRole)
+}
+
+func (f *fakeSubscriptionService) ListUserSubscriptions(ctx context.Context, userID uuid.UUID, params serviceDTO.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
+	return f.listUserSubs(ctx, userID, params)
+}
+
+func (f *fakeSubscriptionService) MarkExpiryNotified(ctx context.Context, subscriptionID uuid.UUID) (*models.Subscription, error) {
+	return f.markExpiryNotified(ctx, subscriptionID)
+}
+
+func (f *fakeSubscriptionService) ListAllUserSubscriptions(ctx context.Context, userID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) ([]models.Subscription, error) {
+	return f.listAllUserSubs(ctx, userID, requestingUserID, requestingUserRole)
+}
+
+func (f *fakeSubscriptionService) ListSubscriptions(ctx context.Context, filters customTypes.ListSubscriptionsFilters, page, pageSize int) ([]models.Subscription, int64, error) {
+	return f.listSubscriptions(ctx, filters, page, pageSize)
+}
+
+func (f *fakeSubscriptionService) DeleteSubscription(ctx context.Context, subscriptionID, requestingUserID uuid.UUID) error {
+	return f.deleteSubscription(ctx, subscriptionID, requestingUserID)
+}
+
+// fakeUserService is an interfaces.UserService for handler tests.
+type fakeUserService struct {
+	interfaces.UserService
+
+	getUserByTelegramID func(ctx context.Context, telegramID int64) (*models.User, error)
+	listUsers           func(ctx context.Context, params serviceDTO.ListUsersServiceParams) ([]models.User, int64, error)
+	listByHostCountry   func(ctx context.Context, country string, page, pageSize int) ([]models.User, int64, error)
+}
+
+func (f *fakeUserService) ListUsers(ctx context.Context, params serviceDTO.ListUsersServiceParams) ([]models.User, int64, error) {
+	return f.listUsers(ctx, params)
+}
+
+func (f *fakeUserService) ListUsersByHostCountry(ctx context.Context, country string, page, pageSize int) ([]models.User, int64, error) {
+	return f.listByHostCountry(ctx, country, page, pageSize)
+}
+
+func (f *fakeUserService) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	return f.getUserByTelegramID(ctx, telegramID)
+}
+
+// fakeKeyService is an interfaces.KeyService for handler tests.
+type fakeKeyService struct {
+	interfaces.KeyService
+
+	getCurrentVlessKeyForUser func(ctx context.Context, userID uuid.UUID) (*serviceDTO.CurrentUserKeyResult, error)
+	generateVlessKeyForUser   func(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateUserKeyResult, error)
+	generateFreeVlessKey      func(ctx context.Context, remarks string, prefs serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error)
+	generateFreeVlessKeys     func(ctx context.Context, count int, remarks string, country *string) ([]serviceDTO.FreeKeyResult, error)
+	keyGenerationRate         func(ctx context.Context, window, bucket time.Duration) (*serviceDTO.KeyGenerationRate, error)
+	conversionFunnel          func(ctx context.Context, from, to time.Time) (*serviceDTO.ConversionFunnel, error)
+	generateTrojanKeyForUser  func(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateTrojanKeyResult, error)
+	generateVlessKeysForUser  func(ctx context.Context, userID uuid.UUID, remarks string, countries []string) (*serviceDTO.MultiCountryKeysResult, error)
+	getSubscriptionFeed       func(ctx context.Context, userID uuid.UUID, remarks string) (*serviceDTO.SubscriptionFeed, error)
+}
+
+func (f *fakeKeyService) GetSubscriptionFeed(ctx context.Context, userID uuid.UUID, remarks string) (*serviceDTO.SubscriptionFeed, error) {
+	return f.getSubscriptionFeed(ctx, userID, remarks)
+}
+
+func (f *fakeKeyService) GenerateVlessKeysForUser(ctx context.Context, userID uuid.UUID, remarks string, countries []string) (*serviceDTO.MultiCountryKeysResult, error) {
+	return f.generateVlessKeysForUser(ctx, userID, remarks, countries)
+}
+
+func (f *fakeKeyService) GenerateTrojanKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateTrojanKeyResult, error) {
+	return f.generateTrojanKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+}
+
+func (f *fakeKeyService) GetConversionFunnel(ctx context.Context, from, to time.Time) (*serviceDTO.ConversionFunnel, error) {
+	return f.conversionFunnel(ctx, from, to)
+}
+
+func (f *fakeKeyService) GenerateFreeVlessKey(ctx context.Context, remarks string, prefs serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error) {
+	return f.generateFreeVlessKey(ctx, remarks, prefs)
+}
+
+func (f *fakeKeyService) GenerateFreeVlessKeys(ctx context.Context, count int, remarks string, country *string) ([]serviceDTO.FreeKeyResult, error) {
+	return f.generateFreeVlessKeys(ctx, count, remarks, country)
+}
+
+func (f *fakeKeyService) GenerateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateUserKeyResult, error) {
+	return f.generateVlessKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+}
+
+func (f *fakeKeyService) GetKeyGenerationRate(ctx context.Context, window, bucket time.Duration) (*serviceDTO.KeyGenerationRate, error) {
+	return f.keyGenerationRate(ctx, window, bucket)
+}
+
+func (f *fakeKeyService) GetCurrentVlessKeyForUser(ctx context.Context, userID uuid.UUID) (*serviceDTO.CurrentUserKeyResult, error) {
+	return f.getCurrentVlessKeyForUser(ctx, userID)
+}
+
+// fakeHostService is an interfaces.HostService for handler tests.
+type fakeHostService struct {
+	interfaces.HostService
+
+	getHostByID    func(ctx context.Context, hostID uint) (*models.Host, error)
+	listHosts      func(ctx context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error)
+	listHostsAfter func(ctx context.Context, params serviceDTO.ListHostsServiceParams, after *customTypes.HostListCursor) ([]models.Host, bool, error)
+	providers      func(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error)
+	availability   func(ctx context.Context) (*serviceDTO.HostAvailabilityReport, error)
+	countries      func(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error)
+	utilization    func(ctx context.Context, hostID uint) (*serviceDTO.HostUtilization, error)
+	exportHosts    func(ctx context.Context) ([]models.Host, error)
+	importHosts    func(ctx context.Context, inputs []serviceDTO.ImportHostInput) (*serviceDTO.ImportHostsResult, error)
+	addHost        func(ctx context.Context, input serviceDTO.CreateHostInput) (*models.Host, error)
+	updateHost     func(ctx context.Context, hostID uint, input serviceDTO.UpdateHostInput) (*models.Host, error)
+	setHostTier    func(ctx context.Context, hostID uint, isFreeTier bool) (*models.Host, error)
+}
+
+func (f *fakeHostService) SetHostTier(ctx context.Context, hostID uint, isFreeTier bool) (*models.Host, error) {
+	return f.setHostTier(ctx, hostID, isFreeTier)
+}
+
+func (f *fakeHostService) AddHost(ctx context.Context, input serviceDTO.CreateHostInput) (*models.Host, error) {
+	return f.addHost(ctx, input)
+}
+
+func (f *fakeHostService) UpdateHost(ctx context.Context, hostID uint, input serviceDTO.UpdateHostInput) (*models.Host, error) {
+	return f.updateHost(ctx, hostID, input)
+}
+
+func (f *fakeHostService) ExportHosts(ctx context.Context) ([]models.Host, error) {
+	return f.exportHosts(ctx)
+}
+
+func (f *fakeHostService) ImportHosts(ctx context.Context, inputs []serviceDTO.ImportHostInput) (*serviceDTO.ImportHostsResult, error) {
+	return f.importHosts(ctx, inputs)
+}
+
+func (f *fakeHostService) GetAvailabilityReport(ctx context.Context) (*serviceDTO.HostAvailabilityReport, error) {
+	return f.availability(ctx)
+}
+
+func (f *fakeHostService) GetHostUtilization(ctx context.Context, hostID uint) (*serviceDTO.HostUtilization, error) {
+	return f.utilization(ctx, hostID)
+}
+
+func (f *fakeHostService) GetHostAvailability(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error) {
+	return f.countries(ctx, isFreeTier)
+}
+
+func (f *fakeHostService) GetProvidersReport(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error) {
+	return f.providers(ctx, country)
+}
+
+func (f *fakeHostService) GetHostByID(ctx context.Context, hostID uint) (*models.Host, error) {
+	return f.getHostByID(ctx, hostID)
+}
+
+func (f *fakeHostService) ListHosts(ctx context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+	return f.listHosts(ctx, params)
+}
+
+func (f *fakeHostService) ListHostsAfter(ctx context.Context, params serviceDTO.ListHostsServiceParams, after *customTypes.HostListCursor) ([]models.Host, bool, error) {
+	return f.listHostsAfter(ctx, params, after)
+}
+
+// fakeReportService is an interfaces.ReportService for handler tests.
+type fakeReportService struct {
+	getOverview func(ctx context.Context) (*serviceDTO.Overview, error)
+}
+
+func (f *fakeReportService) GetOverview(ctx context.Context) (*serviceDTO.Overview, error) {
+	return f.getOverview(ctx)
+}
+
+// fakeOnboardingService is an interfaces.OnboardingService for handler tests.
+type fakeOnboardingService struct {
+	onboard func(ctx context.Context, input serviceDTO.OnboardInput) (*serviceDTO.OnboardResult, error)
+}
+
+func (f *fakeOnboardingService) Onboard(ctx context.Context, input serviceDTO.OnboardInput) (*serviceDTO.OnboardResult, error) {
+	return f.onboard(ctx, input)
+}
+
+// stubDatabase is an interfaces.SQLDatabase whose Ping returns pingErr.
+type stubDatabase struct {
+	interfaces.SQLDatabase
+
+	pingErr error
+}
+
+func (d *stubDatabase) Ping(context.Context) error {
+	return d.pingErr
+}
+
+// asPrincipal returns r authenticated as the given user and role, as the auth middleware would.
+func asPrincipal(r *http.Request, userID uuid.UUID, role customTypes.UserRole) *http.Request {
+	return r.WithContext(httpctx.WithPrincipal(r.Context(), httpctx.Principal{UserID: userID, Role: role}))
+}
+
+// serveRoutes registers routes on a new router and serves r through it.
+func serveRoutes(register func(mux RouteRegistrar), r *http.Request) *httptest.ResponseRecorder {
+	router := NewRouter()
+	register(router)
+	rec := httptest.NewRecorder()
+	router.GetHandler().ServeHTTP(rec, r)
+	return rec
+}
+
+// decodeJSON decodes the recorded response body into a value of type T.
+func decodeJSON[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("failed to decode response body %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// ptrTo returns a pointer to v.
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bitback/internal/interfaces"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// readinessPingTimeout bounds how long the readiness probe waits for the database.
+const readinessPingTimeout = 2 * time.Second
+
+// HealthHandler handles liveness and readiness probes from load balancers and orchestrators.
+type HealthHandler struct {
+	database interfaces.SQLDatabase
+}
+
+// NewHealthHandler creates a new instance of HealthHandler.
+// It takes an SQLDatabase as a dependency to check readiness.
+func NewHealthHandler(db interfaces.SQLDatabase) *HealthHandler {
+	return &HealthHandler{
+		database: db,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for the HealthHandler.
+func (h *HealthHandler) RegisterRoutes(mux RouteRegistrar) {
+	mux.HandleFunc("GET /healthz", h.Liveness)
+	mux.HandleFunc("GET /readyz", h.Readiness)
+}
+
+// Liveness reports that the process is up and able to serve requests.
+// Expected route: GET /healthz
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness reports whether the service can handle traffic, which requires a reachable database.
+// It responds with 503 and the failure reason if the database ping fails.
+// Expected route: GET /readyz
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+	defer cancel()
+
+	if err := h.database.Ping(ctx); err != nil {
+		slog.WarnContext(ctx, "Readiness: database is not reachable", "error", err)
+		respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status":   "unavailable",
+			"database": err.Error(),
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":   "ok",
+		"database": "ok",
+	})
+}
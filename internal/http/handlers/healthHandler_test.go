@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		pingErr      error
+		wantStatus   int
+		wantStatusKV string
+		wantDatabase string
+	}{
+		{name: "liveness", path: "/healthz", wantStatus: http.StatusOK, wantStatusKV: "ok"},
+		{name: "liveness ignores the database", path: "/healthz", pingErr: errors.New("connection refused"), wantStatus: http.StatusOK, wantStatusKV: "ok"},
+		{name: "ready", path: "/readyz", wantStatus: http.StatusOK, wantStatusKV: "ok", wantDatabase: "ok"},
+		{name: "database unreachable", path: "/readyz", pingErr: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable, wantStatusKV: "unavailable", wantDatabase: "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(&stubDatabase{pingErr: tt.pingErr})
+
+			rec := serveRoutes(h.RegisterRoutes, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			got := decodeJSON[map[string]string](t, rec)
+			if got["status"] != tt.wantStatusKV || got["database"] != tt.wantDatabase {
+				t.Errorf("body = %v, want status %q and database %q", got, tt.wantStatusKV, tt.wantDatabase)
+			}
+		})
+	}
+}
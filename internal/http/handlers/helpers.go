@@ -2,20 +2,110 @@ package handlers
 
 import (
 	"bitback/internal/http/handlers/dto"
+	"bitback/internal/httpctx"
 	"bitback/internal/models"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 )
 
+// Machine-readable error codes included in every error response.
+const (
+	errorCodeBadRequest       = "bad_request"
+	errorCodeValidationFailed = "validation_failed"
+	errorCodeUnauthenticated  = "unauthenticated"
+	errorCodeForbidden        = "forbidden"
+	errorCodeNotFound         = "not_found"
+	errorCodeConflict         = "conflict"
+	errorCodeTooManyRequests  = "too_many_requests"
+	errorCodeInternal         = "internal_error"
+	errorCodeUnavailable      = "service_unavailable"
+	errorCodeClientClosed     = "client_closed_request"
+)
+
+// statusClientClosedRequest is the non-standard status used when the client went away before the response was ready.
+const statusClientClosedRequest = 499
+
+// defaultReportDays is the length of the period covered by reports when the client gives no 'from' date.
+const defaultReportDays = 30
+
 // respondWithError logs an error and sends a JSON error response to the client.
+// The error code is derived from the HTTP status.
 func respondWithError(w http.ResponseWriter, code int, message string) {
-	slog.Error("Responding with error", "code", code, "message", message)
-	respondWithJSON(w, code, map[string]string{"error": message})
+	respondWithErrorCode(w, code, errorCodeForStatus(code), message)
+}
+
+// respondWithErrorCode logs an error and sends a JSON error response with an explicit error code.
+// The "error" field duplicates the message for clients that predate structured errors.
+// The request ID set by the RequestID middleware is included in both, as the response writer carries no context.
+func respondWithErrorCode(w http.ResponseWriter, status int, errorCode, message string) {
+	requestID := w.Header().Get(requestIDHeader)
+	slog.Error("Responding with error", "code", status, "errorCode", errorCode, "message", message, "request_id", requestID)
+	respondWithJSON(w, status, dto.ErrorResponse{Code: errorCode, Message: message, Error: message, RequestID: requestID})
+}
+
+// respondWithServiceError maps an error returned by a service to an HTTP error response.
+// Errors tagged with a service error category are reported with their own message;
+// anything else is treated as an internal error and reported with internalMessage.
+func respondWithServiceError(w http.ResponseWriter, err error, internalMessage string) {
+	switch {
+	case errors.Is(err, services.ErrRequestCanceled):
+		// Nobody is waiting for the body; record the outcome without reporting a server error.
+		requestID := w.Header().Get(requestIDHeader)
+		slog.Warn("Request canceled by the client", "error", err, "request_id", requestID)
+		respondWithJSON(w, statusClientClosedRequest, dto.ErrorResponse{Code: errorCodeClientClosed, Message: "Request canceled.", Error: "Request canceled.", RequestID: requestID})
+	case errors.Is(err, services.ErrRequestTimeout):
+		respondWithErrorCode(w, http.StatusServiceUnavailable, errorCodeUnavailable, "The request timed out; please retry.")
+	case errors.Is(err, services.ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithErrorCode(w, http.StatusNotFound, errorCodeNotFound, err.Error())
+	case errors.Is(err, services.ErrConflict):
+		respondWithErrorCode(w, http.StatusConflict, errorCodeConflict, err.Error())
+	case errors.Is(err, services.ErrUnauthorized):
+		respondWithErrorCode(w, http.StatusForbidden, errorCodeForbidden, err.Error())
+	case errors.Is(err, services.ErrValidation):
+		respondWithErrorCode(w, http.StatusBadRequest, errorCodeValidationFailed, err.Error())
+	case errors.Is(err, services.ErrUnavailable):
+		respondWithErrorCode(w, http.StatusServiceUnavailable, errorCodeUnavailable, err.Error())
+	default:
+		respondWithErrorCode(w, http.StatusInternalServerError, errorCodeInternal, internalMessage)
+	}
+}
+
+// errorCodeForStatus returns the default error code for an HTTP status.
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return errorCodeBadRequest
+	case http.StatusUnauthorized:
+		return errorCodeUnauthenticated
+	case http.StatusForbidden:
+		return errorCodeForbidden
+	case http.StatusNotFound:
+		return errorCodeNotFound
+	case http.StatusConflict:
+		return errorCodeConflict
+	case http.StatusTooManyRequests:
+		return errorCodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return errorCodeUnavailable
+	case statusClientClosedRequest:
+		return errorCodeClientClosed
+	default:
+		if status >= http.StatusInternalServerError {
+			return errorCodeInternal
+		}
+		return errorCodeBadRequest
+	}
 }
 
 // respondWithJSON marshals the payload to JSON and sends it as an HTTP response.
@@ -28,7 +118,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusInternalServerError)
 		// Provide a generic error message to the client in case of marshalling failure.
-		errorResponse := `{"error": "An internal server error occurred while processing your request."}`
+		errorResponse := `{"code": "internal_error", "message": "An internal server error occurred while processing your request.", "error": "An internal server error occurred while processing your request."}`
 		_, writeErr := w.Write([]byte(errorResponse))
 		if writeErr != nil {
 			slog.Error("Failed to write error response after marshalling error", "original_error", err, "write_error", writeErr)
@@ -49,18 +139,24 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 // It handles optional fields like Price and Currency, setting them only if they have meaningful values.
 func toSubscriptionResponse(sub *models.Subscription) dto.SubscriptionResponse {
 	resp := dto.SubscriptionResponse{
-		ID:            sub.ID,
-		UserID:        sub.UserID,
-		PlanName:      sub.PlanName,
-		DurationUnit:  sub.DurationUnit,
-		DurationValue: sub.DurationValue,
-		StartDate:     sub.StartDate,
-		EndDate:       sub.EndDate,
-		IsActive:      sub.IsActive,
-		PaymentStatus: sub.PaymentStatus,
-		AutoRenew:     sub.AutoRenew,
-		CreatedAt:     sub.CreatedAt,
-		UpdatedAt:     sub.UpdatedAt,
+		ID:                   sub.ID,
+		UserID:               sub.UserID,
+		PlanID:               sub.PlanID,
+		PromoCodeID:          sub.PromoCodeID,
+		PlanName:             sub.PlanName,
+		DurationUnit:         sub.DurationUnit,
+		DurationValue:        sub.DurationValue,
+		StartDate:            sub.StartDate,
+		EndDate:              sub.EndDate,
+		IsActive:             sub.IsActive,
+		PaymentStatus:        sub.PaymentStatus,
+		ExternalPaymentID:    sub.ExternalPaymentID,
+		AutoRenew:            sub.AutoRenew,
+		LastRenewedAt:        sub.LastRenewedAt,
+		LastExpiryNotifiedAt: sub.LastExpiryNotifiedAt,
+		CreatedAt:            sub.CreatedAt,
+		UpdatedAt:            sub.UpdatedAt,
+		Invalid:              !sub.DurationUnit.IsValid(),
 	}
 	// Only include price if it's non-zero (assuming price cannot be negative).
 	if sub.Price != 0 {
@@ -74,22 +170,34 @@ func toSubscriptionResponse(sub *models.Subscription) dto.SubscriptionResponse {
 }
 
 // getRequestingUserID extracts the authenticated user's ID from the request context.
-// This is a placeholder.
+// The ID is placed there by AuthMiddleware; an error is returned if the request is unauthenticated.
 func getRequestingUserID(ctx context.Context) (uuid.UUID, error) {
-	// TODO: Implement actual user ID retrieval from context.
-	dummyUserID, _ := uuid.NewRandom()
-	slog.WarnContext(ctx, "getRequestingUserID: USING DUMMY USER ID. Implement proper user ID retrieval from context.")
-	return dummyUserID, nil
+	userID, ok := httpctx.UserID(ctx)
+	if !ok {
+		return uuid.Nil, errors.New("no authenticated user in request context")
+	}
+	return userID, nil
+}
+
+// getOptionalRequestingUserID returns the authenticated user's ID, or nil if the request is unauthenticated.
+func getOptionalRequestingUserID(ctx context.Context) *uuid.UUID {
+	userID, err := getRequestingUserID(ctx)
+	if err != nil {
+		return nil
+	}
+	return &userID
 }
 
 // toHostResponse converts a models.Host to a dto.HostResponse.
-func toHostResponse(host *models.Host) dto.HostResponse {
-	return dto.HostResponse{
+// Operator notes are included only when includeNotes is true, i.e. for administrators.
+func toHostResponse(host *models.Host, includeNotes bool) dto.HostResponse {
+	resp := dto.HostResponse{
 		ID:            host.ID,
 		HostName:      host.HostName,
 		Country:       host.Country,
 		City:          host.City,
 		Address:       host.Address,
+		AddressFamily: string(host.AddressFamily),
 		Port:          host.Port,
 		Protocol:      host.Protocol,
 		Network:       host.Network, // Network type.
@@ -100,19 +208,112 @@ func toHostResponse(host *models.Host) dto.HostResponse {
 		SNI:           host.SNI,
 		Fingerprint:   host.Fingerprint,
 		IsPrivate:     host.IsPrivate,
+		IsFreeTier:    host.IsFreeTier,
+		MaxUsers:      host.MaxUsers,
 		IsOnline:      host.IsOnline,
 		Status:        host.Status,
 		LastCheckedAt: host.LastCheckedAt,
+		LatencyMs:     host.LatencyMs,
 		Region:        host.Region,
 		Provider:      host.Provider,
 		CreatedAt:     host.CreatedAt,
 		UpdatedAt:     host.UpdatedAt,
 	}
+	if includeNotes {
+		resp.Notes = host.Notes
+	}
+	if host.DeletedAt.Valid {
+		resp.DeletedAt = &host.DeletedAt.Time
+	}
+	return resp
+}
+
+// toCreateHostInput maps a dto.CreateHostRequest to the service layer input.
+func toCreateHostInput(req dto.CreateHostRequest) serviceDTO.CreateHostInput {
+	return serviceDTO.CreateHostInput{
+		HostName:     req.HostName,
+		Country:      req.Country,
+		City:         req.City,
+		Address:      req.Address,
+		Port:         req.Port,
+		Protocol:     req.Protocol,
+		Network:      req.Network,
+		PublicKey:    req.PublicKey,
+		Flow:         req.Flow,
+		RSID:         req.RSID,
+		SecurityType: req.SecurityType,
+		SNI:          req.SNI,
+		Fingerprint:  req.Fingerprint,
+		IsPrivate:    req.IsPrivate,
+		IsFreeTier:   req.IsFreeTier,
+		MaxUsers:     req.MaxUsers,
+		Region:       req.Region,
+		Provider:     req.Provider,
+		Notes:        req.Notes,
+	}
+}
+
+// toPlanResponse converts a models.Plan to a dto.PlanResponse.
+func toPlanResponse(plan *models.Plan) dto.PlanResponse {
+	return dto.PlanResponse{
+		ID:            plan.ID,
+		Name:          plan.Name,
+		Description:   plan.Description,
+		DurationUnit:  plan.DurationUnit,
+		DurationValue: plan.DurationValue,
+		Price:         plan.Price,
+		Currency:      plan.Currency,
+		IsActive:      plan.IsActive,
+		IsFreeTier:    plan.IsFreeTier,
+		CreatedAt:     plan.CreatedAt,
+		UpdatedAt:     plan.UpdatedAt,
+	}
+}
+
+// toPromoCodeResponse converts a models.PromoCode to a dto.PromoCodeResponse.
+func toPromoCodeResponse(promoCode *models.PromoCode) dto.PromoCodeResponse {
+	return dto.PromoCodeResponse{
+		ID:              promoCode.ID,
+		Code:            promoCode.Code,
+		DiscountPercent: promoCode.DiscountPercent,
+		DiscountAmount:  promoCode.DiscountAmount,
+		ValidFrom:       promoCode.ValidFrom,
+		ValidUntil:      promoCode.ValidUntil,
+		MaxUses:         promoCode.MaxUses,
+		UsedCount:       promoCode.UsedCount,
+		IsActive:        promoCode.IsActive,
+		CreatedAt:       promoCode.CreatedAt,
+	}
+}
+
+// toHostCheckResponse converts a models.HostCheck to a dto.HostCheckResponse.
+func toHostCheckResponse(check *models.HostCheck) dto.HostCheckResponse {
+	return dto.HostCheckResponse{
+		ID:        check.ID,
+		HostID:    check.HostID,
+		CheckedAt: check.CheckedAt,
+		WasOnline: check.WasOnline,
+		LatencyMs: check.LatencyMs,
+		Error:     check.Error,
+	}
+}
+
+// toSubscriptionEventResponse converts a models.SubscriptionEvent to a dto.SubscriptionEventResponse.
+func toSubscriptionEventResponse(event *models.SubscriptionEvent) dto.SubscriptionEventResponse {
+	return dto.SubscriptionEventResponse{
+		ID:          event.ID,
+		EventType:   event.EventType,
+		OldValue:    event.OldValue,
+		NewValue:    event.NewValue,
+		ActorUserID: event.ActorUserID,
+		CreatedAt:   event.CreatedAt,
+	}
 }
 
 // toUserResponse converts a models.User to a dto.UserResponse.
-func toUserResponse(user *models.User) dto.UserResponse {
-	return dto.UserResponse{
+// The role is only disclosed to administrators and to the user themself.
+func toUserResponse(ctx context.Context, user *models.User) dto.UserResponse {
+	resp := dto.UserResponse{
 		ID:         user.ID,
 		Name:       user.Name,
 		Email:      user.Email,
@@ -122,6 +323,13 @@ func toUserResponse(user *models.User) dto.UserResponse {
 		CreatedAt:  user.CreatedAt,
 		UpdatedAt:  user.UpdatedAt,
 	}
+	if requestingUserID, err := getRequestingUserID(ctx); err == nil && (requestingUserID == user.ID || isAdminRequest(ctx)) {
+		resp.Role = string(user.Role)
+	}
+	if user.DeletedAt.Valid {
+		resp.DeletedAt = &user.DeletedAt.Time
+	}
+	return resp
 }
 
 // parseUint converts a string to a uint.
@@ -133,3 +341,36 @@ func parseUint(s string) (uint, error) {
 	}
 	return uint(val), nil
 }
+
+// parseReportPeriod parses the optional 'from' and 'to' query parameters of a report (see parseReportDate).
+// 'to' defaults to now and 'from' to defaultDays before 'to'. The returned error is suitable for the client.
+func parseReportPeriod(query url.Values, defaultDays int) (from, to time.Time, err error) {
+	to = time.Now().UTC()
+	if toStr := query.Get("to"); toStr != "" {
+		if to, err = parseReportDate(toStr); err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid 'to' query parameter (expected RFC 3339 or YYYY-MM-DD).")
+		}
+	}
+	from = to.AddDate(0, 0, -defaultDays)
+	if fromStr := query.Get("from"); fromStr != "" {
+		if from, err = parseReportDate(fromStr); err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid 'from' query parameter (expected RFC 3339 or YYYY-MM-DD).")
+		}
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("Query parameter 'from' must be before 'to'.")
+	}
+	return from, to, nil
+}
+
+// parseReportDate parses a report date given either in RFC 3339 format or as a plain YYYY-MM-DD date (UTC midnight).
+func parseReportDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse '%s' as a date: %w", s, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/httpctx"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestRespondWithServiceError(t *testing.T) {
+	const internalMessage = "Failed to do the thing."
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{name: "not found", err: fmt.Errorf("user 42 not found: %w", services.ErrNotFound),
+			wantStatus: http.StatusNotFound, wantCode: errorCodeNotFound, wantMessage: "user 42 not found: not found"},
+		{name: "record not found", err: fmt.Errorf("lookup: %w", gorm.ErrRecordNotFound),
+			wantStatus: http.StatusNotFound, wantCode: errorCodeNotFound, wantMessage: "lookup: record not found"},
+		{name: "conflict", err: fmt.Errorf("email taken: %w", services.ErrConflict),
+			wantStatus: http.StatusConflict, wantCode: errorCodeConflict, wantMessage: "email taken: conflict"},
+		{name: "unauthorized", err: fmt.Errorf("not the owner: %w", services.ErrUnauthorized),
+			wantStatus: http.StatusForbidden, wantCode: errorCodeForbidden, wantMessage: "not the owner: not authorized"},
+		{name: "validation", err: fmt.Errorf("port 0: %w", services.ErrInvalidHostPort),
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeValidationFailed, wantMessage: "port 0: invalid host port"},
+		{name: "unavailable", err: fmt.Errorf("no free hosts: %w", services.ErrUnavailable),
+			wantStatus: http.StatusServiceUnavailable, wantCode: errorCodeUnavailable, wantMessage: "no free hosts: unavailable"},
+		{name: "timeout", err: fmt.Errorf("query: %w", services.ErrRequestTimeout),
+			wantStatus: http.StatusServiceUnavailable, wantCode: errorCodeUnavailable, wantMessage: "The request timed out; please retry."},
+		{name: "canceled", err: fmt.Errorf("query: %w", services.ErrRequestCanceled),
+			wantStatus: statusClientClosedRequest, wantCode: errorCodeClientClosed, wantMessage: "Request canceled."},
+		{name: "uncategorized", err: errors.New("pq: connection refused"),
+			wantStatus: http.StatusInternalServerError, wantCode: errorCodeInternal, wantMessage: internalMessage},
+		{name: "raw context error is not exposed", err: context.DeadlineExceeded,
+			wantStatus: http.StatusInternalServerError, wantCode: errorCodeInternal, wantMessage: internalMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.Header().Set(requestIDHeader, "req-123")
+
+			respondWithServiceError(rec, tt.err, internalMessage)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			got := decodeJSON[dto.ErrorResponse](t, rec)
+			want := dto.ErrorResponse{Code: tt.wantCode, Message: tt.wantMessage, Error: tt.wantMessage, RequestID: "req-123"}
+			if got != want {
+				t.Errorf("body = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{status: http.StatusBadRequest, want: errorCodeBadRequest},
+		{status: http.StatusUnauthorized, want: errorCodeUnauthenticated},
+		{status: http.StatusForbidden, want: errorCodeForbidden},
+		{status: http.StatusNotFound, want: errorCodeNotFound},
+		{status: http.StatusConflict, want: errorCodeConflict},
+		{status: http.StatusTooManyRequests, want: errorCodeTooManyRequests},
+		{status: http.StatusServiceUnavailable, want: errorCodeUnavailable},
+		{status: statusClientClosedRequest, want: errorCodeClientClosed},
+		{status: http.StatusInternalServerError, want: errorCodeInternal},
+		{status: http.StatusBadGateway, want: errorCodeInternal},
+		{status: http.StatusRequestURITooLong, want: errorCodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			if got := errorCodeForStatus(tt.status); got != tt.want {
+				t.Errorf("errorCodeForStatus(%d) = %q, want %q", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRequestingUserID(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    uuid.UUID
+		wantErr bool
+	}{
+		{name: "authenticated", ctx: httpctx.WithPrincipal(context.Background(), httpctx.Principal{UserID: userID, Role: customTypes.RoleUser}), want: userID},
+		{name: "user ID only", ctx: httpctx.WithUserID(context.Background(), userID), want: userID},
+		{name: "unauthenticated", ctx: context.Background(), wantErr: true},
+		{name: "string key is ignored", ctx: context.WithValue(context.Background(), "userID", userID), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getRequestingUserID(tt.ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRequestingUserID() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getRequestingUserID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReportPeriod(t *testing.T) {
+	march := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{name: "dates", query: "from=2026-03-01&to=2026-04-01", wantFrom: march, wantTo: april},
+		{name: "RFC 3339", query: "from=2026-03-01T02:00:00%2B02:00&to=2026-04-01T00:00:00Z", wantFrom: march, wantTo: april},
+		{name: "from defaults to the days before to", query: "to=2026-04-01", wantFrom: april.AddDate(0, 0, -30), wantTo: april},
+		{name: "invalid from", query: "from=yesterday", wantErr: true},
+		{name: "invalid to", query: "to=2026-13-01", wantErr: true},
+		{name: "from equals to", query: "from=2026-03-01&to=2026-03-01", wantErr: true},
+		{name: "from after to", query: "from=2026-04-01&to=2026-03-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery(%q) error = %v", tt.query, err)
+			}
+
+			from, to, err := parseReportPeriod(query, 30)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseReportPeriod(%q) = %v, %v; want an error", tt.query, from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReportPeriod(%q) error = %v", tt.query, err)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("parseReportPeriod(%q) = %v, %v; want %v, %v", tt.query, from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+
+	t.Run("defaults to the last days", func(t *testing.T) {
+		from, to, err := parseReportPeriod(url.Values{}, 7)
+		if err != nil {
+			t.Fatalf("parseReportPeriod() error = %v", err)
+		}
+		if time.Since(to) > time.Minute || !from.Equal(to.AddDate(0, 0, -7)) {
+			t.Errorf("parseReportPeriod() = %v, %v; want the 7 days up to now", from, to)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	serviceDTO "bitback/internal/services/dto"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// hostBundleVersion is the format version of the host configuration bundles produced and accepted by the API.
+	hostBundleVersion = 1
+	// maxHostBundleSize is the maximum number of hosts accepted in a single bundle import.
+	maxHostBundleSize = 5000
+)
+
+// ExportHosts handles the request to export the configuration of all hosts as a backup bundle.
+// The bundle includes sensitive fields such as public keys and notes and is sent as a JSON attachment.
+// Expected route: GET /api/v1/hosts/export
+func (h *HostHandler) ExportHosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	hosts, err := h.hostService.ExportHosts(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "ExportHosts: failed to export hosts via service", "error", err)
+		respondWithServiceError(w, err, "Failed to export hosts.")
+		return
+	}
+
+	bundle := dto.HostBundle{
+		Version:    hostBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Hosts:      make([]dto.HostBundleEntry, len(hosts)),
+	}
+	for i := range hosts {
+		bundle.Hosts[i] = toHostBundleEntry(&hosts[i])
+	}
+
+	filename := fmt.Sprintf("hosts-%s.json", bundle.ExportedAt.Format("20060102T150405Z"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	slog.InfoContext(ctx, "ExportHosts: host bundle exported", "count", len(hosts))
+	respondWithJSON(w, http.StatusOK, bundle)
+}
+
+// ImportHosts handles the request to restore hosts from a backup bundle produced by ExportHosts.
+// Hosts are matched by address, port, protocol and network: matching hosts have their configuration updated
+// and the others are created, so importing the same bundle again is a no-op. If any entry is invalid,
+// nothing is imported and 422 is returned with the per-entry results.
+// Expected route: POST /api/v1/hosts/import
+func (h *HostHandler) ImportHosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var bundle dto.HostBundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		slog.ErrorContext(ctx, "ImportHosts: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if bundle.Version != hostBundleVersion {
+		slog.WarnContext(ctx, "ImportHosts: unsupported bundle version", "version", bundle.Version)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported bundle version %d; expected %d.", bundle.Version, hostBundleVersion))
+		return
+	}
+	if len(bundle.Hosts) > maxHostBundleSize {
+		slog.WarnContext(ctx, "ImportHosts: too many hosts in bundle", "count", len(bundle.Hosts), "max", maxHostBundleSize)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Too many hosts in one bundle: at most %d are allowed.", maxHostBundleSize))
+		return
+	}
+
+	inputs := make([]serviceDTO.ImportHostInput, len(bundle.Hosts))
+	for i, entry := range bundle.Hosts {
+		inputs[i] = toImportHostInput(entry)
+	}
+
+	result, err := h.hostService.ImportHosts(ctx, inputs)
+	if err != nil {
+		slog.ErrorContext(ctx, "ImportHosts: failed to import hosts via service", "error", err, "count", len(inputs))
+		respondWithServiceError(w, err, "Failed to import hosts.")
+		return
+	}
+
+	resp := dto.ImportHostsResponse{
+		Results:   make([]dto.ImportHostResultResponse, len(result.Results)),
+		Total:     len(result.Results),
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		Invalid:   result.Invalid,
+		Committed: result.Committed,
+	}
+	for i, entryResult := range result.Results {
+		resp.Results[i] = dto.ImportHostResultResponse{
+			Index:  entryResult.Index,
+			Status: entryResult.Status,
+			Error:  entryResult.Error,
+		}
+		if entryResult.HostID != 0 {
+			resp.Results[i].HostID = &entryResult.HostID
+		}
+	}
+
+	if !result.Committed {
+		slog.WarnContext(ctx, "ImportHosts: host bundle rejected", "total", resp.Total, "invalid", resp.Invalid)
+		respondWithJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	slog.InfoContext(ctx, "ImportHosts: host bundle imported", "total", resp.Total, "created", resp.Created, "updated", resp.Updated, "unchanged", resp.Unchanged)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// toHostBundleEntry converts a models.Host to its entry in a host bundle.
+func toHostBundleEntry(host *models.Host) dto.HostBundleEntry {
+	return dto.HostBundleEntry{
+		ID:           host.ID,
+		HostName:     host.HostName,
+		Country:      host.Country,
+		City:         host.City,
+		Region:       host.Region,
+		Provider:     host.Provider,
+		Address:      host.Address,
+		Port:         host.Port,
+		Protocol:     host.Protocol,
+		Network:      host.Network,
+		PublicKey:    host.PublicKey,
+		Flow:         host.Flow,
+		RSID:         host.RSID,
+		SecurityType: host.SecurityType,
+		SNI:          host.SNI,
+		Fingerprint:  host.Fingerprint,
+		IsPrivate:    host.IsPrivate,
+		IsFreeTier:   host.IsFreeTier,
+		MaxUsers:     host.MaxUsers,
+		Status:       host.Status,
+		Notes:        host.Notes,
+	}
+}
+
+// toImportHostInput maps a host bundle entry to the service layer input.
+func toImportHostInput(entry dto.HostBundleEntry) serviceDTO.ImportHostInput {
+	return serviceDTO.ImportHostInput{
+		CreateHostInput: serviceDTO.CreateHostInput{
+			HostName:     entry.HostName,
+			Country:      entry.Country,
+			City:         entry.City,
+			Address:      entry.Address,
+			Port:         entry.Port,
+			Protocol:     entry.Protocol,
+			Network:      entry.Network,
+			PublicKey:    entry.PublicKey,
+			Flow:         entry.Flow,
+			RSID:         entry.RSID,
+			SecurityType: entry.SecurityType,
+			SNI:          entry.SNI,
+			Fingerprint:  entry.Fingerprint,
+			IsPrivate:    entry.IsPrivate,
+			IsFreeTier:   entry.IsFreeTier,
+			MaxUsers:     entry.MaxUsers,
+			Region:       entry.Region,
+			Provider:     entry.Provider,
+			Notes:        entry.Notes,
+		},
+		Status: entry.Status,
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// hostCSVColumns lists the columns accepted in a text/csv bulk host import, in their documented order.
+// The first row of the CSV body must be a header naming the columns. Columns are matched by name
+// (case-insensitive), so they may appear in any order and optional ones may be omitted;
+// "address", "port" and "protocol" are required. "is_private" and "is_free_tier" accept any value understood by strconv.ParseBool.
+var hostCSVColumns = []string{
+	"host_name", "country", "city", "address", "port", "protocol", "network", "public_key", "flow",
+	"rsid", "security_type", "sni", "fingerprint", "is_private", "is_free_tier", "region", "provider", "notes",
+}
+
+// hostCSVRequiredColumns lists the columns that must be present in the CSV header.
+var hostCSVRequiredColumns = []string{"address", "port", "protocol"}
+
+// parseHostsCSV decodes a CSV bulk host import body into create requests, stopping after maxRows data rows.
+// It returns an error if the header is missing or malformed, or if a row cannot be decoded.
+func parseHostsCSV(body io.Reader, maxRows int) ([]dto.CreateHostRequest, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV body is empty; a header row is required")
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columnIndexes := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isKnownHostCSVColumn(name) {
+			return nil, fmt.Errorf("unknown CSV column '%s' (supported columns: %s)", name, strings.Join(hostCSVColumns, ","))
+		}
+		if _, ok := columnIndexes[name]; ok {
+			return nil, fmt.Errorf("duplicate CSV column '%s'", name)
+		}
+		columnIndexes[name] = i
+	}
+	for _, name := range hostCSVRequiredColumns {
+		if _, ok := columnIndexes[name]; !ok {
+			return nil, fmt.Errorf("missing required CSV column '%s'", name)
+		}
+	}
+
+	var reqs []dto.CreateHostRequest
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row: %w", err)
+		}
+		if len(reqs) == maxRows {
+			return nil, fmt.Errorf("CSV body contains more than %d rows", maxRows)
+		}
+
+		value := func(column string) string {
+			if i, ok := columnIndexes[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		req := dto.CreateHostRequest{
+			HostName:     value("host_name"),
+			Country:      value("country"),
+			City:         value("city"),
+			Address:      value("address"),
+			Port:         value("port"),
+			Protocol:     value("protocol"),
+			Network:      value("network"),
+			PublicKey:    value("public_key"),
+			Flow:         value("flow"),
+			RSID:         value("rsid"),
+			SecurityType: value("security_type"),
+			SNI:          value("sni"),
+			Fingerprint:  value("fingerprint"),
+			Region:       value("region"),
+			Provider:     value("provider"),
+			Notes:        value("notes"),
+		}
+		if isPrivate := value("is_private"); isPrivate != "" {
+			req.IsPrivate, err = strconv.ParseBool(isPrivate)
+			if err != nil {
+				line, _ := reader.FieldPos(columnIndexes["is_private"])
+				return nil, fmt.Errorf("invalid is_private value '%s' on line %d", isPrivate, line)
+			}
+		}
+		if isFreeTier := value("is_free_tier"); isFreeTier != "" {
+			req.IsFreeTier, err = strconv.ParseBool(isFreeTier)
+			if err != nil {
+				line, _ := reader.FieldPos(columnIndexes["is_free_tier"])
+				return nil, fmt.Errorf("invalid is_free_tier value '%s' on line %d", isFreeTier, line)
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// isKnownHostCSVColumn reports whether name is one of hostCSVColumns.
+func isKnownHostCSVColumn(name string) bool {
+	for _, column := range hostCSVColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,41 +1,68 @@
 package handlers
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/http/handlers/dto"
 	"bitback/internal/interfaces"
 	"bitback/internal/models/customTypes"
 	serviceDTO "bitback/internal/services/dto"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"gorm.io/gorm"
 	"log/slog"
-	"math"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	// maxBulkHostImportSize is the maximum number of hosts accepted by a single bulk import request.
+	maxBulkHostImportSize = 500
+	// defaultUptimeWindow is the uptime window used when none is requested.
+	defaultUptimeWindow = 24 * time.Hour
+	// maxUptimeWindow bounds the uptime window to keep the aggregation cheap.
+	maxUptimeWindow = 90 * 24 * time.Hour
 )
 
 // HostHandler handles HTTP requests related to hosts.
 type HostHandler struct {
 	hostService interfaces.HostService
+	cfg         *config.Config
 }
 
 // NewHostHandler creates a new instance of HostHandler.
-func NewHostHandler(hs interfaces.HostService) *HostHandler {
+func NewHostHandler(hs interfaces.HostService, cfg *config.Config) *HostHandler {
 	return &HostHandler{
 		hostService: hs,
+		cfg:         cfg,
 	}
 }
 
 // RegisterRoutes registers the HTTP routes for host-related actions.
-func (h *HostHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /v1/hosts", h.CreateHost)
+func (h *HostHandler) RegisterRoutes(mux RouteRegistrar) {
 	mux.HandleFunc("GET /v1/hosts", h.ListHosts)
+	mux.HandleFunc("GET /v1/hosts/availability", h.GetHostAvailability)
 	mux.HandleFunc("GET /v1/hosts/{hostID}", h.GetHostByID)
-	mux.HandleFunc("PUT /v1/hosts/{hostID}", h.UpdateHost)
-	mux.HandleFunc("DELETE /v1/hosts/{hostID}", h.DeleteHost) // Soft delete.
-	mux.HandleFunc("PATCH /v1/hosts/{hostID}/status", h.UpdateHostOnlineStatus)
+
+	// Mutation routes are restricted to administrators.
+	mux.HandleFunc("POST /v1/hosts", requireAdmin(h.CreateHost))
+	mux.HandleFunc("POST /v1/hosts/bulk", requireAdmin(h.CreateHostsBulk))
+	mux.HandleFunc("GET /v1/hosts/export", requireAdmin(h.ExportHosts))
+	mux.HandleFunc("POST /v1/hosts/import", requireAdmin(h.ImportHosts))
+	mux.HandleFunc("PUT /v1/hosts/{hostID}", requireAdmin(h.UpdateHost))
+	mux.HandleFunc("DELETE /v1/hosts/{hostID}", requireAdmin(h.DeleteHost)) // Soft delete.
+	mux.HandleFunc("POST /v1/hosts/{hostID}/restore", requireAdmin(h.RestoreHost))
+	mux.HandleFunc("PATCH /v1/hosts/{hostID}/status", requireAdmin(h.UpdateHostOnlineStatus))
+	mux.HandleFunc("PATCH /v1/hosts/{hostID}/tier", requireAdmin(h.SetHostTier))
+
+	// Health check history routes, restricted to administrators.
+	mux.HandleFunc("POST /v1/hosts/{hostID}/checks", requireAdmin(h.RecordHostCheck))
+	mux.HandleFunc("GET /v1/hosts/{hostID}/checks", requireAdmin(h.ListHostChecks))
+	mux.HandleFunc("GET /v1/hosts/{hostID}/uptime", requireAdmin(h.GetHostUptime))
+	mux.HandleFunc("GET /v1/hosts/{hostID}/utilization", requireAdmin(h.GetHostUtilization))
+	mux.HandleFunc("GET /v1/reports/providers", requireAdmin(h.GetProvidersReport))
+	mux.HandleFunc("GET /v1/reports/host-availability", requireAdmin(h.GetAvailabilityReport))
 }
 
 // CreateHost handles the request to create a new host.
@@ -50,43 +77,104 @@ func (h *HostHandler) CreateHost(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: Implement request DTO validation.
 
-	// Map the handler DTO to the service layer input DTO.
-	serviceInput := serviceDTO.CreateHostInput{
-		HostName:     req.HostName,
-		Country:      req.Country,
-		City:         req.City,
-		Address:      req.Address,
-		Port:         req.Port,
-		Protocol:     req.Protocol,
-		Network:      req.Network,
-		PublicKey:    req.PublicKey,
-		Flow:         req.Flow,
-		RSID:         req.RSID,
-		SecurityType: req.SecurityType,
-		SNI:          req.SNI,
-		Fingerprint:  req.Fingerprint,
-		IsPrivate:    req.IsPrivate,
-		Region:       req.Region,
-		Provider:     req.Provider,
-	}
-
-	host, err := h.hostService.AddHost(ctx, serviceInput)
+	host, err := h.hostService.AddHost(ctx, toCreateHostInput(req))
 	if err != nil {
 		slog.ErrorContext(ctx, "CreateHost: failed to add host via service", "error", err, "address", req.Address)
-		if strings.Contains(err.Error(), "already exists") {
-			respondWithError(w, http.StatusConflict, err.Error())
-		} else if strings.Contains(err.Error(), "cannot be empty") {
-			respondWithError(w, http.StatusBadRequest, err.Error())
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to add host.")
+		respondWithServiceError(w, err, "Failed to add host.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, toHostResponse(host, true))
+}
+
+// CreateHostsBulk handles the request to create multiple hosts at once.
+// The body is either a JSON array of host objects or, with Content-Type text/csv, a CSV document
+// whose header row names the columns (see hostCSVColumns). At most maxBulkHostImportSize entries are accepted.
+// By default the import is atomic: if any entry is invalid or a duplicate, nothing is created and 422 is returned.
+// With the 'atomic=false' query parameter, valid entries are created while failing ones are skipped.
+// Expected route: POST /api/v1/hosts/bulk
+func (h *HostHandler) CreateHostsBulk(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	atomic := true
+	if atomicStr := r.URL.Query().Get("atomic"); atomicStr != "" {
+		var err error
+		atomic, err = strconv.ParseBool(atomicStr)
+		if err != nil {
+			slog.WarnContext(ctx, "CreateHostsBulk: invalid 'atomic' query parameter", "atomic", atomicStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'atomic' query parameter (expected true or false).")
+			return
 		}
+	}
+
+	var reqs []dto.CreateHostRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		var err error
+		reqs, err = parseHostsCSV(r.Body, maxBulkHostImportSize)
+		if err != nil {
+			slog.WarnContext(ctx, "CreateHostsBulk: failed to parse CSV body", "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid CSV payload: "+err.Error())
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		slog.ErrorContext(ctx, "CreateHostsBulk: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if len(reqs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Request payload must contain at least one host.")
+		return
+	}
+	if len(reqs) > maxBulkHostImportSize {
+		slog.WarnContext(ctx, "CreateHostsBulk: too many entries", "count", len(reqs), "max", maxBulkHostImportSize)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Too many hosts in one request: at most %d are allowed.", maxBulkHostImportSize))
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, toHostResponse(host))
+	inputs := make([]serviceDTO.CreateHostInput, len(reqs))
+	for i, req := range reqs {
+		inputs[i] = toCreateHostInput(req)
+	}
+
+	result, err := h.hostService.AddHosts(ctx, inputs, atomic)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateHostsBulk: failed to add hosts via service", "error", err, "count", len(inputs))
+		respondWithServiceError(w, err, "Failed to add hosts.")
+		return
+	}
+
+	resp := dto.BulkCreateHostsResponse{
+		Results:    make([]dto.BulkHostResultResponse, len(result.Results)),
+		Total:      len(result.Results),
+		Created:    result.Created,
+		Duplicates: result.Duplicates,
+		Invalid:    result.Invalid,
+		Atomic:     atomic,
+		Committed:  result.Committed,
+	}
+	for i, itemResult := range result.Results {
+		resp.Results[i] = dto.BulkHostResultResponse{
+			Index:  itemResult.Index,
+			Status: itemResult.Status,
+			Error:  itemResult.Error,
+		}
+		if itemResult.Host != nil {
+			resp.Results[i].HostID = &itemResult.Host.ID
+		}
+	}
+
+	if !result.Committed {
+		slog.WarnContext(ctx, "CreateHostsBulk: atomic bulk host import rejected", "total", resp.Total, "duplicates", resp.Duplicates, "invalid", resp.Invalid)
+		respondWithJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	slog.InfoContext(ctx, "CreateHostsBulk: bulk host import processed", "total", resp.Total, "created", resp.Created, "duplicates", resp.Duplicates, "invalid", resp.Invalid)
+	respondWithJSON(w, http.StatusOK, resp)
 }
 
 // GetHostByID handles the request to retrieve a host by its ID.
+// The response carries an ETag; requests whose If-None-Match names it get 304 Not Modified.
 func (h *HostHandler) GetHostByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	hostIDStr := r.PathValue("hostID")
@@ -100,40 +188,27 @@ func (h *HostHandler) GetHostByID(w http.ResponseWriter, r *http.Request) {
 	host, err := h.hostService.GetHostByID(ctx, hostID)
 	if err != nil {
 		slog.ErrorContext(ctx, "GetHostByID: failed to get host from service", "error", err, "hostID", hostID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Host not found.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve host.")
-		}
+		respondWithServiceError(w, err, "Failed to retrieve host.")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, toHostResponse(host))
+	respondWithCacheableJSON(w, r, toHostResponse(host, isAdminRequest(ctx)))
 }
 
 // ListHosts handles the request to retrieve a list of hosts with filtering and pagination.
+// Administrators may pass 'include_deleted=true' to also list soft-deleted hosts; the flag is ignored for other callers.
+// Supplying the 'cursor' query parameter switches from page-based to keyset pagination.
+// The response carries an ETag; requests whose If-None-Match names it get 304 Not Modified.
 func (h *HostHandler) ListHosts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	slog.InfoContext(ctx, "ListHosts: received request to list hosts")
 	query := r.URL.Query()
 
-	// Parse pagination parameters.
-	page, err := strconv.Atoi(query.Get("page"))
-	if err != nil || page < 1 {
-		page = 1 // Default to page 1.
-	}
-
-	pageSize, err := strconv.Atoi(query.Get("pageSize"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10 // Default page size.
-	}
-	if pageSize > 100 { // Max page size limit.
-		pageSize = 100
-	}
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointHosts))
 
 	// Prepare service parameters for listing hosts.
 	serviceParams := serviceDTO.ListHostsServiceParams{
-		Page:      page,
-		PageSize:  pageSize,
+		Page:      params.Page,
+		PageSize:  params.PageSize,
 		SortBy:    query.Get("sort_by"),    // E.g., "created_at"
 		SortOrder: query.Get("sort_order"), // E.g., "asc" or "desc"
 	}
@@ -145,6 +220,12 @@ func (h *HostHandler) ListHosts(w http.ResponseWriter, r *http.Request) {
 	if city := query.Get("city"); city != "" {
 		serviceParams.City = &city
 	}
+	if region := query.Get("region"); region != "" {
+		serviceParams.Region = &region
+	}
+	if provider := query.Get("provider"); provider != "" {
+		serviceParams.Provider = &provider
+	}
 	if protocol := query.Get("protocol"); protocol != "" {
 		serviceParams.Protocol = &protocol
 	}
@@ -187,38 +268,105 @@ func (h *HostHandler) ListHosts(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if isFreeTierStr := query.Get("is_free_tier"); isFreeTierStr != "" {
+		isFreeTier, err := strconv.ParseBool(isFreeTierStr)
+		if err == nil {
+			serviceParams.IsFreeTier = &isFreeTier
+		} else {
+			slog.WarnContext(ctx, "ListHosts: invalid 'is_free_tier' query parameter", "is_free_tier_param", isFreeTierStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'is_free_tier' query parameter (must be true or false): %s", isFreeTierStr))
+			return
+		}
+	}
+
+	if includeDeletedStr := query.Get("include_deleted"); includeDeletedStr != "" {
+		includeDeleted, err := strconv.ParseBool(includeDeletedStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListHosts: invalid 'include_deleted' query parameter", "include_deleted_param", includeDeletedStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'include_deleted' query parameter (must be true or false): %s", includeDeletedStr))
+			return
+		}
+		// Deleted hosts are only listed for administrators; the flag is ignored for other callers.
+		if includeDeleted && !isAdminRequest(ctx) {
+			slog.InfoContext(ctx, "ListHosts: ignoring 'include_deleted' for non-admin caller")
+			includeDeleted = false
+		}
+		serviceParams.IncludeDeleted = includeDeleted
+	}
+
+	if query.Has(cursorQueryParam) {
+		h.listHostsAfter(w, r, serviceParams, query.Get(cursorQueryParam))
+		return
+	}
 
 	hostsModels, totalItems, err := h.hostService.ListHosts(ctx, serviceParams)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListHosts: failed to retrieve hosts from service", "error", err, "params", serviceParams)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve hosts list.")
+		respondWithServiceError(w, err, "Failed to retrieve hosts list.")
 		return
 	}
 
+	// Operator notes are only exposed to administrators.
+	includeNotes := isAdminRequest(ctx)
 	hostResponses := make([]dto.HostResponse, len(hostsModels))
 	for i, hModel := range hostsModels {
-		hostResponses[i] = toHostResponse(&hModel)
+		hostResponses[i] = toHostResponse(&hModel, includeNotes)
+	}
+
+	response := newPaginatedResponse(ctx, "ListHosts", "hosts", hostResponses, params, totalItems)
+	slog.InfoContext(ctx, "ListHosts: successfully listed hosts", "count_in_page", len(hostResponses), "total_items", totalItems, "current_page", params.Page)
+	respondWithCacheableJSON(w, r, response)
+}
+
+// listHostsAfter serves ListHosts in keyset pagination mode, used when the 'cursor' query parameter is present.
+// Results are ordered newest first; 'page' and sorting parameters are not supported in this mode.
+func (h *HostHandler) listHostsAfter(w http.ResponseWriter, r *http.Request, serviceParams serviceDTO.ListHostsServiceParams, cursor string) {
+	ctx := r.Context()
+	if serviceParams.SortBy != "" || serviceParams.SortOrder != "" {
+		slog.WarnContext(ctx, "ListHosts: sorting requested together with a cursor", "sort_by", serviceParams.SortBy, "sort_order", serviceParams.SortOrder)
+		respondWithError(w, http.StatusBadRequest, "Sorting parameters cannot be combined with 'cursor'.")
+		return
 	}
 
-	totalPages := 0
-	if totalItems > 0 && pageSize > 0 {
-		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	var after *customTypes.HostListCursor
+	if cursor != "" {
+		createdAt, idStr, err := decodeCursor(cursor)
+		if err != nil {
+			slog.WarnContext(ctx, "ListHosts: invalid cursor", "cursor", cursor, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'cursor' query parameter.")
+			return
+		}
+		id, err := strconv.ParseUint(idStr, 10, 0)
+		if err != nil {
+			slog.WarnContext(ctx, "ListHosts: invalid host ID in cursor", "cursor", cursor, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'cursor' query parameter.")
+			return
+		}
+		after = &customTypes.HostListCursor{CreatedAt: createdAt, ID: uint(id)}
 	}
-	// If requested page is out of bounds but there are items, return an empty list for that page.
-	if page > totalPages && totalPages > 0 {
-		hostResponses = []dto.HostResponse{}
-		slog.WarnContext(ctx, "ListHosts: requested page is out of bounds", "requested_page", page, "total_pages", totalPages)
+
+	hostsModels, hasMore, err := h.hostService.ListHostsAfter(ctx, serviceParams, after)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListHosts: failed to retrieve hosts from service", "error", err, "params", serviceParams)
+		respondWithServiceError(w, err, "Failed to retrieve hosts list.")
+		return
 	}
 
-	response := dto.PaginatedHostsResponse{
-		Hosts:       hostResponses,
-		TotalItems:  totalItems,
-		TotalPages:  totalPages,
-		CurrentPage: page,
-		PageSize:    pageSize,
+	includeNotes := isAdminRequest(ctx)
+	hostResponses := make([]dto.HostResponse, len(hostsModels))
+	for i, hModel := range hostsModels {
+		hostResponses[i] = toHostResponse(&hModel, includeNotes)
 	}
-	slog.InfoContext(ctx, "ListHosts: successfully listed hosts", "count_in_page", len(hostResponses), "total_items", totalItems, "current_page", page)
-	respondWithJSON(w, http.StatusOK, response)
+
+	nextCursor := ""
+	if hasMore && len(hostsModels) > 0 {
+		last := hostsModels[len(hostsModels)-1]
+		nextCursor = encodeCursor(last.CreatedAt, strconv.FormatUint(uint64(last.ID), 10))
+	}
+
+	response := newCursorPaginatedResponse("hosts", hostResponses, serviceParams.PageSize, nextCursor)
+	slog.InfoContext(ctx, "ListHosts: successfully listed hosts after cursor", "count_in_page", len(hostResponses), "has_more", hasMore)
+	respondWithCacheableJSON(w, r, response)
 }
 
 // UpdateHost handles the request to update an existing host.
@@ -256,23 +404,20 @@ func (h *HostHandler) UpdateHost(w http.ResponseWriter, r *http.Request) {
 		SNI:          req.SNI,
 		Fingerprint:  req.Fingerprint,
 		IsPrivate:    req.IsPrivate,
+		IsFreeTier:   req.IsFreeTier,
+		MaxUsers:     req.MaxUsers,
 		Region:       req.Region,
 		Provider:     req.Provider,
+		Notes:        req.Notes,
 	}
 
 	updatedHost, err := h.hostService.UpdateHost(ctx, hostID, serviceInput)
 	if err != nil {
 		slog.ErrorContext(ctx, "UpdateHost: failed to update host via service", "error", err, "hostID", hostID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Host not found.")
-		} else if strings.Contains(err.Error(), "uniqueness constraint") || strings.Contains(err.Error(), "already exists") {
-			respondWithError(w, http.StatusConflict, err.Error())
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to update host.")
-		}
+		respondWithServiceError(w, err, "Failed to update host.")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, toHostResponse(updatedHost))
+	respondWithJSON(w, http.StatusOK, toHostResponse(updatedHost, true))
 }
 
 // DeleteHost handles the request to (soft) delete a host.
@@ -288,17 +433,35 @@ func (h *HostHandler) DeleteHost(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.hostService.RemoveHost(ctx, hostID); err != nil {
 		slog.ErrorContext(ctx, "DeleteHost: failed to remove host via service", "error", err, "hostID", hostID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Host not found.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to remove host.")
-		}
+		respondWithServiceError(w, err, "Failed to remove host.")
 		return
 	}
 	slog.InfoContext(ctx, "DeleteHost: host deleted successfully", "hostID", hostID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RestoreHost handles the request to restore a soft-deleted host.
+// Expected route: POST /api/v1/hosts/{hostID}/restore
+func (h *HostHandler) RestoreHost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "RestoreHost: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	host, err := h.hostService.RestoreHost(ctx, hostID)
+	if err != nil {
+		slog.ErrorContext(ctx, "RestoreHost: failed to restore host via service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to restore host.")
+		return
+	}
+	slog.InfoContext(ctx, "RestoreHost: host restored successfully", "hostID", hostID)
+	respondWithJSON(w, http.StatusOK, toHostResponse(host, true))
+}
+
 // UpdateHostOnlineStatus handles the request to update a host's online status and general status.
 func (h *HostHandler) UpdateHostOnlineStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -332,15 +495,287 @@ func (h *HostHandler) UpdateHostOnlineStatus(w http.ResponseWriter, r *http.Requ
 	updatedHost, err := h.hostService.UpdateHostOnlineStatus(ctx, hostID, serviceInput)
 	if err != nil {
 		slog.ErrorContext(ctx, "UpdateHostOnlineStatus: failed to update host status via service", "error", err, "hostID", hostID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Host not found.")
-		} else if strings.Contains(err.Error(), "invalid host status") { // Specific error from service.
-			respondWithError(w, http.StatusBadRequest, err.Error())
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to update host status.")
-		}
+		respondWithServiceError(w, err, "Failed to update host status.")
 		return
 	}
 	slog.InfoContext(ctx, "UpdateHostOnlineStatus: host status updated successfully", "hostID", hostID, "new_is_online", updatedHost.IsOnline, "new_status", updatedHost.Status)
-	respondWithJSON(w, http.StatusOK, toHostResponse(updatedHost))
+	respondWithJSON(w, http.StatusOK, toHostResponse(updatedHost, true))
+}
+
+// SetHostTier handles the request to move a host between the free and paid tiers.
+// Unlike UpdateHost, it changes nothing but the tier.
+// Expected route: PATCH /api/v1/hosts/{hostID}/tier
+func (h *HostHandler) SetHostTier(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "SetHostTier: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	var req dto.SetHostTierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "SetHostTier: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if req.IsFreeTier == nil {
+		respondWithError(w, http.StatusBadRequest, "The 'is_free_tier' field is required.")
+		return
+	}
+
+	host, err := h.hostService.SetHostTier(ctx, hostID, *req.IsFreeTier)
+	if err != nil {
+		slog.ErrorContext(ctx, "SetHostTier: failed to set host tier via service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to update host tier.")
+		return
+	}
+	slog.InfoContext(ctx, "SetHostTier: host tier set successfully", "hostID", hostID, "isFreeTier", host.IsFreeTier)
+	respondWithJSON(w, http.StatusOK, toHostResponse(host, true))
+}
+
+// RecordHostCheck handles the request to record a health check result for a host.
+// Expected route: POST /api/v1/hosts/{hostID}/checks
+func (h *HostHandler) RecordHostCheck(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "RecordHostCheck: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	var req dto.RecordHostCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "RecordHostCheck: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if req.Status != nil && !req.Status.IsValid() {
+		slog.WarnContext(ctx, "RecordHostCheck: invalid status value provided in request", "status_value", *req.Status)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid status value provided: %s", *req.Status))
+		return
+	}
+	if req.LatencyMs != nil && *req.LatencyMs < 0 {
+		respondWithError(w, http.StatusBadRequest, "Latency must not be negative.")
+		return
+	}
+
+	check, err := h.hostService.RecordHostCheck(ctx, hostID, serviceDTO.HostCheckResult{
+		IsOnline:  req.IsOnline,
+		Status:    req.Status,
+		LatencyMs: req.LatencyMs,
+		Error:     req.Error,
+		CheckedAt: req.CheckedAt,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordHostCheck: failed to record host check via service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to record host check.")
+		return
+	}
+	slog.InfoContext(ctx, "RecordHostCheck: host check recorded successfully", "hostID", hostID, "checkID", check.ID)
+	respondWithJSON(w, http.StatusCreated, toHostCheckResponse(check))
+}
+
+// ListHostChecks handles the request to retrieve the paginated health check history of a host.
+// Expected route: GET /api/v1/hosts/{hostID}/checks
+func (h *HostHandler) ListHostChecks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "ListHostChecks: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointHostChecks))
+
+	checks, totalItems, err := h.hostService.ListHostChecks(ctx, hostID, params.Page, params.PageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListHostChecks: failed to retrieve host checks from service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to retrieve host checks.")
+		return
+	}
+
+	checkResponses := make([]dto.HostCheckResponse, len(checks))
+	for i := range checks {
+		checkResponses[i] = toHostCheckResponse(&checks[i])
+	}
+
+	respondWithJSON(w, http.StatusOK, newPaginatedResponse(ctx, "ListHostChecks", "checks", checkResponses, params, totalItems))
+}
+
+// GetHostUptime handles the request to compute a host's uptime over a time window.
+// Accepts an optional 'window' query parameter as a Go duration (e.g., "24h", "90m"); defaults to 24h.
+// Expected route: GET /api/v1/hosts/{hostID}/uptime
+func (h *HostHandler) GetHostUptime(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetHostUptime: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	window := defaultUptimeWindow
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		window, err = time.ParseDuration(windowStr)
+		if err != nil || window <= 0 || window > maxUptimeWindow {
+			slog.WarnContext(ctx, "GetHostUptime: invalid 'window' query parameter", "window", windowStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'window' query parameter (expected a positive duration up to %s, e.g. 24h): %s", maxUptimeWindow, windowStr))
+			return
+		}
+	}
+
+	uptime, err := h.hostService.GetHostUptime(ctx, hostID, window)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetHostUptime: failed to compute host uptime via service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to compute host uptime.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.HostUptimeResponse{
+		HostID:       uptime.HostID,
+		Window:       window.String(),
+		From:         uptime.From,
+		To:           uptime.To,
+		TotalChecks:  uptime.TotalChecks,
+		OnlineChecks: uptime.OnlineChecks,
+		UptimeRatio:  uptime.UptimeRatio,
+	})
+}
+
+// GetHostUtilization handles the request to report a host's current users against its capacity,
+// together with its online status and latency.
+// Expected route: GET /api/v1/hosts/{hostID}/utilization
+func (h *HostHandler) GetHostUtilization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostIDStr := r.PathValue("hostID")
+	hostID, err := parseUint(hostIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetHostUtilization: invalid host ID format in path", "hostID_str", hostIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid host ID format provided.")
+		return
+	}
+
+	utilization, err := h.hostService.GetHostUtilization(ctx, hostID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetHostUtilization: failed to get host utilization via service", "error", err, "hostID", hostID)
+		respondWithServiceError(w, err, "Failed to get host utilization.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.HostUtilizationResponse{
+		HostID:             utilization.HostID,
+		CurrentUsers:       utilization.CurrentUsers,
+		MaxUsers:           utilization.MaxUsers,
+		UtilizationPercent: utilization.UtilizationPercent,
+		IsOnline:           utilization.IsOnline,
+		Status:             utilization.Status,
+		LatencyMs:          utilization.LatencyMs,
+		LastCheckedAt:      utilization.LastCheckedAt,
+	})
+}
+
+// GetProvidersReport handles the request to report host counts per provider.
+// Supports an optional 'country' query parameter.
+// Expected route: GET /api/v1/reports/providers
+func (h *HostHandler) GetProvidersReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var country *string
+	if countryStr := strings.TrimSpace(r.URL.Query().Get("country")); countryStr != "" {
+		country = &countryStr
+	}
+
+	counts, err := h.hostService.GetProvidersReport(ctx, country)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetProvidersReport: failed to get providers report from service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate providers report.")
+		return
+	}
+
+	response := dto.ProvidersReportResponse{Providers: make([]dto.ProviderHostCountsResponse, len(counts))}
+	if country != nil {
+		response.Country = *country
+	}
+	for i, c := range counts {
+		response.Providers[i] = dto.ProviderHostCountsResponse{
+			Provider: c.Provider,
+			Total:    c.Total,
+			Online:   c.Online,
+			Active:   c.Active,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetAvailabilityReport handles the request to report the hosts available for key generation per country and tier.
+// Expected route: GET /api/v1/reports/host-availability
+func (h *HostHandler) GetAvailabilityReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	report, err := h.hostService.GetAvailabilityReport(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAvailabilityReport: failed to get availability report from service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate host availability report.")
+		return
+	}
+
+	response := dto.HostAvailabilityReportResponse{
+		Countries: make([]dto.CountryAvailabilityResponse, len(report.Countries)),
+		TotalFree: report.TotalFree,
+		TotalPaid: report.TotalPaid,
+	}
+	for i, c := range report.Countries {
+		response.Countries[i] = dto.CountryAvailabilityResponse{
+			Country:   c.Country,
+			FreeCount: c.Free,
+			PaidCount: c.Paid,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetHostAvailability handles the request to list the countries with public hosts available for key generation,
+// e.g. to populate a country picker. The optional 'tier' query parameter ("free" or "paid") restricts
+// the hosts considered.
+// Expected route: GET /api/v1/hosts/availability
+func (h *HostHandler) GetHostAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var isFreeTier *bool
+	switch tier := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tier"))); tier {
+	case "":
+	case "free", "paid":
+		isFree := tier == "free"
+		isFreeTier = &isFree
+	default:
+		slog.WarnContext(ctx, "GetHostAvailability: invalid 'tier' query parameter", "tier", tier)
+		respondWithError(w, http.StatusBadRequest, "Invalid 'tier' query parameter. Must be 'free' or 'paid'.")
+		return
+	}
+
+	countries, err := h.hostService.GetHostAvailability(ctx, isFreeTier)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetHostAvailability: failed to get host availability from service", "error", err)
+		respondWithServiceError(w, err, "Failed to get host availability.")
+		return
+	}
+
+	response := dto.HostAvailabilityResponse{Countries: make([]dto.CountryHostAvailabilityResponse, len(countries))}
+	for i, c := range countries {
+		response.Countries[i] = dto.CountryHostAvailabilityResponse{
+			Country:         c.Country,
+			CityCount:       c.CityCount,
+			OnlineHostCount: c.OnlineHostCount,
+			HasFreeTier:     c.HasFreeTier,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, response)
 }
@@ -0,0 +1,765 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newTestHostHandler returns a HostHandler on svc with the default configuration.
+func newTestHostHandler(svc *fakeHostService) *HostHandler {
+	return NewHostHandler(svc, &config.Config{DefaultPageSize: 10})
+}
+
+func TestHostNotesVisibility(t *testing.T) {
+	const notes = "Reboot via provider panel X."
+	host := models.Host{ID: 7, Address: "de1.example.com", Port: "443", Protocol: "vless", Notes: notes}
+	svc := &fakeHostService{
+		getHostByID: func(context.Context, uint) (*models.Host, error) { return &host, nil },
+		listHosts: func(context.Context, serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+			return []models.Host{host}, 1, nil
+		},
+		listHostsAfter: func(context.Context, serviceDTO.ListHostsServiceParams, *customTypes.HostListCursor) ([]models.Host, bool, error) {
+			return []models.Host{host}, false, nil
+		},
+	}
+	// hostsOf returns the hosts of a single host or list response body.
+	hostsOf := func(t *testing.T, rec *httptest.ResponseRecorder) []map[string]any {
+		body := decodeJSON[map[string]json.RawMessage](t, rec)
+		raw, isList := body["hosts"]
+		if !isList {
+			return []map[string]any{decodeJSON[map[string]any](t, rec)}
+		}
+		var hosts []map[string]any
+		if err := json.Unmarshal(raw, &hosts); err != nil {
+			t.Fatalf("failed to decode hosts %s: %v", raw, err)
+		}
+		return hosts
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		role      customTypes.UserRole // Empty for anonymous callers.
+		wantNotes bool
+	}{
+		{name: "admin gets host", path: "/v1/hosts/7", role: customTypes.RoleAdmin, wantNotes: true},
+		{name: "user gets host", path: "/v1/hosts/7", role: customTypes.RoleUser},
+		{name: "anonymous gets host", path: "/v1/hosts/7"},
+		{name: "admin lists hosts", path: "/v1/hosts", role: customTypes.RoleAdmin, wantNotes: true},
+		{name: "user lists hosts", path: "/v1/hosts", role: customTypes.RoleUser},
+		{name: "anonymous lists hosts", path: "/v1/hosts"},
+		{name: "admin lists hosts by cursor", path: "/v1/hosts?cursor=", role: customTypes.RoleAdmin, wantNotes: true},
+		{name: "anonymous lists hosts by cursor", path: "/v1/hosts?cursor="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.role != "" {
+				req = asPrincipal(req, uuid.New(), tt.role)
+			}
+
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			hosts := hostsOf(t, rec)
+			if len(hosts) != 1 {
+				t.Fatalf("got %d hosts, want 1", len(hosts))
+			}
+			got, hasNotes := hosts[0]["notes"]
+			if hasNotes != tt.wantNotes || (tt.wantNotes && got != notes) {
+				t.Errorf("notes = %v (present %v), want present %v", got, hasNotes, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestListHostsRegionProviderFilters(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		wantRegion   *string
+		wantProvider *string
+	}{
+		{name: "no filters"},
+		{name: "region", query: "?region=eu-central", wantRegion: ptrTo("eu-central")},
+		{name: "provider", query: "?provider=Hetzner", wantProvider: ptrTo("Hetzner")},
+		{name: "region and provider", query: "?region=EU-West&provider=ovh", wantRegion: ptrTo("EU-West"), wantProvider: ptrTo("ovh")},
+		{name: "empty values are ignored", query: "?region=&provider="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got serviceDTO.ListHostsServiceParams
+			svc := &fakeHostService{
+				listHosts: func(_ context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+					got = params
+					return []models.Host{{ID: 1, Region: "eu-central", Provider: "Hetzner"}}, 1, nil
+				},
+			}
+
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if deref(got.Region) != deref(tt.wantRegion) || (got.Region == nil) != (tt.wantRegion == nil) {
+				t.Errorf("service called with region %v, want %v", deref(got.Region), deref(tt.wantRegion))
+			}
+			if deref(got.Provider) != deref(tt.wantProvider) || (got.Provider == nil) != (tt.wantProvider == nil) {
+				t.Errorf("service called with provider %v, want %v", deref(got.Provider), deref(tt.wantProvider))
+			}
+		})
+	}
+}
+
+func TestListHostsCursor(t *testing.T) {
+	createdAt := time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)
+	hostsPage := []models.Host{{ID: 9, CreatedAt: createdAt.Add(time.Hour)}, {ID: 8, CreatedAt: createdAt}}
+
+	tests := []struct {
+		name           string
+		query          string
+		hasMore        bool
+		wantStatus     int
+		wantAfter      *customTypes.HostListCursor
+		wantNextCursor string
+	}{
+		{name: "first page", query: "?cursor=", hasMore: true, wantStatus: http.StatusOK, wantNextCursor: encodeCursor(createdAt, "8")},
+		{name: "next page", query: "?cursor=" + encodeCursor(createdAt.Add(2*time.Hour), "10"), hasMore: true, wantStatus: http.StatusOK,
+			wantAfter: &customTypes.HostListCursor{CreatedAt: createdAt.Add(2 * time.Hour), ID: 10}, wantNextCursor: encodeCursor(createdAt, "8")},
+		{name: "last page", query: "?cursor=" + encodeCursor(createdAt.Add(2*time.Hour), "10"), wantStatus: http.StatusOK,
+			wantAfter: &customTypes.HostListCursor{CreatedAt: createdAt.Add(2 * time.Hour), ID: 10}},
+		{name: "undecodable cursor", query: "?cursor=%25%25", wantStatus: http.StatusBadRequest},
+		{name: "non-numeric host ID", query: "?cursor=" + encodeCursor(createdAt, "abc"), wantStatus: http.StatusBadRequest},
+		{name: "combined with sorting", query: "?cursor=&sort_by=country", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAfter *customTypes.HostListCursor
+			svc := &fakeHostService{
+				listHostsAfter: func(_ context.Context, _ serviceDTO.ListHostsServiceParams, after *customTypes.HostListCursor) ([]models.Host, bool, error) {
+					gotAfter = after
+					return hostsPage, tt.hasMore, nil
+				},
+			}
+
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if (gotAfter == nil) != (tt.wantAfter == nil) || (gotAfter != nil && (gotAfter.ID != tt.wantAfter.ID || !gotAfter.CreatedAt.Equal(tt.wantAfter.CreatedAt))) {
+				t.Errorf("service called after %+v, want %+v", gotAfter, tt.wantAfter)
+			}
+			body := decodeJSON[struct {
+				Hosts      []map[string]any `json:"hosts"`
+				PageSize   int              `json:"page_size"`
+				NextCursor string           `json:"next_cursor"`
+				TotalItems *int64           `json:"total_items"`
+			}](t, rec)
+			if len(body.Hosts) != len(hostsPage) || body.NextCursor != tt.wantNextCursor {
+				t.Errorf("got %d hosts and next_cursor %q, want %d and %q", len(body.Hosts), body.NextCursor, len(hostsPage), tt.wantNextCursor)
+			}
+			if body.TotalItems != nil {
+				t.Errorf("total_items = %d in cursor mode, want it omitted", *body.TotalItems)
+			}
+		})
+	}
+}
+
+func TestGetProvidersReport(t *testing.T) {
+	// Counts of seeded hosts: Hetzner in DE and FI, OVH in DE and FR, and a DE host without a provider.
+	countsByCountry := map[string][]customTypes.ProviderHostCounts{
+		"": {
+			{Provider: "Hetzner", Total: 5, Online: 4, Active: 3},
+			{Provider: "OVH", Total: 3, Online: 2, Active: 2},
+			{Provider: "", Total: 1},
+		},
+		"DE": {
+			{Provider: "Hetzner", Total: 3, Online: 3, Active: 2},
+			{Provider: "OVH", Total: 1, Online: 1, Active: 1},
+			{Provider: "", Total: 1},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		role        customTypes.UserRole
+		wantStatus  int
+		wantCountry string
+	}{
+		{name: "all countries", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "one country", query: "?country=%20DE%20", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantCountry: "DE"},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeHostService{
+				providers: func(_ context.Context, country *string) ([]customTypes.ProviderHostCounts, error) {
+					if got := deref(country); got != tt.wantCountry || (country != nil) != (tt.wantCountry != "") {
+						t.Errorf("service called with country %q, want %q", got, tt.wantCountry)
+					}
+					return countsByCountry[deref(country)], nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/providers"+tt.query, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body := decodeJSON[dto.ProvidersReportResponse](t, rec)
+			if body.Country != tt.wantCountry {
+				t.Errorf("country = %q, want %q", body.Country, tt.wantCountry)
+			}
+			want := countsByCountry[tt.wantCountry]
+			if len(body.Providers) != len(want) {
+				t.Fatalf("got %d providers, want %d", len(body.Providers), len(want))
+			}
+			for i, p := range body.Providers {
+				if p.Provider != want[i].Provider || p.Total != want[i].Total || p.Online != want[i].Online || p.Active != want[i].Active {
+					t.Errorf("provider %d = %+v, want %+v", i, p, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetHostByIDDoneContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantLog    string // Expected in the log; a 500 response must never be logged.
+	}{
+		{name: "canceled", err: fmt.Errorf("could not retrieve host: %w", services.ErrRequestCanceled),
+			wantStatus: statusClientClosedRequest, wantLog: "Request canceled by the client"},
+		{name: "timed out", err: fmt.Errorf("could not retrieve host: %w", services.ErrRequestTimeout),
+			wantStatus: http.StatusServiceUnavailable, wantLog: "code=503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			h := newTestHostHandler(&fakeHostService{
+				getHostByID: func(context.Context, uint) (*models.Host, error) { return nil, tt.err },
+			})
+			rec := serveRoutes(h.RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/hosts/7", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("logs do not contain %q:\n%s", tt.wantLog, logs.String())
+			}
+			if strings.Contains(logs.String(), "code=500") {
+				t.Errorf("logs report a 500 response:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestGetAvailabilityReport(t *testing.T) {
+	report := &serviceDTO.HostAvailabilityReport{
+		Countries: []customTypes.CountryHostAvailability{{Country: "DE", Free: 1, Paid: 2}, {Country: "US", Paid: 1}},
+		TotalFree: 1, TotalPaid: 3,
+	}
+
+	tests := []struct {
+		name       string
+		role       customTypes.UserRole
+		report     *serviceDTO.HostAvailabilityReport
+		wantStatus int
+		want       dto.HostAvailabilityReportResponse
+	}{
+		{name: "report", role: customTypes.RoleAdmin, report: report, wantStatus: http.StatusOK, want: dto.HostAvailabilityReportResponse{
+			Countries: []dto.CountryAvailabilityResponse{{Country: "DE", FreeCount: 1, PaidCount: 2}, {Country: "US", PaidCount: 1}},
+			TotalFree: 1, TotalPaid: 3,
+		}},
+		{name: "no available hosts", role: customTypes.RoleAdmin, report: &serviceDTO.HostAvailabilityReport{}, wantStatus: http.StatusOK,
+			want: dto.HostAvailabilityReportResponse{Countries: []dto.CountryAvailabilityResponse{}}},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeHostService{
+				availability: func(context.Context) (*serviceDTO.HostAvailabilityReport, error) { return tt.report, nil },
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/host-availability", nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			// An empty report lists no countries rather than null.
+			if !strings.Contains(rec.Body.String(), `"countries":[`) {
+				t.Errorf("body %s does not list countries as an array", rec.Body.String())
+			}
+			if got := decodeJSON[dto.HostAvailabilityReportResponse](t, rec); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHostAvailability(t *testing.T) {
+	countries := []customTypes.CountryAvailability{
+		{Country: "DE", CityCount: 2, OnlineHostCount: 3, HasFreeTier: true},
+		{Country: "NL", CityCount: 1, OnlineHostCount: 2},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		countries  []customTypes.CountryAvailability
+		serviceErr error
+		wantStatus int
+		wantTier   *bool
+		want       dto.HostAvailabilityResponse
+	}{
+		{name: "all tiers", countries: countries, wantStatus: http.StatusOK, want: dto.HostAvailabilityResponse{
+			Countries: []dto.CountryHostAvailabilityResponse{
+				{Country: "DE", CityCount: 2, OnlineHostCount: 3, HasFreeTier: true},
+				{Country: "NL", CityCount: 1, OnlineHostCount: 2},
+			},
+		}},
+		{name: "free tier", query: "?tier=free", wantStatus: http.StatusOK, wantTier: ptrTo(true),
+			want: dto.HostAvailabilityResponse{Countries: []dto.CountryHostAvailabilityResponse{}}},
+		{name: "paid tier is case-insensitive", query: "?tier=PAID", wantStatus: http.StatusOK, wantTier: ptrTo(false),
+			want: dto.HostAvailabilityResponse{Countries: []dto.CountryHostAvailabilityResponse{}}},
+		{name: "invalid tier", query: "?tier=gold", wantStatus: http.StatusBadRequest},
+		{name: "service error", serviceErr: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTier *bool
+			svc := &fakeHostService{
+				countries: func(_ context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error) {
+					gotTier = isFreeTier
+					return tt.countries, tt.serviceErr
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/hosts/availability"+tt.query, nil), uuid.New(), customTypes.RoleUser)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(gotTier, tt.wantTier) {
+				t.Errorf("service called with tier %v, want %v", deref(gotTier), deref(tt.wantTier))
+			}
+			if got := decodeJSON[dto.HostAvailabilityResponse](t, rec); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHostUtilization(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		role        customTypes.UserRole
+		utilization *serviceDTO.HostUtilization
+		serviceErr  error
+		wantStatus  int
+		wantBody    string // Fragment the response body must contain.
+	}{
+		{
+			name: "zero capacity", path: "/v1/hosts/1/utilization", role: customTypes.RoleAdmin,
+			utilization: &serviceDTO.HostUtilization{HostID: 1, CurrentUsers: 7, IsOnline: true, Status: customTypes.StatusActive},
+			wantStatus:  http.StatusOK, wantBody: `"utilization_percent":null`,
+		},
+		{
+			name: "partial", path: "/v1/hosts/2/utilization", role: customTypes.RoleAdmin,
+			utilization: &serviceDTO.HostUtilization{HostID: 2, CurrentUsers: 5, MaxUsers: 20, UtilizationPercent: ptrTo(25.0), IsOnline: true, Status: customTypes.StatusActive, LatencyMs: ptrTo(42)},
+			wantStatus:  http.StatusOK, wantBody: `"utilization_percent":25`,
+		},
+		{
+			name: "full", path: "/v1/hosts/3/utilization", role: customTypes.RoleAdmin,
+			utilization: &serviceDTO.HostUtilization{HostID: 3, CurrentUsers: 20, MaxUsers: 20, UtilizationPercent: ptrTo(100.0), Status: customTypes.StatusMaintenance},
+			wantStatus:  http.StatusOK, wantBody: `"utilization_percent":100`,
+		},
+		{name: "unknown host", path: "/v1/hosts/99/utilization", role: customTypes.RoleAdmin, serviceErr: services.ErrNotFound, wantStatus: http.StatusNotFound},
+		{name: "invalid host ID", path: "/v1/hosts/abc/utilization", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", path: "/v1/hosts/1/utilization", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeHostService{
+				utilization: func(context.Context, uint) (*serviceDTO.HostUtilization, error) { return tt.utilization, tt.serviceErr },
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, tt.path, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %s does not contain %s", rec.Body.String(), tt.wantBody)
+			}
+			got := decodeJSON[dto.HostUtilizationResponse](t, rec)
+			want := tt.utilization
+			if got.HostID != want.HostID || got.CurrentUsers != want.CurrentUsers || got.MaxUsers != want.MaxUsers ||
+				got.IsOnline != want.IsOnline || got.Status != want.Status || !reflect.DeepEqual(got.LatencyMs, want.LatencyMs) {
+				t.Errorf("body = %+v, want %+v", got, *want)
+			}
+		})
+	}
+}
+
+func TestExportImportHostsRoundTrip(t *testing.T) {
+	hosts := []models.Host{
+		{ID: 1, HostName: "de-1", Country: "DE", City: "Berlin", Region: "eu-central", Provider: "hetzner", Address: "de1.example.com", Port: "443",
+			Protocol: "vless", Network: "tcp", PublicKey: "pbk", Flow: "xtls-rprx-vision", RSID: "ab", SecurityType: "reality", SNI: "www.example.com",
+			Fingerprint: "chrome", IsFreeTier: true, MaxUsers: 100, Status: customTypes.StatusActive, Notes: "Reboot via provider panel."},
+		{ID: 2, HostName: "us-1", Country: "US", Address: "us1.example.com", Port: "8443", Protocol: "trojan", Network: "ws", IsPrivate: true,
+			Status: customTypes.StatusMaintenance},
+	}
+	var imported []serviceDTO.ImportHostInput
+	svc := &fakeHostService{
+		exportHosts: func(context.Context) ([]models.Host, error) { return hosts, nil },
+		importHosts: func(_ context.Context, inputs []serviceDTO.ImportHostInput) (*serviceDTO.ImportHostsResult, error) {
+			imported = inputs
+			result := &serviceDTO.ImportHostsResult{Committed: true, Unchanged: len(inputs)}
+			for i := range inputs {
+				result.Results = append(result.Results, serviceDTO.ImportHostResult{Index: i, Status: serviceDTO.ImportHostStatusUnchanged, HostID: uint(i + 1)})
+			}
+			return result, nil
+		},
+	}
+	handler := newTestHostHandler(svc)
+	admin := uuid.New()
+
+	exportReq := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/hosts/export", nil), admin, customTypes.RoleAdmin)
+	exportRec := serveRoutes(handler.RegisterRoutes, exportReq)
+	if exportRec.Code != http.StatusOK {
+		t.Fatalf("export status = %d, want %d (body %s)", exportRec.Code, http.StatusOK, exportRec.Body.String())
+	}
+	if cd := exportRec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="hosts-`) || !strings.HasSuffix(cd, `.json"`) {
+		t.Errorf("Content-Disposition = %q, want a JSON attachment", cd)
+	}
+	bundle := decodeJSON[dto.HostBundle](t, exportRec)
+	if bundle.Version != hostBundleVersion || len(bundle.Hosts) != len(hosts) {
+		t.Fatalf("bundle = version %d with %d hosts, want version %d with %d", bundle.Version, len(bundle.Hosts), hostBundleVersion, len(hosts))
+	}
+	// Sensitive fields are part of the backup.
+	if got := bundle.Hosts[0]; got.PublicKey != "pbk" || got.Notes != hosts[0].Notes {
+		t.Errorf("exported entry = %+v, want the public key and notes", got)
+	}
+
+	importReq := asPrincipal(httptest.NewRequest(http.MethodPost, "/v1/hosts/import", bytes.NewReader(exportRec.Body.Bytes())), admin, customTypes.RoleAdmin)
+	importRec := serveRoutes(handler.RegisterRoutes, importReq)
+	if importRec.Code != http.StatusOK {
+		t.Fatalf("import status = %d, want %d (body %s)", importRec.Code, http.StatusOK, importRec.Body.String())
+	}
+	if got := decodeJSON[dto.ImportHostsResponse](t, importRec); got.Total != 2 || got.Unchanged != 2 || !got.Committed {
+		t.Errorf("import response = %+v, want 2 unchanged hosts committed", got)
+	}
+
+	// Every configuration field survives the round trip.
+	want := make([]serviceDTO.ImportHostInput, len(hosts))
+	for i, host := range hosts {
+		want[i] = serviceDTO.ImportHostInput{
+			CreateHostInput: serviceDTO.CreateHostInput{
+				HostName: host.HostName, Country: host.Country, City: host.City, Region: host.Region, Provider: host.Provider,
+				Address: host.Address, Port: host.Port, Protocol: host.Protocol, Network: host.Network,
+				PublicKey: host.PublicKey, Flow: host.Flow, RSID: host.RSID, SecurityType: host.SecurityType, SNI: host.SNI, Fingerprint: host.Fingerprint,
+				IsPrivate: host.IsPrivate, IsFreeTier: host.IsFreeTier, MaxUsers: host.MaxUsers, Notes: host.Notes,
+			},
+			Status: host.Status,
+		}
+	}
+	if !reflect.DeepEqual(imported, want) {
+		t.Errorf("imported inputs = %+v, want %+v", imported, want)
+	}
+}
+
+func TestImportHosts(t *testing.T) {
+	validBundle := `{"version":1,"hosts":[{"address":"de1.example.com","port":"443","protocol":"vless"}]}`
+	rejected := &serviceDTO.ImportHostsResult{
+		Results: []serviceDTO.ImportHostResult{{Index: 0, Status: serviceDTO.ImportHostStatusInvalid, Error: "host port cannot be empty"}},
+		Invalid: 1,
+	}
+
+	tests := []struct {
+		name       string
+		role       customTypes.UserRole
+		body       string
+		result     *serviceDTO.ImportHostsResult
+		err        error
+		wantStatus int
+		wantCalled bool
+	}{
+		{name: "not an admin", role: customTypes.RoleUser, body: validBundle, wantStatus: http.StatusForbidden},
+		{name: "malformed body", role: customTypes.RoleAdmin, body: `{"version":`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported version", role: customTypes.RoleAdmin, body: `{"version":2,"hosts":[]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid entry", role: customTypes.RoleAdmin, body: validBundle, result: rejected, wantStatus: http.StatusUnprocessableEntity, wantCalled: true},
+		{name: "service failure", role: customTypes.RoleAdmin, body: validBundle, err: errors.New("connection reset"), wantStatus: http.StatusInternalServerError, wantCalled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			svc := &fakeHostService{
+				importHosts: func(context.Context, []serviceDTO.ImportHostInput) (*serviceDTO.ImportHostsResult, error) {
+					called = true
+					return tt.result, tt.err
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodPost, "/v1/hosts/import", strings.NewReader(tt.body)), uuid.New(), tt.role)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if called != tt.wantCalled {
+				t.Errorf("service called = %t, want %t", called, tt.wantCalled)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity {
+				got := decodeJSON[dto.ImportHostsResponse](t, rec)
+				if got.Committed || got.Invalid != 1 || len(got.Results) != 1 || got.Results[0].Error == "" || got.Results[0].HostID != nil {
+					t.Errorf("response = %+v, want the rejected entry", got)
+				}
+			}
+		})
+	}
+}
+
+func TestHostFreeTierRequests(t *testing.T) {
+	admin := uuid.New()
+
+	t.Run("create", func(t *testing.T) {
+		var got serviceDTO.CreateHostInput
+		svc := &fakeHostService{
+			addHost: func(_ context.Context, input serviceDTO.CreateHostInput) (*models.Host, error) {
+				got = input
+				return &models.Host{ID: 1, Address: input.Address, Port: input.Port, Protocol: input.Protocol, IsFreeTier: input.IsFreeTier}, nil
+			},
+		}
+		body := `{"address":"free.example.com","port":"443","protocol":"vless","is_free_tier":true}`
+		req := asPrincipal(httptest.NewRequest(http.MethodPost, "/v1/hosts", strings.NewReader(body)), admin, customTypes.RoleAdmin)
+		rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusCreated, rec.Body.String())
+		}
+		if !got.IsFreeTier {
+			t.Error("service called with a paid host, want a free one")
+		}
+		if resp := decodeJSON[dto.HostResponse](t, rec); !resp.IsFreeTier {
+			t.Errorf("response = %+v, want is_free_tier true", resp)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+			want *bool
+		}{
+			{name: "omitted", body: `{"city":"Berlin"}`},
+			{name: "free", body: `{"is_free_tier":true}`, want: ptrTo(true)},
+			{name: "paid", body: `{"is_free_tier":false}`, want: ptrTo(false)},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got serviceDTO.UpdateHostInput
+				svc := &fakeHostService{
+					updateHost: func(_ context.Context, hostID uint, input serviceDTO.UpdateHostInput) (*models.Host, error) {
+						got = input
+						return &models.Host{ID: hostID, IsFreeTier: deref(input.IsFreeTier)}, nil
+					},
+				}
+				req := asPrincipal(httptest.NewRequest(http.MethodPut, "/v1/hosts/1", strings.NewReader(tt.body)), admin, customTypes.RoleAdmin)
+				rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body.String())
+				}
+				if (got.IsFreeTier == nil) != (tt.want == nil) || deref(got.IsFreeTier) != deref(tt.want) {
+					t.Errorf("service called with is_free_tier %v, want %v", got.IsFreeTier, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			query      string
+			wantStatus int
+			want       *bool
+		}{
+			{name: "all tiers", wantStatus: http.StatusOK},
+			{name: "free", query: "?is_free_tier=true", wantStatus: http.StatusOK, want: ptrTo(true)},
+			{name: "paid", query: "?is_free_tier=false", wantStatus: http.StatusOK, want: ptrTo(false)},
+			{name: "invalid", query: "?is_free_tier=both", wantStatus: http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var got *serviceDTO.ListHostsServiceParams
+				svc := &fakeHostService{
+					listHosts: func(_ context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+						got = &params
+						return []models.Host{{ID: 1, IsFreeTier: true}}, 1, nil
+					},
+				}
+				rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+				}
+				if tt.wantStatus != http.StatusOK {
+					if got != nil {
+						t.Error("service was called for an invalid request")
+					}
+					return
+				}
+				if (got.IsFreeTier == nil) != (tt.want == nil) || deref(got.IsFreeTier) != deref(tt.want) {
+					t.Errorf("service called with is_free_tier %v, want %v", got.IsFreeTier, tt.want)
+				}
+				if !strings.Contains(rec.Body.String(), `"is_free_tier":true`) {
+					t.Errorf("body %s does not report the host tier", rec.Body.String())
+				}
+			})
+		}
+	})
+}
+
+func TestSetHostTier(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       customTypes.UserRole
+		path       string
+		body       string
+		wantStatus int
+		wantCall   *bool // Tier the service is called with; nil if it must not be called.
+	}{
+		{name: "to free", role: customTypes.RoleAdmin, path: "/v1/hosts/7/tier", body: `{"is_free_tier":true}`, wantStatus: http.StatusOK, wantCall: ptrTo(true)},
+		{name: "to paid", role: customTypes.RoleAdmin, path: "/v1/hosts/7/tier", body: `{"is_free_tier":false}`, wantStatus: http.StatusOK, wantCall: ptrTo(false)},
+		{name: "unknown host", role: customTypes.RoleAdmin, path: "/v1/hosts/8/tier", body: `{"is_free_tier":true}`, wantStatus: http.StatusNotFound, wantCall: ptrTo(true)},
+		{name: "missing field", role: customTypes.RoleAdmin, path: "/v1/hosts/7/tier", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed body", role: customTypes.RoleAdmin, path: "/v1/hosts/7/tier", body: `{"is_free_tier":"yes"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid host ID", role: customTypes.RoleAdmin, path: "/v1/hosts/abc/tier", body: `{"is_free_tier":true}`, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", role: customTypes.RoleUser, path: "/v1/hosts/7/tier", body: `{"is_free_tier":true}`, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called *bool
+			svc := &fakeHostService{
+				setHostTier: func(_ context.Context, hostID uint, isFreeTier bool) (*models.Host, error) {
+					called = &isFreeTier
+					if hostID != 7 {
+						return nil, fmt.Errorf("host with ID %d not found: %w", hostID, services.ErrNotFound)
+					}
+					return &models.Host{ID: 7, Address: "de1.example.com", IsFreeTier: isFreeTier}, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body)), uuid.New(), tt.role)
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if (called == nil) != (tt.wantCall == nil) || (called != nil && *called != *tt.wantCall) {
+				t.Errorf("service called with %v, want %v", called, tt.wantCall)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := decodeJSON[dto.HostResponse](t, rec); got.ID != 7 || got.IsFreeTier != *tt.wantCall {
+					t.Errorf("response = %+v, want host 7 with is_free_tier %t", got, *tt.wantCall)
+				}
+			}
+		})
+	}
+}
+
+func TestListHostsIncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		wantStatus int
+		wantFlag   bool
+	}{
+		{name: "excluded by default", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "admin includes deleted", query: "?include_deleted=true", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantFlag: true},
+		{name: "admin excludes deleted", query: "?include_deleted=false", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "ignored for users", query: "?include_deleted=true", role: customTypes.RoleUser, wantStatus: http.StatusOK},
+		{name: "invalid flag", query: "?include_deleted=yes-please", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *serviceDTO.ListHostsServiceParams
+			svc := &fakeHostService{
+				listHosts: func(_ context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+					got = &params
+					hosts := []models.Host{{ID: 1}}
+					if params.IncludeDeleted {
+						hosts = append(hosts, models.Host{ID: 2, DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true}})
+					}
+					return hosts, int64(len(hosts)), nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestHostHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got.IncludeDeleted != tt.wantFlag {
+				t.Errorf("service called with IncludeDeleted = %t, want %t", got.IncludeDeleted, tt.wantFlag)
+			}
+			body := decodeJSON[struct {
+				Hosts []dto.HostResponse `json:"hosts"`
+			}](t, rec)
+			var deleted []uint
+			for _, host := range body.Hosts {
+				if host.DeletedAt != nil {
+					if !host.DeletedAt.Equal(deletedAt) {
+						t.Errorf("host %d deleted_at = %v, want %v", host.ID, host.DeletedAt, deletedAt)
+					}
+					deleted = append(deleted, host.ID)
+				}
+			}
+			if (len(deleted) == 1) != tt.wantFlag || len(body.Hosts) != len(deleted)+1 {
+				t.Errorf("response lists hosts %+v with deleted %v, want the deleted host only when included", body.Hosts, deleted)
+			}
+		})
+	}
+}
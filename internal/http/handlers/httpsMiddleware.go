@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// forwardedProtoHeader is the header through which the TLS-terminating proxy passes the original request scheme.
+const forwardedProtoHeader = "X-Forwarded-Proto"
+
+// EnforceHTTPS returns a middleware that handles requests which reached the proxy over plain HTTP,
+// as reported by the X-Forwarded-Proto header. Depending on mode, such requests are redirected
+// to the HTTPS equivalent URL (301) or rejected (400). Requests without the header are passed through,
+// as they did not come through the proxy.
+func EnforceHTTPS(mode string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if mode != config.HTTPSEnforcementRedirect && mode != config.HTTPSEnforcementReject {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proto := strings.ToLower(strings.TrimSpace(r.Header.Get(forwardedProtoHeader)))
+			if proto != "http" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if mode == config.HTTPSEnforcementRedirect {
+				target := "https://" + r.Host + r.URL.RequestURI()
+				slog.InfoContext(r.Context(), "EnforceHTTPS: redirecting plain HTTP request", "method", r.Method, "target", target)
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+
+			slog.WarnContext(r.Context(), "EnforceHTTPS: rejecting plain HTTP request", "method", r.Method, "path", r.URL.Path)
+			respondWithError(w, http.StatusBadRequest, "HTTPS is required.")
+		})
+	}
+}
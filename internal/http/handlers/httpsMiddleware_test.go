@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnforceHTTPS(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		proto        string // Value of X-Forwarded-Proto; empty if the header is not sent.
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "redirect http", mode: config.HTTPSEnforcementRedirect, proto: "http", wantStatus: http.StatusMovedPermanently, wantLocation: "https://api.example.com/v1/plans?page=2"},
+		{name: "redirect upper case http", mode: config.HTTPSEnforcementRedirect, proto: " HTTP ", wantStatus: http.StatusMovedPermanently, wantLocation: "https://api.example.com/v1/plans?page=2"},
+		{name: "redirect passes https", mode: config.HTTPSEnforcementRedirect, proto: "https", wantStatus: http.StatusOK},
+		{name: "reject http", mode: config.HTTPSEnforcementReject, proto: "http", wantStatus: http.StatusBadRequest},
+		{name: "reject passes https", mode: config.HTTPSEnforcementReject, proto: "https", wantStatus: http.StatusOK},
+		{name: "no header passes", mode: config.HTTPSEnforcementReject, wantStatus: http.StatusOK},
+		{name: "off passes http", mode: config.HTTPSEnforcementOff, proto: "http", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com/v1/plans?page=2", nil)
+			if tt.proto != "" {
+				req.Header.Set(forwardedProtoHeader, tt.proto)
+			}
+
+			rec := httptest.NewRecorder()
+			EnforceHTTPS(tt.mode)(next).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -1,40 +1,175 @@
 package handlers
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/http/handlers/dto"
 	"bitback/internal/interfaces"
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// defaultKeyRateWindow is the key generation rate window used when none is requested.
+	defaultKeyRateWindow = time.Hour
+	// maxKeyRateWindow bounds the key generation rate window to keep the aggregation cheap.
+	maxKeyRateWindow = 30 * 24 * time.Hour
+	// maxKeyRateBuckets bounds the number of buckets in a key generation rate report.
+	maxKeyRateBuckets = 1000
+)
+
 // KeyHandler handles HTTP requests related to VLESS key generation.
 type KeyHandler struct {
 	keyManagerService interfaces.KeyService
+	cfg               *config.Config
 }
 
 // NewKeyHandler creates a new instance of KeyHandler.
-// It takes a KeyService as a dependency.
-func NewKeyHandler(kmService interfaces.KeyService) *KeyHandler {
+// It takes a KeyService and the application configuration as dependencies.
+func NewKeyHandler(kmService interfaces.KeyService, cfg *config.Config) *KeyHandler {
 	return &KeyHandler{
 		keyManagerService: kmService,
+		cfg:               cfg,
 	}
 }
 
 // RegisterRoutes registers the HTTP routes for the KeyHandler.
-func (h *KeyHandler) RegisterRoutes(mux *http.ServeMux) {
+func (h *KeyHandler) RegisterRoutes(mux RouteRegistrar) {
 	// Route for generating a VLESS key for a specific user.
-	// Expects userID as a path parameter and optional 'remarks' & 'country' as query parameters.
+	// Expects userID as a path parameter and optional 'remarks' (or 'remarks_template'), 'country', 'network'
+	// & 'security' as query parameters.
 	mux.HandleFunc("GET /v1/users/{userID}/vless-key", h.GenerateUserVlessKey)
+	// Route for generating a VLESS key for a specific user and returning its decoded components as JSON.
+	// Accepts the same query parameters as the vless-key route.
+	mux.HandleFunc("GET /v1/users/{userID}/vless-key/config", h.GenerateUserVlessConfig)
+	// Route for generating a VLESS key for a specific user and returning it as a PNG QR code.
+	// Accepts the same query parameters as the vless-key route plus an optional 'size' in pixels.
+	mux.HandleFunc("GET /v1/users/{userID}/vless-key/qr", h.GenerateUserVlessKeyQR)
+	// Route for generating a trojan key for a specific user on a trojan host.
+	// Accepts the same query parameters as the vless-key route. Restricted to that user and administrators.
+	mux.HandleFunc("GET /v1/users/{userID}/trojan-key", requireSelfOrAdmin(h.GenerateUserTrojanKey))
+	// Route for generating one VLESS key per available country for a specific user.
+	// Accepts optional 'remarks' (or 'remarks_template') and a comma-separated 'countries' filter as query parameters.
+	// Restricted to that user and administrators.
+	mux.HandleFunc("GET /v1/users/{userID}/vless-keys", requireSelfOrAdmin(h.GenerateUserVlessKeys))
+	// Route for a user's subscription feed: the keys of every eligible host, base64-encoded for VPN clients.
+	// Accepts optional 'remarks' (or 'remarks_template') as query parameters. Restricted to that user and administrators.
+	mux.HandleFunc("GET /v1/users/{userID}/subscription.txt", requireSelfOrAdmin(h.GetUserSubscriptionFeed))
+	// Route for re-sending the most recently issued VLESS key for a specific user. Restricted to that user and administrators.
+	mux.HandleFunc("GET /v1/users/{userID}/current-key", requireSelfOrAdmin(h.GetCurrentUserVlessKey))
+	// Route for moving a user's key to another host, e.g. off a degraded one. Restricted to administrators.
+	mux.HandleFunc("POST /v1/users/{userID}/reassign-host", requireAdmin(h.ReassignUserHost))
 	// Route for generating a VLESS key for a free user.
-	// Expects optional 'remarks' & 'country' as query parameters.
+	// Expects optional 'remarks' (or 'remarks_template'), 'country', 'network' & 'security' as query parameters.
 	mux.HandleFunc("GET /v1/key/free", h.GenerateFreeVlessKey)
+	// Route for generating a VLESS key for a free user and returning it as a PNG QR code.
+	// Accepts the same query parameters as the free key route plus an optional 'size' in pixels.
+	mux.HandleFunc("GET /v1/key/free/qr", h.GenerateFreeVlessKeyQR)
+	// Route for generating many free VLESS keys at once, e.g. for seeding or load testing. Restricted to administrators.
+	// Expects 'count' and optional 'remarks' (or 'remarks_template') & 'country' as query parameters.
+	mux.HandleFunc("POST /v1/keys/free/batch", requireAdmin(h.GenerateFreeVlessKeyBatch))
+	// Route for reporting how many keys were generated over a time window. Restricted to administrators.
+	// Accepts optional 'window' and 'bucket' query parameters as Go durations.
+	mux.HandleFunc("GET /v1/reports/key-generation-rate", requireAdmin(h.GetKeyGenerationRate))
+	mux.HandleFunc("GET /v1/reports/conversion-funnel", requireAdmin(h.GetConversionFunnel))
+}
+
+// remarksFromQuery returns the remarks requested for a key: the 'remarks_template' query parameter if set,
+// otherwise 'remarks', otherwise the given default. Placeholders such as {country} or {city} in either parameter
+// are expanded from the selected host's metadata when the key is built.
+// The requested remarks are sanitized with sanitizeRemarks; an error is returned if they are too long.
+func (h *KeyHandler) remarksFromQuery(r *http.Request, defaultRemarks string) (string, error) {
+	query := r.URL.Query()
+	remarks := query.Get("remarks_template")
+	if remarks == "" {
+		remarks = query.Get("remarks")
+	}
+	remarks, err := h.sanitizeRemarks(remarks)
+	if err != nil {
+		return "", err
+	}
+	if remarks == "" {
+		return defaultRemarks, nil
+	}
+	return remarks, nil
+}
+
+// sanitizeRemarks strips control characters and '#', which would break the key's URL fragment, and surrounding
+// whitespace from remarks. It returns an error if the result exceeds the configured maximum length.
+func (h *KeyHandler) sanitizeRemarks(remarks string) (string, error) {
+	remarks = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '#' {
+			return -1
+		}
+		return r
+	}, remarks))
+	if maxLength := h.cfg.MaxRemarksLength; maxLength > 0 && utf8.RuneCountInString(remarks) > maxLength {
+		return "", fmt.Errorf("remarks must be at most %d characters long", maxLength)
+	}
+	return remarks, nil
+}
+
+// allowFreeFallbackFromQuery returns whether a subscribed user may be served a free host when no paid host
+// is available: the 'allow_free_fallback' query parameter if set, otherwise the configured default.
+func (h *KeyHandler) allowFreeFallbackFromQuery(r *http.Request) (bool, error) {
+	allowStr := r.URL.Query().Get("allow_free_fallback")
+	if allowStr == "" {
+		return h.cfg.AllowFreeFallback, nil
+	}
+	allow, err := strconv.ParseBool(allowStr)
+	if err != nil {
+		return false, errors.New("invalid 'allow_free_fallback' query parameter (expected true or false)")
+	}
+	return allow, nil
+}
+
+// hostPreferencesFromQuery returns the host preferences of a key request: the optional 'country', 'network'
+// (e.g. tcp, ws or grpc), 'security' (e.g. none, tls or reality) and 'address_family' (ipv4, ipv6 or any,
+// the default) query parameters.
+// A transport preference is relaxed after the country: the exact match is tried first, then the requested
+// country with any transport, then the requested transport in any country. An address family preference is
+// relaxed last, falling back to hosts of any family.
+func hostPreferencesFromQuery(r *http.Request) (serviceDTO.HostPreferences, error) {
+	query := r.URL.Query()
+	prefs := serviceDTO.HostPreferences{
+		Country:      strings.TrimSpace(query.Get("country")),
+		Network:      strings.ToLower(strings.TrimSpace(query.Get("network"))),
+		SecurityType: strings.ToLower(strings.TrimSpace(query.Get("security"))),
+	}
+	switch family := customTypes.AddressFamily(strings.ToLower(strings.TrimSpace(query.Get("address_family")))); family {
+	case customTypes.AddressFamilyIPv4, customTypes.AddressFamilyIPv6:
+		prefs.AddressFamily = family
+	case "", "any":
+	default:
+		return serviceDTO.HostPreferences{}, errors.New("invalid 'address_family' query parameter (expected ipv4, ipv6 or any)")
+	}
+	return prefs, nil
+}
+
+// toKeyHostResponse converts the attributes of the host a key was issued on to their response DTO.
+func toKeyHostResponse(host serviceDTO.KeyHost) *dto.KeyHostResponse {
+	return &dto.KeyHostResponse{
+		Country:       host.Country,
+		Network:       host.Network,
+		SecurityType:  host.SecurityType,
+		AddressFamily: string(host.AddressFamily),
+	}
 }
 
 // GenerateUserVlessKey handles the request to generate a VLESS key for a specified user.
-// It extracts the userID from the path and optional remarks & country from query parameters.
+// It extracts the userID from the path and optional remarks and host preferences from query parameters.
 func (h *KeyHandler) GenerateUserVlessKey(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -46,23 +181,33 @@ func (h *KeyHandler) GenerateUserVlessKey(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Retrieve 'remarks' from query parameters; use a default if not provided.
-	remarks := r.URL.Query().Get("remarks")
-	if remarks == "" {
-		remarks = "BittenVPN" // Default remarks
+	// Retrieve 'remarks_template' or 'remarks' from query parameters; use a default if neither is provided.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKey: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Retrieve 'country' from query parameters.
-	countryQuery := r.URL.Query().Get("country")
-	var countryPtr *string
-	if countryQuery != "" {
-		countryPtr = &countryQuery
+	// Retrieve the 'country', 'network', 'security' and 'address_family' host preferences from query parameters.
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKey: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	slog.InfoContext(ctx, "GenerateUserVlessKey: request received", "userID", userID, "remarks", remarks, "country", countryQuery)
+	slog.InfoContext(ctx, "GenerateUserVlessKey: request received", "userID", userID, "remarks", remarks, "country", prefs.Country, "network", prefs.Network, "security", prefs.SecurityType)
 
 	// Call the service to generate the VLESS key.
-	result, err := h.keyManagerService.GenerateVlessKeyForUser(ctx, userID, remarks, countryPtr)
+	allowFreeFallback, err := h.allowFreeFallbackFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKey: invalid 'allow_free_fallback' query parameter", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.keyManagerService.GenerateVlessKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
 	if err != nil {
 		slog.ErrorContext(ctx, "GenerateUserVlessKey: failed to generate VLESS key via service", "userID", userID, "error", err)
 		if strings.Contains(err.Error(), "not found") { // User not found
@@ -81,32 +226,325 @@ func (h *KeyHandler) GenerateUserVlessKey(w http.ResponseWriter, r *http.Request
 		UserID:                userID.String(),
 		Remarks:               remarks,
 		HasActiveSubscription: &result.HasActiveSubscription,
+		Host:                  toKeyHostResponse(result.Host),
+		ServedTier:            result.ServedTier,
+		Degraded:              result.Degraded,
+		ExpiresAt:             result.ExpiresAt,
 	}
 	slog.InfoContext(ctx, "GenerateUserVlessKey: VLESS key generated successfully", "userID", userID, "hasActiveSubscription", result.HasActiveSubscription)
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// GenerateUserTrojanKey handles the request to generate a trojan key for a specified user.
+// It accepts the same query parameters as GenerateUserVlessKey; only hosts whose protocol is trojan are used.
+// Expected route: GET /api/v1/users/{userID}/trojan-key
+func (h *KeyHandler) GenerateUserTrojanKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserTrojanKey: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserTrojanKey: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserTrojanKey: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	allowFreeFallback, err := h.allowFreeFallbackFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserTrojanKey: invalid 'allow_free_fallback' query parameter", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.keyManagerService.GenerateTrojanKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateUserTrojanKey: failed to generate trojan key via service", "userID", userID, "error", err)
+		respondWithServiceError(w, err, "Failed to generate trojan key.")
+		return
+	}
+
+	response := dto.TrojanKeyResponse{
+		TrojanKey:             result.TrojanKey,
+		UserID:                userID.String(),
+		Remarks:               remarks,
+		HasActiveSubscription: result.HasActiveSubscription,
+		Host:                  toKeyHostResponse(result.Host),
+		ServedTier:            result.ServedTier,
+		Degraded:              result.Degraded,
+		ExpiresAt:             result.ExpiresAt,
+	}
+	slog.InfoContext(ctx, "GenerateUserTrojanKey: trojan key generated successfully", "userID", userID, "hasActiveSubscription", result.HasActiveSubscription)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GenerateUserVlessKeys handles the request to generate one VLESS key per country for a specified user,
+// each on a representative online host of the user's tier.
+// Accepts an optional comma-separated 'countries' query parameter (e.g., "NL,DE,US") and the usual remarks parameters.
+// Expected route: GET /api/v1/users/{userID}/vless-keys
+func (h *KeyHandler) GenerateUserVlessKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeys: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeys: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var countries []string
+	for _, country := range strings.Split(r.URL.Query().Get("countries"), ",") {
+		if country = strings.TrimSpace(country); country != "" {
+			countries = append(countries, country)
+		}
+	}
+	if len(countries) > h.cfg.MaxKeyCountries {
+		slog.WarnContext(ctx, "GenerateUserVlessKeys: too many countries requested", "count", len(countries), "max", h.cfg.MaxKeyCountries)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Too many countries requested: at most %d are allowed.", h.cfg.MaxKeyCountries))
+		return
+	}
+
+	slog.InfoContext(ctx, "GenerateUserVlessKeys: request received", "userID", userID, "remarks", remarks, "countries", countries)
+
+	result, err := h.keyManagerService.GenerateVlessKeysForUser(ctx, userID, remarks, countries)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateUserVlessKeys: failed to generate VLESS keys via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") { // User not found
+			respondWithError(w, http.StatusNotFound, err.Error())
+		} else if strings.Contains(err.Error(), "no active hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to generate keys: No active hosts are currently available for your criteria.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate VLESS keys.")
+		}
+		return
+	}
+
+	response := dto.MultiCountryKeysResponse{
+		UserID:                userID.String(),
+		Keys:                  make([]dto.CountryKeyResponse, len(result.Keys)),
+		Count:                 len(result.Keys),
+		Remarks:               remarks,
+		HasActiveSubscription: result.HasActiveSubscription,
+		ExpiresAt:             result.ExpiresAt,
+	}
+	for i, key := range result.Keys {
+		response.Keys[i] = dto.CountryKeyResponse{VlessKey: key.VlessKey, Host: *toKeyHostResponse(key.Host)}
+	}
+	slog.InfoContext(ctx, "GenerateUserVlessKeys: VLESS keys generated successfully", "userID", userID, "count", response.Count)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GenerateUserVlessConfig handles the request to generate a VLESS key for a specified user and return
+// its structured components instead of only the URL. Host selection is the same as for GenerateUserVlessKey.
+func (h *KeyHandler) GenerateUserVlessConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessConfig: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	// Retrieve 'remarks_template' or 'remarks' from query parameters; use a default if neither is provided.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessConfig: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Retrieve the 'country', 'network', 'security' and 'address_family' host preferences from query parameters.
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessConfig: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.InfoContext(ctx, "GenerateUserVlessConfig: request received", "userID", userID, "remarks", remarks, "country", prefs.Country, "network", prefs.Network, "security", prefs.SecurityType)
+
+	allowFreeFallback, err := h.allowFreeFallbackFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessConfig: invalid 'allow_free_fallback' query parameter", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.keyManagerService.GenerateVlessKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateUserVlessConfig: failed to generate VLESS key via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") { // User not found
+			respondWithError(w, http.StatusNotFound, err.Error())
+		} else if strings.Contains(err.Error(), "no active hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to generate key: No active hosts are currently available for your criteria.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate VLESS key.")
+		}
+		return
+	}
+
+	response := dto.VlessConfigResponse{
+		Country:  result.Host.Country,
+		Address:  result.Config.Address,
+		Port:     result.Config.Port,
+		UUID:     result.Config.UUID,
+		Network:  result.Config.Network,
+		Security: result.Config.Security,
+		SNI:      result.Config.SNI,
+		FP:       result.Config.Fingerprint,
+		PBK:      result.Config.PublicKey,
+		SID:      result.Config.ShortID,
+		Flow:     result.Config.Flow,
+		Remarks:  result.Config.Remarks,
+		VlessKey: result.VlessKey,
+
+		ServedTier: result.ServedTier,
+		Degraded:   result.Degraded,
+	}
+	slog.InfoContext(ctx, "GenerateUserVlessConfig: VLESS config generated successfully", "userID", userID)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetCurrentUserVlessKey handles the request to re-send the most recently issued VLESS key for a user.
+// The key is reconstructed from the stored assignment; no new host is selected.
+func (h *KeyHandler) GetCurrentUserVlessKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetCurrentUserVlessKey: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	result, err := h.keyManagerService.GetCurrentVlessKeyForUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetCurrentUserVlessKey: failed to get current VLESS key via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "No active key found for this user.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve current VLESS key.")
+		}
+		return
+	}
+
+	response := dto.VlessKeyResponse{
+		VlessKey: result.VlessKey,
+		UserID:   userID.String(),
+		Remarks:  result.Remarks,
+	}
+	slog.InfoContext(ctx, "GetCurrentUserVlessKey: current VLESS key returned successfully", "userID", userID, "hostID", result.HostID)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ReassignUserHost handles the request to revoke a user's current key and issue a new one on another host.
+// The body is optional; without a host_id, a host of the user's tier is selected automatically.
+func (h *KeyHandler) ReassignUserHost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "ReassignUserHost: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	var req dto.ReassignHostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		slog.WarnContext(ctx, "ReassignUserHost: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if req.Remarks != nil {
+		remarks, err := h.sanitizeRemarks(*req.Remarks)
+		if err != nil {
+			slog.WarnContext(ctx, "ReassignUserHost: invalid remarks", "error", err)
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Remarks = &remarks
+	}
+
+	result, err := h.keyManagerService.ReassignUserHost(ctx, userID, serviceDTO.ReassignHostInput{
+		TargetHostID: req.HostID,
+		Country:      req.Country,
+		Remarks:      req.Remarks,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "ReassignUserHost: failed to reassign user via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, err.Error())
+		} else if strings.Contains(err.Error(), "invalid target host") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else if strings.Contains(err.Error(), "no active hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to reassign user: No other active hosts are currently available.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to reassign user to another host.")
+		}
+		return
+	}
+
+	slog.InfoContext(ctx, "ReassignUserHost: user reassigned successfully", "userID", userID, "hostID", result.HostID)
+	respondWithJSON(w, http.StatusOK, dto.ReassignHostResponse{
+		VlessKey:       result.VlessKey,
+		UserID:         userID.String(),
+		Remarks:        result.Remarks,
+		HostID:         result.HostID,
+		PreviousHostID: result.PreviousHostID,
+		RevokedCount:   result.RevokedCount,
+	})
+}
+
 // GenerateFreeVlessKey handles the request to generate a VLESS key for a free user.
 func (h *KeyHandler) GenerateFreeVlessKey(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Retrieve 'remarks' from query parameters; use a default if not provided.
-	remarks := r.URL.Query().Get("remarks")
-	if remarks == "" {
-		remarks = "BittenVPN-Free" // Default remarks for free key
+	// Retrieve 'remarks_template' or 'remarks' from query parameters; use a default if neither is provided.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyFreeDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKey: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Retrieve 'country' from query parameters.
-	countryQuery := r.URL.Query().Get("country")
-	var countryPtr *string
-	if countryQuery != "" {
-		countryPtr = &countryQuery
+	// Retrieve the 'country', 'network', 'security' and 'address_family' host preferences from query parameters.
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKey: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	slog.InfoContext(ctx, "GenerateFreeVlessKey: request received", "remarks", remarks, "country", countryQuery)
+	slog.InfoContext(ctx, "GenerateFreeVlessKey: request received", "remarks", remarks, "country", prefs.Country, "network", prefs.Network, "security", prefs.SecurityType)
 
 	// Call the service to generate the VLESS key.
-	vlessKey, err := h.keyManagerService.GenerateFreeVlessKey(ctx, remarks, countryPtr)
+	result, err := h.keyManagerService.GenerateFreeVlessKey(ctx, remarks, prefs)
 	if err != nil {
 		slog.ErrorContext(ctx, "GenerateFreeVlessKey: failed to generate VLESS key via service", "error", err)
 		if strings.Contains(err.Error(), "no active free hosts available") {
@@ -121,9 +559,156 @@ func (h *KeyHandler) GenerateFreeVlessKey(w http.ResponseWriter, r *http.Request
 	// UserID is omitted as this key uses a predefined generic user ID.
 	// HasActiveSubscription is not applicable here.
 	response := dto.VlessKeyResponse{
-		VlessKey: vlessKey,
-		Remarks:  remarks,
+		VlessKey:  result.VlessKey,
+		Remarks:   remarks,
+		Host:      toKeyHostResponse(result.Host),
+		ExpiresAt: result.ExpiresAt,
 	}
 	slog.InfoContext(ctx, "GenerateFreeVlessKey: VLESS key generated successfully")
 	respondWithJSON(w, http.StatusOK, response)
 }
+
+// GenerateFreeVlessKeyBatch handles the request to generate a batch of free VLESS keys.
+func (h *KeyHandler) GenerateFreeVlessKeyBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	countStr := query.Get("count")
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKeyBatch: invalid count", "count_str", countStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Query parameter 'count' must be a positive integer.")
+		return
+	}
+
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyFreeDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKeyBatch: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	countryQuery := query.Get("country")
+	var countryPtr *string
+	if countryQuery != "" {
+		countryPtr = &countryQuery
+	}
+
+	slog.InfoContext(ctx, "GenerateFreeVlessKeyBatch: request received", "count", count, "remarks", remarks, "country", countryQuery)
+
+	keys, err := h.keyManagerService.GenerateFreeVlessKeys(ctx, count, remarks, countryPtr)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateFreeVlessKeyBatch: failed to generate VLESS keys via service", "count", count, "error", err)
+		if strings.Contains(err.Error(), "invalid count") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else if strings.Contains(err.Error(), "no active free hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to generate keys: No active free hosts are currently available.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate VLESS keys.")
+		}
+		return
+	}
+
+	response := dto.FreeKeyBatchResponse{
+		Keys:    make([]dto.FreeKeyResponse, len(keys)),
+		Count:   len(keys),
+		Remarks: remarks,
+	}
+	hosts := make(map[uint]struct{})
+	for i, key := range keys {
+		response.Keys[i] = dto.FreeKeyResponse{VlessKey: key.VlessKey, HostID: key.HostID}
+		hosts[key.HostID] = struct{}{}
+	}
+	response.Hosts = len(hosts)
+
+	slog.InfoContext(ctx, "GenerateFreeVlessKeyBatch: VLESS keys generated successfully", "count", response.Count, "hosts", response.Hosts)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetKeyGenerationRate handles the request to report the number of free and user keys generated over a time window.
+// Accepts optional 'window' (default 1h) and 'bucket' query parameters as Go durations. The bucket defaults to a
+// twelfth of the window, but at least one minute.
+// Expected route: GET /api/v1/reports/key-generation-rate
+func (h *KeyHandler) GetKeyGenerationRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	window := defaultKeyRateWindow
+	if windowStr := query.Get("window"); windowStr != "" {
+		var err error
+		window, err = time.ParseDuration(windowStr)
+		if err != nil || window <= 0 || window > maxKeyRateWindow {
+			slog.WarnContext(ctx, "GetKeyGenerationRate: invalid 'window' query parameter", "window", windowStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'window' query parameter (expected a positive duration up to %s, e.g. 1h): %s", maxKeyRateWindow, windowStr))
+			return
+		}
+	}
+
+	bucket := max(window/12, time.Minute)
+	if bucketStr := query.Get("bucket"); bucketStr != "" {
+		var err error
+		bucket, err = time.ParseDuration(bucketStr)
+		if err != nil || bucket < time.Minute {
+			slog.WarnContext(ctx, "GetKeyGenerationRate: invalid 'bucket' query parameter", "bucket", bucketStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'bucket' query parameter (expected a duration of at least 1m, e.g. 5m): %s", bucketStr))
+			return
+		}
+	}
+	bucket = min(bucket, window)
+	if (window+bucket-1)/bucket > maxKeyRateBuckets {
+		slog.WarnContext(ctx, "GetKeyGenerationRate: too many buckets requested", "window", window.String(), "bucket", bucket.String())
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Too many buckets: the window may span at most %d buckets.", maxKeyRateBuckets))
+		return
+	}
+
+	rate, err := h.keyManagerService.GetKeyGenerationRate(ctx, window, bucket)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetKeyGenerationRate: failed to compute key generation rate via service", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to compute key generation rate.")
+		return
+	}
+
+	response := dto.KeyGenerationRateResponse{
+		Window:    window.String(),
+		Bucket:    rate.Bucket.String(),
+		From:      rate.From,
+		To:        rate.To,
+		Buckets:   make([]dto.KeyGenerationBucketResponse, len(rate.Buckets)),
+		TotalFree: rate.TotalFree,
+		TotalUser: rate.TotalUser,
+	}
+	for i, counts := range rate.Buckets {
+		response.Buckets[i] = dto.KeyGenerationBucketResponse{Start: counts.Start, FreeKeys: counts.FreeKeys, UserKeys: counts.UserKeys}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetConversionFunnel handles the request to report how many users issued a free-tier key went on to subscribe
+// and pay. Accepts optional 'from' and 'to' query parameters (RFC 3339 or YYYY-MM-DD); defaults to the last 30 days.
+// Expected route: GET /api/v1/reports/conversion-funnel
+func (h *KeyHandler) GetConversionFunnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	from, to, err := parseReportPeriod(r.URL.Query(), defaultReportDays)
+	if err != nil {
+		slog.WarnContext(ctx, "GetConversionFunnel: invalid report period", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	funnel, err := h.keyManagerService.GetConversionFunnel(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetConversionFunnel: failed to compute conversion funnel via service", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to compute conversion funnel.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.ConversionFunnelResponse{
+		From:           funnel.From,
+		To:             funnel.To,
+		FreeKeyUsers:   funnel.FreeKeyUsers,
+		Subscribed:     funnel.Subscribed,
+		Paid:           funnel.Paid,
+		ConversionRate: funnel.ConversionRate,
+	})
+}
@@ -0,0 +1,653 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestKeyHandler returns a KeyHandler on svc with the default configuration.
+func newTestKeyHandler(svc *fakeKeyService) *KeyHandler {
+	return NewKeyHandler(svc, &config.Config{})
+}
+
+func TestGetCurrentUserVlessKey(t *testing.T) {
+	userID := uuid.New()
+	const vlessKey = "vless://key@de1.example.com:443?type=tcp#My%20key"
+
+	path := "/v1/users/" + userID.String() + "/current-key"
+
+	tests := []struct {
+		name       string
+		path       string
+		principal  *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "existing assignment", path: path, principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusOK},
+		{name: "missing assignment", path: path, principal: &userID, role: customTypes.RoleUser,
+			serviceErr: fmt.Errorf("active key assignment for user %s not found", userID), wantStatus: http.StatusNotFound},
+		{name: "lookup failure", path: path, principal: &userID, role: customTypes.RoleUser, serviceErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+		{name: "invalid user ID", path: "/v1/users/not-a-uuid/current-key", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+		{name: "another user's key", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "admin", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "unauthenticated", path: path, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			svc := &fakeKeyService{
+				getCurrentVlessKeyForUser: func(_ context.Context, id uuid.UUID) (*serviceDTO.CurrentUserKeyResult, error) {
+					called = true
+					if id != userID {
+						t.Errorf("service called with user %s, want %s", id, userID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.CurrentUserKeyResult{VlessKey: vlessKey, Remarks: "My key", HostID: 3}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if called && (tt.wantStatus == http.StatusForbidden || tt.wantStatus == http.StatusUnauthorized) {
+				t.Errorf("service was called for a rejected request")
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got := decodeJSON[dto.VlessKeyResponse](t, rec)
+			if got.VlessKey != vlessKey || got.UserID != userID.String() || got.Remarks != "My key" {
+				t.Errorf("response = %+v, want the service's key for user %s", got, userID)
+			}
+		})
+	}
+}
+
+func TestGenerateUserVlessKeys(t *testing.T) {
+	userID := uuid.New()
+	path := "/v1/users/" + userID.String() + "/vless-keys"
+
+	tests := []struct {
+		name       string
+		path       string
+		principal  *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role       customTypes.UserRole
+		wantStatus int
+	}{
+		{name: "own keys", path: path + "?countries=NL,DE", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusOK},
+		{name: "another user's keys", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "admin", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "unauthenticated", path: path, wantStatus: http.StatusUnauthorized},
+		{name: "invalid user ID", path: "/v1/users/not-a-uuid/vless-keys", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			svc := &fakeKeyService{
+				generateVlessKeysForUser: func(_ context.Context, id uuid.UUID, _ string, _ []string) (*serviceDTO.MultiCountryKeysResult, error) {
+					called = true
+					if id != userID {
+						t.Errorf("service called with user %s, want %s", id, userID)
+					}
+					return &serviceDTO.MultiCountryKeysResult{Keys: []serviceDTO.CountryKey{{VlessKey: "vless://nl", Host: serviceDTO.KeyHost{Country: "NL"}}}}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(NewKeyHandler(svc, &config.Config{MaxKeyCountries: 5}).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("service called = %t, want %t", called, tt.wantStatus == http.StatusOK)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := decodeJSON[dto.MultiCountryKeysResponse](t, rec); got.UserID != userID.String() || got.Count != 1 {
+				t.Errorf("response = %+v, want the service's key for user %s", got, userID)
+			}
+		})
+	}
+}
+
+func TestGenerateUserVlessConfig(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		config serviceDTO.VlessConfig
+		want   dto.VlessConfigResponse
+	}{
+		{
+			name:   "vless host",
+			config: serviceDTO.VlessConfig{Address: "de1.example.com", Port: "443", UUID: userID.String(), Network: "ws", Security: "tls", SNI: "cdn.example.com", Fingerprint: "chrome"},
+			want:   dto.VlessConfigResponse{Address: "de1.example.com", Port: "443", UUID: userID.String(), Network: "ws", Security: "tls", SNI: "cdn.example.com", FP: "chrome"},
+		},
+		{
+			name: "reality host",
+			config: serviceDTO.VlessConfig{Address: "nl1.example.com", Port: "443", UUID: userID.String(), Network: "tcp", Security: "reality",
+				SNI: "www.microsoft.com", Fingerprint: "firefox", PublicKey: "pbk123", ShortID: "ab12", Flow: "xtls-rprx-vision"},
+			want: dto.VlessConfigResponse{Address: "nl1.example.com", Port: "443", UUID: userID.String(), Network: "tcp", Security: "reality",
+				SNI: "www.microsoft.com", FP: "firefox", PBK: "pbk123", SID: "ab12", Flow: "xtls-rprx-vision"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeKeyService{
+				generateVlessKeyForUser: func(context.Context, uuid.UUID, string, serviceDTO.HostPreferences, bool) (*serviceDTO.GenerateUserKeyResult, error) {
+					return &serviceDTO.GenerateUserKeyResult{VlessKey: "vless://key", Config: tt.config, ServedTier: serviceDTO.ServedTierPaid}, nil
+				},
+			}
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/users/"+userID.String()+"/vless-key/config", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			tt.want.VlessKey, tt.want.ServedTier = "vless://key", serviceDTO.ServedTierPaid
+			if got := decodeJSON[dto.VlessConfigResponse](t, rec); got != tt.want {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFreeVlessKeyBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+		wantCount  int
+		wantHosts  int
+	}{
+		{name: "spread over hosts", query: "?count=6", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantCount: 6, wantHosts: 3},
+		{name: "above the cap", query: "?count=1000", role: customTypes.RoleAdmin,
+			serviceErr: errors.New("invalid count: at most 100 keys can be generated per batch"), wantStatus: http.StatusBadRequest},
+		{name: "no free hosts", query: "?count=1", role: customTypes.RoleAdmin,
+			serviceErr: errors.New("no active free hosts available to generate key"), wantStatus: http.StatusServiceUnavailable},
+		{name: "count missing", query: "", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "count not a number", query: "?count=many", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", query: "?count=6", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeKeyService{
+				generateFreeVlessKeys: func(_ context.Context, count int, _ string, _ *string) ([]serviceDTO.FreeKeyResult, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					keys := make([]serviceDTO.FreeKeyResult, count)
+					for i := range keys {
+						keys[i] = serviceDTO.FreeKeyResult{VlessKey: fmt.Sprintf("vless://key-%d", i), HostID: uint(i%3 + 1)}
+					}
+					return keys, nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodPost, "/v1/keys/free/batch"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body := decodeJSON[dto.FreeKeyBatchResponse](t, rec)
+			if body.Count != tt.wantCount || len(body.Keys) != tt.wantCount || body.Hosts != tt.wantHosts {
+				t.Errorf("response has count %d, %d keys and %d hosts; want %d keys on %d hosts", body.Count, len(body.Keys), body.Hosts, tt.wantCount, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestKeyRemarksQuery(t *testing.T) {
+	userID := uuid.New()
+	path := "/v1/users/" + userID.String() + "/vless-key"
+
+	tests := []struct {
+		name        string
+		query       string
+		wantRemarks string
+	}{
+		{name: "template", query: "?remarks_template=" + url.QueryEscape("BittenVPN | {country} | {city}"), wantRemarks: "BittenVPN | {country} | {city}"},
+		{name: "template takes precedence", query: "?remarks=Plain&remarks_template=" + url.QueryEscape("{host_name}"), wantRemarks: "{host_name}"},
+		{name: "literal remarks", query: "?remarks=" + url.QueryEscape("My key"), wantRemarks: "My key"},
+		{name: "fragment and control characters are stripped", query: "?remarks_template=" + url.QueryEscape(" #{country}\t\n"), wantRemarks: "{country}"},
+		{name: "empty template falls back to remarks", query: "?remarks_template=&remarks=Plain", wantRemarks: "Plain"},
+		{name: "neither", wantRemarks: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRemarks string
+			svc := &fakeKeyService{
+				generateVlessKeyForUser: func(_ context.Context, _ uuid.UUID, remarks string, _ serviceDTO.HostPreferences, _ bool) (*serviceDTO.GenerateUserKeyResult, error) {
+					gotRemarks = remarks
+					return &serviceDTO.GenerateUserKeyResult{VlessKey: "vless://key", ServedTier: serviceDTO.ServedTierPaid}, nil
+				},
+			}
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, path+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if gotRemarks != tt.wantRemarks {
+				t.Errorf("service called with remarks %q, want %q", gotRemarks, tt.wantRemarks)
+			}
+		})
+	}
+}
+
+func TestKeyRemarksLength(t *testing.T) {
+	userPath := "/v1/users/" + uuid.NewString() + "/vless-key"
+
+	tests := []struct {
+		name        string
+		maxLength   int
+		remarks     string
+		wantStatus  int
+		wantRemarks string
+	}{
+		{name: "normal", maxLength: 10, remarks: "My key", wantStatus: http.StatusOK, wantRemarks: "My key"},
+		{name: "at the limit", maxLength: 10, remarks: "0123456789", wantStatus: http.StatusOK, wantRemarks: "0123456789"},
+		{name: "overlong", maxLength: 10, remarks: "0123456789A", wantStatus: http.StatusBadRequest},
+		{name: "limit counts characters, not bytes", maxLength: 10, remarks: "Zürich 🇨🇭", wantStatus: http.StatusOK, wantRemarks: "Zürich 🇨🇭"},
+		{name: "control characters are stripped before measuring", maxLength: 10, remarks: "\x00My\x1b key\r\n\t",
+			wantStatus: http.StatusOK, wantRemarks: "My key"},
+		{name: "only control characters falls back to the default", maxLength: 10, remarks: "\x07\x7f\n", wantStatus: http.StatusOK, wantRemarks: "Default"},
+		{name: "limit disabled", maxLength: 0, remarks: strings.Repeat("a", 500), wantStatus: http.StatusOK, wantRemarks: strings.Repeat("a", 500)},
+	}
+	for _, tt := range tests {
+		for _, route := range []string{"user", "free"} {
+			t.Run(tt.name+"/"+route, func(t *testing.T) {
+				var gotRemarks string
+				called := false
+				svc := &fakeKeyService{
+					generateVlessKeyForUser: func(_ context.Context, _ uuid.UUID, remarks string, _ serviceDTO.HostPreferences, _ bool) (*serviceDTO.GenerateUserKeyResult, error) {
+						called, gotRemarks = true, remarks
+						return &serviceDTO.GenerateUserKeyResult{VlessKey: "vless://key", ServedTier: serviceDTO.ServedTierPaid}, nil
+					},
+					generateFreeVlessKey: func(_ context.Context, remarks string, _ serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error) {
+						called, gotRemarks = true, remarks
+						return &serviceDTO.FreeKeyResult{VlessKey: "vless://key"}, nil
+					},
+				}
+				h := NewKeyHandler(svc, &config.Config{MaxRemarksLength: tt.maxLength, KeyDefaultRemarks: "Default", KeyFreeDefaultRemarks: "Default"})
+
+				path := userPath
+				if route == "free" {
+					path = "/v1/key/free"
+				}
+				rec := serveRoutes(h.RegisterRoutes, httptest.NewRequest(http.MethodGet, path+"?remarks="+url.QueryEscape(tt.remarks), nil))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+				}
+				if tt.wantStatus != http.StatusOK {
+					if called {
+						t.Error("service called with rejected remarks")
+					}
+					return
+				}
+				if gotRemarks != tt.wantRemarks {
+					t.Errorf("service called with remarks %q, want %q", gotRemarks, tt.wantRemarks)
+				}
+			})
+		}
+	}
+}
+
+func TestAllowFreeFallbackQuery(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		configDefault bool
+		query         string
+		wantStatus    int
+		wantAllow     bool
+	}{
+		{name: "configured default off", wantStatus: http.StatusOK},
+		{name: "configured default on", configDefault: true, wantStatus: http.StatusOK, wantAllow: true},
+		{name: "enabled per request", query: "?allow_free_fallback=true", wantStatus: http.StatusOK, wantAllow: true},
+		{name: "disabled per request", configDefault: true, query: "?allow_free_fallback=false", wantStatus: http.StatusOK},
+		{name: "invalid value", query: "?allow_free_fallback=maybe", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/vless-key", "/vless-key/config"} {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				var gotAllow *bool
+				svc := &fakeKeyService{
+					generateVlessKeyForUser: func(_ context.Context, _ uuid.UUID, _ string, _ serviceDTO.HostPreferences, allow bool) (*serviceDTO.GenerateUserKeyResult, error) {
+						gotAllow = &allow
+						return &serviceDTO.GenerateUserKeyResult{VlessKey: "vless://key", HasActiveSubscription: true, ServedTier: serviceDTO.ServedTierFree, Degraded: true}, nil
+					},
+				}
+				handler := NewKeyHandler(svc, &config.Config{AllowFreeFallback: tt.configDefault})
+
+				rec := serveRoutes(handler.RegisterRoutes, httptest.NewRequest(http.MethodGet, "/v1/users/"+userID.String()+path+tt.query, nil))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+				}
+				if tt.wantStatus != http.StatusOK {
+					if gotAllow != nil {
+						t.Error("service was called for an invalid request")
+					}
+					return
+				}
+				if gotAllow == nil || *gotAllow != tt.wantAllow {
+					t.Errorf("allowFreeFallback = %v, want %t", gotAllow, tt.wantAllow)
+				}
+				// The degraded tier is surfaced to the client.
+				if body := rec.Body.String(); !strings.Contains(body, `"served_tier":"free"`) || !strings.Contains(body, `"degraded":true`) {
+					t.Errorf("body %s does not report the degraded free tier", body)
+				}
+			})
+		}
+	}
+}
+
+func TestKeyHostPreferencesQuery(t *testing.T) {
+	userID := uuid.New()
+	issuedOn := serviceDTO.KeyHost{Country: "NL", Network: "grpc", SecurityType: "tls", AddressFamily: customTypes.AddressFamilyIPv4}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPrefs  serviceDTO.HostPreferences
+	}{
+		{name: "no preferences", wantStatus: http.StatusOK},
+		{
+			name:       "country and transport",
+			query:      "?country=DE&network=grpc&security=tls",
+			wantStatus: http.StatusOK,
+			wantPrefs:  serviceDTO.HostPreferences{Country: "DE", Network: "grpc", SecurityType: "tls"},
+		},
+		{
+			name:       "transport is normalized",
+			query:      "?network=%20WS%20&security=Reality",
+			wantStatus: http.StatusOK,
+			wantPrefs:  serviceDTO.HostPreferences{Network: "ws", SecurityType: "reality"},
+		},
+		{
+			name:       "address family",
+			query:      "?address_family=%20IPv6",
+			wantStatus: http.StatusOK,
+			wantPrefs:  serviceDTO.HostPreferences{AddressFamily: customTypes.AddressFamilyIPv6},
+		},
+		{name: "any address family", query: "?address_family=any", wantStatus: http.StatusOK},
+		{name: "hostname is not an address family preference", query: "?address_family=hostname", wantStatus: http.StatusBadRequest},
+		{name: "invalid address family", query: "?network=ws&address_family=ipx", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/v1/users/" + userID.String() + "/vless-key", "/v1/key/free"} {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				var gotPrefs *serviceDTO.HostPreferences
+				svc := &fakeKeyService{
+					generateVlessKeyForUser: func(_ context.Context, _ uuid.UUID, _ string, prefs serviceDTO.HostPreferences, _ bool) (*serviceDTO.GenerateUserKeyResult, error) {
+						gotPrefs = &prefs
+						return &serviceDTO.GenerateUserKeyResult{VlessKey: "vless://key", Host: issuedOn, ServedTier: serviceDTO.ServedTierFree}, nil
+					},
+					generateFreeVlessKey: func(_ context.Context, _ string, prefs serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error) {
+						gotPrefs = &prefs
+						return &serviceDTO.FreeKeyResult{VlessKey: "vless://key", HostID: 1, Host: issuedOn}, nil
+					},
+				}
+
+				rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, path+tt.query, nil))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+				}
+				if tt.wantStatus != http.StatusOK {
+					if gotPrefs != nil {
+						t.Error("service was called for an invalid request")
+					}
+					return
+				}
+				if gotPrefs == nil || *gotPrefs != tt.wantPrefs {
+					t.Errorf("host preferences = %+v, want %+v", gotPrefs, tt.wantPrefs)
+				}
+				// The attributes of the host actually used are reported, whatever was requested.
+				got := decodeJSON[dto.VlessKeyResponse](t, rec)
+				if got.Host == nil || got.Host.Country != issuedOn.Country || got.Host.Network != issuedOn.Network || got.Host.SecurityType != issuedOn.SecurityType ||
+					got.Host.AddressFamily != string(issuedOn.AddressFamily) {
+					t.Errorf("response host = %+v, want %+v", got.Host, issuedOn)
+				}
+			})
+		}
+	}
+}
+
+func TestGetKeyGenerationRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+		wantWindow time.Duration
+		wantBucket time.Duration
+	}{
+		{name: "defaults", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantWindow: time.Hour, wantBucket: 5 * time.Minute},
+		{name: "explicit window and bucket", query: "?window=6h&bucket=30m", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantWindow: 6 * time.Hour, wantBucket: 30 * time.Minute},
+		{name: "default bucket at least a minute", query: "?window=6m", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantWindow: 6 * time.Minute, wantBucket: time.Minute},
+		{name: "bucket capped at the window", query: "?window=10m&bucket=1h", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantWindow: 10 * time.Minute, wantBucket: 10 * time.Minute},
+		{name: "longest window", query: "?window=720h&bucket=1h", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantWindow: 720 * time.Hour, wantBucket: time.Hour},
+		{name: "window not a duration", query: "?window=hour", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "negative window", query: "?window=-1h", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "window over 30 days", query: "?window=721h", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "bucket under a minute", query: "?bucket=30s", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "too many buckets", query: "?window=720h&bucket=1m", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "service failure", role: customTypes.RoleAdmin, serviceErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+			var gotWindow, gotBucket time.Duration
+			svc := &fakeKeyService{
+				keyGenerationRate: func(_ context.Context, window, bucket time.Duration) (*serviceDTO.KeyGenerationRate, error) {
+					gotWindow, gotBucket = window, bucket
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.KeyGenerationRate{
+						From: to.Add(-window), To: to, Bucket: bucket,
+						Buckets: []serviceDTO.KeyGenerationBucket{
+							{Start: to.Add(-window), FreeKeys: 2, UserKeys: 1},
+							{Start: to.Add(-window + bucket), FreeKeys: 0, UserKeys: 3},
+						},
+						TotalFree: 2, TotalUser: 4,
+					}, nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/key-generation-rate"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if gotWindow != tt.wantWindow || gotBucket != tt.wantBucket {
+				t.Errorf("service called with window %s and bucket %s, want %s and %s", gotWindow, gotBucket, tt.wantWindow, tt.wantBucket)
+			}
+			body := decodeJSON[dto.KeyGenerationRateResponse](t, rec)
+			if body.Window != tt.wantWindow.String() || body.Bucket != tt.wantBucket.String() {
+				t.Errorf("response window %q and bucket %q, want %q and %q", body.Window, body.Bucket, tt.wantWindow, tt.wantBucket)
+			}
+			if !body.From.Equal(to.Add(-tt.wantWindow)) || !body.To.Equal(to) {
+				t.Errorf("response spans %v to %v, want %v to %v", body.From, body.To, to.Add(-tt.wantWindow), to)
+			}
+			if len(body.Buckets) != 2 || body.Buckets[0].FreeKeys != 2 || body.Buckets[1].UserKeys != 3 || body.TotalFree != 2 || body.TotalUser != 4 {
+				t.Errorf("response buckets = %+v with totals %d free, %d user; want the service's counts", body.Buckets, body.TotalFree, body.TotalUser)
+			}
+		})
+	}
+}
+
+func TestGetConversionFunnel(t *testing.T) {
+	march, april := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+		wantFrom   time.Time // Zero for the default period, the last 30 days.
+		wantTo     time.Time
+	}{
+		{name: "explicit period", query: "?from=2026-03-01&to=2026-04-01", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantFrom: march, wantTo: april},
+		{name: "default period", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "invalid from", query: "?from=March", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2026-04-01&to=2026-03-01", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "service failure", role: customTypes.RoleAdmin, serviceErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFrom, gotTo time.Time
+			svc := &fakeKeyService{
+				conversionFunnel: func(_ context.Context, from, to time.Time) (*serviceDTO.ConversionFunnel, error) {
+					gotFrom, gotTo = from, to
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.ConversionFunnel{From: from, To: to, FreeKeyUsers: 8, Subscribed: 4, Paid: 2, ConversionRate: 0.25}, nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/conversion-funnel"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if tt.serviceErr == nil && !gotTo.IsZero() {
+					t.Error("service was called for a rejected request")
+				}
+				return
+			}
+			if tt.wantFrom.IsZero() {
+				if time.Since(gotTo) > time.Minute || gotTo.Sub(gotFrom) != 30*24*time.Hour {
+					t.Errorf("service called for %v to %v, want the last 30 days", gotFrom, gotTo)
+				}
+			} else if !gotFrom.Equal(tt.wantFrom) || !gotTo.Equal(tt.wantTo) {
+				t.Errorf("service called for %v to %v, want %v to %v", gotFrom, gotTo, tt.wantFrom, tt.wantTo)
+			}
+			body := decodeJSON[dto.ConversionFunnelResponse](t, rec)
+			want := dto.ConversionFunnelResponse{From: gotFrom, To: gotTo, FreeKeyUsers: 8, Subscribed: 4, Paid: 2, ConversionRate: 0.25}
+			if !body.From.Equal(want.From) || !body.To.Equal(want.To) || body.FreeKeyUsers != want.FreeKeyUsers || body.Subscribed != want.Subscribed ||
+				body.Paid != want.Paid || body.ConversionRate != want.ConversionRate {
+				t.Errorf("response = %+v, want %+v", body, want)
+			}
+		})
+	}
+}
+
+func TestGenerateUserTrojanKey(t *testing.T) {
+	userID := uuid.New()
+	const trojanKey = "trojan://0123abcd@us1.example.com:8443?security=tls&type=ws#Trojan%20US"
+
+	path := "/v1/users/" + userID.String() + "/trojan-key"
+
+	tests := []struct {
+		name         string
+		path         string
+		principal    *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role         customTypes.UserRole
+		serviceErr   error
+		wantStatus   int
+		wantCountry  string
+		wantFallback bool
+	}{
+		{name: "trojan key", path: path + "?country=US&remarks=Trojan", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusOK, wantCountry: "US"},
+		{name: "free fallback", path: path + "?allow_free_fallback=true", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusOK, wantFallback: true},
+		{name: "trojan keys disabled", path: path, principal: &userID, role: customTypes.RoleUser,
+			serviceErr: fmt.Errorf("trojan keys are not enabled: %w", services.ErrUnavailable), wantStatus: http.StatusServiceUnavailable},
+		{name: "unknown user", path: path, principal: &userID, role: customTypes.RoleUser,
+			serviceErr: fmt.Errorf("user not found: %w", services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "invalid user ID", path: "/v1/users/not-a-uuid/trojan-key", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+		{name: "another user's key", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "admin", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "unauthenticated", path: path, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			svc := &fakeKeyService{
+				generateTrojanKeyForUser: func(_ context.Context, id uuid.UUID, _ string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateTrojanKeyResult, error) {
+					called = true
+					if id != userID {
+						t.Errorf("service called with user %s, want %s", id, userID)
+					}
+					if prefs.Country != tt.wantCountry || allowFreeFallback != tt.wantFallback {
+						t.Errorf("service called with country %q and fallback %v, want %q and %v", prefs.Country, allowFreeFallback, tt.wantCountry, tt.wantFallback)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.GenerateTrojanKeyResult{
+						TrojanKey:  trojanKey,
+						Host:       serviceDTO.KeyHost{Country: "US", Network: "ws", SecurityType: "tls"},
+						ServedTier: serviceDTO.ServedTierFree,
+					}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if called && (tt.wantStatus == http.StatusForbidden || tt.wantStatus == http.StatusUnauthorized) {
+				t.Errorf("service was called for a rejected request")
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got := decodeJSON[dto.TrojanKeyResponse](t, rec)
+			if got.TrojanKey != trojanKey || got.UserID != userID.String() || got.ServedTier != serviceDTO.ServedTierFree {
+				t.Errorf("response = %+v, want the service's trojan key for user %s", got, userID)
+			}
+			if got.Host == nil || got.Host.Country != "US" || got.Host.Network != "ws" {
+				t.Errorf("response host = %+v, want the service's host", got.Host)
+			}
+		})
+	}
+}
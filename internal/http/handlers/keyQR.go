@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+// QR code image sizes in pixels accepted by the 'size' query parameter.
+const (
+	defaultQRCodeSize = 256
+	minQRCodeSize     = 64
+	maxQRCodeSize     = 1024
+)
+
+// GenerateUserVlessKeyQR handles the request to generate a VLESS key for a specified user and return it
+// as a PNG QR code. Host selection is the same as for GenerateUserVlessKey.
+func (h *KeyHandler) GenerateUserVlessKeyQR(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeyQR: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	size, err := parseQRCodeSize(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Retrieve 'remarks_template' or 'remarks' from query parameters; use a default if neither is provided.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeyQR: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Retrieve the 'country', 'network', 'security' and 'address_family' host preferences from query parameters.
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeyQR: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	allowFreeFallback, err := h.allowFreeFallbackFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateUserVlessKeyQR: invalid 'allow_free_fallback' query parameter", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.keyManagerService.GenerateVlessKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateUserVlessKeyQR: failed to generate VLESS key via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") { // User not found
+			respondWithError(w, http.StatusNotFound, err.Error())
+		} else if strings.Contains(err.Error(), "no active hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to generate key: No active hosts are currently available for your criteria.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate VLESS key.")
+		}
+		return
+	}
+
+	respondWithQRCode(w, r, result.VlessKey, size)
+}
+
+// GenerateFreeVlessKeyQR handles the request to generate a free VLESS key and return it as a PNG QR code.
+func (h *KeyHandler) GenerateFreeVlessKeyQR(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	size, err := parseQRCodeSize(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Retrieve 'remarks_template' or 'remarks' from query parameters; use a default if neither is provided.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyFreeDefaultRemarks)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKeyQR: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Retrieve the 'country', 'network', 'security' and 'address_family' host preferences from query parameters.
+	prefs, err := hostPreferencesFromQuery(r)
+	if err != nil {
+		slog.WarnContext(ctx, "GenerateFreeVlessKeyQR: invalid host preferences", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.keyManagerService.GenerateFreeVlessKey(ctx, remarks, prefs)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateFreeVlessKeyQR: failed to generate VLESS key via service", "error", err)
+		if strings.Contains(err.Error(), "no active free hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to generate key: No active free hosts are currently available.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate VLESS key.")
+		}
+		return
+	}
+
+	respondWithQRCode(w, r, result.VlessKey, size)
+}
+
+// parseQRCodeSize reads the optional 'size' query parameter, the width and height of the QR code image in pixels.
+func parseQRCodeSize(r *http.Request) (int, error) {
+	sizeStr := r.URL.Query().Get("size")
+	if sizeStr == "" {
+		return defaultQRCodeSize, nil
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size < minQRCodeSize || size > maxQRCodeSize {
+		return 0, fmt.Errorf("query parameter 'size' must be an integer between %d and %d", minQRCodeSize, maxQRCodeSize)
+	}
+	return size, nil
+}
+
+// respondWithQRCode encodes content as a PNG QR code of the given size and writes it to the response.
+// Keys are generated per request, so the image must not be cached.
+func respondWithQRCode(w http.ResponseWriter, r *http.Request, content string, size int) {
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "respondWithQRCode: failed to encode QR code", "size", size, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate QR code.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(png); err != nil {
+		slog.ErrorContext(r.Context(), "respondWithQRCode: failed to write QR code", "error", err)
+	}
+}
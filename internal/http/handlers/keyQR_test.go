@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestVlessKeyQRCode(t *testing.T) {
+	userID := uuid.New()
+	userPath := "/v1/users/" + userID.String() + "/vless-key/qr"
+	const vlessKey = "vless://key@de1.example.com:443?type=tcp&security=tls#Free"
+
+	tests := []struct {
+		name       string
+		path       string
+		serviceErr error
+		wantStatus int
+		wantSize   int
+	}{
+		{name: "user key default size", path: userPath, wantStatus: http.StatusOK, wantSize: defaultQRCodeSize},
+		{name: "user key custom size", path: userPath + "?size=512", wantStatus: http.StatusOK, wantSize: 512},
+		{name: "free key default size", path: "/v1/key/free/qr", wantStatus: http.StatusOK, wantSize: defaultQRCodeSize},
+		{name: "free key largest size", path: "/v1/key/free/qr?size=1024", wantStatus: http.StatusOK, wantSize: maxQRCodeSize},
+		{name: "size above the cap", path: userPath + "?size=4096", wantStatus: http.StatusBadRequest},
+		{name: "size below the minimum", path: "/v1/key/free/qr?size=16", wantStatus: http.StatusBadRequest},
+		{name: "size not a number", path: userPath + "?size=big", wantStatus: http.StatusBadRequest},
+		{name: "no free hosts", path: "/v1/key/free/qr", serviceErr: errors.New("no active free hosts available to generate key"), wantStatus: http.StatusServiceUnavailable},
+		{name: "generation failure", path: userPath, serviceErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeKeyService{
+				generateVlessKeyForUser: func(context.Context, uuid.UUID, string, serviceDTO.HostPreferences, bool) (*serviceDTO.GenerateUserKeyResult, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.GenerateUserKeyResult{VlessKey: vlessKey, ServedTier: serviceDTO.ServedTierPaid}, nil
+				},
+				generateFreeVlessKey: func(context.Context, string, serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.FreeKeyResult{VlessKey: vlessKey, HostID: 1}, nil
+				},
+			}
+
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "image/png" {
+				t.Errorf("Content-Type = %q, want image/png", got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+			img, err := png.Decode(rec.Body)
+			if err != nil {
+				t.Fatalf("response body is not a valid PNG: %v", err)
+			}
+			if bounds := img.Bounds(); bounds.Dx() != tt.wantSize || bounds.Dy() != tt.wantSize {
+				t.Errorf("image is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), tt.wantSize, tt.wantSize)
+			}
+		})
+	}
+}
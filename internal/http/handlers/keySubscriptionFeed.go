@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// subscriptionFeedContentType is the content type of subscription feeds.
+	subscriptionFeedContentType = "text/plain; charset=utf-8"
+	// subscriptionFeedUpdateIntervalHours is how often clients are asked to refresh a subscription feed.
+	subscriptionFeedUpdateIntervalHours = 12
+)
+
+// GetUserSubscriptionFeed handles the request for a user's subscription feed, the format imported by VPN clients
+// such as v2rayN or Shadowrocket: the user's VLESS keys for every eligible host, newline-separated and
+// base64-encoded. Users without an active subscription get free-tier hosts only.
+// The Profile-Update-Interval and Subscription-Userinfo headers tell clients how often to refresh the feed
+// and when the subscription expires.
+// Expected route: GET /api/v1/users/{userID}/subscription.txt
+func (h *KeyHandler) GetUserSubscriptionFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUserSubscriptionFeed: invalid userID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid User ID format in path.")
+		return
+	}
+
+	// Every key of the feed is named after the host's country unless the client asks otherwise.
+	remarks, err := h.remarksFromQuery(r, h.cfg.KeyDefaultRemarks+" {country}")
+	if err != nil {
+		slog.WarnContext(ctx, "GetUserSubscriptionFeed: invalid remarks", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, err := h.keyManagerService.GetSubscriptionFeed(ctx, userID, remarks)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionFeed: failed to build subscription feed via service", "userID", userID, "error", err)
+		if strings.Contains(err.Error(), "not found") { // User not found
+			respondWithError(w, http.StatusNotFound, err.Error())
+		} else if strings.Contains(err.Error(), "no active hosts available") {
+			respondWithError(w, http.StatusServiceUnavailable, "Unable to build the subscription feed: No active hosts are currently available.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to build the subscription feed.")
+		}
+		return
+	}
+
+	userInfo := "upload=0; download=0; total=0"
+	if feed.ExpiresAt != nil {
+		userInfo += fmt.Sprintf("; expire=%d", feed.ExpiresAt.Unix())
+		remainingDays := int(math.Ceil(time.Until(*feed.ExpiresAt).Hours() / 24))
+		w.Header().Set("X-Subscription-Remaining-Days", strconv.Itoa(max(remainingDays, 0)))
+	}
+	w.Header().Set("Content-Type", subscriptionFeedContentType)
+	w.Header().Set("Profile-Update-Interval", strconv.Itoa(subscriptionFeedUpdateIntervalHours))
+	w.Header().Set("Subscription-Userinfo", userInfo)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	payload := base64.StdEncoding.EncodeToString([]byte(strings.Join(feed.VlessKeys, "\n")))
+	if _, err := w.Write([]byte(payload)); err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionFeed: failed to write response", "error", err)
+	}
+	slog.InfoContext(ctx, "GetUserSubscriptionFeed: subscription feed sent", "userID", userID, "count", len(feed.VlessKeys), "hasActiveSubscription", feed.HasActiveSubscription)
+}
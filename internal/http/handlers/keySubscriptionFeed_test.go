@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetUserSubscriptionFeed(t *testing.T) {
+	userID := uuid.New()
+	path := "/v1/users/" + userID.String() + "/subscription.txt"
+
+	tests := []struct {
+		name       string
+		path       string
+		principal  *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role       customTypes.UserRole
+		wantStatus int
+	}{
+		{name: "own feed", path: path, principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusOK},
+		{name: "another user's feed", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "admin", path: path, principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "unauthenticated", path: path, wantStatus: http.StatusUnauthorized},
+		{name: "invalid user ID", path: "/v1/users/not-a-uuid/subscription.txt", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			svc := &fakeKeyService{
+				getSubscriptionFeed: func(_ context.Context, id uuid.UUID, _ string) (*serviceDTO.SubscriptionFeed, error) {
+					called = true
+					if id != userID {
+						t.Errorf("service called with user %s, want %s", id, userID)
+					}
+					return &serviceDTO.SubscriptionFeed{VlessKeys: []string{"vless://nl", "vless://de"}}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(newTestKeyHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("service called = %t, want %t", called, tt.wantStatus == http.StatusOK)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if want := base64.StdEncoding.EncodeToString([]byte("vless://nl\nvless://de")); rec.Body.String() != want {
+				t.Errorf("body = %q, want %q", rec.Body.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bitback/internal/httpctx"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// requestIDHeader is the header through which clients or proxies pass a request ID; it is echoed in responses.
+	requestIDHeader = "X-Request-ID"
+	// maxRequestIDLength is the maximum accepted length of a client-supplied request ID.
+	maxRequestIDLength = 128
+)
+
+// RequestID returns a middleware that assigns every request an ID, taken from the X-Request-ID header
+// if it holds a usable value and generated otherwise. The ID is echoed in the X-Request-ID response header
+// and stored in the request context through httpctx, where the logging.ContextHandler picks it up for every log record.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := strings.TrimSpace(r.Header.Get(requestIDHeader))
+			if !isValidRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set(requestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(httpctx.WithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// AccessLog returns a middleware that logs the method, path, status code, duration and response size
+// of every request once it has been handled.
+func AccessLog() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startedAt := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			slog.InfoContext(r.Context(), "HTTP request handled",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", recorder.status,
+				"duration_ms", time.Since(startedAt).Milliseconds(),
+				"response_bytes", recorder.bytesWritten,
+				"remote_addr", r.RemoteAddr,
+			)
+		})
+	}
+}
+
+// Recover returns a middleware that recovers from panics in downstream handlers, logs the panic value
+// and stack trace, and responds with a 500 JSON error if no response has been started yet.
+// http.ErrAbortHandler, including errors wrapping it, is re-panicked so that the server can abort the response as intended.
+func Recover() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+				slog.ErrorContext(r.Context(), "Recover: panic while handling request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", recovered,
+					"stack", string(debug.Stack()),
+				)
+				if !recorder.wroteHeader {
+					respondWithError(recorder, http.StatusInternalServerError, "Internal server error.")
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// isValidRequestID reports whether a client-supplied request ID is non-empty, not too long,
+// and consists of printable ASCII characters only, so that it is safe to log and echo.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
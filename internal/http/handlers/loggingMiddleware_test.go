@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/httpctx"
+	"bitback/internal/logging"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		wantGenerated bool
+		want          string
+	}{
+		{name: "client ID is echoed", header: "abc-123", want: "abc-123"},
+		{name: "surrounding whitespace is trimmed", header: "  abc-123 ", want: "abc-123"},
+		{name: "longest accepted ID", header: strings.Repeat("a", maxRequestIDLength), want: strings.Repeat("a", maxRequestIDLength)},
+		{name: "missing ID is generated", wantGenerated: true},
+		{name: "too long ID is replaced", header: strings.Repeat("a", maxRequestIDLength+1), wantGenerated: true},
+		{name: "ID with spaces is replaced", header: "abc 123", wantGenerated: true},
+		{name: "ID with non-ASCII characters is replaced", header: "abc-ü", wantGenerated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = httpctx.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/hosts", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if tt.wantGenerated {
+				if _, err := uuid.Parse(got); err != nil {
+					t.Errorf("generated request ID %q is not a UUID: %v", got, err)
+				}
+			} else if got != tt.want {
+				t.Errorf("%s = %q, want %q", requestIDHeader, got, tt.want)
+			}
+			if fromContext != got {
+				t.Errorf("request ID in context = %q, want the echoed %q", fromContext, got)
+			}
+		})
+	}
+
+	t.Run("generated IDs are unique", func(t *testing.T) {
+		handler := RequestID()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		seen := make(map[string]bool)
+		for range 100 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/hosts", nil))
+			id := rec.Header().Get(requestIDHeader)
+			if seen[id] {
+				t.Fatalf("request ID %q generated twice", id)
+			}
+			seen[id] = true
+		}
+	})
+}
+
+func TestErrorResponseRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request.")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/hosts", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := decodeJSON[dto.ErrorResponse](t, rec); got.RequestID != "abc-123" {
+		t.Errorf("error response request_id = %q, want %q", got.RequestID, "abc-123")
+	}
+	if !strings.Contains(logs.String(), "request_id=abc-123") {
+		t.Errorf("error log %q does not carry the request ID", logs.String())
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantPanic  bool   // Whether the panic must propagate to the server.
+		wantBody   string // Exact body expected if the handler started the response itself.
+		wantLogged bool
+	}{
+		{
+			name:       "nil pointer dereference",
+			handler:    func(http.ResponseWriter, *http.Request) { var p *struct{ Name string }; _ = p.Name },
+			wantStatus: http.StatusInternalServerError,
+			wantLogged: true,
+		},
+		{
+			name:       "panic with a value",
+			handler:    func(http.ResponseWriter, *http.Request) { panic("unexpected state") },
+			wantStatus: http.StatusInternalServerError,
+			wantLogged: true,
+		},
+		{
+			name: "panic after the response started",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("partial"))
+				panic("unexpected state")
+			},
+			wantStatus: http.StatusAccepted,
+			wantBody:   "partial",
+			wantLogged: true,
+		},
+		{
+			name:       "no panic",
+			handler:    func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:      "abort handler",
+			handler:   func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) },
+			wantPanic: true,
+		},
+		{
+			name:      "wrapped abort handler",
+			handler:   func(http.ResponseWriter, *http.Request) { panic(fmt.Errorf("stream closed: %w", http.ErrAbortHandler)) },
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(logging.NewContextHandler(slog.NewTextHandler(&logs, nil))))
+
+			handler := RequestID()(Recover()(tt.handler))
+			req := httptest.NewRequest(http.MethodGet, "/v1/hosts", nil)
+			req.Header.Set(requestIDHeader, "abc-123")
+			rec := httptest.NewRecorder()
+
+			panicked := func() (panicked bool) {
+				defer func() { panicked = recover() != nil }()
+				handler.ServeHTTP(rec, req)
+				return false
+			}()
+			if panicked != tt.wantPanic {
+				t.Fatalf("panic propagated = %t, want %t", panicked, tt.wantPanic)
+			}
+			if tt.wantPanic {
+				return
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			switch {
+			case tt.wantBody != "":
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+				}
+			case tt.wantStatus == http.StatusInternalServerError:
+				if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+					t.Errorf("Content-Type = %q, want JSON", ct)
+				}
+				got := decodeJSON[dto.ErrorResponse](t, rec)
+				if got.Code != errorCodeInternal || got.Message == "" || got.RequestID != "abc-123" {
+					t.Errorf("error response = %+v, want an internal error for request abc-123", got)
+				}
+			}
+
+			logged := strings.Contains(logs.String(), "Recover: panic while handling request")
+			if logged != tt.wantLogged {
+				t.Fatalf("panic logged = %t, want %t: %s", logged, tt.wantLogged, logs.String())
+			}
+			if tt.wantLogged {
+				for _, want := range []string{"level=ERROR", "request_id=abc-123", "stack=", "loggingMiddleware_test.go"} {
+					if !strings.Contains(logs.String(), want) {
+						t.Errorf("panic log does not contain %q: %s", want, logs.String())
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bitback/internal/interfaces"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unmatchedRouteLabel is used as the path label for requests that do not match any registered route.
+const unmatchedRouteLabel = "unmatched"
+
+// metricsMiddleware returns an HTTP middleware that records request counts and latencies in m.
+// The route is the registered route pattern resolved by routePattern (e.g. "/v1/hosts/{hostID}"),
+// not the raw URL, to keep label cardinality bounded.
+func metricsMiddleware(m interfaces.HTTPMetrics, routePattern func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startedAt := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(recorder, r)
+
+			m.ObserveRequest(r.Method, routePatternPath(routePattern(r)), recorder.status, time.Since(startedAt))
+		})
+	}
+}
+
+// routePatternPath returns the path of a route pattern, without the method prefix.
+func routePatternPath(pattern string) string {
+	if pattern == "" {
+		return unmatchedRouteLabel
+	}
+	// Patterns registered with a method have the form "GET /v1/hosts/{hostID}".
+	if _, path, found := strings.Cut(pattern, " "); found {
+		return path
+	}
+	return pattern
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code and response size written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status       int
+	wroteHeader  bool
+	bytesWritten int64
+}
+
+// WriteHeader records the status code before delegating to the wrapped ResponseWriter.
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the header as written with the default status before delegating to the wrapped ResponseWriter,
+// and counts the bytes written.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, allowing http.ResponseController to reach it.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
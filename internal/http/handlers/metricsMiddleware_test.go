@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bitback/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsScrape(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []string // Paths requested with GET before scraping.
+		want     []string // Lines expected in the scraped metrics.
+	}{
+		{
+			name:     "route pattern label",
+			requests: []string{"/v1/things/1", "/v1/things/2"},
+			want: []string{
+				`http_requests_total{method="GET",path="/v1/things/{thingID}",status="200"} 2`,
+				`http_request_duration_seconds_count{method="GET",path="/v1/things/{thingID}"} 2`,
+			},
+		},
+		{
+			name:     "status label",
+			requests: []string{"/v1/things/missing"},
+			want:     []string{`http_requests_total{method="GET",path="/v1/things/{thingID}",status="404"} 1`},
+		},
+		{
+			name:     "unmatched route",
+			requests: []string{"/v1/nowhere", "/v1/nowhere/else"},
+			want:     []string{`http_requests_total{method="GET",path="unmatched",status="404"} 2`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.New(nil, "")
+			router := NewRouter()
+			router.EnableMetrics(m, m.Handler())
+			router.HandleFunc("GET /v1/things/{thingID}", func(w http.ResponseWriter, r *http.Request) {
+				if r.PathValue("thingID") == "missing" {
+					respondWithError(w, http.StatusNotFound, "Thing not found.")
+					return
+				}
+				respondWithJSON(w, http.StatusOK, map[string]string{"id": r.PathValue("thingID")})
+			})
+			handler := router.GetHandler()
+			for _, path := range tt.requests {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("scrape status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			scraped := rec.Body.String()
+			for _, line := range tt.want {
+				if !strings.Contains(scraped, line+"\n") {
+					t.Errorf("scraped metrics do not contain %q", line)
+				}
+			}
+			if strings.Contains(scraped, `path="/v1/things/1"`) {
+				t.Errorf("scraped metrics use the raw URL as a path label")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/interfaces"
+	serviceDTO "bitback/internal/services/dto"
+	"log/slog"
+	"net/http"
+)
+
+// OnboardingHandler handles HTTP requests that onboard new users.
+type OnboardingHandler struct {
+	onboardingService interfaces.OnboardingService
+}
+
+// NewOnboardingHandler creates a new instance of OnboardingHandler.
+func NewOnboardingHandler(ons interfaces.OnboardingService) *OnboardingHandler {
+	return &OnboardingHandler{
+		onboardingService: ons,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for onboarding.
+func (h *OnboardingHandler) RegisterRoutes(mux RouteRegistrar) {
+	// Onboarding creates users on others' behalf, e.g. for the sign-up bot. Restricted to administrators.
+	mux.HandleFunc("POST /v1/onboard", requireAdmin(h.Onboard))
+}
+
+// Onboard handles the request to create a user together with their first subscription.
+// Both are created in one transaction: if the subscription is rejected, the user is not created either.
+// The subscription is to a catalog plan at the plan's price and awaits payment, like one the user creates themselves.
+// Expected route: POST /api/v1/onboard
+func (h *OnboardingHandler) Onboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeVersionedPayload[dto.OnboardRequest](r, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Onboard: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	sub := req.Subscription
+	if sub.PlanID == nil {
+		slog.WarnContext(ctx, "Onboard: subscription without a plan ID")
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: subscription.plan_id is required")
+		return
+	}
+	if sub.StartDate.IsZero() {
+		slog.WarnContext(ctx, "Onboard: subscription without a start date")
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: subscription.start_date is required")
+		return
+	}
+
+	result, err := h.onboardingService.Onboard(ctx, serviceDTO.OnboardInput{
+		User: serviceDTO.CreateUserInput{
+			Name:       req.User.Name,
+			Email:      req.User.Email,
+			TelegramID: req.User.TelegramID,
+		},
+		Subscription: serviceDTO.CreateSubscriptionInput{
+			PlanID:        sub.PlanID,
+			PlanName:      sub.PlanName,
+			StartDate:     sub.StartDate,
+			Currency:      sub.Currency,
+			PaymentStatus: "pending", // The plan's price is paid through the payment provider after onboarding.
+			AutoRenew:     sub.AutoRenew,
+			PromoCode:     sub.PromoCode,
+			ActorUserID:   getOptionalRequestingUserID(ctx),
+			ActorRole:     getRequestingUserRole(ctx),
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Onboard: failed to onboard user via service", "error", err, "email", req.User.Email)
+		respondWithServiceError(w, err, "Failed to onboard user.")
+		return
+	}
+
+	slog.InfoContext(ctx, "Onboard: user onboarded successfully", "userID", result.User.ID, "subscriptionID", result.Subscription.ID)
+	respondWithJSON(w, http.StatusCreated, dto.OnboardResponse{
+		User:         toUserResponse(ctx, result.User),
+		Subscription: toSubscriptionResponse(result.Subscription),
+	})
+}
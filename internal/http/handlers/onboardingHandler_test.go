@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestOnboard(t *testing.T) {
+	startDate := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	const user = `"user":{"name":"Alice","email":"alice@example.com","telegram_id":42}`
+	body := `{` + user + `,"subscription":{"plan_id":3,"plan_name":"Basic","start_date":"2026-03-01T00:00:00Z",` +
+		`"currency":"EUR","auto_renew":true,"promo_code":"SPRING"}}`
+
+	tests := []struct {
+		name       string
+		body       string
+		role       customTypes.UserRole // Role of the authenticated caller; empty for an unauthenticated request.
+		serviceErr error
+		wantStatus int
+		wantCalled bool
+	}{
+		{name: "onboarded", body: body, role: customTypes.RoleAdmin, wantStatus: http.StatusCreated, wantCalled: true},
+		{name: "invalid JSON", body: `{"user":`, role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "no plan", body: `{` + user + `,"subscription":{"plan_name":"Basic","start_date":"2026-03-01T00:00:00Z"}}`, role: customTypes.RoleAdmin,
+			wantStatus: http.StatusBadRequest},
+		{name: "no start date", body: `{` + user + `,"subscription":{"plan_id":3}}`, role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "email taken", body: body, role: customTypes.RoleAdmin, serviceErr: fmt.Errorf("taken: %w", services.ErrConflict),
+			wantStatus: http.StatusConflict, wantCalled: true},
+		{name: "subscription rejected", body: body, role: customTypes.RoleAdmin, serviceErr: fmt.Errorf("bad plan: %w", services.ErrValidation),
+			wantStatus: http.StatusBadRequest, wantCalled: true},
+		{name: "not an admin", body: body, role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "unauthenticated", body: body, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, subID := uuid.New(), uuid.New()
+			var got *serviceDTO.OnboardInput
+			svc := &fakeOnboardingService{
+				onboard: func(_ context.Context, input serviceDTO.OnboardInput) (*serviceDTO.OnboardResult, error) {
+					got = &input
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.OnboardResult{
+						User:         &models.User{ID: userID, Name: input.User.Name, Email: input.User.Email},
+						Subscription: &models.Subscription{ID: subID, UserID: userID, PlanName: input.Subscription.PlanName},
+					}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/onboard", strings.NewReader(tt.body))
+			if tt.role != "" {
+				req = asPrincipal(req, uuid.New(), tt.role)
+			}
+			rec := serveRoutes(NewOnboardingHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if (got != nil) != tt.wantCalled {
+				t.Fatalf("service called = %t, want %t", got != nil, tt.wantCalled)
+			}
+			if got != nil {
+				user, sub := got.User, got.Subscription
+				if user.Name != "Alice" || user.Email != "alice@example.com" || user.TelegramID != 42 {
+					t.Errorf("user input = %+v, want the request's user", user)
+				}
+				if deref(sub.PlanID) != 3 || sub.PlanName != "Basic" || !sub.StartDate.Equal(startDate) ||
+					deref(sub.Currency) != "EUR" || !sub.AutoRenew || deref(sub.PromoCode) != "SPRING" {
+					t.Errorf("subscription input = %+v, want the request's subscription", sub)
+				}
+				if sub.Price != nil || sub.PaymentStatus != "pending" {
+					t.Errorf("subscription input has price %v and payment status %q, want the plan's price and %q", sub.Price, sub.PaymentStatus, "pending")
+				}
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			resp := decodeJSON[dto.OnboardResponse](t, rec)
+			if resp.User.ID != userID || resp.Subscription.ID != subID || resp.Subscription.UserID != userID {
+				t.Errorf("response = %+v, want user %s and their subscription %s", resp, userID, subID)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the generated document follows.
+const openAPIVersion = "3.0.3"
+
+// swaggerUIVersion is the swagger-ui-dist release loaded by the documentation page.
+const swaggerUIVersion = "5.17.14"
+
+// apiOperation describes a single route for the generated OpenAPI document.
+// Request and response bodies are given as zero values of their DTOs; their schemas are derived by reflection.
+type apiOperation struct {
+	pattern     string   // ServeMux pattern the route is registered under, e.g. "GET /v1/users/{userID}".
+	summary     string   // One-line description of the operation.
+	tag         string   // Group the operation is listed under.
+	admin       bool     // Whether the route requires the admin role.
+	query       []string // Supported query parameters, besides the pagination ones.
+	request     any      // Request body DTO; nil if the route takes no body.
+	response    any      // Success response DTO; for paginated routes, the item DTO.
+	status      int      // Success status code; defaults to 200.
+	itemsKey    string   // Key the items are serialized under; set only for paginated routes.
+	cursor      bool     // Whether the paginated route also supports keyset pagination.
+	contentType string   // Media type of a non-JSON success response.
+}
+
+// apiOperations lists every route served by the API.
+// Router.UndocumentedRoutes reports registered routes missing from this list.
+var apiOperations = []apiOperation{
+	{pattern: "GET /v1/auth/permissions", summary: "Get the requesting user's permissions", tag: "auth", response: dto.PermissionsResponse{}},
+
+	{pattern: "GET /healthz", summary: "Liveness probe", tag: "health", response: map[string]string{}},
+	{pattern: "GET /readyz", summary: "Readiness probe; 503 if the database is unreachable", tag: "health", response: map[string]string{}},
+	{pattern: "GET /metrics", summary: "Prometheus metrics", tag: "health", contentType: "text/plain"},
+
+	{pattern: "GET /v1/openapi.json", summary: "This OpenAPI document", tag: "docs", response: map[string]any{}},
+	{pattern: "GET /v1/docs", summary: "Swagger UI for this API", tag: "docs", contentType: "text/html"},
+
+	{pattern: "GET /v1/hosts", summary: "List hosts", tag: "hosts", response: dto.HostResponse{}, itemsKey: "hosts", cursor: true,
+		query: []string{"sort_by", "sort_order", "country", "city", "region", "provider", "protocol", "host_name", "address", "network", "status", "is_online", "is_private", "is_free_tier", "include_deleted"}},
+	{pattern: "GET /v1/hosts/{hostID}", summary: "Get a host", tag: "hosts", response: dto.HostResponse{}},
+	{pattern: "POST /v1/hosts", summary: "Create a host", tag: "hosts", admin: true, request: dto.CreateHostRequest{}, response: dto.HostResponse{}, status: http.StatusCreated},
+	{pattern: "POST /v1/hosts/bulk", summary: "Import hosts from a JSON array or CSV", tag: "hosts", admin: true, query: []string{"atomic"},
+		request: []dto.CreateHostRequest{}, response: dto.BulkCreateHostsResponse{}},
+	{pattern: "GET /v1/hosts/export", summary: "Export the full host configuration as a backup bundle", tag: "hosts", admin: true, response: dto.HostBundle{}},
+	{pattern: "POST /v1/hosts/import", summary: "Restore hosts from a backup bundle", tag: "hosts", admin: true, request: dto.HostBundle{}, response: dto.ImportHostsResponse{}},
+	{pattern: "PUT /v1/hosts/{hostID}", summary: "Update a host", tag: "hosts", admin: true, request: dto.UpdateHostRequest{}, response: dto.HostResponse{}},
+	{pattern: "DELETE /v1/hosts/{hostID}", summary: "Soft-delete a host", tag: "hosts", admin: true, status: http.StatusNoContent},
+	{pattern: "POST /v1/hosts/{hostID}/restore", summary: "Restore a soft-deleted host", tag: "hosts", admin: true, response: dto.HostResponse{}},
+	{pattern: "PATCH /v1/hosts/{hostID}/status", summary: "Set a host's online status", tag: "hosts", admin: true, request: dto.UpdateHostStatusRequest{}, response: dto.HostResponse{}},
+	{pattern: "PATCH /v1/hosts/{hostID}/tier", summary: "Move a host between the free and paid tiers", tag: "hosts", admin: true, request: dto.SetHostTierRequest{}, response: dto.HostResponse{}},
+	{pattern: "POST /v1/hosts/{hostID}/checks", summary: "Record a health check result", tag: "hosts", admin: true, request: dto.RecordHostCheckRequest{}, response: dto.HostCheckResponse{}, status: http.StatusCreated},
+	{pattern: "GET /v1/hosts/{hostID}/checks", summary: "List a host's health checks", tag: "hosts", admin: true, response: dto.HostCheckResponse{}, itemsKey: "checks"},
+	{pattern: "GET /v1/hosts/{hostID}/uptime", summary: "Get a host's uptime", tag: "hosts", admin: true, query: []string{"window"}, response: dto.HostUptimeResponse{}},
+	{pattern: "GET /v1/hosts/{hostID}/utilization", summary: "Get a host's utilization", tag: "hosts", admin: true, response: dto.HostUtilizationResponse{}},
+	{pattern: "GET /v1/reports/providers", summary: "Host counts per provider", tag: "reports", admin: true, query: []string{"country"}, response: dto.ProvidersReportResponse{}},
+	{pattern: "GET /v1/hosts/availability", summary: "Countries with hosts available for key generation", tag: "hosts", query: []string{"tier"}, response: dto.HostAvailabilityResponse{}},
+	{pattern: "GET /v1/reports/host-availability", summary: "Available free and paid hosts per country", tag: "reports", admin: true, response: dto.HostAvailabilityReportResponse{}},
+
+	{pattern: "GET /v1/users/{userID}/vless-key", summary: "Generate a VLESS key for a user", tag: "keys", query: []string{"country", "network", "security", "address_family", "allow_free_fallback", "remarks", "remarks_template"}, response: dto.VlessKeyResponse{}},
+	{pattern: "GET /v1/users/{userID}/vless-key/config", summary: "Generate a VLESS client configuration for a user", tag: "keys", query: []string{"country", "network", "security", "address_family", "allow_free_fallback", "remarks", "remarks_template"}, response: dto.VlessConfigResponse{}},
+	{pattern: "GET /v1/reports/key-generation-rate", summary: "Free and user keys generated per time bucket", tag: "reports", admin: true, query: []string{"window", "bucket"}, response: dto.KeyGenerationRateResponse{}},
+	{pattern: "GET /v1/reports/overview", summary: "Dashboard overview of users, subscriptions, hosts and keys", tag: "reports", admin: true, response: dto.OverviewResponse{}},
+	{pattern: "GET /v1/reports/conversion-funnel", summary: "Users issued free-tier keys who subscribed and paid afterwards", tag: "reports", admin: true, query: []string{"from", "to"}, response: dto.ConversionFunnelResponse{}},
+	{pattern: "GET /v1/users/{userID}/subscription.txt", summary: "A user's VLESS keys as a base64 subscription feed", tag: "keys", query: []string{"remarks", "remarks_template"}, contentType: subscriptionFeedContentType},
+	{pattern: "GET /v1/users/{userID}/vless-keys", summary: "Generate one VLESS key per country for a user", tag: "keys", query: []string{"countries", "remarks", "remarks_template"}, response: dto.MultiCountryKeysResponse{}},
+	{pattern: "GET /v1/users/{userID}/trojan-key", summary: "Generate a trojan key for a user", tag: "keys", query: []string{"country", "network", "security", "address_family", "allow_free_fallback", "remarks", "remarks_template"}, response: dto.TrojanKeyResponse{}},
+	{pattern: "GET /v1/users/{userID}/vless-key/qr", summary: "Generate a VLESS key for a user as a QR code", tag: "keys", query: []string{"country", "network", "security", "address_family", "allow_free_fallback", "remarks", "remarks_template", "size"}, contentType: "image/png"},
+	{pattern: "GET /v1/users/{userID}/current-key", summary: "Get a user's current VLESS key", tag: "keys", response: dto.VlessKeyResponse{}},
+	{pattern: "POST /v1/users/{userID}/reassign-host", summary: "Move a user to another host", tag: "keys", admin: true, request: dto.ReassignHostRequest{}, response: dto.ReassignHostResponse{}},
+	{pattern: "GET /v1/key/free", summary: "Generate a free-tier VLESS key", tag: "keys", query: []string{"country", "network", "security", "address_family", "remarks", "remarks_template"}, response: dto.VlessKeyResponse{}},
+	{pattern: "GET /v1/key/free/qr", summary: "Generate a free-tier VLESS key as a QR code", tag: "keys", query: []string{"country", "network", "security", "address_family", "remarks", "remarks_template", "size"}, contentType: "image/png"},
+	{pattern: "POST /v1/keys/free/batch", summary: "Generate a batch of free-tier VLESS keys", tag: "keys", admin: true, query: []string{"count", "country", "remarks", "remarks_template"}, response: dto.FreeKeyBatchResponse{}},
+
+	{pattern: "GET /v1/plans", summary: "List plans", tag: "plans", query: []string{"active_only"}, response: dto.PlanResponse{}, itemsKey: "plans"},
+	{pattern: "GET /v1/plans/{planID}", summary: "Get a plan", tag: "plans", response: dto.PlanResponse{}},
+	{pattern: "POST /v1/plans", summary: "Create a plan", tag: "plans", admin: true, request: dto.CreatePlanRequest{}, response: dto.PlanResponse{}, status: http.StatusCreated},
+	{pattern: "PUT /v1/plans/{planID}", summary: "Update a plan", tag: "plans", admin: true, request: dto.UpdatePlanRequest{}, response: dto.PlanResponse{}},
+	{pattern: "DELETE /v1/plans/{planID}", summary: "Soft-delete a plan", tag: "plans", admin: true, status: http.StatusNoContent},
+
+	{pattern: "POST /v1/promo-codes", summary: "Create a promo code", tag: "promo-codes", admin: true, request: dto.CreatePromoCodeRequest{}, response: dto.PromoCodeResponse{}, status: http.StatusCreated},
+	{pattern: "GET /v1/promo-codes/{code}", summary: "Validate a promo code", tag: "promo-codes", response: dto.PromoCodeResponse{}},
+
+	{pattern: "POST /v1/users/{userID}/subscriptions", summary: "Create a subscription for a user", tag: "subscriptions", request: dto.CreateSubscriptionRequest{}, response: dto.SubscriptionResponse{}, status: http.StatusCreated},
+	{pattern: "GET /v1/users/{userID}/subscriptions", summary: "List a user's subscriptions", tag: "subscriptions", query: []string{"sort_by", "sort_order", "status", "payment_status", "plan_name"},
+		response: dto.SubscriptionResponse{}, itemsKey: "subscriptions"},
+	{pattern: "GET /v1/users/{userID}/subscriptions.ics", summary: "A user's subscription dates as an iCalendar feed", tag: "subscriptions", contentType: iCalContentType},
+	{pattern: "GET /v1/users/{userID}/subscription-status", summary: "Check whether a user has an active subscription", tag: "subscriptions", response: dto.SubscriptionStatusResponse{}},
+	{pattern: "GET /v1/subscriptions/{subscriptionID}", summary: "Get a subscription", tag: "subscriptions", response: dto.SubscriptionResponse{}},
+	{pattern: "GET /v1/subscriptions/{subscriptionID}/events", summary: "List a subscription's lifecycle events", tag: "subscriptions", response: dto.SubscriptionEventResponse{}, itemsKey: "events"},
+	{pattern: "GET /v1/subscriptions/{subscriptionID}/usage", summary: "Get a subscription's metered usage per billing period", tag: "subscriptions", response: dto.UsageReportResponse{}},
+	{pattern: "POST /v1/subscriptions/{subscriptionID}/usage", summary: "Record metered usage", tag: "subscriptions", admin: true, request: dto.RecordUsageRequest{}, response: dto.SubscriptionUsageResponse{}},
+	{pattern: "PATCH /v1/subscriptions/{subscriptionID}/cancel", summary: "Cancel a subscription", tag: "subscriptions", response: dto.SubscriptionResponse{}},
+	{pattern: "PATCH /v1/subscriptions/{subscriptionID}/payment", summary: "Override a subscription's payment status", tag: "subscriptions", admin: true, request: dto.UpdateSubscriptionPaymentRequest{}, response: dto.SubscriptionResponse{}},
+	{pattern: "PATCH /v1/subscriptions/{subscriptionID}/autorenew", summary: "Enable or disable auto-renewal", tag: "subscriptions", request: dto.SetSubscriptionAutoRenewRequest{}, response: dto.SubscriptionResponse{}},
+	{pattern: "PATCH /v1/subscriptions/{subscriptionID}/expiry-notified", summary: "Mark the expiry reminder as sent", tag: "subscriptions", admin: true, response: dto.SubscriptionResponse{}},
+	{pattern: "GET /v1/subscriptions", summary: "List subscriptions", tag: "subscriptions", admin: true, query: []string{"payment_status", "is_active", "reminder_sent"},
+		response: dto.SubscriptionResponse{}, itemsKey: "subscriptions"},
+	{pattern: "DELETE /v1/subscriptions/{subscriptionID}", summary: "Delete a subscription", tag: "subscriptions", admin: true, response: map[string]string{}},
+	{pattern: "POST /v1/webhooks/payments", summary: "Receive a signed payment notification from the payment provider", tag: "subscriptions", request: dto.PaymentWebhookRequest{}, response: dto.PaymentWebhookResponse{}},
+	{pattern: "POST /v1/subscriptions/renewals/run", summary: "Run the auto-renewal job now", tag: "subscriptions", admin: true, response: dto.RenewalRunResponse{}},
+	{pattern: "GET /v1/reports/expiring-subscriptions", summary: "Users with subscriptions expiring soon", tag: "reports", admin: true, query: []string{"days_in_advance"},
+		response: dto.UserWithExpiringSubscriptionsResponse{}, itemsKey: "data"},
+	{pattern: "GET /v1/reports/active-by-plan", summary: "Active subscriptions of a plan", tag: "reports", admin: true, query: []string{"plan_name"},
+		response: dto.SubscriptionResponse{}, itemsKey: "subscriptions"},
+	{pattern: "GET /v1/reports/churn", summary: "Subscription churn over a period", tag: "reports", admin: true, query: []string{"from", "to"}, response: dto.ChurnReportResponse{}},
+	{pattern: "GET /v1/reports/subscriptions-by-currency", summary: "Subscriptions and revenue per currency over a period", tag: "reports", admin: true, query: []string{"from", "to"}, response: dto.SubscriptionsByCurrencyResponse{}},
+
+	{pattern: "POST /v1/users", summary: "Register a user", tag: "users", request: dto.CreateUserRequest{}, response: dto.CreateUserResponse{}, status: http.StatusCreated},
+	{pattern: "POST /v1/onboard", summary: "Create a user together with their first subscription", tag: "users", request: dto.OnboardRequest{}, response: dto.OnboardResponse{}, status: http.StatusCreated},
+	{pattern: "GET /v1/users/{userID}", summary: "Get a user", tag: "users", response: dto.UserResponse{}},
+	{pattern: "GET /v1/users/by-telegram/{telegramID}", summary: "Get a user by Telegram ID", tag: "users", admin: true, response: dto.UserResponse{}},
+	{pattern: "PUT /v1/users/{userID}", summary: "Update a user", tag: "users", request: dto.UpdateUserRequest{}, response: dto.UserResponse{}},
+	{pattern: "DELETE /v1/users/{userID}", summary: "Delete a user", tag: "users", query: []string{"hard", "force"}, response: map[string]string{}},
+	{pattern: "POST /v1/users/{userID}/restore", summary: "Restore a soft-deleted user", tag: "users", admin: true, response: dto.UserResponse{}},
+	{pattern: "GET /v1/users", summary: "List users", tag: "users", admin: true, query: []string{"sort_by", "sort_order", "q", "is_active", "has_telegram", "created_after", "created_before", "include_deleted"},
+		response: dto.UserResponse{}, itemsKey: "users", cursor: true},
+	{pattern: "PATCH /v1/users/{userID}/role", summary: "Change a user's role", tag: "users", admin: true, request: dto.UpdateUserRoleRequest{}, response: dto.UserResponse{}},
+	{pattern: "POST /v1/users/{userID}/login-events", summary: "Record a user login", tag: "users", admin: true, status: http.StatusNoContent},
+	{pattern: "GET /v1/reports/inactive-users", summary: "Users who have not logged in recently", tag: "reports", admin: true, query: []string{"days"},
+		response: dto.UserResponse{}, itemsKey: "users"},
+	{pattern: "GET /v1/reports/users-by-host-country", summary: "Users with an active key on a host in a country", tag: "reports", admin: true, query: []string{"country"},
+		response: dto.UserResponse{}, itemsKey: "users"},
+}
+
+// pathParamPattern matches the wildcards of a ServeMux path, e.g. "{userID}".
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// buildOpenAPIDocument generates the OpenAPI document for the given operations.
+func buildOpenAPIDocument(operations []apiOperation) map[string]any {
+	b := newSchemaBuilder()
+	b.components["ErrorResponse"] = b.schemaFor(reflect.TypeOf(dto.ErrorResponse{}))
+	b.components["ErrorResponse"]["example"] = dto.ErrorResponse{Code: errorCodeNotFound, Message: "User not found.", Error: "User not found."}
+	b.components["Pagination"] = b.schemaFor(reflect.TypeOf(dto.Pagination{}))
+	b.components["Pagination"]["example"] = dto.NewPagination(2, 20, 45)
+	b.components["CursorPagination"] = b.schemaFor(reflect.TypeOf(dto.CursorPagination{}))
+	b.components["CursorPagination"]["example"] = dto.CursorPagination{PageSize: 20, NextCursor: "eyJpZCI6NDJ9"}
+
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		method, path, _ := strings.Cut(op.pattern, " ")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = b.operation(op, path)
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "Bitten API",
+			"version":     "v1",
+			"description": "Requests are authenticated by the API gateway, which passes the user's ID in the " + userIDHeader + " header.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"gatewayUser": map[string]any{"type": "apiKey", "in": "header", "name": userIDHeader},
+			},
+		},
+	}
+}
+
+// operation generates the OpenAPI operation object for op.
+func (b *schemaBuilder) operation(op apiOperation, path string) map[string]any {
+	var params []map[string]any
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	query := op.query
+	if op.itemsKey != "" {
+		query = append([]string{"page", "pageSize"}, query...)
+		if op.cursor {
+			query = append(query, cursorQueryParam)
+		}
+	}
+	for _, name := range query {
+		params = append(params, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.contentType != "":
+		success["content"] = map[string]any{op.contentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+	case op.itemsKey != "":
+		success["content"] = jsonContent(b.paginatedSchema(op))
+	case op.response != nil:
+		success["content"] = jsonContent(b.schemaFor(reflect.TypeOf(op.response)))
+	}
+
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     jsonContent(map[string]any{"$ref": "#/components/schemas/ErrorResponse"}),
+	}
+	result := map[string]any{
+		"summary": op.summary,
+		"tags":    []string{op.tag},
+		"responses": map[string]any{
+			strconv.Itoa(status): success,
+			"default":            errorResponse,
+		},
+		"security": []map[string][]string{{"gatewayUser": {}}},
+	}
+	if op.admin {
+		result["description"] = "Requires the admin role."
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+	if op.request != nil {
+		result["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(b.schemaFor(reflect.TypeOf(op.request))),
+		}
+	}
+	return result
+}
+
+// paginatedSchema returns the schema of the paginated envelope of op: its items under op.itemsKey
+// together with the offset or, for cursor-paginated routes, either pagination metadata.
+func (b *schemaBuilder) paginatedSchema(op apiOperation) map[string]any {
+	items := map[string]any{
+		"type":       "object",
+		"required":   []string{op.itemsKey},
+		"properties": map[string]any{op.itemsKey: map[string]any{"type": "array", "items": b.schemaFor(reflect.TypeOf(op.response))}},
+	}
+	envelope := func(meta string) map[string]any {
+		return map[string]any{"allOf": []any{items, map[string]any{"$ref": "#/components/schemas/" + meta}}}
+	}
+	if op.cursor {
+		return map[string]any{"oneOf": []any{envelope("Pagination"), envelope("CursorPagination")}}
+	}
+	return envelope("Pagination")
+}
+
+// jsonContent returns an OpenAPI content map with schema as its JSON media type.
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaBuilder derives OpenAPI schemas from Go types, collecting named structs as components.
+type schemaBuilder struct {
+	components map[string]map[string]any
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]map[string]any{}}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the schema of t. Named structs are added to the components and referenced.
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := b.schemaFor(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			b.components[t.Name()] = map[string]any{} // Placeholder that breaks recursion.
+			b.components[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the object schema of struct type t, following encoding/json's field rules:
+// fields are named by their json tag, omitempty fields are optional, and embedded structs are inlined.
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = b.schemaFor(field.Type)
+			if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// swaggerUIPage is the documentation page; it renders the OpenAPI document with Swagger UI loaded from a CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Bitten API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js"></script>
+  <script>window.ui = SwaggerUIBundle({url: "/v1/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// serveOpenAPIDocument returns a handler that serves the OpenAPI document, encoded once up front.
+func serveOpenAPIDocument() http.HandlerFunc {
+	document, err := json.Marshal(buildOpenAPIDocument(apiOperations))
+	if err != nil {
+		// Every schema is built from plain maps and DTO values, so this indicates a programming error.
+		panic("handlers: failed to encode OpenAPI document: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.Write(document); err != nil {
+			slog.ErrorContext(r.Context(), "OpenAPI: failed to write document", "error", err)
+		}
+	}
+}
+
+// serveSwaggerUI serves the Swagger UI page for the OpenAPI document.
+func serveSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write([]byte(swaggerUIPage)); err != nil {
+		slog.ErrorContext(r.Context(), "OpenAPI: failed to write Swagger UI page", "error", err)
+	}
+}
+
+// undocumentedRoutes returns the patterns that are not described by any of the operations.
+func undocumentedRoutes(patterns []string, operations []apiOperation) []string {
+	documented := make(map[string]bool, len(operations))
+	for _, op := range operations {
+		documented[op.pattern] = true
+	}
+	var missing []string
+	for _, pattern := range patterns {
+		if !documented[pattern] {
+			missing = append(missing, pattern)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newDocumentedRouter returns a router with every route the application registers.
+func newDocumentedRouter() *Router {
+	cfg := &config.Config{DefaultPageSize: 10}
+	router := NewRouter()
+	router.RegisterUserRoutes(NewUserHandler(&fakeUserService{}, cfg))
+	router.RegisterSubscriptionRoutes(NewSubscriptionHandler(&fakeSubscriptionService{}, cfg))
+	router.RegisterOnboardingRoutes(NewOnboardingHandler(nil))
+	router.RegisterHostRoutes(NewHostHandler(&fakeHostService{}, cfg))
+	router.RegisterPlanRoutes(NewPlanHandler(nil, cfg))
+	router.RegisterPromoCodeRoutes(NewPromoCodeHandler(nil))
+	router.RegisterKeyRoutes(NewKeyHandler(&fakeKeyService{}, cfg))
+	router.RegisterReportRoutes(NewReportHandler(nil))
+	router.RegisterAuthRoutes(NewAuthHandler())
+	router.RegisterHealthRoutes(NewHealthHandler(nil))
+	router.EnableMetrics(nil, http.NotFoundHandler())
+	router.EnableOpenAPI()
+	return router
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	router := newDocumentedRouter()
+	if len(router.patterns) == 0 {
+		t.Fatal("no routes were registered")
+	}
+	if missing := router.UndocumentedRoutes(); len(missing) > 0 {
+		t.Errorf("routes missing from the OpenAPI document: %v", missing)
+	}
+
+	// Every documented operation must also be served, so the document does not list removed routes.
+	for _, op := range apiOperations {
+		if !slices.Contains(router.patterns, op.pattern) {
+			t.Errorf("documented route %q is not registered", op.pattern)
+		}
+	}
+}
+
+func TestUndocumentedRoutes(t *testing.T) {
+	operations := []apiOperation{{pattern: "GET /v1/users"}, {pattern: "POST /v1/users/{userID}/restore"}}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{name: "all documented", patterns: []string{"GET /v1/users", "POST /v1/users/{userID}/restore"}},
+		{name: "no routes"},
+		{name: "missing route", patterns: []string{"GET /v1/users", "DELETE /v1/users/{userID}"}, want: []string{"DELETE /v1/users/{userID}"}},
+		{name: "method must match", patterns: []string{"POST /v1/users"}, want: []string{"POST /v1/users"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := undocumentedRoutes(tt.patterns, operations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("undocumentedRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeOpenAPIDocument(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newDocumentedRouter().GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, openAPIVersion)
+	}
+	for _, op := range apiOperations {
+		method, path, _ := strings.Cut(op.pattern, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("document does not describe %q", op.pattern)
+		}
+	}
+	for _, name := range []string{"ErrorResponse", "Pagination", "CursorPagination"} {
+		if _, ok := doc.Components.Schemas[name]["example"]; !ok {
+			t.Errorf("component %s has no example", name)
+		}
+	}
+}
+
+func TestServeSwaggerUI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newDocumentedRouter().GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/docs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `url: "/v1/openapi.json"`) {
+		t.Errorf("page does not load the OpenAPI document: %s", rec.Body.String())
+	}
+}
+
+func TestOperationResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		op         apiOperation
+		wantStatus string
+		wantSchema string // JSON fragment the success response must contain.
+	}{
+		{
+			name:       "paginated",
+			op:         apiOperation{pattern: "GET /v1/users", response: struct{}{}, itemsKey: "users"},
+			wantStatus: "200",
+			wantSchema: `"$ref":"#/components/schemas/Pagination"`,
+		},
+		{
+			name:       "cursor paginated",
+			op:         apiOperation{pattern: "GET /v1/users", response: struct{}{}, itemsKey: "users", cursor: true},
+			wantStatus: "200",
+			wantSchema: `"$ref":"#/components/schemas/CursorPagination"`,
+		},
+		{
+			name:       "no content",
+			op:         apiOperation{pattern: "POST /v1/users/{userID}/login-events", status: http.StatusNoContent},
+			wantStatus: "204",
+			wantSchema: `"description":"No Content"`,
+		},
+		{
+			name:       "non-JSON body",
+			op:         apiOperation{pattern: "GET /metrics", contentType: "text/plain"},
+			wantStatus: "200",
+			wantSchema: `"text/plain"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, path, _ := strings.Cut(tt.op.pattern, " ")
+			op := newSchemaBuilder().operation(tt.op, path)
+			responses := op["responses"].(map[string]any)
+
+			success, err := json.Marshal(responses[tt.wantStatus])
+			if err != nil {
+				t.Fatalf("failed to encode response %s: %v", tt.wantStatus, err)
+			}
+			if !strings.Contains(string(success), tt.wantSchema) {
+				t.Errorf("response %s = %s, want it to contain %s", tt.wantStatus, success, tt.wantSchema)
+			}
+			errorBody, _ := json.Marshal(responses["default"])
+			if !strings.Contains(string(errorBody), `"$ref":"#/components/schemas/ErrorResponse"`) {
+				t.Errorf("default response = %s, want the ErrorResponse schema", errorBody)
+			}
+		})
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type embedded struct {
+		Inlined string `json:"inlined"`
+	}
+	type sample struct {
+		embedded
+		ID       uuid.UUID  `json:"id"`
+		Created  time.Time  `json:"created_at"`
+		Expires  *time.Time `json:"expires_at,omitempty"`
+		Count    int        `json:"count"`
+		Tags     []string   `json:"tags,omitempty"`
+		Internal string     `json:"-"`
+		Untagged bool
+		hidden   string
+	}
+
+	schema := newSchemaBuilder().structSchema(reflect.TypeOf(sample{}))
+	properties := schema["properties"].(map[string]any)
+
+	tests := []struct {
+		property string
+		want     map[string]any // nil if the property must not be listed.
+	}{
+		{property: "inlined", want: map[string]any{"type": "string"}},
+		{property: "id", want: map[string]any{"type": "string", "format": "uuid"}},
+		{property: "created_at", want: map[string]any{"type": "string", "format": "date-time"}},
+		{property: "expires_at", want: map[string]any{"type": "string", "format": "date-time", "nullable": true}},
+		{property: "count", want: map[string]any{"type": "integer"}},
+		{property: "tags", want: map[string]any{"type": "array", "items": map[string]any{"type": "string"}}},
+		{property: "Untagged", want: map[string]any{"type": "boolean"}},
+		{property: "Internal"},
+		{property: "-"},
+		{property: "hidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.property, func(t *testing.T) {
+			got, ok := properties[tt.property]
+			if tt.want == nil {
+				if ok {
+					t.Errorf("property %q is listed: %v", tt.property, got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("property %q = %v, want %v", tt.property, got, tt.want)
+			}
+		})
+	}
+
+	wantRequired := []string{"inlined", "id", "created_at", "count", "Untagged"}
+	if got := schema["required"]; !reflect.DeepEqual(got, wantRequired) {
+		t.Errorf("required = %v, want %v", got, wantRequired)
+	}
+}
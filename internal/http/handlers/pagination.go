@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// maxPageSize is the largest page size accepted by list endpoints.
+const maxPageSize = 100
+
+// pageParams holds the page number and page size requested by a client.
+type pageParams struct {
+	Page     int
+	PageSize int
+}
+
+// parsePagination reads the 'page' and 'pageSize' query parameters.
+// Missing or invalid values fall back to page 1 and defaultPageSize; the page size is capped at maxPageSize.
+func parsePagination(r *http.Request, defaultPageSize int) pageParams {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(query.Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return pageParams{Page: page, PageSize: pageSize}
+}
+
+// newPaginatedResponse wraps a page of items in the shared paginated envelope under itemsKey.
+// A page past the end of the result set is returned without items. operation names the calling
+// handler in logs.
+func newPaginatedResponse[T any](ctx context.Context, operation, itemsKey string, items []T, params pageParams, totalItems int64) dto.Paginated[T] {
+	pagination := dto.NewPagination(params.Page, params.PageSize, totalItems)
+	if params.Page > pagination.TotalPages && pagination.TotalPages > 0 {
+		items = []T{}
+		slog.WarnContext(ctx, operation+": requested page is out of bounds",
+			"requested_page", params.Page, "total_pages", pagination.TotalPages, "total_items", totalItems)
+	}
+	return dto.NewPaginated(itemsKey, items, pagination)
+}
+
+// newCursorPaginatedResponse wraps a keyset-paginated page of items in the cursor envelope under itemsKey.
+// nextCursor is empty on the last page.
+func newCursorPaginatedResponse[T any](itemsKey string, items []T, pageSize int, nextCursor string) dto.CursorPaginated[T] {
+	return dto.NewCursorPaginated(itemsKey, items, dto.CursorPagination{
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestConfiguredDefaultPageSize(t *testing.T) {
+	cfg := &config.Config{
+		DefaultPageSize: 15,
+		PageSizeByEndpoint: map[string]int{
+			config.PageSizeEndpointHosts: 50,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantHosts int
+		wantSubs  int
+	}{
+		{name: "per-endpoint and global defaults", query: "", wantHosts: 50, wantSubs: 15},
+		{name: "explicit pageSize wins", query: "?pageSize=7", wantHosts: 7, wantSubs: 7},
+		{name: "invalid pageSize falls back", query: "?pageSize=0", wantHosts: 50, wantSubs: 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHosts, gotSubs int
+			hostSvc := &fakeHostService{
+				listHosts: func(_ context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+					gotHosts = params.PageSize
+					return nil, 0, nil
+				},
+			}
+			subSvc := &fakeSubscriptionService{
+				listSubscriptions: func(_ context.Context, _ customTypes.ListSubscriptionsFilters, _, pageSize int) ([]models.Subscription, int64, error) {
+					gotSubs = pageSize
+					return nil, 0, nil
+				},
+			}
+
+			admin := uuid.New()
+			rec := serveRoutes(NewHostHandler(hostSvc, cfg).RegisterRoutes,
+				asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil), admin, customTypes.RoleAdmin))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("hosts status = %d, want 200; body %s", rec.Code, rec.Body)
+			}
+			rec = serveRoutes(NewSubscriptionHandler(subSvc, cfg).RegisterRoutes,
+				asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/subscriptions"+tt.query, nil), admin, customTypes.RoleAdmin))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("subscriptions status = %d, want 200; body %s", rec.Code, rec.Body)
+			}
+
+			if gotHosts != tt.wantHosts {
+				t.Errorf("hosts page size = %d, want %d", gotHosts, tt.wantHosts)
+			}
+			if gotSubs != tt.wantSubs {
+				t.Errorf("subscriptions page size = %d, want %d", gotSubs, tt.wantSubs)
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  pageParams
+	}{
+		{name: "defaults", want: pageParams{Page: 1, PageSize: 20}},
+		{name: "explicit values", query: "?page=3&pageSize=50", want: pageParams{Page: 3, PageSize: 50}},
+		{name: "page below 1", query: "?page=0&pageSize=5", want: pageParams{Page: 1, PageSize: 5}},
+		{name: "negative page size", query: "?page=2&pageSize=-5", want: pageParams{Page: 2, PageSize: 20}},
+		{name: "not numbers", query: "?page=two&pageSize=many", want: pageParams{Page: 1, PageSize: 20}},
+		{name: "page size is capped", query: "?pageSize=1000", want: pageParams{Page: 1, PageSize: maxPageSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parsePagination(httptest.NewRequest(http.MethodGet, "/v1/hosts"+tt.query, nil), 20)
+			if got != tt.want {
+				t.Errorf("parsePagination() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPaginatedResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      int
+		wantItems int
+	}{
+		{name: "page within the results", page: 2, wantItems: 1},
+		{name: "page past the end", page: 5, wantItems: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newPaginatedResponse(context.Background(), "Test", "hosts", []string{"a"}, pageParams{Page: tt.page, PageSize: 2}, 3)
+			if len(got.Items) != tt.wantItems || got.Items == nil {
+				t.Errorf("items = %v, want %d items", got.Items, tt.wantItems)
+			}
+			if got.ItemsKey != "hosts" || got.TotalItems != 3 || got.TotalPages != 2 || got.CurrentPage != tt.page {
+				t.Errorf("pagination = %+v, want page %d of 2 over 3 hosts", got.Pagination, tt.page)
+			}
+		})
+	}
+}
+
+// TestPaginationFlags checks that every page-numbered list endpoint reports has_next and has_previous.
+func TestPaginationFlags(t *testing.T) {
+	cfg := &config.Config{DefaultPageSize: 10}
+	const totalItems = 25
+	hostSvc := &fakeHostService{
+		listHosts: func(context.Context, serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+			return []models.Host{{ID: 1}}, totalItems, nil
+		},
+	}
+	subSvc := &fakeSubscriptionService{
+		listSubscriptions: func(context.Context, customTypes.ListSubscriptionsFilters, int, int) ([]models.Subscription, int64, error) {
+			return []models.Subscription{{ID: uuid.New()}}, totalItems, nil
+		},
+	}
+	userSvc := &fakeUserService{
+		listUsers: func(context.Context, serviceDTO.ListUsersServiceParams) ([]models.User, int64, error) {
+			return []models.User{{ID: uuid.New()}}, totalItems, nil
+		},
+	}
+	endpoints := []struct {
+		path     string
+		register func(mux RouteRegistrar)
+	}{
+		{path: "/v1/hosts", register: NewHostHandler(hostSvc, cfg).RegisterRoutes},
+		{path: "/v1/subscriptions", register: NewSubscriptionHandler(subSvc, cfg).RegisterRoutes},
+		{path: "/v1/users", register: NewUserHandler(userSvc, cfg).RegisterRoutes},
+	}
+
+	tests := []struct {
+		name            string
+		page            int
+		wantHasNext     bool
+		wantHasPrevious bool
+	}{
+		{name: "first page", page: 1, wantHasNext: true},
+		{name: "middle page", page: 2, wantHasNext: true, wantHasPrevious: true},
+		{name: "last page", page: 3, wantHasPrevious: true},
+	}
+	for _, tt := range tests {
+		for _, endpoint := range endpoints {
+			t.Run(tt.name+" "+endpoint.path, func(t *testing.T) {
+				req := asPrincipal(httptest.NewRequest(http.MethodGet, endpoint.path+"?page="+strconv.Itoa(tt.page), nil), uuid.New(), customTypes.RoleAdmin)
+				rec := serveRoutes(endpoint.register, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+				}
+				got := decodeJSON[struct {
+					HasNext     *bool `json:"has_next"`
+					HasPrevious *bool `json:"has_previous"`
+				}](t, rec)
+				if got.HasNext == nil || got.HasPrevious == nil {
+					t.Fatalf("body %s does not report has_next and has_previous", rec.Body)
+				}
+				if *got.HasNext != tt.wantHasNext || *got.HasPrevious != tt.wantHasPrevious {
+					t.Errorf("has_next = %t, has_previous = %t; want %t, %t", *got.HasNext, *got.HasPrevious, tt.wantHasNext, tt.wantHasPrevious)
+				}
+			})
+		}
+	}
+}
+
+// TestListHandlersUseParsePagination checks that the list endpoints pass the parsed and clamped page
+// parameters on to their services.
+func TestListHandlersUseParsePagination(t *testing.T) {
+	cfg := &config.Config{DefaultPageSize: 10}
+	admin := uuid.New()
+
+	tests := []struct {
+		name         string
+		query        string
+		wantPage     int
+		wantPageSize int
+	}{
+		{name: "defaults", wantPage: 1, wantPageSize: 10},
+		{name: "explicit values", query: "?page=2&pageSize=30", wantPage: 2, wantPageSize: 30},
+		{name: "clamped", query: "?page=-1&pageSize=1000", wantPage: 1, wantPageSize: maxPageSize},
+		{name: "invalid input", query: "?page=x&pageSize=y", wantPage: 1, wantPageSize: 10},
+	}
+	for _, tt := range tests {
+		var gotPage, gotPageSize int
+		endpoints := []struct {
+			path     string
+			register func(mux RouteRegistrar)
+		}{
+			{path: "/v1/hosts", register: NewHostHandler(&fakeHostService{
+				listHosts: func(_ context.Context, params serviceDTO.ListHostsServiceParams) ([]models.Host, int64, error) {
+					gotPage, gotPageSize = params.Page, params.PageSize
+					return nil, 0, nil
+				},
+			}, cfg).RegisterRoutes},
+			{path: "/v1/subscriptions", register: NewSubscriptionHandler(&fakeSubscriptionService{
+				listSubscriptions: func(_ context.Context, _ customTypes.ListSubscriptionsFilters, page, pageSize int) ([]models.Subscription, int64, error) {
+					gotPage, gotPageSize = page, pageSize
+					return nil, 0, nil
+				},
+			}, cfg).RegisterRoutes},
+			{path: "/v1/users/" + admin.String() + "/subscriptions", register: NewSubscriptionHandler(&fakeSubscriptionService{
+				listUserSubs: func(_ context.Context, _ uuid.UUID, params serviceDTO.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
+					gotPage, gotPageSize = params.Page, params.PageSize
+					return nil, 0, nil
+				},
+			}, cfg).RegisterRoutes},
+			{path: "/v1/users", register: NewUserHandler(&fakeUserService{
+				listUsers: func(_ context.Context, params serviceDTO.ListUsersServiceParams) ([]models.User, int64, error) {
+					gotPage, gotPageSize = params.Page, params.PageSize
+					return nil, 0, nil
+				},
+			}, cfg).RegisterRoutes},
+		}
+		for _, endpoint := range endpoints {
+			t.Run(tt.name+" "+endpoint.path, func(t *testing.T) {
+				gotPage, gotPageSize = 0, 0
+				req := asPrincipal(httptest.NewRequest(http.MethodGet, endpoint.path+tt.query, nil), admin, customTypes.RoleAdmin)
+				rec := serveRoutes(endpoint.register, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+				}
+				if gotPage != tt.wantPage || gotPageSize != tt.wantPageSize {
+					t.Errorf("service got page %d of size %d, want %d of size %d", gotPage, gotPageSize, tt.wantPage, tt.wantPageSize)
+				}
+			})
+		}
+	}
+}
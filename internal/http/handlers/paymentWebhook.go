@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	serviceDTO "bitback/internal/services/dto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// paymentSignatureHeader carries the hex-encoded HMAC-SHA256 of the raw webhook body, optionally prefixed with "sha256=".
+	paymentSignatureHeader = "X-Payment-Signature"
+	// maxPaymentWebhookBodyBytes is the largest payment webhook body accepted.
+	maxPaymentWebhookBodyBytes = 64 << 10
+)
+
+// Outcomes of a payment webhook delivery reported back to the provider.
+const (
+	paymentWebhookApplied   = "applied"   // The payment was applied to the subscription.
+	paymentWebhookDuplicate = "duplicate" // The payment had already been applied; nothing changed.
+	paymentWebhookIgnored   = "ignored"   // The event does not report a completed payment; nothing changed.
+)
+
+// HandlePaymentWebhook handles payment notifications from the payment provider.
+// The raw body must be signed with PAYMENT_WEBHOOK_SECRET in the X-Payment-Signature header; unsigned or wrongly
+// signed requests get 401 without details. "paid" events are applied to the subscription at most once per
+// external_payment_id, so redelivered events get 200 without side effects. Other statuses are acknowledged and ignored.
+// Expected route: POST /api/v1/webhooks/payments
+func (h *SubscriptionHandler) HandlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.cfg.PaymentWebhookSecret == "" {
+		slog.WarnContext(ctx, "HandlePaymentWebhook: payment webhook received but PAYMENT_WEBHOOK_SECRET is not set")
+		respondWithError(w, http.StatusServiceUnavailable, "Payment webhooks are not configured.")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPaymentWebhookBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Payment webhook body is too large.")
+			return
+		}
+		slog.WarnContext(ctx, "HandlePaymentWebhook: failed to read request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Failed to read request body.")
+		return
+	}
+
+	if !validPaymentSignature(body, r.Header.Get(paymentSignatureHeader), h.cfg.PaymentWebhookSecret) {
+		slog.WarnContext(ctx, "HandlePaymentWebhook: invalid payment webhook signature", "remoteAddr", r.RemoteAddr)
+		respondWithError(w, http.StatusUnauthorized, "Invalid signature.")
+		return
+	}
+
+	var req dto.PaymentWebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		slog.WarnContext(ctx, "HandlePaymentWebhook: failed to decode payment webhook", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if req.SubscriptionID == uuid.Nil || strings.TrimSpace(req.ExternalPaymentID) == "" || req.Status == "" {
+		respondWithError(w, http.StatusBadRequest, "Fields 'subscription_id', 'status' and 'external_payment_id' are required.")
+		return
+	}
+
+	resp := dto.PaymentWebhookResponse{SubscriptionID: req.SubscriptionID, Result: paymentWebhookIgnored}
+	if !strings.EqualFold(req.Status, "paid") {
+		slog.InfoContext(ctx, "HandlePaymentWebhook: ignoring payment event", "subscriptionID", req.SubscriptionID, "status", req.Status, "externalPaymentID", req.ExternalPaymentID)
+		respondWithJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	_, applied, err := h.subService.ApplyPayment(ctx, serviceDTO.ApplyPaymentInput{
+		SubscriptionID:    req.SubscriptionID,
+		ExternalPaymentID: req.ExternalPaymentID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		PaidAt:            req.Timestamp,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "HandlePaymentWebhook: failed to apply payment via service", "subscriptionID", req.SubscriptionID, "externalPaymentID", req.ExternalPaymentID, "error", err)
+		respondWithServiceError(w, err, "Failed to apply payment.")
+		return
+	}
+
+	resp.Result = paymentWebhookDuplicate
+	if applied {
+		resp.Result = paymentWebhookApplied
+	}
+	slog.InfoContext(ctx, "HandlePaymentWebhook: payment event processed", "subscriptionID", req.SubscriptionID, "externalPaymentID", req.ExternalPaymentID, "result", resp.Result)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// validPaymentSignature reports whether signature is the hex-encoded HMAC-SHA256 of body under secret.
+// A "sha256=" prefix is accepted. The comparison takes constant time.
+func validPaymentSignature(body []byte, signature, secret string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) != sha256.Size {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// signPayment returns the hex-encoded HMAC-SHA256 of body under secret, as the payment provider sends it.
+func signPayment(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidPaymentSignature(t *testing.T) {
+	const body, secret = `{"status":"paid"}`, "s3cret"
+	valid := signPayment(body, secret)
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      bool
+	}{
+		{name: "valid", body: body, signature: valid, want: true},
+		{name: "sha256 prefix", body: body, signature: "sha256=" + valid, want: true},
+		{name: "surrounding whitespace", body: body, signature: " " + valid + " ", want: true},
+		{name: "upper-case hex", body: body, signature: strings.ToUpper(valid), want: true},
+		{name: "tampered body", body: `{"status":"paid "}`, signature: valid},
+		{name: "other secret", body: body, signature: signPayment(body, "other")},
+		{name: "missing", body: body},
+		{name: "not hex", body: body, signature: "zz" + valid[2:]},
+		{name: "truncated", body: body, signature: valid[:len(valid)-2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validPaymentSignature([]byte(tt.body), tt.signature, secret); got != tt.want {
+				t.Errorf("validPaymentSignature(%q, %q) = %t, want %t", tt.body, tt.signature, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlePaymentWebhook(t *testing.T) {
+	const secret = "s3cret"
+	subID := uuid.New()
+	paidAt := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	payload := func(status string) string {
+		return fmt.Sprintf(`{"subscription_id":%q,"status":%q,"amount":9.99,"currency":"USD","external_payment_id":"pay_1","timestamp":%q}`,
+			subID, status, paidAt.Format(time.RFC3339))
+	}
+
+	tests := []struct {
+		name        string
+		secret      string // Configured webhook secret.
+		body        string
+		signature   *string // Defaults to the valid signature of body.
+		applied     bool
+		serviceErr  error
+		wantStatus  int
+		wantResult  string
+		wantApplied bool // Whether the service must be called.
+	}{
+		{name: "applied", secret: secret, body: payload("paid"), applied: true,
+			wantStatus: http.StatusOK, wantResult: "applied", wantApplied: true},
+		{name: "replayed", secret: secret, body: payload("PAID"),
+			wantStatus: http.StatusOK, wantResult: "duplicate", wantApplied: true},
+		{name: "other status ignored", secret: secret, body: payload("failed"), wantStatus: http.StatusOK, wantResult: "ignored"},
+		{name: "invalid signature", secret: secret, body: payload("paid"), signature: ptrTo(signPayment(payload("paid"), "guess")),
+			wantStatus: http.StatusUnauthorized},
+		{name: "missing signature", secret: secret, body: payload("paid"), signature: ptrTo(""), wantStatus: http.StatusUnauthorized},
+		{name: "not configured", body: payload("paid"), wantStatus: http.StatusServiceUnavailable},
+		{name: "invalid JSON", secret: secret, body: `{"status":`, wantStatus: http.StatusBadRequest},
+		{name: "missing payment ID", secret: secret, body: fmt.Sprintf(`{"subscription_id":%q,"status":"paid"}`, subID), wantStatus: http.StatusBadRequest},
+		{name: "body too large", secret: secret, body: `{"pad":"` + strings.Repeat("x", maxPaymentWebhookBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "payment of another subscription", secret: secret, body: payload("paid"),
+			serviceErr: fmt.Errorf("already applied: %w", services.ErrConflict), wantStatus: http.StatusConflict, wantApplied: true},
+		{name: "payment short of the price", secret: secret, body: payload("paid"),
+			serviceErr: fmt.Errorf("too little: %w", services.ErrValidation), wantStatus: http.StatusBadRequest, wantApplied: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *serviceDTO.ApplyPaymentInput
+			svc := &fakeSubscriptionService{
+				applyPayment: func(_ context.Context, input serviceDTO.ApplyPaymentInput) (*models.Subscription, bool, error) {
+					got = &input
+					if tt.serviceErr != nil {
+						return nil, false, tt.serviceErr
+					}
+					return &models.Subscription{ID: input.SubscriptionID}, tt.applied, nil
+				},
+			}
+			signature := signPayment(tt.body, secret)
+			if tt.signature != nil {
+				signature = *tt.signature
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/payments", strings.NewReader(tt.body))
+			req.Header.Set(paymentSignatureHeader, signature)
+
+			handler := NewSubscriptionHandler(svc, &config.Config{PaymentWebhookSecret: tt.secret})
+			rec := serveRoutes(handler.RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if (got != nil) != tt.wantApplied {
+				t.Fatalf("service called = %t, want %t", got != nil, tt.wantApplied)
+			}
+			if got != nil && (got.SubscriptionID != subID || got.ExternalPaymentID != "pay_1" || got.Amount != 9.99 || got.Currency != "USD" || !got.PaidAt.Equal(paidAt)) {
+				t.Errorf("service called with %+v, want the webhook's payment", *got)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				if body := decodeJSON[dto.ErrorResponse](t, rec); body.Message != "Invalid signature." {
+					t.Errorf("401 message = %q, want no details beyond %q", body.Message, "Invalid signature.")
+				}
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if resp := decodeJSON[dto.PaymentWebhookResponse](t, rec); resp.SubscriptionID != subID || resp.Result != tt.wantResult {
+				t.Errorf("response = %+v, want result %q for %s", resp, tt.wantResult, subID)
+			}
+		})
+	}
+}
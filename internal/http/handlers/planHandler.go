@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/interfaces"
+	serviceDTO "bitback/internal/services/dto"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PlanHandler handles HTTP requests related to the subscription plan catalog.
+type PlanHandler struct {
+	planService interfaces.PlanService
+	cfg         *config.Config
+}
+
+// NewPlanHandler creates a new instance of PlanHandler.
+func NewPlanHandler(ps interfaces.PlanService, cfg *config.Config) *PlanHandler {
+	return &PlanHandler{
+		planService: ps,
+		cfg:         cfg,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for plan-related actions.
+func (h *PlanHandler) RegisterRoutes(mux RouteRegistrar) {
+	mux.HandleFunc("GET /v1/plans", h.ListPlans)
+	mux.HandleFunc("GET /v1/plans/{planID}", h.GetPlan)
+
+	// Mutation routes are restricted to administrators.
+	mux.HandleFunc("POST /v1/plans", requireAdmin(h.CreatePlan))
+	mux.HandleFunc("PUT /v1/plans/{planID}", requireAdmin(h.UpdatePlan))
+	mux.HandleFunc("DELETE /v1/plans/{planID}", requireAdmin(h.DeletePlan)) // Soft delete.
+}
+
+// CreatePlan handles the request to add a new plan to the catalog.
+// Expected route: POST /api/v1/plans
+func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req dto.CreatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "CreatePlan: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	plan, err := h.planService.CreatePlan(ctx, serviceDTO.CreatePlanInput{
+		Name:          req.Name,
+		Description:   req.Description,
+		DurationUnit:  req.DurationUnit,
+		DurationValue: req.DurationValue,
+		Price:         req.Price,
+		Currency:      req.Currency,
+		IsActive:      req.IsActive,
+		IsFreeTier:    req.IsFreeTier,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "CreatePlan: failed to create plan via service", "error", err, "name", req.Name)
+		if strings.Contains(err.Error(), "already exists") {
+			respondWithError(w, http.StatusConflict, err.Error())
+		} else if strings.Contains(err.Error(), "invalid plan") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to create plan.")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toPlanResponse(plan))
+}
+
+// GetPlan handles the request to retrieve a plan by its ID.
+// Expected route: GET /api/v1/plans/{planID}
+func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	planIDStr := r.PathValue("planID")
+	planID, err := parseUint(planIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetPlan: invalid plan ID format in path", "planID_str", planIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid plan ID format provided.")
+		return
+	}
+
+	plan, err := h.planService.GetPlan(ctx, planID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "Plan not found.")
+		} else {
+			slog.ErrorContext(ctx, "GetPlan: failed to get plan from service", "error", err, "planID", planID)
+			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve plan.")
+		}
+		return
+	}
+	// Inactive plans are only visible to administrators.
+	if !plan.IsActive && !isAdminRequest(ctx) {
+		respondWithError(w, http.StatusNotFound, "Plan not found.")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toPlanResponse(plan))
+}
+
+// UpdatePlan handles the request to update an existing plan.
+// Existing subscriptions keep the plan data copied at their creation.
+// Expected route: PUT /api/v1/plans/{planID}
+func (h *PlanHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	planIDStr := r.PathValue("planID")
+	planID, err := parseUint(planIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "UpdatePlan: invalid plan ID format in path", "planID_str", planIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid plan ID format provided.")
+		return
+	}
+
+	var req dto.UpdatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "UpdatePlan: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	plan, err := h.planService.UpdatePlan(ctx, planID, serviceDTO.UpdatePlanInput{
+		Name:          req.Name,
+		Description:   req.Description,
+		DurationUnit:  req.DurationUnit,
+		DurationValue: req.DurationValue,
+		Price:         req.Price,
+		Currency:      req.Currency,
+		IsActive:      req.IsActive,
+		IsFreeTier:    req.IsFreeTier,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdatePlan: failed to update plan via service", "error", err, "planID", planID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "Plan not found.")
+		} else if strings.Contains(err.Error(), "already exists") {
+			respondWithError(w, http.StatusConflict, err.Error())
+		} else if strings.Contains(err.Error(), "invalid plan") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update plan.")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toPlanResponse(plan))
+}
+
+// DeletePlan handles the request to (soft) delete a plan.
+// Expected route: DELETE /api/v1/plans/{planID}
+func (h *PlanHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	planIDStr := r.PathValue("planID")
+	planID, err := parseUint(planIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "DeletePlan: invalid plan ID format in path", "planID_str", planIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid plan ID format provided.")
+		return
+	}
+
+	if err := h.planService.DeletePlan(ctx, planID); err != nil {
+		slog.ErrorContext(ctx, "DeletePlan: failed to delete plan via service", "error", err, "planID", planID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "Plan not found.")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to delete plan.")
+		}
+		return
+	}
+	slog.InfoContext(ctx, "DeletePlan: plan deleted successfully", "planID", planID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPlans handles the request to retrieve a paginated list of plans.
+// Non-admin callers only see active plans; administrators see all plans unless 'active_only=true' is given.
+// Expected route: GET /api/v1/plans
+func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointPlans))
+
+	activeOnly := !isAdminRequest(ctx)
+	if activeOnlyStr := query.Get("active_only"); activeOnlyStr != "" && !activeOnly {
+		var err error
+		activeOnly, err = strconv.ParseBool(activeOnlyStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid 'active_only' query parameter (expected true or false).")
+			return
+		}
+	}
+
+	plans, totalItems, err := h.planService.ListPlans(ctx, params.Page, params.PageSize, activeOnly)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListPlans: failed to list plans from service", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve plans.")
+		return
+	}
+
+	planResponses := make([]dto.PlanResponse, len(plans))
+	for i := range plans {
+		planResponses[i] = toPlanResponse(&plans[i])
+	}
+
+	respondWithJSON(w, http.StatusOK, newPaginatedResponse(ctx, "ListPlans", "plans", planResponses, params, totalItems))
+}
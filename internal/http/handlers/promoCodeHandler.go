@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/interfaces"
+	serviceDTO "bitback/internal/services/dto"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PromoCodeHandler handles HTTP requests related to promo codes.
+type PromoCodeHandler struct {
+	promoCodeService interfaces.PromoCodeService
+}
+
+// NewPromoCodeHandler creates a new instance of PromoCodeHandler.
+func NewPromoCodeHandler(ps interfaces.PromoCodeService) *PromoCodeHandler {
+	return &PromoCodeHandler{
+		promoCodeService: ps,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for promo code actions.
+func (h *PromoCodeHandler) RegisterRoutes(mux RouteRegistrar) {
+	mux.HandleFunc("POST /v1/promo-codes", requireAdmin(h.CreatePromoCode))
+	mux.HandleFunc("GET /v1/promo-codes/{code}", h.ValidatePromoCode)
+}
+
+// CreatePromoCode handles the request to create a new promo code.
+// Expected route: POST /api/v1/promo-codes
+func (h *PromoCodeHandler) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req dto.CreatePromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "CreatePromoCode: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	promoCode, err := h.promoCodeService.CreatePromoCode(ctx, serviceDTO.CreatePromoCodeInput{
+		Code:            req.Code,
+		DiscountPercent: req.DiscountPercent,
+		DiscountAmount:  req.DiscountAmount,
+		ValidFrom:       req.ValidFrom,
+		ValidUntil:      req.ValidUntil,
+		MaxUses:         req.MaxUses,
+		IsActive:        req.IsActive,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "CreatePromoCode: failed to create promo code via service", "error", err, "code", req.Code)
+		if strings.Contains(err.Error(), "already exists") {
+			respondWithError(w, http.StatusConflict, err.Error())
+		} else if strings.Contains(err.Error(), "invalid promo code") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to create promo code.")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, toPromoCodeResponse(promoCode))
+}
+
+// ValidatePromoCode handles the request to check whether a promo code can currently be redeemed.
+// Returns the code's discount if it is valid, 404 if it does not exist, and 400 if it is expired or exhausted.
+// Expected route: GET /api/v1/promo-codes/{code}
+func (h *PromoCodeHandler) ValidatePromoCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := r.PathValue("code")
+
+	promoCode, err := h.promoCodeService.ValidatePromoCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
+			respondWithError(w, http.StatusNotFound, "Promo code not found.")
+		} else if strings.Contains(err.Error(), "invalid promo code") {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			slog.ErrorContext(ctx, "ValidatePromoCode: failed to validate promo code via service", "error", err, "code", code)
+			respondWithError(w, http.StatusInternalServerError, "Failed to validate promo code.")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toPromoCodeResponse(promoCode))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bitback/internal/interfaces"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// realIPHeader is the header through which the reverse proxy passes the client's IP address.
+const realIPHeader = "X-Real-IP"
+
+// RateLimit returns a middleware that rejects requests exceeding the limiter's limit with 429 Too Many Requests
+// and a Retry-After header. Authenticated requests are limited per user and anonymous ones per client IP; the
+// X-Real-IP header is only honored on requests arriving from one of trustedProxies.
+// If the limiter fails, the request is let through rather than failing the endpoint.
+func RateLimit(limiter interfaces.RateLimiter, trustedProxies []netip.Prefix) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			key := rateLimitKey(r, trustedProxies)
+
+			allowed, retryAfter, err := limiter.Allow(ctx, key)
+			if err != nil {
+				slog.ErrorContext(ctx, "RateLimit: rate limiter failed, allowing request", "key", key, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				slog.WarnContext(ctx, "RateLimit: rate limit exceeded", "key", key, "path", r.URL.Path, "retry_after_seconds", seconds)
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				respondWithError(w, http.StatusTooManyRequests, "Too many requests. Please retry later.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the client of a request for rate limiting: the requesting user if authenticated (the
+// principal is only set from a gateway-signed header), otherwise the connection's remote address. If the remote
+// address is one of trustedProxies, the client IP the proxy reports in X-Real-IP is used instead.
+func rateLimitKey(r *http.Request, trustedProxies []netip.Prefix) string {
+	if userID := getOptionalRequestingUserID(r.Context()); userID != nil {
+		return "user:" + userID.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if isTrustedProxy(host, trustedProxies) {
+		if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(realIPHeader))); err == nil {
+			return "ip:" + ip.Unmap().String()
+		}
+	}
+	return "ip:" + host
+}
+
+// isTrustedProxy reports whether the remote host address is within one of trustedProxies.
+func isTrustedProxy(host string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
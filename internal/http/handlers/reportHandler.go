@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/interfaces"
+	"log/slog"
+	"net/http"
+)
+
+// ReportHandler handles HTTP requests for reports that span several resources.
+type ReportHandler struct {
+	reportService interfaces.ReportService
+}
+
+// NewReportHandler creates a new instance of ReportHandler.
+func NewReportHandler(rs interfaces.ReportService) *ReportHandler {
+	return &ReportHandler{
+		reportService: rs,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for cross-resource reports.
+func (h *ReportHandler) RegisterRoutes(mux RouteRegistrar) {
+	mux.HandleFunc("GET /v1/reports/overview", requireAdmin(h.GetOverview))
+}
+
+// GetOverview handles the request to summarize users, subscriptions, hosts and generated keys for the admin dashboard.
+// Expected route: GET /api/v1/reports/overview
+func (h *ReportHandler) GetOverview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	overview, err := h.reportService.GetOverview(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to generate overview via service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate overview.")
+		return
+	}
+
+	resp := dto.OverviewResponse{
+		GeneratedAt: overview.GeneratedAt,
+		Users: dto.OverviewUsersResponse{
+			Total:                      overview.Users.Total,
+			Active:                     overview.Users.Active,
+			WithActivePaidSubscription: overview.UsersWithActivePaidSubscription,
+			NewLast7Days:               overview.Users.NewLast7Days,
+			NewLast30Days:              overview.Users.NewLast30Days,
+		},
+		Subscriptions: dto.OverviewSubscriptionsResponse{
+			ByPaymentStatus: make([]dto.PaymentStatusCountResponse, len(overview.SubscriptionsByPaymentStatus)),
+			ActiveByPlan:    make([]dto.PlanCountResponse, len(overview.ActiveSubscriptionsByPlan)),
+		},
+		Hosts: dto.OverviewHostsResponse{
+			ByStatus:  make([]dto.HostStatusCountResponse, len(overview.HostsByStatus)),
+			ByCountry: make([]dto.CountryHostCountResponse, len(overview.HostsByCountry)),
+		},
+		Keys: dto.OverviewKeysResponse{GeneratedToday: overview.KeysGeneratedToday},
+	}
+	for i, count := range overview.SubscriptionsByPaymentStatus {
+		resp.Subscriptions.ByPaymentStatus[i] = dto.PaymentStatusCountResponse{PaymentStatus: count.PaymentStatus, Count: count.Count}
+	}
+	for i, count := range overview.ActiveSubscriptionsByPlan {
+		resp.Subscriptions.ActiveByPlan[i] = dto.PlanCountResponse{PlanName: count.PlanName, Count: count.Count}
+	}
+	for i, count := range overview.HostsByStatus {
+		resp.Hosts.ByStatus[i] = dto.HostStatusCountResponse{Status: string(count.Status), Count: count.Count}
+	}
+	for i, count := range overview.HostsByCountry {
+		resp.Hosts.ByCountry[i] = dto.CountryHostCountResponse{Count: count.Count}
+		if count.Country != "" {
+			country := count.Country
+			resp.Hosts.ByCountry[i].Country = &country
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bitback/internal/models/customTypes"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGetOverview(t *testing.T) {
+	generatedAt := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	overview := &serviceDTO.Overview{
+		GeneratedAt:                     generatedAt,
+		Users:                           customTypes.UserCounts{Total: 4, Active: 3, NewLast7Days: 2, NewLast30Days: 3},
+		UsersWithActivePaidSubscription: 2,
+		SubscriptionsByPaymentStatus:    []customTypes.PaymentStatusCount{{PaymentStatus: "paid", Count: 6}, {PaymentStatus: "pending", Count: 2}},
+		ActiveSubscriptionsByPlan:       []customTypes.PlanSubscriptionCount{{PlanName: "Premium", Count: 3}, {PlanName: "Basic", Count: 2}},
+		HostsByStatus:                   []customTypes.HostStatusCount{{Status: customTypes.StatusActive, Count: 2}, {Status: customTypes.StatusUnknown, Count: 1}},
+		HostsByCountry:                  []customTypes.CountryHostCount{{Country: "DE", Count: 3}, {Country: "", Count: 1}},
+		KeysGeneratedToday:              5,
+	}
+	const wantBody = `{"generated_at":"2026-03-01T12:00:00Z",` +
+		`"users":{"total":4,"active":3,"with_active_paid_subscription":2,"new_last_7_days":2,"new_last_30_days":3},` +
+		`"subscriptions":{"by_payment_status":[{"payment_status":"paid","count":6},{"payment_status":"pending","count":2}],` +
+		`"active_by_plan":[{"plan_name":"Premium","count":3},{"plan_name":"Basic","count":2}]},` +
+		`"hosts":{"by_status":[{"status":"active","count":2},{"status":"unknown","count":1}],` +
+		`"by_country":[{"country":"DE","count":3},{"country":null,"count":1}]},` +
+		`"keys":{"generated_today":5}}`
+	const wantEmpty = `{"generated_at":"2026-03-01T12:00:00Z",` +
+		`"users":{"total":0,"active":0,"with_active_paid_subscription":0,"new_last_7_days":0,"new_last_30_days":0},` +
+		`"subscriptions":{"by_payment_status":[],"active_by_plan":[]},"hosts":{"by_status":[],"by_country":[]},"keys":{"generated_today":0}}`
+
+	tests := []struct {
+		name       string
+		role       customTypes.UserRole
+		overview   *serviceDTO.Overview
+		serviceErr error
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "overview", role: customTypes.RoleAdmin, overview: overview, wantStatus: http.StatusOK, wantBody: wantBody},
+		{name: "empty database", role: customTypes.RoleAdmin, overview: &serviceDTO.Overview{GeneratedAt: generatedAt},
+			wantStatus: http.StatusOK, wantBody: wantEmpty},
+		{name: "service failure", role: customTypes.RoleAdmin, serviceErr: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			svc := &fakeReportService{
+				getOverview: func(context.Context) (*serviceDTO.Overview, error) {
+					called = true
+					return tt.overview, tt.serviceErr
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/overview", nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(NewReportHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if called != (tt.wantStatus != http.StatusForbidden) {
+				t.Errorf("service called = %t for role %q", called, tt.role)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s\nwant %s", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
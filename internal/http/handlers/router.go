@@ -1,48 +1,202 @@
 package handlers
 
 import (
+	"bitback/internal/interfaces"
 	"net/http"
 )
 
+// RouteRegistrar is the subset of http.ServeMux that handlers use to register their routes.
+type RouteRegistrar interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+	// HandleOverlapping registers a route whose literal segments overlap the wildcards of other routes;
+	// see Router.HandleOverlapping.
+	HandleOverlapping(pattern string, handler http.Handler)
+}
+
+// Middleware wraps an http.Handler with additional behavior.
+type Middleware func(http.Handler) http.Handler
+
 // Router encapsulates the HTTP multiplexer (ServeMux) and provides methods
 // for registering routes for different handlers.
 type Router struct {
-	mux *http.ServeMux
+	mux         *http.ServeMux
+	overlapping *http.ServeMux // Routes matched before mux; see HandleOverlapping.
+	middlewares []Middleware
+	metrics     interfaces.HTTPMetrics
+	patterns    []string // Patterns of all registered routes, in registration order.
 }
 
 // NewRouter creates and returns a new instance of Router, initializing the ServeMux.
 func NewRouter() *Router {
 	return &Router{
-		mux: http.NewServeMux(),
+		mux:         http.NewServeMux(),
+		overlapping: http.NewServeMux(),
 	}
 }
 
 // RegisterKeyRoutes registers the routes managed by KeyHandler.
 // It delegates the actual route registration to the KeyHandler's RegisterRoutes method.
-func (r *Router) RegisterKeyRoutes(keyHandler *KeyHandler) {
-	keyHandler.RegisterRoutes(r.mux)
+// The given middlewares, such as rate limiting, wrap only the key routes, inside the router-wide ones.
+func (r *Router) RegisterKeyRoutes(keyHandler *KeyHandler, middlewares ...Middleware) {
+	keyHandler.RegisterRoutes(routeGroup{router: r, middlewares: middlewares})
 }
 
 // RegisterUserRoutes registers the routes managed by UserHandler.
 // It delegates the actual route registration to the UserHandler's RegisterRoutes method.
 func (r *Router) RegisterUserRoutes(userHandler *UserHandler) {
-	userHandler.RegisterRoutes(r.mux)
+	userHandler.RegisterRoutes(r)
 }
 
 // RegisterSubscriptionRoutes registers the routes managed by SubscriptionHandler.
 // It delegates the actual route registration to the SubscriptionHandler's RegisterRoutes method.
 func (r *Router) RegisterSubscriptionRoutes(subscriptionHandler *SubscriptionHandler) {
-	subscriptionHandler.RegisterRoutes(r.mux)
+	subscriptionHandler.RegisterRoutes(r)
+}
+
+// RegisterOnboardingRoutes registers the routes managed by OnboardingHandler.
+// It delegates the actual route registration to the OnboardingHandler's RegisterRoutes method.
+func (r *Router) RegisterOnboardingRoutes(onboardingHandler *OnboardingHandler) {
+	onboardingHandler.RegisterRoutes(r)
+}
+
+// RegisterReportRoutes registers the routes managed by ReportHandler.
+// It delegates the actual route registration to the ReportHandler's RegisterRoutes method.
+func (r *Router) RegisterReportRoutes(reportHandler *ReportHandler) {
+	reportHandler.RegisterRoutes(r)
 }
 
 // RegisterHostRoutes registers the routes managed by HostHandler.
 // It delegates the actual route registration to the HostHandler's RegisterRoutes method.
 func (r *Router) RegisterHostRoutes(hostHandler *HostHandler) {
-	hostHandler.RegisterRoutes(r.mux)
+	hostHandler.RegisterRoutes(r)
+}
+
+// RegisterPlanRoutes registers the routes managed by PlanHandler.
+// It delegates the actual route registration to the PlanHandler's RegisterRoutes method.
+func (r *Router) RegisterPlanRoutes(planHandler *PlanHandler) {
+	planHandler.RegisterRoutes(r)
+}
+
+// RegisterPromoCodeRoutes registers the routes managed by PromoCodeHandler.
+// It delegates the actual route registration to the PromoCodeHandler's RegisterRoutes method.
+func (r *Router) RegisterPromoCodeRoutes(promoCodeHandler *PromoCodeHandler) {
+	promoCodeHandler.RegisterRoutes(r)
+}
+
+// RegisterAuthRoutes registers the routes managed by AuthHandler.
+// It delegates the actual route registration to the AuthHandler's RegisterRoutes method.
+func (r *Router) RegisterAuthRoutes(authHandler *AuthHandler) {
+	authHandler.RegisterRoutes(r)
+}
+
+// RegisterHealthRoutes registers the routes managed by HealthHandler.
+// It delegates the actual route registration to the HealthHandler's RegisterRoutes method.
+func (r *Router) RegisterHealthRoutes(healthHandler *HealthHandler) {
+	healthHandler.RegisterRoutes(r)
+}
+
+// EnableMetrics serves the scrape endpoint handler at GET /metrics and records the count
+// and latency of every request handled by the router in m.
+func (r *Router) EnableMetrics(m interfaces.HTTPMetrics, handler http.Handler) {
+	r.metrics = m
+	r.Handle("GET /metrics", handler)
+}
+
+// EnableOpenAPI serves the OpenAPI document describing the API at GET /v1/openapi.json
+// and a Swagger UI page rendering it at GET /v1/docs.
+func (r *Router) EnableOpenAPI() {
+	r.HandleFunc("GET /v1/openapi.json", serveOpenAPIDocument())
+	r.HandleFunc("GET /v1/docs", serveSwaggerUI)
+}
+
+// UndocumentedRoutes returns the patterns of registered routes that the OpenAPI document does not describe.
+// It is meant to be checked once all routes are registered, so that the document does not drift silently.
+func (r *Router) UndocumentedRoutes() []string {
+	return undocumentedRoutes(r.patterns, apiOperations)
 }
 
-// GetHandler returns the underlying http.ServeMux instance, which implements http.Handler.
+// Handle registers the handler for the given pattern on the underlying ServeMux and records the pattern.
+func (r *Router) Handle(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern on the underlying ServeMux and records the pattern.
+func (r *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// HandleOverlapping registers a route that is matched before the routes of the underlying ServeMux and records the
+// pattern. ServeMux panics on patterns such as "GET /v1/users/by-telegram/{telegramID}" and
+// "GET /v1/users/{userID}/subscriptions", which both match "/v1/users/by-telegram/subscriptions" without either
+// being more specific; registering the first one here lets its literal segment win instead.
+func (r *Router) HandleOverlapping(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.overlapping.Handle(pattern, handler)
+}
+
+// ServeHTTP dispatches the request to the matching overlapping route, if any, and to the underlying ServeMux otherwise.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if _, pattern := r.overlapping.Handler(req); pattern != "" {
+		r.overlapping.ServeHTTP(w, req)
+		return
+	}
+	r.mux.ServeHTTP(w, req)
+}
+
+// routePattern returns the pattern of the route that serves req, or "" if no route matches.
+func (r *Router) routePattern(req *http.Request) string {
+	if _, pattern := r.overlapping.Handler(req); pattern != "" {
+		return pattern
+	}
+	_, pattern := r.mux.Handler(req)
+	return pattern
+}
+
+// routeGroup registers routes on a Router with a set of middlewares wrapping each handler.
+type routeGroup struct {
+	router      *Router
+	middlewares []Middleware
+}
+
+// Handle registers the handler wrapped in the group's middlewares, the first one being the outermost.
+func (g routeGroup) Handle(pattern string, handler http.Handler) {
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		handler = g.middlewares[i](handler)
+	}
+	g.router.Handle(pattern, handler)
+}
+
+// HandleOverlapping registers the handler wrapped in the group's middlewares as an overlapping route.
+func (g routeGroup) HandleOverlapping(pattern string, handler http.Handler) {
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		handler = g.middlewares[i](handler)
+	}
+	g.router.HandleOverlapping(pattern, handler)
+}
+
+// HandleFunc registers the handler function wrapped in the group's middlewares.
+func (g routeGroup) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	g.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// Use appends middlewares to the chain applied to every request.
+// Middlewares are applied in the order they are added; the first one added is the outermost.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// GetHandler returns the router wrapped in the registered middlewares.
+// When metrics are enabled, the metrics middleware is the outermost one so that every request is recorded.
 // This allows the router to be used with an http.Server.
 func (r *Router) GetHandler() http.Handler {
-	return r.mux
+	var handler http.Handler = r
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	if r.metrics != nil {
+		handler = metricsMiddleware(r.metrics, r.routePattern)(handler)
+	}
+	return handler
 }
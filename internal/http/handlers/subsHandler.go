@@ -1,52 +1,90 @@
 package handlers
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/http/handlers/dto"
 	"bitback/internal/interfaces"
+	"bitback/internal/models/customTypes"
 	serviceDTO "bitback/internal/services/dto"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
+)
+
+const (
+	// idempotencyKeyHeader is the header through which clients make subscription creation idempotent.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength is the maximum accepted length of an idempotency key.
+	maxIdempotencyKeyLength = 128
+	// maxPromoCodeLength is the maximum accepted length of a promo code.
+	maxPromoCodeLength = 64
 )
 
 // SubscriptionHandler handles HTTP requests related to subscriptions.
 type SubscriptionHandler struct {
 	subService interfaces.SubscriptionService
+	cfg        *config.Config
 }
 
 // NewSubscriptionHandler creates a new instance of SubscriptionHandler.
-func NewSubscriptionHandler(ss interfaces.SubscriptionService) *SubscriptionHandler {
+func NewSubscriptionHandler(ss interfaces.SubscriptionService, cfg *config.Config) *SubscriptionHandler {
 	return &SubscriptionHandler{
 		subService: ss,
+		cfg:        cfg,
 	}
 }
 
 // RegisterRoutes registers the HTTP routes for subscription-related actions.
-func (h *SubscriptionHandler) RegisterRoutes(mux *http.ServeMux) {
+func (h *SubscriptionHandler) RegisterRoutes(mux RouteRegistrar) {
 	// Routes for subscriptions specific to a user.
-	mux.HandleFunc("POST /v1/users/{userID}/subscriptions", h.CreateSubscriptionForUser)
+	mux.HandleFunc("POST /v1/users/{userID}/subscriptions", requireSelfOrAdmin(h.CreateSubscriptionForUser))
 	mux.HandleFunc("GET /v1/users/{userID}/subscriptions", h.ListUserSubscriptions)
+	mux.HandleFunc("GET /v1/users/{userID}/subscriptions.ics", h.GetUserSubscriptionsCalendar)
+	mux.HandleFunc("GET /v1/users/{userID}/subscription-status", requireSelfOrAdmin(h.GetUserSubscriptionStatus))
 
 	// Routes for managing a specific subscription by its ID.
 	mux.HandleFunc("GET /v1/subscriptions/{subscriptionID}", h.GetSubscriptionByID)
-	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/cancel", h.CancelSubscription)
-	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/payment", h.UpdatePaymentStatus)
-	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/autorenew", h.SetAutoRenew)
-
-	// Reporting routes.
-	mux.HandleFunc("GET /v1/reports/expiring-subscriptions", h.ListUsersWithExpiringSubscriptions)
-	mux.HandleFunc("GET /v1/reports/active-by-plan", h.ListActiveSubscriptionsByPlan)
+	mux.HandleFunc("GET /v1/subscriptions/{subscriptionID}/events", h.ListSubscriptionEvents)
+	mux.HandleFunc("GET /v1/subscriptions/{subscriptionID}/usage", h.GetUsageReport)
+	// Route for reporting metered usage, used by the systems that meter it. Restricted to administrators.
+	mux.HandleFunc("POST /v1/subscriptions/{subscriptionID}/usage", requireAdmin(h.RecordUsage))
+	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/cancel", requireAuthenticated(h.CancelSubscription))
+	// Manual payment status override for support staff; provider payments arrive through the webhook. Restricted to administrators.
+	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/payment", requireAdmin(h.UpdatePaymentStatus))
+	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/autorenew", requireAuthenticated(h.SetAutoRenew))
+	// Route for recording that an expiry reminder was sent, used by the notification sender. Restricted to administrators.
+	mux.HandleFunc("PATCH /v1/subscriptions/{subscriptionID}/expiry-notified", requireAdmin(h.MarkExpiryNotified))
+
+	// Administration routes for subscriptions across all users.
+	mux.HandleFunc("GET /v1/subscriptions", requireAdmin(h.ListSubscriptions))
+	mux.HandleFunc("DELETE /v1/subscriptions/{subscriptionID}", requireAdmin(h.DeleteSubscription))
+
+	// Reporting routes, restricted to administrators.
+	mux.HandleFunc("GET /v1/reports/expiring-subscriptions", requireAdmin(h.ListUsersWithExpiringSubscriptions))
+	mux.HandleFunc("GET /v1/reports/active-by-plan", requireAdmin(h.ListActiveSubscriptionsByPlan))
+	mux.HandleFunc("GET /v1/reports/churn", requireAdmin(h.GetChurnReport))
+	mux.HandleFunc("GET /v1/reports/subscriptions-by-currency", requireAdmin(h.GetSubscriptionsByCurrency))
+
+	// Route for payment notifications from the payment provider, authenticated by the payload signature.
+	mux.HandleFunc("POST /v1/webhooks/payments", h.HandlePaymentWebhook)
+
+	// Route for running the auto-renewal job immediately instead of waiting for the worker. Restricted to administrators.
+	mux.HandleFunc("POST /v1/subscriptions/renewals/run", requireAdmin(h.RunRenewals))
 }
 
 // CreateSubscriptionForUser handles the request to create a new subscription for a specified user.
+// Users may only subscribe themselves, and their subscriptions await payment whatever payment status they ask for;
+// administrators may subscribe any user with any payment status.
+// An optional Idempotency-Key header makes retries safe: a repeated request with the same key and payload
+// returns the original subscription with 200 instead of creating a new one.
 // Expected route: POST /api/v1/users/{userID}/subscriptions
 func (h *SubscriptionHandler) CreateSubscriptionForUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -58,53 +96,105 @@ func (h *SubscriptionHandler) CreateSubscriptionForUser(w http.ResponseWriter, r
 		return
 	}
 
-	var req dto.CreateSubscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.ErrorContext(ctx, "CreateSubscriptionForUser: failed to decode request body", "error", err)
+	req, err := decodeVersionedPayload(r, createSubscriptionConverters)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateSubscriptionForUser: failed to decode request body", "error", err, "apiVersion", r.Header.Get(apiVersionHeader))
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
 		return
 	}
 
-	// TODO: Implement request DTO validation.
+	isAdmin := isAdminRequest(ctx)
+	if err := validateCreateSubscriptionRequest(req, isAdmin); err != nil {
+		slog.WarnContext(ctx, "CreateSubscriptionForUser: invalid request payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
 
-	// Ensure UserID in the request body (if present) matches the UserID from the path,
-	// or primarily use the UserID from the path. For consistency, the path UserID is preferred.
-	if req.UserID != "" {
-		parsedBodyUserID, parseErr := uuid.Parse(req.UserID)
-		if parseErr != nil || parsedBodyUserID != targetUserID {
-			slog.WarnContext(ctx, "CreateSubscriptionForUser: UserID in body does not match UserID in path or is invalid.",
-				"path_userID", targetUserID, "body_userID_str", req.UserID)
+	var idempotencyKey *string
+	if key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader)); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			slog.WarnContext(ctx, "CreateSubscriptionForUser: idempotency key too long", "length", len(key))
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("%s header must be at most %d characters.", idempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
 		}
+		idempotencyKey = &key
 	}
 
 	serviceInput := serviceDTO.CreateSubscriptionInput{
-		UserID:        targetUserID, // Use UserID from path.
-		PlanName:      req.PlanName,
-		DurationUnit:  req.DurationUnit,
-		DurationValue: req.DurationValue,
-		StartDate:     req.StartDate,
-		Price:         req.Price,
-		Currency:      req.Currency,
-		PaymentStatus: req.PaymentStatus,
-		AutoRenew:     req.AutoRenew,
-	}
-
-	subscription, err := h.subService.CreateSubscription(ctx, serviceInput)
+		UserID:         targetUserID, // Use UserID from path.
+		PlanID:         req.PlanID,
+		PlanName:       req.PlanName,
+		DurationUnit:   req.DurationUnit,
+		DurationValue:  req.DurationValue,
+		StartDate:      req.StartDate,
+		Price:          req.Price,
+		Currency:       req.Currency,
+		PaymentStatus:  req.PaymentStatus,
+		AutoRenew:      req.AutoRenew,
+		PromoCode:      req.PromoCode,
+		IdempotencyKey: idempotencyKey,
+		ActorUserID:    getOptionalRequestingUserID(ctx),
+		ActorRole:      getRequestingUserRole(ctx),
+	}
+
+	subscription, created, err := h.subService.CreateSubscription(ctx, serviceInput)
 	if err != nil {
 		slog.ErrorContext(ctx, "CreateSubscriptionForUser: failed to create subscription via service", "error", err, "userID", targetUserID, "plan", req.PlanName)
-		if strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, err.Error())
-		} else if strings.Contains(err.Error(), "already exists") {
-			respondWithError(w, http.StatusConflict, err.Error())
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to create subscription.")
-		}
+		respondWithServiceError(w, err, "Failed to create subscription.")
 		return
 	}
 
+	if !created {
+		slog.InfoContext(ctx, "CreateSubscriptionForUser: returning existing subscription for idempotency key", "subscriptionID", subscription.ID, "userID", targetUserID)
+		respondWithJSON(w, http.StatusOK, toSubscriptionResponse(subscription))
+		return
+	}
 	respondWithJSON(w, http.StatusCreated, toSubscriptionResponse(subscription))
 }
 
+// validateCreateSubscriptionRequest checks the fields of a create subscription request that the service does not
+// check itself. The payment status is only required from administrators; the service ignores it for everyone else.
+func validateCreateSubscriptionRequest(req dto.CreateSubscriptionRequest, isAdmin bool) error {
+	if req.StartDate.IsZero() {
+		return errors.New("start_date is required")
+	}
+	if req.Price != nil && *req.Price < 0 {
+		return errors.New("price cannot be negative")
+	}
+	if req.Currency != nil && strings.TrimSpace(*req.Currency) != "" && len(strings.TrimSpace(*req.Currency)) != 3 {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	if req.PromoCode != nil && len(*req.PromoCode) > maxPromoCodeLength {
+		return fmt.Errorf("promo_code must be at most %d characters", maxPromoCodeLength)
+	}
+	if isAdmin && strings.TrimSpace(req.PaymentStatus) == "" {
+		return errors.New("payment_status is required")
+	}
+	return nil
+}
+
+// createSubscriptionConverters converts create subscription payloads of older schema versions.
+var createSubscriptionConverters = map[int]payloadConverter[dto.CreateSubscriptionRequest]{
+	1: convertCreateSubscriptionRequestV1,
+}
+
+// convertCreateSubscriptionRequestV1 converts a version 1 create subscription payload, whose user_id must be
+// the user in the path.
+func convertCreateSubscriptionRequestV1(r *http.Request, body io.Reader) (dto.CreateSubscriptionRequest, error) {
+	var v1 dto.CreateSubscriptionRequestV1
+	if err := json.NewDecoder(body).Decode(&v1); err != nil {
+		return dto.CreateSubscriptionRequest{}, err
+	}
+	bodyUserID, err := uuid.Parse(v1.UserID)
+	if err != nil {
+		return dto.CreateSubscriptionRequest{}, fmt.Errorf("invalid user_id %q", v1.UserID)
+	}
+	if pathUserID, err := uuid.Parse(r.PathValue("userID")); err != nil || bodyUserID != pathUserID {
+		return dto.CreateSubscriptionRequest{}, errors.New("user_id does not match the user ID in the path")
+	}
+	return v1.CreateSubscriptionRequest, nil
+}
+
 // GetSubscriptionByID handles the request to retrieve a subscription by its ID.
 // Expected route: GET /api/v1/subscriptions/{subscriptionID}
 func (h *SubscriptionHandler) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
@@ -117,30 +207,145 @@ func (h *SubscriptionHandler) GetSubscriptionByID(w http.ResponseWriter, r *http
 		return
 	}
 
-	requestingUserID, err := getRequestingUserID(ctx) // Placeholder for actual user auth.
+	requestingUserID, err := getRequestingUserID(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "GetSubscriptionByID: failed to get requesting user ID (auth missing/failed)", "error", err)
 		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
 		return
 	}
 
-	subscription, err := h.subService.GetSubscriptionByID(ctx, subscriptionID, requestingUserID)
+	subscription, err := h.subService.GetSubscriptionByID(ctx, subscriptionID, requestingUserID, getRequestingUserRole(ctx))
 	if err != nil {
 		slog.ErrorContext(ctx, "GetSubscriptionByID: failed to get subscription from service", "error", err, "subscriptionID", subscriptionID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Subscription not found.")
-		} else if strings.Contains(err.Error(), "not authorized") {
-			respondWithError(w, http.StatusForbidden, "You are not authorized to view this subscription.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve subscription.")
-		}
+		respondWithServiceError(w, err, "Failed to retrieve subscription.")
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, toSubscriptionResponse(subscription))
 }
 
+// RecordUsage handles the request to add a usage increment to a metered subscription.
+// Increments for the same metric within a billing period are summed.
+// Expected route: POST /api/v1/subscriptions/{subscriptionID}/usage
+func (h *SubscriptionHandler) RecordUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionIDStr := r.PathValue("subscriptionID")
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "RecordUsage: invalid subscription ID format in path", "subscriptionID_str", subscriptionIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID format.")
+		return
+	}
+
+	var req dto.RecordUsageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(ctx, "RecordUsage: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	usage, err := h.subService.RecordUsage(ctx, subscriptionID, serviceDTO.RecordUsageInput{
+		Metric:     req.Metric,
+		Quantity:   req.Quantity,
+		OccurredAt: req.OccurredAt,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordUsage: failed to record usage via service", "error", err, "subscriptionID", subscriptionID)
+		respondWithServiceError(w, err, "Failed to record usage.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.SubscriptionUsageResponse{
+		SubscriptionID: usage.SubscriptionID,
+		Metric:         usage.Metric,
+		Quantity:       usage.Quantity,
+		PeriodStart:    usage.PeriodStart,
+		PeriodEnd:      usage.PeriodEnd,
+		UpdatedAt:      usage.UpdatedAt,
+	})
+}
+
+// GetUsageReport handles the request to summarize a subscription's usage per billing period.
+// Only the owner of the subscription or an administrator may view it.
+// Expected route: GET /api/v1/subscriptions/{subscriptionID}/usage
+func (h *SubscriptionHandler) GetUsageReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionIDStr := r.PathValue("subscriptionID")
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUsageReport: invalid subscription ID format in path", "subscriptionID_str", subscriptionIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID format.")
+		return
+	}
+
+	requestingUserID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUsageReport: unauthenticated request", "error", err)
+		respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+		return
+	}
+
+	report, err := h.subService.GetUsageReport(ctx, subscriptionID, requestingUserID, getRequestingUserRole(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUsageReport: failed to get usage report from service", "error", err, "subscriptionID", subscriptionID)
+		respondWithServiceError(w, err, "Failed to retrieve usage report.")
+		return
+	}
+
+	resp := dto.UsageReportResponse{
+		SubscriptionID: report.SubscriptionID,
+		Periods:        make([]dto.UsagePeriodResponse, len(report.Periods)),
+	}
+	for i, period := range report.Periods {
+		resp.Periods[i] = dto.UsagePeriodResponse{
+			PeriodStart: period.PeriodStart,
+			PeriodEnd:   period.PeriodEnd,
+			Metrics:     period.Metrics,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// ListSubscriptionEvents handles the request to list the history of a subscription, oldest first.
+// Only the owner of the subscription or an administrator may view it.
+// Expected route: GET /api/v1/subscriptions/{subscriptionID}/events
+func (h *SubscriptionHandler) ListSubscriptionEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionIDStr := r.PathValue("subscriptionID")
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "ListSubscriptionEvents: invalid subscription ID format in path", "subscriptionID_str", subscriptionIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID format.")
+		return
+	}
+
+	requestingUserID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "ListSubscriptionEvents: unauthenticated request", "error", err)
+		respondWithError(w, http.StatusUnauthorized, "Authentication required.")
+		return
+	}
+
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointSubscriptionEvents))
+	eventModels, totalItems, err := h.subService.ListSubscriptionEvents(ctx, subscriptionID, requestingUserID, getRequestingUserRole(ctx), params.Page, params.PageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListSubscriptionEvents: failed to list subscription history from service", "error", err, "subscriptionID", subscriptionID)
+		respondWithServiceError(w, err, "Failed to retrieve subscription history.")
+		return
+	}
+
+	eventResponses := make([]dto.SubscriptionEventResponse, len(eventModels))
+	for i := range eventModels {
+		eventResponses[i] = toSubscriptionEventResponse(&eventModels[i])
+	}
+
+	response := newPaginatedResponse(ctx, "ListSubscriptionEvents", "events", eventResponses, params, totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
 // ListUserSubscriptions handles the request to list subscriptions for a specific user.
+// Supports optional 'status' (active, expired or cancelled), 'payment_status' and 'plan_name' filters,
+// and sorting by 'sort_by' (created_at, start_date or end_date) and 'sort_order'.
 // Expected route: GET /api/v1/users/{userID}/subscriptions
 func (h *SubscriptionHandler) ListUserSubscriptions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -154,23 +359,40 @@ func (h *SubscriptionHandler) ListUserSubscriptions(w http.ResponseWriter, r *ht
 
 	// TODO: Add authorization check
 
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointUserSubscriptions))
 	query := r.URL.Query()
-	page, err := strconv.Atoi(query.Get("page"))
-	if err != nil || page < 1 {
-		page = 1
+
+	serviceParams := serviceDTO.ListUserSubscriptionsParams{
+		Page:      params.Page,
+		PageSize:  params.PageSize,
+		SortBy:    query.Get("sort_by"),
+		SortOrder: query.Get("sort_order"),
+	}
+	if statusStr := strings.TrimSpace(query.Get("status")); statusStr != "" {
+		status := customTypes.SubscriptionListStatus(strings.ToLower(statusStr))
+		if !status.IsValid() {
+			slog.WarnContext(ctx, "ListUserSubscriptions: invalid 'status' query parameter", "status_param", statusStr)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'status' query parameter (must be active, expired or cancelled): %s", statusStr))
+			return
+		}
+		serviceParams.Status = &status
 	}
-	pageSize, err := strconv.Atoi(query.Get("pageSize"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10
+	if paymentStatus := strings.TrimSpace(query.Get("payment_status")); paymentStatus != "" {
+		serviceParams.PaymentStatus = &paymentStatus
 	}
-	if pageSize > 100 { // Max page size limit.
-		pageSize = 100
+	if planName := strings.TrimSpace(query.Get("plan_name")); planName != "" {
+		serviceParams.PlanName = &planName
+	}
+	if sortBy := serviceParams.SortBy; sortBy != "" && sortBy != "created_at" && sortBy != "start_date" && sortBy != "end_date" {
+		slog.WarnContext(ctx, "ListUserSubscriptions: invalid 'sort_by' query parameter", "sort_by_param", sortBy)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'sort_by' query parameter (must be created_at, start_date or end_date): %s", sortBy))
+		return
 	}
 
-	subsModels, totalItems, err := h.subService.ListUserSubscriptions(ctx, targetUserID, page, pageSize)
+	subsModels, totalItems, err := h.subService.ListUserSubscriptions(ctx, targetUserID, serviceParams)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListUserSubscriptions: failed to list user subscriptions from service", "error", err, "userID", targetUserID)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve user subscriptions.")
+		respondWithServiceError(w, err, "Failed to retrieve user subscriptions.")
 		return
 	}
 
@@ -179,24 +401,71 @@ func (h *SubscriptionHandler) ListUserSubscriptions(w http.ResponseWriter, r *ht
 		subResponses[i] = toSubscriptionResponse(&s)
 	}
 
-	totalPages := 0
-	if totalItems > 0 && pageSize > 0 {
-		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	response := newPaginatedResponse(ctx, "ListUserSubscriptions", "subscriptions", subResponses, params, totalItems)
+	slog.InfoContext(ctx, "ListUserSubscriptions: successfully listed subscriptions", "userID", targetUserID, "count_in_page", len(subResponses), "total_items", totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetUserSubscriptionsCalendar handles the request to export a user's subscriptions as an iCalendar feed,
+// with one event on the end date of each subscription. Only the user themselves or an administrator may access it.
+// Expected route: GET /api/v1/users/{userID}/subscriptions.ics
+func (h *SubscriptionHandler) GetUserSubscriptionsCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	targetUserIDStr := r.PathValue("userID")
+	targetUserID, err := uuid.Parse(targetUserIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUserSubscriptionsCalendar: invalid target userID format in path", "userID_str", targetUserIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid target user ID format in path.")
+		return
 	}
-	if page > totalPages && totalPages > 0 { // Handle out-of-bounds page requests.
-		subResponses = []dto.SubscriptionResponse{}
-		slog.WarnContext(ctx, "ListUserSubscriptions: requested page is out of bounds", "requested_page", page, "total_pages", totalPages)
+
+	requestingUserID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionsCalendar: failed to get requesting user ID (auth missing/failed)", "error", err)
+		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
+		return
 	}
 
-	response := dto.PaginatedSubscriptionsResponse{
-		Subscriptions: subResponses,
-		TotalItems:    totalItems,
-		TotalPages:    totalPages,
-		CurrentPage:   page,
-		PageSize:      pageSize,
+	subscriptions, err := h.subService.ListAllUserSubscriptions(ctx, targetUserID, requestingUserID, getRequestingUserRole(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionsCalendar: failed to list subscriptions from service", "error", err, "userID", targetUserID)
+		respondWithServiceError(w, err, "Failed to retrieve user subscriptions.")
+		return
+	}
+
+	w.Header().Set("Content-Type", iCalContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(buildSubscriptionsICal(subscriptions, time.Now()))); err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionsCalendar: failed to write response", "error", err)
 	}
-	slog.InfoContext(ctx, "ListUserSubscriptions: successfully listed subscriptions", "userID", targetUserID, "count_in_page", len(subResponses), "total_items", totalItems)
-	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetUserSubscriptionStatus handles the request to check whether a user currently has an active subscription,
+// e.g. to gate premium features. The response also lists the plans that are active.
+// Only the user themselves and administrators may check it.
+// Expected route: GET /api/v1/users/{userID}/subscription-status
+func (h *SubscriptionHandler) GetUserSubscriptionStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	targetUserIDStr := r.PathValue("userID")
+	targetUserID, err := uuid.Parse(targetUserIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUserSubscriptionStatus: invalid target userID format in path", "userID_str", targetUserIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid target user ID format in path.")
+		return
+	}
+
+	status, err := h.subService.GetUserSubscriptionStatus(ctx, targetUserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionStatus: failed to check subscription status via service", "error", err, "userID", targetUserID)
+		respondWithServiceError(w, err, "Failed to check subscription status.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.SubscriptionStatusResponse{
+		HasActiveSubscription: status.HasActiveSubscription,
+		ActivePlans:           status.ActivePlans,
+	})
 }
 
 // CancelSubscription handles the request to cancel a subscription.
@@ -211,30 +480,24 @@ func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.
 		return
 	}
 
-	requestingUserID, err := getRequestingUserID(ctx) // Placeholder for actual user auth.
+	requestingUserID, err := getRequestingUserID(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "CancelSubscription: failed to get requesting user ID", "error", err)
 		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
 		return
 	}
 
-	updatedSub, err := h.subService.CancelSubscription(ctx, subscriptionID, requestingUserID)
+	updatedSub, err := h.subService.CancelSubscription(ctx, subscriptionID, requestingUserID, getRequestingUserRole(ctx))
 	if err != nil {
 		slog.ErrorContext(ctx, "CancelSubscription: failed to cancel subscription via service", "error", err, "subscriptionID", subscriptionID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Subscription not found.")
-		} else if strings.Contains(err.Error(), "not authorized") {
-			respondWithError(w, http.StatusForbidden, "You are not authorized to cancel this subscription.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to cancel subscription.")
-		}
+		respondWithServiceError(w, err, "Failed to cancel subscription.")
 		return
 	}
 	slog.InfoContext(ctx, "CancelSubscription: subscription cancelled successfully", "subscriptionID", subscriptionID)
 	respondWithJSON(w, http.StatusOK, toSubscriptionResponse(updatedSub))
 }
 
-// UpdatePaymentStatus handles the request to update a subscription's payment status.
+// UpdatePaymentStatus handles an administrator's request to override a subscription's payment status.
 // Expected route: PATCH /api/v1/subscriptions/{subscriptionID}/payment
 func (h *SubscriptionHandler) UpdatePaymentStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -246,8 +509,6 @@ func (h *SubscriptionHandler) UpdatePaymentStatus(w http.ResponseWriter, r *http
 		return
 	}
 
-	// TODO: Add authorization check
-
 	var req dto.UpdateSubscriptionPaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.ErrorContext(ctx, "UpdatePaymentStatus: failed to decode request body", "error", err)
@@ -257,20 +518,37 @@ func (h *SubscriptionHandler) UpdatePaymentStatus(w http.ResponseWriter, r *http
 
 	// TODO: Validate req.PaymentStatus against a list of allowed statuses.
 
-	updatedSub, err := h.subService.UpdatePaymentStatus(ctx, subscriptionID, req.PaymentStatus)
+	updatedSub, err := h.subService.UpdatePaymentStatus(ctx, subscriptionID, req.PaymentStatus, getOptionalRequestingUserID(ctx))
 	if err != nil {
 		slog.ErrorContext(ctx, "UpdatePaymentStatus: failed to update payment status via service", "error", err, "subscriptionID", subscriptionID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Subscription not found.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to update payment status.")
-		}
+		respondWithServiceError(w, err, "Failed to update payment status.")
 		return
 	}
 	slog.InfoContext(ctx, "UpdatePaymentStatus: payment status updated successfully", "subscriptionID", subscriptionID, "new_status", req.PaymentStatus)
 	respondWithJSON(w, http.StatusOK, toSubscriptionResponse(updatedSub))
 }
 
+// MarkExpiryNotified handles the request to record that the owner of a subscription was reminded of its expiry.
+// Expected route: PATCH /api/v1/subscriptions/{subscriptionID}/expiry-notified
+func (h *SubscriptionHandler) MarkExpiryNotified(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionIDStr := r.PathValue("subscriptionID")
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "MarkExpiryNotified: invalid subscription ID format", "subscriptionID_str", subscriptionIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID format.")
+		return
+	}
+
+	updatedSub, err := h.subService.MarkExpiryNotified(ctx, subscriptionID)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkExpiryNotified: failed to record expiry reminder via service", "error", err, "subscriptionID", subscriptionID)
+		respondWithServiceError(w, err, "Failed to record expiry reminder.")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, toSubscriptionResponse(updatedSub))
+}
+
 // SetAutoRenew handles the request to set the auto-renewal flag for a subscription.
 // Expected route: PATCH /api/v1/subscriptions/{subscriptionID}/autorenew
 func (h *SubscriptionHandler) SetAutoRenew(w http.ResponseWriter, r *http.Request) {
@@ -283,7 +561,7 @@ func (h *SubscriptionHandler) SetAutoRenew(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	requestingUserID, err := getRequestingUserID(ctx) // Placeholder for actual user auth.
+	requestingUserID, err := getRequestingUserID(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "SetAutoRenew: failed to get requesting user ID", "error", err)
 		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
@@ -297,57 +575,115 @@ func (h *SubscriptionHandler) SetAutoRenew(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	updatedSub, err := h.subService.SetAutoRenew(ctx, subscriptionID, requestingUserID, req.AutoRenew)
+	updatedSub, err := h.subService.SetAutoRenew(ctx, subscriptionID, requestingUserID, getRequestingUserRole(ctx), req.AutoRenew)
 	if err != nil {
 		slog.ErrorContext(ctx, "SetAutoRenew: failed to set auto-renew status via service", "error", err, "subscriptionID", subscriptionID)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "Subscription not found.")
-		} else if strings.Contains(err.Error(), "not authorized") {
-			respondWithError(w, http.StatusForbidden, "You are not authorized to modify this subscription.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to set auto-renew status.")
-		}
+		respondWithServiceError(w, err, "Failed to set auto-renew status.")
 		return
 	}
 	slog.InfoContext(ctx, "SetAutoRenew: auto-renew status updated successfully", "subscriptionID", subscriptionID, "auto_renew_set_to", req.AutoRenew)
 	respondWithJSON(w, http.StatusOK, toSubscriptionResponse(updatedSub))
 }
 
+// DeleteSubscription handles the request to soft delete a subscription.
+// Only administrators are allowed to delete subscriptions.
+// Expected route: DELETE /api/v1/subscriptions/{subscriptionID}
+func (h *SubscriptionHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionIDStr := r.PathValue("subscriptionID")
+	subscriptionID, err := uuid.Parse(subscriptionIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "DeleteSubscription: invalid subscription ID format", "subscriptionID_str", subscriptionIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID format.")
+		return
+	}
+
+	requestingUserID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteSubscription: failed to get requesting user ID", "error", err)
+		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
+		return
+	}
+
+	if err := h.subService.DeleteSubscription(ctx, subscriptionID, requestingUserID); err != nil {
+		slog.ErrorContext(ctx, "DeleteSubscription: failed to delete subscription via service", "error", err, "subscriptionID", subscriptionID)
+		respondWithServiceError(w, err, "Failed to delete subscription.")
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteSubscription: subscription deleted successfully", "subscriptionID", subscriptionID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Subscription deleted successfully."})
+}
+
+// ListSubscriptions handles the request to list subscriptions across all users.
+// Supports optional filtering by 'payment_status' and 'is_active' query parameters.
+// Expected route: GET /api/v1/subscriptions
+func (h *SubscriptionHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	var filters customTypes.ListSubscriptionsFilters
+	if paymentStatus := strings.TrimSpace(query.Get("payment_status")); paymentStatus != "" {
+		filters.PaymentStatus = &paymentStatus
+	}
+	if isActiveStr := query.Get("is_active"); isActiveStr != "" {
+		isActive, err := strconv.ParseBool(isActiveStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListSubscriptions: invalid 'is_active' query parameter", "is_active_param", isActiveStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'is_active' query parameter (must be true or false): %s", isActiveStr))
+			return
+		}
+		filters.IsActive = &isActive
+	}
+	if reminderSentStr := query.Get("reminder_sent"); reminderSentStr != "" {
+		reminderSent, err := strconv.ParseBool(reminderSentStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListSubscriptions: invalid 'reminder_sent' query parameter", "reminder_sent_param", reminderSentStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'reminder_sent' query parameter (must be true or false): %s", reminderSentStr))
+			return
+		}
+		filters.ReminderSent = &reminderSent
+	}
+
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointSubscriptions))
+
+	subsModels, totalItems, err := h.subService.ListSubscriptions(ctx, filters, params.Page, params.PageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListSubscriptions: failed to list subscriptions from service", "error", err)
+		respondWithServiceError(w, err, "Failed to retrieve subscriptions.")
+		return
+	}
+
+	subResponses := make([]dto.SubscriptionResponse, len(subsModels))
+	for i, s := range subsModels {
+		subResponses[i] = toSubscriptionResponse(&s)
+	}
+
+	response := newPaginatedResponse(ctx, "ListSubscriptions", "subscriptions", subResponses, params, totalItems)
+	slog.InfoContext(ctx, "ListSubscriptions: successfully listed subscriptions", "count_in_page", len(subResponses), "total_items", totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
 // ListUsersWithExpiringSubscriptions handles the request to generate a report of users with subscriptions nearing expiration.
 // Expected route: GET /api/v1/reports/expiring-subscriptions
 func (h *SubscriptionHandler) ListUsersWithExpiringSubscriptions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	slog.InfoContext(ctx, "ListUsersWithExpiringSubscriptions: received request for expiring subscriptions report")
 
-	// TODO: Add authorization check
-
 	query := r.URL.Query()
 	daysStr := query.Get("days_in_advance")
-	pageStr := query.Get("page")
-	pageSizeStr := query.Get("pageSize")
 
 	daysInAdvance, err := strconv.Atoi(daysStr)
 	if err != nil || daysInAdvance < 0 {
 		daysInAdvance = 7 // Default to 7 days in advance.
 	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointExpiringSubscriptions))
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 { // Max page size limit for reports.
-		pageSize = 100
-	}
-
-	reportData, totalItems, err := h.subService.GetUsersWithExpiringSubscriptions(ctx, daysInAdvance, page, pageSize)
+	reportData, totalItems, err := h.subService.GetUsersWithExpiringSubscriptions(ctx, daysInAdvance, params.Page, params.PageSize)
 	if err != nil {
-		slog.ErrorContext(ctx, "ListUsersWithExpiringSubscriptions: failed to get report from service", "error", err, "days_in_advance", daysInAdvance, "page", page)
-		respondWithError(w, http.StatusInternalServerError, "Failed to generate expiring subscriptions report.")
+		slog.ErrorContext(ctx, "ListUsersWithExpiringSubscriptions: failed to get report from service", "error", err, "days_in_advance", daysInAdvance, "page", params.Page)
+		respondWithServiceError(w, err, "Failed to generate expiring subscriptions report.")
 		return
 	}
 
@@ -366,29 +702,12 @@ func (h *SubscriptionHandler) ListUsersWithExpiringSubscriptions(w http.Response
 			}
 		}
 		responseData[i] = dto.UserWithExpiringSubscriptionsResponse{
-			User:                  toUserResponse(&data.User),
+			User:                  toUserResponse(ctx, &data.User),
 			ExpiringSubscriptions: expiringSubsDTO,
 		}
 	}
 
-	totalPages := 0
-	if totalItems > 0 && pageSize > 0 {
-		// totalItems here refers to the total number of expiring *subscriptions* or *users with expiring subscriptions*
-		// depending on the service layer's pagination strategy.
-		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
-	}
-	if page > totalPages && totalPages > 0 {
-		responseData = []dto.UserWithExpiringSubscriptionsResponse{}
-		slog.WarnContext(ctx, "ListUsersWithExpiringSubscriptions: requested page is out of bounds", "requested_page", page, "total_pages", totalPages)
-	}
-
-	paginatedResponse := dto.PaginatedUserExpiringSubscriptionsResponse{
-		Data:        responseData,
-		TotalItems:  totalItems,
-		CurrentPage: page,
-		PageSize:    pageSize,
-		TotalPages:  totalPages,
-	}
+	paginatedResponse := newPaginatedResponse(ctx, "ListUsersWithExpiringSubscriptions", "data", responseData, params, totalItems)
 
 	slog.InfoContext(ctx, "ListUsersWithExpiringSubscriptions: report generated successfully", "users_in_page", len(responseData), "total_items_for_pagination", totalItems)
 	respondWithJSON(w, http.StatusOK, paginatedResponse)
@@ -400,12 +719,8 @@ func (h *SubscriptionHandler) ListActiveSubscriptionsByPlan(w http.ResponseWrite
 	ctx := r.Context()
 	slog.InfoContext(ctx, "ListActiveSubscriptionsByPlan: received request for active subscriptions by plan")
 
-	// TODO: Add authorization check.
-
 	query := r.URL.Query()
 	planName := query.Get("plan_name")
-	pageStr := query.Get("page")
-	pageSizeStr := query.Get("pageSize")
 
 	if strings.TrimSpace(planName) == "" {
 		slog.WarnContext(ctx, "ListActiveSubscriptionsByPlan: missing 'plan_name' query parameter")
@@ -413,20 +728,9 @@ func (h *SubscriptionHandler) ListActiveSubscriptionsByPlan(w http.ResponseWrite
 		return
 	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 { // Max page size limit.
-		pageSize = 100
-	}
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointPlanSubscriptions))
 
-	subsModels, totalItems, err := h.subService.ListActiveSubscriptionsByPlan(ctx, planName, page, pageSize)
+	subsModels, totalItems, err := h.subService.ListActiveSubscriptionsByPlan(ctx, planName, params.Page, params.PageSize)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListActiveSubscriptionsByPlan: failed to retrieve subscriptions from service", "error", err, "plan_name", planName)
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve subscriptions list for plan: %s.", planName))
@@ -438,23 +742,103 @@ func (h *SubscriptionHandler) ListActiveSubscriptionsByPlan(w http.ResponseWrite
 		subResponses[i] = toSubscriptionResponse(&s)
 	}
 
-	totalPages := 0
-	if totalItems > 0 && pageSize > 0 {
-		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	response := newPaginatedResponse(ctx, "ListActiveSubscriptionsByPlan", "subscriptions", subResponses, params, totalItems)
+
+	slog.InfoContext(ctx, "ListActiveSubscriptionsByPlan: successfully listed subscriptions", "plan_name", planName, "count_in_page", len(subResponses), "total_items", totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetChurnReport handles the request to report subscription churn over a period.
+// Accepts optional 'from' and 'to' query parameters (RFC 3339 or YYYY-MM-DD); defaults to the last 30 days.
+// Expected route: GET /api/v1/reports/churn
+func (h *SubscriptionHandler) GetChurnReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	from, to, err := parseReportPeriod(r.URL.Query(), defaultReportDays)
+	if err != nil {
+		slog.WarnContext(ctx, "GetChurnReport: invalid report period", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if page > totalPages && totalPages > 0 {
-		subResponses = []dto.SubscriptionResponse{}
-		slog.WarnContext(ctx, "ListActiveSubscriptionsByPlan: requested page is out of bounds", "requested_page", page, "total_pages", totalPages)
+
+	report, err := h.subService.GetChurnReport(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetChurnReport: failed to get churn report from service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate churn report.")
+		return
 	}
 
-	response := dto.PaginatedSubscriptionsResponse{
-		Subscriptions: subResponses,
-		TotalItems:    totalItems,
-		TotalPages:    totalPages,
-		CurrentPage:   page,
-		PageSize:      pageSize,
+	respondWithJSON(w, http.StatusOK, dto.ChurnReportResponse{
+		From:          report.From,
+		To:            report.To,
+		ActiveAtStart: report.ActiveAtStart,
+		Renewed:       report.Renewed,
+		Expired:       report.Expired,
+		Cancelled:     report.Cancelled,
+		Churned:       report.Churned,
+		ChurnRate:     report.ChurnRate,
+	})
+}
+
+// GetSubscriptionsByCurrency handles the request to report subscriptions and revenue per currency over a period.
+// Accepts optional 'from' and 'to' query parameters (RFC 3339 or YYYY-MM-DD); defaults to the last 30 days.
+// Subscriptions without a currency are reported in their own entry with a null currency.
+// Expected route: GET /api/v1/reports/subscriptions-by-currency
+func (h *SubscriptionHandler) GetSubscriptionsByCurrency(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	from, to, err := parseReportPeriod(r.URL.Query(), defaultReportDays)
+	if err != nil {
+		slog.WarnContext(ctx, "GetSubscriptionsByCurrency: invalid report period", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	slog.InfoContext(ctx, "ListActiveSubscriptionsByPlan: successfully listed subscriptions", "plan_name", planName, "count_in_page", len(subResponses), "total_items", totalItems)
-	respondWithJSON(w, http.StatusOK, response)
+	report, err := h.subService.GetSubscriptionsByCurrency(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetSubscriptionsByCurrency: failed to get currency report from service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate subscriptions by currency report.")
+		return
+	}
+
+	resp := dto.SubscriptionsByCurrencyResponse{
+		From:          report.From,
+		To:            report.To,
+		Subscriptions: report.Subscriptions,
+		Currencies:    make([]dto.CurrencyTotalsResponse, len(report.Currencies)),
+	}
+	for i, totals := range report.Currencies {
+		resp.Currencies[i] = dto.CurrencyTotalsResponse{
+			Subscriptions: totals.Subscriptions,
+			Paid:          totals.Paid,
+			Revenue:       totals.Revenue,
+		}
+		if totals.Currency != "" {
+			currency := totals.Currency
+			resp.Currencies[i].Currency = &currency
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// RunRenewals handles the request to run the subscription auto-renewal job immediately.
+// The job is idempotent, so running it while the worker also runs does not renew a subscription twice.
+func (h *SubscriptionHandler) RunRenewals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	summary, err := h.subService.RunRenewals(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "RunRenewals: failed to run renewals via service", "error", err)
+		respondWithServiceError(w, err, "Failed to run subscription renewals.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dto.RenewalRunResponse{
+		WindowStart: summary.WindowStart,
+		WindowEnd:   summary.WindowEnd,
+		Candidates:  summary.Candidates,
+		Renewed:     summary.Renewed,
+		Skipped:     summary.Skipped,
+		Deactivated: summary.Deactivated,
+	})
 }
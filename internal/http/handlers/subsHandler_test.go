@@ -0,0 +1,691 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestSubscriptionHandler returns a SubscriptionHandler on svc with the default configuration.
+func newTestSubscriptionHandler(svc *fakeSubscriptionService) *SubscriptionHandler {
+	return NewSubscriptionHandler(svc, &config.Config{DefaultPageSize: 10})
+}
+
+func TestListSubscriptionsPagination(t *testing.T) {
+	const totalItems = 25
+
+	tests := []struct {
+		name         string
+		query        string
+		wantPage     int
+		wantPageSize int
+		wantItems    int
+		wantHasNext  bool
+	}{
+		{name: "defaults", query: "", wantPage: 1, wantPageSize: 10, wantItems: 10, wantHasNext: true},
+		{name: "last page", query: "?page=3&pageSize=10", wantPage: 3, wantPageSize: 10, wantItems: 5},
+		{name: "page size capped", query: "?pageSize=1000", wantPage: 1, wantPageSize: maxPageSize, wantItems: totalItems},
+		{name: "invalid values fall back", query: "?page=-2&pageSize=abc", wantPage: 1, wantPageSize: 10, wantItems: 10, wantHasNext: true},
+		{name: "page past the end", query: "?page=9&pageSize=10", wantPage: 9, wantPageSize: 10, wantItems: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPage, gotPageSize int
+			svc := &fakeSubscriptionService{
+				listSubscriptions: func(_ context.Context, _ customTypes.ListSubscriptionsFilters, page, pageSize int) ([]models.Subscription, int64, error) {
+					gotPage, gotPageSize = page, pageSize
+					// Serve the requested slice of a result set of totalItems subscriptions.
+					var subs []models.Subscription
+					for i := (page - 1) * pageSize; i < min(page*pageSize, totalItems); i++ {
+						subs = append(subs, models.Subscription{ID: uuid.New(), DurationUnit: customTypes.UnitMonth})
+					}
+					return subs, totalItems, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/subscriptions"+tt.query, nil), uuid.New(), customTypes.RoleAdmin)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+			}
+			if gotPage != tt.wantPage || gotPageSize != tt.wantPageSize {
+				t.Errorf("service called with page %d, pageSize %d; want %d, %d", gotPage, gotPageSize, tt.wantPage, tt.wantPageSize)
+			}
+			body := decodeJSON[struct {
+				Subscriptions []map[string]any `json:"subscriptions"`
+				TotalItems    int64            `json:"total_items"`
+				HasNext       bool             `json:"has_next"`
+			}](t, rec)
+			if len(body.Subscriptions) != tt.wantItems || body.TotalItems != totalItems || body.HasNext != tt.wantHasNext {
+				t.Errorf("got %d items, total %d, has_next %v; want %d, %d, %v",
+					len(body.Subscriptions), body.TotalItems, body.HasNext, tt.wantItems, totalItems, tt.wantHasNext)
+			}
+		})
+	}
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "deleted", path: "/v1/subscriptions/" + uuid.NewString(), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "not found", path: "/v1/subscriptions/" + uuid.NewString(), role: customTypes.RoleAdmin,
+			serviceErr: fmt.Errorf("subscription not found: %w", services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "invalid ID", path: "/v1/subscriptions/not-a-uuid", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", path: "/v1/subscriptions/" + uuid.NewString(), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "repository failure", path: "/v1/subscriptions/" + uuid.NewString(), role: customTypes.RoleAdmin,
+			serviceErr: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeSubscriptionService{
+				deleteSubscription: func(context.Context, uuid.UUID, uuid.UUID) error { return tt.serviceErr },
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodDelete, tt.path, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsReminderSent(t *testing.T) {
+	notifiedAt := time.Date(2026, time.May, 3, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFilter *bool
+	}{
+		{name: "no filter", wantStatus: http.StatusOK},
+		{name: "reminded", query: "?reminder_sent=true", wantStatus: http.StatusOK, wantFilter: ptrTo(true)},
+		{name: "not reminded", query: "?reminder_sent=false", wantStatus: http.StatusOK, wantFilter: ptrTo(false)},
+		{name: "invalid value", query: "?reminder_sent=maybe", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFilter *bool
+			svc := &fakeSubscriptionService{
+				listSubscriptions: func(_ context.Context, filters customTypes.ListSubscriptionsFilters, _, _ int) ([]models.Subscription, int64, error) {
+					gotFilter = filters.ReminderSent
+					return []models.Subscription{
+						{ID: uuid.New(), DurationUnit: customTypes.UnitMonth, LastExpiryNotifiedAt: &notifiedAt},
+						{ID: uuid.New(), DurationUnit: customTypes.UnitMonth},
+					}, 2, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/subscriptions"+tt.query, nil), uuid.New(), customTypes.RoleAdmin)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if (gotFilter == nil) != (tt.wantFilter == nil) || (gotFilter != nil && *gotFilter != *tt.wantFilter) {
+				t.Errorf("service called with ReminderSent = %v, want %v", deref(gotFilter), deref(tt.wantFilter))
+			}
+			body := decodeJSON[struct {
+				Subscriptions []dto.SubscriptionResponse `json:"subscriptions"`
+			}](t, rec)
+			if len(body.Subscriptions) != 2 {
+				t.Fatalf("got %d subscriptions, want 2", len(body.Subscriptions))
+			}
+			if got := body.Subscriptions[0].LastExpiryNotifiedAt; got == nil || !got.Equal(notifiedAt) {
+				t.Errorf("last_expiry_notified_at = %v, want %v", got, notifiedAt)
+			}
+			if got := body.Subscriptions[1].LastExpiryNotifiedAt; got != nil {
+				t.Errorf("last_expiry_notified_at = %v for a subscription never reminded, want it omitted", got)
+			}
+		})
+	}
+}
+
+func TestMarkExpiryNotified(t *testing.T) {
+	subID := uuid.New()
+	notifiedAt := time.Date(2026, time.May, 3, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		path       string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "recorded", path: "/v1/subscriptions/" + subID.String() + "/expiry-notified", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "unknown subscription", path: "/v1/subscriptions/" + subID.String() + "/expiry-notified", role: customTypes.RoleAdmin,
+			serviceErr: fmt.Errorf("subscription with ID '%s' not found: %w", subID, services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "invalid ID", path: "/v1/subscriptions/abc/expiry-notified", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", path: "/v1/subscriptions/" + subID.String() + "/expiry-notified", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeSubscriptionService{
+				markExpiryNotified: func(_ context.Context, id uuid.UUID) (*models.Subscription, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.Subscription{ID: id, DurationUnit: customTypes.UnitMonth, LastExpiryNotifiedAt: &notifiedAt}, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodPatch, tt.path, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := decodeJSON[dto.SubscriptionResponse](t, rec).LastExpiryNotifiedAt; got == nil || !got.Equal(notifiedAt) {
+				t.Errorf("last_expiry_notified_at = %v, want %v", got, notifiedAt)
+			}
+		})
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	subID := uuid.New()
+	path := "/v1/subscriptions/" + subID.String() + "/usage"
+	periodStart := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	occurredAt := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		path       string
+		role       customTypes.UserRole
+		body       string
+		serviceErr error
+		wantStatus int
+		wantInput  serviceDTO.RecordUsageInput
+	}{
+		{name: "recorded", path: path, role: customTypes.RoleAdmin, body: `{"metric":"traffic_gb","quantity":5,"occurred_at":"2026-01-10T12:00:00Z"}`,
+			wantStatus: http.StatusOK, wantInput: serviceDTO.RecordUsageInput{Metric: "traffic_gb", Quantity: 5, OccurredAt: &occurredAt}},
+		{name: "occurred_at defaults in the service", path: path, role: customTypes.RoleAdmin, body: `{"metric":"traffic_gb","quantity":5}`,
+			wantStatus: http.StatusOK, wantInput: serviceDTO.RecordUsageInput{Metric: "traffic_gb", Quantity: 5}},
+		{name: "rejected by the service", path: path, role: customTypes.RoleAdmin, body: `{"metric":"traffic_gb","quantity":-1}`,
+			serviceErr: fmt.Errorf("usage quantity must be positive: %w", services.ErrValidation), wantStatus: http.StatusBadRequest},
+		{name: "unknown subscription", path: path, role: customTypes.RoleAdmin, body: `{"metric":"traffic_gb","quantity":1}`,
+			serviceErr: fmt.Errorf("subscription with ID %s not found: %w", subID, services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "malformed body", path: path, role: customTypes.RoleAdmin, body: `{"metric":`, wantStatus: http.StatusBadRequest},
+		{name: "invalid ID", path: "/v1/subscriptions/abc/usage", role: customTypes.RoleAdmin, body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", path: path, role: customTypes.RoleUser, body: `{"metric":"traffic_gb","quantity":1}`, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotInput serviceDTO.RecordUsageInput
+			svc := &fakeSubscriptionService{
+				recordUsage: func(_ context.Context, id uuid.UUID, input serviceDTO.RecordUsageInput) (*models.SubscriptionUsage, error) {
+					gotInput = input
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.SubscriptionUsage{SubscriptionID: id, Metric: input.Metric, Quantity: 12, PeriodStart: periodStart, PeriodEnd: periodStart.AddDate(0, 1, 0)}, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)), uuid.New(), tt.role)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if gotInput.Metric != tt.wantInput.Metric || gotInput.Quantity != tt.wantInput.Quantity ||
+				(gotInput.OccurredAt == nil) != (tt.wantInput.OccurredAt == nil) || (gotInput.OccurredAt != nil && !gotInput.OccurredAt.Equal(*tt.wantInput.OccurredAt)) {
+				t.Errorf("service input = %+v, want %+v", gotInput, tt.wantInput)
+			}
+			resp := decodeJSON[dto.SubscriptionUsageResponse](t, rec)
+			if resp.SubscriptionID != subID || resp.Quantity != 12 || !resp.PeriodStart.Equal(periodStart) {
+				t.Errorf("response = %+v, want the period total of subscription %s", resp, subID)
+			}
+		})
+	}
+}
+
+func TestGetUsageReport(t *testing.T) {
+	userID, subID := uuid.New(), uuid.New()
+	periodStart := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	report := &serviceDTO.UsageReport{SubscriptionID: subID, Periods: []serviceDTO.UsagePeriod{
+		{PeriodStart: periodStart, PeriodEnd: periodStart.AddDate(0, 1, 0), Metrics: map[string]int64{"traffic_gb": 11, "api_calls": 10}},
+		{PeriodStart: periodStart.AddDate(0, 2, 0), PeriodEnd: periodStart.AddDate(0, 3, 0), Metrics: map[string]int64{"traffic_gb": 4}},
+	}}
+
+	tests := []struct {
+		name       string
+		path       string
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "report", path: "/v1/subscriptions/" + subID.String() + "/usage", wantStatus: http.StatusOK},
+		{name: "another user's subscription", path: "/v1/subscriptions/" + subID.String() + "/usage",
+			serviceErr: fmt.Errorf("user not authorized to view subscription %s: %w", subID, services.ErrUnauthorized), wantStatus: http.StatusForbidden},
+		{name: "invalid ID", path: "/v1/subscriptions/abc/usage", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeSubscriptionService{
+				getUsageReport: func(_ context.Context, _, requestingUserID uuid.UUID, _ customTypes.UserRole) (*serviceDTO.UsageReport, error) {
+					if requestingUserID != userID {
+						t.Errorf("requesting user = %s, want %s", requestingUserID, userID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return report, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, tt.path, nil), userID, customTypes.RoleUser)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			resp := decodeJSON[dto.UsageReportResponse](t, rec)
+			if resp.SubscriptionID != subID || len(resp.Periods) != len(report.Periods) {
+				t.Fatalf("response = %+v, want %d periods of subscription %s", resp, len(report.Periods), subID)
+			}
+			for i, period := range resp.Periods {
+				want := report.Periods[i]
+				if !period.PeriodStart.Equal(want.PeriodStart) || !period.PeriodEnd.Equal(want.PeriodEnd) || !maps.Equal(period.Metrics, want.Metrics) {
+					t.Errorf("periods[%d] = %+v, want %+v", i, period, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsByCurrency(t *testing.T) {
+	march := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+		wantFrom   time.Time // Zero if the period defaults to the last 30 days.
+		wantTo     time.Time
+	}{
+		{name: "explicit period", query: "?from=2026-03-01&to=2026-04-01", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantFrom: march, wantTo: april},
+		{name: "RFC 3339 period", query: "?from=2026-03-01T00:00:00Z&to=2026-04-01T00:00:00Z", role: customTypes.RoleAdmin,
+			wantStatus: http.StatusOK, wantFrom: march, wantTo: april},
+		{name: "default period", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "invalid from", query: "?from=March", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2026-04-01&to=2026-03-01", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "service failure", role: customTypes.RoleAdmin, serviceErr: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+		{name: "not an admin", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFrom, gotTo time.Time
+			svc := &fakeSubscriptionService{
+				byCurrency: func(_ context.Context, from, to time.Time) (*serviceDTO.SubscriptionsByCurrency, error) {
+					gotFrom, gotTo = from, to
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &serviceDTO.SubscriptionsByCurrency{From: from, To: to, Subscriptions: 5, Currencies: []customTypes.SubscriptionCurrencyTotals{
+						{Currency: "", Subscriptions: 2, Paid: 1, Revenue: 3},
+						{Currency: "USD", Subscriptions: 3, Paid: 2, Revenue: 15.5},
+					}}, nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/subscriptions-by-currency"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if tt.wantFrom.IsZero() {
+				if gotTo.Sub(gotFrom) != 30*24*time.Hour || time.Since(gotTo) > time.Minute {
+					t.Errorf("service called for %v to %v, want the last 30 days", gotFrom, gotTo)
+				}
+			} else if !gotFrom.Equal(tt.wantFrom) || !gotTo.Equal(tt.wantTo) {
+				t.Errorf("service called for %v to %v, want %v to %v", gotFrom, gotTo, tt.wantFrom, tt.wantTo)
+			}
+			resp := decodeJSON[dto.SubscriptionsByCurrencyResponse](t, rec)
+			if resp.Subscriptions != 5 || len(resp.Currencies) != 2 {
+				t.Fatalf("response = %+v, want 5 subscriptions in 2 currencies", resp)
+			}
+			if blank := resp.Currencies[0]; blank.Currency != nil || blank.Subscriptions != 2 || blank.Paid != 1 || blank.Revenue != 3 {
+				t.Errorf("currencies[0] = %+v with currency %v, want the null-currency bucket", blank, deref(blank.Currency))
+			}
+			if usd := resp.Currencies[1]; deref(usd.Currency) != "USD" || usd.Subscriptions != 3 || usd.Paid != 2 || usd.Revenue != 15.5 {
+				t.Errorf("currencies[1] = %+v with currency %v, want the USD totals", usd, deref(usd.Currency))
+			}
+		})
+	}
+}
+
+func TestListSubscriptionEvents(t *testing.T) {
+	userID, subID := uuid.New(), uuid.New()
+	created := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	history := []models.SubscriptionEvent{
+		{ID: 1, SubscriptionID: subID, EventType: customTypes.SubscriptionEventCreated, NewValue: []byte(`{"plan_name":"Basic"}`), ActorUserID: &userID, CreatedAt: created},
+		{ID: 2, SubscriptionID: subID, EventType: customTypes.SubscriptionEventPaymentUpdated,
+			OldValue: []byte(`{"payment_status":"pending"}`), NewValue: []byte(`{"payment_status":"paid"}`), CreatedAt: created.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		serviceErr   error
+		wantStatus   int
+		wantPage     int
+		wantPageSize int
+		wantIDs      []uint
+	}{
+		{name: "history", path: "/v1/subscriptions/" + subID.String() + "/events", wantStatus: http.StatusOK, wantPage: 1, wantPageSize: 10, wantIDs: []uint{1, 2}},
+		{name: "paged", path: "/v1/subscriptions/" + subID.String() + "/events?page=2&pageSize=1", wantStatus: http.StatusOK, wantPage: 2, wantPageSize: 1, wantIDs: []uint{2}},
+		{name: "another user's subscription", path: "/v1/subscriptions/" + subID.String() + "/events",
+			serviceErr: fmt.Errorf("not yours: %w", services.ErrUnauthorized), wantStatus: http.StatusForbidden},
+		{name: "missing subscription", path: "/v1/subscriptions/" + subID.String() + "/events",
+			serviceErr: fmt.Errorf("gone: %w", services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "invalid ID", path: "/v1/subscriptions/abc/events", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPage, gotPageSize int
+			svc := &fakeSubscriptionService{
+				listEvents: func(_ context.Context, subscriptionID, requestingUserID uuid.UUID, role customTypes.UserRole, page, pageSize int) ([]models.SubscriptionEvent, int64, error) {
+					if subscriptionID != subID || requestingUserID != userID || role != customTypes.RoleUser {
+						t.Errorf("service called for %s by %s (%s), want %s by %s (user)", subscriptionID, requestingUserID, role, subID, userID)
+					}
+					gotPage, gotPageSize = page, pageSize
+					if tt.serviceErr != nil {
+						return nil, 0, tt.serviceErr
+					}
+					offset := min((page-1)*pageSize, len(history))
+					return history[offset:min(offset+pageSize, len(history))], int64(len(history)), nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, tt.path, nil), userID, customTypes.RoleUser)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if gotPage != tt.wantPage || gotPageSize != tt.wantPageSize {
+				t.Errorf("service called for page %d of size %d, want %d of %d", gotPage, gotPageSize, tt.wantPage, tt.wantPageSize)
+			}
+			resp := decodeJSON[struct {
+				Events []dto.SubscriptionEventResponse `json:"events"`
+			}](t, rec)
+			if len(resp.Events) != len(tt.wantIDs) {
+				t.Fatalf("response has %d events, want %d", len(resp.Events), len(tt.wantIDs))
+			}
+			for i, event := range resp.Events {
+				want := history[tt.wantIDs[i]-1]
+				if event.ID != want.ID || event.EventType != want.EventType || !event.CreatedAt.Equal(want.CreatedAt) ||
+					!bytes.Equal(event.OldValue, want.OldValue) || !bytes.Equal(event.NewValue, want.NewValue) ||
+					(event.ActorUserID == nil) != (want.ActorUserID == nil) {
+					t.Errorf("events[%d] = %+v, want %+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+// deref returns *p, or the zero value when p is nil.
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+func TestListUserSubscriptionsFilters(t *testing.T) {
+	userID := uuid.New()
+	path := "/v1/users/" + userID.String() + "/subscriptions"
+	status := func(s customTypes.SubscriptionListStatus) *customTypes.SubscriptionListStatus { return &s }
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       serviceDTO.ListUserSubscriptionsParams
+	}{
+		{name: "defaults", wantStatus: http.StatusOK, want: serviceDTO.ListUserSubscriptionsParams{Page: 1, PageSize: 10}},
+		{name: "expired", query: "?status=expired", wantStatus: http.StatusOK,
+			want: serviceDTO.ListUserSubscriptionsParams{Page: 1, PageSize: 10, Status: status(customTypes.SubscriptionListStatusExpired)}},
+		{name: "status is case-insensitive", query: "?status=%20Cancelled%20", wantStatus: http.StatusOK,
+			want: serviceDTO.ListUserSubscriptionsParams{Page: 1, PageSize: 10, Status: status(customTypes.SubscriptionListStatusCancelled)}},
+		{name: "payment status and plan", query: "?payment_status=pending&plan_name=Premium", wantStatus: http.StatusOK,
+			want: serviceDTO.ListUserSubscriptionsParams{Page: 1, PageSize: 10, PaymentStatus: ptrTo("pending"), PlanName: ptrTo("Premium")}},
+		{name: "sorted", query: "?sort_by=end_date&sort_order=asc&page=2&pageSize=5", wantStatus: http.StatusOK,
+			want: serviceDTO.ListUserSubscriptionsParams{Page: 2, PageSize: 5, SortBy: "end_date", SortOrder: "asc"}},
+		{name: "unknown status", query: "?status=paused", wantStatus: http.StatusBadRequest},
+		{name: "unsortable column", query: "?sort_by=price", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got serviceDTO.ListUserSubscriptionsParams
+			svc := &fakeSubscriptionService{
+				listUserSubs: func(_ context.Context, id uuid.UUID, params serviceDTO.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
+					if id != userID {
+						t.Errorf("service called for user %s, want %s", id, userID)
+					}
+					got = params
+					return []models.Subscription{{ID: uuid.New(), UserID: userID, DurationUnit: customTypes.UnitMonth}}, 1, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, path+tt.query, nil), userID, customTypes.RoleUser)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got.Page != tt.want.Page || got.PageSize != tt.want.PageSize || got.SortBy != tt.want.SortBy || got.SortOrder != tt.want.SortOrder ||
+				deref(got.Status) != deref(tt.want.Status) || deref(got.PaymentStatus) != deref(tt.want.PaymentStatus) || deref(got.PlanName) != deref(tt.want.PlanName) {
+				t.Errorf("service called with %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionForUser(t *testing.T) {
+	userID := uuid.New()
+	const plan = `"plan_name":"Premium","duration_unit":"month","duration_value":1`
+	const start = `"start_date":"2026-05-01T00:00:00Z"`
+
+	tests := []struct {
+		name       string
+		principal  *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role       customTypes.UserRole
+		body       string
+		wantStatus int
+	}{
+		{name: "user subscribing themselves", principal: &userID, role: customTypes.RoleUser,
+			body: `{` + plan + `,` + start + `}`, wantStatus: http.StatusCreated},
+		{name: "user subscribing another user", principal: ptrTo(uuid.New()), role: customTypes.RoleUser,
+			body: `{` + plan + `,` + start + `}`, wantStatus: http.StatusForbidden},
+		{name: "admin subscribing another user", principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin,
+			body: `{` + plan + `,` + start + `,"payment_status":"paid"}`, wantStatus: http.StatusCreated},
+		{name: "unauthenticated", body: `{` + plan + `,` + start + `}`, wantStatus: http.StatusUnauthorized},
+		{name: "missing start date", principal: &userID, role: customTypes.RoleUser,
+			body: `{` + plan + `}`, wantStatus: http.StatusBadRequest},
+		{name: "negative price", principal: &userID, role: customTypes.RoleUser,
+			body: `{` + plan + `,` + start + `,"price":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed currency", principal: &userID, role: customTypes.RoleUser,
+			body: `{` + plan + `,` + start + `,"currency":"EURO"}`, wantStatus: http.StatusBadRequest},
+		{name: "promo code too long", principal: &userID, role: customTypes.RoleUser,
+			body: `{` + plan + `,` + start + `,"promo_code":"` + strings.Repeat("A", maxPromoCodeLength+1) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "admin without payment status", principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin,
+			body: `{` + plan + `,` + start + `}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotInput *serviceDTO.CreateSubscriptionInput
+			svc := &fakeSubscriptionService{
+				createSubscription: func(_ context.Context, input serviceDTO.CreateSubscriptionInput) (*models.Subscription, bool, error) {
+					gotInput = &input
+					return &models.Subscription{ID: uuid.New(), UserID: input.UserID, PlanName: input.PlanName, DurationUnit: input.DurationUnit}, true, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/users/"+userID.String()+"/subscriptions", strings.NewReader(tt.body))
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if gotInput != nil {
+					t.Errorf("service was called with %+v for a rejected request", *gotInput)
+				}
+				return
+			}
+			if gotInput == nil {
+				t.Fatal("service was not called")
+			}
+			if gotInput.UserID != userID || deref(gotInput.ActorUserID) != *tt.principal || gotInput.ActorRole != tt.role {
+				t.Errorf("service called for user %s by %v (%s), want %s by %s (%s)", gotInput.UserID, gotInput.ActorUserID, gotInput.ActorRole, userID, *tt.principal, tt.role)
+			}
+		})
+	}
+}
+
+func TestGetUserSubscriptionStatus(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		userID     string
+		principal  *uuid.UUID // The authenticated user; nil for an unauthenticated request.
+		role       customTypes.UserRole
+		status     *serviceDTO.UserSubscriptionStatus
+		serviceErr error
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "active subscription", userID: userID.String(), principal: &userID, role: customTypes.RoleUser,
+			status:     &serviceDTO.UserSubscriptionStatus{HasActiveSubscription: true, ActivePlans: []string{"Basic", "Premium"}},
+			wantStatus: http.StatusOK, wantBody: `{"has_active_subscription":true,"active_plans":["Basic","Premium"]}`},
+		{name: "no active subscription", userID: userID.String(), principal: &userID, role: customTypes.RoleUser,
+			status:     &serviceDTO.UserSubscriptionStatus{ActivePlans: []string{}},
+			wantStatus: http.StatusOK, wantBody: `{"has_active_subscription":false,"active_plans":[]}`},
+		{name: "unknown user", userID: userID.String(), principal: ptrTo(uuid.New()), role: customTypes.RoleAdmin,
+			serviceErr: fmt.Errorf("user not found: %w", services.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "invalid user ID", userID: "not-a-uuid", principal: &userID, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+		{name: "another user's status", userID: userID.String(), principal: ptrTo(uuid.New()), role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+		{name: "unauthenticated", userID: userID.String(), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID *uuid.UUID
+			svc := &fakeSubscriptionService{
+				userSubStatus: func(_ context.Context, id uuid.UUID) (*serviceDTO.UserSubscriptionStatus, error) {
+					gotUserID = &id
+					return tt.status, tt.serviceErr
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/"+tt.userID+"/subscription-status", nil)
+			if tt.principal != nil {
+				req = asPrincipal(req, *tt.principal, tt.role)
+			}
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			switch tt.wantStatus {
+			case http.StatusBadRequest, http.StatusForbidden, http.StatusUnauthorized:
+				if gotUserID != nil {
+					t.Error("service was called for a rejected request")
+				}
+				return
+			}
+			if gotUserID == nil || *gotUserID != userID {
+				t.Errorf("service called for user %v, want %s", gotUserID, userID)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s, want %s", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsFlagsInvalidDurationUnit(t *testing.T) {
+	tests := []struct {
+		name        string
+		unit        customTypes.DurationUnit
+		wantInvalid bool
+	}{
+		{name: "known unit", unit: customTypes.UnitMonth},
+		{name: "legacy unit", unit: "fortnight", wantInvalid: true},
+		{name: "empty unit", unit: "", wantInvalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validID, flaggedID := uuid.New(), uuid.New()
+			svc := &fakeSubscriptionService{
+				listSubscriptions: func(context.Context, customTypes.ListSubscriptionsFilters, int, int) ([]models.Subscription, int64, error) {
+					return []models.Subscription{
+						{ID: validID, DurationUnit: customTypes.UnitYear},
+						{ID: flaggedID, DurationUnit: tt.unit},
+					}, 2, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/subscriptions", nil), uuid.New(), customTypes.RoleAdmin)
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+			}
+			body := decodeJSON[struct {
+				Subscriptions []map[string]any `json:"subscriptions"`
+			}](t, rec)
+			if len(body.Subscriptions) != 2 {
+				t.Fatalf("got %d subscriptions, want both", len(body.Subscriptions))
+			}
+			if _, ok := body.Subscriptions[0]["invalid"]; ok {
+				t.Errorf("valid subscription is flagged: %v", body.Subscriptions[0])
+			}
+			if invalid, _ := body.Subscriptions[1]["invalid"].(bool); invalid != tt.wantInvalid {
+				t.Errorf("subscription with unit %q has invalid = %v, want %v", tt.unit, invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bitback/internal/models"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// iCalContentType is the content type of iCalendar feeds.
+	iCalContentType = "text/calendar; charset=utf-8"
+	// iCalProductID identifies this service as the producer of iCalendar feeds.
+	iCalProductID = "-//BittenCloud//bitback//EN"
+	// iCalDateFormat and iCalDateTimeFormat are the iCalendar DATE and UTC DATE-TIME formats.
+	iCalDateFormat     = "20060102"
+	iCalDateTimeFormat = "20060102T150405Z"
+	// iCalMaxLineLength is the maximum length of a content line in octets before it must be folded.
+	iCalMaxLineLength = 75
+)
+
+// iCalTextEscaper escapes characters that have special meaning in iCalendar TEXT values.
+var iCalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// buildSubscriptionsICal renders an iCalendar feed with one all-day VEVENT on the end date of each subscription.
+// generatedAt is used as the DTSTAMP of every event.
+func buildSubscriptionsICal(subscriptions []models.Subscription, generatedAt time.Time) string {
+	var b strings.Builder
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+iCalProductID)
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:Subscriptions")
+
+	stamp := generatedAt.UTC().Format(iCalDateTimeFormat)
+	for _, sub := range subscriptions {
+		endDate := sub.EndDate.UTC()
+		summary := fmt.Sprintf("%s subscription expires", sub.PlanName)
+		if sub.AutoRenew {
+			summary = fmt.Sprintf("%s subscription renews", sub.PlanName)
+		}
+		description := fmt.Sprintf("Subscription %s (%d %s) ends at %s.",
+			sub.ID, sub.DurationValue, sub.DurationUnit, endDate.Format(time.RFC3339))
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, fmt.Sprintf("UID:subscription-%s@bitback", sub.ID))
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART;VALUE=DATE:"+endDate.Format(iCalDateFormat))
+		writeICalLine(&b, "DTEND;VALUE=DATE:"+endDate.AddDate(0, 0, 1).Format(iCalDateFormat))
+		writeICalLine(&b, "SUMMARY:"+iCalTextEscaper.Replace(summary))
+		writeICalLine(&b, "DESCRIPTION:"+iCalTextEscaper.Replace(description))
+		writeICalLine(&b, "TRANSP:TRANSPARENT")
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// writeICalLine writes a content line terminated by CRLF, folding it into continuation lines
+// of at most iCalMaxLineLength octets without splitting multi-byte characters.
+func writeICalLine(b *strings.Builder, line string) {
+	limit := iCalMaxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards their length.
+		limit = iCalMaxLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGetUserSubscriptionsCalendar(t *testing.T) {
+	owner := uuid.New()
+	subs := []models.Subscription{
+		{ID: uuid.New(), UserID: owner, PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+			EndDate: time.Date(2026, time.November, 16, 12, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), UserID: owner, PlanName: "Pro", DurationUnit: customTypes.UnitYear, DurationValue: 1, AutoRenew: true,
+			EndDate: time.Date(2027, time.January, 31, 23, 30, 0, 0, time.UTC)},
+		{ID: uuid.New(), UserID: owner, PlanName: "Trial", DurationUnit: customTypes.UnitDay, DurationValue: 7,
+			EndDate: time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		authUser   *uuid.UUID
+		role       customTypes.UserRole
+		serviceErr error
+		wantStatus int
+	}{
+		{name: "owner", path: "/v1/users/" + owner.String() + "/subscriptions.ics", authUser: &owner, role: customTypes.RoleUser, wantStatus: http.StatusOK},
+		{name: "admin", path: "/v1/users/" + owner.String() + "/subscriptions.ics", authUser: ptrTo(uuid.New()), role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "another user", path: "/v1/users/" + owner.String() + "/subscriptions.ics", authUser: ptrTo(uuid.New()), role: customTypes.RoleUser,
+			serviceErr: fmt.Errorf("user not authorized: %w", services.ErrUnauthorized), wantStatus: http.StatusForbidden},
+		{name: "unauthenticated", path: "/v1/users/" + owner.String() + "/subscriptions.ics", wantStatus: http.StatusUnauthorized},
+		{name: "invalid user ID", path: "/v1/users/not-a-uuid/subscriptions.ics", authUser: &owner, role: customTypes.RoleUser, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeSubscriptionService{
+				listAllUserSubs: func(_ context.Context, userID, _ uuid.UUID, _ customTypes.UserRole) ([]models.Subscription, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					if userID != owner {
+						t.Errorf("service called for user %s, want %s", userID, owner)
+					}
+					return subs, nil
+				},
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authUser != nil {
+				req = asPrincipal(req, *tt.authUser, tt.role)
+			}
+
+			rec := serveRoutes(newTestSubscriptionHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "text/calendar; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want text/calendar", got)
+			}
+
+			body := rec.Body.String()
+			if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+				t.Errorf("body is not a VCALENDAR:\n%s", body)
+			}
+			if got := strings.Count(body, "BEGIN:VEVENT\r\n"); got != len(subs) {
+				t.Errorf("got %d events, want %d", got, len(subs))
+			}
+			for _, want := range []string{
+				"UID:subscription-" + subs[0].ID.String() + "@bitback",
+				"DTSTART;VALUE=DATE:20261116\r\nDTEND;VALUE=DATE:20261117",
+				"DTSTART;VALUE=DATE:20270131\r\nDTEND;VALUE=DATE:20270201",
+				"DTSTART;VALUE=DATE:20260228\r\nDTEND;VALUE=DATE:20260301",
+				"SUMMARY:Basic subscription expires",
+				"SUMMARY:Pro subscription renews",
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("feed does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteICalLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "short line", line: "SUMMARY:Basic", want: "SUMMARY:Basic\r\n"},
+		{name: "exactly 75 octets", line: strings.Repeat("a", 75), want: strings.Repeat("a", 75) + "\r\n"},
+		{name: "folded", line: strings.Repeat("a", 80), want: strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 5) + "\r\n"},
+		{name: "multi-byte character kept whole", line: strings.Repeat("a", 74) + "é", want: strings.Repeat("a", 74) + "\r\n é\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeICalLine(&b, tt.line)
+			if got := b.String(); got != tt.want {
+				t.Errorf("writeICalLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
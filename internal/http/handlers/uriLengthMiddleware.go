@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// LimitURILength returns a middleware that rejects requests whose raw request URI (path and query string)
+// is longer than maxLength bytes with 414 URI Too Long, before any handler parses the query.
+// A maxLength of 0 or less disables the limit.
+func LimitURILength(maxLength int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if maxLength <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI() // Requests not read from the wire, e.g. in-process ones, have no raw URI.
+			}
+			if len(uri) > maxLength {
+				slog.WarnContext(r.Context(), "LimitURILength: rejecting request with overlong URI", "method", r.Method, "path", r.URL.Path, "uri_length", len(uri), "max_length", maxLength)
+				respondWithError(w, http.StatusRequestURITooLong, "Request URI is too long.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
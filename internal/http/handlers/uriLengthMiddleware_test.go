@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitURILength(t *testing.T) {
+	const maxLength = 64
+	// uriOfLength returns a request URI of exactly n bytes with a long 'ids' query parameter.
+	uriOfLength := func(n int) string {
+		const prefix = "/v1/hosts?ids="
+		return prefix + strings.Repeat("1", n-len(prefix))
+	}
+
+	tests := []struct {
+		name       string
+		maxLength  int
+		uri        string
+		fromWire   bool // Whether the request carries a raw RequestURI, as requests read by the server do.
+		wantStatus int
+	}{
+		{name: "short URI", maxLength: maxLength, uri: "/v1/hosts", fromWire: true, wantStatus: http.StatusOK},
+		{name: "at the limit", maxLength: maxLength, uri: uriOfLength(maxLength), fromWire: true, wantStatus: http.StatusOK},
+		{name: "one byte over the limit", maxLength: maxLength, uri: uriOfLength(maxLength + 1), fromWire: true, wantStatus: http.StatusRequestURITooLong},
+		{name: "far over the limit", maxLength: maxLength, uri: uriOfLength(10 * maxLength), fromWire: true, wantStatus: http.StatusRequestURITooLong},
+		{name: "in-process request over the limit", maxLength: maxLength, uri: uriOfLength(maxLength + 1), wantStatus: http.StatusRequestURITooLong},
+		{name: "in-process request at the limit", maxLength: maxLength, uri: uriOfLength(maxLength), wantStatus: http.StatusOK},
+		{name: "limit disabled", maxLength: 0, uri: uriOfLength(10 * maxLength), fromWire: true, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.uri, nil)
+			if !tt.fromWire {
+				req.RequestURI = ""
+			}
+
+			rec := httptest.NewRecorder()
+			LimitURILength(tt.maxLength)(next).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if wantCalled := tt.wantStatus == http.StatusOK; called != wantCalled {
+				t.Errorf("next handler called = %v, want %v", called, wantCalled)
+			}
+		})
+	}
+}
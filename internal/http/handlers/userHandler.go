@@ -1,47 +1,61 @@
 package handlers
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/http/handlers/dto"
 	"bitback/internal/interfaces"
+	"bitback/internal/models/customTypes"
 	serviceDTO "bitback/internal/services/dto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 	"log/slog"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+// defaultInactiveUserDays is the inactivity threshold of the inactive users report when none is requested.
+const defaultInactiveUserDays = 90
+
 // UserHandler handles HTTP requests related to users.
 type UserHandler struct {
 	userService interfaces.UserService
+	cfg         *config.Config
 }
 
 // NewUserHandler creates a new instance of UserHandler.
-func NewUserHandler(us interfaces.UserService) *UserHandler {
+func NewUserHandler(us interfaces.UserService, cfg *config.Config) *UserHandler {
 	return &UserHandler{
 		userService: us,
+		cfg:         cfg,
 	}
 }
 
 // RegisterRoutes registers the HTTP routes for user-related actions.
-func (h *UserHandler) RegisterRoutes(mux *http.ServeMux) {
+func (h *UserHandler) RegisterRoutes(mux RouteRegistrar) {
 	mux.HandleFunc("POST /v1/users", h.CreateUser)
-	mux.HandleFunc("GET /v1/users/{userID}", h.GetUser)
-	mux.HandleFunc("PUT /v1/users/{userID}", h.UpdateUser)
-	mux.HandleFunc("DELETE /v1/users/{userID}", h.DeleteUser)
-	mux.HandleFunc("GET /v1/users", h.ListUsers)
+	mux.HandleFunc("GET /v1/users/{userID}", requireSelfOrAdmin(h.GetUser))
+	// Registered as overlapping: it matches the same paths as "GET /v1/users/{userID}/subscriptions" and the like
+	// for a userID of "by-telegram", which is never a valid user ID.
+	mux.HandleOverlapping("GET /v1/users/by-telegram/{telegramID}", requireAdmin(h.GetUserByTelegramID))
+	mux.HandleFunc("PUT /v1/users/{userID}", requireSelfOrAdmin(h.UpdateUser))
+	mux.HandleFunc("DELETE /v1/users/{userID}", requireSelfOrAdmin(h.DeleteUser))
+	mux.HandleFunc("POST /v1/users/{userID}/restore", requireAdmin(h.RestoreUser))
+	mux.HandleFunc("GET /v1/users", requireAdmin(h.ListUsers))
+	mux.HandleFunc("PATCH /v1/users/{userID}/role", requireAdmin(h.UpdateUserRole))
+	// Called by the auth gateway after a successful login.
+	mux.HandleFunc("POST /v1/users/{userID}/login-events", requireAdmin(h.RecordLogin))
+	mux.HandleFunc("GET /v1/reports/inactive-users", requireAdmin(h.ListInactiveUsers))
+	mux.HandleFunc("GET /v1/reports/users-by-host-country", requireAdmin(h.ListUsersByHostCountry))
 }
 
 // CreateUser handles the request to create a new user.
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	var req dto.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	req, err := decodeVersionedPayload[dto.CreateUserRequest](r, nil)
+	if err != nil {
 		slog.ErrorContext(ctx, "CreateUser: failed to decode request body", "error", err)
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
 		return
@@ -55,22 +69,27 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		TelegramID: req.TelegramID,
 	}
 
-	user, err := h.userService.RegisterUser(r.Context(), serviceInput)
+	user, trial, err := h.userService.RegisterUser(r.Context(), serviceInput)
 	if err != nil {
 		slog.ErrorContext(ctx, "CreateUser: failed to register user via service", "error", err, "email", req.Email)
 		// Check for specific errors like duplicate email.
-		if errors.Is(err, gorm.ErrDuplicatedKey) ||
-			(err.Error() == fmt.Sprintf("user with email '%s' already exists", req.Email)) ||
-			strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "duplicate key") {
+		if errors.Is(err, interfaces.ErrEmailTaken) {
 			respondWithError(w, http.StatusConflict, "User with this email already exists.")
+		} else if errors.Is(err, interfaces.ErrTelegramIDTaken) {
+			respondWithError(w, http.StatusConflict, "User with this Telegram ID already exists.")
 		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to create user.")
+			respondWithServiceError(w, err, "Failed to create user.")
 		}
 		return
 	}
 
-	slog.InfoContext(ctx, "CreateUser: user created successfully", "userID", user.ID)
-	respondWithJSON(w, http.StatusCreated, toUserResponse(user))
+	resp := dto.CreateUserResponse{UserResponse: toUserResponse(ctx, user)}
+	if trial != nil {
+		trialResp := toSubscriptionResponse(trial)
+		resp.TrialSubscription = &trialResp
+	}
+	slog.InfoContext(ctx, "CreateUser: user created successfully", "userID", user.ID, "trial", trial != nil)
+	respondWithJSON(w, http.StatusCreated, resp)
 }
 
 // GetUser handles the request to retrieve a user by their ID.
@@ -93,15 +112,122 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	user, err := h.userService.GetUser(r.Context(), userID)
 	if err != nil {
 		slog.ErrorContext(ctx, "GetUser: failed to get user from service", "userID", userID, "error", err)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "User not found.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve user.")
+		respondWithServiceError(w, err, "Failed to retrieve user.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, toUserResponse(ctx, user))
+}
+
+// RecordLogin handles the request to record a successful login of a user, updating their last login time.
+// Expected route: POST /api/v1/users/{userID}/login-events
+func (h *UserHandler) RecordLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "RecordLogin: invalid user ID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format.")
+		return
+	}
+
+	if err := h.userService.RecordLogin(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "RecordLogin: failed to record login via service", "userID", userID, "error", err)
+		respondWithServiceError(w, err, "Failed to record login.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListInactiveUsers handles the request to report users who have not logged in recently.
+// The 'days' query parameter sets the inactivity threshold and defaults to 90.
+// Expected route: GET /api/v1/reports/inactive-users
+func (h *UserHandler) ListInactiveUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days := defaultInactiveUserDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 1 {
+			slog.WarnContext(ctx, "ListInactiveUsers: invalid 'days' query parameter", "days", daysStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'days' query parameter (must be a positive integer): %s", daysStr))
+			return
 		}
+		days = parsed
+	}
+
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointInactiveUsers))
+
+	usersModels, totalItems, err := h.userService.ListInactiveUsers(ctx, days, params.Page, params.PageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListInactiveUsers: failed to list inactive users from service", "error", err)
+		respondWithServiceError(w, err, "Failed to generate inactive users report.")
+		return
+	}
+
+	userResponses := make([]dto.UserResponse, len(usersModels))
+	for i, u := range usersModels {
+		userResponses[i] = toUserResponse(ctx, &u)
+	}
+
+	response := newPaginatedResponse(ctx, "ListInactiveUsers", "users", userResponses, params, totalItems)
+	slog.InfoContext(ctx, "ListInactiveUsers: report generated successfully", "days", days, "count_in_page", len(userResponses), "total_items", totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ListUsersByHostCountry handles the request to report users whose active key is assigned to a host
+// in a given country, so that they can be contacted during a regional outage.
+// The 'country' query parameter is required.
+// Expected route: GET /api/v1/reports/users-by-host-country
+func (h *UserHandler) ListUsersByHostCountry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	country := strings.TrimSpace(r.URL.Query().Get("country"))
+	if country == "" {
+		slog.WarnContext(ctx, "ListUsersByHostCountry: missing 'country' query parameter")
+		respondWithError(w, http.StatusBadRequest, "The 'country' query parameter is required.")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, toUserResponse(user))
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointHostCountryUsers))
+
+	usersModels, totalItems, err := h.userService.ListUsersByHostCountry(ctx, country, params.Page, params.PageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListUsersByHostCountry: failed to list users from service", "country", country, "error", err)
+		respondWithServiceError(w, err, "Failed to generate users by host country report.")
+		return
+	}
+
+	userResponses := make([]dto.UserResponse, len(usersModels))
+	for i, u := range usersModels {
+		userResponses[i] = toUserResponse(ctx, &u)
+	}
+
+	response := newPaginatedResponse(ctx, "ListUsersByHostCountry", "users", userResponses, params, totalItems)
+	slog.InfoContext(ctx, "ListUsersByHostCountry: report generated successfully", "country", country, "count_in_page", len(userResponses), "total_items", totalItems)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetUserByTelegramID handles the request to retrieve a user by their Telegram ID.
+// Expected route: GET /api/v1/users/by-telegram/{telegramID}
+func (h *UserHandler) GetUserByTelegramID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	telegramIDStr := r.PathValue("telegramID")
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		slog.WarnContext(ctx, "GetUserByTelegramID: invalid Telegram ID format in path", "telegramID_str", telegramIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid Telegram ID format.")
+		return
+	}
+
+	user, err := h.userService.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserByTelegramID: failed to get user from service", "telegramID", telegramID, "error", err)
+		respondWithServiceError(w, err, "Failed to retrieve user.")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, toUserResponse(ctx, user))
 }
 
 // UpdateUser handles the request to update an existing user.
@@ -121,8 +247,8 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req dto.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	req, err := decodeVersionedPayload[dto.UpdateUserRequest](r, nil)
+	if err != nil {
 		slog.ErrorContext(ctx, "UpdateUser: failed to decode request body", "error", err)
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
 		return
@@ -130,6 +256,13 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	// TODO: Add request DTO validation here.
 
+	// Only administrators may activate or deactivate an account.
+	if req.IsActive != nil && !isAdminRequest(ctx) {
+		slog.WarnContext(ctx, "UpdateUser: non-admin attempted to change is_active", "userID", userID)
+		respondWithError(w, http.StatusForbidden, "Administrator role required to change is_active.")
+		return
+	}
+
 	serviceInput := serviceDTO.UpdateUserInput{
 		Name:       req.Name,
 		Email:      req.Email,
@@ -140,21 +273,17 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	updatedUser, err := h.userService.UpdateUser(r.Context(), userID, serviceInput)
 	if err != nil {
 		slog.ErrorContext(ctx, "UpdateUser: failed to update user via service", "userID", userID, "error", err)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "User not found.")
-		} else if strings.Contains(err.Error(), "email is already in use") {
-			respondWithError(w, http.StatusConflict, err.Error())
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to update user.")
-		}
+		respondWithServiceError(w, err, "Failed to update user.")
 		return
 	}
 
 	slog.InfoContext(ctx, "UpdateUser: user updated successfully", "userID", updatedUser.ID)
-	respondWithJSON(w, http.StatusOK, toUserResponse(updatedUser))
+	respondWithJSON(w, http.StatusOK, toUserResponse(ctx, updatedUser))
 }
 
 // DeleteUser handles the request to (soft) delete a user.
+// With the 'hard=true' query parameter the user and their subscriptions are permanently removed instead;
+// this is restricted to administrators and requires 'force=true' if the user has an active, paid subscription.
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userIDStr := r.PathValue("userID")
@@ -171,13 +300,23 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := r.URL.Query()
+	if hardStr := query.Get("hard"); hardStr != "" {
+		hard, err := strconv.ParseBool(hardStr)
+		if err != nil {
+			slog.WarnContext(ctx, "DeleteUser: invalid 'hard' query parameter", "hard", hardStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'hard' query parameter (expected true or false).")
+			return
+		}
+		if hard {
+			h.purgeUser(w, r, userID)
+			return
+		}
+	}
+
 	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
 		slog.ErrorContext(ctx, "DeleteUser: failed to delete user via service", "userID", userID, "error", err)
-		if errors.Is(err, gorm.ErrRecordNotFound) || strings.Contains(err.Error(), "not found") {
-			respondWithError(w, http.StatusNotFound, "User not found.")
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to delete user.")
-		}
+		respondWithServiceError(w, err, "Failed to delete user.")
 		return
 	}
 
@@ -185,63 +324,248 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "User deleted successfully."})
 }
 
+// RestoreUser handles the request to restore a soft-deleted user.
+// Expected route: POST /api/v1/users/{userID}/restore
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "RestoreUser: invalid user ID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format.")
+		return
+	}
+
+	user, err := h.userService.RestoreUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "RestoreUser: failed to restore user via service", "userID", userID, "error", err)
+		respondWithServiceError(w, err, "Failed to restore user.")
+		return
+	}
+	slog.InfoContext(ctx, "RestoreUser: user restored successfully", "userID", userID)
+	respondWithJSON(w, http.StatusOK, toUserResponse(ctx, user))
+}
+
+// purgeUser permanently deletes a user on behalf of an administrator.
+func (h *UserHandler) purgeUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	ctx := r.Context()
+	if !isAdminRequest(ctx) {
+		slog.WarnContext(ctx, "DeleteUser: hard delete requested by non-admin user", "userID", userID)
+		respondWithError(w, http.StatusForbidden, "Only administrators can permanently delete users.")
+		return
+	}
+
+	force := false
+	if forceStr := r.URL.Query().Get("force"); forceStr != "" {
+		var err error
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			slog.WarnContext(ctx, "DeleteUser: invalid 'force' query parameter", "force", forceStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'force' query parameter (expected true or false).")
+			return
+		}
+	}
+
+	if err := h.userService.PurgeUser(ctx, userID, force); err != nil {
+		slog.ErrorContext(ctx, "DeleteUser: failed to purge user via service", "userID", userID, "error", err)
+		respondWithServiceError(w, err, "Failed to purge user.")
+		return
+	}
+
+	slog.InfoContext(ctx, "DeleteUser: user purged successfully", "userID", userID)
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "User permanently deleted."})
+}
+
+// UpdateUserRole handles the request to change a user's role.
+// Only administrators are allowed to change roles.
+func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userIDStr := r.PathValue("userID")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		slog.WarnContext(ctx, "UpdateUserRole: invalid user ID format in path", "userID_str", userIDStr, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format.")
+		return
+	}
+
+	requestingUserID, err := getRequestingUserID(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to get requesting user ID", "error", err)
+		respondWithError(w, http.StatusUnauthorized, "Authentication required or failed: "+err.Error())
+		return
+	}
+
+	var req dto.UpdateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to decode request body", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if !req.Role.IsValid() {
+		slog.WarnContext(ctx, "UpdateUserRole: invalid role value provided in request", "role", req.Role)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid role value provided: %s", req.Role))
+		return
+	}
+
+	updatedUser, err := h.userService.UpdateUserRole(ctx, requestingUserID, userID, req.Role)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to update user role via service", "userID", userID, "error", err)
+		respondWithServiceError(w, err, "Failed to update user role.")
+		return
+	}
+
+	slog.InfoContext(ctx, "UpdateUserRole: user role updated successfully", "userID", updatedUser.ID, "role", updatedUser.Role)
+	respondWithJSON(w, http.StatusOK, toUserResponse(ctx, updatedUser))
+}
+
 // ListUsers handles the request to retrieve a paginated list of users.
+// Supports optional 'q' (name or email search), 'is_active', 'has_telegram', 'created_after' and
+// 'created_before' filters, 'include_deleted' (honored for administrators only), and sorting by 'sort_by' (created_at, name, email or last_login) and 'sort_order'.
+// Supplying the 'cursor' query parameter switches from page-based to keyset pagination.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	slog.InfoContext(ctx, "ListUsers: received request to list users")
+	query := r.URL.Query()
 
 	// Get pagination parameters from query string.
-	query := r.URL.Query()
-	pageStr := query.Get("page")
-	pageSizeStr := query.Get("pageSize")
+	params := parsePagination(r, h.cfg.GetDefaultPageSize(config.PageSizeEndpointUsers))
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1 // Default to page 1.
+	serviceParams := serviceDTO.ListUsersServiceParams{
+		Page:      params.Page,
+		PageSize:  params.PageSize,
+		SortBy:    query.Get("sort_by"),
+		SortOrder: query.Get("sort_order"),
 	}
-
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = 10 // Default page size.
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		serviceParams.Query = &q
+	}
+	if isActiveStr := query.Get("is_active"); isActiveStr != "" {
+		isActive, err := strconv.ParseBool(isActiveStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid 'is_active' query parameter", "is_active_param", isActiveStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'is_active' query parameter (must be true or false): %s", isActiveStr))
+			return
+		}
+		serviceParams.IsActive = &isActive
+	}
+	if hasTelegramStr := query.Get("has_telegram"); hasTelegramStr != "" {
+		hasTelegram, err := strconv.ParseBool(hasTelegramStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid 'has_telegram' query parameter", "has_telegram_param", hasTelegramStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'has_telegram' query parameter (must be true or false): %s", hasTelegramStr))
+			return
+		}
+		serviceParams.HasTelegram = &hasTelegram
+	}
+	if createdAfterStr := query.Get("created_after"); createdAfterStr != "" {
+		createdAfter, err := parseReportDate(createdAfterStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid 'created_after' query parameter", "created_after", createdAfterStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'created_after' query parameter (expected RFC 3339 or YYYY-MM-DD).")
+			return
+		}
+		serviceParams.CreatedAfter = &createdAfter
+	}
+	if createdBeforeStr := query.Get("created_before"); createdBeforeStr != "" {
+		createdBefore, err := parseReportDate(createdBeforeStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid 'created_before' query parameter", "created_before", createdBeforeStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'created_before' query parameter (expected RFC 3339 or YYYY-MM-DD).")
+			return
+		}
+		serviceParams.CreatedBefore = &createdBefore
+	}
+	if includeDeletedStr := query.Get("include_deleted"); includeDeletedStr != "" {
+		includeDeleted, err := strconv.ParseBool(includeDeletedStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid 'include_deleted' query parameter", "include_deleted_param", includeDeletedStr, "error", err)
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'include_deleted' query parameter (must be true or false): %s", includeDeletedStr))
+			return
+		}
+		// Deleted users are only listed for administrators; the flag is ignored for other callers.
+		if includeDeleted && !isAdminRequest(ctx) {
+			slog.InfoContext(ctx, "ListUsers: ignoring 'include_deleted' for non-admin caller")
+			includeDeleted = false
+		}
+		serviceParams.IncludeDeleted = includeDeleted
 	}
-	if pageSize > 100 {
-		pageSize = 100
+	if sortBy := serviceParams.SortBy; sortBy != "" && sortBy != "created_at" && sortBy != "name" && sortBy != "email" && sortBy != "last_login" {
+		slog.WarnContext(ctx, "ListUsers: invalid 'sort_by' query parameter", "sort_by_param", sortBy)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'sort_by' query parameter (must be created_at, name, email or last_login): %s", sortBy))
+		return
 	}
 
-	usersModels, totalItems, err := h.userService.ListUsers(ctx, page, pageSize)
+	if query.Has(cursorQueryParam) {
+		h.listUsersAfter(w, r, serviceParams, query.Get(cursorQueryParam))
+		return
+	}
+
+	usersModels, totalItems, err := h.userService.ListUsers(ctx, serviceParams)
 	if err != nil {
-		slog.ErrorContext(ctx, "ListUsers: failed to retrieve users from service", "error", err, "page", page, "pageSize", pageSize)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve users list.")
+		slog.ErrorContext(ctx, "ListUsers: failed to retrieve users from service", "error", err, "page", params.Page, "pageSize", params.PageSize)
+		respondWithServiceError(w, err, "Failed to retrieve users list.")
 		return
 	}
 
 	// Convert []models.User to []dto.UserResponse.
 	userResponses := make([]dto.UserResponse, len(usersModels))
 	for i, u := range usersModels {
-		userResponses[i] = toUserResponse(&u)
+		userResponses[i] = toUserResponse(ctx, &u)
+	}
+
+	response := newPaginatedResponse(ctx, "ListUsers", "users", userResponses, params, totalItems)
+
+	slog.InfoContext(ctx, "ListUsers: successfully listed users", "count_in_page", len(userResponses), "total_items", totalItems, "current_page", params.Page)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// listUsersAfter serves ListUsers in keyset pagination mode, used when the 'cursor' query parameter is present.
+// Results are ordered newest first; 'page' and sorting parameters are not supported in this mode.
+func (h *UserHandler) listUsersAfter(w http.ResponseWriter, r *http.Request, serviceParams serviceDTO.ListUsersServiceParams, cursor string) {
+	ctx := r.Context()
+	if serviceParams.SortBy != "" || serviceParams.SortOrder != "" {
+		slog.WarnContext(ctx, "ListUsers: sorting requested together with a cursor", "sort_by", serviceParams.SortBy, "sort_order", serviceParams.SortOrder)
+		respondWithError(w, http.StatusBadRequest, "Sorting parameters cannot be combined with 'cursor'.")
+		return
+	}
+
+	var after *customTypes.UserListCursor
+	if cursor != "" {
+		createdAt, idStr, err := decodeCursor(cursor)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid cursor", "cursor", cursor, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'cursor' query parameter.")
+			return
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			slog.WarnContext(ctx, "ListUsers: invalid user ID in cursor", "cursor", cursor, "error", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid 'cursor' query parameter.")
+			return
+		}
+		after = &customTypes.UserListCursor{CreatedAt: createdAt, ID: id}
 	}
 
-	totalPages := 0
-	if totalItems > 0 && pageSize > 0 {
-		totalPages = int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	usersModels, hasMore, err := h.userService.ListUsersAfter(ctx, serviceParams, after)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListUsers: failed to retrieve users from service", "error", err, "pageSize", serviceParams.PageSize)
+		respondWithServiceError(w, err, "Failed to retrieve users list.")
+		return
 	}
 
-	// Handle cases where the requested page is out of bounds.
-	// If totalPages is 0 (no items), this condition won't be met.
-	if page > totalPages && totalPages > 0 {
-		userResponses = []dto.UserResponse{}
-		slog.WarnContext(ctx, "ListUsers: requested page is out of bounds",
-			"requested_page", page, "total_pages", totalPages, "total_items", totalItems)
+	userResponses := make([]dto.UserResponse, len(usersModels))
+	for i, u := range usersModels {
+		userResponses[i] = toUserResponse(ctx, &u)
 	}
 
-	response := dto.PaginatedUsersResponse{
-		Users:       userResponses,
-		TotalItems:  totalItems,
-		TotalPages:  totalPages,
-		CurrentPage: page,
-		PageSize:    pageSize,
+	nextCursor := ""
+	if hasMore && len(usersModels) > 0 {
+		last := usersModels[len(usersModels)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID.String())
 	}
 
-	slog.InfoContext(ctx, "ListUsers: successfully listed users", "count_in_page", len(userResponses), "total_items", totalItems, "current_page", page)
+	response := newCursorPaginatedResponse("users", userResponses, serviceParams.PageSize, nextCursor)
+	slog.InfoContext(ctx, "ListUsers: successfully listed users after cursor", "count_in_page", len(userResponses), "has_more", hasMore)
 	respondWithJSON(w, http.StatusOK, response)
 }
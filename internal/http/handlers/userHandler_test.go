@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/http/handlers/dto"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services"
+	serviceDTO "bitback/internal/services/dto"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newTestUserHandler returns a UserHandler on svc with the default configuration.
+func newTestUserHandler(svc *fakeUserService) *UserHandler {
+	return NewUserHandler(svc, &config.Config{DefaultPageSize: 10})
+}
+
+func TestGetUserByTelegramID(t *testing.T) {
+	user := models.User{ID: uuid.New(), Name: "Telegram User", TelegramID: 123456789, IsActive: true, Role: customTypes.RoleUser}
+
+	tests := []struct {
+		name       string
+		telegramID string
+		role       customTypes.UserRole
+		wantStatus int
+		wantLookup int64
+	}{
+		{name: "found", telegramID: "123456789", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantLookup: 123456789},
+		{name: "not found", telegramID: "987654321", role: customTypes.RoleAdmin, wantStatus: http.StatusNotFound, wantLookup: 987654321},
+		{name: "not a number", telegramID: "abc", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "overflows int64", telegramID: "99999999999999999999", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", telegramID: "123456789", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookedUp int64
+			svc := &fakeUserService{
+				getUserByTelegramID: func(_ context.Context, telegramID int64) (*models.User, error) {
+					lookedUp = telegramID
+					if telegramID != user.TelegramID {
+						return nil, fmt.Errorf("user with Telegram ID %d not found: %w", telegramID, services.ErrNotFound)
+					}
+					return &user, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/users/by-telegram/"+tt.telegramID, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestUserHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if lookedUp != tt.wantLookup {
+				t.Errorf("service looked up Telegram ID %d, want %d", lookedUp, tt.wantLookup)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got := decodeJSON[dto.UserResponse](t, rec)
+			if got.ID != user.ID || got.TelegramID != user.TelegramID || got.Name != user.Name {
+				t.Errorf("response = %+v, want user %s with Telegram ID %d", got, user.ID, user.TelegramID)
+			}
+		})
+	}
+}
+
+func TestListUsersByHostCountry(t *testing.T) {
+	users := []models.User{{ID: uuid.New(), Name: "Berlin User", IsActive: true}, {ID: uuid.New(), Name: "Munich User", IsActive: true}}
+
+	type call struct {
+		country        string
+		page, pageSize int
+	}
+	tests := []struct {
+		name       string
+		role       customTypes.UserRole
+		query      string
+		wantStatus int
+		wantCall   *call
+	}{
+		{name: "first page", role: customTypes.RoleAdmin, query: "?country=DE", wantStatus: http.StatusOK, wantCall: &call{"DE", 1, 10}},
+		{name: "paged", role: customTypes.RoleAdmin, query: "?country=de&page=2&pageSize=1", wantStatus: http.StatusOK, wantCall: &call{"de", 2, 1}},
+		{name: "country required", role: customTypes.RoleAdmin, query: "?country=+", wantStatus: http.StatusBadRequest},
+		{name: "not an admin", role: customTypes.RoleUser, query: "?country=DE", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *call
+			svc := &fakeUserService{
+				listByHostCountry: func(_ context.Context, country string, page, pageSize int) ([]models.User, int64, error) {
+					got = &call{country, page, pageSize}
+					return users, 5, nil
+				},
+			}
+
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/reports/users-by-host-country"+tt.query, nil), uuid.New(), tt.role)
+			rec := serveRoutes(newTestUserHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if (got == nil) != (tt.wantCall == nil) || (got != nil && *got != *tt.wantCall) {
+				t.Fatalf("service called with %+v, want %+v", got, tt.wantCall)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body := decodeJSON[struct {
+				Users []dto.UserResponse `json:"users"`
+				dto.Pagination
+			}](t, rec)
+			if len(body.Users) != len(users) || body.Users[0].ID != users[0].ID || body.TotalItems != 5 || body.CurrentPage != tt.wantCall.page {
+				t.Errorf("response = %+v, want the service's users on page %d of 5 items", body, tt.wantCall.page)
+			}
+		})
+	}
+}
+
+func TestListUsersIncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	deletedID := uuid.New()
+
+	tests := []struct {
+		name       string
+		query      string
+		role       customTypes.UserRole
+		wantStatus int
+		wantFlag   bool
+	}{
+		{name: "excluded by default", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "admin includes deleted", query: "?include_deleted=true", role: customTypes.RoleAdmin, wantStatus: http.StatusOK, wantFlag: true},
+		{name: "admin excludes deleted", query: "?include_deleted=false", role: customTypes.RoleAdmin, wantStatus: http.StatusOK},
+		{name: "invalid flag", query: "?include_deleted=1x", role: customTypes.RoleAdmin, wantStatus: http.StatusBadRequest},
+		{name: "users cannot list users", query: "?include_deleted=true", role: customTypes.RoleUser, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *serviceDTO.ListUsersServiceParams
+			svc := &fakeUserService{
+				listUsers: func(_ context.Context, params serviceDTO.ListUsersServiceParams) ([]models.User, int64, error) {
+					got = &params
+					users := []models.User{{ID: uuid.New()}}
+					if params.IncludeDeleted {
+						users = append(users, models.User{ID: deletedID, DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true}})
+					}
+					return users, int64(len(users)), nil
+				},
+			}
+			req := asPrincipal(httptest.NewRequest(http.MethodGet, "/v1/users"+tt.query, nil), uuid.New(), tt.role)
+
+			rec := serveRoutes(newTestUserHandler(svc).RegisterRoutes, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got.IncludeDeleted != tt.wantFlag {
+				t.Errorf("service called with IncludeDeleted = %t, want %t", got.IncludeDeleted, tt.wantFlag)
+			}
+			body := decodeJSON[struct {
+				Users []dto.UserResponse `json:"users"`
+			}](t, rec)
+			var deleted []uuid.UUID
+			for _, user := range body.Users {
+				if user.DeletedAt != nil {
+					if !user.DeletedAt.Equal(deletedAt) {
+						t.Errorf("user %s deleted_at = %v, want %v", user.ID, user.DeletedAt, deletedAt)
+					}
+					deleted = append(deleted, user.ID)
+				}
+			}
+			if (len(deleted) == 1 && deleted[0] == deletedID) != tt.wantFlag || len(body.Users) != len(deleted)+1 {
+				t.Errorf("response lists users %+v with deleted %v, want the deleted user only when included", body.Users, deleted)
+			}
+		})
+	}
+}
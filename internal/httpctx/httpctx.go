@@ -0,0 +1,73 @@
+// Package httpctx defines the request-scoped values stored in a request's context and typed accessors for them.
+// Every value has its own unexported key type, so values cannot collide with each other or with other packages.
+package httpctx
+
+import (
+	"bitback/internal/models/customTypes"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type (
+	userIDKey    struct{}
+	roleKey      struct{}
+	requestIDKey struct{}
+	principalKey struct{}
+)
+
+// Principal is the authenticated user on whose behalf a request is made.
+type Principal struct {
+	UserID uuid.UUID            // ID of the authenticated user.
+	Role   customTypes.UserRole // Role of the authenticated user at the time of the request.
+}
+
+// WithUserID returns a copy of ctx carrying the authenticated user's ID.
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the authenticated user's ID stored in ctx. ok is false if there is none.
+func UserID(ctx context.Context) (userID uuid.UUID, ok bool) {
+	userID, ok = ctx.Value(userIDKey{}).(uuid.UUID)
+	return userID, ok && userID != uuid.Nil
+}
+
+// WithRole returns a copy of ctx carrying the authenticated user's role.
+func WithRole(ctx context.Context, role customTypes.UserRole) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// Role returns the authenticated user's role stored in ctx. ok is false if there is none.
+func Role(ctx context.Context) (role customTypes.UserRole, ok bool) {
+	role, ok = ctx.Value(roleKey{}).(customTypes.UserRole)
+	return role, ok
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, or an empty string if there is none.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal, as well as its user ID and role,
+// so that they can also be read with UserID and Role.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, principal)
+	ctx = WithUserID(ctx, principal.UserID)
+	return WithRole(ctx, principal.Role)
+}
+
+// PrincipalFromContext returns the principal stored in ctx. ok is false if the request is unauthenticated.
+func PrincipalFromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
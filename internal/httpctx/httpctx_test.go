@@ -0,0 +1,126 @@
+package httpctx
+
+import (
+	"bitback/internal/models/customTypes"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRoundTrip(t *testing.T) {
+	userID := uuid.New()
+	principal := Principal{UserID: uuid.New(), Role: customTypes.RoleAdmin}
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		wantUserID    uuid.UUID
+		wantRole      customTypes.UserRole
+		wantRequestID string
+		wantPrincipal *Principal
+	}{
+		{name: "empty context"},
+		{name: "user ID", ctx: WithUserID(context.Background(), userID), wantUserID: userID},
+		{name: "nil user ID is not authenticated", ctx: WithUserID(context.Background(), uuid.Nil)},
+		{name: "role", ctx: WithRole(context.Background(), customTypes.RoleUser), wantRole: customTypes.RoleUser},
+		{name: "request ID", ctx: WithRequestID(context.Background(), "req-1"), wantRequestID: "req-1"},
+		{
+			name:          "principal sets user ID and role",
+			ctx:           WithPrincipal(context.Background(), principal),
+			wantUserID:    principal.UserID,
+			wantRole:      principal.Role,
+			wantPrincipal: &principal,
+		},
+		{
+			name:          "values are kept side by side",
+			ctx:           WithRequestID(WithPrincipal(context.Background(), principal), "req-2"),
+			wantUserID:    principal.UserID,
+			wantRole:      principal.Role,
+			wantRequestID: "req-2",
+			wantPrincipal: &principal,
+		},
+		{
+			name:       "inner values shadow outer ones",
+			ctx:        WithUserID(WithUserID(context.Background(), uuid.New()), userID),
+			wantUserID: userID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if got, ok := UserID(ctx); got != tt.wantUserID || ok != (tt.wantUserID != uuid.Nil) {
+				t.Errorf("UserID() = %v, %t; want %v, %t", got, ok, tt.wantUserID, tt.wantUserID != uuid.Nil)
+			}
+			if got, ok := Role(ctx); got != tt.wantRole || ok != (tt.wantRole != "") {
+				t.Errorf("Role() = %q, %t; want %q, %t", got, ok, tt.wantRole, tt.wantRole != "")
+			}
+			if got := RequestID(ctx); got != tt.wantRequestID {
+				t.Errorf("RequestID() = %q, want %q", got, tt.wantRequestID)
+			}
+			got, ok := PrincipalFromContext(ctx)
+			if ok != (tt.wantPrincipal != nil) || (ok && got != *tt.wantPrincipal) {
+				t.Errorf("PrincipalFromContext() = %+v, %t; want %+v", got, ok, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+// TestKeyIsolation checks that values stored under other keys, including bare string keys
+// named like ours, are not mistaken for the values of this package.
+func TestKeyIsolation(t *testing.T) {
+	tests := []struct {
+		name string
+		key  any
+		val  any
+	}{
+		{name: "string user ID key", key: "userID", val: uuid.New()},
+		{name: "string role key", key: "role", val: customTypes.RoleAdmin},
+		{name: "string request ID key", key: "requestID", val: "req-1"},
+		{name: "string principal key", key: "principal", val: Principal{UserID: uuid.New(), Role: customTypes.RoleAdmin}},
+		{name: "empty struct key of another type", key: struct{}{}, val: "req-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), tt.key, tt.val)
+
+			if got, ok := UserID(ctx); ok {
+				t.Errorf("UserID() = %v, want none", got)
+			}
+			if got, ok := Role(ctx); ok {
+				t.Errorf("Role() = %q, want none", got)
+			}
+			if got := RequestID(ctx); got != "" {
+				t.Errorf("RequestID() = %q, want none", got)
+			}
+			if got, ok := PrincipalFromContext(ctx); ok {
+				t.Errorf("PrincipalFromContext() = %+v, want none", got)
+			}
+		})
+	}
+
+	t.Run("setting one value leaves the others unset", func(t *testing.T) {
+		ctx := WithRequestID(context.Background(), uuid.NewString())
+		if got, ok := UserID(ctx); ok {
+			t.Errorf("UserID() = %v, want none", got)
+		}
+		ctx = WithUserID(context.Background(), uuid.New())
+		if got := RequestID(ctx); got != "" {
+			t.Errorf("RequestID() = %q, want none", got)
+		}
+		if got, ok := PrincipalFromContext(ctx); ok {
+			t.Errorf("PrincipalFromContext() = %+v, want none", got)
+		}
+	})
+}
+
+// TestRequestIDNilContext checks that RequestID tolerates a nil context, which log handlers may pass.
+func TestRequestIDNilContext(t *testing.T) {
+	if got := RequestID(nil); got != "" {
+		t.Errorf("RequestID(nil) = %q, want empty", got)
+	}
+}
@@ -1,12 +1,17 @@
 package interfaces
 
-import "gorm.io/gorm"
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
 
 // SQLDatabase defines the interface for SQL database operations.
 // It includes methods for health checking, graceful shutdown, and accessing the underlying GORM client.
 type SQLDatabase interface {
 	// Ping checks the connectivity to the database.
-	Ping()
+	// It returns an error if the database is unreachable.
+	Ping(ctx context.Context) error
 
 	// Shutdown gracefully closes the database connection and releases resources.
 	Shutdown()
@@ -14,4 +19,14 @@ type SQLDatabase interface {
 	// GetGormClient returns the underlying GORM database client instance.
 	// This allows services and repositories to perform database operations using GORM.
 	GetGormClient() *gorm.DB
+
+	Transactor
+}
+
+// Transactor runs a group of repository calls atomically.
+type Transactor interface {
+	// WithTx runs fn inside a transaction that is committed if fn returns nil and rolled back otherwise.
+	// Repository calls must use the context passed to fn to take part in the transaction.
+	// Calls nested in an existing transaction join it.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -0,0 +1,17 @@
+package interfaces
+
+import "context"
+
+// Event is a domain event that can be published to downstream systems.
+type Event interface {
+	// EventName returns the stable name under which the event is published (e.g., "subscription.created").
+	EventName() string
+}
+
+// EventPublisher defines the interface for publishing domain events.
+// Implementations may deliver events in-process or forward them to a message broker such as NATS or Pub/Sub.
+type EventPublisher interface {
+	// Publish delivers the event. Callers publish after the change has been persisted,
+	// so a failure to publish does not roll the change back.
+	Publish(ctx context.Context, event Event) error
+}
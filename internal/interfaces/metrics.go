@@ -0,0 +1,29 @@
+package interfaces
+
+import "time"
+
+// HTTPMetrics records metrics about handled HTTP requests.
+type HTTPMetrics interface {
+	// ObserveRequest records a handled request. route is the matched route pattern, not the raw URL.
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// KeyMetrics records metrics about generated keys.
+type KeyMetrics interface {
+	// KeyGenerated counts a key issued on a free-tier or paid host.
+	KeyGenerated(freeTier bool)
+}
+
+// HostCount is the number of hosts in a country with a given status and reachability.
+type HostCount struct {
+	Country string
+	Status  string
+	Online  bool
+	Count   int
+}
+
+// HostMetrics records metrics about the host fleet.
+type HostMetrics interface {
+	// SetHostCounts replaces the current host counts; combinations missing from counts are reset.
+	SetHostCounts(counts []HostCount)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimiter limits how often a client may make requests.
+// Implementations may keep their state in memory or in a shared store such as Redis.
+type RateLimiter interface {
+	// Allow consumes one request for the client identified by key.
+	// If the client has exceeded its limit, it returns false and how long the client should wait before retrying.
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
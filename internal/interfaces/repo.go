@@ -5,52 +5,127 @@ import (
 	"bitback/internal/models"
 	"bitback/internal/models/customTypes"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"time"
 )
 
+// Errors returned by UserRepository when a unique user attribute is already used by another non-deleted user.
+var (
+	ErrEmailTaken      = errors.New("email is already in use")
+	ErrTelegramIDTaken = errors.New("telegram ID is already in use")
+)
+
 // UserRepository defines methods for interacting with the user data storage.
 type UserRepository interface {
 	// Create persists a new user to the storage.
+	// Returns ErrEmailTaken if another non-deleted user has the same email, ignoring case,
+	// and ErrTelegramIDTaken if another non-deleted user has the same Telegram ID.
 	Create(ctx context.Context, user *models.User) error
 
 	// GetByID retrieves a user by their unique UUID.
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 
+	// GetByIDForUpdate retrieves a user by their unique UUID and locks the row until the surrounding
+	// transaction ends. It must be called with a context carrying a transaction.
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error)
+
 	// GetByIDs retrieves a list of users by their unique UUIDs.
 	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]models.User, error)
 
-	// GetByEmail retrieves a user by their email address.
+	// GetByEmail retrieves a non-deleted user by their email address, ignoring case.
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 
+	// GetByTelegramID retrieves a non-deleted user by their Telegram ID.
+	GetByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+
+	// ExistsDeletedByEmailOrTelegramID reports whether a soft-deleted user has the email (ignoring case) or the
+	// Telegram ID. Empty emails and zero Telegram IDs are not matched.
+	ExistsDeletedByEmailOrTelegramID(ctx context.Context, email string, telegramID int64) (bool, error)
+
 	// Update persists changes to an existing user in the storage.
+	// Returns ErrEmailTaken or ErrTelegramIDTaken if the new email or Telegram ID is used by another non-deleted user.
 	Update(ctx context.Context, user *models.User) error
 
 	// Delete performs a soft delete on a user identified by their UUID.
 	Delete(ctx context.Context, id uuid.UUID) error
 
-	// List retrieves a paginated list of users.
+	// UpdateLastLogin sets only the last login timestamp of a user, leaving other columns untouched.
+	// Returns gorm.ErrRecordNotFound if the user does not exist.
+	UpdateLastLogin(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	// ListInactiveSince retrieves a paginated list of users who have not logged in since the given time,
+	// including users who never logged in.
+	ListInactiveSince(ctx context.Context, since time.Time, offset, limit int) ([]models.User, int64, error)
+
+	// ListWithActiveKeyInCountry retrieves a paginated list of users holding an active key assignment
+	// on a host in the given country (case-insensitive).
+	ListWithActiveKeyInCountry(ctx context.Context, country string, offset, limit int) ([]models.User, int64, error)
+
+	// List retrieves a paginated list of users based on specified filter and sort parameters.
 	// It returns the list of users, the total count of users matching the criteria, and any error.
-	List(ctx context.Context, offset, limit int) ([]models.User, int64, error)
+	List(ctx context.Context, params customTypes.ListUsersParams) ([]models.User, int64, error)
+
+	// ListAfter retrieves up to params.Limit users matching the filters in params and following the cursor,
+	// newest first, using keyset pagination. A nil cursor starts at the newest user. Offset and sorting are ignored.
+	ListAfter(ctx context.Context, params customTypes.ListUsersParams, after *customTypes.UserListCursor) ([]models.User, error)
+
+	// GetByIDUnscoped retrieves a user by their unique UUID, including soft-deleted users.
+	GetByIDUnscoped(ctx context.Context, id uuid.UUID) (*models.User, error)
+
+	// Restore clears the deletion timestamp of a soft-deleted user and returns the restored user.
+	// Returns ErrEmailTaken or ErrTelegramIDTaken if an active user now holds the same email or Telegram ID.
+	Restore(ctx context.Context, id uuid.UUID) (*models.User, error)
+
+	// Purge permanently removes a user (soft-deleted or not) together with their subscriptions and key assignments.
+	// Returns gorm.ErrRecordNotFound if the user does not exist.
+	Purge(ctx context.Context, id uuid.UUID) error
+
+	// ListDeletedBefore retrieves up to limit users that were soft-deleted before the given time, oldest first.
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.User, error)
+	// CountSummary counts all users, the active ones and those created within the 7 and 30 days before now
+	// in a single query.
+	CountSummary(ctx context.Context, now time.Time) (*customTypes.UserCounts, error)
 }
 
 // SubscriptionRepository defines methods for interacting with the subscription data storage.
 type SubscriptionRepository interface {
-	// Create persists a new subscription to the storage.
-	Create(ctx context.Context, subscription *models.Subscription) error
+	// Create persists a new subscription to the storage and appends event to its history in the same transaction.
+	// The event's SubscriptionID is filled in by the repository.
+	Create(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error
 
 	// GetByID retrieves a subscription by its unique UUID.
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
 
-	// Update persists changes to an existing subscription in the storage.
-	Update(ctx context.Context, subscription *models.Subscription) error
+	// GetByIDForUpdate retrieves a subscription by its UUID and locks it until the surrounding transaction ends.
+	// It must be called within a transaction.
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
 
-	// Delete performs a soft delete on a subscription identified by its ID.
-	Delete(ctx context.Context, id uuid.UUID) error
+	// GetByExternalPaymentID retrieves the subscription paid for by the payment provider's payment externalPaymentID.
+	GetByExternalPaymentID(ctx context.Context, externalPaymentID string) (*models.Subscription, error)
+
+	// GetByUserIDAndIdempotencyKey retrieves a user's subscription created with the given idempotency key.
+	GetByUserIDAndIdempotencyKey(ctx context.Context, userID uuid.UUID, idempotencyKey string) (*models.Subscription, error)
+
+	// CreateWithPromoCode atomically persists a new subscription, records a redemption of the promo code and
+	// appends event to the subscription's history.
+	// Returns gorm.ErrRecordNotFound if the promo code is no longer redeemable (e.g., it reached its maximum number of uses).
+	CreateWithPromoCode(ctx context.Context, subscription *models.Subscription, promoCodeID uint, event *models.SubscriptionEvent) error
+
+	// Update persists changes to an existing subscription and appends event to its history in the same transaction.
+	Update(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error
+
+	// Delete performs a soft delete on a subscription identified by its ID and appends event to its history
+	// in the same transaction.
+	Delete(ctx context.Context, id uuid.UUID, event *models.SubscriptionEvent) error
+
+	// ListEvents retrieves a paginated list of a subscription's history events, oldest first.
+	// It returns the list of events, the total count, and any error.
+	ListEvents(ctx context.Context, subscriptionID uuid.UUID, offset, limit int) (events []models.SubscriptionEvent, totalCount int64, err error)
 
 	// ListByUserID retrieves a paginated list of subscriptions for a specific user.
 	// It returns the list of subscriptions, the total count, and any error.
-	ListByUserID(ctx context.Context, userID uuid.UUID, offset, limit int) (subscriptions []models.Subscription, totalCount int64, err error)
+	ListByUserID(ctx context.Context, userID uuid.UUID, params customTypes.ListUserSubscriptionsParams) (subscriptions []models.Subscription, totalCount int64, err error)
 
 	// ListExpiringSoon retrieves a paginated list of active subscriptions that are due to expire within a given time window.
 	// It returns the list of subscriptions, the total count, and any error.
@@ -60,16 +135,128 @@ type SubscriptionRepository interface {
 	// It returns the list of subscriptions, the total count, and any error.
 	ListActiveByPlanName(ctx context.Context, planName string, offset, limit int) (subscriptions []models.Subscription, totalCount int64, err error)
 
+	// List retrieves a paginated list of subscriptions across all users, optionally filtered.
+	// It returns the list of subscriptions, the total count matching the filters, and any error.
+	List(ctx context.Context, offset, limit int, filters customTypes.ListSubscriptionsFilters) (subscriptions []models.Subscription, totalCount int64, err error)
+
 	// CheckUserActiveSubscription checks if a user has any active subscription.
 	// Returns true if an active subscription is found, false otherwise.
 	CheckUserActiveSubscription(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	// ListActivePlanNames retrieves the distinct plan names of the user's active subscriptions, ordered by name.
+	ListActivePlanNames(ctx context.Context, userID uuid.UUID) ([]string, error)
+
+	// GetActiveByUserID retrieves the user's active subscription that ends last.
+	// Returns gorm.ErrRecordNotFound if the user has no active subscription.
+	GetActiveByUserID(ctx context.Context, userID uuid.UUID) (*models.Subscription, error)
+
+	// CheckUserActivePaidSubscription checks if a user has any active subscription with a "paid" payment status.
+	CheckUserActivePaidSubscription(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	// ListRenewalCandidates retrieves up to limit active, paid, auto-renewing subscriptions that end within the given
+	// time window.
+	ListRenewalCandidates(ctx context.Context, thresholdDateFrom time.Time, thresholdDateTo time.Time, limit int) ([]models.Subscription, error)
+
+	// ListActivationCandidates retrieves up to limit paid, inactive subscriptions whose term contains now,
+	// i.e. future-dated subscriptions whose start date has arrived.
+	ListActivationCandidates(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error)
+
+	// ExtendForRenewal atomically moves the subscription's end date to newEndDate, sets its payment status to
+	// pending, clears the payment of the previous period so the renewal can be paid, records last_renewed_at and
+	// appends event to its history; subscription is updated accordingly.
+	// Returns gorm.ErrRecordNotFound if the subscription no longer exists or is no longer a renewal candidate
+	// with the same end date, e.g. because another run already extended it.
+	ExtendForRenewal(ctx context.Context, subscription *models.Subscription, newEndDate time.Time, event *models.SubscriptionEvent) error
+
+	// ListUnpaidRenewals retrieves up to limit active subscriptions whose renewal, made at or before renewedBefore,
+	// still awaits payment, oldest renewal first.
+	ListUnpaidRenewals(ctx context.Context, renewedBefore time.Time, limit int) ([]models.Subscription, error)
+
+	// DeactivateUnpaidRenewal atomically deactivates a subscription whose renewal still awaits payment and appends
+	// event to its history; subscription is updated accordingly. Returns gorm.ErrRecordNotFound if the subscription
+	// no longer exists or is no longer an unpaid renewal, e.g. because the payment arrived in the meantime.
+	DeactivateUnpaidRenewal(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error
+
+	// AddUsage adds usage.Quantity to the usage recorded for the subscription, metric and period start of usage,
+	// creating the record if needed. usage is populated with the resulting record.
+	AddUsage(ctx context.Context, usage *models.SubscriptionUsage) error
+
+	// ListUsage retrieves the usage recorded for a subscription, ordered by period and metric.
+	ListUsage(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionUsage, error)
+
+	// GetChurnCounts aggregates paid subscriptions active at the start of the period and those ending
+	// within [from, to), split by whether they were renewed, expired or cancelled.
+	GetChurnCounts(ctx context.Context, from, to time.Time) (*customTypes.SubscriptionChurnCounts, error)
+
+	// GetCurrencyTotals aggregates subscriptions created within [from, to) per currency, ordered by currency.
+	// Subscriptions with a NULL or empty currency form their own group with an empty Currency.
+	GetCurrencyTotals(ctx context.Context, from, to time.Time) ([]customTypes.SubscriptionCurrencyTotals, error)
+	// CountUsersWithActivePaidSubscription counts the distinct users with an active, paid subscription whose term
+	// contains now.
+	CountUsersWithActivePaidSubscription(ctx context.Context, now time.Time) (int64, error)
+
+	// CountByPaymentStatus counts subscriptions per payment status, ordered by payment status.
+	CountByPaymentStatus(ctx context.Context) ([]customTypes.PaymentStatusCount, error)
+
+	// CountActiveByPlan counts active subscriptions whose term contains now per plan name, largest first.
+	CountActiveByPlan(ctx context.Context, now time.Time) ([]customTypes.PlanSubscriptionCount, error)
 }
 
+// KeyGenerationRepository defines methods for interacting with the key generation log storage.
+type KeyGenerationRepository interface {
+	// Create persists a new key generation log entry to the storage.
+	Create(ctx context.Context, generation *models.KeyGeneration) error
+
+	// CountByBucket counts the free and user keys generated in [from, to) per bucket of the given width.
+	// Buckets are numbered from 0 starting at from and ordered by number; empty buckets are omitted.
+	CountByBucket(ctx context.Context, from, to time.Time, bucket time.Duration) ([]customTypes.KeyGenerationBucketCounts, error)
+
+	// CountConversionFunnel counts the users issued a free-tier key in [from, to) and how many of them created a
+	// subscription, and a paid one, after their first free-tier key in that period.
+	CountConversionFunnel(ctx context.Context, from, to time.Time) (*customTypes.ConversionFunnelCounts, error)
+	// CountSince counts the keys generated at or after since.
+	CountSince(ctx context.Context, since time.Time) (int64, error)
+}
+
+// KeyAssignmentRepository defines methods for interacting with the key assignment data storage.
+type KeyAssignmentRepository interface {
+	// Create persists a new key assignment to the storage and counts it towards its host's current users.
+	Create(ctx context.Context, assignment *models.KeyAssignment) error
+
+	// Reassign atomically deactivates all active key assignments of the assignment's user and creates the new one,
+	// updating the current users of every affected host. It returns the deactivated assignments.
+	Reassign(ctx context.Context, assignment *models.KeyAssignment) (previous []models.KeyAssignment, err error)
+
+	// GetLatestActiveByUserID retrieves the most recent active key assignment for a user, including its host.
+	GetLatestActiveByUserID(ctx context.Context, userID uuid.UUID) (*models.KeyAssignment, error)
+}
+
+// HostCheckRepository defines methods for interacting with the host health check history storage.
+type HostCheckRepository interface {
+	// Create persists a new host check to the storage.
+	Create(ctx context.Context, check *models.HostCheck) error
+
+	// ListByHostID retrieves a paginated list of checks for a host, newest first.
+	// It returns the list of checks, the total count, and any error.
+	ListByHostID(ctx context.Context, hostID uint, offset, limit int) (checks []models.HostCheck, totalCount int64, err error)
+
+	// CountSince counts the checks recorded for a host since the given time.
+	// It returns the total number of checks and the number of checks where the host was online.
+	CountSince(ctx context.Context, hostID uint, since time.Time) (total int64, online int64, err error)
+}
+
+// ErrHostNameTaken is returned by HostRepository when host-name uniqueness is enforced
+// and another non-deleted host already uses the name, ignoring case.
+var ErrHostNameTaken = errors.New("host name is already in use")
+
 // HostRepository defines methods for interacting with the host data storage.
 type HostRepository interface {
 	// Create persists a new host to the storage.
 	Create(ctx context.Context, host *models.Host) error
 
+	// CreateBatch persists multiple new hosts to the storage in a single transaction.
+	CreateBatch(ctx context.Context, hosts []*models.Host) error
+
 	// GetByID retrieves a host by its unique ID.
 	GetByID(ctx context.Context, id uint) (*models.Host, error)
 
@@ -77,19 +264,96 @@ type HostRepository interface {
 	// This is often used to check for uniqueness.
 	GetByAddressPortProtocolNetwork(ctx context.Context, address, port, protocol, network string) (*models.Host, error)
 
-	// GetRandomActiveHost retrieves a random, active host from the storage,
-	// optionally filtering by country and whether it's a free tier host.
-	// If isFreeTier is nil, it doesn't filter by free status.
-	// If country is nil or empty, it doesn't filter by country.
-	GetRandomActiveHost(ctx context.Context, country *string, isFreeTier *bool) (*models.Host, error)
+	// GetByHostName retrieves a non-deleted host by its name, ignoring case.
+	GetByHostName(ctx context.Context, hostName string) (*models.Host, error)
+
+	// ListAll retrieves every non-deleted host, ordered by ID.
+	ListAll(ctx context.Context) ([]models.Host, error)
+
+	// AcquireLeastIssuedHost selects the online, active host with the fewest issued keys,
+	// optionally filtering by country, tier, network and security type, and atomically records a new issuance on it.
+	// Ties are broken by the least recent issuance and then randomly.
+	AcquireLeastIssuedHost(ctx context.Context, filter customTypes.HostSelectionFilter) (*models.Host, error)
 
-	// Update persists changes to an existing host in the storage.
-	Update(ctx context.Context, host *models.Host) error
+	// AcquireLeastIssuedHostExcluding behaves like AcquireLeastIssuedHost but never selects any of the excluded hosts.
+	AcquireLeastIssuedHostExcluding(ctx context.Context, filter customTypes.HostSelectionFilter, excludeHostIDs []uint) (*models.Host, error)
+
+	// ListSelectableHosts retrieves up to limit online hosts eligible for key issuance that match the filter,
+	// ordered by country and ID. Hosts with the 'active' status are returned if any match; otherwise any online host.
+	ListSelectableHosts(ctx context.Context, filter customTypes.HostSelectionFilter, limit int) ([]models.Host, error)
+
+	// ListCountryCandidates retrieves, in a single query, the selectable hosts of each distinct country matching the
+	// filter that are tied for the best rank: hosts with the 'active' status rank first, then the least issued ones.
+	// At most perCountry candidates are returned per country, for the first maxCountries countries, ordered by country
+	// and ID. If countries is not empty, only those countries are considered (case-insensitively).
+	ListCountryCandidates(ctx context.Context, filter customTypes.HostSelectionFilter, countries []string, perCountry, maxCountries int) ([]models.Host, error)
+
+	// Update persists the changed columns of an existing host, keyed by column name, leaving other columns untouched.
+	// An empty changes map is a no-op. Returns gorm.ErrRecordNotFound if the host does not exist.
+	Update(ctx context.Context, host *models.Host, changes map[string]any) error
 
 	// Delete performs a soft delete on a host identified by its ID.
 	Delete(ctx context.Context, id uint) error
 
+	// CountByProvider aggregates host counts per distinct provider, optionally restricted to a country (case-insensitive).
+	CountByProvider(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error)
+
+	// CountAvailableByCountry counts online hosts with the 'active' status per country, split by tier.
+	CountAvailableByCountry(ctx context.Context) ([]customTypes.CountryHostAvailability, error)
+
+	// AggregateAvailability summarizes the public hosts that keys can be generated for per country,
+	// optionally restricted to a tier.
+	AggregateAvailability(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error)
+
+	// GetDeletedByID retrieves a soft-deleted host by its ID.
+	// Returns gorm.ErrRecordNotFound if no soft-deleted host has the ID.
+	GetDeletedByID(ctx context.Context, id uint) (*models.Host, error)
+
+	// Restore clears the deletion timestamp of a soft-deleted host and returns the restored host.
+	// Returns gorm.ErrRecordNotFound if no soft-deleted host has the ID.
+	Restore(ctx context.Context, id uint) (*models.Host, error)
+
 	// List retrieves a list of hosts based on specified filter parameters, with pagination.
 	// It returns the list of hosts, the total count matching the criteria, and any error.
 	List(ctx context.Context, params customTypes.ListHostsParams) (hosts []models.Host, totalCount int64, err error)
+
+	// ListAfter retrieves up to params.Limit hosts matching the filters in params and following the cursor,
+	// newest first, using keyset pagination. A nil cursor starts at the newest host. Offset and sorting are ignored.
+	ListAfter(ctx context.Context, params customTypes.ListHostsParams, after *customTypes.HostListCursor) ([]models.Host, error)
+	// CountByStatus counts hosts per status, ordered by status.
+	CountByStatus(ctx context.Context) ([]customTypes.HostStatusCount, error)
+
+	// CountByCountry counts hosts per country, largest first, then by country code.
+	CountByCountry(ctx context.Context) ([]customTypes.CountryHostCount, error)
+}
+
+// PlanRepository defines methods for interacting with the subscription plan catalog storage.
+type PlanRepository interface {
+	// Create persists a new plan to the storage.
+	Create(ctx context.Context, plan *models.Plan) error
+
+	// GetByID retrieves a plan by its unique ID.
+	GetByID(ctx context.Context, id uint) (*models.Plan, error)
+
+	// GetByName retrieves a plan by its unique name.
+	GetByName(ctx context.Context, name string) (*models.Plan, error)
+
+	// Update persists changes to an existing plan in the storage.
+	Update(ctx context.Context, plan *models.Plan) error
+
+	// Delete performs a soft delete on a plan identified by its ID.
+	Delete(ctx context.Context, id uint) error
+
+	// List retrieves a paginated list of plans, optionally restricted to active plans.
+	// It returns the list of plans, the total count matching the criteria, and any error.
+	List(ctx context.Context, offset, limit int, activeOnly bool) (plans []models.Plan, totalCount int64, err error)
+}
+
+// PromoCodeRepository defines methods for interacting with the promo code storage.
+type PromoCodeRepository interface {
+	// Create persists a new promo code to the storage.
+	Create(ctx context.Context, promoCode *models.PromoCode) error
+
+	// GetByCode retrieves a promo code by its (upper-case) code.
+	GetByCode(ctx context.Context, code string) (*models.PromoCode, error)
 }
@@ -2,75 +2,203 @@ package interfaces
 
 import (
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	serviceDTO "bitback/internal/services/dto"
 	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 // KeyService defines methods for managing and generating keys.
 type KeyService interface {
-	// GenerateVlessKeyForUser creates a VLESS key string for a specified user,
-	// optionally including remarks for identification and filtering by country.
-	// Returns the key and whether the user has an active subscription.
-	GenerateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, country *string) (*serviceDTO.GenerateUserKeyResult, error)
-
-	// GenerateFreeVlessKey creates a VLESS key string using a free-tier host,
-	// optionally including remarks and filtering by country.
-	GenerateFreeVlessKey(ctx context.Context, remarks string, country *string) (string, error)
+	// GenerateVlessKeyForUser creates a VLESS key string for a specified user, optionally including remarks
+	// for identification, on a host of the user's tier matching the preferences as closely as possible.
+	// Preferences are relaxed in this order: the exact preferences, then the preferred country with any transport,
+	// then the preferred transport in any country. Without a transport preference, the preferred country is followed
+	// by any country. With an address family preference, these steps are first tried on hosts of that family only
+	// and then on any host. If allowFreeFallback is set, a subscribed user is then served a free host in the same order.
+	// Returns the key, its decoded components, the host used, whether the user has an active subscription, and the tier served.
+	GenerateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateUserKeyResult, error)
+
+	// GenerateTrojanKeyForUser creates a trojan key string for a specified user on a host of the user's tier whose
+	// protocol is trojan. Host preferences and the free fallback work as for GenerateVlessKeyForUser. The password in
+	// the key is derived from the user ID and the configured trojan password secret.
+	GenerateTrojanKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs serviceDTO.HostPreferences, allowFreeFallback bool) (*serviceDTO.GenerateTrojanKeyResult, error)
+
+	// GenerateVlessKeysForUser creates one VLESS key for each country with an online host of the user's tier,
+	// restricted to the given countries if any. The number of keys is capped by configuration.
+	GenerateVlessKeysForUser(ctx context.Context, userID uuid.UUID, remarks string, countries []string) (*serviceDTO.MultiCountryKeysResult, error)
+
+	// GetSubscriptionFeed builds the keys of a user's subscription feed: one VLESS key for every online host
+	// eligible for the user's tier. Users without an active subscription get free-tier hosts only.
+	GetSubscriptionFeed(ctx context.Context, userID uuid.UUID, remarks string) (*serviceDTO.SubscriptionFeed, error)
+
+	// GetKeyGenerationRate counts the free and user keys generated over the given window ending now,
+	// split into buckets of the given width.
+	GetKeyGenerationRate(ctx context.Context, window, bucket time.Duration) (*serviceDTO.KeyGenerationRate, error)
+
+	// GetConversionFunnel reports how many users issued a free-tier key in [from, to) subscribed and paid afterwards.
+	GetConversionFunnel(ctx context.Context, from, to time.Time) (*serviceDTO.ConversionFunnel, error)
+
+	// GenerateFreeVlessKey creates a VLESS key string using a free-tier host, optionally including remarks.
+	// The host preferences are relaxed in the same order as for GenerateVlessKeyForUser.
+	GenerateFreeVlessKey(ctx context.Context, remarks string, prefs serviceDTO.HostPreferences) (*serviceDTO.FreeKeyResult, error)
+
+	// GenerateFreeVlessKeys creates count free-tier VLESS keys, spread across free hosts,
+	// for seeding and load testing. The count is capped by configuration.
+	GenerateFreeVlessKeys(ctx context.Context, count int, remarks string, country *string) ([]serviceDTO.FreeKeyResult, error)
+
+	// GetCurrentVlessKeyForUser reconstructs the VLESS key from the user's most recent active key assignment
+	// without selecting a new host.
+	GetCurrentVlessKeyForUser(ctx context.Context, userID uuid.UUID) (*serviceDTO.CurrentUserKeyResult, error)
+
+	// ReassignUserHost revokes the user's active key assignments and issues a new key on the target host,
+	// or on an automatically selected host of the user's tier if no target is given.
+	ReassignUserHost(ctx context.Context, userID uuid.UUID, input serviceDTO.ReassignHostInput) (*serviceDTO.ReassignHostResult, error)
 }
 
 // UserService defines the business logic methods for user management.
 type UserService interface {
-	// RegisterUser creates a new user account.
-	RegisterUser(ctx context.Context, input serviceDTO.CreateUserInput) (*models.User, error)
+	// RegisterUser creates a new user account. If trials are enabled, it also creates a trial subscription for
+	// users who never registered before and returns it; otherwise the returned subscription is nil.
+	RegisterUser(ctx context.Context, input serviceDTO.CreateUserInput) (*models.User, *models.Subscription, error)
 
 	// GetUser retrieves a user by their unique ID.
 	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
 
+	// GetUserByTelegramID retrieves a user by their Telegram ID.
+	GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+
+	// RecordLogin sets the user's last login time to now.
+	RecordLogin(ctx context.Context, userID uuid.UUID) error
+
+	// ListInactiveUsers retrieves a paginated list of users who have not logged in for at least inactiveDays days,
+	// including users who never logged in.
+	ListInactiveUsers(ctx context.Context, inactiveDays, page, pageSize int) (users []models.User, totalCount int64, err error)
+
+	// ListUsersByHostCountry retrieves a paginated list of users whose active key is assigned to a host
+	// in the given country, e.g. to notify them of a regional outage.
+	ListUsersByHostCountry(ctx context.Context, country string, page, pageSize int) (users []models.User, totalCount int64, err error)
+
 	// UpdateUser modifies an existing user's information.
 	UpdateUser(ctx context.Context, id uuid.UUID, input serviceDTO.UpdateUserInput) (*models.User, error)
 
 	// DeleteUser performs a soft delete on a user.
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 
-	// ListUsers retrieves a paginated list of users.
-	// It returns the slice of users, the total count of users, and any error encountered.
-	ListUsers(ctx context.Context, page, pageSize int) (users []models.User, totalCount int64, err error)
+	// PurgeUser permanently removes a user, including soft-deleted ones, and all of their subscriptions.
+	// It refuses to purge a user with an active, paid subscription unless force is true.
+	PurgeUser(ctx context.Context, id uuid.UUID, force bool) error
+
+	// RestoreUser brings back a soft-deleted user, provided no active user has since taken
+	// their email or Telegram ID.
+	RestoreUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+
+	// PurgeDeletedUsers permanently removes users that were soft-deleted longer ago than the configured retention period.
+	// Users with an active, paid subscription are skipped. It is intended to be run periodically by a background worker.
+	PurgeDeletedUsers(ctx context.Context) error
+
+	// UpdateUserRole changes the role of a user.
+	// Only users with the admin role (identified by requestingUserID) are allowed to perform this operation.
+	UpdateUserRole(ctx context.Context, requestingUserID uuid.UUID, userID uuid.UUID, role customTypes.UserRole) (*models.User, error)
+
+	// ListUsers retrieves a paginated, filtered and sorted list of users.
+	// It returns the slice of users, the total count of users matching the filters, and any error encountered.
+	ListUsers(ctx context.Context, params serviceDTO.ListUsersServiceParams) (users []models.User, totalCount int64, err error)
+
+	// ListUsersAfter retrieves a page of filtered users following the cursor, newest first, using keyset pagination.
+	// A nil cursor returns the first page. It also reports whether more users follow the page.
+	ListUsersAfter(ctx context.Context, params serviceDTO.ListUsersServiceParams, after *customTypes.UserListCursor) (users []models.User, hasMore bool, err error)
 }
 
 // SubscriptionService defines the business logic methods for managing user subscriptions.
 type SubscriptionService interface {
 	// CreateSubscription establishes a new subscription for a user based on the provided input.
-	CreateSubscription(ctx context.Context, input serviceDTO.CreateSubscriptionInput) (*models.Subscription, error)
+	// When input.IdempotencyKey matches an earlier request with the same payload, the existing subscription
+	// is returned and created is false.
+	CreateSubscription(ctx context.Context, input serviceDTO.CreateSubscriptionInput) (subscription *models.Subscription, created bool, err error)
 
 	// GetSubscriptionByID retrieves a specific subscription by its ID.
-	// The requestingUserID is used for authorization to ensure the user has rights to view it.
-	GetSubscriptionByID(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) (*models.Subscription, error)
+	// The requestingUserID and requestingUserRole are used for authorization: owners and admins may view it.
+	GetSubscriptionByID(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*models.Subscription, error)
 
 	// ListUserSubscriptions retrieves a paginated list of all subscriptions for a given user.
-	ListUserSubscriptions(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Subscription, int64, error)
+	ListUserSubscriptions(ctx context.Context, userID uuid.UUID, params serviceDTO.ListUserSubscriptionsParams) ([]models.Subscription, int64, error)
+
+	// ListAllUserSubscriptions retrieves every subscription of a user, ordered as ListUserSubscriptions.
+	// Only the user themselves or an administrator may list them.
+	ListAllUserSubscriptions(ctx context.Context, userID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) ([]models.Subscription, error)
 
 	// GetUsersWithExpiringSubscriptions generates a report of users whose subscriptions are nearing expiration.
 	// The report is paginated and includes details of the expiring subscriptions for each user.
 	// Returns a slice of UserWithExpiringSubscriptions, the total count of such users (or subscriptions, depending on pagination strategy), and any error.
 	GetUsersWithExpiringSubscriptions(ctx context.Context, daysInAdvance int, page, pageSize int) (reportData []serviceDTO.UserWithExpiringSubscriptions, totalCount int64, err error)
 
+	// GetChurnReport calculates how many subscriptions ended without renewal within [from, to)
+	// relative to the number of subscriptions active at the start of the period.
+	GetChurnReport(ctx context.Context, from, to time.Time) (*serviceDTO.ChurnReport, error)
+
+	// GetSubscriptionsByCurrency reports the subscriptions created within [from, to) and their revenue per currency.
+	GetSubscriptionsByCurrency(ctx context.Context, from, to time.Time) (*serviceDTO.SubscriptionsByCurrency, error)
+
 	// ListActiveSubscriptionsByPlan retrieves a paginated list of active subscriptions for a specific plan name.
 	ListActiveSubscriptionsByPlan(ctx context.Context, planName string, page, pageSize int) (subscriptions []models.Subscription, totalCount int64, err error)
 
 	// CancelSubscription cancels a subscription, which might involve disabling auto-renewal or deactivating it.
-	// The requestingUserID is used for authorization.
-	CancelSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) (*models.Subscription, error)
+	// The requestingUserID and requestingUserRole are used for authorization: owners and admins may cancel it.
+	CancelSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*models.Subscription, error)
 
 	// UpdatePaymentStatus updates the payment status of a specific subscription.
-	UpdatePaymentStatus(ctx context.Context, subscriptionID uuid.UUID, paymentStatus string) (*models.Subscription, error)
+	// actorUserID identifies the user making the change for the subscription's history; it is nil for unauthenticated callers.
+	UpdatePaymentStatus(ctx context.Context, subscriptionID uuid.UUID, paymentStatus string, actorUserID *uuid.UUID) (*models.Subscription, error)
+
+	// ApplyPayment marks a subscription as paid by a payment reported by the payment provider and activates it.
+	// It is idempotent on the external payment ID: replays return the subscription with applied set to false.
+	ApplyPayment(ctx context.Context, input serviceDTO.ApplyPaymentInput) (sub *models.Subscription, applied bool, err error)
+
+	// RecordUsage adds a usage increment to the billing period of a subscription that contains input.OccurredAt.
+	// The usage must fall within the subscription's term.
+	RecordUsage(ctx context.Context, subscriptionID uuid.UUID, input serviceDTO.RecordUsageInput) (*models.SubscriptionUsage, error)
+
+	// GetUsageReport summarizes a subscription's usage per billing period.
+	// Only the owner of the subscription or an administrator may view it.
+	GetUsageReport(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*serviceDTO.UsageReport, error)
+
+	// ListSubscriptionEvents retrieves a paginated list of a subscription's history, oldest first.
+	// Only the owner of the subscription or an administrator may view it.
+	ListSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, page, pageSize int) ([]models.SubscriptionEvent, int64, error)
+
+	// MarkExpiryNotified records that the owner of a subscription has been reminded of its expiry.
+	MarkExpiryNotified(ctx context.Context, subscriptionID uuid.UUID) (*models.Subscription, error)
 
 	// SetAutoRenew enables or disables the auto-renewal feature for a subscription.
-	// The requestingUserID is used for authorization.
-	SetAutoRenew(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, autoRenew bool) (*models.Subscription, error)
+	// The requestingUserID and requestingUserRole are used for authorization: owners and admins may change it.
+	SetAutoRenew(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, autoRenew bool) (*models.Subscription, error)
 
 	// CheckUserActiveSubscription checks if a user has any active subscription.
 	CheckUserActiveSubscription(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	// GetUserSubscriptionStatus reports whether a user has an active subscription and the plans that are active.
+	// Returns a not found error if the user does not exist.
+	GetUserSubscriptionStatus(ctx context.Context, userID uuid.UUID) (*serviceDTO.UserSubscriptionStatus, error)
+
+	// DeleteSubscription performs a soft delete on a subscription.
+	// Only users with the admin role (identified by requestingUserID) are allowed to perform this operation.
+	DeleteSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) error
+
+	// ListSubscriptions retrieves a paginated list of subscriptions across all users, optionally filtered.
+	ListSubscriptions(ctx context.Context, filters customTypes.ListSubscriptionsFilters, page, pageSize int) (subscriptions []models.Subscription, totalCount int64, err error)
+
+	// ProcessRenewals renews active, paid, auto-renewing subscriptions that are about to expire
+	// by extending them in place by another period of the same duration, awaiting payment.
+	// Renewals still unpaid after the payment grace period are deactivated.
+	ProcessRenewals(ctx context.Context) error
+
+	// ProcessActivations activates paid subscriptions whose start date has arrived.
+	ProcessActivations(ctx context.Context) error
+
+	// RunRenewals performs the same work as ProcessRenewals and returns a summary of the run.
+	RunRenewals(ctx context.Context) (*serviceDTO.RenewalRunSummary, error)
 }
 
 // HostService defines the business logic methods for managing hosts or servers.
@@ -78,6 +206,21 @@ type HostService interface {
 	// AddHost adds a new host to the system based on the provided input.
 	AddHost(ctx context.Context, input serviceDTO.CreateHostInput) (*models.Host, error)
 
+	// AddHosts creates multiple hosts at once in a single transaction.
+	// Every entry is validated and checked against existing hosts and earlier entries in the batch.
+	// If atomic is true, nothing is created unless all entries are valid; otherwise invalid entries and duplicates
+	// are skipped and the remaining hosts are created. The result reports the outcome of every entry.
+	AddHosts(ctx context.Context, inputs []serviceDTO.CreateHostInput, atomic bool) (*serviceDTO.BulkAddHostsResult, error)
+
+	// ExportHosts retrieves every non-deleted host with its full configuration, ordered by ID.
+	ExportHosts(ctx context.Context) ([]models.Host, error)
+
+	// ImportHosts restores hosts from a configuration bundle in a single transaction.
+	// Entries matching an existing host by address, port, protocol and network update its configuration;
+	// others create new hosts, so importing the same bundle twice changes nothing the second time.
+	// If any entry is invalid, nothing is imported and the result reports the invalid entries.
+	ImportHosts(ctx context.Context, inputs []serviceDTO.ImportHostInput) (*serviceDTO.ImportHostsResult, error)
+
 	// GetHostByID retrieves a host by its unique ID.
 	GetHostByID(ctx context.Context, hostID uint) (*models.Host, error)
 
@@ -87,10 +230,86 @@ type HostService interface {
 	// RemoveHost performs a soft delete on a host.
 	RemoveHost(ctx context.Context, hostID uint) error
 
+	// RestoreHost brings back a soft-deleted host, provided no active host has since taken its
+	// address, port, protocol and network combination (or its name, when names are unique).
+	RestoreHost(ctx context.Context, hostID uint) (*models.Host, error)
+
 	// ListHosts retrieves a paginated and filtered list of hosts.
 	// It returns the slice of hosts, the total count of hosts matching the criteria, and any error.
 	ListHosts(ctx context.Context, params serviceDTO.ListHostsServiceParams) (hosts []models.Host, totalCount int64, err error)
 
+	// ListHostsAfter retrieves a page of filtered hosts following the cursor, newest first, using keyset pagination.
+	// A nil cursor returns the first page. It also reports whether more hosts follow the page.
+	ListHostsAfter(ctx context.Context, params serviceDTO.ListHostsServiceParams, after *customTypes.HostListCursor) (hosts []models.Host, hasMore bool, err error)
+
 	// UpdateHostOnlineStatus updates the online status and other related metrics of a host.
 	UpdateHostOnlineStatus(ctx context.Context, hostID uint, input serviceDTO.UpdateHostStatusInput) (*models.Host, error)
+
+	// SetHostTier moves a host to the free or paid tier, writing only the tier column.
+	// Setting the tier the host already has is a no-op that returns the host unchanged.
+	SetHostTier(ctx context.Context, hostID uint, isFreeTier bool) (*models.Host, error)
+
+	// RecordHostCheck appends a health check result to the host's history and updates
+	// the host's online status, status and last checked time accordingly.
+	RecordHostCheck(ctx context.Context, hostID uint, result serviceDTO.HostCheckResult) (*models.HostCheck, error)
+
+	// ListHostChecks retrieves a paginated health check history for a host, newest first.
+	ListHostChecks(ctx context.Context, hostID uint, page, pageSize int) (checks []models.HostCheck, totalCount int64, err error)
+
+	// GetHostUptime computes the ratio of successful checks for a host over the given window ending now.
+	GetHostUptime(ctx context.Context, hostID uint, window time.Duration) (*serviceDTO.HostUptime, error)
+	// GetHostUtilization reports the host's current users against its capacity, along with its online status and latency.
+	GetHostUtilization(ctx context.Context, hostID uint) (*serviceDTO.HostUtilization, error)
+
+	// GetProvidersReport returns total, online and active host counts for each distinct provider,
+	// optionally restricted to a country.
+	GetProvidersReport(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error)
+
+	// GetAvailabilityReport returns the number of online, active free and paid hosts per country, with totals.
+	GetAvailabilityReport(ctx context.Context) (*serviceDTO.HostAvailabilityReport, error)
+
+	// GetHostAvailability returns the countries with public hosts available for key generation,
+	// optionally restricted to a tier. Results are cached for the configured TTL.
+	GetHostAvailability(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error)
+}
+
+// OnboardingService defines the interface for onboarding new users.
+type OnboardingService interface {
+	// Onboard atomically registers a user and creates their first subscription; if either fails, neither is saved.
+	Onboard(ctx context.Context, input serviceDTO.OnboardInput) (*serviceDTO.OnboardResult, error)
+}
+
+// ReportService defines the interface for reports that span several resources.
+type ReportService interface {
+	// GetOverview summarizes users, subscriptions, hosts and the keys generated today for the admin dashboard.
+	GetOverview(ctx context.Context) (*serviceDTO.Overview, error)
+}
+
+// PlanService defines the interface for managing the subscription plan catalog.
+type PlanService interface {
+	// CreatePlan adds a new plan to the catalog. Plan names must be unique.
+	CreatePlan(ctx context.Context, input serviceDTO.CreatePlanInput) (*models.Plan, error)
+
+	// GetPlan retrieves a plan by its ID.
+	GetPlan(ctx context.Context, id uint) (*models.Plan, error)
+
+	// UpdatePlan modifies an existing plan. Existing subscriptions are not affected.
+	UpdatePlan(ctx context.Context, id uint, input serviceDTO.UpdatePlanInput) (*models.Plan, error)
+
+	// DeletePlan performs a soft delete on a plan. Existing subscriptions are not affected.
+	DeletePlan(ctx context.Context, id uint) error
+
+	// ListPlans retrieves a paginated list of plans, optionally restricted to active plans.
+	// It returns the slice of plans, the total count, and any error encountered.
+	ListPlans(ctx context.Context, page, pageSize int, activeOnly bool) (plans []models.Plan, totalCount int64, err error)
+}
+
+// PromoCodeService defines the interface for managing promotional discount codes.
+type PromoCodeService interface {
+	// CreatePromoCode creates a new promo code. Codes are unique and case-insensitive.
+	CreatePromoCode(ctx context.Context, input serviceDTO.CreatePromoCodeInput) (*models.PromoCode, error)
+
+	// ValidatePromoCode retrieves a promo code and checks that it is active, within its validity window,
+	// and below its maximum number of uses.
+	ValidatePromoCode(ctx context.Context, code string) (*models.PromoCode, error)
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+import "context"
+
+// BackgroundWorker defines the interface for a long-running background job.
+// Workers are started together with the application and stopped during graceful shutdown.
+type BackgroundWorker interface {
+	// Start launches the worker in its own goroutine and returns immediately.
+	// The worker stops when the provided context is cancelled or Stop is called.
+	Start(ctx context.Context)
+
+	// Stop signals the worker to finish and waits until its current run completes.
+	Stop()
+}
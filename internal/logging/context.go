@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bitback/internal/httpctx"
+	"context"
+	"log/slog"
+)
+
+// requestIDAttr is the log attribute under which the request ID is recorded.
+const requestIDAttr = "request_id"
+
+// ContextHandler is a slog.Handler that adds request-scoped values from the context,
+// such as the request ID, to every record logged with a *Context logging call.
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps the given handler in a ContextHandler.
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: handler}
+}
+
+// Handle adds the request ID from ctx, if any, before delegating to the wrapped handler.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := httpctx.RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(requestIDAttr, requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a ContextHandler whose wrapped handler has the given attributes.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a ContextHandler whose wrapped handler uses the given group.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
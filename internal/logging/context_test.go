@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bitback/internal/httpctx"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		logger        func(*slog.Logger) *slog.Logger
+		wantRequestID string
+		wantAttrs     map[string]any
+	}{
+		{name: "request ID", ctx: httpctx.WithRequestID(context.Background(), "req-1"), wantRequestID: "req-1"},
+		{name: "no request ID", ctx: context.Background()},
+		{
+			name:          "attributes are kept",
+			ctx:           httpctx.WithRequestID(context.Background(), "req-2"),
+			logger:        func(l *slog.Logger) *slog.Logger { return l.With("component", "test") },
+			wantRequestID: "req-2",
+			wantAttrs:     map[string]any{"component": "test"},
+		},
+		{
+			name:          "groups are kept",
+			ctx:           httpctx.WithRequestID(context.Background(), "req-3"),
+			logger:        func(l *slog.Logger) *slog.Logger { return l.WithGroup("http") },
+			wantRequestID: "",
+			wantAttrs:     map[string]any{"http": map[string]any{"request_id": "req-3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+			if tt.logger != nil {
+				logger = tt.logger(logger)
+			}
+
+			logger.InfoContext(tt.ctx, "handled")
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("failed to decode log record %q: %v", buf.String(), err)
+			}
+			got, _ := record[requestIDAttr].(string)
+			if got != tt.wantRequestID {
+				t.Errorf("request_id = %q, want %q (record %s)", got, tt.wantRequestID, buf.String())
+			}
+			for key, want := range tt.wantAttrs {
+				if gotAttr, _ := json.Marshal(record[key]); string(gotAttr) != mustMarshal(t, want) {
+					t.Errorf("%s = %s, want %s", key, gotAttr, mustMarshal(t, want))
+				}
+			}
+		})
+	}
+
+	t.Run("calls without a context", func(t *testing.T) {
+		var buf bytes.Buffer
+		slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).Info("handled")
+		if bytes.Contains(buf.Bytes(), []byte(requestIDAttr)) {
+			t.Errorf("log record %s has a request ID, want none", buf.String())
+		}
+	})
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,98 @@
+// Package metrics registers the application's Prometheus collectors and exposes them for scraping.
+// Handlers, services and workers record metrics through the small interfaces in package interfaces,
+// so they do not depend on Prometheus directly.
+package metrics
+
+import (
+	"bitback/internal/interfaces"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Key tier label values.
+const (
+	tierFree = "free"
+	tierPaid = "paid"
+)
+
+// Metrics holds the application's Prometheus collectors and the registry they are registered with.
+// It implements interfaces.HTTPMetrics, interfaces.KeyMetrics and interfaces.HostMetrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	keysGenerated   *prometheus.CounterVec
+	hosts           *prometheus.GaugeVec
+}
+
+// New creates the application collectors and registers them, together with the Go runtime and process
+// collectors, with a new registry. If db is not nil, its connection pool statistics are exported as well.
+func New(db *sql.DB, dbName string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests processed, partitioned by method, route pattern and status code.",
+		}, []string{"method", "path", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests in seconds, partitioned by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "path"}),
+		keysGenerated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vless_keys_generated_total",
+			Help: "Total number of VLESS keys generated, partitioned by host tier (free or paid).",
+		}, []string{"tier"}),
+		hosts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hosts",
+			Help: "Number of hosts as of the last health check, partitioned by country, status and reachability.",
+		}, []string{"country", "status", "online"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestsTotal,
+		m.requestDuration,
+		m.keysGenerated,
+		m.hosts,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+	}
+	return m
+}
+
+// Handler returns the HTTP handler serving the registered metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records the count and latency of a handled HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requestsTotal.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// KeyGenerated counts a generated key for the tier of the host it was issued on.
+func (m *Metrics) KeyGenerated(freeTier bool) {
+	tier := tierPaid
+	if freeTier {
+		tier = tierFree
+	}
+	m.keysGenerated.WithLabelValues(tier).Inc()
+}
+
+// SetHostCounts replaces the host gauges with counts, so combinations no longer present are dropped.
+func (m *Metrics) SetHostCounts(counts []interfaces.HostCount) {
+	m.hosts.Reset()
+	for _, c := range counts {
+		m.hosts.WithLabelValues(c.Country, c.Status, strconv.FormatBool(c.Online)).Add(float64(c.Count))
+	}
+}
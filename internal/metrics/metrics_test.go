@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/testutil/sqlfake"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metrics served by m's handler in the text exposition format.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  func(m *Metrics)
+		want    []string // Lines expected in the scraped metrics.
+		notWant []string // Substrings that must not appear.
+	}{
+		{
+			name: "requests",
+			record: func(m *Metrics) {
+				m.ObserveRequest(http.MethodGet, "/v1/hosts/{hostID}", http.StatusOK, 20*time.Millisecond)
+				m.ObserveRequest(http.MethodGet, "/v1/hosts/{hostID}", http.StatusNotFound, 5*time.Millisecond)
+				m.ObserveRequest(http.MethodGet, "/v1/hosts/{hostID}", http.StatusOK, 3*time.Second)
+			},
+			want: []string{
+				`http_requests_total{method="GET",path="/v1/hosts/{hostID}",status="200"} 2`,
+				`http_requests_total{method="GET",path="/v1/hosts/{hostID}",status="404"} 1`,
+				`http_request_duration_seconds_count{method="GET",path="/v1/hosts/{hostID}"} 3`,
+				`http_request_duration_seconds_bucket{method="GET",path="/v1/hosts/{hostID}",le="0.025"} 2`,
+			},
+		},
+		{
+			name: "keys by tier",
+			record: func(m *Metrics) {
+				m.KeyGenerated(true)
+				m.KeyGenerated(true)
+				m.KeyGenerated(false)
+			},
+			want: []string{
+				`vless_keys_generated_total{tier="free"} 2`,
+				`vless_keys_generated_total{tier="paid"} 1`,
+			},
+		},
+		{
+			name: "host counts",
+			record: func(m *Metrics) {
+				m.SetHostCounts([]interfaces.HostCount{
+					{Country: "DE", Status: "active", Online: true, Count: 3},
+					{Country: "DE", Status: "inactive", Online: false, Count: 1},
+				})
+			},
+			want: []string{
+				`hosts{country="DE",online="true",status="active"} 3`,
+				`hosts{country="DE",online="false",status="inactive"} 1`,
+			},
+		},
+		{
+			name: "host counts are replaced",
+			record: func(m *Metrics) {
+				m.SetHostCounts([]interfaces.HostCount{{Country: "DE", Status: "active", Online: true, Count: 3}})
+				m.SetHostCounts([]interfaces.HostCount{{Country: "NL", Status: "active", Online: true, Count: 2}})
+			},
+			want:    []string{`hosts{country="NL",online="true",status="active"} 2`},
+			notWant: []string{`country="DE"`},
+		},
+		{
+			name:    "no database",
+			want:    []string{"go_goroutines", "process_start_time_seconds"},
+			notWant: []string{"go_sql_"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(nil, "")
+			if tt.record != nil {
+				tt.record(m)
+			}
+
+			scraped := scrape(t, m)
+			for _, line := range tt.want {
+				if !strings.Contains(scraped, line) {
+					t.Errorf("scraped metrics do not contain %q", line)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(scraped, s) {
+					t.Errorf("scraped metrics contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsDBStats(t *testing.T) {
+	gormDB, _, err := sqlfake.Open(nil)
+	if err != nil {
+		t.Fatalf("sqlfake.Open() error = %v", err)
+	}
+	db, err := gormDB.DB()
+	if err != nil {
+		t.Fatalf("DB() error = %v", err)
+	}
+
+	scraped := scrape(t, New(db, "bitback"))
+	for _, line := range []string{
+		`go_sql_max_open_connections{db_name="bitback"}`,
+		`go_sql_open_connections{db_name="bitback"}`,
+		`go_sql_wait_count_total{db_name="bitback"}`,
+	} {
+		if !strings.Contains(scraped, line) {
+			t.Errorf("scraped metrics do not contain %q", line)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package customTypes
+
+import (
+	"net"
+	"strings"
+)
+
+// AddressFamily classifies a host address by the IP version clients need to reach it.
+type AddressFamily string
+
+// Defines the address families of host addresses.
+const (
+	AddressFamilyIPv4     AddressFamily = "ipv4"     // The address is an IPv4 address.
+	AddressFamilyIPv6     AddressFamily = "ipv6"     // The address is an IPv6 address.
+	AddressFamilyHostname AddressFamily = "hostname" // The address is a domain name, which may resolve to either family.
+)
+
+// AddressFamilyOf classifies address with net.ParseIP. IPv6 addresses may be enclosed in brackets;
+// IPv4-mapped IPv6 addresses count as IPv4. Anything that is not an IP address is a hostname.
+func AddressFamilyOf(address string) AddressFamily {
+	address = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(address), "["), "]")
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return AddressFamilyHostname
+	case ip.To4() != nil:
+		return AddressFamilyIPv4
+	default:
+		return AddressFamilyIPv6
+	}
+}
+
+// IsIP reports whether af is one of the IP address families a client can ask for.
+func (af AddressFamily) IsIP() bool {
+	return af == AddressFamilyIPv4 || af == AddressFamilyIPv6
+}
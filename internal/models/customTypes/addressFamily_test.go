@@ -0,0 +1,32 @@
+package customTypes
+
+import "testing"
+
+func TestAddressFamilyOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    AddressFamily
+		wantIP  bool
+	}{
+		{name: "IPv4", address: "203.0.113.7", want: AddressFamilyIPv4, wantIP: true},
+		{name: "IPv4 with surrounding space", address: " 203.0.113.7 ", want: AddressFamilyIPv4, wantIP: true},
+		{name: "IPv6", address: "2001:db8::1", want: AddressFamilyIPv6, wantIP: true},
+		{name: "bracketed IPv6", address: "[2001:db8::1]", want: AddressFamilyIPv6, wantIP: true},
+		{name: "IPv4-mapped IPv6", address: "::ffff:203.0.113.7", want: AddressFamilyIPv4, wantIP: true},
+		{name: "hostname", address: "vpn.example.com", want: AddressFamilyHostname},
+		{name: "IPv4 with a port", address: "203.0.113.7:443", want: AddressFamilyHostname},
+		{name: "empty", address: "", want: AddressFamilyHostname},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AddressFamilyOf(tt.address)
+			if got != tt.want {
+				t.Errorf("AddressFamilyOf(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+			if got.IsIP() != tt.wantIP {
+				t.Errorf("%q.IsIP() = %t, want %t", got, got.IsIP(), tt.wantIP)
+			}
+		})
+	}
+}
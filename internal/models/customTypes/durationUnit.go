@@ -16,13 +16,13 @@ const (
 )
 
 // String satisfies the fmt.Stringer interface.
-func (du *DurationUnit) String() string {
-	return string(*du)
+func (du DurationUnit) String() string {
+	return string(du)
 }
 
 // IsValid checks if the DurationUnit value is one of the defined valid units.
-func (du *DurationUnit) IsValid() bool {
-	switch *du {
+func (du DurationUnit) IsValid() bool {
+	switch du {
 	case UnitDay, UnitMonth, UnitYear:
 		return true
 	default:
@@ -32,15 +32,18 @@ func (du *DurationUnit) IsValid() bool {
 
 // Value implements the driver.Valuer interface.
 // This method defines how DurationUnit will be stored in the database.
-func (du *DurationUnit) Value() (driver.Value, error) {
+// It has a value receiver because GORM passes struct fields by value; with a pointer receiver the check would be skipped.
+func (du DurationUnit) Value() (driver.Value, error) {
 	if !du.IsValid() {
-		return nil, fmt.Errorf("invalid DurationUnit value for database storage: %s", *du)
+		return nil, fmt.Errorf("invalid DurationUnit value for database storage: %s", du)
 	}
-	return string(*du), nil
+	return string(du), nil
 }
 
 // Scan implements the sql.Scanner interface.
 // This method defines how DurationUnit will be read from the database.
+// Unknown values from legacy rows are kept as they are instead of failing the whole query;
+// callers detect them with IsValid.
 func (du *DurationUnit) Scan(value interface{}) error {
 	if value == nil {
 		// Handle NULL from database; perhaps set to a default.
@@ -58,11 +61,6 @@ func (du *DurationUnit) Scan(value interface{}) error {
 		return fmt.Errorf("failed to scan DurationUnit: unsupported type %T", value)
 	}
 
-	scannedUnit := DurationUnit(strValue)
-
-	if strValue != "" && !scannedUnit.IsValid() {
-		return fmt.Errorf("invalid DurationUnit value '%s' from database", strValue)
-	}
-	*du = scannedUnit
+	*du = DurationUnit(strValue)
 	return nil
 }
@@ -0,0 +1,66 @@
+package customTypes
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestDurationUnitValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    DurationUnit
+		want    driver.Value
+		wantErr bool
+	}{
+		{name: "day", unit: UnitDay, want: "day"},
+		{name: "month", unit: UnitMonth, want: "month"},
+		{name: "year", unit: UnitYear, want: "year"},
+		{name: "empty", unit: "", wantErr: true},
+		{name: "unknown", unit: "week", wantErr: true},
+		{name: "wrong case", unit: "Month", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GORM hands fields to the driver by value, so the check must run through a non-pointer Valuer.
+			var valuer driver.Valuer = tt.unit
+			got, err := valuer.Value()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Value() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationUnitScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     any
+		want      DurationUnit
+		wantValid bool
+		wantErr   bool
+	}{
+		{name: "string", value: "month", want: UnitMonth, wantValid: true},
+		{name: "bytes", value: []byte("year"), want: UnitYear, wantValid: true},
+		{name: "NULL", value: nil, want: ""},
+		{name: "legacy unit is kept", value: "fortnight", want: "fortnight"},
+		{name: "unsupported type", value: 42, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got DurationUnit
+			err := got.Scan(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Scan() = %q, want %q", got, tt.want)
+			}
+			if got.IsValid() != tt.wantValid {
+				t.Errorf("IsValid() = %v, want %v", got.IsValid(), tt.wantValid)
+			}
+		})
+	}
+}
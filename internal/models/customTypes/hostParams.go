@@ -3,17 +3,69 @@ package customTypes
 // ListHostsParams contains parameters for filtering and paginating the list of hosts.
 // Pointer fields are used for optional filters; if a field is nil, the filter is not applied.
 type ListHostsParams struct {
-	Offset    int         // The number of records to skip for pagination.
-	Limit     int         // The maximum number of records to return.
-	Country   *string     // Optional: Filter by country code (e.g., ISO 3166-1 alpha-2).
-	City      *string     // Optional: Filter by city name.
-	Protocol  *string     // Optional: Filter by protocol (e.g., "tcp", "udp", "http").
-	Network   *string     // Optional: Filter by network type (e.g., "tcp", "ws").
-	IsOnline  *bool       // Optional: Filter by online status.
-	IsPrivate *bool       // Optional: Filter by private status.
-	Status    *HostStatus // Optional: Filter by specific host status (e.g., "active", "maintenance").
-	HostName  *string     // Optional: Filter by a partial match on the host name.
-	Address   *string     // Optional: Filter by a partial match on the host address (IP or domain).
-	SortBy    string      // Field name to sort by (e.g., "created_at", "host_name").
-	SortOrder string      // Sort order: "asc" for ascending, "desc" for descending.
+	Offset     int         // The number of records to skip for pagination.
+	Limit      int         // The maximum number of records to return.
+	Country    *string     // Optional: Filter by country code (e.g., ISO 3166-1 alpha-2).
+	City       *string     // Optional: Filter by city name.
+	Region     *string     // Optional: Filter by region, case-insensitively.
+	Provider   *string     // Optional: Filter by provider, case-insensitively.
+	Protocol   *string     // Optional: Filter by protocol (e.g., "tcp", "udp", "http").
+	Network    *string     // Optional: Filter by network type (e.g., "tcp", "ws").
+	IsOnline   *bool       // Optional: Filter by online status.
+	IsPrivate  *bool       // Optional: Filter by private status.
+	IsFreeTier *bool       // Optional: Filter by tier (true for free hosts, false for paid ones).
+	Status     *HostStatus // Optional: Filter by specific host status (e.g., "active", "maintenance").
+	HostName   *string     // Optional: Filter by a partial match on the host name.
+	Address    *string     // Optional: Filter by a partial match on the host address (IP or domain).
+	SortBy     string      // Field name to sort by (e.g., "created_at", "host_name").
+	SortOrder  string      // Sort order: "asc" for ascending, "desc" for descending.
+
+	IncludeDeleted bool // Include soft-deleted hosts in the results.
+}
+
+// HostSelectionFilter restricts the hosts considered when a host is selected for a key.
+// Nil or empty fields match any host; string fields are compared case-insensitively.
+type HostSelectionFilter struct {
+	Country      *string // Country code.
+	IsFreeTier   *bool   // Free (true) or paid (false) tier.
+	Network      *string // Transport, e.g. tcp, ws or grpc.
+	SecurityType *string // Security type, e.g. none, tls or reality.
+	Protocol     *string // Protocol, e.g. vless or trojan.
+
+	AddressFamily *AddressFamily // Family of the host address.
+}
+
+// CountryHostAvailability contains the number of hosts available for key generation in a single country.
+type CountryHostAvailability struct {
+	Country string // Country code; empty for hosts without a country.
+	Free    int64  // Available free-tier hosts.
+	Paid    int64  // Available paid-tier hosts.
+}
+
+// CountryAvailability summarizes the public hosts available for key generation in a single country.
+type CountryAvailability struct {
+	Country         string // Country code; empty for hosts without a country.
+	CityCount       int64  // Distinct non-empty cities with an available host.
+	OnlineHostCount int64  // Available hosts.
+	HasFreeTier     bool   // Whether any available host is in the free tier.
+}
+
+// ProviderHostCounts contains aggregated host counts for a single provider.
+type ProviderHostCounts struct {
+	Provider string // Provider name; empty for hosts without a provider.
+	Total    int64  // Number of non-deleted hosts of the provider.
+	Online   int64  // Hosts currently marked online.
+	Active   int64  // Hosts with the 'active' status.
+}
+
+// HostStatusCount contains the number of hosts with one status.
+type HostStatusCount struct {
+	Status HostStatus
+	Count  int64
+}
+
+// CountryHostCount contains the number of hosts in one country.
+type CountryHostCount struct {
+	Country string // Country code; empty for hosts without a country.
+	Count   int64
 }
@@ -0,0 +1,15 @@
+package customTypes
+
+// KeyGenerationBucketCounts contains the number of keys generated in one time bucket of a key generation report.
+type KeyGenerationBucketCounts struct {
+	Bucket   int64 // Index of the bucket, counted from the start of the report window.
+	FreeKeys int64 // Anonymous free keys.
+	UserKeys int64 // Keys generated for a user.
+}
+
+// ConversionFunnelCounts contains the number of users at each stage of the free-to-paid conversion funnel.
+type ConversionFunnelCounts struct {
+	FreeKeyUsers int64 // Users who were issued a free-tier key within the period.
+	Subscribed   int64 // Of those, users who created a subscription after their first free-tier key in the period.
+	Paid         int64 // Of those, users with such a subscription that is paid.
+}
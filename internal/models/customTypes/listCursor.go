@@ -0,0 +1,21 @@
+package customTypes
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HostListCursor identifies the last host of a keyset-paginated page of hosts.
+// The next page starts after it in (created_at DESC, id DESC) order.
+type HostListCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// UserListCursor identifies the last user of a keyset-paginated page of users.
+// The next page starts after it in (created_at DESC, id DESC) order.
+type UserListCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
@@ -0,0 +1,18 @@
+package customTypes
+
+// SubscriptionEventType identifies the kind of change recorded in a subscription's history.
+type SubscriptionEventType string
+
+// Defines the set of subscription event types.
+const (
+	SubscriptionEventCreated          SubscriptionEventType = "created"           // The subscription was created.
+	SubscriptionEventPaymentUpdated   SubscriptionEventType = "payment_updated"   // The payment status (and possibly the active flag) changed.
+	SubscriptionEventPaymentReceived  SubscriptionEventType = "payment_received"  // The payment provider reported a payment for the subscription.
+	SubscriptionEventCancelled        SubscriptionEventType = "cancelled"         // Auto-renewal was turned off by a cancellation.
+	SubscriptionEventAutoRenewChanged SubscriptionEventType = "autorenew_changed" // The auto-renewal flag was changed directly.
+	SubscriptionEventExpiryNotified   SubscriptionEventType = "expiry_notified"   // The user was reminded that the subscription expires.
+	SubscriptionEventRenewed          SubscriptionEventType = "renewed"           // The renewal job extended the subscription by another period.
+	SubscriptionEventActivated        SubscriptionEventType = "activated"         // A paid subscription became active when its start date arrived.
+	SubscriptionEventDeactivated      SubscriptionEventType = "deactivated"       // A renewal was not paid within the grace period.
+	SubscriptionEventDeleted          SubscriptionEventType = "deleted"           // The subscription was soft-deleted.
+)
@@ -0,0 +1,72 @@
+package customTypes
+
+// SubscriptionListStatus is a derived lifecycle status used to filter a user's subscriptions.
+// It is computed from the stored fields rather than stored itself.
+type SubscriptionListStatus string
+
+// Defines the set of subscription statuses accepted by list filters.
+const (
+	SubscriptionListStatusActive    SubscriptionListStatus = "active"    // Active and not yet past its end date.
+	SubscriptionListStatusExpired   SubscriptionListStatus = "expired"   // Past its end date, regardless of the is_active flag.
+	SubscriptionListStatusCancelled SubscriptionListStatus = "cancelled" // Inactive with auto-renewal disabled.
+)
+
+// IsValid checks if the SubscriptionListStatus value is one of the predefined statuses.
+func (s SubscriptionListStatus) IsValid() bool {
+	switch s {
+	case SubscriptionListStatusActive, SubscriptionListStatusExpired, SubscriptionListStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListUserSubscriptionsParams contains parameters for filtering, sorting and paginating a user's subscriptions.
+// Pointer fields are used for optional filters; if a field is nil, the filter is not applied.
+type ListUserSubscriptionsParams struct {
+	Offset        int                     // The number of records to skip for pagination.
+	Limit         int                     // The maximum number of records to return.
+	Status        *SubscriptionListStatus // Optional: Filter by derived lifecycle status.
+	PaymentStatus *string                 // Optional: Filter by payment status (e.g., "paid", "pending").
+	PlanName      *string                 // Optional: Filter by plan name, case-insensitively.
+	SortBy        string                  // Field name to sort by ("created_at", "start_date" or "end_date"); defaults to created_at.
+	SortOrder     string                  // Sort order: "asc" for ascending, "desc" for descending (default).
+}
+
+// ListSubscriptionsFilters contains optional filters for listing subscriptions across all users.
+// Pointer fields are used for optional filters; if a field is nil, the filter is not applied.
+type ListSubscriptionsFilters struct {
+	PaymentStatus *string // Optional: Filter by payment status (e.g., "paid", "pending").
+	IsActive      *bool   // Optional: Filter by active status.
+	ReminderSent  *bool   // Optional: Filter by whether an expiry reminder has been sent.
+}
+
+// SubscriptionCurrencyTotals contains aggregated subscription totals for one currency.
+// Subscriptions without a currency are grouped under an empty Currency.
+type SubscriptionCurrencyTotals struct {
+	Currency      string  // Upper-cased currency code, or empty.
+	Subscriptions int64   // Subscriptions in this currency.
+	Paid          int64   // Of those, subscriptions with payment status "paid".
+	Revenue       float64 // Sum of the prices of the paid subscriptions.
+}
+
+// SubscriptionChurnCounts contains aggregated subscription counts for a churn period.
+// Only paid subscriptions are counted.
+type SubscriptionChurnCounts struct {
+	ActiveAtStart int64 // Subscriptions active at the start of the period.
+	Renewed       int64 // Subscriptions that ended within the period and were renewed.
+	Expired       int64 // Subscriptions that ended within the period with auto-renewal enabled but were not renewed.
+	Cancelled     int64 // Subscriptions that ended within the period after auto-renewal was disabled.
+}
+
+// PaymentStatusCount contains the number of subscriptions with one payment status.
+type PaymentStatusCount struct {
+	PaymentStatus string
+	Count         int64
+}
+
+// PlanSubscriptionCount contains the number of active subscriptions of one plan.
+type PlanSubscriptionCount struct {
+	PlanName string
+	Count    int64
+}
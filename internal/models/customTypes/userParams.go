@@ -0,0 +1,27 @@
+package customTypes
+
+import "time"
+
+// ListUsersParams contains parameters for filtering, sorting and paginating the list of users.
+// Pointer fields are used for optional filters; if a field is nil, the filter is not applied.
+type ListUsersParams struct {
+	Offset        int        // The number of records to skip for pagination.
+	Limit         int        // The maximum number of records to return.
+	Query         *string    // Optional: Case-insensitive partial match on the name or email.
+	IsActive      *bool      // Optional: Filter by active status.
+	HasTelegram   *bool      // Optional: Filter by whether a Telegram ID is set.
+	CreatedAfter  *time.Time // Optional: Only users created at or after this time.
+	CreatedBefore *time.Time // Optional: Only users created before this time.
+	SortBy        string     // Field name to sort by ("created_at", "name", "email" or "last_login"); defaults to created_at.
+	SortOrder     string     // Sort order: "asc" for ascending, "desc" for descending (default).
+
+	IncludeDeleted bool // Include soft-deleted users in the results.
+}
+
+// UserCounts contains aggregated counts of the non-deleted users.
+type UserCounts struct {
+	Total         int64 // All users.
+	Active        int64 // Users whose account is active.
+	NewLast7Days  int64 // Users created within the last 7 days.
+	NewLast30Days int64 // Users created within the last 30 days.
+}
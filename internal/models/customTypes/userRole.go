@@ -0,0 +1,73 @@
+package customTypes
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// UserRole defines the role of a user within the system.
+type UserRole string
+
+// Defines the set of valid user roles.
+const (
+	RoleUser  UserRole = "user"  // Regular user; can only access their own resources.
+	RoleAdmin UserRole = "admin" // Administrator; can access and manage all resources.
+)
+
+// String satisfies the fmt.Stringer interface, returning the string representation of the UserRole.
+func (ur *UserRole) String() string {
+	return string(*ur)
+}
+
+// IsValid checks if the UserRole value is one of the predefined valid roles.
+func (ur *UserRole) IsValid() bool {
+	switch *ur {
+	case RoleUser, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsAdmin reports whether the role grants administrator privileges.
+func (ur *UserRole) IsAdmin() bool {
+	return *ur == RoleAdmin
+}
+
+// Value implements the driver.Valuer interface.
+// This method defines how UserRole will be stored in the database.
+func (ur *UserRole) Value() (driver.Value, error) {
+	if !ur.IsValid() {
+		return nil, fmt.Errorf("invalid UserRole value for database storage: %s", *ur)
+	}
+	return string(*ur), nil
+}
+
+// Scan implements the sql.Scanner interface.
+// This method defines how UserRole will be read from the database.
+func (ur *UserRole) Scan(value interface{}) error {
+	if value == nil {
+		// If the database value is NULL, fall back to the least privileged role.
+		*ur = RoleUser
+		return nil
+	}
+
+	var strValue string
+	switch v := value.(type) {
+	case []byte:
+		strValue = string(v)
+	case string:
+		strValue = v
+	default:
+		return fmt.Errorf("failed to scan UserRole: unsupported type %T", value)
+	}
+
+	scannedRole := UserRole(strValue)
+
+	if !scannedRole.IsValid() {
+		*ur = RoleUser
+		return nil
+	}
+	*ur = scannedRole
+	return nil
+}
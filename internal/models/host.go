@@ -6,30 +6,41 @@ import (
 	"time"
 )
 
+// HostNameUniqueIndex is the partial unique index on lower(host_name) for non-deleted hosts with a name.
+// It is created only when host-name uniqueness is enforced, so it is not declared in the Host struct tags.
+const HostNameUniqueIndex = "idx_hosts_host_name_unique"
+
 // Host defines the database model for a host or server.
 type Host struct {
-	ID            uint                   `gorm:"primaryKey" json:"id"`
-	HostName      string                 `json:"host_name,omitempty" gorm:"index"`                               // Optional: A descriptive name for the host.
-	Country       string                 `json:"country,omitempty" gorm:"index"`                                 // Optional: The country where the host is located.
-	City          string                 `json:"city,omitempty" gorm:"index"`                                    // Optional: The city where the host is located.
-	Region        string                 `json:"region,omitempty" gorm:"index"`                                  // Optional: The geographical or logical region of the host.
-	Provider      string                 `json:"provider,omitempty"`                                             // Optional: The provider or owner of the host infrastructure.
-	Address       string                 `json:"address" gorm:"not null;"`                                       // Mandatory: The IP address or domain name of the host.
-	Port          string                 `json:"port" gorm:"not null;"`                                          // Mandatory: The port number for the host service.
-	Protocol      string                 `json:"protocol" gorm:"type:varchar(10);not null;"`                     // Mandatory: The protocol (e.g., http, https, tcp).
-	Network       string                 `json:"network,omitempty" gorm:"type:varchar(10);default:'tcp';index;"` // Network type (e.g., tcp, ws, grpc, kcp). Defaults to 'tcp'.
-	PublicKey     string                 `json:"public_key,omitempty" gorm:"type:text"`                          // Public key, often used for specific security protocols (e.g., Reality).
-	Flow          string                 `json:"flow,omitempty"`                                                 // Flow control mechanism or specific protocol feature.
-	RSID          string                 `json:"rsid,omitempty" gorm:"column:rsid"`                              // Reality Short ID.
-	SecurityType  string                 `json:"security_type,omitempty"`                                        // Security type (e.g., tls, none, reality).
-	SNI           string                 `json:"sni,omitempty" gorm:"column:sni"`                                // Server Name Indication, used in TLS.
-	Fingerprint   string                 `json:"fingerprint,omitempty"`                                          // TLS fingerprint or similar identifier.
-	IsPrivate     bool                   `json:"is_private" gorm:"default:false"`                                // Specifies if the host is private; defaults to false.
-	IsOnline      bool                   `json:"is_online" gorm:"default:false;index"`                           // Indicates if the host is currently online; defaults to false.
-	IsFreeTier    bool                   `json:"is_free_tier" gorm:"default:false;index"`                        // Specifies if the host is available for the free tier; defaults to false.
-	Status        customTypes.HostStatus `json:"status,omitempty" gorm:"type:varchar(20);default:'unknown'"`     // Detailed status of the host (e.g., active, maintenance); defaults to 'unknown'.
-	LastCheckedAt *time.Time             `json:"last_checked_at,omitempty"`                                      // Timestamp of the last status check.
-	CreatedAt     time.Time              `json:"created_at"`                                                     // Timestamp of creation.
-	UpdatedAt     time.Time              `json:"updated_at"`                                                     // Timestamp of the last update.
-	DeletedAt     gorm.DeletedAt         `gorm:"index" json:"deleted_at,omitempty"`                              // Timestamp for soft deletion.
+	ID            uint                      `gorm:"primaryKey;index:idx_hosts_created_at_id,priority:2" json:"id"`
+	HostName      string                    `json:"host_name,omitempty" gorm:"index"`                               // Optional: A descriptive name for the host.
+	Country       string                    `json:"country,omitempty" gorm:"index"`                                 // Optional: The country where the host is located.
+	City          string                    `json:"city,omitempty" gorm:"index"`                                    // Optional: The city where the host is located.
+	Region        string                    `json:"region,omitempty" gorm:"index"`                                  // Optional: The geographical or logical region of the host.
+	Provider      string                    `json:"provider,omitempty"`                                             // Optional: The provider or owner of the host infrastructure.
+	Address       string                    `json:"address" gorm:"not null;"`                                       // Mandatory: The IP address or domain name of the host.
+	AddressFamily customTypes.AddressFamily `json:"address_family" gorm:"type:varchar(8);index"`                    // Family of Address (ipv4, ipv6 or hostname), derived from it when the address is saved.
+	Port          string                    `json:"port" gorm:"not null;"`                                          // Mandatory: The port number for the host service.
+	Protocol      string                    `json:"protocol" gorm:"type:varchar(10);not null;"`                     // Mandatory: The protocol (e.g., vless, vmess, trojan).
+	Network       string                    `json:"network,omitempty" gorm:"type:varchar(10);default:'tcp';index;"` // Network type (e.g., tcp, ws, grpc, kcp). Defaults to 'tcp'.
+	PublicKey     string                    `json:"public_key,omitempty" gorm:"type:text"`                          // Public key, often used for specific security protocols (e.g., Reality).
+	Flow          string                    `json:"flow,omitempty"`                                                 // Flow control mechanism or specific protocol feature.
+	RSID          string                    `json:"rsid,omitempty" gorm:"column:rsid"`                              // Reality Short ID.
+	SecurityType  string                    `json:"security_type,omitempty"`                                        // Security type (e.g., tls, none, reality).
+	SNI           string                    `json:"sni,omitempty" gorm:"column:sni"`                                // Server Name Indication, used in TLS.
+	Fingerprint   string                    `json:"fingerprint,omitempty"`                                          // TLS fingerprint or similar identifier.
+	IsPrivate     bool                      `json:"is_private" gorm:"default:false"`                                // Specifies if the host is private; defaults to false.
+	IsOnline      bool                      `json:"is_online" gorm:"default:false;index"`                           // Indicates if the host is currently online; defaults to false.
+	IsFreeTier    bool                      `json:"is_free_tier" gorm:"default:false;index"`                        // Specifies if the host is available for the free tier; defaults to false.
+	Status        customTypes.HostStatus    `json:"status,omitempty" gorm:"type:varchar(20);default:'unknown'"`     // Detailed status of the host (e.g., active, maintenance); defaults to 'unknown'.
+	LastCheckedAt *time.Time                `json:"last_checked_at,omitempty"`                                      // Timestamp of the last status check.
+	LatencyMs     *int                      `json:"latency_ms,omitempty"`                                           // Latency measured by the last check in milliseconds; nil if unknown or the host was unreachable.
+	Notes         string                    `json:"notes,omitempty" gorm:"type:text"`                               // Optional: Operator notes or runbook for the host; visible to administrators only.
+	IssuedCount   int64                     `json:"issued_count" gorm:"not null;default:0;index"`                   // Number of keys issued for this host; used to spread key issuance evenly.
+	LastIssuedAt  *time.Time                `json:"last_issued_at,omitempty"`                                       // Timestamp of the last key issued for this host.
+	CurrentUsers  int64                     `json:"current_users" gorm:"not null;default:0"`                        // Number of active key assignments on this host.
+	MaxUsers      int64                     `json:"max_users" gorm:"not null;default:0"`                            // Capacity of the host in active key assignments; 0 if unknown or unlimited.
+	CreatedAt     time.Time                 `json:"created_at" gorm:"index:idx_hosts_created_at_id,priority:1"`     // Timestamp of creation; indexed with ID for keyset pagination.
+	UpdatedAt     time.Time                 `json:"updated_at"`                                                     // Timestamp of the last update.
+	DeletedAt     gorm.DeletedAt            `gorm:"index" json:"deleted_at,omitempty"`                              // Timestamp for soft deletion.
 }
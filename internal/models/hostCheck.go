@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// HostCheck defines the database model for a single health check result of a host.
+// The history of checks is used to compute host uptime.
+type HostCheck struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HostID    uint      `json:"host_id" gorm:"not null;index:idx_host_checks_host_checked_at,priority:1"`    // Foreign key linking to the Host.
+	Host      Host      `json:"-" gorm:"foreignKey:HostID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`    // Associated Host model (ignored in JSON, handled by foreign key).
+	CheckedAt time.Time `json:"checked_at" gorm:"not null;index:idx_host_checks_host_checked_at,priority:2"` // Timestamp when the check was performed.
+	WasOnline bool      `json:"was_online"`                                                                  // Indicates if the host was reachable during the check.
+	LatencyMs *int      `json:"latency_ms,omitempty"`                                                        // Optional: Measured latency in milliseconds.
+	Error     string    `json:"error,omitempty" gorm:"type:text"`                                            // Optional: Error reported by the check.
+	CreatedAt time.Time `json:"created_at"`                                                                  // Timestamp of creation.
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// KeyAssignment defines the database model for a key issued to a user on a specific host.
+// It allows the most recently issued key to be reconstructed without selecting a new host.
+type KeyAssignment struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`                                          // Unique identifier for the assignment.
+	UserID    uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`                                  // Foreign key linking to the User.
+	User      User           `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"` // Associated User model (ignored in JSON, handled by foreign key).
+	HostID    uint           `json:"host_id" gorm:"not null;index"`                                            // Foreign key linking to the Host the key was issued for.
+	Host      Host           `json:"-" gorm:"foreignKey:HostID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"` // Associated Host model (ignored in JSON, handled by foreign key).
+	Remarks   string         `json:"remarks,omitempty"`                                                        // Optional: Remarks embedded in the issued key.
+	IsActive  bool           `json:"is_active" gorm:"default:true;index"`                                      // Indicates if the assignment is still valid; defaults to true.
+	CreatedAt time.Time      `json:"created_at"`                                                               // Timestamp of creation.
+	UpdatedAt time.Time      `json:"updated_at"`                                                               // Timestamp of the last update.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`                                        // Timestamp for soft deletion.
+}
+
+// BeforeCreate is a GORM hook that runs before a new key assignment record is created.
+// It generates a new UUID (version 7) for the assignment's ID.
+func (k *KeyAssignment) BeforeCreate(tx *gorm.DB) (err error) {
+	k.ID, err = uuid.NewV7()
+	return err
+}
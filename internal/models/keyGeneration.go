@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// KeyGeneration defines the database model for a log entry of a generated key.
+// The log is used to report how fast keys are issued.
+type KeyGeneration struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index"` // User the key was generated for; nil for anonymous free keys.
+	HostID     uint       `json:"host_id" gorm:"not null"`                  // Host the key was issued on.
+	IsFreeTier bool       `json:"is_free_tier"`                             // Whether the host was in the free tier when the key was issued.
+	CreatedAt  time.Time  `json:"created_at" gorm:"not null;index"`         // Timestamp of the generation.
+}
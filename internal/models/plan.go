@@ -0,0 +1,25 @@
+package models
+
+import (
+	"bitback/internal/models/customTypes"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Plan defines the database model for a subscription plan in the catalog.
+// Subscriptions copy the plan's name, duration and price when they are created,
+// so later changes to a plan do not affect existing subscriptions.
+type Plan struct {
+	ID            uint                     `gorm:"primaryKey" json:"id"`
+	Name          string                   `json:"name" gorm:"not null;uniqueIndex:idx_plans_name,where:deleted_at IS NULL"` // Unique name of the plan among non-deleted plans.
+	Description   string                   `json:"description,omitempty" gorm:"type:text"`                                   // Optional: Human-readable description of the plan.
+	DurationUnit  customTypes.DurationUnit `json:"duration_unit" gorm:"type:varchar(10);not null"`                           // Unit for the duration (e.g., day, month, year).
+	DurationValue int                      `json:"duration_value" gorm:"not null"`                                           // Value for the duration in DurationUnit.
+	Price         float64                  `json:"price"`                                                                    // Price of the plan.
+	Currency      string                   `json:"currency,omitempty" gorm:"type:varchar(3)"`                                // Optional: Currency code for the price (e.g., "USD").
+	IsActive      bool                     `json:"is_active" gorm:"not null;index"`                                          // Indicates if new subscriptions may use the plan.
+	IsFreeTier    bool                     `json:"is_free_tier" gorm:"not null"`                                             // Indicates if the plan grants free tier access only.
+	CreatedAt     time.Time                `json:"created_at"`                                                               // Timestamp of creation.
+	UpdatedAt     time.Time                `json:"updated_at"`                                                               // Timestamp of the last update.
+	DeletedAt     gorm.DeletedAt           `gorm:"index" json:"deleted_at,omitempty"`                                        // Timestamp for soft deletion.
+}
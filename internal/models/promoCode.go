@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// PromoCode defines the database model for a promotional discount code applied when creating subscriptions.
+// A code grants either a percentage or a fixed amount off the subscription price.
+type PromoCode struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Code            string         `json:"code" gorm:"type:varchar(64);not null;uniqueIndex:idx_promo_codes_code,where:deleted_at IS NULL"` // Unique, upper-case code entered by customers.
+	DiscountPercent float64        `json:"discount_percent,omitempty"`                                                                      // Percentage taken off the price (0-100); mutually exclusive with DiscountAmount.
+	DiscountAmount  float64        `json:"discount_amount,omitempty"`                                                                       // Fixed amount taken off the price; mutually exclusive with DiscountPercent.
+	ValidFrom       *time.Time     `json:"valid_from,omitempty"`                                                                            // Optional: The code cannot be used before this time.
+	ValidUntil      *time.Time     `json:"valid_until,omitempty"`                                                                           // Optional: The code cannot be used after this time.
+	MaxUses         *int           `json:"max_uses,omitempty"`                                                                              // Optional: Maximum number of redemptions; unlimited if nil.
+	UsedCount       int            `json:"used_count" gorm:"not null;default:0"`                                                            // Number of times the code has been redeemed.
+	IsActive        bool           `json:"is_active" gorm:"not null;index"`                                                                 // Indicates if the code can currently be redeemed.
+	CreatedAt       time.Time      `json:"created_at"`                                                                                      // Timestamp of creation.
+	UpdatedAt       time.Time      `json:"updated_at"`                                                                                      // Timestamp of the last update.
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`                                                               // Timestamp for soft deletion.
+}
@@ -9,22 +9,29 @@ import (
 
 // Subscription defines the database model for a user's subscription plan.
 type Subscription struct {
-	ID            uuid.UUID                `gorm:"type:uuid;primary_key" json:"id"`                                           // Unique identifier for the subscription.
-	UserID        uuid.UUID                `json:"user_id" gorm:"type:uuid;not null;index"`                                   // Foreign key linking to the User.
-	User          User                     `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"` // Associated User model (ignored in JSON, handled by foreign key).
-	PlanName      string                   `json:"plan_name" gorm:"not null"`                                                 // Name of the subscription plan.
-	DurationUnit  customTypes.DurationUnit `json:"duration_unit" gorm:"type:varchar(10);not null"`                            // Unit for the duration (e.g., day, month, year).
-	DurationValue int                      `json:"duration_value" gorm:"not null"`                                            // Value for the duration in DurationUnit.
-	StartDate     time.Time                `json:"start_date" gorm:"not null"`                                                // Date when the subscription starts.
-	EndDate       time.Time                `json:"end_date" gorm:"not null"`                                                  // Date when the subscription ends.
-	Currency      string                   `json:"currency,omitempty" gorm:"type:varchar(3)"`                                 // Optional: Currency code for the price (e.g., "USD").
-	Price         float64                  `json:"price,omitempty"`                                                           // Optional: Price of the subscription.
-	IsActive      bool                     `json:"is_active"`                                                                 // Indicates if the subscription is currently active.
-	PaymentStatus string                   `json:"payment_status,omitempty" gorm:"type:varchar(20);index"`                    // Status of the payment (e.g., "paid", "pending").
-	AutoRenew     bool                     `json:"auto_renew" gorm:"default:false"`                                           // Flag indicating if the subscription should auto-renew; defaults to false.
-	CreatedAt     time.Time                `json:"created_at"`                                                                // Timestamp of creation.
-	UpdatedAt     time.Time                `json:"updated_at"`                                                                // Timestamp of the last update.
-	DeletedAt     gorm.DeletedAt           `gorm:"index" json:"deleted_at,omitempty"`                                         // Timestamp for soft deletion.
+	ID                   uuid.UUID                `gorm:"type:uuid;primary_key" json:"id"`                                                            // Unique identifier for the subscription.
+	UserID               uuid.UUID                `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_subscriptions_user_idempotency_key"` // Foreign key linking to the User.
+	User                 User                     `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`                  // Associated User model (ignored in JSON, handled by foreign key).
+	PlanID               *uint                    `json:"plan_id,omitempty" gorm:"index"`                                                             // Optional: ID of the catalog plan the subscription was created from.
+	PlanName             string                   `json:"plan_name" gorm:"not null"`                                                                  // Name of the subscription plan, copied from the plan at creation time.
+	DurationUnit         customTypes.DurationUnit `json:"duration_unit" gorm:"type:varchar(10);not null"`                                             // Unit for the duration (e.g., day, month, year).
+	DurationValue        int                      `json:"duration_value" gorm:"not null"`                                                             // Value for the duration in DurationUnit.
+	StartDate            time.Time                `json:"start_date" gorm:"not null"`                                                                 // Date when the subscription starts.
+	EndDate              time.Time                `json:"end_date" gorm:"not null"`                                                                   // Date when the subscription ends.
+	Currency             string                   `json:"currency,omitempty" gorm:"type:varchar(3)"`                                                  // Optional: Currency code for the price (e.g., "USD").
+	Price                float64                  `json:"price,omitempty"`                                                                            // Optional: Price of the subscription.
+	IsActive             bool                     `json:"is_active"`                                                                                  // Indicates if the subscription is currently active.
+	PaymentStatus        string                   `json:"payment_status,omitempty" gorm:"type:varchar(20);index"`                                     // Status of the payment (e.g., "paid", "pending").
+	ExternalPaymentID    *string                  `json:"external_payment_id,omitempty" gorm:"type:varchar(128);uniqueIndex"`                         // Optional: Payment provider's ID of the payment that paid for the subscription.
+	AutoRenew            bool                     `json:"auto_renew" gorm:"default:false"`                                                            // Flag indicating if the subscription should auto-renew; defaults to false.
+	PromoCodeID          *uint                    `json:"promo_code_id,omitempty" gorm:"index"`                                                       // Optional: ID of the promo code redeemed when the subscription was created.
+	LastRenewedAt        *time.Time               `json:"last_renewed_at,omitempty"`                                                                  // Optional: Timestamp at which the renewal job last extended this subscription.
+	LastExpiryNotifiedAt *time.Time               `json:"last_expiry_notified_at,omitempty"`                                                          // Optional: Timestamp at which the user was last reminded that the subscription expires.
+	IdempotencyKey       *string                  `json:"-" gorm:"type:varchar(128);uniqueIndex:idx_subscriptions_user_idempotency_key"`              // Optional: Client-supplied key that makes creation idempotent; unique per user.
+	IdempotencyHash      string                   `json:"-" gorm:"type:varchar(64)"`                                                                  // Fingerprint of the creation payload, used to detect reuse of an idempotency key with a different payload.
+	CreatedAt            time.Time                `json:"created_at"`                                                                                 // Timestamp of creation.
+	UpdatedAt            time.Time                `json:"updated_at"`                                                                                 // Timestamp of the last update.
+	DeletedAt            gorm.DeletedAt           `gorm:"index" json:"deleted_at,omitempty"`                                                          // Timestamp for soft deletion.
 }
 
 // BeforeCreate is a GORM hook that runs before a new subscription record is created.
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"bitback/internal/models/customTypes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// SubscriptionEvent defines the database model for one entry of a subscription's append-only history.
+// Events are written in the same transaction as the change they describe and are never updated or deleted.
+type SubscriptionEvent struct {
+	ID             uint                              `gorm:"primaryKey" json:"id"`
+	SubscriptionID uuid.UUID                         `json:"subscription_id" gorm:"type:uuid;not null;index:idx_subscription_events_subscription_created_at,priority:1"` // The subscription the event belongs to.
+	EventType      customTypes.SubscriptionEventType `json:"event_type" gorm:"type:varchar(32);not null"`                                                                // Kind of change.
+	OldValue       json.RawMessage                   `json:"old_value,omitempty" gorm:"type:jsonb"`                                                                      // Optional: The changed fields before the change.
+	NewValue       json.RawMessage                   `json:"new_value,omitempty" gorm:"type:jsonb"`                                                                      // Optional: The changed fields after the change.
+	ActorUserID    *uuid.UUID                        `json:"actor_user_id,omitempty" gorm:"type:uuid;index"`                                                             // Optional: The user who made the change; nil for system jobs.
+	CreatedAt      time.Time                         `json:"created_at" gorm:"index:idx_subscription_events_subscription_created_at,priority:2"`                         // Timestamp of the change.
+}
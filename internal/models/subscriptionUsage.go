@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SubscriptionUsage defines the database model for the metered usage of a subscription.
+// There is one record per subscription, metric and billing period; reported usage is added to its quantity.
+type SubscriptionUsage struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id" gorm:"type:uuid;not null;uniqueIndex:idx_subscription_usages_period,priority:1"` // The subscription the usage belongs to.
+	Metric         string    `json:"metric" gorm:"type:varchar(64);not null;uniqueIndex:idx_subscription_usages_period,priority:3"`   // Name of the metered quantity (e.g., "traffic_gb").
+	Quantity       int64     `json:"quantity" gorm:"not null;default:0"`                                                              // Total usage reported for the period.
+	PeriodStart    time.Time `json:"period_start" gorm:"not null;uniqueIndex:idx_subscription_usages_period,priority:2"`              // Start of the billing period (inclusive).
+	PeriodEnd      time.Time `json:"period_end" gorm:"not null"`                                                                      // End of the billing period (exclusive).
+	CreatedAt      time.Time `json:"created_at"`                                                                                      // Timestamp of the first report in the period.
+	UpdatedAt      time.Time `json:"updated_at"`                                                                                      // Timestamp of the latest report in the period.
+}
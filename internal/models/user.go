@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bitback/internal/models/customTypes"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
@@ -8,15 +9,16 @@ import (
 
 // User defines the database model for a user.
 type User struct {
-	ID         uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`   // Unique identifier for the user.
-	Name       string         `json:"name" gorm:"not null"`              // Name of the user.
-	Email      string         `json:"email"`                             // Email address of the user.
-	TelegramID int64          `json:"telegram_id,omitempty"`             // Optional: User's Telegram ID.
-	IsActive   bool           `json:"is_active" gorm:"default:true"`     // Indicates if the user account is active; defaults to true.
-	LastLogin  *time.Time     `json:"last_login,omitempty"`              // Optional: Timestamp of the user's last login.
-	CreatedAt  time.Time      `json:"created_at"`                        // Timestamp of creation.
-	UpdatedAt  time.Time      `json:"updated_at"`                        // Timestamp of the last update.
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // Timestamp for soft deletion.
+	ID         uuid.UUID            `gorm:"type:uuid;primary_key;index:idx_users_created_at_id,priority:2" json:"id"`                                     // Unique identifier for the user.
+	Name       string               `json:"name" gorm:"not null"`                                                                                         // Name of the user.
+	Email      string               `json:"email" gorm:"uniqueIndex:idx_users_email_lower,expression:lower(email),where:deleted_at IS NULL"`              // Email address of the user; unique case-insensitively among non-deleted users.
+	TelegramID int64                `json:"telegram_id,omitempty" gorm:"uniqueIndex:idx_users_telegram_id,where:telegram_id <> 0 AND deleted_at IS NULL"` // Optional: User's Telegram ID; unique among non-deleted users, 0 if absent.
+	IsActive   bool                 `json:"is_active" gorm:"default:true"`                                                                                // Indicates if the user account is active; defaults to true.
+	Role       customTypes.UserRole `json:"role" gorm:"type:varchar(20);not null;default:'user';index"`                                                   // Role of the user (e.g., user, admin); defaults to 'user'.
+	LastLogin  *time.Time           `json:"last_login,omitempty"`                                                                                         // Optional: Timestamp of the user's last login.
+	CreatedAt  time.Time            `json:"created_at" gorm:"index:idx_users_created_at_id,priority:1"`                                                   // Timestamp of creation; indexed with ID for keyset pagination.
+	UpdatedAt  time.Time            `json:"updated_at"`                                                                                                   // Timestamp of the last update.
+	DeletedAt  gorm.DeletedAt       `gorm:"index" json:"deleted_at,omitempty"`                                                                            // Timestamp for soft deletion.
 }
 
 // BeforeCreate is a GORM hook that runs before a new user record is created.
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// CleanupInterval is how often idle buckets should be removed from a MemoryLimiter.
+const CleanupInterval = time.Minute
+
+// bucket is the token bucket of a single client.
+type bucket struct {
+	tokens  float64   // Tokens available at updated.
+	updated time.Time // When tokens was last computed.
+}
+
+// MemoryLimiter is an in-memory token-bucket RateLimiter.
+// Every client gets a bucket holding up to perMinute tokens that refills at perMinute tokens per minute;
+// each request consumes one token. The limits are per process, so replicas do not share them.
+type MemoryLimiter struct {
+	capacity float64 // Maximum number of tokens in a bucket, i.e. the allowed burst.
+	rate     float64 // Tokens added per second.
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryLimiter creates a MemoryLimiter allowing perMinute requests per minute per client.
+// perMinute must be positive.
+func NewMemoryLimiter(perMinute int) *MemoryLimiter {
+	return &MemoryLimiter{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// Allow consumes one token from the bucket of key. If the bucket is empty, it returns false and the time
+// until the next token is available. It never returns an error.
+func (l *MemoryLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.capacity, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait, nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// Cleanup removes the buckets that have refilled completely, as they are equivalent to new ones.
+// It is meant to be run periodically, e.g. every CleanupInterval, and never returns an error.
+func (l *MemoryLimiter) Cleanup(ctx context.Context) error {
+	now := time.Now()
+	refillTime := time.Duration(l.capacity / l.rate * float64(time.Second))
+
+	l.mu.Lock()
+	removed := 0
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= refillTime {
+			delete(l.buckets, key)
+			removed++
+		}
+	}
+	remaining := len(l.buckets)
+	l.mu.Unlock()
+
+	slog.DebugContext(ctx, "MemoryLimiter: removed idle rate-limit buckets", "removed", removed, "remaining", remaining)
+	return nil
+}
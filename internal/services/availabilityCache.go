@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bitback/internal/models/customTypes"
+	"sync"
+	"time"
+)
+
+// cachedAvailability is a host availability summary together with its expiry time.
+type cachedAvailability struct {
+	countries []customTypes.CountryAvailability
+	expiresAt time.Time
+}
+
+// hostAvailabilityCache keeps host availability summaries in memory for a short time, as client apps
+// request them on every launch. Entries are keyed by tier filter. A zero TTL disables caching.
+type hostAvailabilityCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedAvailability
+}
+
+func newHostAvailabilityCache(ttl time.Duration) *hostAvailabilityCache {
+	return &hostAvailabilityCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedAvailability),
+	}
+}
+
+// get returns the cached summary for key if it has not expired yet.
+func (c *hostAvailabilityCache) get(key string) ([]customTypes.CountryAvailability, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.countries, true
+}
+
+// set caches the summary for key for the cache's TTL.
+func (c *hostAvailabilityCache) set(key string, countries []customTypes.CountryAvailability) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cachedAvailability{countries: countries, expiresAt: time.Now().Add(c.ttl)}
+}
@@ -5,6 +5,26 @@ import "github.com/google/uuid"
 const (
 	defaultPageSize = 10
 	maxPageSize     = 100
+
+	maxIdempotencyKeyLength = 128
+
+	// maxUsageMetricLength bounds the length of metered usage metric names.
+	maxUsageMetricLength = 64
+
+	// maxHostSelectionAttempts bounds how many hosts are tried when a selected host cannot produce a valid key.
+	maxHostSelectionAttempts = 3
+
+	// maxHostCandidatesPerCountry bounds how many equally ranked hosts of a country are loaded to pick one at random.
+	maxHostCandidatesPerCountry = 8
+
+	// maxSubscriptionFeedHosts bounds how many hosts are included in a user's subscription feed.
+	maxSubscriptionFeedHosts = 200
+
+	// trojanProtocol is the host protocol trojan keys are issued on.
+	trojanProtocol = "trojan"
+
+	// activationBatchSize bounds how many future-dated subscriptions are activated per activation run.
+	activationBatchSize = 500
 )
 
 // FreeTierUserUUID is a predefined UUID for users accessing free tier keys without registration.
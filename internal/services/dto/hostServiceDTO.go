@@ -1,7 +1,9 @@
 package dto
 
 import (
+	"bitback/internal/models"
 	"bitback/internal/models/customTypes"
+	"time"
 )
 
 // CreateHostInput defines the data required to create a new host at the service layer.
@@ -11,7 +13,7 @@ type CreateHostInput struct {
 	City         string // Optional: The city where the host is located.
 	Address      string // Mandatory: The IP address or domain name of the host.
 	Port         string // Mandatory: The port number for the host service.
-	Protocol     string // Mandatory: The protocol used by the host service (e.g., http, https, tcp).
+	Protocol     string // Mandatory: The protocol used by the host service (e.g., vless, vmess, trojan).
 	Network      string // Optional: The network type (e.g., tcp, ws, grpc); defaults to "tcp" if not specified or handled by service logic.
 	PublicKey    string // Optional: The public key, often used for specific security protocols (e.g., Reality).
 	Flow         string // Optional: Flow control mechanism or specific protocol feature.
@@ -20,8 +22,11 @@ type CreateHostInput struct {
 	SNI          string // Optional: Server Name Indication, used in TLS.
 	Fingerprint  string // Optional: TLS fingerprint or similar identifier.
 	IsPrivate    bool   // Specifies if the host is private; defaults to false.
+	IsFreeTier   bool   // Specifies if the host serves free-tier keys; defaults to false (paid).
+	MaxUsers     int64  // Optional: Capacity of the host in active key assignments; 0 if unknown or unlimited.
 	Region       string // Optional: The geographical or logical region of the host.
 	Provider     string // Optional: The provider or owner of the host infrastructure.
+	Notes        string // Optional: Operator notes or runbook for the host.
 }
 
 // UpdateHostInput defines the data for updating an existing host at the service layer.
@@ -41,27 +46,35 @@ type UpdateHostInput struct {
 	SNI          *string // Server Name Indication.
 	Fingerprint  *string // TLS fingerprint.
 	IsPrivate    *bool   // Specifies if the host is private.
+	IsFreeTier   *bool   // Specifies if the host serves free-tier keys.
+	MaxUsers     *int64  // Capacity of the host in active key assignments; 0 if unknown or unlimited.
 	Region       *string // The geographical or logical region of the host.
 	Provider     *string // The provider or owner of the host infrastructure.
+	Notes        *string // Operator notes or runbook for the host.
 	// Note: IsOnline, Status, and LastCheckedAt are typically updated via separate mechanisms (e.g., monitoring).
 }
 
 // ListHostsServiceParams defines parameters for listing hosts at the service layer.
 // These are subsequently mapped to repository-level parameters.
 type ListHostsServiceParams struct {
-	Page      int
-	PageSize  int
-	Country   *string
-	City      *string
-	Protocol  *string
-	Network   *string // Filter by network type.
-	IsOnline  *bool
-	IsPrivate *bool
-	Status    *customTypes.HostStatus // Filter by host status, using a pointer to allow omitting this filter.
-	HostName  *string                 // Filter by partial host name match.
-	Address   *string                 // Filter by partial address match.
-	SortBy    string                  // Field to sort by (e.g., "created_at", "host_name").
-	SortOrder string                  // Sort order ("asc" or "desc").
+	Page       int
+	PageSize   int
+	Country    *string
+	City       *string
+	Region     *string // Filter by region, case-insensitively.
+	Provider   *string // Filter by provider, case-insensitively.
+	Protocol   *string
+	Network    *string // Filter by network type.
+	IsOnline   *bool
+	IsPrivate  *bool
+	IsFreeTier *bool                   // Filter by tier: true for free hosts, false for paid ones.
+	Status     *customTypes.HostStatus // Filter by host status, using a pointer to allow omitting this filter.
+	HostName   *string                 // Filter by partial host name match.
+	Address    *string                 // Filter by partial address match.
+	SortBy     string                  // Field to sort by (e.g., "created_at", "host_name").
+	SortOrder  string                  // Sort order ("asc" or "desc").
+
+	IncludeDeleted bool // Include soft-deleted hosts; only offered to administrators.
 }
 
 // UpdateHostStatusInput defines the data for specifically updating a host's online status.
@@ -69,3 +82,100 @@ type UpdateHostStatusInput struct {
 	IsOnline bool                   // The new online status.
 	Status   customTypes.HostStatus // The new detailed status; not a pointer as it should be explicitly set.
 }
+
+// HostCheckResult defines the outcome of a single host health check at the service layer.
+type HostCheckResult struct {
+	IsOnline  bool                    // Whether the host was reachable.
+	Status    *customTypes.HostStatus // Optional: New detailed status of the host; the current status is kept if nil.
+	LatencyMs *int                    // Optional: Measured latency in milliseconds.
+	Error     string                  // Optional: Error reported by the check.
+	CheckedAt *time.Time              // Optional: When the check was performed; defaults to now.
+}
+
+// HostUptime summarizes the availability of a host over a time window.
+type HostUptime struct {
+	HostID       uint
+	From         time.Time
+	To           time.Time
+	TotalChecks  int64
+	OnlineChecks int64
+	UptimeRatio  *float64 // OnlineChecks divided by TotalChecks; nil when no checks were recorded in the window.
+}
+
+// HostUtilization summarizes the load and current health of a host.
+type HostUtilization struct {
+	HostID             uint
+	CurrentUsers       int64
+	MaxUsers           int64
+	UtilizationPercent *float64 // CurrentUsers as a percentage of MaxUsers; nil when the capacity is unknown (MaxUsers is 0).
+	IsOnline           bool
+	Status             customTypes.HostStatus
+	LatencyMs          *int
+	LastCheckedAt      *time.Time
+}
+
+// Outcomes of a single entry in a bulk host import.
+const (
+	BulkHostStatusCreated   = "created"   // The host was created.
+	BulkHostStatusDuplicate = "duplicate" // The host already exists or appears earlier in the same batch; skipped.
+	BulkHostStatusInvalid   = "invalid"   // The entry failed validation; skipped.
+	BulkHostStatusRejected  = "rejected"  // The entry was valid, but an atomic batch was rejected because of other entries.
+)
+
+// BulkHostResult describes the outcome of one entry of a bulk host import.
+type BulkHostResult struct {
+	Index  int          // Position of the entry in the submitted batch.
+	Status string       // One of the BulkHostStatus* constants.
+	Host   *models.Host // The created host; set only when Status is BulkHostStatusCreated.
+	Error  string       // Reason the entry was skipped; empty when created.
+}
+
+// BulkAddHostsResult summarizes a bulk host import.
+type BulkAddHostsResult struct {
+	Results    []BulkHostResult // Per-entry outcomes, in the order the entries were submitted.
+	Created    int              // Number of hosts created.
+	Duplicates int              // Number of entries skipped as duplicates.
+	Invalid    int              // Number of entries skipped as invalid.
+	Committed  bool             // Whether the valid entries were persisted; false when an atomic batch was rejected.
+}
+
+// ImportHostInput defines one host of a configuration bundle being restored.
+// Hosts are matched to existing ones by address, port, protocol and network.
+type ImportHostInput struct {
+	CreateHostInput
+	Status customTypes.HostStatus // Optional: Detailed status; applied only when the host is created.
+}
+
+// Outcomes of a single entry in a host bundle import.
+const (
+	ImportHostStatusCreated   = "created"   // No matching host existed; it was created.
+	ImportHostStatusUpdated   = "updated"   // A matching host existed and its configuration was updated.
+	ImportHostStatusUnchanged = "unchanged" // A matching host existed with the same configuration.
+	ImportHostStatusInvalid   = "invalid"   // The entry failed validation; nothing was imported.
+)
+
+// ImportHostResult describes the outcome of one entry of a host bundle import.
+type ImportHostResult struct {
+	Index  int    // Position of the entry in the bundle.
+	Status string // One of the ImportHostStatus* constants.
+	HostID uint   // ID of the created or matched host; zero when invalid.
+	Error  string // Reason the entry is invalid; empty otherwise.
+}
+
+// ImportHostsResult summarizes a host bundle import.
+type ImportHostsResult struct {
+	Results   []ImportHostResult // Per-entry outcomes, in bundle order.
+	Created   int                // Number of hosts created.
+	Updated   int                // Number of existing hosts updated.
+	Unchanged int                // Number of existing hosts left as they were.
+	Invalid   int                // Number of invalid entries.
+	Committed bool               // Whether the bundle was applied; false if any entry was invalid.
+}
+
+// HostAvailabilityReport lists the hosts available for key generation per country, with overall totals.
+// A host is available when it is online and has the 'active' status.
+type HostAvailabilityReport struct {
+	Countries []customTypes.CountryHostAvailability // Ordered by country.
+	TotalFree int64
+	TotalPaid int64
+}
@@ -1,7 +1,160 @@
 package dto
 
+import (
+	"bitback/internal/models/customTypes"
+	"time"
+)
+
+// Tiers of the host a key was issued on.
+const (
+	ServedTierPaid = "paid"
+	ServedTierFree = "free"
+)
+
+// HostPreferences are the client's preferences for the host a key is issued on. Empty fields express no preference.
+// When no host matches them all, the preferences are relaxed in a fixed order; see KeyService.
+type HostPreferences struct {
+	Country      string // Country code.
+	Network      string // Transport the client supports, e.g. tcp, ws or grpc.
+	SecurityType string // Security type the client supports, e.g. none, tls or reality.
+
+	AddressFamily customTypes.AddressFamily // IP version the client can connect over; hosts of the other family are only used as a fallback.
+}
+
+// KeyHost describes the host a key was issued on, so that clients can show where they connect to.
+type KeyHost struct {
+	Country       string
+	Network       string
+	SecurityType  string
+	AddressFamily customTypes.AddressFamily
+}
+
 // GenerateUserKeyResult holds the result of generating a key for a user.
 type GenerateUserKeyResult struct {
 	VlessKey              string
+	Config                VlessConfig // The structured components the VLESS key was built from.
+	Host                  KeyHost     // The attributes of the host the key was issued on.
+	HasActiveSubscription bool
+	ExpiresAt             *time.Time // End date of the user's active subscription; nil if the user has none.
+	ServedTier            string     // Tier of the host the key was issued on; one of the ServedTier* constants.
+	Degraded              bool       // Whether a subscribed user was served a free host because no paid host was available.
+}
+
+// CountryKey holds a key issued on a representative host of one country.
+type CountryKey struct {
+	VlessKey string
+	HostID   uint
+	Host     KeyHost // The attributes of the host the key was built for.
+}
+
+// MultiCountryKeysResult holds the keys generated for a user across countries.
+type MultiCountryKeysResult struct {
+	Keys                  []CountryKey // One key per country, ordered by country.
+	HasActiveSubscription bool
+	ExpiresAt             *time.Time // End date of the user's active subscription; nil if the user has none.
+}
+
+// SubscriptionFeed holds the keys of a user's subscription feed, one per eligible host.
+type SubscriptionFeed struct {
+	VlessKeys             []string
+	HasActiveSubscription bool
+	ExpiresAt             *time.Time // End date of the user's active subscription; nil if the user has none.
+}
+
+// KeyGenerationBucket holds the number of keys generated in one bucket of a KeyGenerationRate.
+type KeyGenerationBucket struct {
+	Start    time.Time // Start of the bucket.
+	FreeKeys int64     // Anonymous free keys.
+	UserKeys int64     // Keys generated for a user.
+}
+
+// KeyGenerationRate summarizes the keys generated over a time window.
+type KeyGenerationRate struct {
+	From      time.Time
+	To        time.Time
+	Bucket    time.Duration         // Width of each bucket.
+	Buckets   []KeyGenerationBucket // Every bucket of the window, oldest first.
+	TotalFree int64
+	TotalUser int64
+}
+
+// VlessConfig holds the components of a VLESS key as they are encoded in the URL.
+type VlessConfig struct {
+	Address     string // Host address (IP or domain).
+	Port        string // Host port.
+	UUID        string // VLESS user ID.
+	Network     string // Transport type (e.g., tcp, ws, grpc); "type" in the URL.
+	Security    string // Security type (e.g., none, tls, reality).
+	SNI         string // Server Name Indication.
+	Fingerprint string // TLS fingerprint; "fp" in the URL.
+	PublicKey   string // Reality public key; "pbk" in the URL.
+	ShortID     string // Reality short ID; "sid" in the URL.
+	Flow        string // Flow control mechanism.
+	Remarks     string // Key name; the URL fragment.
+}
+
+// TrojanConfig holds the components of a trojan key as they are encoded in the URL.
+type TrojanConfig struct {
+	Address     string // Host address (IP or domain).
+	Port        string // Host port.
+	Password    string // The user's trojan password.
+	Network     string // Transport type (e.g., tcp, ws, grpc); "type" in the URL.
+	Security    string // Security type; trojan runs over TLS unless the host says otherwise.
+	SNI         string // Server Name Indication.
+	Fingerprint string // TLS fingerprint; "fp" in the URL.
+	Remarks     string // Key name; the URL fragment.
+}
+
+// GenerateTrojanKeyResult holds the result of generating a trojan key for a user.
+type GenerateTrojanKeyResult struct {
+	TrojanKey             string
+	Config                TrojanConfig // The structured components the trojan key was built from.
+	Host                  KeyHost      // The attributes of the host the key was issued on.
 	HasActiveSubscription bool
+	ExpiresAt             *time.Time // End date of the user's active subscription; nil if the user has none.
+	ServedTier            string     // Tier of the host the key was issued on; one of the ServedTier* constants.
+	Degraded              bool       // Whether a subscribed user was served a free host because no paid host was available.
+}
+
+// CurrentUserKeyResult holds the key reconstructed from a user's most recent key assignment.
+type CurrentUserKeyResult struct {
+	VlessKey   string
+	Remarks    string
+	HostID     uint
+	AssignedAt time.Time
+}
+
+// ReassignHostInput defines the parameters for moving a user's key to another host.
+type ReassignHostInput struct {
+	TargetHostID *uint   // Optional: Host to move the user to; selected automatically if nil.
+	Country      *string // Optional: Country for automatic selection; defaults to the current host's country.
+	Remarks      *string // Optional: Remarks for the new key; defaults to the current key's remarks.
+}
+
+// ReassignHostResult holds the new key issued when a user is moved to another host.
+type ReassignHostResult struct {
+	VlessKey       string
+	Config         VlessConfig
+	HostID         uint   // The host the user was moved to.
+	PreviousHostID uint   // The host of the user's most recent active key before the move.
+	RevokedCount   int    // Number of active key assignments that were revoked.
+	Remarks        string // Remarks embedded in the new key.
+}
+
+// FreeKeyResult holds a generated free key.
+type FreeKeyResult struct {
+	VlessKey  string
+	HostID    uint       // The free-tier host the key was issued on.
+	Host      KeyHost    // The attributes of the host the key was issued on.
+	ExpiresAt *time.Time // When the key is considered expired per the configured free key TTL; nil if no TTL is set.
+}
+
+// ConversionFunnel reports how many users issued a free-tier key within a period went on to subscribe and pay.
+type ConversionFunnel struct {
+	From           time.Time
+	To             time.Time
+	FreeKeyUsers   int64   // Users issued a free-tier key within the period.
+	Subscribed     int64   // Of those, users who created a subscription after their first free-tier key.
+	Paid           int64   // Of those, users with such a subscription that is paid.
+	ConversionRate float64 // Paid divided by FreeKeyUsers; 0 when no free-tier keys were issued to users.
 }
@@ -0,0 +1,15 @@
+package dto
+
+import "bitback/internal/models"
+
+// OnboardInput defines a new user and the first subscription to create for them.
+type OnboardInput struct {
+	User         CreateUserInput
+	Subscription CreateSubscriptionInput // UserID and IdempotencyKey are ignored; the subscription is created for the new user.
+}
+
+// OnboardResult holds the user and subscription created by onboarding.
+type OnboardResult struct {
+	User         *models.User
+	Subscription *models.Subscription
+}
@@ -0,0 +1,28 @@
+package dto
+
+import "bitback/internal/models/customTypes"
+
+// CreatePlanInput defines the data required to create a new plan at the service layer.
+type CreatePlanInput struct {
+	Name          string                   // The unique name of the plan.
+	Description   string                   // Optional: A description of the plan.
+	DurationUnit  customTypes.DurationUnit // The unit of the plan duration (e.g., day, month, year).
+	DurationValue int                      // The value of the plan duration.
+	Price         float64                  // The price of the plan.
+	Currency      string                   // Optional: The currency for the price (e.g., "USD").
+	IsActive      *bool                    // Optional: Whether the plan can be used for new subscriptions; defaults to true.
+	IsFreeTier    bool                     // Whether the plan grants free tier access only.
+}
+
+// UpdatePlanInput defines the data for updating an existing plan at the service layer.
+// Fields are pointers to distinguish between zero values and fields that were not provided for update.
+type UpdatePlanInput struct {
+	Name          *string
+	Description   *string
+	DurationUnit  *customTypes.DurationUnit
+	DurationValue *int
+	Price         *float64
+	Currency      *string
+	IsActive      *bool
+	IsFreeTier    *bool
+}
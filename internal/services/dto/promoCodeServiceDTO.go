@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+// CreatePromoCodeInput defines the data required to create a new promo code at the service layer.
+// Exactly one of DiscountPercent and DiscountAmount must be set.
+type CreatePromoCodeInput struct {
+	Code            string     // The code customers enter; normalized to upper case.
+	DiscountPercent float64    // Optional: Percentage taken off the price (0-100].
+	DiscountAmount  float64    // Optional: Fixed amount taken off the price.
+	ValidFrom       *time.Time // Optional: Start of the validity window.
+	ValidUntil      *time.Time // Optional: End of the validity window.
+	MaxUses         *int       // Optional: Maximum number of redemptions; unlimited if nil.
+	IsActive        *bool      // Optional: Whether the code can be redeemed; defaults to true.
+}
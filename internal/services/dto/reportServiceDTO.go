@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"bitback/internal/models/customTypes"
+	"time"
+)
+
+// Overview summarizes users, subscriptions, hosts and key generation for the admin dashboard.
+type Overview struct {
+	GeneratedAt                     time.Time
+	Users                           customTypes.UserCounts
+	UsersWithActivePaidSubscription int64
+	SubscriptionsByPaymentStatus    []customTypes.PaymentStatusCount    // Ordered by payment status.
+	ActiveSubscriptionsByPlan       []customTypes.PlanSubscriptionCount // Largest first.
+	HostsByStatus                   []customTypes.HostStatusCount       // Ordered by status.
+	HostsByCountry                  []customTypes.CountryHostCount      // Largest first.
+	KeysGeneratedToday              int64                               // Keys generated since midnight UTC.
+}
@@ -9,15 +9,29 @@ import (
 
 // CreateSubscriptionInput defines the data required to create a new subscription at the service layer.
 type CreateSubscriptionInput struct {
-	UserID        uuid.UUID                // The ID of the user for whom the subscription is being created.
-	PlanName      string                   // The name of the subscription plan.
-	DurationUnit  customTypes.DurationUnit // The unit of measurement for the subscription duration (e.g., day, month, year).
-	DurationValue int                      // The value of the subscription duration.
-	StartDate     time.Time                // The start date of the subscription can be in the future.
-	Price         *float64                 // Optional: The price of the subscription.
-	Currency      *string                  // Optional: The currency for the price (e.g., "USD").
-	PaymentStatus string                   // The status of the payment (e.g., "paid", "pending", "failed").
-	AutoRenew     bool                     // Flag indicating if the subscription should auto-renew.
+	UserID         uuid.UUID                // The ID of the user for whom the subscription is being created.
+	PlanID         *uint                    // Optional: The catalog plan to subscribe to; its name, duration and price take precedence over the fields below.
+	PlanName       string                   // The name of the subscription plan; required unless PlanID is set.
+	DurationUnit   customTypes.DurationUnit // The unit of measurement for the subscription duration (e.g., day, month, year).
+	DurationValue  int                      // The value of the subscription duration.
+	StartDate      time.Time                // The start date of the subscription can be in the future.
+	Price          *float64                 // Optional: The price of the subscription; always the plan's price unless an administrator creates the subscription.
+	Currency       *string                  // Optional: The currency for the price (e.g., "USD"); inferred from the country of the user's current host when omitted.
+	PaymentStatus  string                   // The status of the payment (e.g., "paid", "pending", "failed"); always "pending" unless an administrator creates the subscription.
+	AutoRenew      bool                     // Flag indicating if the subscription should auto-renew.
+	PromoCode      *string                  // Optional: Promo code whose discount is applied to the price; its usage is recorded with the subscription.
+	IdempotencyKey *string                  // Optional: Client-supplied key scoped to the user; repeated requests with the same key return the original subscription.
+	ActorUserID    *uuid.UUID               // Optional: The authenticated user making the request, recorded in the subscription's history.
+	ActorRole      customTypes.UserRole     // The role of the user making the request; only administrators may choose the price, currency and payment status.
+}
+
+// ApplyPaymentInput defines a completed payment reported by the payment provider.
+type ApplyPaymentInput struct {
+	SubscriptionID    uuid.UUID
+	ExternalPaymentID string    // Provider's ID of the payment; a payment is applied at most once.
+	Amount            float64   // Amount paid, in Currency.
+	Currency          string    // Optional: Currency of the payment; must match the subscription's if both are set.
+	PaidAt            time.Time // Optional: When the provider recorded the payment.
 }
 
 // UpdateSubscriptionInput defines the data that can be updated for an existing subscription.
@@ -38,9 +52,77 @@ type ExpiringSubscriptionInfo struct {
 	AutoRenew     bool                     `json:"auto_renew"`
 }
 
+// RenewalRunSummary reports the outcome of one run of the auto-renewal job.
+type RenewalRunSummary struct {
+	WindowStart time.Time // Subscriptions ending between WindowStart and WindowEnd were considered.
+	WindowEnd   time.Time
+	Candidates  int // Subscriptions found that were due for renewal.
+	Renewed     int // Subscriptions renewed by this run.
+	Skipped     int // Candidates not renewed, because another run renewed them first or because of an error.
+	Deactivated int // Renewals deactivated because they were not paid within the grace period.
+}
+
+// ChurnReport summarizes subscription churn over a period.
+// Churned subscriptions are those that ended within the period without being renewed.
+type ChurnReport struct {
+	From          time.Time
+	To            time.Time
+	ActiveAtStart int64   // Paid subscriptions active at the start of the period.
+	Renewed       int64   // Subscriptions that ended within the period and were renewed.
+	Expired       int64   // Subscriptions that ended within the period with auto-renewal enabled but were not renewed.
+	Cancelled     int64   // Subscriptions that ended within the period after auto-renewal was disabled.
+	Churned       int64   // Expired plus cancelled subscriptions.
+	ChurnRate     float64 // Churned divided by ActiveAtStart; 0 when nothing was active at the start.
+}
+
+// UserSubscriptionStatus reports whether a user currently has an active subscription.
+type UserSubscriptionStatus struct {
+	HasActiveSubscription bool
+	ActivePlans           []string // Distinct plan names of the active subscriptions, ordered by name.
+}
+
+// SubscriptionsByCurrency reports the subscriptions created within a period and their revenue per currency.
+type SubscriptionsByCurrency struct {
+	From          time.Time
+	To            time.Time
+	Subscriptions int64                                    // Subscriptions created within the period, in any currency.
+	Currencies    []customTypes.SubscriptionCurrencyTotals // Totals per currency, ordered by currency code.
+}
+
 // UserWithExpiringSubscriptions groups a user with their list of subscriptions that are about to expire.
 // This is used for reporting purposes.
 type UserWithExpiringSubscriptions struct {
 	User                  models.User
 	ExpiringSubscriptions []ExpiringSubscriptionInfo
 }
+
+// ListUserSubscriptionsParams defines the parameters for listing a user's subscriptions with filtering and sorting.
+type ListUserSubscriptionsParams struct {
+	Page          int
+	PageSize      int
+	Status        *customTypes.SubscriptionListStatus // Filter by derived lifecycle status (active, expired or cancelled).
+	PaymentStatus *string                             // Filter by payment status.
+	PlanName      *string                             // Filter by plan name, case-insensitively.
+	SortBy        string                              // Field to sort by ("created_at", "start_date" or "end_date").
+	SortOrder     string                              // Sort order ("asc" or "desc").
+}
+
+// RecordUsageInput defines a usage increment reported for a metered subscription.
+type RecordUsageInput struct {
+	Metric     string     // Name of the metered quantity; normalized to lower case.
+	Quantity   int64      // Amount to add; must be positive.
+	OccurredAt *time.Time // Optional: When the usage happened, which selects the billing period; defaults to now.
+}
+
+// UsagePeriod summarizes the usage of a subscription in one billing period.
+type UsagePeriod struct {
+	PeriodStart time.Time        // Inclusive.
+	PeriodEnd   time.Time        // Exclusive.
+	Metrics     map[string]int64 // Total quantity per metric.
+}
+
+// UsageReport summarizes the usage of a subscription per billing period, oldest period first.
+type UsageReport struct {
+	SubscriptionID uuid.UUID
+	Periods        []UsagePeriod
+}
@@ -1,10 +1,13 @@
 package dto
 
+import "time"
+
 // CreateUserInput defines the data required for creating a user at the service layer.
 type CreateUserInput struct {
 	Name       string // The name of the user.
 	Email      string // The email address of the user.
 	TelegramID int64  // Optional: The user's Telegram ID.
+	SkipTrial  bool   // Do not create a trial subscription even if trials are enabled.
 }
 
 // UpdateUserInput defines the data for updating an existing user at the service layer.
@@ -16,3 +19,18 @@ type UpdateUserInput struct {
 	TelegramID *int64  // The new Telegram ID of the user.
 	IsActive   *bool   // The new active status of the user.
 }
+
+// ListUsersServiceParams defines the parameters for listing users with filtering and sorting.
+type ListUsersServiceParams struct {
+	Page          int
+	PageSize      int
+	Query         *string    // Case-insensitive partial match on the name or email.
+	IsActive      *bool      // Filter by active status.
+	HasTelegram   *bool      // Filter by whether a Telegram ID is set.
+	CreatedAfter  *time.Time // Only users created at or after this time.
+	CreatedBefore *time.Time // Only users created before this time.
+	SortBy        string     // Field to sort by ("created_at", "name", "email" or "last_login").
+	SortOrder     string     // Sort order ("asc" or "desc").
+
+	IncludeDeleted bool // Include soft-deleted users.
+}
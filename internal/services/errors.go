@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// Error categories. Service methods attach one of them to the errors they return,
+// so handlers can pick a response status with errors.Is instead of matching message text.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("not authorized")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnavailable  = errors.New("unavailable") // A feature or resource the request needs is disabled or exhausted; retrying later may succeed.
+
+	// ErrRequestCanceled and ErrRequestTimeout mark errors caused by the request's context
+	// rather than by the data or the database.
+	ErrRequestCanceled = errors.New("request canceled")
+	ErrRequestTimeout  = errors.New("request timed out")
+)
+
+// Host validation errors. They are wrapped with details about the offending value,
+// so callers should match them with errors.Is.
+var (
+	ErrInvalidHostPort     = invalid(errors.New("invalid host port"))
+	ErrInvalidHostProtocol = invalid(errors.New("invalid host protocol"))
+	ErrInvalidHostNetwork  = invalid(errors.New("invalid host network"))
+)
+
+// ErrInvalidSubscriptionPeriod is returned when a subscription's end date would not be after its start date.
+// It guards against regressions in date calculation rather than any expected client input.
+var ErrInvalidSubscriptionPeriod = invalid(errors.New("invalid subscription period: end date must be after start date"))
+
+// categorizedError tags an error with one of the error categories above
+// without changing its message.
+type categorizedError struct {
+	category error
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap exposes both the category and the original error to errors.Is and errors.As.
+func (e *categorizedError) Unwrap() []error {
+	return []error{e.category, e.err}
+}
+
+func notFound(err error) error {
+	return &categorizedError{category: ErrNotFound, err: err}
+}
+
+func conflict(err error) error {
+	return &categorizedError{category: ErrConflict, err: err}
+}
+
+func unauthorized(err error) error {
+	return &categorizedError{category: ErrUnauthorized, err: err}
+}
+
+func invalid(err error) error {
+	return &categorizedError{category: ErrValidation, err: err}
+}
+
+func unavailable(err error) error {
+	return &categorizedError{category: ErrUnavailable, err: err}
+}
+
+// contextAware tags err with ErrRequestCanceled or ErrRequestTimeout when it was caused by
+// the cancellation or deadline of a context, and returns it unchanged otherwise.
+func contextAware(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return &categorizedError{category: ErrRequestCanceled, err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &categorizedError{category: ErrRequestTimeout, err: err}
+	}
+	return err
+}
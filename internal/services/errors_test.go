@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestErrorCategories(t *testing.T) {
+	cause := errors.New("user 42 not found")
+	categories := []error{ErrNotFound, ErrConflict, ErrUnauthorized, ErrValidation, ErrUnavailable, ErrRequestCanceled, ErrRequestTimeout}
+
+	tests := []struct {
+		name         string
+		err          error
+		wantCategory error // nil if the error must carry no category.
+		wantCause    error
+	}{
+		{name: "not found", err: notFound(cause), wantCategory: ErrNotFound, wantCause: cause},
+		{name: "conflict", err: conflict(cause), wantCategory: ErrConflict, wantCause: cause},
+		{name: "unauthorized", err: unauthorized(cause), wantCategory: ErrUnauthorized, wantCause: cause},
+		{name: "invalid", err: invalid(cause), wantCategory: ErrValidation, wantCause: cause},
+		{name: "unavailable", err: unavailable(cause), wantCategory: ErrUnavailable, wantCause: cause},
+		{name: "wrapped again", err: fmt.Errorf("create subscription: %w", notFound(cause)), wantCategory: ErrNotFound, wantCause: cause},
+		{name: "host validation sentinel", err: fmt.Errorf("port 0: %w", ErrInvalidHostPort), wantCategory: ErrValidation, wantCause: ErrInvalidHostPort},
+		{name: "canceled context", err: contextAware(fmt.Errorf("query: %w", context.Canceled)), wantCategory: ErrRequestCanceled, wantCause: context.Canceled},
+		{name: "expired context", err: contextAware(fmt.Errorf("query: %w", context.DeadlineExceeded)), wantCategory: ErrRequestTimeout, wantCause: context.DeadlineExceeded},
+		{name: "context aware database error", err: contextAware(cause), wantCause: cause},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, category := range categories {
+				if got, want := errors.Is(tt.err, category), category == tt.wantCategory; got != want {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, category, got, want)
+				}
+			}
+			if !errors.Is(tt.err, tt.wantCause) {
+				t.Errorf("error %v does not wrap %v", tt.err, tt.wantCause)
+			}
+			// Categories must not change the message reported to clients.
+			if !strings.HasSuffix(tt.err.Error(), tt.wantCause.Error()) {
+				t.Errorf("error message %q does not end with the cause %q", tt.err.Error(), tt.wantCause.Error())
+			}
+		})
+	}
+}
+
+func TestServicesReportDoneContexts(t *testing.T) {
+	user := models.User{ID: uuid.New(), Name: "Alice", Role: customTypes.RoleUser}
+	host := models.Host{ID: 7, Address: "de1.example.com", Port: "443", Protocol: "vless"}
+	sub := models.Subscription{ID: uuid.New(), UserID: user.ID}
+
+	userSvc := NewUserService(newFakeUserRepo(user), newFakeSubRepo(), fakeTx{}, &config.Config{})
+	hostSvc, _ := newTestHostService(t, nil, host)
+	subSvc, subDeps, _ := newTestSubscriptionService(t, nil)
+	subDeps.subs.subs[sub.ID] = &sub
+
+	calls := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{name: "user service", call: func(ctx context.Context) error {
+			_, err := userSvc.GetUser(ctx, user.ID)
+			return err
+		}},
+		{name: "host service", call: func(ctx context.Context) error {
+			_, err := hostSvc.GetHostByID(ctx, host.ID)
+			return err
+		}},
+		{name: "subscription service", call: func(ctx context.Context) error {
+			_, err := subSvc.GetSubscriptionByID(ctx, sub.ID, user.ID, user.Role)
+			return err
+		}},
+	}
+	contexts := []struct {
+		name         string
+		ctx          func() context.Context
+		wantCategory error
+		wantCause    error
+	}{
+		{name: "canceled", wantCategory: ErrRequestCanceled, wantCause: context.Canceled, ctx: func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}},
+		{name: "deadline exceeded", wantCategory: ErrRequestTimeout, wantCause: context.DeadlineExceeded, ctx: func() context.Context {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			t.Cleanup(cancel)
+			return ctx
+		}},
+	}
+	for _, call := range calls {
+		// The same calls succeed while the context is live.
+		if err := call.call(context.Background()); err != nil {
+			t.Fatalf("%s: error = %v with a live context", call.name, err)
+		}
+		for _, tc := range contexts {
+			t.Run(call.name+"/"+tc.name, func(t *testing.T) {
+				err := call.call(tc.ctx())
+				if !errors.Is(err, tc.wantCategory) || !errors.Is(err, tc.wantCause) {
+					t.Fatalf("error = %v, want %v wrapping %v", err, tc.wantCategory, tc.wantCause)
+				}
+				if errors.Is(err, ErrNotFound) {
+					t.Errorf("error = %v, must not be reported as not found", err)
+				}
+			})
+		}
+	}
+}
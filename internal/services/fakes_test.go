@@ -0,0 +1,1325 @@
+package services
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// The fakes below keep their records in memory and implement only the repository methods the tests exercise;
+// calling any other method panics through the nil embedded interface.
+
+// fakeTx runs the function without a transaction, as the fakes have no storage to roll back.
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// rollbackTx runs the function in a transaction over the user and subscription fakes: if the function fails,
+// the users, subscriptions and subscription events are restored to their state before it ran, as a rollback
+// would. Nested calls join the outer transaction.
+type rollbackTx struct {
+	users  *fakeUserRepo
+	subs   *fakeSubRepo
+	active bool
+}
+
+func (tx *rollbackTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx.active {
+		return fn(ctx)
+	}
+	users, subs, events := tx.snapshot()
+	tx.active = true
+	err := fn(ctx)
+	tx.active = false
+	if err != nil {
+		tx.users.mu.Lock()
+		tx.users.users = users
+		tx.users.mu.Unlock()
+		tx.subs.mu.Lock()
+		tx.subs.subs, tx.subs.events = subs, events
+		tx.subs.mu.Unlock()
+	}
+	return err
+}
+
+// snapshot copies the stored users, subscriptions and subscription events.
+func (tx *rollbackTx) snapshot() (map[uuid.UUID]*models.User, map[uuid.UUID]*models.Subscription, []models.SubscriptionEvent) {
+	tx.users.mu.Lock()
+	users := make(map[uuid.UUID]*models.User, len(tx.users.users))
+	for id, user := range tx.users.users {
+		copied := *user
+		users[id] = &copied
+	}
+	tx.users.mu.Unlock()
+	tx.subs.mu.Lock()
+	defer tx.subs.mu.Unlock()
+	subs := make(map[uuid.UUID]*models.Subscription, len(tx.subs.subs))
+	for id, sub := range tx.subs.subs {
+		copied := *sub
+		subs[id] = &copied
+	}
+	return users, subs, slices.Clone(tx.subs.events)
+}
+
+// fakeUserRepo is an in-memory interfaces.UserRepository.
+type fakeUserRepo struct {
+	interfaces.UserRepository
+
+	mu    sync.Mutex
+	users map[uuid.UUID]*models.User
+
+	beforeCreate func() // Optional: called by Create before the insert, e.g. to register a racing user.
+
+	assignments []models.KeyAssignment // Key assignments with their hosts, for the queries that join them.
+}
+
+func newFakeUserRepo(users ...models.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: make(map[uuid.UUID]*models.User)}
+	for i := range users {
+		r.users[users[i].ID] = &users[i]
+	}
+	return r
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err // As a query run through WithContext would.
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) GetByTelegramID(_ context.Context, telegramID int64) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.TelegramID == telegramID {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.GetByID(ctx, id)
+}
+
+// Create enforces the unique indexes on lower(email) and telegram_id like the database does.
+func (r *fakeUserRepo) Create(_ context.Context, user *models.User) error {
+	if r.beforeCreate != nil {
+		r.beforeCreate()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if user.Email != "" && strings.EqualFold(existing.Email, user.Email) {
+			return fmt.Errorf("failed to create user: %w", interfaces.ErrEmailTaken)
+		}
+		if user.TelegramID != 0 && existing.TelegramID == user.TelegramID {
+			return fmt.Errorf("failed to create user: %w", interfaces.ErrTelegramIDTaken)
+		}
+	}
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+// GetByEmail matches emails ignoring case, like the lower(email) lookup.
+func (r *fakeUserRepo) GetByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// CountSummary counts the non-deleted users like the FILTER query does.
+func (r *fakeUserRepo) CountSummary(_ context.Context, now time.Time) (*customTypes.UserCounts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var counts customTypes.UserCounts
+	for _, user := range r.users {
+		if user.DeletedAt.Valid {
+			continue
+		}
+		counts.Total++
+		if user.IsActive {
+			counts.Active++
+		}
+		if !user.CreatedAt.Before(now.AddDate(0, 0, -7)) {
+			counts.NewLast7Days++
+		}
+		if !user.CreatedAt.Before(now.AddDate(0, 0, -30)) {
+			counts.NewLast30Days++
+		}
+	}
+	return &counts, nil
+}
+
+// ListWithActiveKeyInCountry returns each user with an active assignment on a non-deleted host in the country
+// (case-insensitive) once, oldest first, paged by offset and limit.
+func (r *fakeUserRepo) ListWithActiveKeyInCountry(_ context.Context, country string, offset, limit int) ([]models.User, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []models.User
+	for _, user := range r.users {
+		if user.DeletedAt.Valid {
+			continue
+		}
+		if slices.ContainsFunc(r.assignments, func(a models.KeyAssignment) bool {
+			return a.UserID == user.ID && a.IsActive && !a.Host.DeletedAt.Valid && strings.EqualFold(a.Host.Country, country)
+		}) {
+			users = append(users, *user)
+		}
+	}
+	slices.SortFunc(users, func(a, b models.User) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	total := int64(len(users))
+	users = users[min(offset, len(users)):]
+	return users[:min(limit, len(users))], total, nil
+}
+
+// fakeSubRepo is an in-memory interfaces.SubscriptionRepository.
+type fakeSubRepo struct {
+	interfaces.SubscriptionRepository
+
+	mu     sync.Mutex
+	subs   map[uuid.UUID]*models.Subscription
+	events []models.SubscriptionEvent
+	usage  []models.SubscriptionUsage
+
+	beforeCreate func()             // Optional: called by Create before the insert, e.g. to hold concurrent requests at the same point.
+	promoCodes   *fakePromoCodeRepo // Optional: the codes redeemed by CreateWithPromoCode.
+	createErr    error              // Optional: returned by Create instead of inserting the subscription.
+	updateErr    error              // Optional: returned by Update instead of storing the change.
+}
+
+func newFakeSubRepo(subs ...models.Subscription) *fakeSubRepo {
+	r := &fakeSubRepo{subs: make(map[uuid.UUID]*models.Subscription)}
+	for i := range subs {
+		r.subs[subs[i].ID] = &subs[i]
+	}
+	return r
+}
+
+// Create enforces the unique index on (user_id, idempotency_key) like the database does.
+func (r *fakeSubRepo) Create(_ context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
+	if r.beforeCreate != nil {
+		r.beforeCreate()
+	}
+	if r.createErr != nil {
+		return r.createErr
+	}
+	return r.insert(subscription, event)
+}
+
+// CreateWithPromoCode redeems the promo code like the SQL repository does before inserting the subscription,
+// and returns gorm.ErrRecordNotFound without inserting it if the code is no longer redeemable.
+func (r *fakeSubRepo) CreateWithPromoCode(_ context.Context, subscription *models.Subscription, promoCodeID uint, event *models.SubscriptionEvent) error {
+	if r.beforeCreate != nil {
+		r.beforeCreate()
+	}
+	if !r.promoCodes.redeem(promoCodeID, time.Now()) {
+		return gorm.ErrRecordNotFound
+	}
+	subscription.PromoCodeID = &promoCodeID
+	return r.insert(subscription, event)
+}
+
+func (r *fakeSubRepo) insert(subscription *models.Subscription, event *models.SubscriptionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if subscription.IdempotencyKey != nil {
+		for _, sub := range r.subs {
+			if sub.UserID == subscription.UserID && sub.IdempotencyKey != nil && *sub.IdempotencyKey == *subscription.IdempotencyKey {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+	}
+	subscription.ID = uuid.New()
+	copied := *subscription
+	r.subs[subscription.ID] = &copied
+	if event != nil {
+		event.SubscriptionID = subscription.ID
+		r.events = append(r.events, *event)
+	}
+	return nil
+}
+
+func (r *fakeSubRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err // As a query run through WithContext would.
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (r *fakeSubRepo) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *fakeSubRepo) GetByExternalPaymentID(_ context.Context, externalPaymentID string) (*models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		if sub.ExternalPaymentID != nil && *sub.ExternalPaymentID == externalPaymentID {
+			copied := *sub
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeSubRepo) GetByUserIDAndIdempotencyKey(_ context.Context, userID uuid.UUID, idempotencyKey string) (*models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		if sub.UserID == userID && sub.IdempotencyKey != nil && *sub.IdempotencyKey == idempotencyKey {
+			copied := *sub
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetActiveByUserID returns the user's active subscription that ends last.
+func (r *fakeSubRepo) GetActiveByUserID(_ context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active *models.Subscription
+	for _, sub := range r.subs {
+		if sub.UserID == userID && sub.IsActive && sub.EndDate.After(time.Now()) && (active == nil || sub.EndDate.After(active.EndDate)) {
+			active = sub
+		}
+	}
+	if active == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *active
+	return &copied, nil
+}
+
+func (r *fakeSubRepo) CheckUserActiveSubscription(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := r.GetActiveByUserID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// subscriptionInTerm reports whether sub is a live, active subscription whose term contains now.
+func subscriptionInTerm(sub *models.Subscription, now time.Time) bool {
+	return !sub.DeletedAt.Valid && sub.IsActive && !sub.StartDate.After(now) && sub.EndDate.After(now)
+}
+
+// CountUsersWithActivePaidSubscription counts the distinct users with a paid subscription in its term.
+func (r *fakeSubRepo) CountUsersWithActivePaidSubscription(_ context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make(map[uuid.UUID]bool)
+	for _, sub := range r.subs {
+		if subscriptionInTerm(sub, now) && sub.PaymentStatus == "paid" {
+			users[sub.UserID] = true
+		}
+	}
+	return int64(len(users)), nil
+}
+
+// CountByPaymentStatus counts the live subscriptions per payment status, ordered by payment status.
+func (r *fakeSubRepo) CountByPaymentStatus(_ context.Context) ([]customTypes.PaymentStatusCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[string]int64)
+	for _, sub := range r.subs {
+		if !sub.DeletedAt.Valid {
+			index[sub.PaymentStatus]++
+		}
+	}
+	var counts []customTypes.PaymentStatusCount
+	for status, count := range index {
+		counts = append(counts, customTypes.PaymentStatusCount{PaymentStatus: status, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b customTypes.PaymentStatusCount) int {
+		return strings.Compare(a.PaymentStatus, b.PaymentStatus)
+	})
+	return counts, nil
+}
+
+// CountActiveByPlan counts the subscriptions in their term per plan, largest first, then by name.
+func (r *fakeSubRepo) CountActiveByPlan(_ context.Context, now time.Time) ([]customTypes.PlanSubscriptionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[string]int64)
+	for _, sub := range r.subs {
+		if subscriptionInTerm(sub, now) {
+			index[sub.PlanName]++
+		}
+	}
+	var counts []customTypes.PlanSubscriptionCount
+	for planName, count := range index {
+		counts = append(counts, customTypes.PlanSubscriptionCount{PlanName: planName, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b customTypes.PlanSubscriptionCount) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return strings.Compare(a.PlanName, b.PlanName)
+	})
+	return counts, nil
+}
+
+// ListActivePlanNames returns the distinct plan names of the user's active, unexpired subscriptions, ordered by
+// name; it returns an empty slice rather than nil if there are none, like the Pluck of the SQL repository.
+func (r *fakeSubRepo) ListActivePlanNames(_ context.Context, userID uuid.UUID) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	planNames := []string{}
+	for _, sub := range r.subs {
+		if sub.UserID == userID && sub.IsActive && sub.EndDate.After(time.Now()) && !slices.Contains(planNames, sub.PlanName) {
+			planNames = append(planNames, sub.PlanName)
+		}
+	}
+	slices.Sort(planNames)
+	return planNames, nil
+}
+
+// Update stores subscription in place of the subscription with the same ID and records event.
+func (r *fakeSubRepo) Update(_ context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
+	if r.updateErr != nil {
+		return r.updateErr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[subscription.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	copied := *subscription
+	r.subs[subscription.ID] = &copied
+	if event != nil {
+		event.SubscriptionID = subscription.ID
+		r.events = append(r.events, *event)
+	}
+	return nil
+}
+
+// ListEvents returns the subscription's events in the order they were recorded, paged by offset and limit.
+func (r *fakeSubRepo) ListEvents(_ context.Context, subscriptionID uuid.UUID, offset, limit int) ([]models.SubscriptionEvent, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []models.SubscriptionEvent
+	for _, event := range r.events {
+		if event.SubscriptionID == subscriptionID {
+			events = append(events, event)
+		}
+	}
+	total := int64(len(events))
+	events = events[min(offset, len(events)):]
+	return events[:min(limit, len(events))], total, nil
+}
+
+// ListByUserID returns the user's subscriptions ordered by start date, newest first; of the filters,
+// only the plan name is applied, case-insensitively like the SQL repository does.
+func (r *fakeSubRepo) ListByUserID(_ context.Context, userID uuid.UUID, params customTypes.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []models.Subscription
+	for _, sub := range r.subs {
+		if sub.UserID == userID && (params.PlanName == nil || strings.EqualFold(sub.PlanName, *params.PlanName)) {
+			matching = append(matching, *sub)
+		}
+	}
+	slices.SortFunc(matching, func(a, b models.Subscription) int { return b.StartDate.Compare(a.StartDate) })
+	start, end := min(params.Offset, len(matching)), min(params.Offset+params.Limit, len(matching))
+	return matching[start:end], int64(len(matching)), nil
+}
+
+// ListActiveByPlanName returns the active subscriptions with exactly the plan name, ordered by start date, newest first.
+func (r *fakeSubRepo) ListActiveByPlanName(_ context.Context, planName string, offset, limit int) ([]models.Subscription, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []models.Subscription
+	for _, sub := range r.subs {
+		if sub.IsActive && sub.PlanName == planName {
+			matching = append(matching, *sub)
+		}
+	}
+	slices.SortFunc(matching, func(a, b models.Subscription) int { return b.StartDate.Compare(a.StartDate) })
+	start, end := min(offset, len(matching)), min(offset+limit, len(matching))
+	return matching[start:end], int64(len(matching)), nil
+}
+
+func (r *fakeSubRepo) Delete(_ context.Context, id uuid.UUID, event *models.SubscriptionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.subs, id)
+	event.SubscriptionID = id
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// List returns the subscriptions ordered by ID; filters are ignored.
+func (r *fakeSubRepo) List(_ context.Context, offset, limit int, _ customTypes.ListSubscriptionsFilters) ([]models.Subscription, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]models.Subscription, 0, len(r.subs))
+	for _, sub := range r.subs {
+		all = append(all, *sub)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID.String() < all[j].ID.String() })
+	if offset >= len(all) {
+		return []models.Subscription{}, int64(len(all)), nil
+	}
+	return all[offset:min(offset+limit, len(all))], int64(len(all)), nil
+}
+
+// GetChurnCounts classifies the paid subscriptions and the renewals awaiting payment like the SQL aggregation does.
+func (r *fakeSubRepo) GetChurnCounts(_ context.Context, from, to time.Time) (*customTypes.SubscriptionChurnCounts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inPeriod := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	var counts customTypes.SubscriptionChurnCounts
+	for _, sub := range r.subs {
+		if sub.PaymentStatus != "paid" && (sub.PaymentStatus != "pending" || sub.LastRenewedAt == nil) {
+			continue
+		}
+		if !sub.StartDate.After(from) && sub.EndDate.After(from) {
+			counts.ActiveAtStart++
+		}
+		switch {
+		case sub.LastRenewedAt != nil && inPeriod(*sub.LastRenewedAt):
+			counts.Renewed++
+		case inPeriod(sub.EndDate) && sub.AutoRenew:
+			counts.Expired++
+		case inPeriod(sub.EndDate):
+			counts.Cancelled++
+		}
+	}
+	return &counts, nil
+}
+
+// GetCurrencyTotals groups the subscriptions created in [from, to) by trimmed, upper-cased currency like the SQL
+// aggregation does.
+func (r *fakeSubRepo) GetCurrencyTotals(_ context.Context, from, to time.Time) ([]customTypes.SubscriptionCurrencyTotals, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byCurrency := make(map[string]*customTypes.SubscriptionCurrencyTotals)
+	for _, sub := range r.subs {
+		if sub.CreatedAt.Before(from) || !sub.CreatedAt.Before(to) {
+			continue
+		}
+		currency := strings.ToUpper(strings.TrimSpace(sub.Currency))
+		if _, ok := byCurrency[currency]; !ok {
+			byCurrency[currency] = &customTypes.SubscriptionCurrencyTotals{Currency: currency}
+		}
+		byCurrency[currency].Subscriptions++
+		if sub.PaymentStatus == "paid" {
+			byCurrency[currency].Paid++
+			byCurrency[currency].Revenue += sub.Price
+		}
+	}
+	var totals []customTypes.SubscriptionCurrencyTotals
+	for _, t := range byCurrency {
+		totals = append(totals, *t)
+	}
+	slices.SortFunc(totals, func(a, b customTypes.SubscriptionCurrencyTotals) int { return strings.Compare(a.Currency, b.Currency) })
+	return totals, nil
+}
+
+func (r *fakeSubRepo) ListRenewalCandidates(_ context.Context, from, to time.Time, limit int) ([]models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var candidates []models.Subscription
+	for _, sub := range r.subs {
+		if sub.AutoRenew && sub.IsActive && sub.PaymentStatus == "paid" &&
+			!sub.EndDate.Before(from) && !sub.EndDate.After(to) {
+			candidates = append(candidates, *sub)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].EndDate.Before(candidates[j].EndDate) })
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func (r *fakeSubRepo) ListActivationCandidates(_ context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var candidates []models.Subscription
+	for _, sub := range r.subs {
+		if (sub.PaymentStatus == "paid" || sub.PaymentStatus == "trial") && !sub.IsActive &&
+			!sub.StartDate.After(now) && sub.EndDate.After(now) {
+			candidates = append(candidates, *sub)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].StartDate.Before(candidates[j].StartDate) })
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func (r *fakeSubRepo) ExtendForRenewal(_ context.Context, subscription *models.Subscription, newEndDate time.Time, event *models.SubscriptionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.subs[subscription.ID]
+	if !ok || !stored.EndDate.Equal(subscription.EndDate) || !stored.AutoRenew || !stored.IsActive || stored.PaymentStatus != "paid" {
+		return gorm.ErrRecordNotFound
+	}
+	renewedAt := time.Now()
+	stored.EndDate = newEndDate
+	stored.PaymentStatus = "pending"
+	stored.ExternalPaymentID = nil
+	stored.LastRenewedAt = &renewedAt
+	*subscription = *stored
+	event.SubscriptionID = subscription.ID
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeSubRepo) ListUnpaidRenewals(_ context.Context, renewedBefore time.Time, limit int) ([]models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unpaid []models.Subscription
+	for _, sub := range r.subs {
+		if sub.IsActive && sub.PaymentStatus == "pending" && sub.LastRenewedAt != nil && !sub.LastRenewedAt.After(renewedBefore) {
+			unpaid = append(unpaid, *sub)
+		}
+	}
+	sort.Slice(unpaid, func(i, j int) bool { return unpaid[i].LastRenewedAt.Before(*unpaid[j].LastRenewedAt) })
+	if limit > 0 && len(unpaid) > limit {
+		unpaid = unpaid[:limit]
+	}
+	return unpaid, nil
+}
+
+func (r *fakeSubRepo) DeactivateUnpaidRenewal(_ context.Context, subscription *models.Subscription, event *models.SubscriptionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.subs[subscription.ID]
+	if !ok || stored.LastRenewedAt == nil || subscription.LastRenewedAt == nil || !stored.LastRenewedAt.Equal(*subscription.LastRenewedAt) ||
+		!stored.IsActive || stored.PaymentStatus != "pending" {
+		return gorm.ErrRecordNotFound
+	}
+	stored.IsActive = false
+	*subscription = *stored
+	event.SubscriptionID = subscription.ID
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// AddUsage adds to the row with the same subscription, period and metric, or inserts one, like the upsert does.
+func (r *fakeSubRepo) AddUsage(_ context.Context, usage *models.SubscriptionUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.usage {
+		row := &r.usage[i]
+		if row.SubscriptionID == usage.SubscriptionID && row.PeriodStart.Equal(usage.PeriodStart) && row.Metric == usage.Metric {
+			row.Quantity += usage.Quantity
+			row.UpdatedAt = now
+			*usage = *row
+			return nil
+		}
+	}
+	usage.ID = uint(len(r.usage) + 1)
+	usage.CreatedAt, usage.UpdatedAt = now, now
+	r.usage = append(r.usage, *usage)
+	return nil
+}
+
+// ListUsage returns the subscription's usage rows ordered by period start and metric.
+func (r *fakeSubRepo) ListUsage(_ context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var usage []models.SubscriptionUsage
+	for _, row := range r.usage {
+		if row.SubscriptionID == subscriptionID {
+			usage = append(usage, row)
+		}
+	}
+	slices.SortFunc(usage, func(a, b models.SubscriptionUsage) int {
+		if c := a.PeriodStart.Compare(b.PeriodStart); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Metric, b.Metric)
+	})
+	return usage, nil
+}
+
+// fakePublisher is an interfaces.EventPublisher that records the published events.
+type fakePublisher struct {
+	mu     sync.Mutex
+	events []interfaces.Event
+}
+
+func (p *fakePublisher) Publish(_ context.Context, event interfaces.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// fakeAssignmentRepo is an in-memory interfaces.KeyAssignmentRepository holding each user's latest active assignment.
+type fakeAssignmentRepo struct {
+	interfaces.KeyAssignmentRepository
+
+	latest map[uuid.UUID]*models.KeyAssignment
+}
+
+// Create records assignment as the user's latest active assignment.
+func (r *fakeAssignmentRepo) Create(_ context.Context, assignment *models.KeyAssignment) error {
+	assignment.ID = uuid.New()
+	copied := *assignment
+	r.latest[assignment.UserID] = &copied
+	return nil
+}
+
+// Reassign replaces the user's latest active assignment with assignment and returns the replaced one, deactivated.
+func (r *fakeAssignmentRepo) Reassign(ctx context.Context, assignment *models.KeyAssignment) ([]models.KeyAssignment, error) {
+	var previous []models.KeyAssignment
+	if current, ok := r.latest[assignment.UserID]; ok {
+		revoked := *current
+		revoked.IsActive = false
+		previous = append(previous, revoked)
+	}
+	return previous, r.Create(ctx, assignment)
+}
+
+func (r *fakeAssignmentRepo) GetLatestActiveByUserID(_ context.Context, userID uuid.UUID) (*models.KeyAssignment, error) {
+	assignment, ok := r.latest[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return assignment, nil
+}
+
+// fakeHostRepo is an in-memory interfaces.HostRepository that acquires hosts like the SQL repository does:
+// the matching online host with the lowest issued count, preferring hosts with the 'active' status.
+// Lookups skip soft-deleted hosts, like GORM's default scope.
+type fakeHostRepo struct {
+	interfaces.HostRepository
+
+	mu    sync.Mutex
+	hosts []*models.Host
+
+	beforeUpdate   func() // Optional: called by Update before writing, e.g. to interleave a concurrent writer.
+	aggregateCalls int    // Number of AggregateAvailability calls, to observe caching.
+}
+
+func newFakeHostRepo(hosts ...models.Host) *fakeHostRepo {
+	r := &fakeHostRepo{}
+	for i := range hosts {
+		r.hosts = append(r.hosts, &hosts[i])
+	}
+	return r
+}
+
+func (r *fakeHostRepo) AcquireLeastIssuedHostExcluding(_ context.Context, filter customTypes.HostSelectionFilter, excludeHostIDs []uint) (*models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, requireActiveStatus := range []bool{true, false} {
+		var best *models.Host
+		for _, host := range r.hosts {
+			if !hostMatchesFilter(host, filter) || slices.Contains(excludeHostIDs, host.ID) ||
+				(requireActiveStatus && host.Status != customTypes.StatusActive) {
+				continue
+			}
+			if best == nil || host.IssuedCount < best.IssuedCount {
+				best = host
+			}
+		}
+		if best != nil {
+			best.IssuedCount++
+			copied := *best
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeHostRepo) GetByID(ctx context.Context, id uint) (*models.Host, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err // As a query run through WithContext would.
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid && host.ID == id {
+			copied := *host
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeHostRepo) GetByAddressPortProtocolNetwork(_ context.Context, address, port, protocol, network string) (*models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid && host.Address == address && host.Port == port && host.Protocol == protocol && host.Network == network {
+			copied := *host
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeHostRepo) GetByHostName(_ context.Context, hostName string) (*models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid && strings.EqualFold(host.HostName, hostName) {
+			copied := *host
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetDeletedByID returns the soft-deleted host with the given ID.
+func (r *fakeHostRepo) GetDeletedByID(_ context.Context, id uint) (*models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if host.ID == id && host.DeletedAt.Valid {
+			copied := *host
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Restore clears the deletion timestamp of the soft-deleted host with the given ID.
+func (r *fakeHostRepo) Restore(_ context.Context, id uint) (*models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if host.ID == id && host.DeletedAt.Valid {
+			host.DeletedAt = gorm.DeletedAt{}
+			copied := *host
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Create assigns host the next free ID and stores it.
+func (r *fakeHostRepo) Create(ctx context.Context, host *models.Host) error {
+	return r.CreateBatch(ctx, []*models.Host{host})
+}
+
+// CreateBatch assigns the hosts the next free IDs and stores them.
+func (r *fakeHostRepo) CreateBatch(_ context.Context, hosts []*models.Host) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range hosts {
+		host.ID = uint(len(r.hosts) + 1)
+		copied := *host
+		r.hosts = append(r.hosts, &copied)
+	}
+	return nil
+}
+
+// Update stores host in place of the host with the same ID; changes are not inspected.
+// Update writes only the columns in changes to the stored host, like Updates with a map does, so columns
+// changed concurrently by other writers are kept.
+func (r *fakeHostRepo) Update(ctx context.Context, host *models.Host, changes map[string]any) error {
+	hostSchema, err := schema.Parse(&models.Host{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+	if hook := r.beforeUpdate; hook != nil {
+		r.beforeUpdate = nil // Interleave once; the hook may update hosts itself.
+		hook()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.hosts {
+		if stored.DeletedAt.Valid || stored.ID != host.ID {
+			continue
+		}
+		for column, value := range changes {
+			field := hostSchema.LookUpField(column)
+			if field == nil {
+				return fmt.Errorf("unknown column %q", column)
+			}
+			if err := field.Set(ctx, reflect.ValueOf(stored).Elem(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// CountAvailableByCountry aggregates the online, active hosts per country and tier like the GROUP BY query does.
+func (r *fakeHostRepo) CountAvailableByCountry(_ context.Context) ([]customTypes.CountryHostAvailability, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[string]*customTypes.CountryHostAvailability)
+	var counts []customTypes.CountryHostAvailability
+	for _, host := range r.hosts {
+		if host.DeletedAt.Valid || !host.IsOnline || host.Status != customTypes.StatusActive {
+			continue
+		}
+		if _, ok := index[host.Country]; !ok {
+			index[host.Country] = &customTypes.CountryHostAvailability{Country: host.Country}
+		}
+		if host.IsFreeTier {
+			index[host.Country].Free++
+		} else {
+			index[host.Country].Paid++
+		}
+	}
+	for _, c := range index {
+		counts = append(counts, *c)
+	}
+	slices.SortFunc(counts, func(a, b customTypes.CountryHostAvailability) int { return strings.Compare(a.Country, b.Country) })
+	return counts, nil
+}
+
+// AggregateAvailability summarizes the public hosts that keys can be generated for per country like the GROUP BY
+// query does: online, active, non-private hosts, where reality hosts need a public key.
+// CountByStatus counts the non-deleted hosts per status, ordered by status.
+func (r *fakeHostRepo) CountByStatus(_ context.Context) ([]customTypes.HostStatusCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[customTypes.HostStatus]int64)
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid {
+			index[host.Status]++
+		}
+	}
+	var counts []customTypes.HostStatusCount
+	for status, count := range index {
+		counts = append(counts, customTypes.HostStatusCount{Status: status, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b customTypes.HostStatusCount) int { return strings.Compare(string(a.Status), string(b.Status)) })
+	return counts, nil
+}
+
+// CountByCountry counts the non-deleted hosts per country, largest first, then by country code.
+func (r *fakeHostRepo) CountByCountry(_ context.Context) ([]customTypes.CountryHostCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[string]int64)
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid {
+			index[host.Country]++
+		}
+	}
+	var counts []customTypes.CountryHostCount
+	for country, count := range index {
+		counts = append(counts, customTypes.CountryHostCount{Country: country, Count: count})
+	}
+	slices.SortFunc(counts, func(a, b customTypes.CountryHostCount) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return strings.Compare(a.Country, b.Country)
+	})
+	return counts, nil
+}
+
+func (r *fakeHostRepo) AggregateAvailability(_ context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aggregateCalls++
+	index := make(map[string]*customTypes.CountryAvailability)
+	cities := make(map[string]map[string]bool)
+	var availability []customTypes.CountryAvailability
+	for _, host := range r.hosts {
+		if host.DeletedAt.Valid || host.IsPrivate || host.Status != customTypes.StatusActive ||
+			!hostMatchesFilter(host, customTypes.HostSelectionFilter{IsFreeTier: isFreeTier}) ||
+			(strings.EqualFold(host.SecurityType, "reality") && host.PublicKey == "") {
+			continue
+		}
+		if _, ok := index[host.Country]; !ok {
+			index[host.Country] = &customTypes.CountryAvailability{Country: host.Country}
+			cities[host.Country] = make(map[string]bool)
+		}
+		index[host.Country].OnlineHostCount++
+		index[host.Country].HasFreeTier = index[host.Country].HasFreeTier || host.IsFreeTier
+		if host.City != "" {
+			cities[host.Country][host.City] = true
+		}
+	}
+	for country, c := range index {
+		c.CityCount = int64(len(cities[country]))
+		availability = append(availability, *c)
+	}
+	slices.SortFunc(availability, func(a, b customTypes.CountryAvailability) int { return strings.Compare(a.Country, b.Country) })
+	return availability, nil
+}
+
+// ListAll returns every non-deleted host, ordered by ID.
+func (r *fakeHostRepo) ListAll(ctx context.Context) ([]models.Host, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hosts []models.Host
+	for _, host := range r.hosts {
+		if !host.DeletedAt.Valid {
+			hosts = append(hosts, *host)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ID < hosts[j].ID })
+	return hosts, nil
+}
+
+// List returns the hosts matching the tier filter, ordered by ID, paged by params.Offset and params.Limit.
+// Deleted hosts are only included with params.IncludeDeleted; other filters are not applied.
+func (r *fakeHostRepo) List(_ context.Context, params customTypes.ListHostsParams) ([]models.Host, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hosts []models.Host
+	for _, host := range r.hosts {
+		if (params.IncludeDeleted || !host.DeletedAt.Valid) && (params.IsFreeTier == nil || host.IsFreeTier == *params.IsFreeTier) {
+			hosts = append(hosts, *host)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ID < hosts[j].ID })
+	total := int64(len(hosts))
+	hosts = hosts[min(params.Offset, len(hosts)):]
+	return hosts[:min(params.Limit, len(hosts))], total, nil
+}
+
+// ListAfter returns up to params.Limit hosts in (created_at DESC, id DESC) order after the cursor, like the keyset
+// query does; filters are not applied.
+func (r *fakeHostRepo) ListAfter(_ context.Context, params customTypes.ListHostsParams, after *customTypes.HostListCursor) ([]models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// before reports whether a sorts before b, i.e. (a.created_at, a.id) > (b.created_at, b.id).
+	before := func(aCreatedAt time.Time, aID uint, bCreatedAt time.Time, bID uint) bool {
+		return aCreatedAt.After(bCreatedAt) || (aCreatedAt.Equal(bCreatedAt) && aID > bID)
+	}
+	var hosts []models.Host
+	for _, host := range r.hosts {
+		if after == nil || before(after.CreatedAt, after.ID, host.CreatedAt, host.ID) {
+			hosts = append(hosts, *host)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return before(hosts[i].CreatedAt, hosts[i].ID, hosts[j].CreatedAt, hosts[j].ID) })
+	return hosts[:min(params.Limit, len(hosts))], nil
+}
+
+// issuedCounts returns the issued count of every host by ID.
+// ListCountryCandidates returns, per country of the matching online hosts, the hosts tied for the best rank
+// (the 'active' status first, then the lowest issued count), like the window functions of the SQL repository.
+func (r *fakeHostRepo) ListCountryCandidates(_ context.Context, filter customTypes.HostSelectionFilter, countries []string, perCountry, maxCountries int) ([]models.Host, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byCountry := map[string][]models.Host{}
+	for _, host := range r.hosts {
+		country := strings.ToLower(host.Country)
+		if host.DeletedAt.Valid || country == "" || !hostMatchesFilter(host, filter) ||
+			(len(countries) > 0 && !slices.ContainsFunc(countries, func(c string) bool { return strings.EqualFold(c, country) })) {
+			continue
+		}
+		byCountry[country] = append(byCountry[country], *host)
+	}
+	rank := func(host models.Host) [2]int64 {
+		if host.Status == customTypes.StatusActive {
+			return [2]int64{0, host.IssuedCount}
+		}
+		return [2]int64{1, host.IssuedCount}
+	}
+
+	var candidates []models.Host
+	for _, country := range slices.Sorted(maps.Keys(byCountry))[:min(maxCountries, len(byCountry))] {
+		hosts := byCountry[country]
+		slices.SortFunc(hosts, func(a, b models.Host) int { return cmp.Compare(a.ID, b.ID) })
+		best := rank(slices.MinFunc(hosts, func(a, b models.Host) int {
+			ra, rb := rank(a), rank(b)
+			return cmp.Or(cmp.Compare(ra[0], rb[0]), cmp.Compare(ra[1], rb[1]))
+		}))
+		tied := slices.DeleteFunc(hosts, func(host models.Host) bool { return rank(host) != best })
+		candidates = append(candidates, tied[:min(perCountry, len(tied))]...)
+	}
+	return candidates, nil
+}
+
+func (r *fakeHostRepo) issuedCounts() map[uint]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[uint]int64, len(r.hosts))
+	for _, host := range r.hosts {
+		counts[host.ID] = host.IssuedCount
+	}
+	return counts
+}
+
+// hostMatchesFilter reports whether an online host matches every field set in filter.
+func hostMatchesFilter(host *models.Host, filter customTypes.HostSelectionFilter) bool {
+	matches := func(want *string, got string) bool {
+		return want == nil || *want == "" || strings.EqualFold(*want, got)
+	}
+	return host.IsOnline &&
+		matches(filter.Country, host.Country) &&
+		matches(filter.Network, host.Network) &&
+		matches(filter.SecurityType, host.SecurityType) &&
+		matches(filter.Protocol, host.Protocol) &&
+		(filter.IsFreeTier == nil || *filter.IsFreeTier == host.IsFreeTier) &&
+		(filter.AddressFamily == nil || *filter.AddressFamily == host.AddressFamily)
+}
+
+// fakeGenerationRepo is an in-memory interfaces.KeyGenerationRepository.
+type fakeGenerationRepo struct {
+	interfaces.KeyGenerationRepository
+
+	mu          sync.Mutex
+	generations []models.KeyGeneration
+
+	subs *fakeSubRepo // Optional: the subscriptions CountConversionFunnel joins.
+}
+
+func (r *fakeGenerationRepo) Create(_ context.Context, generation *models.KeyGeneration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if generation.CreatedAt.IsZero() {
+		generation.CreatedAt = time.Now() // As GORM does.
+	}
+	r.generations = append(r.generations, *generation)
+	return nil
+}
+
+// CountByBucket counts the generations in [from, to) per bucket like the GROUP BY query does, omitting empty buckets.
+func (r *fakeGenerationRepo) CountByBucket(_ context.Context, from, to time.Time, bucket time.Duration) ([]customTypes.KeyGenerationBucketCounts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := make(map[int64]*customTypes.KeyGenerationBucketCounts)
+	for _, generation := range r.generations {
+		if generation.CreatedAt.Before(from) || !generation.CreatedAt.Before(to) {
+			continue
+		}
+		i := int64(generation.CreatedAt.Sub(from) / bucket)
+		if _, ok := index[i]; !ok {
+			index[i] = &customTypes.KeyGenerationBucketCounts{Bucket: i}
+		}
+		if generation.UserID == nil {
+			index[i].FreeKeys++
+		} else {
+			index[i].UserKeys++
+		}
+	}
+	var counts []customTypes.KeyGenerationBucketCounts
+	for _, c := range index {
+		counts = append(counts, *c)
+	}
+	slices.SortFunc(counts, func(a, b customTypes.KeyGenerationBucketCounts) int { return int(a.Bucket - b.Bucket) })
+	return counts, nil
+}
+
+// CountSince counts the generations at or after since.
+func (r *fakeGenerationRepo) CountSince(_ context.Context, since time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, generation := range r.generations {
+		if !generation.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountConversionFunnel counts like the conversion funnel query does: the users issued a free-tier key in
+// [from, to), and those of them with a subscription, and a paid one, created after their first such key.
+func (r *fakeGenerationRepo) CountConversionFunnel(_ context.Context, from, to time.Time) (*customTypes.ConversionFunnelCounts, error) {
+	r.mu.Lock()
+	firstFreeKeyAt := make(map[uuid.UUID]time.Time)
+	for _, generation := range r.generations {
+		if generation.UserID == nil || !generation.IsFreeTier || generation.CreatedAt.Before(from) || !generation.CreatedAt.Before(to) {
+			continue
+		}
+		if first, ok := firstFreeKeyAt[*generation.UserID]; !ok || generation.CreatedAt.Before(first) {
+			firstFreeKeyAt[*generation.UserID] = generation.CreatedAt
+		}
+	}
+	r.mu.Unlock()
+
+	counts := &customTypes.ConversionFunnelCounts{FreeKeyUsers: int64(len(firstFreeKeyAt))}
+	if r.subs == nil {
+		return counts, nil
+	}
+	r.subs.mu.Lock()
+	defer r.subs.mu.Unlock()
+	for userID, first := range firstFreeKeyAt {
+		subscribed, paid := false, false
+		for _, sub := range r.subs.subs {
+			if sub.UserID != userID || sub.DeletedAt.Valid || !sub.CreatedAt.After(first) {
+				continue
+			}
+			subscribed = true
+			paid = paid || sub.PaymentStatus == "paid"
+		}
+		if subscribed {
+			counts.Subscribed++
+		}
+		if paid {
+			counts.Paid++
+		}
+	}
+	return counts, nil
+}
+
+// fakeHostCheckRepo is an in-memory interfaces.HostCheckRepository.
+type fakeHostCheckRepo struct {
+	interfaces.HostCheckRepository
+
+	mu     sync.Mutex
+	checks []models.HostCheck
+}
+
+func (r *fakeHostCheckRepo) Create(_ context.Context, check *models.HostCheck) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	check.ID = uint(len(r.checks) + 1)
+	r.checks = append(r.checks, *check)
+	return nil
+}
+
+func (r *fakeHostCheckRepo) CountSince(_ context.Context, hostID uint, since time.Time) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total, online int64
+	for _, check := range r.checks {
+		if check.HostID == hostID && !check.CheckedAt.Before(since) {
+			total++
+			if check.WasOnline {
+				online++
+			}
+		}
+	}
+	return total, online, nil
+}
+
+// fakePlanRepo is an in-memory interfaces.PlanRepository.
+type fakePlanRepo struct {
+	interfaces.PlanRepository
+
+	plans map[uint]models.Plan
+}
+
+func newFakePlanRepo(plans ...models.Plan) *fakePlanRepo {
+	r := &fakePlanRepo{plans: map[uint]models.Plan{}}
+	for _, plan := range plans {
+		r.plans[plan.ID] = plan
+	}
+	return r
+}
+
+func (r *fakePlanRepo) GetByID(_ context.Context, id uint) (*models.Plan, error) {
+	plan, ok := r.plans[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &plan, nil
+}
+
+// fakePromoCodeRepo is an in-memory interfaces.PromoCodeRepository.
+type fakePromoCodeRepo struct {
+	interfaces.PromoCodeRepository
+
+	mu    sync.Mutex
+	codes []*models.PromoCode
+}
+
+func newFakePromoCodeRepo(codes ...models.PromoCode) *fakePromoCodeRepo {
+	r := &fakePromoCodeRepo{}
+	for i := range codes {
+		r.codes = append(r.codes, &codes[i])
+	}
+	return r
+}
+
+func (r *fakePromoCodeRepo) GetByCode(_ context.Context, code string) (*models.PromoCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, promoCode := range r.codes {
+		if promoCode.Code == code {
+			copied := *promoCode
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// redeem increments the usage count of the promo code with the given ID under the conditions of the SQL repository's
+// update, and reports whether it did.
+func (r *fakePromoCodeRepo) redeem(id uint, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, promoCode := range r.codes {
+		if promoCode.ID != id {
+			continue
+		}
+		if !promoCode.IsActive || (promoCode.ValidFrom != nil && promoCode.ValidFrom.After(now)) ||
+			(promoCode.ValidUntil != nil && promoCode.ValidUntil.Before(now)) ||
+			(promoCode.MaxUses != nil && promoCode.UsedCount >= *promoCode.MaxUses) {
+			return false
+		}
+		promoCode.UsedCount++
+		return true
+	}
+	return false
+}
+
+// usedCount returns the usage count of the promo code with the given ID.
+func (r *fakePromoCodeRepo) usedCount(id uint) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, promoCode := range r.codes {
+		if promoCode.ID == id {
+			return promoCode.UsedCount
+		}
+	}
+	return 0
+}
@@ -1,16 +1,31 @@
 package services
 
 import (
+	"bitback/internal/events"
+	"bitback/internal/models"
 	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // calculateEndDate calculates the subscription end date.
 func calculateEndDate(startDate time.Time, unit customTypes.DurationUnit, value int) (time.Time, error) {
 	if value <= 0 {
-		return time.Time{}, errors.New("duration value must be positive")
+		return time.Time{}, invalid(errors.New("duration value must be positive"))
 	}
 	switch unit {
 	case customTypes.UnitDay:
@@ -20,6 +35,418 @@ func calculateEndDate(startDate time.Time, unit customTypes.DurationUnit, value
 	case customTypes.UnitYear:
 		return startDate.AddDate(value, 0, 0), nil
 	default:
-		return time.Time{}, fmt.Errorf("invalid duration unit: %s", unit)
+		return time.Time{}, invalid(fmt.Errorf("invalid duration unit: %s", unit))
+	}
+}
+
+// warnInvalidDurationUnits logs the subscriptions whose stored duration unit is not a known one.
+// Such legacy rows are still returned, flagged as invalid in API responses, rather than failing the whole read.
+func warnInvalidDurationUnits(ctx context.Context, op string, subs ...models.Subscription) {
+	for i := range subs {
+		if !subs[i].DurationUnit.IsValid() {
+			slog.WarnContext(ctx, op+": subscription has an invalid duration unit", "subscriptionID", subs[i].ID, "unit", subs[i].DurationUnit)
+		}
+	}
+}
+
+// validateSubscriptionPeriod checks the invariant that a subscription ends strictly after it starts.
+func validateSubscriptionPeriod(startDate, endDate time.Time) error {
+	if !endDate.After(startDate) {
+		return fmt.Errorf("%w (start %s, end %s)", ErrInvalidSubscriptionPeriod,
+			startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// usagePeriod returns the billing period of the subscription that contains at. Periods are one month long,
+// start on the subscription's start date and its monthly anniversaries, and the last one ends with the subscription.
+// ok is false if at falls outside the subscription's term.
+func usagePeriod(sub *models.Subscription, at time.Time) (start, end time.Time, ok bool) {
+	if at.Before(sub.StartDate) || !at.Before(sub.EndDate) {
+		return time.Time{}, time.Time{}, false
+	}
+	for months := 0; ; months++ {
+		start = sub.StartDate.AddDate(0, months, 0)
+		end = sub.StartDate.AddDate(0, months+1, 0)
+		if end.After(sub.EndDate) {
+			end = sub.EndDate
+		}
+		if at.Before(end) {
+			return start, end, true
+		}
+	}
+}
+
+// isValidUsageMetric reports whether metric is a non-empty name of lower-case letters, digits, '_', '.' and '-'
+// no longer than maxUsageMetricLength.
+func isValidUsageMetric(metric string) bool {
+	if metric == "" || len(metric) > maxUsageMetricLength {
+		return false
+	}
+	for _, r := range metric {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// inferCurrency resolves the currency for a country using the provided country-to-currency map.
+// It returns defaultCurrency if the country is not provided or has no mapping.
+func inferCurrency(country *string, currencyByCountry map[string]string, defaultCurrency string) string {
+	if country != nil {
+		if currency, ok := currencyByCountry[strings.ToUpper(strings.TrimSpace(*country))]; ok {
+			return currency
+		}
+	}
+	return defaultCurrency
+}
+
+// renewalEndDate computes the end date of an auto-renewing subscription extended by another period of its duration,
+// counted from its current end date.
+func renewalEndDate(sub *models.Subscription) (time.Time, error) {
+	endDate, err := calculateEndDate(sub.EndDate, sub.DurationUnit, sub.DurationValue)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to calculate renewal end date: %w", err)
+	}
+	if err := validateSubscriptionPeriod(sub.EndDate, endDate); err != nil {
+		return time.Time{}, err
+	}
+	return endDate, nil
+}
+
+// newSubscriptionRenewedEvent builds the domain event published after sub was extended from previousEndDate.
+func newSubscriptionRenewedEvent(sub *models.Subscription, previousEndDate time.Time) events.SubscriptionRenewed {
+	return events.SubscriptionRenewed{
+		SubscriptionID:  sub.ID,
+		UserID:          sub.UserID,
+		PreviousEndDate: previousEndDate,
+		EndDate:         sub.EndDate,
+		Price:           sub.Price,
+		Currency:        sub.Currency,
+		PaymentStatus:   sub.PaymentStatus,
+		OccurredAt:      time.Now(),
+	}
+}
+
+// subscriptionFingerprint returns a stable hash of the client input for a new subscription, after the plan name
+// has been resolved to its canonical name. It is stored alongside an idempotency key to detect reuse of the key
+// with a different payload. Values derived on the server, such as an inferred currency, a catalog plan's price
+// or a promo code discount, are left out, so a retry matches even if they changed since the original request.
+func subscriptionFingerprint(input dto.CreateSubscriptionInput) string {
+	// Fields the client omitted are written as "-", so they differ from explicit zero values.
+	planID, price := "-", "-"
+	if input.PlanID != nil {
+		planID = strconv.FormatUint(uint64(*input.PlanID), 10)
+	}
+	if input.Price != nil {
+		price = fmt.Sprintf("%.4f", *input.Price)
+	}
+	currency, promoCode := "-", "-"
+	if input.Currency != nil && strings.TrimSpace(*input.Currency) != "" {
+		currency = strings.ToUpper(strings.TrimSpace(*input.Currency))
+	}
+	if input.PromoCode != nil && strings.TrimSpace(*input.PromoCode) != "" {
+		promoCode = normalizePromoCode(*input.PromoCode)
+	}
+	payload := fmt.Sprintf("%s|%s|%s|%s|%d|%s|%s|%s|%s|%t|%s",
+		input.UserID,
+		planID,
+		input.PlanName,
+		input.DurationUnit,
+		input.DurationValue,
+		input.StartDate.UTC().Format(time.RFC3339Nano),
+		price,
+		currency,
+		input.PaymentStatus,
+		input.AutoRenew,
+		promoCode,
+	)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// isDuplicateKeyError reports whether err is caused by a unique constraint violation.
+func isDuplicateKeyError(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
+
+// normalizePage defaults a missing or invalid page to 1 and normalizes pageSize with normalizePageSize.
+func normalizePage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	return page, normalizePageSize(pageSize)
+}
+
+// normalizePageSize defaults a missing or invalid page size to defaultPageSize and caps it at maxPageSize.
+func normalizePageSize(pageSize int) int {
+	if pageSize < 1 {
+		return defaultPageSize
+	}
+	return min(pageSize, maxPageSize)
+}
+
+// uptimeRatio returns the share of online checks among all checks, or nil if there were no checks.
+func uptimeRatio(total, online int64) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	ratio := float64(online) / float64(total)
+	return &ratio
+}
+
+// utilizationPercent returns current as a percentage of capacity, or nil if the capacity is unknown.
+// The result may exceed 100 when a host is over capacity.
+func utilizationPercent(current, capacity int64) *float64 {
+	if capacity <= 0 {
+		return nil
+	}
+	percent := float64(current) / float64(capacity) * 100
+	return &percent
+}
+
+// hostSecurityError returns an "invalid" error describing what is missing from host's security
+// configuration, or nil if keys can be generated for it. Reality hosts need a public key.
+func hostSecurityError(host *models.Host) error {
+	if strings.EqualFold(host.SecurityType, "reality") && strings.TrimSpace(host.PublicKey) == "" {
+		return invalid(errors.New("host security type 'reality' requires a public key"))
+	}
+	return nil
+}
+
+// newHostFromInput validates the input for a new host and builds the corresponding model.
+// The protocol must be one of allowedProtocols. It does not check the host for uniqueness.
+func newHostFromInput(input dto.CreateHostInput, allowedProtocols []string) (*models.Host, error) {
+	// Perform basic input validation.
+	if strings.TrimSpace(input.Address) == "" {
+		return nil, invalid(errors.New("host address cannot be empty"))
+	}
+	if strings.TrimSpace(input.Port) == "" {
+		return nil, invalid(errors.New("host port cannot be empty"))
+	}
+	if strings.TrimSpace(input.Protocol) == "" {
+		return nil, invalid(errors.New("host protocol cannot be empty"))
+	}
+	port, err := normalizeHostPort(input.Port)
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := normalizeHostProtocol(input.Protocol, allowedProtocols)
+	if err != nil {
+		return nil, err
+	}
+	if input.MaxUsers < 0 {
+		return nil, invalid(errors.New("host max users cannot be negative"))
+	}
+	network := "tcp" // Set an explicit default network type at the service level if necessary.
+	if input.Network != "" {
+		if network, err = normalizeHostNetwork(input.Network); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.Host{
+		HostName:      input.HostName,
+		Country:       input.Country,
+		City:          input.City,
+		Address:       strings.TrimSpace(input.Address),
+		AddressFamily: customTypes.AddressFamilyOf(input.Address),
+		Port:          port,
+		Protocol:      protocol,
+		Network:       network,
+		PublicKey:     input.PublicKey,
+		Flow:          input.Flow,
+		RSID:          input.RSID,
+		SecurityType:  input.SecurityType,
+		SNI:           input.SNI,
+		Fingerprint:   input.Fingerprint,
+		IsPrivate:     input.IsPrivate,
+		IsFreeTier:    input.IsFreeTier,
+		MaxUsers:      input.MaxUsers,
+		IsOnline:      false, // New hosts are considered offline by default until a status check.
+		Status:        customTypes.StatusUnknown,
+		Region:        input.Region,
+		Provider:      input.Provider,
+		Notes:         input.Notes,
+	}, nil
+}
+
+// hostConfigChanges returns the configuration columns of current that differ from desired, keyed by
+// column name. The endpoint columns, status and operational state are not compared.
+func hostConfigChanges(current, desired *models.Host) map[string]any {
+	changes := make(map[string]any)
+	set := func(column string, currentValue, desiredValue any) {
+		if currentValue != desiredValue {
+			changes[column] = desiredValue
+		}
+	}
+	set("host_name", current.HostName, desired.HostName)
+	set("country", current.Country, desired.Country)
+	set("city", current.City, desired.City)
+	set("region", current.Region, desired.Region)
+	set("provider", current.Provider, desired.Provider)
+	set("public_key", current.PublicKey, desired.PublicKey)
+	set("flow", current.Flow, desired.Flow)
+	set("rsid", current.RSID, desired.RSID)
+	set("security_type", current.SecurityType, desired.SecurityType)
+	set("sni", current.SNI, desired.SNI)
+	set("fingerprint", current.Fingerprint, desired.Fingerprint)
+	set("is_private", current.IsPrivate, desired.IsPrivate)
+	set("is_free_tier", current.IsFreeTier, desired.IsFreeTier)
+	set("max_users", current.MaxUsers, desired.MaxUsers)
+	set("notes", current.Notes, desired.Notes)
+	return changes
+}
+
+// validatePlan checks that a plan has a name, a valid duration and a non-negative price.
+func validatePlan(plan *models.Plan) error {
+	if plan.Name == "" {
+		return invalid(errors.New("invalid plan: name cannot be empty"))
+	}
+	if plan.DurationUnit == "" || !plan.DurationUnit.IsValid() {
+		return invalid(fmt.Errorf("invalid plan: invalid or empty duration unit '%s'", plan.DurationUnit))
+	}
+	if plan.DurationValue <= 0 {
+		return invalid(errors.New("invalid plan: duration value must be positive"))
+	}
+	if plan.Price < 0 {
+		return invalid(errors.New("invalid plan: price cannot be negative"))
+	}
+	if plan.Currency != "" && len(plan.Currency) != 3 {
+		return invalid(fmt.Errorf("invalid plan: currency '%s' must be a 3-letter ISO 4217 code", plan.Currency))
+	}
+	return nil
+}
+
+// allowedHostNetworks lists the transport types a host may use.
+var allowedHostNetworks = []string{"tcp", "ws", "grpc", "kcp"}
+
+// normalizeHostPort checks that port is an integer between 1 and 65535 and returns it in canonical form.
+func normalizeHostPort(port string) (string, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil || value < 1 || value > 65535 {
+		return "", fmt.Errorf("%w: '%s' must be an integer between 1 and 65535", ErrInvalidHostPort, port)
+	}
+	return strconv.Itoa(value), nil
+}
+
+// normalizeHostProtocol checks that protocol is one of allowedProtocols (case-insensitively) and returns it in lower case.
+func normalizeHostProtocol(protocol string, allowedProtocols []string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(protocol))
+	if !slices.Contains(allowedProtocols, normalized) {
+		return "", fmt.Errorf("%w: '%s' is not one of %s", ErrInvalidHostProtocol, protocol, strings.Join(allowedProtocols, ", "))
+	}
+	return normalized, nil
+}
+
+// normalizeHostNetwork checks that network is one of allowedHostNetworks (case-insensitively) and returns it in lower case.
+func normalizeHostNetwork(network string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(network))
+	if !slices.Contains(allowedHostNetworks, normalized) {
+		return "", fmt.Errorf("%w: '%s' is not one of %s", ErrInvalidHostNetwork, network, strings.Join(allowedHostNetworks, ", "))
+	}
+	return normalized, nil
+}
+
+// newSubscriptionCreatedEvent builds the event published after sub has been persisted.
+func newSubscriptionCreatedEvent(sub *models.Subscription) events.SubscriptionCreated {
+	return events.SubscriptionCreated{
+		SubscriptionID: sub.ID,
+		UserID:         sub.UserID,
+		PlanID:         sub.PlanID,
+		PlanName:       sub.PlanName,
+		StartDate:      sub.StartDate,
+		EndDate:        sub.EndDate,
+		Price:          sub.Price,
+		Currency:       sub.Currency,
+		PaymentStatus:  sub.PaymentStatus,
+		OccurredAt:     time.Now(),
+	}
+}
+
+// activatesSubscription reports whether a subscription with paymentStatus is active during its term:
+// paid subscriptions and trials are, pending or failed payments are not.
+func activatesSubscription(paymentStatus string) bool {
+	return paymentStatus == "paid" || paymentStatus == "trial"
+}
+
+// newSubscriptionEvent builds a history entry for a subscription change.
+// oldValue and newValue hold the changed fields and may be nil; actorUserID is nil for system jobs.
+func newSubscriptionEvent(eventType customTypes.SubscriptionEventType, actorUserID *uuid.UUID, oldValue, newValue map[string]any) *models.SubscriptionEvent {
+	return &models.SubscriptionEvent{
+		EventType:   eventType,
+		OldValue:    subscriptionEventValue(oldValue),
+		NewValue:    subscriptionEventValue(newValue),
+		ActorUserID: actorUserID,
+	}
+}
+
+// subscriptionEventValue encodes the fields of a subscription event as JSON; nil fields are stored as NULL.
+func subscriptionEventValue(fields map[string]any) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	value, err := json.Marshal(fields)
+	if err != nil {
+		// The fields are plain values, so this only happens on a programming error.
+		return json.RawMessage(fmt.Sprintf(`{"marshal_error": %q}`, err.Error()))
+	}
+	return value
+}
+
+// subscriptionSnapshot returns the fields of a new subscription recorded in its creation event.
+func subscriptionSnapshot(sub *models.Subscription) map[string]any {
+	return map[string]any{
+		"plan_name":      sub.PlanName,
+		"start_date":     sub.StartDate,
+		"end_date":       sub.EndDate,
+		"price":          sub.Price,
+		"currency":       sub.Currency,
+		"payment_status": sub.PaymentStatus,
+		"is_active":      sub.IsActive,
+		"auto_renew":     sub.AutoRenew,
+	}
+}
+
+// normalizeEmail returns the canonical, lower-case form of an email address.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizePromoCode returns the canonical, upper-case form of a promo code.
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// checkPromoCodeRedeemable returns an error if the promo code is inactive, outside its validity window at now,
+// or has reached its maximum number of uses.
+func checkPromoCodeRedeemable(promoCode *models.PromoCode, now time.Time) error {
+	if !promoCode.IsActive {
+		return invalid(fmt.Errorf("invalid promo code: '%s' is not active", promoCode.Code))
+	}
+	if promoCode.ValidFrom != nil && now.Before(*promoCode.ValidFrom) {
+		return invalid(fmt.Errorf("invalid promo code: '%s' is not valid before %s", promoCode.Code, promoCode.ValidFrom.Format(time.RFC3339)))
+	}
+	if promoCode.ValidUntil != nil && now.After(*promoCode.ValidUntil) {
+		return invalid(fmt.Errorf("invalid promo code: '%s' expired at %s", promoCode.Code, promoCode.ValidUntil.Format(time.RFC3339)))
+	}
+	if promoCode.MaxUses != nil && promoCode.UsedCount >= *promoCode.MaxUses {
+		return invalid(fmt.Errorf("invalid promo code: '%s' has reached its maximum number of uses", promoCode.Code))
+	}
+	return nil
+}
+
+// applyPromoDiscount returns the price after the promo code's discount, rounded to cents and never below zero.
+func applyPromoDiscount(price float64, promoCode *models.PromoCode) float64 {
+	discounted := price
+	if promoCode.DiscountPercent > 0 {
+		discounted = price * (1 - promoCode.DiscountPercent/100)
+	} else if promoCode.DiscountAmount > 0 {
+		discounted = price - promoCode.DiscountAmount
+	}
+	if discounted < 0 {
+		return 0
 	}
+	return math.Round(discounted*100) / 100
 }
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizePage(t *testing.T) {
+	tests := []struct {
+		name         string
+		page         int
+		pageSize     int
+		wantPage     int
+		wantPageSize int
+	}{
+		{name: "valid values are kept", page: 3, pageSize: 25, wantPage: 3, wantPageSize: 25},
+		{name: "zero values use the defaults", page: 0, pageSize: 0, wantPage: 1, wantPageSize: defaultPageSize},
+		{name: "negative values use the defaults", page: -2, pageSize: -10, wantPage: 1, wantPageSize: defaultPageSize},
+		{name: "largest page size", page: 1, pageSize: maxPageSize, wantPage: 1, wantPageSize: maxPageSize},
+		{name: "page size is capped", page: 2, pageSize: maxPageSize + 1, wantPage: 2, wantPageSize: maxPageSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, pageSize := normalizePage(tt.page, tt.pageSize)
+			if page != tt.wantPage || pageSize != tt.wantPageSize {
+				t.Errorf("normalizePage(%d, %d) = %d, %d; want %d, %d", tt.page, tt.pageSize, page, pageSize, tt.wantPage, tt.wantPageSize)
+			}
+		})
+	}
+}
+
+func TestHostSecurityError(t *testing.T) {
+	tests := []struct {
+		name         string
+		securityType string
+		publicKey    string
+		wantErr      bool
+	}{
+		{name: "reality with a public key", securityType: "reality", publicKey: "pbk"},
+		{name: "reality without a public key", securityType: "reality", wantErr: true},
+		{name: "reality with a blank public key", securityType: "reality", publicKey: "  ", wantErr: true},
+		{name: "security type is case-insensitive", securityType: "REALITY", wantErr: true},
+		{name: "tls needs no public key", securityType: "tls"},
+		{name: "no security", securityType: "none"},
+		{name: "unset security type", securityType: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hostSecurityError(&models.Host{SecurityType: tt.securityType, PublicKey: tt.publicKey})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostSecurityError() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrValidation) {
+				t.Errorf("hostSecurityError() error = %v, want %v", err, ErrValidation)
+			}
+		})
+	}
+}
+
+func TestSubscriptionFingerprint(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	base := func() dto.CreateSubscriptionInput {
+		return dto.CreateSubscriptionInput{UserID: uuid.MustParse("0190a5a8-0000-7000-8000-000000000001"), PlanName: "Pro",
+			DurationUnit: customTypes.UnitMonth, DurationValue: 1, StartDate: time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC),
+			PaymentStatus: "pending", Currency: ptr("EUR"), PromoCode: ptr("SPRING")}
+	}
+	zero, planID := 0.0, uint(3)
+
+	tests := []struct {
+		name     string
+		mutate   func(in *dto.CreateSubscriptionInput)
+		wantSame bool
+	}{
+		{name: "identical input", mutate: func(*dto.CreateSubscriptionInput) {}, wantSame: true},
+		{name: "currency case and spacing", mutate: func(in *dto.CreateSubscriptionInput) { in.Currency = ptr(" eur ") }, wantSame: true},
+		{name: "promo code case and spacing", mutate: func(in *dto.CreateSubscriptionInput) { in.PromoCode = ptr(" spring ") }, wantSame: true},
+		{name: "start date in another zone", mutate: func(in *dto.CreateSubscriptionInput) { in.StartDate = in.StartDate.In(time.FixedZone("CEST", 2*3600)) }, wantSame: true},
+		{name: "actor is not client input", mutate: func(in *dto.CreateSubscriptionInput) { actor := uuid.New(); in.ActorUserID = &actor }, wantSame: true},
+		{name: "currency omitted", mutate: func(in *dto.CreateSubscriptionInput) { in.Currency = nil }},
+		{name: "explicit zero price", mutate: func(in *dto.CreateSubscriptionInput) { in.Price = &zero }},
+		{name: "plan ID", mutate: func(in *dto.CreateSubscriptionInput) { in.PlanID = &planID }},
+		{name: "other promo code", mutate: func(in *dto.CreateSubscriptionInput) { in.PromoCode = ptr("SUMMER") }},
+		{name: "promo code omitted", mutate: func(in *dto.CreateSubscriptionInput) { in.PromoCode = nil }},
+		{name: "payment status", mutate: func(in *dto.CreateSubscriptionInput) { in.PaymentStatus = "paid" }},
+		{name: "auto-renew", mutate: func(in *dto.CreateSubscriptionInput) { in.AutoRenew = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base()
+			tt.mutate(&changed)
+			if same := subscriptionFingerprint(changed) == subscriptionFingerprint(base()); same != tt.wantSame {
+				t.Errorf("fingerprints equal = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"bitback/internal/models"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+// HostSelector makes the random choices of host selection in Go rather than in SQL.
+// Its randomness source is injectable, so a seeded source makes the selection reproducible.
+type HostSelector struct {
+	mu  sync.Mutex // Guards rng, which is not safe for concurrent use.
+	rng *rand.Rand
+}
+
+// NewHostSelector creates a HostSelector drawing from src, or from a randomly seeded source if src is nil.
+func NewHostSelector(src rand.Source) *HostSelector {
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return &HostSelector{rng: rand.New(src)}
+}
+
+// Pick returns one of candidates chosen uniformly at random, or nil if there are none.
+func (s *HostSelector) Pick(candidates []models.Host) *models.Host {
+	if len(candidates) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	i := s.rng.IntN(len(candidates))
+	s.mu.Unlock()
+	return &candidates[i]
+}
+
+// PickPerCountry picks one host at random for every country of candidates, which must be ordered by country
+// (compared case-insensitively). The picked hosts keep the order of their countries.
+func (s *HostSelector) PickPerCountry(candidates []models.Host) []models.Host {
+	picked := make([]models.Host, 0, len(candidates))
+	for start := 0; start < len(candidates); {
+		end := start + 1
+		for end < len(candidates) && strings.EqualFold(candidates[end].Country, candidates[start].Country) {
+			end++
+		}
+		picked = append(picked, *s.Pick(candidates[start:end]))
+		start = end
+	}
+	return picked
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bitback/internal/models"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestHostSelectorPick(t *testing.T) {
+	hosts := []models.Host{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	tests := []struct {
+		name       string
+		candidates []models.Host
+		want       []uint // Hosts that may be picked; nil if no host may be.
+	}{
+		{name: "no candidates"},
+		{name: "single candidate", candidates: hosts[2:3], want: []uint{3}},
+		{name: "several candidates", candidates: hosts, want: []uint{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewHostSelector(rand.NewPCG(1, 2))
+			b := NewHostSelector(rand.NewPCG(1, 2))
+			seen := map[uint]bool{}
+			for range 100 {
+				got, again := a.Pick(tt.candidates), b.Pick(tt.candidates)
+				if tt.want == nil {
+					if got != nil {
+						t.Fatalf("Pick() = host %d, want nil", got.ID)
+					}
+					continue
+				}
+				if got == nil || again == nil {
+					t.Fatal("Pick() = nil, want a host")
+				}
+				if got.ID != again.ID {
+					t.Fatalf("selectors with the same seed picked hosts %d and %d", got.ID, again.ID)
+				}
+				if !slices.Contains(tt.want, got.ID) {
+					t.Fatalf("Pick() = host %d, want one of %v", got.ID, tt.want)
+				}
+				seen[got.ID] = true
+			}
+			if len(seen) != len(tt.want) {
+				t.Errorf("picked hosts %v in 100 draws, want every one of %v", seen, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostSelectorPickPerCountry(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []models.Host
+		want       [][]uint // Per picked host in order, the hosts it may be.
+	}{
+		{name: "no candidates", want: [][]uint{}},
+		{name: "one candidate per country",
+			candidates: []models.Host{{ID: 1, Country: "DE"}, {ID: 2, Country: "NL"}},
+			want:       [][]uint{{1}, {2}}},
+		{name: "several candidates per country",
+			candidates: []models.Host{{ID: 1, Country: "DE"}, {ID: 4, Country: "DE"}, {ID: 2, Country: "NL"}, {ID: 3, Country: "US"}, {ID: 5, Country: "US"}},
+			want:       [][]uint{{1, 4}, {2}, {3, 5}}},
+		{name: "countries compared case-insensitively",
+			candidates: []models.Host{{ID: 1, Country: "de"}, {ID: 2, Country: "DE"}, {ID: 3, Country: "nl"}},
+			want:       [][]uint{{1, 2}, {3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := range uint64(20) {
+				picked := NewHostSelector(rand.NewPCG(seed, seed)).PickPerCountry(tt.candidates)
+				if len(picked) != len(tt.want) {
+					t.Fatalf("PickPerCountry() picked %d hosts, want %d", len(picked), len(tt.want))
+				}
+				var ids []uint
+				for i, host := range picked {
+					if !slices.Contains(tt.want[i], host.ID) {
+						t.Fatalf("PickPerCountry() host %d = %d, want one of %v", i, host.ID, tt.want[i])
+					}
+					ids = append(ids, host.ID)
+				}
+				again := NewHostSelector(rand.NewPCG(seed, seed)).PickPerCountry(tt.candidates)
+				for i := range again {
+					if again[i].ID != ids[i] {
+						t.Fatalf("seed %d: picks %v are not reproducible, got host %d at %d", seed, ids, again[i].ID, i)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
 	"bitback/internal/models/customTypes"
@@ -15,13 +16,21 @@ import (
 )
 
 type hostService struct {
-	hostRepo interfaces.HostRepository
+	hostRepo      interfaces.HostRepository
+	hostCheckRepo interfaces.HostCheckRepository
+	tx            interfaces.Transactor
+	cfg           *config.Config
+	availability  *hostAvailabilityCache
 }
 
 // NewHostService creates a new instance of hostService.
-func NewHostService(hr interfaces.HostRepository) interfaces.HostService {
+func NewHostService(hr interfaces.HostRepository, hcr interfaces.HostCheckRepository, tx interfaces.Transactor, cfg *config.Config) interfaces.HostService {
 	return &hostService{
-		hostRepo: hr,
+		hostRepo:      hr,
+		hostCheckRepo: hcr,
+		tx:            tx,
+		cfg:           cfg,
+		availability:  newHostAvailabilityCache(cfg.HostAvailabilityCacheTTL),
 	}
 }
 
@@ -30,65 +39,279 @@ func NewHostService(hr interfaces.HostRepository) interfaces.HostService {
 func (s *hostService) AddHost(ctx context.Context, input dto.CreateHostInput) (*models.Host, error) {
 	slog.InfoContext(ctx, "AddHost: attempting to add new host", "address", input.Address, "port", input.Port, "protocol", input.Protocol)
 
-	// Perform basic input validation.
-	if strings.TrimSpace(input.Address) == "" {
-		return nil, errors.New("host address cannot be empty")
-	}
-	if strings.TrimSpace(input.Port) == "" {
-		return nil, errors.New("host port cannot be empty")
-	}
-	if strings.TrimSpace(input.Protocol) == "" {
-		return nil, errors.New("host protocol cannot be empty")
+	host, err := newHostFromInput(input, s.cfg.AllowedHostProtocols)
+	if err != nil {
+		slog.WarnContext(ctx, "AddHost: invalid host input", "address", input.Address, "error", err)
+		return nil, err
 	}
-	network := input.Network
-	if network == "" {
-		network = "tcp" // Set an explicit default network type at the service level if necessary.
+	if err := s.checkHostSecurity(host); err != nil {
+		slog.WarnContext(ctx, "AddHost: incomplete security configuration", "address", input.Address, "securityType", host.SecurityType, "error", err)
+		return nil, err
 	}
-	// TODO: Implement more comprehensive validation (e.g., IP/domain format, port range, allowed protocols).
 
 	// Verify that a host with the same address, port, protocol, and network does not already exist.
-	existingHost, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, input.Address, input.Port, input.Protocol, network)
+	existingHost, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, host.Address, host.Port, host.Protocol, host.Network)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		slog.ErrorContext(ctx, "AddHost: error checking for existing host", "address", input.Address, "error", err)
-		return nil, fmt.Errorf("could not verify host uniqueness: %w", err)
+		return nil, contextAware(fmt.Errorf("could not verify host uniqueness: %w", err))
 	}
 	if existingHost != nil {
-		slog.WarnContext(ctx, "AddHost: host already exists", "address", input.Address, "port", input.Port, "protocol", input.Protocol, "network", network, "existingID", existingHost.ID)
-		return nil, fmt.Errorf("host with address '%s', port '%s', protocol '%s', and network '%s' already exists", input.Address, input.Port, input.Protocol, network)
-	}
-
-	// Prepare the Host model for creation.
-	host := &models.Host{
-		HostName:     input.HostName,
-		Country:      input.Country,
-		City:         input.City,
-		Address:      input.Address,
-		Port:         input.Port,
-		Protocol:     input.Protocol,
-		Network:      network,
-		PublicKey:    input.PublicKey,
-		Flow:         input.Flow,
-		RSID:         input.RSID,
-		SecurityType: input.SecurityType,
-		SNI:          input.SNI,
-		Fingerprint:  input.Fingerprint,
-		IsPrivate:    input.IsPrivate,
-		IsOnline:     false, // New hosts are considered offline by default until a status check.
-		Status:       customTypes.StatusUnknown,
-		Region:       input.Region,
-		Provider:     input.Provider,
+		slog.WarnContext(ctx, "AddHost: host already exists", "address", host.Address, "port", host.Port, "protocol", host.Protocol, "network", host.Network, "existingID", existingHost.ID)
+		return nil, conflict(fmt.Errorf("host with address '%s', port '%s', protocol '%s', and network '%s' already exists", host.Address, host.Port, host.Protocol, host.Network))
+	}
+	if err := s.checkHostNameAvailable(ctx, host.HostName, 0); err != nil {
+		slog.WarnContext(ctx, "AddHost: host name not available", "hostName", host.HostName, "error", err)
+		return nil, err
 	}
 
 	// Persist the new host to the repository.
 	if err := s.hostRepo.Create(ctx, host); err != nil {
+		if errors.Is(err, interfaces.ErrHostNameTaken) {
+			slog.WarnContext(ctx, "AddHost: host name already in use", "hostName", host.HostName)
+			return nil, conflict(fmt.Errorf("host with name '%s' already exists: %w", host.HostName, err))
+		}
 		slog.ErrorContext(ctx, "AddHost: failed to create host in repository", "address", input.Address, "error", err)
-		return nil, fmt.Errorf("could not add host: %w", err)
+		return nil, contextAware(fmt.Errorf("could not add host: %w", err))
 	}
 
 	slog.InfoContext(ctx, "AddHost: host added successfully", "hostID", host.ID, "address", host.Address)
 	return host, nil
 }
 
+// AddHosts creates multiple hosts in one request.
+// Each entry is validated and checked for uniqueness individually. In atomic mode any invalid or duplicate
+// entry rejects the whole batch; otherwise such entries are reported and skipped, while the remaining hosts
+// are inserted together in a single transaction.
+func (s *hostService) AddHosts(ctx context.Context, inputs []dto.CreateHostInput, atomic bool) (*dto.BulkAddHostsResult, error) {
+	slog.InfoContext(ctx, "AddHosts: attempting to add hosts in bulk", "count", len(inputs), "atomic", atomic)
+
+	result := &dto.BulkAddHostsResult{
+		Results: make([]dto.BulkHostResult, len(inputs)),
+	}
+	hostsToCreate := make([]*models.Host, 0, len(inputs))
+	resultIndexes := make([]int, 0, len(inputs)) // Maps each host in hostsToCreate to its entry in Results.
+	seen := make(map[string]int, len(inputs))    // Uniqueness key -> index of the first entry using it.
+	seenNames := make(map[string]int)            // Lower-cased host name -> index of the first entry using it; only used when names must be unique.
+
+	for i, input := range inputs {
+		result.Results[i].Index = i
+
+		host, err := newHostFromInput(input, s.cfg.AllowedHostProtocols)
+		if err == nil {
+			err = s.checkHostSecurity(host)
+		}
+		if err != nil {
+			result.Results[i].Status = dto.BulkHostStatusInvalid
+			result.Results[i].Error = err.Error()
+			result.Invalid++
+			continue
+		}
+
+		key := strings.Join([]string{host.Address, host.Port, host.Protocol, host.Network}, "|")
+		if firstIndex, ok := seen[key]; ok {
+			result.Results[i].Status = dto.BulkHostStatusDuplicate
+			result.Results[i].Error = fmt.Sprintf("duplicate of entry %d in the same batch", firstIndex)
+			result.Duplicates++
+			continue
+		}
+
+		existingHost, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, host.Address, host.Port, host.Protocol, host.Network)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "AddHosts: error checking for existing host", "index", i, "address", host.Address, "error", err)
+			return nil, contextAware(fmt.Errorf("could not verify host uniqueness for entry %d: %w", i, err))
+		}
+		if existingHost != nil {
+			result.Results[i].Status = dto.BulkHostStatusDuplicate
+			result.Results[i].Error = fmt.Sprintf("host with address '%s', port '%s', protocol '%s', and network '%s' already exists", host.Address, host.Port, host.Protocol, host.Network)
+			result.Duplicates++
+			continue
+		}
+
+		nameKey := strings.ToLower(host.HostName)
+		if s.cfg.EnforceUniqueHostNames && nameKey != "" {
+			if firstIndex, ok := seenNames[nameKey]; ok {
+				result.Results[i].Status = dto.BulkHostStatusDuplicate
+				result.Results[i].Error = fmt.Sprintf("host name duplicates entry %d in the same batch", firstIndex)
+				result.Duplicates++
+				continue
+			}
+		}
+		if err := s.checkHostNameAvailable(ctx, host.HostName, 0); err != nil {
+			if !strings.Contains(err.Error(), "already exists") {
+				slog.ErrorContext(ctx, "AddHosts: error checking host name", "index", i, "hostName", host.HostName, "error", err)
+				return nil, contextAware(fmt.Errorf("could not verify host name uniqueness for entry %d: %w", i, err))
+			}
+			result.Results[i].Status = dto.BulkHostStatusDuplicate
+			result.Results[i].Error = err.Error()
+			result.Duplicates++
+			continue
+		}
+
+		seen[key] = i
+		if s.cfg.EnforceUniqueHostNames && nameKey != "" {
+			seenNames[nameKey] = i
+		}
+		hostsToCreate = append(hostsToCreate, host)
+		resultIndexes = append(resultIndexes, i)
+	}
+
+	if atomic && (result.Invalid > 0 || result.Duplicates > 0) {
+		for _, i := range resultIndexes {
+			result.Results[i].Status = dto.BulkHostStatusRejected
+			result.Results[i].Error = "not created because other entries in the atomic batch failed"
+		}
+		slog.WarnContext(ctx, "AddHosts: atomic bulk host import rejected", "submitted", len(inputs), "duplicates", result.Duplicates, "invalid", result.Invalid)
+		return result, nil
+	}
+
+	if err := s.hostRepo.CreateBatch(ctx, hostsToCreate); err != nil {
+		if errors.Is(err, interfaces.ErrHostNameTaken) {
+			slog.WarnContext(ctx, "AddHosts: host name taken concurrently", "count", len(hostsToCreate))
+			return nil, conflict(fmt.Errorf("host name already exists: %w", err))
+		}
+		slog.ErrorContext(ctx, "AddHosts: failed to create hosts in repository", "count", len(hostsToCreate), "error", err)
+		return nil, contextAware(fmt.Errorf("could not add hosts: %w", err))
+	}
+	for n, host := range hostsToCreate {
+		i := resultIndexes[n]
+		result.Results[i].Status = dto.BulkHostStatusCreated
+		result.Results[i].Host = host
+	}
+	result.Created = len(hostsToCreate)
+	result.Committed = true
+
+	slog.InfoContext(ctx, "AddHosts: bulk host import completed", "submitted", len(inputs), "created", result.Created, "duplicates", result.Duplicates, "invalid", result.Invalid)
+	return result, nil
+}
+
+// ExportHosts retrieves every non-deleted host for a configuration bundle.
+func (s *hostService) ExportHosts(ctx context.Context) ([]models.Host, error) {
+	hosts, err := s.hostRepo.ListAll(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "ExportHosts: failed to list hosts from repository", "error", err)
+		return nil, contextAware(fmt.Errorf("could not export hosts: %w", err))
+	}
+	slog.InfoContext(ctx, "ExportHosts: hosts exported successfully", "count", len(hosts))
+	return hosts, nil
+}
+
+// ImportHosts restores hosts from a configuration bundle.
+// All entries are validated first; the bundle is then applied in one transaction, creating missing hosts
+// and writing only the changed configuration columns of existing ones. Operational state such as the online
+// flag and issued-key counters is never overwritten.
+func (s *hostService) ImportHosts(ctx context.Context, inputs []dto.ImportHostInput) (*dto.ImportHostsResult, error) {
+	slog.InfoContext(ctx, "ImportHosts: attempting to import host bundle", "count", len(inputs))
+
+	result := &dto.ImportHostsResult{
+		Results: make([]dto.ImportHostResult, len(inputs)),
+	}
+	hosts := make([]*models.Host, len(inputs))
+	seen := make(map[string]int, len(inputs)) // Uniqueness key -> index of the first entry using it.
+	for i, input := range inputs {
+		result.Results[i].Index = i
+
+		host, err := newHostFromInput(input.CreateHostInput, s.cfg.AllowedHostProtocols)
+		if err == nil && input.Status != "" {
+			if !input.Status.IsValid() {
+				err = invalid(fmt.Errorf("invalid host status '%s'", input.Status))
+			} else {
+				host.Status = input.Status
+			}
+		}
+		if err == nil {
+			err = s.checkHostSecurity(host)
+		}
+		if err == nil {
+			key := strings.Join([]string{host.Address, host.Port, host.Protocol, host.Network}, "|")
+			if firstIndex, ok := seen[key]; ok {
+				err = fmt.Errorf("duplicate of entry %d in the same bundle", firstIndex)
+			}
+			seen[key] = i
+		}
+		if err != nil {
+			result.Results[i].Status = dto.ImportHostStatusInvalid
+			result.Results[i].Error = err.Error()
+			result.Invalid++
+			continue
+		}
+		hosts[i] = host
+	}
+	if result.Invalid > 0 {
+		slog.WarnContext(ctx, "ImportHosts: host bundle rejected", "count", len(inputs), "invalid", result.Invalid)
+		return result, nil
+	}
+
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		for i, host := range hosts {
+			status, hostID, err := s.importHost(ctx, host)
+			if err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+			result.Results[i].Status = status
+			result.Results[i].HostID = hostID
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "ImportHosts: failed to import host bundle", "error", err)
+		return nil, err
+	}
+
+	for _, entry := range result.Results {
+		switch entry.Status {
+		case dto.ImportHostStatusCreated:
+			result.Created++
+		case dto.ImportHostStatusUpdated:
+			result.Updated++
+		case dto.ImportHostStatusUnchanged:
+			result.Unchanged++
+		}
+	}
+	result.Committed = true
+
+	slog.InfoContext(ctx, "ImportHosts: host bundle imported", "count", len(inputs), "created", result.Created, "updated", result.Updated, "unchanged", result.Unchanged)
+	return result, nil
+}
+
+// importHost creates host, or updates the configuration of the existing host with the same endpoint.
+// It returns the ImportHostStatus* outcome and the ID of the created or matched host.
+func (s *hostService) importHost(ctx context.Context, host *models.Host) (string, uint, error) {
+	existing, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, host.Address, host.Port, host.Protocol, host.Network)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, contextAware(fmt.Errorf("could not look up host '%s:%s': %w", host.Address, host.Port, err))
+	}
+
+	if existing == nil {
+		if err := s.checkHostNameAvailable(ctx, host.HostName, 0); err != nil {
+			return "", 0, err
+		}
+		if err := s.hostRepo.Create(ctx, host); err != nil {
+			if errors.Is(err, interfaces.ErrHostNameTaken) {
+				return "", 0, conflict(fmt.Errorf("host with name '%s' already exists: %w", host.HostName, err))
+			}
+			return "", 0, contextAware(fmt.Errorf("could not create host '%s:%s': %w", host.Address, host.Port, err))
+		}
+		return dto.ImportHostStatusCreated, host.ID, nil
+	}
+
+	changes := hostConfigChanges(existing, host)
+	if len(changes) == 0 {
+		return dto.ImportHostStatusUnchanged, existing.ID, nil
+	}
+	if name, ok := changes["host_name"].(string); ok {
+		if err := s.checkHostNameAvailable(ctx, name, existing.ID); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := s.hostRepo.Update(ctx, existing, changes); err != nil {
+		if errors.Is(err, interfaces.ErrHostNameTaken) {
+			return "", 0, conflict(fmt.Errorf("host with name '%s' already exists: %w", host.HostName, err))
+		}
+		return "", 0, contextAware(fmt.Errorf("could not update host %d: %w", existing.ID, err))
+	}
+	return dto.ImportHostStatusUpdated, existing.ID, nil
+}
+
 // GetHostByID retrieves a host by its unique ID.
 func (s *hostService) GetHostByID(ctx context.Context, hostID uint) (*models.Host, error) {
 	slog.InfoContext(ctx, "GetHostByID: attempting to get host", "hostID", hostID)
@@ -96,10 +319,10 @@ func (s *hostService) GetHostByID(ctx context.Context, hostID uint) (*models.Hos
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "GetHostByID: host not found", "hostID", hostID)
-			return nil, fmt.Errorf("host with ID %d not found: %w", hostID, err)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
 		}
 		slog.ErrorContext(ctx, "GetHostByID: failed to get host from repository", "hostID", hostID, "error", err)
-		return nil, fmt.Errorf("could not retrieve host: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
 	}
 	slog.InfoContext(ctx, "GetHostByID: host retrieved successfully", "hostID", host.ID)
 	return host, nil
@@ -113,81 +336,204 @@ func (s *hostService) UpdateHost(ctx context.Context, hostID uint, input dto.Upd
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "UpdateHost: host to update not found", "hostID", hostID)
-			return nil, fmt.Errorf("host with ID %d not found for update: %w", hostID, err)
+			return nil, notFound(fmt.Errorf("host with ID %d not found for update: %w", hostID, err))
 		}
 		slog.ErrorContext(ctx, "UpdateHost: failed to retrieve host for update", "hostID", hostID, "error", err)
-		return nil, fmt.Errorf("could not retrieve host for update: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host for update: %w", err))
 	}
 
-	changesMade := false
+	// Only the changed columns are written, so concurrent updates of other columns, such as the
+	// status written by the health checker, are not overwritten.
+	changes := make(map[string]any)
 	if input.HostName != nil && *input.HostName != host.HostName {
+		if err := s.checkHostNameAvailable(ctx, *input.HostName, host.ID); err != nil {
+			slog.WarnContext(ctx, "UpdateHost: host name not available", "hostID", hostID, "hostName", *input.HostName, "error", err)
+			return nil, err
+		}
 		host.HostName = *input.HostName
-		changesMade = true
+		changes["host_name"] = host.HostName
 	}
 	if input.Country != nil && *input.Country != host.Country {
 		host.Country = *input.Country
-		changesMade = true
+		changes["country"] = host.Country
 	}
 	if input.City != nil && *input.City != host.City {
 		host.City = *input.City
-		changesMade = true
+		changes["city"] = host.City
 	}
 	if input.Flow != nil && *input.Flow != host.Flow {
 		host.Flow = *input.Flow
-		changesMade = true
+		changes["flow"] = host.Flow
 	}
 	if input.RSID != nil && *input.RSID != host.RSID {
 		host.RSID = *input.RSID
-		changesMade = true
+		changes["rsid"] = host.RSID
 	}
 	if input.SecurityType != nil && *input.SecurityType != host.SecurityType {
 		host.SecurityType = *input.SecurityType
-		changesMade = true
+		changes["security_type"] = host.SecurityType
 	}
 	if input.SNI != nil && *input.SNI != host.SNI {
 		host.SNI = *input.SNI
-		changesMade = true
+		changes["sni"] = host.SNI
 	}
 	if input.Fingerprint != nil && *input.Fingerprint != host.Fingerprint {
 		host.Fingerprint = *input.Fingerprint
-		changesMade = true
+		changes["fingerprint"] = host.Fingerprint
 	}
 	if input.IsPrivate != nil && *input.IsPrivate != host.IsPrivate {
 		host.IsPrivate = *input.IsPrivate
-		changesMade = true
+		changes["is_private"] = host.IsPrivate
+	}
+	if input.IsFreeTier != nil && *input.IsFreeTier != host.IsFreeTier {
+		host.IsFreeTier = *input.IsFreeTier
+		changes["is_free_tier"] = host.IsFreeTier
+	}
+	if input.MaxUsers != nil && *input.MaxUsers != host.MaxUsers {
+		if *input.MaxUsers < 0 {
+			slog.WarnContext(ctx, "UpdateHost: negative max users provided", "hostID", hostID, "maxUsers", *input.MaxUsers)
+			return nil, invalid(errors.New("host max users cannot be negative"))
+		}
+		host.MaxUsers = *input.MaxUsers
+		changes["max_users"] = host.MaxUsers
 	}
 	if input.PublicKey != nil && *input.PublicKey != host.PublicKey {
 		host.PublicKey = *input.PublicKey
-		changesMade = true
+		changes["public_key"] = host.PublicKey
 	}
 	if input.Region != nil && *input.Region != host.Region {
 		host.Region = *input.Region
-		changesMade = true
+		changes["region"] = host.Region
 	}
 	if input.Provider != nil && *input.Provider != host.Provider {
 		host.Provider = *input.Provider
-		changesMade = true
+		changes["provider"] = host.Provider
+	}
+	if input.Notes != nil && *input.Notes != host.Notes {
+		host.Notes = *input.Notes
+		changes["notes"] = host.Notes
+	}
+	// Address, port, protocol and network identify the endpoint; changes to them are validated
+	// and must not collide with another host.
+	endpointChanged := false
+	if input.Address != nil && strings.TrimSpace(*input.Address) != host.Address {
+		if strings.TrimSpace(*input.Address) == "" {
+			return nil, invalid(errors.New("host address cannot be empty"))
+		}
+		host.Address = strings.TrimSpace(*input.Address)
+		host.AddressFamily = customTypes.AddressFamilyOf(host.Address)
+		changes["address"] = host.Address
+		changes["address_family"] = host.AddressFamily
+		endpointChanged = true
+	}
+	if input.Port != nil && *input.Port != host.Port {
+		port, err := normalizeHostPort(*input.Port)
+		if err != nil {
+			slog.WarnContext(ctx, "UpdateHost: invalid port", "hostID", hostID, "port", *input.Port)
+			return nil, err
+		}
+		if port != host.Port {
+			host.Port = port
+			changes["port"] = host.Port
+			endpointChanged = true
+		}
+	}
+	if input.Protocol != nil && *input.Protocol != host.Protocol {
+		protocol, err := normalizeHostProtocol(*input.Protocol, s.cfg.AllowedHostProtocols)
+		if err != nil {
+			slog.WarnContext(ctx, "UpdateHost: invalid protocol", "hostID", hostID, "protocol", *input.Protocol)
+			return nil, err
+		}
+		if protocol != host.Protocol {
+			host.Protocol = protocol
+			changes["protocol"] = host.Protocol
+			endpointChanged = true
+		}
 	}
 	if input.Network != nil && *input.Network != host.Network {
-		// TODO: If Address, Port, Protocol, or Network fields are changed,
-		host.Network = *input.Network
-		changesMade = true
+		network, err := normalizeHostNetwork(*input.Network)
+		if err != nil {
+			slog.WarnContext(ctx, "UpdateHost: invalid network", "hostID", hostID, "network", *input.Network)
+			return nil, err
+		}
+		if network != host.Network {
+			host.Network = network
+			changes["network"] = host.Network
+			endpointChanged = true
+		}
+	}
+	if endpointChanged {
+		existingHost, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, host.Address, host.Port, host.Protocol, host.Network)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "UpdateHost: error checking for existing host", "hostID", hostID, "error", err)
+			return nil, contextAware(fmt.Errorf("could not verify host uniqueness: %w", err))
+		}
+		if existingHost != nil && existingHost.ID != host.ID {
+			slog.WarnContext(ctx, "UpdateHost: another host already uses the endpoint", "hostID", hostID, "existingID", existingHost.ID)
+			return nil, conflict(fmt.Errorf("host with address '%s', port '%s', protocol '%s', and network '%s' already exists", host.Address, host.Port, host.Protocol, host.Network))
+		}
+	}
+
+	// Hosts must not be left with a security configuration keys cannot be generated for.
+	_, securityTypeChanged := changes["security_type"]
+	_, publicKeyChanged := changes["public_key"]
+	if securityTypeChanged || publicKeyChanged {
+		if err := s.checkHostSecurity(host); err != nil {
+			slog.WarnContext(ctx, "UpdateHost: incomplete security configuration", "hostID", hostID, "securityType", host.SecurityType, "error", err)
+			return nil, err
+		}
 	}
 
-	if !changesMade {
+	if len(changes) == 0 {
 		slog.InfoContext(ctx, "UpdateHost: no actual changes detected for host", "hostID", hostID)
 		return host, nil
 	}
 
-	if err := s.hostRepo.Update(ctx, host); err != nil {
+	if err := s.hostRepo.Update(ctx, host, changes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "UpdateHost: host deleted before the update was saved", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found for update: %w", hostID, err))
+		}
+		if errors.Is(err, interfaces.ErrHostNameTaken) {
+			slog.WarnContext(ctx, "UpdateHost: host name already in use", "hostID", hostID, "hostName", host.HostName)
+			return nil, conflict(fmt.Errorf("host with name '%s' already exists: %w", host.HostName, err))
+		}
 		slog.ErrorContext(ctx, "UpdateHost: failed to update host in repository", "hostID", hostID, "error", err)
-		return nil, fmt.Errorf("could not save host updates: %w", err)
+		return nil, contextAware(fmt.Errorf("could not save host updates: %w", err))
 	}
 
 	slog.InfoContext(ctx, "UpdateHost: host updated successfully", "hostID", host.ID)
 	return host, nil
 }
 
+// checkHostSecurity returns an "invalid" error if complete security configurations are required and host's
+// is incomplete, e.g. a Reality host without a public key, which would fail key generation.
+func (s *hostService) checkHostSecurity(host *models.Host) error {
+	if !s.cfg.RequireHostSecurity {
+		return nil
+	}
+	return hostSecurityError(host)
+}
+
+// checkHostNameAvailable returns an "already exists" error if host-name uniqueness is enforced and another
+// non-deleted host, other than excludeHostID, uses hostName. Empty names are never checked.
+func (s *hostService) checkHostNameAvailable(ctx context.Context, hostName string, excludeHostID uint) error {
+	if !s.cfg.EnforceUniqueHostNames || hostName == "" {
+		return nil
+	}
+	existingHost, err := s.hostRepo.GetByHostName(ctx, hostName)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return contextAware(fmt.Errorf("could not verify host name uniqueness: %w", err))
+	}
+	if existingHost.ID != excludeHostID {
+		return conflict(fmt.Errorf("host with name '%s' already exists", hostName))
+	}
+	return nil
+}
+
 // RemoveHost performs a soft delete on a host.
 // The repository handles the existence check and returns gorm.ErrRecordNotFound if applicable.
 func (s *hostService) RemoveHost(ctx context.Context, hostID uint) error {
@@ -195,56 +541,128 @@ func (s *hostService) RemoveHost(ctx context.Context, hostID uint) error {
 	if err := s.hostRepo.Delete(ctx, hostID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "RemoveHost: host to remove not found", "hostID", hostID)
-			return fmt.Errorf("host with ID %d not found for removal: %w", hostID, err)
+			return notFound(fmt.Errorf("host with ID %d not found for removal: %w", hostID, err))
 		}
 		slog.ErrorContext(ctx, "RemoveHost: failed to remove host from repository", "hostID", hostID, "error", err)
-		return fmt.Errorf("could not remove host: %w", err)
+		return contextAware(fmt.Errorf("could not remove host: %w", err))
 	}
 	slog.InfoContext(ctx, "RemoveHost: host removed successfully", "hostID", hostID)
 	return nil
 }
 
+// RestoreHost clears the soft-deletion of a host. It fails if the host is not soft-deleted, or if an active
+// host now occupies the same address, port, protocol and network, or the same name when names must be unique.
+func (s *hostService) RestoreHost(ctx context.Context, hostID uint) (*models.Host, error) {
+	slog.InfoContext(ctx, "RestoreHost: attempting to restore host", "hostID", hostID)
+
+	host, err := s.hostRepo.GetDeletedByID(ctx, hostID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RestoreHost: deleted host not found", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("deleted host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "RestoreHost: failed to retrieve deleted host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	existingHost, err := s.hostRepo.GetByAddressPortProtocolNetwork(ctx, host.Address, host.Port, host.Protocol, host.Network)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(ctx, "RestoreHost: failed to check for conflicting host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not verify host uniqueness: %w", err))
+	}
+	if existingHost != nil {
+		slog.WarnContext(ctx, "RestoreHost: an active host occupies the same address", "hostID", hostID, "existingHostID", existingHost.ID)
+		return nil, conflict(fmt.Errorf("host with address %s, port %s, protocol %s and network %s already exists (ID %d)",
+			host.Address, host.Port, host.Protocol, host.Network, existingHost.ID))
+	}
+	if err := s.checkHostNameAvailable(ctx, host.HostName, hostID); err != nil {
+		slog.WarnContext(ctx, "RestoreHost: host name is taken", "hostID", hostID, "hostName", host.HostName, "error", err)
+		return nil, err
+	}
+
+	restored, err := s.hostRepo.Restore(ctx, hostID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RestoreHost: host was restored concurrently", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("deleted host with ID %d not found: %w", hostID, err))
+		}
+		if errors.Is(err, interfaces.ErrHostNameTaken) {
+			return nil, conflict(fmt.Errorf("host with name '%s' already exists: %w", host.HostName, err))
+		}
+		slog.ErrorContext(ctx, "RestoreHost: failed to restore host in repository", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not restore host: %w", err))
+	}
+	slog.InfoContext(ctx, "RestoreHost: host restored successfully", "hostID", hostID)
+	return restored, nil
+}
+
 // ListHosts retrieves a paginated and filtered list of hosts.
 func (s *hostService) ListHosts(ctx context.Context, params dto.ListHostsServiceParams) ([]models.Host, int64, error) {
 	slog.InfoContext(ctx, "ListHosts: attempting to list hosts", "params", fmt.Sprintf("%+v", params))
 
 	// Convert service-layer DTO parameters to repository-layer parameters.
-	repoParams := customTypes.ListHostsParams{
-		Country:   params.Country,
-		City:      params.City,
-		Protocol:  params.Protocol,
-		Network:   params.Network,
-		IsOnline:  params.IsOnline,
-		IsPrivate: params.IsPrivate,
-		Status:    params.Status,
-		HostName:  params.HostName,
-		Address:   params.Address,
-		SortBy:    params.SortBy,
-		SortOrder: params.SortOrder,
-	}
+	repoParams := toListHostsRepoParams(params)
 
 	// Validate and set default values for pagination.
-	if params.Page < 1 {
-		params.Page = 1
-	}
-	if params.PageSize < 1 {
-		params.PageSize = defaultPageSize
-	}
-	if params.PageSize > maxPageSize {
-		params.PageSize = maxPageSize
-	}
+	params.Page, params.PageSize = normalizePage(params.Page, params.PageSize)
 	repoParams.Offset = (params.Page - 1) * params.PageSize
 	repoParams.Limit = params.PageSize
 
 	hosts, totalCount, err := s.hostRepo.List(ctx, repoParams)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListHosts: failed to list hosts from repository", "error", err)
-		return nil, 0, fmt.Errorf("could not retrieve hosts list: %w", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve hosts list: %w", err))
 	}
 	slog.InfoContext(ctx, "ListHosts: hosts listed successfully", "count", len(hosts), "totalCount", totalCount)
 	return hosts, totalCount, nil
 }
 
+// ListHostsAfter retrieves a page of filtered hosts following the cursor, newest first, using keyset pagination.
+// Page and sort parameters are ignored. One extra host is fetched to report whether more hosts follow the page.
+func (s *hostService) ListHostsAfter(ctx context.Context, params dto.ListHostsServiceParams, after *customTypes.HostListCursor) ([]models.Host, bool, error) {
+	slog.InfoContext(ctx, "ListHostsAfter: attempting to list hosts", "params", fmt.Sprintf("%+v", params), "hasCursor", after != nil)
+
+	params.PageSize = normalizePageSize(params.PageSize)
+	repoParams := toListHostsRepoParams(params)
+	repoParams.Limit = params.PageSize + 1
+
+	hosts, err := s.hostRepo.ListAfter(ctx, repoParams, after)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListHostsAfter: failed to list hosts from repository", "error", err)
+		return nil, false, contextAware(fmt.Errorf("could not retrieve hosts list: %w", err))
+	}
+
+	hasMore := len(hosts) > params.PageSize
+	if hasMore {
+		hosts = hosts[:params.PageSize]
+	}
+	slog.InfoContext(ctx, "ListHostsAfter: hosts listed successfully", "count", len(hosts), "hasMore", hasMore)
+	return hosts, hasMore, nil
+}
+
+// toListHostsRepoParams converts the filter and sort parameters of a service-layer list request
+// to repository-layer parameters. Pagination fields are left for the caller to set.
+func toListHostsRepoParams(params dto.ListHostsServiceParams) customTypes.ListHostsParams {
+	return customTypes.ListHostsParams{
+		Country:    params.Country,
+		City:       params.City,
+		Region:     params.Region,
+		Provider:   params.Provider,
+		Protocol:   params.Protocol,
+		Network:    params.Network,
+		IsOnline:   params.IsOnline,
+		IsPrivate:  params.IsPrivate,
+		IsFreeTier: params.IsFreeTier,
+		Status:     params.Status,
+		HostName:   params.HostName,
+		Address:    params.Address,
+		SortBy:     params.SortBy,
+		SortOrder:  params.SortOrder,
+
+		IncludeDeleted: params.IncludeDeleted,
+	}
+}
+
 // UpdateHostOnlineStatus updates a host's online status, typically called by a monitoring system.
 // This includes IsOnline, Status, and LastCheckedAt fields.
 func (s *hostService) UpdateHostOnlineStatus(ctx context.Context, hostID uint, input dto.UpdateHostStatusInput) (*models.Host, error) {
@@ -254,15 +672,21 @@ func (s *hostService) UpdateHostOnlineStatus(ctx context.Context, hostID uint, i
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "UpdateHostOnlineStatus: host not found", "hostID", hostID)
-			return nil, fmt.Errorf("host with ID %d not found: %w", hostID, err)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
 		}
 		slog.ErrorContext(ctx, "UpdateHostOnlineStatus: failed to retrieve host", "hostID", hostID, "error", err)
-		return nil, fmt.Errorf("could not retrieve host: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
 	}
 
 	if !input.Status.IsValid() {
 		slog.WarnContext(ctx, "UpdateHostOnlineStatus: invalid status provided", "hostID", hostID, "status", input.Status)
-		return nil, fmt.Errorf("invalid host status provided: %s", input.Status)
+		return nil, invalid(fmt.Errorf("invalid host status provided: %s", input.Status))
+	}
+	if input.IsOnline || input.Status == customTypes.StatusActive {
+		if err := s.checkHostSecurity(host); err != nil {
+			slog.WarnContext(ctx, "UpdateHostOnlineStatus: refusing to activate host with incomplete security configuration", "hostID", hostID, "securityType", host.SecurityType, "error", err)
+			return nil, invalid(fmt.Errorf("host %d cannot become online or active: %w", hostID, err))
+		}
 	}
 
 	host.IsOnline = input.IsOnline
@@ -270,10 +694,259 @@ func (s *hostService) UpdateHostOnlineStatus(ctx context.Context, hostID uint, i
 	now := time.Now()
 	host.LastCheckedAt = &now
 
-	if err := s.hostRepo.Update(ctx, host); err != nil {
+	changes := map[string]any{
+		"is_online":       host.IsOnline,
+		"status":          host.Status,
+		"last_checked_at": host.LastCheckedAt,
+	}
+	if err := s.hostRepo.Update(ctx, host, changes); err != nil {
 		slog.ErrorContext(ctx, "UpdateHostOnlineStatus: failed to update host status in repository", "hostID", hostID, "error", err)
-		return nil, fmt.Errorf("could not save host status update: %w", err)
+		return nil, contextAware(fmt.Errorf("could not save host status update: %w", err))
 	}
 	slog.InfoContext(ctx, "UpdateHostOnlineStatus: host status updated successfully", "hostID", host.ID)
 	return host, nil
 }
+
+// SetHostTier moves a host to the free tier if isFreeTier is true, or to the paid tier otherwise.
+// Only the is_free_tier column is written, so concurrent changes to other columns are kept.
+func (s *hostService) SetHostTier(ctx context.Context, hostID uint, isFreeTier bool) (*models.Host, error) {
+	slog.InfoContext(ctx, "SetHostTier: attempting to set host tier", "hostID", hostID, "isFreeTier", isFreeTier)
+
+	host, err := s.hostRepo.GetByID(ctx, hostID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "SetHostTier: host not found", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "SetHostTier: failed to retrieve host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	if host.IsFreeTier == isFreeTier {
+		slog.InfoContext(ctx, "SetHostTier: host already has the requested tier", "hostID", hostID, "isFreeTier", isFreeTier)
+		return host, nil
+	}
+
+	host.IsFreeTier = isFreeTier
+	if err := s.hostRepo.Update(ctx, host, map[string]any{"is_free_tier": isFreeTier}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "SetHostTier: host deleted before the tier was saved", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "SetHostTier: failed to update host tier in repository", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not save host tier: %w", err))
+	}
+	slog.InfoContext(ctx, "SetHostTier: host tier updated successfully", "hostID", hostID, "isFreeTier", isFreeTier)
+	return host, nil
+}
+
+// RecordHostCheck appends a health check result to the host's history and updates the host's
+// IsOnline, Status (if provided), LastCheckedAt and LatencyMs fields to reflect the check.
+func (s *hostService) RecordHostCheck(ctx context.Context, hostID uint, result dto.HostCheckResult) (*models.HostCheck, error) {
+	slog.InfoContext(ctx, "RecordHostCheck: recording host check", "hostID", hostID, "isOnline", result.IsOnline, "latencyMs", result.LatencyMs)
+
+	if result.Status != nil && !result.Status.IsValid() {
+		slog.WarnContext(ctx, "RecordHostCheck: invalid status provided", "hostID", hostID, "status", *result.Status)
+		return nil, invalid(fmt.Errorf("invalid host status provided: %s", *result.Status))
+	}
+
+	host, err := s.hostRepo.GetByID(ctx, hostID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RecordHostCheck: host not found", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "RecordHostCheck: failed to retrieve host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	if result.IsOnline || (result.Status != nil && *result.Status == customTypes.StatusActive) {
+		if err := s.checkHostSecurity(host); err != nil {
+			slog.WarnContext(ctx, "RecordHostCheck: refusing to activate host with incomplete security configuration", "hostID", hostID, "securityType", host.SecurityType, "error", err)
+			return nil, invalid(fmt.Errorf("host %d cannot become online or active: %w", hostID, err))
+		}
+	}
+
+	checkedAt := time.Now()
+	if result.CheckedAt != nil {
+		checkedAt = *result.CheckedAt
+	}
+
+	check := &models.HostCheck{
+		HostID:    hostID,
+		CheckedAt: checkedAt,
+		WasOnline: result.IsOnline,
+		LatencyMs: result.LatencyMs,
+		Error:     result.Error,
+	}
+	if err := s.hostCheckRepo.Create(ctx, check); err != nil {
+		slog.ErrorContext(ctx, "RecordHostCheck: failed to save host check", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not save host check: %w", err))
+	}
+
+	host.IsOnline = result.IsOnline
+	if result.Status != nil {
+		host.Status = *result.Status
+	}
+	host.LastCheckedAt = &checkedAt
+	host.LatencyMs = result.LatencyMs
+	changes := map[string]any{
+		"is_online":       host.IsOnline,
+		"status":          host.Status,
+		"last_checked_at": host.LastCheckedAt,
+		"latency_ms":      host.LatencyMs,
+	}
+	if err := s.hostRepo.Update(ctx, host, changes); err != nil {
+		slog.ErrorContext(ctx, "RecordHostCheck: failed to update host after check", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not save host status update: %w", err))
+	}
+
+	slog.InfoContext(ctx, "RecordHostCheck: host check recorded successfully", "hostID", hostID, "checkID", check.ID)
+	return check, nil
+}
+
+// ListHostChecks retrieves a paginated health check history for a host, newest first.
+func (s *hostService) ListHostChecks(ctx context.Context, hostID uint, page, pageSize int) ([]models.HostCheck, int64, error) {
+	slog.InfoContext(ctx, "ListHostChecks: listing host checks", "hostID", hostID, "page", page, "pageSize", pageSize)
+
+	if _, err := s.hostRepo.GetByID(ctx, hostID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "ListHostChecks: host not found", "hostID", hostID)
+			return nil, 0, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "ListHostChecks: failed to retrieve host", "hostID", hostID, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	// Apply default pagination parameters.
+	page, pageSize = normalizePage(page, pageSize)
+	offset := (page - 1) * pageSize
+
+	checks, totalCount, err := s.hostCheckRepo.ListByHostID(ctx, hostID, offset, pageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListHostChecks: failed to list host checks from repo", "hostID", hostID, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve host checks: %w", err))
+	}
+
+	slog.InfoContext(ctx, "ListHostChecks: host checks listed successfully", "hostID", hostID, "count", len(checks), "totalCount", totalCount)
+	return checks, totalCount, nil
+}
+
+// GetHostUptime computes the ratio of checks in which the host was online over the window ending now.
+// The ratio is nil when no checks were recorded in the window.
+func (s *hostService) GetHostUptime(ctx context.Context, hostID uint, window time.Duration) (*dto.HostUptime, error) {
+	slog.InfoContext(ctx, "GetHostUptime: computing host uptime", "hostID", hostID, "window", window.String())
+
+	if window <= 0 {
+		return nil, invalid(errors.New("invalid uptime window: must be positive"))
+	}
+
+	if _, err := s.hostRepo.GetByID(ctx, hostID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetHostUptime: host not found", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "GetHostUptime: failed to retrieve host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	total, online, err := s.hostCheckRepo.CountSince(ctx, hostID, from)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetHostUptime: failed to count host checks", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not compute host uptime: %w", err))
+	}
+
+	uptime := &dto.HostUptime{
+		HostID:       hostID,
+		From:         from,
+		To:           to,
+		TotalChecks:  total,
+		OnlineChecks: online,
+		UptimeRatio:  uptimeRatio(total, online),
+	}
+	slog.InfoContext(ctx, "GetHostUptime: host uptime computed", "hostID", hostID, "totalChecks", total, "onlineChecks", online)
+	return uptime, nil
+}
+
+// GetHostUtilization reports how many of the host's slots are in use, together with its latest health state.
+// The utilization percentage is left unset when the host has no configured capacity.
+func (s *hostService) GetHostUtilization(ctx context.Context, hostID uint) (*dto.HostUtilization, error) {
+	host, err := s.hostRepo.GetByID(ctx, hostID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetHostUtilization: host not found", "hostID", hostID)
+			return nil, notFound(fmt.Errorf("host with ID %d not found: %w", hostID, err))
+		}
+		slog.ErrorContext(ctx, "GetHostUtilization: failed to retrieve host", "hostID", hostID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve host: %w", err))
+	}
+
+	return &dto.HostUtilization{
+		HostID:             host.ID,
+		CurrentUsers:       host.CurrentUsers,
+		MaxUsers:           host.MaxUsers,
+		UtilizationPercent: utilizationPercent(host.CurrentUsers, host.MaxUsers),
+		IsOnline:           host.IsOnline,
+		Status:             host.Status,
+		LatencyMs:          host.LatencyMs,
+		LastCheckedAt:      host.LastCheckedAt,
+	}, nil
+}
+
+// GetProvidersReport returns host counts for each distinct provider, optionally restricted to a country.
+func (s *hostService) GetProvidersReport(ctx context.Context, country *string) ([]customTypes.ProviderHostCounts, error) {
+	slog.InfoContext(ctx, "GetProvidersReport: generating providers report", "country", country)
+
+	counts, err := s.hostRepo.CountByProvider(ctx, country)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetProvidersReport: failed to count hosts by provider", "error", err)
+		return nil, contextAware(fmt.Errorf("could not generate providers report: %w", err))
+	}
+	slog.InfoContext(ctx, "GetProvidersReport: providers report generated", "providers", len(counts))
+	return counts, nil
+}
+
+// GetAvailabilityReport counts the hosts available for key generation per country and tier and sums the totals.
+func (s *hostService) GetAvailabilityReport(ctx context.Context) (*dto.HostAvailabilityReport, error) {
+	slog.InfoContext(ctx, "GetAvailabilityReport: generating host availability report")
+
+	counts, err := s.hostRepo.CountAvailableByCountry(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAvailabilityReport: failed to count available hosts", "error", err)
+		return nil, contextAware(fmt.Errorf("could not generate host availability report: %w", err))
+	}
+
+	report := &dto.HostAvailabilityReport{Countries: counts}
+	for _, c := range counts {
+		report.TotalFree += c.Free
+		report.TotalPaid += c.Paid
+	}
+	slog.InfoContext(ctx, "GetAvailabilityReport: host availability report generated", "countries", len(counts), "free", report.TotalFree, "paid", report.TotalPaid)
+	return report, nil
+}
+
+// GetHostAvailability summarizes the public hosts available for key generation per country, optionally
+// restricted to free (isFreeTier true) or paid hosts. Summaries are served from memory for the configured TTL.
+func (s *hostService) GetHostAvailability(ctx context.Context, isFreeTier *bool) ([]customTypes.CountryAvailability, error) {
+	cacheKey := "all"
+	if isFreeTier != nil && *isFreeTier {
+		cacheKey = "free"
+	} else if isFreeTier != nil {
+		cacheKey = "paid"
+	}
+	if countries, ok := s.availability.get(cacheKey); ok {
+		slog.DebugContext(ctx, "GetHostAvailability: serving cached host availability", "tier", cacheKey)
+		return countries, nil
+	}
+
+	countries, err := s.hostRepo.AggregateAvailability(ctx, isFreeTier)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetHostAvailability: failed to aggregate host availability", "error", err)
+		return nil, contextAware(fmt.Errorf("could not get host availability: %w", err))
+	}
+	s.availability.set(cacheKey, countries)
+	slog.InfoContext(ctx, "GetHostAvailability: host availability aggregated", "tier", cacheKey, "countries", len(countries))
+	return countries, nil
+}
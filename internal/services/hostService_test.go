@@ -0,0 +1,1398 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// hostServiceDeps holds the fakes a hostService under test is built from.
+type hostServiceDeps struct {
+	hosts  *fakeHostRepo
+	checks *fakeHostCheckRepo
+	cfg    *config.Config
+}
+
+// newTestHostService builds a hostService on fakes holding the given hosts.
+func newTestHostService(t *testing.T, cfg *config.Config, hosts ...models.Host) (*hostService, *hostServiceDeps) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{AllowedHostProtocols: []string{"vless", "vmess", "trojan"}}
+	}
+	deps := &hostServiceDeps{
+		hosts:  newFakeHostRepo(hosts...),
+		checks: &fakeHostCheckRepo{},
+		cfg:    cfg,
+	}
+	svc := NewHostService(deps.hosts, deps.checks, fakeTx{}, cfg).(*hostService)
+	return svc, deps
+}
+
+func TestUptimeRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int64
+		online int64
+		want   *float64
+	}{
+		{name: "no checks", total: 0, online: 0, want: nil},
+		{name: "always online", total: 4, online: 4, want: ptr(1.0)},
+		{name: "never online", total: 4, online: 0, want: ptr(0.0)},
+		{name: "partly online", total: 4, online: 3, want: ptr(0.75)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uptimeRatio(tt.total, tt.online)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("uptimeRatio(%d, %d) = %v, want %v", tt.total, tt.online, deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func TestUtilizationPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  int64
+		capacity int64
+		want     *float64
+	}{
+		{name: "zero capacity", current: 5, capacity: 0, want: nil},
+		{name: "negative capacity", current: 5, capacity: -1, want: nil},
+		{name: "empty", current: 0, capacity: 10, want: ptr(0.0)},
+		{name: "partial", current: 3, capacity: 12, want: ptr(25.0)},
+		{name: "full", current: 10, capacity: 10, want: ptr(100.0)},
+		{name: "over capacity", current: 15, capacity: 10, want: ptr(150.0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilizationPercent(tt.current, tt.capacity)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("utilizationPercent(%d, %d) = %v, want %v", tt.current, tt.capacity, deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetHostUtilization(t *testing.T) {
+	checkedAt := time.Now().Add(-time.Minute)
+	host := func(id uint, current, capacity int64) models.Host {
+		h := testHost(id, "DE", false)
+		h.CurrentUsers, h.MaxUsers = current, capacity
+		h.LatencyMs, h.LastCheckedAt = ptr(42), &checkedAt
+		return h
+	}
+	deleted := host(4, 1, 10)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	hosts := []models.Host{host(1, 7, 0), host(2, 5, 20), host(3, 20, 20), deleted}
+
+	tests := []struct {
+		name        string
+		hostID      uint
+		wantErr     error
+		wantPercent *float64
+	}{
+		{name: "zero capacity", hostID: 1, wantPercent: nil},
+		{name: "partial", hostID: 2, wantPercent: ptr(25.0)},
+		{name: "full", hostID: 3, wantPercent: ptr(100.0)},
+		{name: "deleted host", hostID: 4, wantErr: ErrNotFound},
+		{name: "unknown host", hostID: 99, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, nil, hosts...)
+
+			got, err := svc.GetHostUtilization(context.Background(), tt.hostID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetHostUtilization() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetHostUtilization() error = %v", err)
+			}
+			want := hosts[tt.hostID-1]
+			if (got.UtilizationPercent == nil) != (tt.wantPercent == nil) || (got.UtilizationPercent != nil && *got.UtilizationPercent != *tt.wantPercent) {
+				t.Errorf("UtilizationPercent = %v, want %v", deref(got.UtilizationPercent), deref(tt.wantPercent))
+			}
+			if got.HostID != want.ID || got.CurrentUsers != want.CurrentUsers || got.MaxUsers != want.MaxUsers {
+				t.Errorf("utilization = %+v, want the counts of host %d", got, want.ID)
+			}
+			if !got.IsOnline || got.Status != customTypes.StatusActive || deref(got.LatencyMs) != 42 || got.LastCheckedAt == nil || !got.LastCheckedAt.Equal(checkedAt) {
+				t.Errorf("utilization health = %+v, want the host's online state, status, latency and last check", got)
+			}
+		})
+	}
+}
+
+func TestGetHostUptime(t *testing.T) {
+	now := time.Now()
+	check := func(hostID uint, age time.Duration, online bool) models.HostCheck {
+		return models.HostCheck{HostID: hostID, CheckedAt: now.Add(-age), WasOnline: online}
+	}
+	checks := []models.HostCheck{
+		check(1, time.Hour, true),
+		check(1, 2*time.Hour, true),
+		check(1, 3*time.Hour, false),
+		check(1, 4*time.Hour, true),
+		check(1, 30*time.Hour, false), // Outside a 24h window.
+		check(2, time.Hour, false),    // Another host.
+	}
+
+	tests := []struct {
+		name       string
+		hostID     uint
+		window     time.Duration
+		wantTotal  int64
+		wantOnline int64
+		wantRatio  *float64
+		wantErr    error
+	}{
+		{name: "checks within the window", hostID: 1, window: 24 * time.Hour, wantTotal: 4, wantOnline: 3, wantRatio: ptr(0.75)},
+		{name: "longer window includes older checks", hostID: 1, window: 48 * time.Hour, wantTotal: 5, wantOnline: 3, wantRatio: ptr(0.6)},
+		{name: "short window", hostID: 1, window: 90 * time.Minute, wantTotal: 1, wantOnline: 1, wantRatio: ptr(1.0)},
+		{name: "no checks in the window", hostID: 3, window: 24 * time.Hour},
+		{name: "zero window", hostID: 1, window: 0, wantErr: ErrValidation},
+		{name: "unknown host", hostID: 9, window: time.Hour, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil, models.Host{ID: 1}, models.Host{ID: 2}, models.Host{ID: 3})
+			deps.checks.checks = checks
+
+			uptime, err := svc.GetHostUptime(context.Background(), tt.hostID, tt.window)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetHostUptime() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if uptime.TotalChecks != tt.wantTotal || uptime.OnlineChecks != tt.wantOnline {
+				t.Errorf("checks = %d online of %d, want %d of %d", uptime.OnlineChecks, uptime.TotalChecks, tt.wantOnline, tt.wantTotal)
+			}
+			if (uptime.UptimeRatio == nil) != (tt.wantRatio == nil) || (uptime.UptimeRatio != nil && *uptime.UptimeRatio != *tt.wantRatio) {
+				t.Errorf("ratio = %v, want %v", deref(uptime.UptimeRatio), deref(tt.wantRatio))
+			}
+			if got := uptime.To.Sub(uptime.From); got != tt.window {
+				t.Errorf("window = %v, want %v", got, tt.window)
+			}
+		})
+	}
+}
+
+func TestRecordHostCheck(t *testing.T) {
+	maintenance := customTypes.StatusMaintenance
+
+	tests := []struct {
+		name       string
+		result     dto.HostCheckResult
+		wantOnline bool
+		wantStatus customTypes.HostStatus
+	}{
+		{name: "online keeps status", result: dto.HostCheckResult{IsOnline: true, LatencyMs: ptr(42)}, wantOnline: true, wantStatus: customTypes.StatusActive},
+		{name: "offline with maintenance status", result: dto.HostCheckResult{IsOnline: false, Status: &maintenance, Error: "timeout"}, wantStatus: customTypes.StatusMaintenance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil, models.Host{ID: 1, Status: customTypes.StatusActive, SecurityType: "tls"})
+
+			check, err := svc.RecordHostCheck(context.Background(), 1, tt.result)
+			if err != nil {
+				t.Fatalf("RecordHostCheck() error = %v", err)
+			}
+			if len(deps.checks.checks) != 1 || check.WasOnline != tt.wantOnline || check.Error != tt.result.Error {
+				t.Errorf("recorded checks = %+v, want one with online %v", deps.checks.checks, tt.wantOnline)
+			}
+			host, _ := deps.hosts.GetByID(context.Background(), 1)
+			if host.IsOnline != tt.wantOnline || host.Status != tt.wantStatus || host.LastCheckedAt == nil || !host.LastCheckedAt.Equal(check.CheckedAt) {
+				t.Errorf("host = online %v, status %q, checked at %v; want online %v, status %q, checked at %v",
+					host.IsOnline, host.Status, host.LastCheckedAt, tt.wantOnline, tt.wantStatus, check.CheckedAt)
+			}
+		})
+	}
+}
+
+func TestAddHosts(t *testing.T) {
+	existing := models.Host{ID: 1, HostName: "de-1", Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp"}
+	valid := func(address, name string) dto.CreateHostInput {
+		return dto.CreateHostInput{HostName: name, Address: address, Port: "443", Protocol: "vless"}
+	}
+	mixed := []dto.CreateHostInput{
+		valid("nl1.example.com", "nl-1"),
+		valid("de1.example.com", ""),                  // Already exists.
+		{Address: "", Port: "443", Protocol: "vless"}, // Missing address.
+		valid("nl1.example.com", ""),                  // Duplicates the first entry.
+		{Address: "fr1.example.com", Port: "443", Protocol: "gopher"},
+		valid("fr2.example.com", "DE-1"), // Name taken when names must be unique.
+		valid("fr3.example.com", "fr-3"),
+	}
+
+	tests := []struct {
+		name           string
+		inputs         []dto.CreateHostInput
+		atomic         bool
+		uniqueNames    bool
+		wantStatuses   []string
+		wantCreated    int
+		wantDuplicates int
+		wantInvalid    int
+		wantCommitted  bool
+	}{
+		{
+			name:   "best effort creates the valid entries",
+			inputs: mixed,
+			wantStatuses: []string{dto.BulkHostStatusCreated, dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid,
+				dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid, dto.BulkHostStatusCreated, dto.BulkHostStatusCreated},
+			wantCreated: 3, wantDuplicates: 2, wantInvalid: 2, wantCommitted: true,
+		},
+		{
+			name:        "unique host names",
+			inputs:      mixed,
+			uniqueNames: true,
+			wantStatuses: []string{dto.BulkHostStatusCreated, dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid,
+				dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid, dto.BulkHostStatusDuplicate, dto.BulkHostStatusCreated},
+			wantCreated: 2, wantDuplicates: 3, wantInvalid: 2, wantCommitted: true,
+		},
+		{
+			name:   "atomic batch is rejected",
+			inputs: mixed,
+			atomic: true,
+			wantStatuses: []string{dto.BulkHostStatusRejected, dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid,
+				dto.BulkHostStatusDuplicate, dto.BulkHostStatusInvalid, dto.BulkHostStatusRejected, dto.BulkHostStatusRejected},
+			wantDuplicates: 2, wantInvalid: 2,
+		},
+		{
+			name:          "atomic batch of valid entries",
+			inputs:        []dto.CreateHostInput{valid("nl1.example.com", ""), valid("nl2.example.com", "")},
+			atomic:        true,
+			wantStatuses:  []string{dto.BulkHostStatusCreated, dto.BulkHostStatusCreated},
+			wantCreated:   2,
+			wantCommitted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, &config.Config{AllowedHostProtocols: []string{"vless"}, EnforceUniqueHostNames: tt.uniqueNames}, existing)
+
+			result, err := svc.AddHosts(context.Background(), tt.inputs, tt.atomic)
+			if err != nil {
+				t.Fatalf("AddHosts() error = %v", err)
+			}
+			for i, want := range tt.wantStatuses {
+				if got := result.Results[i]; got.Status != want || got.Index != i {
+					t.Errorf("entry %d = %q (%s), want %q", i, got.Status, got.Error, want)
+				}
+				if created := result.Results[i].Host != nil; created != (want == dto.BulkHostStatusCreated) {
+					t.Errorf("entry %d has a host = %v, want %v", i, created, !created)
+				}
+			}
+			if result.Created != tt.wantCreated || result.Duplicates != tt.wantDuplicates || result.Invalid != tt.wantInvalid || result.Committed != tt.wantCommitted {
+				t.Errorf("summary = %d created, %d duplicates, %d invalid, committed %v; want %d, %d, %d, %v",
+					result.Created, result.Duplicates, result.Invalid, result.Committed, tt.wantCreated, tt.wantDuplicates, tt.wantInvalid, tt.wantCommitted)
+			}
+			if stored := len(deps.hosts.hosts) - 1; stored != tt.wantCreated {
+				t.Errorf("repository holds %d new hosts, want %d", stored, tt.wantCreated)
+			}
+		})
+	}
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// deref returns the value v points to, or nil if v is nil, for readable test failures.
+func deref[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestAddHostValidation(t *testing.T) {
+	input := func(port, protocol, network string) dto.CreateHostInput {
+		return dto.CreateHostInput{Address: "de1.example.com", Port: port, Protocol: protocol, Network: network}
+	}
+
+	tests := []struct {
+		name         string
+		input        dto.CreateHostInput
+		wantErr      error
+		wantPort     string
+		wantProtocol string
+		wantNetwork  string
+	}{
+		{name: "valid", input: input("443", "vless", "ws"), wantPort: "443", wantProtocol: "vless", wantNetwork: "ws"},
+		{name: "normalized", input: input(" 0443 ", "VLESS", "GRPC"), wantPort: "443", wantProtocol: "vless", wantNetwork: "grpc"},
+		{name: "network defaults to tcp", input: input("8443", "trojan", ""), wantPort: "8443", wantProtocol: "trojan", wantNetwork: "tcp"},
+		{name: "non-numeric port", input: input("abcd", "vless", "tcp"), wantErr: ErrInvalidHostPort},
+		{name: "port zero", input: input("0", "vless", "tcp"), wantErr: ErrInvalidHostPort},
+		{name: "port above range", input: input("65536", "vless", "tcp"), wantErr: ErrInvalidHostPort},
+		{name: "unsupported protocol", input: input("443", "shadowsocks", "tcp"), wantErr: ErrInvalidHostProtocol},
+		{name: "unsupported network", input: input("443", "vless", "quic"), wantErr: ErrInvalidHostNetwork},
+		{name: "empty port", input: input("", "vless", "tcp"), wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil)
+
+			host, err := svc.AddHost(context.Background(), tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, ErrValidation) {
+					t.Fatalf("AddHost() error = %v, want %v and %v", err, tt.wantErr, ErrValidation)
+				}
+				if len(deps.hosts.hosts) != 0 {
+					t.Errorf("%d hosts stored, want none", len(deps.hosts.hosts))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddHost() error = %v", err)
+			}
+			if host.Port != tt.wantPort || host.Protocol != tt.wantProtocol || host.Network != tt.wantNetwork {
+				t.Errorf("host endpoint = %s/%s/%s, want %s/%s/%s", host.Port, host.Protocol, host.Network, tt.wantPort, tt.wantProtocol, tt.wantNetwork)
+			}
+		})
+	}
+}
+
+func TestAddHostAllowedProtocolsConfig(t *testing.T) {
+	cfg := &config.Config{AllowedHostProtocols: []string{"vless"}}
+	tests := []struct {
+		protocol string
+		wantErr  error
+	}{
+		{protocol: "vless"},
+		{protocol: "vmess", wantErr: ErrInvalidHostProtocol},
+		{protocol: "trojan", wantErr: ErrInvalidHostProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			svc, _ := newTestHostService(t, cfg)
+			_, err := svc.AddHost(context.Background(), dto.CreateHostInput{Address: "de1.example.com", Port: "443", Protocol: tt.protocol})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AddHost() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateHostValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   dto.UpdateHostInput
+		wantErr error
+		want    models.Host
+	}{
+		{name: "valid endpoint change", input: dto.UpdateHostInput{Port: ptr("8443"), Protocol: ptr("VMESS"), Network: ptr("ws")},
+			want: models.Host{Port: "8443", Protocol: "vmess", Network: "ws"}},
+		{name: "unchanged fields are not revalidated", input: dto.UpdateHostInput{Country: ptr("NL")},
+			want: models.Host{Port: "443", Protocol: "vless", Network: "tcp"}},
+		{name: "non-numeric port", input: dto.UpdateHostInput{Port: ptr("abcd")}, wantErr: ErrInvalidHostPort},
+		{name: "port above range", input: dto.UpdateHostInput{Port: ptr("70000")}, wantErr: ErrInvalidHostPort},
+		{name: "unsupported protocol", input: dto.UpdateHostInput{Protocol: ptr("wireguard")}, wantErr: ErrInvalidHostProtocol},
+		{name: "unsupported network", input: dto.UpdateHostInput{Network: ptr("http")}, wantErr: ErrInvalidHostNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := models.Host{ID: 1, Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp"}
+			svc, deps := newTestHostService(t, nil, stored)
+
+			host, err := svc.UpdateHost(context.Background(), stored.ID, tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, ErrValidation) {
+					t.Fatalf("UpdateHost() error = %v, want %v and %v", err, tt.wantErr, ErrValidation)
+				}
+				if got := *deps.hosts.hosts[0]; got.Port != stored.Port || got.Protocol != stored.Protocol || got.Network != stored.Network {
+					t.Errorf("stored host changed to %s/%s/%s", got.Port, got.Protocol, got.Network)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateHost() error = %v", err)
+			}
+			if host.Port != tt.want.Port || host.Protocol != tt.want.Protocol || host.Network != tt.want.Network {
+				t.Errorf("host endpoint = %s/%s/%s, want %s/%s/%s", host.Port, host.Protocol, host.Network, tt.want.Port, tt.want.Protocol, tt.want.Network)
+			}
+		})
+	}
+}
+
+func TestHostNameUniqueness(t *testing.T) {
+	existing := []models.Host{
+		{ID: 1, HostName: "Frankfurt-1", Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp"},
+		{ID: 2, HostName: "Amsterdam-1", Address: "nl1.example.com", Port: "443", Protocol: "vless", Network: "tcp"},
+		{ID: 3, Address: "unnamed.example.com", Port: "443", Protocol: "vless", Network: "tcp"},
+	}
+	add := func(name string) func(svc *hostService) error {
+		return func(svc *hostService) error {
+			_, err := svc.AddHost(context.Background(), dto.CreateHostInput{HostName: name, Address: "new.example.com", Port: "443", Protocol: "vless"})
+			return err
+		}
+	}
+	rename := func(hostID uint, name string) func(svc *hostService) error {
+		return func(svc *hostService) error {
+			_, err := svc.UpdateHost(context.Background(), hostID, dto.UpdateHostInput{HostName: ptr(name)})
+			return err
+		}
+	}
+
+	tests := []struct {
+		name              string
+		operate           func(svc *hostService) error
+		wantEnforcedErr   error
+		wantUnenforcedErr error
+	}{
+		{name: "add with a new name", operate: add("Paris-1")},
+		{name: "add with a taken name", operate: add("Frankfurt-1"), wantEnforcedErr: ErrConflict},
+		{name: "add with a taken name in another case", operate: add("frankfurt-1"), wantEnforcedErr: ErrConflict},
+		{name: "add without a name", operate: add("")},
+		{name: "rename to a new name", operate: rename(1, "Frankfurt-2")},
+		{name: "rename to a taken name", operate: rename(1, "Amsterdam-1"), wantEnforcedErr: ErrConflict},
+		{name: "rename to its own name in another case", operate: rename(1, "FRANKFURT-1")},
+		{name: "name an unnamed host with a taken name", operate: rename(3, "amsterdam-1"), wantEnforcedErr: ErrConflict},
+	}
+	for _, tt := range tests {
+		for _, enforce := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/enforced=%t", tt.name, enforce), func(t *testing.T) {
+				cfg := &config.Config{AllowedHostProtocols: []string{"vless"}, EnforceUniqueHostNames: enforce}
+				svc, _ := newTestHostService(t, cfg, existing...)
+
+				wantErr := tt.wantUnenforcedErr
+				if enforce {
+					wantErr = tt.wantEnforcedErr
+				}
+				if err := tt.operate(svc); !errors.Is(err, wantErr) || (wantErr == nil && err != nil) {
+					t.Errorf("error = %v, want %v", err, wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestListHostsAfter(t *testing.T) {
+	base := time.Date(2026, time.March, 4, 10, 0, 0, 0, time.UTC)
+	// Hosts 4 and 5 share a creation time, so the ID breaks the tie.
+	hosts := []models.Host{
+		{ID: 1, CreatedAt: base}, {ID: 2, CreatedAt: base.Add(time.Minute)}, {ID: 3, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 4, CreatedAt: base.Add(3 * time.Minute)}, {ID: 5, CreatedAt: base.Add(3 * time.Minute)},
+	}
+
+	tests := []struct {
+		name      string
+		pageSize  int
+		wantPages [][]uint
+	}{
+		{name: "pages of two", pageSize: 2, wantPages: [][]uint{{5, 4}, {3, 2}, {1}}},
+		{name: "exact pages", pageSize: 5, wantPages: [][]uint{{5, 4, 3, 2, 1}}},
+		{name: "one page", pageSize: 10, wantPages: [][]uint{{5, 4, 3, 2, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, nil, hosts...)
+
+			var after *customTypes.HostListCursor
+			for i, wantIDs := range tt.wantPages {
+				page, hasMore, err := svc.ListHostsAfter(context.Background(), dto.ListHostsServiceParams{PageSize: tt.pageSize}, after)
+				if err != nil {
+					t.Fatalf("ListHostsAfter() page %d error = %v", i+1, err)
+				}
+				gotIDs := make([]uint, len(page))
+				for j, host := range page {
+					gotIDs[j] = host.ID
+				}
+				if !slices.Equal(gotIDs, wantIDs) {
+					t.Errorf("page %d = %v, want %v", i+1, gotIDs, wantIDs)
+				}
+				if wantMore := i < len(tt.wantPages)-1; hasMore != wantMore {
+					t.Fatalf("page %d hasMore = %v, want %v", i+1, hasMore, wantMore)
+				}
+				last := page[len(page)-1]
+				after = &customTypes.HostListCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+			}
+		})
+	}
+}
+
+func TestRestoreHost(t *testing.T) {
+	deleted := gorm.DeletedAt{Time: time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	deletedHost := models.Host{ID: 1, HostName: "Frankfurt-1", Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp", DeletedAt: deleted}
+
+	tests := []struct {
+		name        string
+		hosts       []models.Host
+		enforceName bool
+		hostID      uint
+		wantErr     error
+	}{
+		{name: "deleted host", hosts: []models.Host{deletedHost}, hostID: 1},
+		{name: "unknown host", hosts: []models.Host{deletedHost}, hostID: 9, wantErr: ErrNotFound},
+		{name: "host not deleted", hosts: []models.Host{{ID: 1, Address: "de1.example.com"}}, hostID: 1, wantErr: ErrNotFound},
+		{name: "endpoint taken by an active host", hostID: 1, wantErr: ErrConflict, hosts: []models.Host{deletedHost,
+			{ID: 2, Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp"}}},
+		{name: "endpoint taken by another deleted host", hostID: 1, hosts: []models.Host{deletedHost,
+			{ID: 2, Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp", DeletedAt: deleted}}},
+		{name: "same endpoint on another network", hostID: 1, hosts: []models.Host{deletedHost,
+			{ID: 2, Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "ws"}}},
+		{name: "name taken with unique names enforced", enforceName: true, hostID: 1, wantErr: ErrConflict, hosts: []models.Host{deletedHost,
+			{ID: 2, HostName: "frankfurt-1", Address: "de2.example.com", Port: "443", Protocol: "vless", Network: "tcp"}}},
+		{name: "name taken with unique names not enforced", hostID: 1, hosts: []models.Host{deletedHost,
+			{ID: 2, HostName: "frankfurt-1", Address: "de2.example.com", Port: "443", Protocol: "vless", Network: "tcp"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, &config.Config{EnforceUniqueHostNames: tt.enforceName}, tt.hosts...)
+
+			host, err := svc.RestoreHost(context.Background(), tt.hostID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("RestoreHost() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if stored, _ := deps.hosts.GetDeletedByID(context.Background(), 1); tt.hosts[0].DeletedAt.Valid && stored == nil {
+					t.Error("host was restored despite the error")
+				}
+				return
+			}
+			if host.ID != tt.hostID || host.DeletedAt.Valid {
+				t.Errorf("RestoreHost() = %+v, want host %d without a deletion time", host, tt.hostID)
+			}
+			if _, err := deps.hosts.GetByID(context.Background(), tt.hostID); err != nil {
+				t.Errorf("restored host is not found: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateHostKeepsConcurrentChanges(t *testing.T) {
+	stored := testHost(1, "DE", false)
+	stored.IsOnline, stored.Status, stored.Notes = false, customTypes.StatusInactive, "Old notes"
+	checkedAt := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	active := customTypes.StatusActive
+
+	tests := []struct {
+		name        string
+		update      func(svc *hostService) error // Reads the host, then writes after interleaved runs.
+		interleaved func(svc *hostService) error // Runs between the read and the write of update.
+	}{
+		{
+			name: "admin edit keeps a concurrent status update",
+			update: func(svc *hostService) error {
+				_, err := svc.UpdateHost(context.Background(), stored.ID, dto.UpdateHostInput{Notes: ptr("New notes")})
+				return err
+			},
+			interleaved: func(svc *hostService) error {
+				_, err := svc.RecordHostCheck(context.Background(), stored.ID, dto.HostCheckResult{IsOnline: true, Status: &active, CheckedAt: &checkedAt})
+				return err
+			},
+		},
+		{
+			name: "status update keeps a concurrent admin edit",
+			update: func(svc *hostService) error {
+				_, err := svc.RecordHostCheck(context.Background(), stored.ID, dto.HostCheckResult{IsOnline: true, Status: &active, CheckedAt: &checkedAt})
+				return err
+			},
+			interleaved: func(svc *hostService) error {
+				_, err := svc.UpdateHost(context.Background(), stored.ID, dto.UpdateHostInput{Notes: ptr("New notes")})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil, stored)
+			var interleavedErr error
+			deps.hosts.beforeUpdate = func() { interleavedErr = tt.interleaved(svc) }
+
+			if err := tt.update(svc); err != nil {
+				t.Fatalf("update error = %v", err)
+			}
+			if interleavedErr != nil {
+				t.Fatalf("interleaved update error = %v", interleavedErr)
+			}
+
+			got, err := deps.hosts.GetByID(context.Background(), stored.ID)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if !got.IsOnline || got.Status != customTypes.StatusActive || got.LastCheckedAt == nil || !got.LastCheckedAt.Equal(checkedAt) {
+				t.Errorf("status = online %v, %s, checked at %v; want the health check result kept", got.IsOnline, got.Status, got.LastCheckedAt)
+			}
+			if got.Notes != "New notes" {
+				t.Errorf("notes = %q, want the admin edit kept", got.Notes)
+			}
+		})
+	}
+}
+
+func TestUpdateHostDeletedConcurrently(t *testing.T) {
+	stored := testHost(1, "DE", false)
+	svc, deps := newTestHostService(t, nil, stored)
+	deps.hosts.beforeUpdate = func() {
+		deps.hosts.mu.Lock()
+		defer deps.hosts.mu.Unlock()
+		deps.hosts.hosts[0].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+
+	if _, err := svc.UpdateHost(context.Background(), stored.ID, dto.UpdateHostInput{Notes: ptr("New notes")}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateHost() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestListHostsPaging(t *testing.T) {
+	var hosts []models.Host
+	for id := uint(1); id <= 25; id++ {
+		hosts = append(hosts, testHost(id, "DE", false))
+	}
+
+	tests := []struct {
+		name      string
+		page      int
+		pageSize  int
+		wantFirst uint // ID of the first host on the page; zero if the page is empty.
+		wantItems int
+	}{
+		{name: "first page", page: 1, pageSize: 10, wantFirst: 1, wantItems: 10},
+		{name: "last partial page", page: 3, pageSize: 10, wantFirst: 21, wantItems: 5},
+		{name: "page past the end", page: 4, pageSize: 10, wantItems: 0},
+		{name: "invalid page and size use defaults", page: -1, pageSize: 0, wantFirst: 1, wantItems: defaultPageSize},
+		{name: "page size is capped", page: 1, pageSize: 1000, wantFirst: 1, wantItems: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, nil, hosts...)
+
+			got, total, err := svc.ListHosts(context.Background(), dto.ListHostsServiceParams{Page: tt.page, PageSize: tt.pageSize})
+			if err != nil {
+				t.Fatalf("ListHosts() error = %v", err)
+			}
+			if len(got) != tt.wantItems || total != 25 {
+				t.Fatalf("got %d hosts of %d, want %d of 25", len(got), total, tt.wantItems)
+			}
+			if len(got) > 0 && got[0].ID != tt.wantFirst {
+				t.Errorf("first host = %d, want %d", got[0].ID, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestListHostsIncludeDeleted(t *testing.T) {
+	deleted := testHost(2, "DE", false)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name           string
+		includeDeleted bool
+		wantIDs        []uint
+	}{
+		{name: "deleted hosts excluded by default", wantIDs: []uint{1}},
+		{name: "deleted hosts included", includeDeleted: true, wantIDs: []uint{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, nil, testHost(1, "DE", false), deleted)
+
+			hosts, total, err := svc.ListHosts(context.Background(), dto.ListHostsServiceParams{IncludeDeleted: tt.includeDeleted})
+			if err != nil {
+				t.Fatalf("ListHosts() error = %v", err)
+			}
+			var ids []uint
+			for _, host := range hosts {
+				ids = append(ids, host.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) || total != int64(len(tt.wantIDs)) {
+				t.Errorf("ListHosts() = hosts %v of %d, want %v", ids, total, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetAvailabilityReport(t *testing.T) {
+	host := func(id uint, country string, free, online bool, status customTypes.HostStatus) models.Host {
+		h := testHost(id, country, free)
+		h.IsOnline, h.Status = online, status
+		return h
+	}
+	deleted := host(9, "FR", true, true, customTypes.StatusActive)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name  string
+		hosts []models.Host
+		want  dto.HostAvailabilityReport
+	}{
+		{name: "no hosts", want: dto.HostAvailabilityReport{}},
+		{
+			name: "grouped by country and tier",
+			hosts: []models.Host{
+				host(1, "NL", true, true, customTypes.StatusActive),
+				host(2, "DE", true, true, customTypes.StatusActive),
+				host(3, "DE", false, true, customTypes.StatusActive),
+				host(4, "DE", false, true, customTypes.StatusActive),
+				host(5, "US", false, true, customTypes.StatusActive),
+				host(6, "", true, true, customTypes.StatusActive),
+			},
+			want: dto.HostAvailabilityReport{
+				Countries: []customTypes.CountryHostAvailability{
+					{Country: "", Free: 1},
+					{Country: "DE", Free: 1, Paid: 2},
+					{Country: "NL", Free: 1},
+					{Country: "US", Paid: 1},
+				},
+				TotalFree: 3, TotalPaid: 3,
+			},
+		},
+		{
+			name: "unavailable hosts are not counted",
+			hosts: []models.Host{
+				host(1, "DE", true, true, customTypes.StatusActive),
+				host(2, "DE", true, false, customTypes.StatusActive),
+				host(3, "DE", false, true, customTypes.StatusMaintenance),
+				host(4, "NL", false, false, customTypes.StatusInactive),
+				deleted,
+			},
+			want: dto.HostAvailabilityReport{
+				Countries: []customTypes.CountryHostAvailability{{Country: "DE", Free: 1}},
+				TotalFree: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, nil, tt.hosts...)
+
+			got, err := svc.GetAvailabilityReport(context.Background())
+			if err != nil {
+				t.Fatalf("GetAvailabilityReport() error = %v", err)
+			}
+			if !slices.Equal(got.Countries, tt.want.Countries) || got.TotalFree != tt.want.TotalFree || got.TotalPaid != tt.want.TotalPaid {
+				t.Errorf("GetAvailabilityReport() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHostAvailability(t *testing.T) {
+	host := func(id uint, country, city string, free bool, mutate func(*models.Host)) models.Host {
+		h := testHost(id, country, free)
+		h.City = city
+		if mutate != nil {
+			mutate(&h)
+		}
+		return h
+	}
+	hosts := []models.Host{
+		host(1, "DE", "Berlin", false, nil),
+		host(2, "DE", "Berlin", true, nil),
+		host(3, "DE", "Frankfurt", false, nil),
+		host(4, "NL", "Amsterdam", false, nil),
+		host(5, "NL", "", false, nil),
+		host(6, "US", "Dallas", true, func(h *models.Host) { h.IsPrivate = true }),
+		host(7, "FR", "Paris", true, func(h *models.Host) { h.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true} }),
+		host(8, "PL", "Warsaw", true, func(h *models.Host) { h.IsOnline = false }),
+		host(9, "SE", "Stockholm", true, func(h *models.Host) { h.Status = customTypes.StatusMaintenance }),
+		host(10, "FI", "Helsinki", true, func(h *models.Host) { h.SecurityType = "reality" }),
+	}
+
+	tests := []struct {
+		name       string
+		isFreeTier *bool
+		want       []customTypes.CountryAvailability
+	}{
+		{
+			name: "all tiers",
+			want: []customTypes.CountryAvailability{
+				{Country: "DE", CityCount: 2, OnlineHostCount: 3, HasFreeTier: true},
+				{Country: "NL", CityCount: 1, OnlineHostCount: 2},
+			},
+		},
+		{
+			name:       "free tier",
+			isFreeTier: ptr(true),
+			want:       []customTypes.CountryAvailability{{Country: "DE", CityCount: 1, OnlineHostCount: 1, HasFreeTier: true}},
+		},
+		{
+			name:       "paid tier",
+			isFreeTier: ptr(false),
+			want: []customTypes.CountryAvailability{
+				{Country: "DE", CityCount: 2, OnlineHostCount: 2},
+				{Country: "NL", CityCount: 1, OnlineHostCount: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestHostService(t, &config.Config{}, hosts...)
+
+			got, err := svc.GetHostAvailability(context.Background(), tt.isFreeTier)
+			if err != nil {
+				t.Fatalf("GetHostAvailability() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetHostAvailability() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHostAvailabilityCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		tiers     []*bool
+		wantCalls int
+	}{
+		{name: "repeated requests are cached", ttl: time.Minute, tiers: []*bool{nil, nil, nil}, wantCalls: 1},
+		{name: "tiers are cached separately", ttl: time.Minute, tiers: []*bool{nil, ptr(true), ptr(false), ptr(true)}, wantCalls: 3},
+		{name: "zero TTL disables caching", tiers: []*bool{nil, nil}, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, &config.Config{HostAvailabilityCacheTTL: tt.ttl}, testHost(1, "DE", true))
+
+			for _, tier := range tt.tiers {
+				if _, err := svc.GetHostAvailability(context.Background(), tier); err != nil {
+					t.Fatalf("GetHostAvailability() error = %v", err)
+				}
+			}
+			if deps.hosts.aggregateCalls != tt.wantCalls {
+				t.Errorf("aggregated availability %d times, want %d", deps.hosts.aggregateCalls, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("expired entries are refreshed", func(t *testing.T) {
+		svc, deps := newTestHostService(t, &config.Config{HostAvailabilityCacheTTL: time.Minute}, testHost(1, "DE", true))
+		if _, err := svc.GetHostAvailability(context.Background(), nil); err != nil {
+			t.Fatalf("GetHostAvailability() error = %v", err)
+		}
+		deps.hosts.hosts = append(deps.hosts.hosts, ptr(testHost(2, "NL", false)))
+		svc.availability.entries["all"] = cachedAvailability{
+			countries: svc.availability.entries["all"].countries,
+			expiresAt: time.Now().Add(-time.Second),
+		}
+
+		got, err := svc.GetHostAvailability(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("GetHostAvailability() error = %v", err)
+		}
+		if len(got) != 2 || deps.hosts.aggregateCalls != 2 {
+			t.Errorf("GetHostAvailability() = %+v after %d aggregations, want both countries after 2", got, deps.hosts.aggregateCalls)
+		}
+	})
+}
+
+// importInputsFor returns the bundle entries restoring hosts, as the host export carries them.
+func importInputsFor(hosts []models.Host) []dto.ImportHostInput {
+	inputs := make([]dto.ImportHostInput, len(hosts))
+	for i, host := range hosts {
+		inputs[i] = dto.ImportHostInput{
+			CreateHostInput: dto.CreateHostInput{
+				HostName: host.HostName, Country: host.Country, City: host.City, Region: host.Region, Provider: host.Provider,
+				Address: host.Address, Port: host.Port, Protocol: host.Protocol, Network: host.Network,
+				PublicKey: host.PublicKey, Flow: host.Flow, RSID: host.RSID, SecurityType: host.SecurityType, SNI: host.SNI, Fingerprint: host.Fingerprint,
+				IsPrivate: host.IsPrivate, IsFreeTier: host.IsFreeTier, MaxUsers: host.MaxUsers, Notes: host.Notes,
+			},
+			Status: host.Status,
+		}
+	}
+	return inputs
+}
+
+func TestExportImportHostsRoundTrip(t *testing.T) {
+	original := []models.Host{
+		{ID: 1, HostName: "de-1", Country: "DE", City: "Berlin", Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp",
+			PublicKey: "pbk", Flow: "xtls-rprx-vision", RSID: "ab", SecurityType: "reality", SNI: "www.example.com", Fingerprint: "chrome",
+			IsFreeTier: true, MaxUsers: 100, Status: customTypes.StatusActive, Notes: "Reboot via provider panel.", IsOnline: true, IssuedCount: 7},
+		{ID: 2, HostName: "us-1", Country: "US", Address: "us1.example.com", Port: "8443", Protocol: "trojan", Network: "ws",
+			IsPrivate: true, Status: customTypes.StatusMaintenance},
+		{ID: 3, HostName: "gone", Address: "gone.example.com", Port: "443", Protocol: "vless", Network: "tcp",
+			DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+	}
+	source, _ := newTestHostService(t, nil, original...)
+
+	exported, err := source.ExportHosts(context.Background())
+	if err != nil {
+		t.Fatalf("ExportHosts() error = %v", err)
+	}
+	if got := len(exported); got != 2 {
+		t.Fatalf("ExportHosts() returned %d hosts, want the 2 non-deleted ones", got)
+	}
+
+	// Restoring into an empty deployment recreates every host with its configuration and status.
+	target, deps := newTestHostService(t, nil)
+	result, err := target.ImportHosts(context.Background(), importInputsFor(exported))
+	if err != nil {
+		t.Fatalf("ImportHosts() error = %v", err)
+	}
+	if !result.Committed || result.Created != 2 || result.Updated != 0 || result.Unchanged != 0 {
+		t.Fatalf("ImportHosts() = %+v, want 2 hosts created", result)
+	}
+	for i, want := range exported[:2] {
+		got := *deps.hosts.hosts[i]
+		want.ID, want.IsOnline, want.IssuedCount = got.ID, false, 0 // Operational state is not restored.
+		want.AddressFamily = got.AddressFamily
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("restored host %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Importing the same bundle again changes nothing.
+	again, err := target.ImportHosts(context.Background(), importInputsFor(exported))
+	if err != nil {
+		t.Fatalf("second ImportHosts() error = %v", err)
+	}
+	if !again.Committed || again.Unchanged != 2 || again.Created != 0 || again.Updated != 0 {
+		t.Errorf("second ImportHosts() = %+v, want 2 hosts unchanged", again)
+	}
+	if got := len(deps.hosts.hosts); got != 2 {
+		t.Errorf("repository holds %d hosts after re-import, want 2", got)
+	}
+}
+
+func TestImportHosts(t *testing.T) {
+	existing := models.Host{ID: 1, HostName: "de-1", Country: "DE", Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp",
+		Status: customTypes.StatusActive, IsOnline: true, IssuedCount: 5}
+	entry := func(edit func(*dto.ImportHostInput)) dto.ImportHostInput {
+		input := importInputsFor([]models.Host{existing})[0]
+		if edit != nil {
+			edit(&input)
+		}
+		return input
+	}
+
+	tests := []struct {
+		name        string
+		inputs      []dto.ImportHostInput
+		wantResults []dto.ImportHostResult // Error is only checked for being non-empty.
+		wantHosts   []models.Host          // Repository contents afterwards; IDs, endpoints and the fields set here are compared.
+	}{
+		{
+			name:        "unchanged",
+			inputs:      []dto.ImportHostInput{entry(nil)},
+			wantResults: []dto.ImportHostResult{{Index: 0, Status: dto.ImportHostStatusUnchanged, HostID: 1}},
+			wantHosts:   []models.Host{existing},
+		},
+		{
+			name: "config updated, operational state kept",
+			inputs: []dto.ImportHostInput{entry(func(in *dto.ImportHostInput) {
+				in.Country, in.Notes, in.Status = "AT", "Moved.", customTypes.StatusMaintenance
+			})},
+			wantResults: []dto.ImportHostResult{{Index: 0, Status: dto.ImportHostStatusUpdated, HostID: 1}},
+			wantHosts: []models.Host{func() models.Host {
+				h := existing
+				h.Country, h.Notes = "AT", "Moved." // Status is only restored for created hosts.
+				return h
+			}()},
+		},
+		{
+			name: "new endpoint created",
+			inputs: []dto.ImportHostInput{entry(nil), entry(func(in *dto.ImportHostInput) {
+				in.HostName, in.Port, in.Status = "de-2", "8443", customTypes.StatusMaintenance
+			})},
+			wantResults: []dto.ImportHostResult{
+				{Index: 0, Status: dto.ImportHostStatusUnchanged, HostID: 1},
+				{Index: 1, Status: dto.ImportHostStatusCreated, HostID: 2},
+			},
+			wantHosts: []models.Host{existing, {ID: 2, HostName: "de-2", Country: "DE", Address: "de1.example.com", Port: "8443", Protocol: "vless", Network: "tcp",
+				Status: customTypes.StatusMaintenance}},
+		},
+		{
+			name: "invalid entry rejects the bundle",
+			inputs: []dto.ImportHostInput{
+				entry(func(in *dto.ImportHostInput) { in.Country = "AT" }),
+				entry(func(in *dto.ImportHostInput) { in.Port = "" }),
+			},
+			wantResults: []dto.ImportHostResult{{Index: 0}, {Index: 1, Status: dto.ImportHostStatusInvalid, Error: "x"}},
+			wantHosts:   []models.Host{existing},
+		},
+		{
+			name:        "invalid status",
+			inputs:      []dto.ImportHostInput{entry(func(in *dto.ImportHostInput) { in.Status = "sleeping" })},
+			wantResults: []dto.ImportHostResult{{Index: 0, Status: dto.ImportHostStatusInvalid, Error: "x"}},
+			wantHosts:   []models.Host{existing},
+		},
+		{
+			name:        "duplicate endpoint in bundle",
+			inputs:      []dto.ImportHostInput{entry(nil), entry(func(in *dto.ImportHostInput) { in.HostName = "copy" })},
+			wantResults: []dto.ImportHostResult{{Index: 0}, {Index: 1, Status: dto.ImportHostStatusInvalid, Error: "x"}},
+			wantHosts:   []models.Host{existing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil, existing)
+
+			result, err := svc.ImportHosts(context.Background(), tt.inputs)
+			if err != nil {
+				t.Fatalf("ImportHosts() error = %v", err)
+			}
+			wantInvalid := 0
+			for _, want := range tt.wantResults {
+				if want.Status == dto.ImportHostStatusInvalid {
+					wantInvalid++
+				}
+			}
+			if result.Committed != (wantInvalid == 0) || result.Invalid != wantInvalid {
+				t.Errorf("ImportHosts() committed = %t, invalid = %d; want %t, %d", result.Committed, result.Invalid, wantInvalid == 0, wantInvalid)
+			}
+			if len(result.Results) != len(tt.wantResults) {
+				t.Fatalf("ImportHosts() returned %d results, want %d", len(result.Results), len(tt.wantResults))
+			}
+			for i, want := range tt.wantResults {
+				got := result.Results[i]
+				if got.Index != want.Index || got.Status != want.Status || got.HostID != want.HostID || (got.Error != "") != (want.Error != "") {
+					t.Errorf("result %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			if len(deps.hosts.hosts) != len(tt.wantHosts) {
+				t.Fatalf("repository holds %d hosts, want %d", len(deps.hosts.hosts), len(tt.wantHosts))
+			}
+			for i, want := range tt.wantHosts {
+				got := deps.hosts.hosts[i]
+				if got.ID != want.ID || got.Port != want.Port || got.HostName != want.HostName || got.Country != want.Country ||
+					got.Notes != want.Notes || got.Status != want.Status || got.IsOnline != want.IsOnline || got.IssuedCount != want.IssuedCount {
+					t.Errorf("host %d = %+v, want %+v", i, *got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHostFreeTier(t *testing.T) {
+	svc, deps := newTestHostService(t, nil)
+	ctx := context.Background()
+
+	free, err := svc.AddHost(ctx, dto.CreateHostInput{Address: "free.example.com", Port: "443", Protocol: "vless", IsFreeTier: true})
+	if err != nil {
+		t.Fatalf("AddHost(free) error = %v", err)
+	}
+	paid, err := svc.AddHost(ctx, dto.CreateHostInput{Address: "paid.example.com", Port: "443", Protocol: "vless"})
+	if err != nil {
+		t.Fatalf("AddHost(paid) error = %v", err)
+	}
+	if stored, _ := deps.hosts.GetByID(ctx, free.ID); !stored.IsFreeTier {
+		t.Errorf("host created with is_free_tier is stored as paid")
+	}
+	if stored, _ := deps.hosts.GetByID(ctx, paid.ID); stored.IsFreeTier {
+		t.Errorf("host created without is_free_tier is stored as free")
+	}
+
+	tests := []struct {
+		name    string
+		tier    *bool
+		wantIDs []uint
+	}{
+		{name: "all tiers", wantIDs: []uint{free.ID, paid.ID}},
+		{name: "free", tier: ptr(true), wantIDs: []uint{free.ID}},
+		{name: "paid", tier: ptr(false), wantIDs: []uint{paid.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, total, err := svc.ListHosts(ctx, dto.ListHostsServiceParams{IsFreeTier: tt.tier})
+			if err != nil {
+				t.Fatalf("ListHosts() error = %v", err)
+			}
+			var ids []uint
+			for _, host := range hosts {
+				ids = append(ids, host.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) || total != int64(len(tt.wantIDs)) {
+				t.Errorf("ListHosts() = %v (total %d), want %v", ids, total, tt.wantIDs)
+			}
+		})
+	}
+
+	// UpdateHost leaves the tier alone unless it is given.
+	if _, err := svc.UpdateHost(ctx, free.ID, dto.UpdateHostInput{City: ptr("Berlin")}); err != nil {
+		t.Fatalf("UpdateHost() error = %v", err)
+	}
+	if stored, _ := deps.hosts.GetByID(ctx, free.ID); !stored.IsFreeTier {
+		t.Errorf("UpdateHost without is_free_tier moved the host to the paid tier")
+	}
+	if _, err := svc.UpdateHost(ctx, free.ID, dto.UpdateHostInput{IsFreeTier: ptr(false)}); err != nil {
+		t.Fatalf("UpdateHost() error = %v", err)
+	}
+	if stored, _ := deps.hosts.GetByID(ctx, free.ID); stored.IsFreeTier {
+		t.Errorf("UpdateHost with is_free_tier false left the host in the free tier")
+	}
+}
+
+func TestSetHostTier(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     bool // Tier of the stored host.
+		hostID     uint
+		isFreeTier bool
+		wantErr    error
+		wantUpdate bool
+	}{
+		{name: "paid to free", stored: false, hostID: 1, isFreeTier: true, wantUpdate: true},
+		{name: "free to paid", stored: true, hostID: 1, isFreeTier: false, wantUpdate: true},
+		{name: "already free", stored: true, hostID: 1, isFreeTier: true},
+		{name: "already paid", stored: false, hostID: 1, isFreeTier: false},
+		{name: "unknown host", hostID: 2, isFreeTier: true, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := testHost(1, "DE", tt.stored)
+			svc, deps := newTestHostService(t, nil, stored)
+			updated := false
+			deps.hosts.beforeUpdate = func() {
+				updated = true
+				// A concurrent writer changes another column, which the tier change must not overwrite.
+				deps.hosts.mu.Lock()
+				defer deps.hosts.mu.Unlock()
+				deps.hosts.hosts[0].Notes = "Concurrent notes"
+			}
+
+			host, err := svc.SetHostTier(context.Background(), tt.hostID, tt.isFreeTier)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetHostTier() error = %v, want %v", err, tt.wantErr)
+			}
+			if updated != tt.wantUpdate {
+				t.Errorf("repository updated = %t, want %t", updated, tt.wantUpdate)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if host.IsFreeTier != tt.isFreeTier {
+				t.Errorf("SetHostTier() returned tier %t, want %t", host.IsFreeTier, tt.isFreeTier)
+			}
+			got := deps.hosts.hosts[0]
+			if got.IsFreeTier != tt.isFreeTier {
+				t.Errorf("stored tier = %t, want %t", got.IsFreeTier, tt.isFreeTier)
+			}
+			if tt.wantUpdate && got.Notes != "Concurrent notes" {
+				t.Errorf("stored notes = %q, want the concurrent change kept", got.Notes)
+			}
+		})
+	}
+}
+
+func TestSetHostTierDeletedConcurrently(t *testing.T) {
+	stored := testHost(1, "DE", false)
+	svc, deps := newTestHostService(t, nil, stored)
+	deps.hosts.beforeUpdate = func() {
+		deps.hosts.mu.Lock()
+		defer deps.hosts.mu.Unlock()
+		deps.hosts.hosts[0].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+
+	if _, err := svc.SetHostTier(context.Background(), stored.ID, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetHostTier() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestHostSecurityEnforcement(t *testing.T) {
+	reality := func(publicKey string) dto.CreateHostInput {
+		return dto.CreateHostInput{Address: "de1.example.com", Port: "443", Protocol: "vless", SecurityType: "reality", PublicKey: publicKey}
+	}
+	// incomplete is a stored Reality host without a public key, which keys cannot be generated for.
+	incomplete := models.Host{ID: 1, Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "tcp",
+		SecurityType: "reality", Status: customTypes.StatusInactive}
+	complete := incomplete
+	complete.PublicKey = "pbk"
+
+	tests := []struct {
+		name    string
+		stored  models.Host
+		call    func(svc *hostService) error
+		wantErr bool // When host security is required; nothing is refused otherwise.
+	}{
+		{
+			name: "add complete host",
+			call: func(svc *hostService) error { _, err := svc.AddHost(context.Background(), reality("pbk")); return err },
+		},
+		{
+			name:    "add incomplete host",
+			call:    func(svc *hostService) error { _, err := svc.AddHost(context.Background(), reality("")); return err },
+			wantErr: true,
+		},
+		{
+			name: "bulk add incomplete host",
+			call: func(svc *hostService) error {
+				result, err := svc.AddHosts(context.Background(), []dto.CreateHostInput{reality("")}, false)
+				if err == nil && result.Results[0].Status == dto.BulkHostStatusInvalid {
+					err = errors.New(result.Results[0].Error)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "import incomplete host",
+			call: func(svc *hostService) error {
+				result, err := svc.ImportHosts(context.Background(), []dto.ImportHostInput{{CreateHostInput: reality("")}})
+				if err == nil && result.Results[0].Status == dto.ImportHostStatusInvalid {
+					err = errors.New(result.Results[0].Error)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "activate complete host",
+			stored: complete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHostOnlineStatus(context.Background(), 1, dto.UpdateHostStatusInput{IsOnline: true, Status: customTypes.StatusActive})
+				return err
+			},
+		},
+		{
+			name:   "activate incomplete host",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHostOnlineStatus(context.Background(), 1, dto.UpdateHostStatusInput{Status: customTypes.StatusActive})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "mark incomplete host online",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHostOnlineStatus(context.Background(), 1, dto.UpdateHostStatusInput{IsOnline: true, Status: customTypes.StatusMaintenance})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "take incomplete host offline",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHostOnlineStatus(context.Background(), 1, dto.UpdateHostStatusInput{Status: customTypes.StatusMaintenance})
+				return err
+			},
+		},
+		{
+			name:   "online check of incomplete host",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.RecordHostCheck(context.Background(), 1, dto.HostCheckResult{IsOnline: true})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "offline check of incomplete host",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.RecordHostCheck(context.Background(), 1, dto.HostCheckResult{Error: "timeout"})
+				return err
+			},
+		},
+		{
+			name:   "update removes the public key",
+			stored: complete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHost(context.Background(), 1, dto.UpdateHostInput{PublicKey: ptr("")})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:   "update completes the configuration",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHost(context.Background(), 1, dto.UpdateHostInput{PublicKey: ptr("pbk")})
+				return err
+			},
+		},
+		{
+			name:   "update switches an incomplete host to tls",
+			stored: incomplete,
+			call: func(svc *hostService) error {
+				_, err := svc.UpdateHost(context.Background(), 1, dto.UpdateHostInput{SecurityType: ptr("tls")})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, required := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/required=%t", tt.name, required), func(t *testing.T) {
+				cfg := &config.Config{AllowedHostProtocols: []string{"vless"}, RequireHostSecurity: required}
+				var stored []models.Host
+				if tt.stored.ID != 0 {
+					stored = append(stored, tt.stored)
+				}
+				svc, deps := newTestHostService(t, cfg, stored...)
+
+				err := tt.call(svc)
+				if wantErr := tt.wantErr && required; wantErr {
+					if err == nil || !strings.Contains(err.Error(), "public key") {
+						t.Fatalf("error = %v, want one describing the missing public key", err)
+					}
+					if !slices.EqualFunc(deps.hosts.hosts, stored, func(got *models.Host, want models.Host) bool { return reflect.DeepEqual(*got, want) }) {
+						t.Errorf("stored hosts changed despite the error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("error = %v, want none", err)
+				}
+			})
+		}
+	}
+}
+
+func TestHostAddressFamilyClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    customTypes.AddressFamily
+	}{
+		{name: "IPv4", address: "203.0.113.7", want: customTypes.AddressFamilyIPv4},
+		{name: "IPv6", address: "2001:db8::1", want: customTypes.AddressFamilyIPv6},
+		{name: "hostname", address: " de1.example.com ", want: customTypes.AddressFamilyHostname},
+	}
+	for _, tt := range tests {
+		t.Run("add "+tt.name, func(t *testing.T) {
+			svc, deps := newTestHostService(t, nil)
+
+			host, err := svc.AddHost(context.Background(), dto.CreateHostInput{Address: tt.address, Port: "443", Protocol: "vless"})
+			if err != nil {
+				t.Fatalf("AddHost() error = %v", err)
+			}
+			if host.AddressFamily != tt.want || deps.hosts.hosts[0].AddressFamily != tt.want {
+				t.Errorf("address family = %q, stored %q, want %q", host.AddressFamily, deps.hosts.hosts[0].AddressFamily, tt.want)
+			}
+		})
+		t.Run("update "+tt.name, func(t *testing.T) {
+			stored := models.Host{ID: 1, Address: "198.51.100.1", AddressFamily: customTypes.AddressFamilyIPv4, Port: "443", Protocol: "vless", Network: "tcp"}
+			svc, deps := newTestHostService(t, nil, stored)
+
+			if _, err := svc.UpdateHost(context.Background(), stored.ID, dto.UpdateHostInput{Address: ptr(tt.address)}); err != nil {
+				t.Fatalf("UpdateHost() error = %v", err)
+			}
+			if got := deps.hosts.hosts[0].AddressFamily; got != tt.want {
+				t.Errorf("stored address family = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unchanged address keeps its family", func(t *testing.T) {
+		stored := models.Host{ID: 1, Address: "198.51.100.1", AddressFamily: customTypes.AddressFamilyIPv4, Port: "443", Protocol: "vless", Network: "tcp"}
+		svc, deps := newTestHostService(t, nil, stored)
+
+		if _, err := svc.UpdateHost(context.Background(), stored.ID, dto.UpdateHostInput{Port: ptr("8443")}); err != nil {
+			t.Fatalf("UpdateHost() error = %v", err)
+		}
+		if got := deps.hosts.hosts[0].AddressFamily; got != customTypes.AddressFamilyIPv4 {
+			t.Errorf("stored address family = %q, want %q", got, customTypes.AddressFamilyIPv4)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bitback/internal/services/dto"
+	"context"
+	"sync"
+	"time"
+)
+
+// keyRequest is an in-flight or recently completed user key request.
+type keyRequest struct {
+	done      chan struct{} // Closed once result and err are set.
+	result    *dto.GenerateUserKeyResult
+	err       error
+	expiresAt time.Time // When the result stops being reused; set once the request succeeds.
+}
+
+// keyRequestDeduplicator makes identical key requests within a short window share one result, so that
+// clients retrying on flaky networks do not create a new key assignment for every attempt.
+// Requests arriving while an identical one is in flight wait for it; failed requests are not remembered.
+type keyRequestDeduplicator struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	requests map[string]*keyRequest
+}
+
+func newKeyRequestDeduplicator(window time.Duration) *keyRequestDeduplicator {
+	return &keyRequestDeduplicator{
+		window:   window,
+		requests: make(map[string]*keyRequest),
+	}
+}
+
+// do returns the result of an identical request made within the window, or runs generate and remembers its result.
+// The returned flag reports whether the result was reused. With a zero window, generate is always run.
+func (d *keyRequestDeduplicator) do(ctx context.Context, key string, generate func() (*dto.GenerateUserKeyResult, error)) (*dto.GenerateUserKeyResult, bool, error) {
+	if d.window <= 0 {
+		result, err := generate()
+		return result, false, err
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	for k, req := range d.requests {
+		if !req.expiresAt.IsZero() && !now.Before(req.expiresAt) {
+			delete(d.requests, k)
+		}
+	}
+	if req, ok := d.requests[key]; ok {
+		d.mu.Unlock()
+		select {
+		case <-req.done:
+			return req.result, true, req.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	req := &keyRequest{done: make(chan struct{})}
+	d.requests[key] = req
+	d.mu.Unlock()
+
+	req.result, req.err = generate()
+
+	d.mu.Lock()
+	if req.err != nil {
+		delete(d.requests, key)
+	} else {
+		req.expiresAt = time.Now().Add(d.window)
+	}
+	d.mu.Unlock()
+	close(req.done)
+	return req.result, false, req.err
+}
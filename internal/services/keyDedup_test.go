@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyRequestDeduplicator(t *testing.T) {
+	generateErr := errors.New("no hosts")
+
+	type call struct {
+		key        string
+		err        error // Returned by generate, if it runs.
+		wantRun    bool
+		wantReused bool
+	}
+	tests := []struct {
+		name   string
+		window time.Duration
+		expire bool // Whether remembered results expire between calls.
+		calls  []call
+	}{
+		{
+			name:   "disabled",
+			window: 0,
+			calls:  []call{{key: "a", wantRun: true}, {key: "a", wantRun: true}},
+		},
+		{
+			name:   "identical request within the window",
+			window: time.Minute,
+			calls:  []call{{key: "a", wantRun: true}, {key: "a", wantReused: true}, {key: "a", wantReused: true}},
+		},
+		{
+			name:   "different requests",
+			window: time.Minute,
+			calls:  []call{{key: "a", wantRun: true}, {key: "b", wantRun: true}, {key: "a", wantReused: true}},
+		},
+		{
+			name:   "failed request is not remembered",
+			window: time.Minute,
+			calls:  []call{{key: "a", err: generateErr, wantRun: true}, {key: "a", wantRun: true}, {key: "a", wantReused: true}},
+		},
+		{
+			name:   "expired result",
+			window: time.Minute,
+			expire: true,
+			calls:  []call{{key: "a", wantRun: true}, {key: "a", wantRun: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newKeyRequestDeduplicator(tt.window)
+			var first *dto.GenerateUserKeyResult
+
+			for i, c := range tt.calls {
+				if tt.expire {
+					d.mu.Lock()
+					for _, req := range d.requests {
+						req.expiresAt = time.Now().Add(-time.Second)
+					}
+					d.mu.Unlock()
+				}
+
+				ran := false
+				result, reused, err := d.do(context.Background(), c.key, func() (*dto.GenerateUserKeyResult, error) {
+					ran = true
+					if c.err != nil {
+						return nil, c.err
+					}
+					return &dto.GenerateUserKeyResult{VlessKey: c.key}, nil
+				})
+				if !errors.Is(err, c.err) {
+					t.Fatalf("call %d: do() error = %v, want %v", i, err, c.err)
+				}
+				if ran != c.wantRun || reused != c.wantReused {
+					t.Errorf("call %d: ran = %t, reused = %t; want %t, %t", i, ran, reused, c.wantRun, c.wantReused)
+				}
+				if c.wantReused && result != first {
+					t.Errorf("call %d: result %p is not the remembered one %p", i, result, first)
+				}
+				if c.key == "a" && c.wantRun && err == nil {
+					first = result
+				}
+			}
+		})
+	}
+}
+
+func TestKeyRequestDeduplicatorConcurrent(t *testing.T) {
+	d := newKeyRequestDeduplicator(time.Minute)
+	release := make(chan struct{})
+	var runs atomic.Int32
+
+	const requests = 10
+	results := make([]*dto.GenerateUserKeyResult, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _, err := d.do(context.Background(), "a", func() (*dto.GenerateUserKeyResult, error) {
+				runs.Add(1)
+				<-release
+				return &dto.GenerateUserKeyResult{VlessKey: "vless://a"}, nil
+			})
+			if err != nil {
+				t.Errorf("do() error = %v", err)
+			}
+			results[i] = result
+		}()
+	}
+	// Give the callers time to wait on the in-flight request; any arriving later reuse its remembered result.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("generate ran %d times, want 1", got)
+	}
+	for i, result := range results {
+		if result != results[0] {
+			t.Errorf("request %d got result %p, want the shared %p", i, result, results[0])
+		}
+	}
+}
+
+func TestKeyRequestDeduplicatorWaiterCanceled(t *testing.T) {
+	d := newKeyRequestDeduplicator(time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	go d.do(context.Background(), "a", func() (*dto.GenerateUserKeyResult, error) {
+		close(started)
+		<-release
+		return &dto.GenerateUserKeyResult{}, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, reused, err := d.do(ctx, "a", func() (*dto.GenerateUserKeyResult, error) {
+		t.Error("generate ran while an identical request was in flight")
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) || reused {
+		t.Errorf("do() reused = %t, error = %v; want a canceled context error", reused, err)
+	}
+}
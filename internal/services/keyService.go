@@ -1,15 +1,20 @@
 package services
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"bitback/internal/services/dto"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -19,21 +24,69 @@ type keyService struct {
 	userRepo         interfaces.UserRepository
 	hostRepo         interfaces.HostRepository
 	subscriptionRepo interfaces.SubscriptionRepository
+	assignmentRepo   interfaces.KeyAssignmentRepository
+	generationRepo   interfaces.KeyGenerationRepository
+	metrics          interfaces.KeyMetrics
+	cfg              *config.Config
+
+	selector *HostSelector
+	dedup    *keyRequestDeduplicator
+
+	invalidHostSkips atomic.Int64 // Number of selected hosts skipped because their configuration could not produce a key.
 }
 
 // NewKeyService creates a new instance of KeyService.
-func NewKeyService(ur interfaces.UserRepository, hr interfaces.HostRepository, sr interfaces.SubscriptionRepository) interfaces.KeyService {
+// The metrics recorder is optional; if it is nil, generated keys are not counted.
+// The selector makes the random choices among equally suitable hosts.
+func NewKeyService(ur interfaces.UserRepository, hr interfaces.HostRepository, sr interfaces.SubscriptionRepository, ar interfaces.KeyAssignmentRepository, gr interfaces.KeyGenerationRepository, metrics interfaces.KeyMetrics, selector *HostSelector, cfg *config.Config) interfaces.KeyService {
 	return &keyService{
 		userRepo:         ur,
 		hostRepo:         hr,
 		subscriptionRepo: sr,
+		assignmentRepo:   ar,
+		generationRepo:   gr,
+		metrics:          metrics,
+		cfg:              cfg,
+		selector:         selector,
+		dedup:            newKeyRequestDeduplicator(cfg.KeyRequestDedupWindow),
+	}
+}
+
+// recordKeyGenerated logs a key issued on host for the user with the given ID, or for an anonymous free user if
+// userID is nil, and counts it if a metrics recorder is configured. A failure to log the key is not returned,
+// as it does not invalidate the key.
+func (s *keyService) recordKeyGenerated(ctx context.Context, host *models.Host, userID *uuid.UUID) {
+	if s.metrics != nil {
+		s.metrics.KeyGenerated(host.IsFreeTier)
+	}
+	generation := &models.KeyGeneration{
+		UserID:     userID,
+		HostID:     host.ID,
+		IsFreeTier: host.IsFreeTier,
+	}
+	if err := s.generationRepo.Create(ctx, generation); err != nil {
+		slog.ErrorContext(ctx, "recordKeyGenerated: failed to log key generation", "hostID", host.ID, "userID", userID, "error", err)
 	}
 }
 
 // GenerateVlessKeyForUser generates a VLESS key string for a given user.
 // It selects an active host based on subscription status and constructs the VLESS URL.
-func (s *keyService) GenerateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, country *string) (*dto.GenerateUserKeyResult, error) {
-	slog.InfoContext(ctx, "GenerateVlessKeyForUser: attempting to generate key", "userID", userID, "country", country)
+// Identical requests of the same user within the configured deduplication window return the same key.
+func (s *keyService) GenerateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs dto.HostPreferences, allowFreeFallback bool) (*dto.GenerateUserKeyResult, error) {
+	key := strings.Join([]string{userID.String(), remarks, prefs.Country, prefs.Network, prefs.SecurityType, string(prefs.AddressFamily), strconv.FormatBool(allowFreeFallback)}, "|")
+
+	result, reused, err := s.dedup.do(ctx, key, func() (*dto.GenerateUserKeyResult, error) {
+		return s.generateVlessKeyForUser(ctx, userID, remarks, prefs, allowFreeFallback)
+	})
+	if reused && err == nil {
+		slog.InfoContext(ctx, "GenerateVlessKeyForUser: duplicate request within the deduplication window, returning the same key", "userID", userID)
+	}
+	return result, err
+}
+
+// generateVlessKeyForUser selects a host for the user and issues a new key on it, recording the assignment.
+func (s *keyService) generateVlessKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs dto.HostPreferences, allowFreeFallback bool) (*dto.GenerateUserKeyResult, error) {
+	slog.InfoContext(ctx, "GenerateVlessKeyForUser: attempting to generate key", "userID", userID, "country", prefs.Country, "network", prefs.Network, "securityType", prefs.SecurityType)
 
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
@@ -45,139 +98,678 @@ func (s *keyService) GenerateVlessKeyForUser(ctx context.Context, userID uuid.UU
 		return nil, fmt.Errorf("could not retrieve user: %w", err)
 	}
 
-	hasActiveSubscription, err := s.subscriptionRepo.CheckUserActiveSubscription(ctx, userID)
+	// The active subscription's end date is reported as the key's expiry.
+	subscription, err := s.subscriptionRepo.GetActiveByUserID(ctx, userID)
 	if err != nil {
-		slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to check user subscription status", "userID", userID, "error", err)
-		hasActiveSubscription = false // Default to no subscription if check fails
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to get user's active subscription", "userID", userID, "error", err)
+		}
+		subscription = nil // Default to no subscription if the lookup fails.
+	}
+	hasActiveSubscription := subscription != nil
+	var expiresAt *time.Time
+	if hasActiveSubscription {
+		expiresAt = &subscription.EndDate
 	}
 
-	var hostTier bool // true for free, false for paid
 	if hasActiveSubscription {
 		slog.InfoContext(ctx, "GenerateVlessKeyForUser: user has active subscription, seeking paid host", "userID", userID)
-		hostTier = false // User has subscription, look for a paid host
 	} else {
 		slog.InfoContext(ctx, "GenerateVlessKeyForUser: user has no active subscription, seeking free host", "userID", userID)
-		hostTier = true // User has no subscription, look for a free host
 	}
 
-	host, err := s.hostRepo.GetRandomActiveHost(ctx, country, &hostTier)
+	host, vlessConfig, err := s.acquireUserHostWithConfig(ctx, user.ID.String(), remarks, prefs, hasActiveSubscription, allowFreeFallback)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			slog.WarnContext(ctx, "GenerateVlessKeyForUser: no active hosts available for the tier/country", "tier_is_free", hostTier, "country", country)
-			// Try fallback: if a specific country was requested and no host found, try without country filter for the same tier
-			if country != nil && *country != "" {
-				slog.InfoContext(ctx, "GenerateVlessKeyForUser: fallback - trying without country filter for tier", "tier_is_free", hostTier)
-				host, err = s.hostRepo.GetRandomActiveHost(ctx, nil, &hostTier)
-			}
-		}
-		// If still not found or other error
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				slog.WarnContext(ctx, "GenerateVlessKeyForUser: no active hosts available even after fallback", "tier_is_free", hostTier)
-				return nil, errors.New("no active hosts available to generate key for the specified criteria")
-			}
-			slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to get active host", "error", err)
-			return nil, fmt.Errorf("could not retrieve an active host: %w", err)
+			slog.WarnContext(ctx, "GenerateVlessKeyForUser: no active hosts available even after fallback", "hasActiveSubscription", hasActiveSubscription, "allowFreeFallback", allowFreeFallback)
+			return nil, errors.New("no active hosts available to generate key for the specified criteria")
 		}
+		slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to get active host", "error", err)
+		return nil, fmt.Errorf("could not retrieve an active host: %w", err)
 	}
 	slog.DebugContext(ctx, "GenerateVlessKeyForUser: selected host", "hostID", host.ID, "hostAddress", host.Address, "isFreeTier", host.IsFreeTier)
 
-	vlessUserID := user.ID.String()
-	vlessURL, err := s.constructVlessURL(vlessUserID, host, remarks)
-	if err != nil {
-		slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to construct VLESS URL", "userID", userID, "hostID", host.ID, "error", err)
-		return nil, err
+	servedTier := dto.ServedTierPaid
+	if host.IsFreeTier {
+		servedTier = dto.ServedTierFree
+	}
+	degraded := hasActiveSubscription && host.IsFreeTier
+	if degraded {
+		slog.WarnContext(ctx, "GenerateVlessKeyForUser: subscribed user served a free host", "userID", userID, "hostID", host.ID)
+	}
+
+	vlessURL := vlessURLFromConfig(vlessConfig)
+
+	// Record the assignment so the key can be re-sent later without selecting a new host.
+	// A failure here does not invalidate the generated key.
+	assignment := &models.KeyAssignment{
+		UserID:   user.ID,
+		HostID:   host.ID,
+		Remarks:  remarks,
+		IsActive: true,
+	}
+	if err := s.assignmentRepo.Create(ctx, assignment); err != nil {
+		slog.ErrorContext(ctx, "GenerateVlessKeyForUser: failed to record key assignment", "userID", userID, "hostID", host.ID, "error", err)
 	}
 
+	s.recordKeyGenerated(ctx, host, &user.ID)
 	slog.InfoContext(ctx, "GenerateVlessKeyForUser: VLESS key generated successfully", "userID", userID, "hostID", host.ID, "hasActiveSubscription", hasActiveSubscription)
 	return &dto.GenerateUserKeyResult{
 		VlessKey:              vlessURL,
+		Config:                *vlessConfig,
+		Host:                  keyHostOf(host),
 		HasActiveSubscription: hasActiveSubscription,
+		ExpiresAt:             expiresAt,
+		ServedTier:            servedTier,
+		Degraded:              degraded,
 	}, nil
 }
 
+// acquireUserHostWithConfig selects a host for a user's key, relaxing the criteria step by step until a host is found:
+// the user's tier through every step of hostSelectionLadder. When allowFreeFallback is set, subscribed users
+// then fall back to a free host through the same steps.
+// It returns gorm.ErrRecordNotFound if every step finds no host.
+func (s *keyService) acquireUserHostWithConfig(ctx context.Context, vlessUserID, remarks string, prefs dto.HostPreferences, hasActiveSubscription, allowFreeFallback bool) (*models.Host, *dto.VlessConfig, error) {
+	tiers := []bool{!hasActiveSubscription} // true for free, false for paid.
+	if hasActiveSubscription && allowFreeFallback {
+		tiers = append(tiers, true)
+	}
+
+	for _, isFreeTier := range tiers {
+		host, vlessConfig, err := s.acquireHostWithLadder(ctx, vlessUserID, remarks, prefs, isFreeTier)
+		if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+			return host, vlessConfig, err
+		}
+	}
+	return nil, nil, gorm.ErrRecordNotFound
+}
+
+// acquireHostWithLadder tries the steps of hostSelectionLadder for the preferences in order, restricted to the tier,
+// and returns the host of the first step that finds one. It returns gorm.ErrRecordNotFound if no step does.
+func (s *keyService) acquireHostWithLadder(ctx context.Context, vlessUserID, remarks string, prefs dto.HostPreferences, isFreeTier bool) (*models.Host, *dto.VlessConfig, error) {
+	for step, filter := range hostSelectionLadder(prefs) {
+		filter.IsFreeTier = &isFreeTier
+		host, vlessConfig, err := s.acquireHostWithConfig(ctx, vlessUserID, remarks, filter, nil)
+		if err == nil {
+			return host, vlessConfig, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+		slog.InfoContext(ctx, "acquireHostWithLadder: no active hosts available, trying fallback", "tier_is_free", isFreeTier, "step", step,
+			"country", filter.Country, "network", filter.Network, "securityType", filter.SecurityType, "addressFamily", filter.AddressFamily)
+	}
+	return nil, nil, gorm.ErrRecordNotFound
+}
+
+// hostSelectionLadder returns the host filters to try, in order, for the preferences; the tier is left unset.
+// With a transport (network or security type) preference the steps are: the exact preferences, the preferred
+// country with any transport, and the preferred transport in any country. Without one they are the preferred
+// country and any country. Steps that would repeat an earlier one are left out.
+// With an IP address family preference, the steps are tried on hosts of that family first and then, as a
+// fallback for when none match, on any host.
+func hostSelectionLadder(prefs dto.HostPreferences) []customTypes.HostSelectionFilter {
+	steps := transportLadder(prefs)
+	if !prefs.AddressFamily.IsIP() {
+		return steps
+	}
+	family := prefs.AddressFamily
+	ladder := make([]customTypes.HostSelectionFilter, 0, 2*len(steps))
+	for _, step := range steps {
+		step.AddressFamily = &family
+		ladder = append(ladder, step)
+	}
+	return append(ladder, steps...)
+}
+
+// transportLadder returns the country and transport steps of hostSelectionLadder.
+func transportLadder(prefs dto.HostPreferences) []customTypes.HostSelectionFilter {
+	country := optionalString(prefs.Country)
+	network := optionalString(prefs.Network)
+	securityType := optionalString(prefs.SecurityType)
+
+	if network == nil && securityType == nil {
+		if country == nil {
+			return []customTypes.HostSelectionFilter{{}}
+		}
+		return []customTypes.HostSelectionFilter{{Country: country}, {}}
+	}
+	transport := customTypes.HostSelectionFilter{Network: network, SecurityType: securityType}
+	if country == nil {
+		return []customTypes.HostSelectionFilter{transport}
+	}
+	exact := transport
+	exact.Country = country
+	return []customTypes.HostSelectionFilter{exact, {Country: country}, transport}
+}
+
+// optionalString returns a pointer to the trimmed value, or nil if it is empty.
+func optionalString(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// keyHostOf returns the attributes of host reported to clients alongside a key.
+func keyHostOf(host *models.Host) dto.KeyHost {
+	return dto.KeyHost{
+		Country:       host.Country,
+		Network:       host.Network,
+		SecurityType:  host.SecurityType,
+		AddressFamily: host.AddressFamily,
+	}
+}
+
+// GenerateVlessKeysForUser generates one VLESS key per country for a user, each on a representative online host
+// of the user's tier. A single repository query loads the best ranked hosts of each country and one of them is
+// picked at random. Hosts whose configuration cannot produce a key are skipped.
+// The keys are not recorded as assignments, so the user's current key is unchanged.
+func (s *keyService) GenerateVlessKeysForUser(ctx context.Context, userID uuid.UUID, remarks string, countries []string) (*dto.MultiCountryKeysResult, error) {
+	slog.InfoContext(ctx, "GenerateVlessKeysForUser: attempting to generate keys", "userID", userID, "countries", countries)
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GenerateVlessKeysForUser: user not found", "userID", userID)
+			return nil, fmt.Errorf("user with ID %s not found", userID)
+		}
+		slog.ErrorContext(ctx, "GenerateVlessKeysForUser: failed to get user", "userID", userID, "error", err)
+		return nil, fmt.Errorf("could not retrieve user: %w", err)
+	}
+
+	subscription, err := s.subscriptionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "GenerateVlessKeysForUser: failed to get user's active subscription", "userID", userID, "error", err)
+		}
+		subscription = nil // Default to no subscription if the lookup fails.
+	}
+	result := &dto.MultiCountryKeysResult{HasActiveSubscription: subscription != nil}
+	if subscription != nil {
+		result.ExpiresAt = &subscription.EndDate
+	}
+
+	isFreeTier := !result.HasActiveSubscription
+	candidates, err := s.hostRepo.ListCountryCandidates(ctx, customTypes.HostSelectionFilter{IsFreeTier: &isFreeTier}, countries, maxHostCandidatesPerCountry, s.cfg.MaxKeyCountries)
+	if err != nil {
+		slog.ErrorContext(ctx, "GenerateVlessKeysForUser: failed to list hosts per country", "error", err)
+		return nil, fmt.Errorf("could not retrieve hosts per country: %w", err)
+	}
+	hosts := s.selector.PickPerCountry(candidates)
+
+	result.Keys = make([]dto.CountryKey, 0, len(hosts))
+	for i := range hosts {
+		host := &hosts[i]
+		vlessURL, err := s.constructVlessURL(userID.String(), host, remarks)
+		if err != nil {
+			skipped := s.invalidHostSkips.Add(1)
+			slog.WarnContext(ctx, "GenerateVlessKeysForUser: skipping misconfigured host", "hostID", host.ID, "country", host.Country, "invalidHostSkipsTotal", skipped, "error", err)
+			continue
+		}
+		s.recordKeyGenerated(ctx, host, &userID)
+		result.Keys = append(result.Keys, dto.CountryKey{
+			VlessKey: vlessURL,
+			HostID:   host.ID,
+			Host:     keyHostOf(host),
+		})
+	}
+	if len(result.Keys) == 0 {
+		slog.WarnContext(ctx, "GenerateVlessKeysForUser: no active hosts available in any requested country", "userID", userID, "isFreeTier", isFreeTier)
+		return nil, errors.New("no active hosts available to generate keys for the specified criteria")
+	}
+
+	slog.InfoContext(ctx, "GenerateVlessKeysForUser: VLESS keys generated successfully", "userID", userID, "count", len(result.Keys), "hasActiveSubscription", result.HasActiveSubscription)
+	return result, nil
+}
+
+// GetSubscriptionFeed builds one VLESS key for every online host eligible for the user's tier, for clients that
+// import a subscription URL. Users without an active subscription get free-tier hosts only. Hosts whose configuration
+// cannot produce a key are skipped. Clients refresh feeds periodically, so the keys are neither recorded as
+// assignments nor logged as generated keys.
+func (s *keyService) GetSubscriptionFeed(ctx context.Context, userID uuid.UUID, remarks string) (*dto.SubscriptionFeed, error) {
+	slog.InfoContext(ctx, "GetSubscriptionFeed: building subscription feed", "userID", userID)
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetSubscriptionFeed: user not found", "userID", userID)
+			return nil, fmt.Errorf("user with ID %s not found", userID)
+		}
+		slog.ErrorContext(ctx, "GetSubscriptionFeed: failed to get user", "userID", userID, "error", err)
+		return nil, fmt.Errorf("could not retrieve user: %w", err)
+	}
+
+	subscription, err := s.subscriptionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "GetSubscriptionFeed: failed to get user's active subscription", "userID", userID, "error", err)
+		}
+		subscription = nil // Default to no subscription if the lookup fails.
+	}
+	feed := &dto.SubscriptionFeed{HasActiveSubscription: subscription != nil}
+	if subscription != nil {
+		feed.ExpiresAt = &subscription.EndDate
+	}
+
+	isFreeTier := !feed.HasActiveSubscription
+	hosts, err := s.hostRepo.ListSelectableHosts(ctx, customTypes.HostSelectionFilter{IsFreeTier: &isFreeTier}, maxSubscriptionFeedHosts)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetSubscriptionFeed: failed to list selectable hosts", "error", err)
+		return nil, fmt.Errorf("could not retrieve hosts: %w", err)
+	}
+
+	feed.VlessKeys = make([]string, 0, len(hosts))
+	for i := range hosts {
+		vlessURL, err := s.constructVlessURL(userID.String(), &hosts[i], remarks)
+		if err != nil {
+			skipped := s.invalidHostSkips.Add(1)
+			slog.WarnContext(ctx, "GetSubscriptionFeed: skipping misconfigured host", "hostID", hosts[i].ID, "invalidHostSkipsTotal", skipped, "error", err)
+			continue
+		}
+		feed.VlessKeys = append(feed.VlessKeys, vlessURL)
+	}
+	if len(feed.VlessKeys) == 0 {
+		slog.WarnContext(ctx, "GetSubscriptionFeed: no active hosts available", "userID", userID, "isFreeTier", isFreeTier)
+		return nil, errors.New("no active hosts available to build the subscription feed")
+	}
+
+	slog.InfoContext(ctx, "GetSubscriptionFeed: subscription feed built", "userID", userID, "count", len(feed.VlessKeys), "hasActiveSubscription", feed.HasActiveSubscription)
+	return feed, nil
+}
+
+// GetKeyGenerationRate counts the free and user keys generated over the window ending now, split into buckets of
+// the given width. The last bucket is shorter if the window is not a multiple of the bucket width.
+func (s *keyService) GetKeyGenerationRate(ctx context.Context, window, bucket time.Duration) (*dto.KeyGenerationRate, error) {
+	slog.InfoContext(ctx, "GetKeyGenerationRate: computing key generation rate", "window", window.String(), "bucket", bucket.String())
+
+	if window <= 0 || bucket <= 0 {
+		return nil, errors.New("invalid key generation rate request: window and bucket must be positive")
+	}
+	if bucket > window {
+		bucket = window
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	counts, err := s.generationRepo.CountByBucket(ctx, from, to, bucket)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetKeyGenerationRate: failed to count key generations", "error", err)
+		return nil, fmt.Errorf("could not compute key generation rate: %w", err)
+	}
+
+	// Every bucket of the window is reported, including those without generated keys.
+	bucketCount := int((window + bucket - 1) / bucket)
+	rate := &dto.KeyGenerationRate{
+		From:    from,
+		To:      to,
+		Bucket:  bucket,
+		Buckets: make([]dto.KeyGenerationBucket, bucketCount),
+	}
+	for i := range rate.Buckets {
+		rate.Buckets[i].Start = from.Add(time.Duration(i) * bucket)
+	}
+	for _, count := range counts {
+		if count.Bucket < 0 || count.Bucket >= int64(bucketCount) {
+			continue
+		}
+		rate.Buckets[count.Bucket].FreeKeys = count.FreeKeys
+		rate.Buckets[count.Bucket].UserKeys = count.UserKeys
+		rate.TotalFree += count.FreeKeys
+		rate.TotalUser += count.UserKeys
+	}
+
+	slog.InfoContext(ctx, "GetKeyGenerationRate: key generation rate computed", "totalFree", rate.TotalFree, "totalUser", rate.TotalUser)
+	return rate, nil
+}
+
+// GetConversionFunnel reports how many users issued a free-tier key in [from, to) created a subscription afterwards
+// and how many of those paid. Anonymous free keys are not attributed to a user and are not counted.
+func (s *keyService) GetConversionFunnel(ctx context.Context, from, to time.Time) (*dto.ConversionFunnel, error) {
+	slog.InfoContext(ctx, "GetConversionFunnel: computing conversion funnel", "from", from, "to", to)
+
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid conversion funnel period: %s is not before %s", from, to)
+	}
+
+	counts, err := s.generationRepo.CountConversionFunnel(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetConversionFunnel: failed to count conversion funnel", "error", err)
+		return nil, fmt.Errorf("could not compute conversion funnel: %w", err)
+	}
+
+	funnel := &dto.ConversionFunnel{
+		From:         from,
+		To:           to,
+		FreeKeyUsers: counts.FreeKeyUsers,
+		Subscribed:   counts.Subscribed,
+		Paid:         counts.Paid,
+	}
+	if funnel.FreeKeyUsers > 0 {
+		funnel.ConversionRate = float64(funnel.Paid) / float64(funnel.FreeKeyUsers)
+	}
+
+	slog.InfoContext(ctx, "GetConversionFunnel: conversion funnel computed", "freeKeyUsers", funnel.FreeKeyUsers, "subscribed", funnel.Subscribed, "paid", funnel.Paid)
+	return funnel, nil
+}
+
 // GenerateFreeVlessKey generates a VLESS key for a free-tier user.
-func (s *keyService) GenerateFreeVlessKey(ctx context.Context, remarks string, country *string) (string, error) {
-	slog.InfoContext(ctx, "GenerateFreeVlessKey: attempting to generate free key", "country", country)
+func (s *keyService) GenerateFreeVlessKey(ctx context.Context, remarks string, prefs dto.HostPreferences) (*dto.FreeKeyResult, error) {
+	slog.InfoContext(ctx, "GenerateFreeVlessKey: attempting to generate free key", "country", prefs.Country, "network", prefs.Network, "securityType", prefs.SecurityType)
+
+	host, vlessConfig, err := s.acquireFreeHostWithConfig(ctx, remarks, prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordKeyGenerated(ctx, host, nil)
+	slog.InfoContext(ctx, "GenerateFreeVlessKey: VLESS key generated successfully", "hostID", host.ID)
+	return &dto.FreeKeyResult{
+		VlessKey:  vlessURLFromConfig(vlessConfig),
+		HostID:    host.ID,
+		Host:      keyHostOf(host),
+		ExpiresAt: s.freeKeyExpiry(),
+	}, nil
+}
+
+// freeKeyExpiry returns when a free key issued now expires per the configured free key TTL, or nil if none is set.
+func (s *keyService) freeKeyExpiry() *time.Time {
+	if s.cfg.FreeKeyTTL <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(s.cfg.FreeKeyTTL)
+	return &expiresAt
+}
 
-	isFreeHost := true
-	host, err := s.hostRepo.GetRandomActiveHost(ctx, country, &isFreeHost)
+// GenerateFreeVlessKeys generates count free-tier VLESS keys for seeding and load testing.
+// Every key is issued on the least issued free host at that moment, so the keys spread evenly across free hosts.
+// The count must be between 1 and the configured maximum batch size.
+func (s *keyService) GenerateFreeVlessKeys(ctx context.Context, count int, remarks string, country *string) ([]dto.FreeKeyResult, error) {
+	slog.InfoContext(ctx, "GenerateFreeVlessKeys: attempting to generate free keys", "count", count, "country", country)
+
+	if count < 1 {
+		return nil, errors.New("invalid count: at least one key must be requested")
+	}
+	if count > s.cfg.MaxFreeKeyBatchSize {
+		return nil, fmt.Errorf("invalid count: at most %d keys can be generated per batch", s.cfg.MaxFreeKeyBatchSize)
+	}
+
+	prefs := dto.HostPreferences{}
+	if country != nil {
+		prefs.Country = *country
+	}
+	keys := make([]dto.FreeKeyResult, 0, count)
+	for i := 0; i < count; i++ {
+		host, vlessConfig, err := s.acquireFreeHostWithConfig(ctx, remarks, prefs)
+		if err != nil {
+			slog.ErrorContext(ctx, "GenerateFreeVlessKeys: failed to generate key", "index", i, "generated", len(keys), "error", err)
+			return nil, err
+		}
+		s.recordKeyGenerated(ctx, host, nil)
+		keys = append(keys, dto.FreeKeyResult{
+			VlessKey:  vlessURLFromConfig(vlessConfig),
+			HostID:    host.ID,
+			Host:      keyHostOf(host),
+			ExpiresAt: s.freeKeyExpiry(),
+		})
+	}
+
+	slog.InfoContext(ctx, "GenerateFreeVlessKeys: free keys generated successfully", "count", len(keys))
+	return keys, nil
+}
+
+// acquireFreeHostWithConfig acquires a free-tier host for a free key and builds the key's VLESS config,
+// relaxing the preferences through the steps of hostSelectionLadder until a host is found.
+func (s *keyService) acquireFreeHostWithConfig(ctx context.Context, remarks string, prefs dto.HostPreferences) (*models.Host, *dto.VlessConfig, error) {
+	host, vlessConfig, err := s.acquireHostWithLadder(ctx, FreeTierUserUUID.String(), remarks, prefs, true)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			slog.WarnContext(ctx, "GenerateFreeVlessKey: no active free hosts available for the country", "country", country)
-			// Try fallback: if a specific country was requested and no host found, try without country filter for free tier
-			if country != nil && *country != "" {
-				slog.InfoContext(ctx, "GenerateFreeVlessKey: fallback - trying without country filter for free tier")
-				host, err = s.hostRepo.GetRandomActiveHost(ctx, nil, &isFreeHost)
+			slog.WarnContext(ctx, "acquireFreeHostWithConfig: no active free hosts available even after fallback")
+			return nil, nil, errors.New("no active free hosts available to generate key")
+		}
+		slog.ErrorContext(ctx, "acquireFreeHostWithConfig: failed to get active free host", "error", err)
+		return nil, nil, fmt.Errorf("could not retrieve an active free host: %w", err)
+	}
+	slog.DebugContext(ctx, "acquireFreeHostWithConfig: selected host", "hostID", host.ID, "hostAddress", host.Address)
+	return host, vlessConfig, nil
+}
+
+// GetCurrentVlessKeyForUser reconstructs the VLESS key for the user's most recent active key assignment.
+// Unlike GenerateVlessKeyForUser, it never selects a new host or records a new assignment.
+func (s *keyService) GetCurrentVlessKeyForUser(ctx context.Context, userID uuid.UUID) (*dto.CurrentUserKeyResult, error) {
+	slog.InfoContext(ctx, "GetCurrentVlessKeyForUser: attempting to retrieve current key", "userID", userID)
+
+	assignment, err := s.assignmentRepo.GetLatestActiveByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetCurrentVlessKeyForUser: no active key assignment found", "userID", userID)
+			return nil, fmt.Errorf("active key assignment for user %s not found", userID)
+		}
+		slog.ErrorContext(ctx, "GetCurrentVlessKeyForUser: failed to get key assignment", "userID", userID, "error", err)
+		return nil, fmt.Errorf("could not retrieve key assignment: %w", err)
+	}
+
+	vlessURL, err := s.constructVlessURL(assignment.UserID.String(), &assignment.Host, assignment.Remarks)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetCurrentVlessKeyForUser: failed to construct VLESS URL", "userID", userID, "hostID", assignment.HostID, "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "GetCurrentVlessKeyForUser: current VLESS key reconstructed successfully", "userID", userID, "hostID", assignment.HostID, "assignmentID", assignment.ID)
+	return &dto.CurrentUserKeyResult{
+		VlessKey:   vlessURL,
+		Remarks:    assignment.Remarks,
+		HostID:     assignment.HostID,
+		AssignedAt: assignment.CreatedAt,
+	}, nil
+}
+
+// ReassignUserHost moves a user off the host of their current key. The new host is either the requested target,
+// which must be online and match the user's tier, or the least issued host of the user's tier other than the
+// current one. Revoking the old assignments and recording the new one happen in a single transaction.
+func (s *keyService) ReassignUserHost(ctx context.Context, userID uuid.UUID, input dto.ReassignHostInput) (*dto.ReassignHostResult, error) {
+	slog.InfoContext(ctx, "ReassignUserHost: attempting to reassign user", "userID", userID, "targetHostID", input.TargetHostID)
+
+	current, err := s.assignmentRepo.GetLatestActiveByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "ReassignUserHost: no active key assignment found", "userID", userID)
+			return nil, fmt.Errorf("active key assignment for user %s not found", userID)
+		}
+		slog.ErrorContext(ctx, "ReassignUserHost: failed to get key assignment", "userID", userID, "error", err)
+		return nil, fmt.Errorf("could not retrieve key assignment: %w", err)
+	}
+
+	hasActiveSubscription, err := s.subscriptionRepo.CheckUserActiveSubscription(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReassignUserHost: failed to check user subscription status", "userID", userID, "error", err)
+		return nil, fmt.Errorf("could not check subscription status: %w", err)
+	}
+	wantFreeTier := !hasActiveSubscription
+
+	remarks := current.Remarks
+	if input.Remarks != nil {
+		remarks = *input.Remarks
+	}
+
+	var host *models.Host
+	var vlessConfig *dto.VlessConfig
+	if input.TargetHostID != nil {
+		host, err = s.hostRepo.GetByID(ctx, *input.TargetHostID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.WarnContext(ctx, "ReassignUserHost: target host not found", "hostID", *input.TargetHostID)
+				return nil, fmt.Errorf("target host with ID %d not found", *input.TargetHostID)
 			}
+			slog.ErrorContext(ctx, "ReassignUserHost: failed to get target host", "hostID", *input.TargetHostID, "error", err)
+			return nil, fmt.Errorf("could not retrieve target host: %w", err)
+		}
+		if host.ID == current.HostID {
+			return nil, fmt.Errorf("invalid target host: user is already assigned to host %d", host.ID)
+		}
+		if !host.IsOnline {
+			return nil, fmt.Errorf("invalid target host: host %d is offline", host.ID)
+		}
+		if host.IsFreeTier != wantFreeTier {
+			return nil, fmt.Errorf("invalid target host: host %d does not match the user's tier (free tier: %t)", host.ID, wantFreeTier)
+		}
+		if vlessConfig, err = buildVlessConfig(userID.String(), host, remarks); err != nil {
+			slog.WarnContext(ctx, "ReassignUserHost: target host cannot produce a key", "userID", userID, "hostID", host.ID, "error", err)
+			return nil, fmt.Errorf("invalid target host: %w", err)
+		}
+	} else {
+		country := input.Country
+		if country == nil && current.Host.Country != "" {
+			country = &current.Host.Country
+		}
+		excluded := []uint{current.HostID}
+		filter := customTypes.HostSelectionFilter{Country: country, IsFreeTier: &wantFreeTier}
+		host, vlessConfig, err = s.acquireHostWithConfig(ctx, userID.String(), remarks, filter, excluded)
+		if errors.Is(err, gorm.ErrRecordNotFound) && country != nil {
+			slog.InfoContext(ctx, "ReassignUserHost: fallback - trying without country filter for tier", "tier_is_free", wantFreeTier)
+			filter.Country = nil
+			host, vlessConfig, err = s.acquireHostWithConfig(ctx, userID.String(), remarks, filter, excluded)
 		}
-		// If still not found or other error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				slog.WarnContext(ctx, "GenerateFreeVlessKey: no active free hosts available even after fallback")
-				return "", errors.New("no active free hosts available to generate key")
+				slog.WarnContext(ctx, "ReassignUserHost: no other active hosts available", "tier_is_free", wantFreeTier, "currentHostID", current.HostID)
+				return nil, errors.New("no active hosts available to reassign the user to")
 			}
-			slog.ErrorContext(ctx, "GenerateFreeVlessKey: failed to get active free host", "error", err)
-			return "", fmt.Errorf("could not retrieve an active free host: %w", err)
+			slog.ErrorContext(ctx, "ReassignUserHost: failed to acquire host", "error", err)
+			return nil, fmt.Errorf("could not retrieve an active host: %w", err)
 		}
 	}
-	slog.DebugContext(ctx, "GenerateFreeVlessKey: selected host", "hostID", host.ID, "hostAddress", host.Address)
 
-	vlessURL, err := s.constructVlessURL(FreeTierUserUUID.String(), host, remarks)
+	assignment := &models.KeyAssignment{
+		UserID:   userID,
+		HostID:   host.ID,
+		Remarks:  remarks,
+		IsActive: true,
+	}
+	revoked, err := s.assignmentRepo.Reassign(ctx, assignment)
 	if err != nil {
-		slog.ErrorContext(ctx, "GenerateFreeVlessKey: failed to construct VLESS URL", "hostID", host.ID, "error", err)
-		return "", err
+		slog.ErrorContext(ctx, "ReassignUserHost: failed to reassign key", "userID", userID, "hostID", host.ID, "error", err)
+		return nil, fmt.Errorf("could not reassign user to host %d: %w", host.ID, err)
 	}
 
-	slog.InfoContext(ctx, "GenerateFreeVlessKey: VLESS key generated successfully", "hostID", host.ID)
-	return vlessURL, nil
+	s.recordKeyGenerated(ctx, host, &userID)
+	slog.InfoContext(ctx, "ReassignUserHost: user reassigned successfully", "userID", userID, "previousHostID", current.HostID, "hostID", host.ID, "revokedCount", len(revoked))
+	return &dto.ReassignHostResult{
+		VlessKey:       vlessURLFromConfig(vlessConfig),
+		Config:         *vlessConfig,
+		HostID:         host.ID,
+		PreviousHostID: current.HostID,
+		RevokedCount:   len(revoked),
+		Remarks:        remarks,
+	}, nil
+}
+
+// acquireHostWithConfig acquires the least issued host matching filter, excluding excludeHostIDs, and builds
+// its VLESS config. A host whose configuration cannot produce a key is skipped and logged, and another host is
+// tried, up to maxHostSelectionAttempts hosts in total.
+// Returns an error wrapping gorm.ErrRecordNotFound if no usable host is found, so callers can relax their filters.
+func (s *keyService) acquireHostWithConfig(ctx context.Context, vlessUserID, remarks string, filter customTypes.HostSelectionFilter, excludeHostIDs []uint) (*models.Host, *dto.VlessConfig, error) {
+	excluded := append([]uint(nil), excludeHostIDs...)
+	for attempt := 1; attempt <= maxHostSelectionAttempts; attempt++ {
+		host, err := s.hostRepo.AcquireLeastIssuedHostExcluding(ctx, filter, excluded)
+		if err != nil {
+			return nil, nil, err
+		}
+		vlessConfig, err := buildVlessConfig(vlessUserID, host, remarks)
+		if err == nil {
+			return host, vlessConfig, nil
+		}
+		skipped := s.invalidHostSkips.Add(1)
+		slog.WarnContext(ctx, "acquireHostWithConfig: skipping misconfigured host", "hostID", host.ID, "attempt", attempt, "invalidHostSkipsTotal", skipped, "error", err)
+		excluded = append(excluded, host.ID)
+	}
+	return nil, nil, fmt.Errorf("no correctly configured host found after %d attempts: %w", maxHostSelectionAttempts, gorm.ErrRecordNotFound)
 }
 
 // constructVlessURL is a helper function to build the VLESS URL string.
 func (s *keyService) constructVlessURL(vlessUserID string, host *models.Host, remarks string) (string, error) {
-	queryParams := url.Values{}
+	vlessConfig, err := buildVlessConfig(vlessUserID, host, remarks)
+	if err != nil {
+		return "", err
+	}
+	return vlessURLFromConfig(vlessConfig), nil
+}
+
+// expandRemarksTemplate replaces the host metadata placeholders {country}, {city}, {region}, {provider} and
+// {host_name} in remarks with the values of the given host. Remarks without placeholders are returned unchanged;
+// escaping for the URL fragment is left to vlessURLFromConfig.
+func expandRemarksTemplate(remarks string, host *models.Host) string {
+	if !strings.Contains(remarks, "{") {
+		return remarks
+	}
+	return strings.NewReplacer(
+		"{country}", host.Country,
+		"{city}", host.City,
+		"{region}", host.Region,
+		"{provider}", host.Provider,
+		"{host_name}", host.HostName,
+	).Replace(remarks)
+}
 
-	if host.SecurityType != "" && host.SecurityType != "none" {
-		queryParams.Set("security", host.SecurityType)
+// buildVlessConfig collects the VLESS key components for the given user ID and host.
+// Placeholders in remarks are expanded from the host's metadata.
+// Reality parameters are only included for hosts using the Reality security type, which requires a public key.
+func buildVlessConfig(vlessUserID string, host *models.Host, remarks string) (*dto.VlessConfig, error) {
+	vlessConfig := &dto.VlessConfig{
+		Address:     host.Address,
+		Port:        host.Port,
+		UUID:        vlessUserID,
+		Network:     host.Network,
+		Security:    host.SecurityType,
+		SNI:         host.SNI,
+		Fingerprint: host.Fingerprint,
+		Flow:        host.Flow,
+		Remarks:     expandRemarksTemplate(remarks, host),
 	}
-	if host.SNI != "" {
-		queryParams.Set("sni", host.SNI)
+	if vlessConfig.Network == "" {
+		vlessConfig.Network = "tcp" // Default to tcp if not specified
 	}
-	if host.Fingerprint != "" {
-		queryParams.Set("fp", host.Fingerprint)
+	if vlessConfig.Security == "" {
+		vlessConfig.Security = "none"
 	}
 
 	if strings.ToLower(host.SecurityType) == "reality" {
 		if host.PublicKey == "" {
-			return "", fmt.Errorf("selected host (ID: %d) is configured for Reality but missing public key (pbk)", host.ID)
-		}
-		queryParams.Set("pbk", host.PublicKey)
-		if host.RSID != "" {
-			queryParams.Set("sid", host.RSID)
+			return nil, fmt.Errorf("selected host (ID: %d) is configured for Reality but missing public key (pbk)", host.ID)
 		}
+		vlessConfig.PublicKey = host.PublicKey
+		vlessConfig.ShortID = host.RSID
 	}
+	return vlessConfig, nil
+}
 
-	if host.Flow != "" {
-		queryParams.Set("flow", host.Flow)
-	}
+// vlessURLFromConfig encodes VLESS key components as a vless:// URL.
+func vlessURLFromConfig(vlessConfig *dto.VlessConfig) string {
+	queryParams := url.Values{}
 
-	if host.Network != "" {
-		queryParams.Set("type", host.Network)
-	} else {
-		queryParams.Set("type", "tcp") // Default to tcp if not specified
+	if vlessConfig.Security != "none" {
+		queryParams.Set("security", vlessConfig.Security)
 	}
-
-	queryString := queryParams.Encode()
-
-	var vlessURL string
-	if queryString != "" {
-		vlessURL = fmt.Sprintf("vless://%s@%s:%s?%s", vlessUserID, host.Address, host.Port, queryString)
-	} else {
-		vlessURL = fmt.Sprintf("vless://%s@%s:%s", vlessUserID, host.Address, host.Port)
+	if vlessConfig.SNI != "" {
+		queryParams.Set("sni", vlessConfig.SNI)
+	}
+	if vlessConfig.Fingerprint != "" {
+		queryParams.Set("fp", vlessConfig.Fingerprint)
+	}
+	if vlessConfig.PublicKey != "" {
+		queryParams.Set("pbk", vlessConfig.PublicKey)
+	}
+	if vlessConfig.ShortID != "" {
+		queryParams.Set("sid", vlessConfig.ShortID)
+	}
+	if vlessConfig.Flow != "" {
+		queryParams.Set("flow", vlessConfig.Flow)
 	}
+	queryParams.Set("type", vlessConfig.Network)
 
-	if remarks != "" {
-		vlessURL = fmt.Sprintf("%s#%s", vlessURL, url.PathEscape(remarks))
+	// The query always contains at least the transport type.
+	vlessURL := fmt.Sprintf("vless://%s@%s:%s?%s", vlessConfig.UUID, vlessConfig.Address, vlessConfig.Port, queryParams.Encode())
+	if vlessConfig.Remarks != "" {
+		vlessURL = fmt.Sprintf("%s#%s", vlessURL, url.PathEscape(vlessConfig.Remarks))
 	}
-	return vlessURL, nil
+	return vlessURL
 }
@@ -0,0 +1,1170 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"context"
+	"maps"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// keyServiceDeps holds the fakes a keyService under test is built from.
+type keyServiceDeps struct {
+	users       *fakeUserRepo
+	hosts       *fakeHostRepo
+	subs        *fakeSubRepo
+	assignments *fakeAssignmentRepo
+	generations *fakeGenerationRepo
+	cfg         *config.Config
+}
+
+// newTestKeyService builds a keyService on fresh fakes with a single existing user and no hosts.
+func newTestKeyService(t *testing.T, cfg *config.Config) (*keyService, *keyServiceDeps, uuid.UUID) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	userID := uuid.New()
+	deps := &keyServiceDeps{
+		users:       newFakeUserRepo(models.User{ID: userID, Name: "Test User", IsActive: true}),
+		hosts:       newFakeHostRepo(),
+		subs:        newFakeSubRepo(),
+		assignments: &fakeAssignmentRepo{latest: map[uuid.UUID]*models.KeyAssignment{}},
+		generations: &fakeGenerationRepo{},
+		cfg:         cfg,
+	}
+	deps.generations.subs = deps.subs
+	svc := NewKeyService(deps.users, deps.hosts, deps.subs, deps.assignments, deps.generations, nil, NewHostSelector(nil), cfg).(*keyService)
+	return svc, deps, userID
+}
+
+func TestGetCurrentVlessKeyForUser(t *testing.T) {
+	assignedAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		hasAssignment bool
+		wantErr       bool
+	}{
+		{name: "existing assignment", hasAssignment: true},
+		{name: "missing assignment", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			if tt.hasAssignment {
+				deps.assignments.latest[userID] = &models.KeyAssignment{
+					ID:     uuid.New(),
+					UserID: userID,
+					HostID: 3,
+					Host: models.Host{
+						ID: 3, Address: "de1.example.com", Port: "443", Protocol: "vless",
+						Network: "tcp", SecurityType: "tls", SNI: "de1.example.com",
+					},
+					Remarks:   "My key",
+					CreatedAt: assignedAt,
+				}
+			}
+
+			result, err := svc.GetCurrentVlessKeyForUser(context.Background(), userID)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not found") {
+					t.Fatalf("GetCurrentVlessKeyForUser() error = %v, want a not found error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCurrentVlessKeyForUser() error = %v", err)
+			}
+			wantPrefix := "vless://" + userID.String() + "@de1.example.com:443?"
+			if !strings.HasPrefix(result.VlessKey, wantPrefix) {
+				t.Errorf("VlessKey = %q, want prefix %q", result.VlessKey, wantPrefix)
+			}
+			if !strings.HasSuffix(result.VlessKey, "#My%20key") {
+				t.Errorf("VlessKey = %q, want the assignment's remarks as fragment", result.VlessKey)
+			}
+			if result.HostID != 3 || !result.AssignedAt.Equal(assignedAt) || result.Remarks != "My key" {
+				t.Errorf("result = %+v, want host 3 assigned at %v with remarks %q", result, assignedAt, "My key")
+			}
+		})
+	}
+}
+
+// testHost returns an online, active VLESS host over TLS with the given ID, country and tier.
+func testHost(id uint, country string, isFreeTier bool) models.Host {
+	return models.Host{
+		ID: id, Country: country, IsFreeTier: isFreeTier, IsOnline: true, Status: customTypes.StatusActive,
+		Address: "host.example.com", Port: "443", Protocol: "vless", Network: "tcp", SecurityType: "tls",
+	}
+}
+
+// TestFreeKeyHostDistribution issues keys concurrently and checks that they spread evenly over the matching hosts.
+func TestFreeKeyHostDistribution(t *testing.T) {
+	tests := []struct {
+		name       string
+		hosts      []models.Host
+		keys       int
+		wantCounts map[uint]int64
+	}{
+		{
+			name:       "equal hosts share the keys",
+			hosts:      []models.Host{testHost(1, "DE", true), testHost(2, "DE", true), testHost(3, "NL", true)},
+			keys:       300,
+			wantCounts: map[uint]int64{1: 100, 2: 100, 3: 100},
+		},
+		{
+			name: "busy host is skipped until the others catch up",
+			hosts: func() []models.Host {
+				busy := testHost(1, "DE", true)
+				busy.IssuedCount = 50
+				return []models.Host{busy, testHost(2, "DE", true)}
+			}(),
+			keys:       150,
+			wantCounts: map[uint]int64{1: 100, 2: 100},
+		},
+		{
+			name:       "paid and offline hosts are never picked",
+			hosts:      []models.Host{testHost(1, "DE", true), testHost(2, "DE", false), func() models.Host { h := testHost(3, "DE", true); h.IsOnline = false; return h }()},
+			keys:       40,
+			wantCounts: map[uint]int64{1: 40, 2: 0, 3: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+
+			var wg sync.WaitGroup
+			for range tt.keys {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := svc.GenerateFreeVlessKey(context.Background(), "", dto.HostPreferences{}); err != nil {
+						t.Errorf("GenerateFreeVlessKey() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			got := deps.hosts.issuedCounts()
+			for id, want := range tt.wantCounts {
+				if got[id] != want {
+					t.Errorf("host %d issued %d keys, want %d (all counts %v)", id, got[id], want, got)
+				}
+			}
+			if len(deps.generations.generations) != tt.keys {
+				t.Errorf("recorded %d key generations, want %d", len(deps.generations.generations), tt.keys)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateFreeVlessKey(b *testing.B) {
+	hosts := newFakeHostRepo(testHost(1, "DE", true), testHost(2, "DE", true), testHost(3, "NL", true), testHost(4, "NL", true))
+	svc := NewKeyService(nil, hosts, nil, nil, &fakeGenerationRepo{}, nil, NewHostSelector(nil), &config.Config{}).(*keyService)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := svc.GenerateFreeVlessKey(context.Background(), "", dto.HostPreferences{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	counts := hosts.issuedCounts()
+	lowest, highest := counts[1], counts[1]
+	for _, count := range counts {
+		lowest, highest = min(lowest, count), max(highest, count)
+	}
+	if highest-lowest > 1 {
+		b.Errorf("issued counts differ by %d across hosts, want at most 1: %v", highest-lowest, counts)
+	}
+}
+
+func TestBuildVlessConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    models.Host
+		want    dto.VlessConfig
+		wantErr bool
+	}{
+		{
+			name: "plain vless defaults",
+			host: models.Host{Address: "1.2.3.4", Port: "80", Protocol: "vless"},
+			want: dto.VlessConfig{Address: "1.2.3.4", Port: "80", UUID: "user", Network: "tcp", Security: "none"},
+		},
+		{
+			name: "vless over tls",
+			host: models.Host{Address: "de1.example.com", Port: "443", Protocol: "vless", Network: "ws", SecurityType: "tls", SNI: "cdn.example.com", Fingerprint: "chrome"},
+			want: dto.VlessConfig{Address: "de1.example.com", Port: "443", UUID: "user", Network: "ws", Security: "tls", SNI: "cdn.example.com", Fingerprint: "chrome"},
+		},
+		{
+			name: "reality",
+			host: models.Host{Address: "nl1.example.com", Port: "443", Protocol: "vless", SecurityType: "reality", SNI: "www.microsoft.com",
+				Fingerprint: "firefox", PublicKey: "pbk123", RSID: "ab12", Flow: "xtls-rprx-vision"},
+			want: dto.VlessConfig{Address: "nl1.example.com", Port: "443", UUID: "user", Network: "tcp", Security: "reality", SNI: "www.microsoft.com",
+				Fingerprint: "firefox", PublicKey: "pbk123", ShortID: "ab12", Flow: "xtls-rprx-vision"},
+		},
+		{
+			name:    "reality without public key",
+			host:    models.Host{Address: "nl1.example.com", Port: "443", Protocol: "vless", SecurityType: "Reality"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildVlessConfig("user", &tt.host, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildVlessConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("buildVlessConfig() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVlessRemarksTemplate(t *testing.T) {
+	host := models.Host{Address: "de1.example.com", Port: "443", Protocol: "vless", Country: "DE", City: "Frankfurt am Main",
+		Region: "eu-central", Provider: "Hetzner", HostName: "fra-1"}
+
+	tests := []struct {
+		name         string
+		remarks      string
+		host         models.Host
+		wantRemarks  string
+		wantFragment string // The raw, escaped fragment of the key URL.
+	}{
+		{name: "literal remarks", remarks: "BittenVPN", host: host, wantRemarks: "BittenVPN", wantFragment: "BittenVPN"},
+		{name: "country and city", remarks: "BittenVPN | {country} | {city}", host: host,
+			wantRemarks: "BittenVPN | DE | Frankfurt am Main", wantFragment: "BittenVPN%20%7C%20DE%20%7C%20Frankfurt%20am%20Main"},
+		{name: "every placeholder", remarks: "{host_name}/{provider}/{region}/{country}/{city}", host: host,
+			wantRemarks: "fra-1/Hetzner/eu-central/DE/Frankfurt am Main", wantFragment: "fra-1%2FHetzner%2Feu-central%2FDE%2FFrankfurt%20am%20Main"},
+		{name: "repeated placeholder", remarks: "{country}-{country}", host: host, wantRemarks: "DE-DE", wantFragment: "DE-DE"},
+		{name: "unknown placeholder is kept", remarks: "{datacenter} {country}", host: host, wantRemarks: "{datacenter} DE", wantFragment: "%7Bdatacenter%7D%20DE"},
+		{name: "missing metadata expands to nothing", remarks: "BittenVPN {region}", host: models.Host{Address: "1.2.3.4", Port: "80"},
+			wantRemarks: "BittenVPN ", wantFragment: "BittenVPN%20"},
+		{name: "special characters in host metadata", remarks: "{city} {provider}", host: models.Host{Address: "1.2.3.4", Port: "80", City: "São Paulo", Provider: "A&B #1 100%?"},
+			wantRemarks: "São Paulo A&B #1 100%?", wantFragment: "S%C3%A3o%20Paulo%20A&B%20%231%20100%25%3F"},
+		{name: "no remarks", host: host},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandRemarksTemplate(tt.remarks, &tt.host); got != tt.wantRemarks {
+				t.Errorf("expandRemarksTemplate() = %q, want %q", got, tt.wantRemarks)
+			}
+
+			key, err := (&keyService{}).constructVlessURL("user", &tt.host, tt.remarks)
+			if err != nil {
+				t.Fatalf("constructVlessURL() error = %v", err)
+			}
+			parsed, err := url.Parse(key)
+			if err != nil {
+				t.Fatalf("key %q does not parse as a URL: %v", key, err)
+			}
+			if parsed.EscapedFragment() != tt.wantFragment || parsed.Fragment != tt.wantRemarks {
+				t.Errorf("key fragment = %q (decoded %q), want %q (decoded %q)", parsed.EscapedFragment(), parsed.Fragment, tt.wantFragment, tt.wantRemarks)
+			}
+			if strings.Count(key, "#") > 1 {
+				t.Errorf("key %q has an unescaped '#' in its fragment", key)
+			}
+		})
+	}
+}
+
+func TestGenerateVlessKeyForUserConfig(t *testing.T) {
+	reality := testHost(2, "NL", true)
+	reality.SecurityType, reality.PublicKey, reality.RSID, reality.Flow, reality.SNI = "reality", "pbk123", "ab12", "xtls-rprx-vision", "www.microsoft.com"
+
+	tests := []struct {
+		name        string
+		host        models.Host
+		wantURLPart []string
+	}{
+		{name: "vless host", host: testHost(1, "DE", true), wantURLPart: []string{"@host.example.com:443?", "security=tls", "type=tcp"}},
+		{name: "reality host", host: reality, wantURLPart: []string{"security=reality", "pbk=pbk123", "sid=ab12", "flow=xtls-rprx-vision", "sni=www.microsoft.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.host)
+			svc.hostRepo = deps.hosts
+
+			result, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", dto.HostPreferences{}, false)
+			if err != nil {
+				t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+			}
+			want, _ := buildVlessConfig(userID.String(), &tt.host, "")
+			if result.Config != *want {
+				t.Errorf("config = %+v, want %+v", result.Config, *want)
+			}
+			for _, part := range tt.wantURLPart {
+				if !strings.Contains(result.VlessKey, part) {
+					t.Errorf("key %q does not contain %q", result.VlessKey, part)
+				}
+			}
+			if assignment := deps.assignments.latest[userID]; assignment == nil || assignment.HostID != tt.host.ID {
+				t.Errorf("assignment = %+v, want one on host %d", assignment, tt.host.ID)
+			}
+		})
+	}
+}
+
+func TestReassignUserHost(t *testing.T) {
+	offline := testHost(4, "DE", true)
+	offline.IsOnline = false
+	hosts := func() []models.Host {
+		busy := testHost(2, "DE", true)
+		busy.IssuedCount = 10
+		return []models.Host{testHost(1, "DE", true), busy, testHost(3, "DE", true), offline, testHost(5, "NL", true), testHost(6, "DE", false)}
+	}
+
+	tests := []struct {
+		name          string
+		hosts         []models.Host
+		input         dto.ReassignHostInput
+		noAssignment  bool
+		wantHostID    uint
+		wantErrSubstr string
+	}{
+		{name: "targeted", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](5)}, wantHostID: 5},
+		{name: "targeted with remarks", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](2), Remarks: ptr("moved")}, wantHostID: 2},
+		{name: "auto-select least issued host in the current country", hosts: hosts(), wantHostID: 3},
+		{name: "auto-select in the requested country", hosts: hosts(), input: dto.ReassignHostInput{Country: ptr("NL")}, wantHostID: 5},
+		{name: "auto-select falls back to other countries", hosts: []models.Host{testHost(1, "DE", true), testHost(5, "NL", true)}, wantHostID: 5},
+		{name: "auto-select without other hosts", hosts: []models.Host{testHost(1, "DE", true)}, wantErrSubstr: "no active hosts available"},
+		{name: "target is the current host", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](1)}, wantErrSubstr: "already assigned"},
+		{name: "target offline", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](4)}, wantErrSubstr: "offline"},
+		{name: "target of another tier", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](6)}, wantErrSubstr: "tier"},
+		{name: "target not found", hosts: hosts(), input: dto.ReassignHostInput{TargetHostID: ptr[uint](99)}, wantErrSubstr: "not found"},
+		{name: "no current assignment", hosts: hosts(), noAssignment: true, wantErrSubstr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+			if !tt.noAssignment {
+				deps.assignments.latest[userID] = &models.KeyAssignment{ID: uuid.New(), UserID: userID, HostID: 1, Host: tt.hosts[0], Remarks: "home", IsActive: true}
+			}
+
+			result, err := svc.ReassignUserHost(context.Background(), userID, tt.input)
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Fatalf("ReassignUserHost() error = %v, want it to mention %q", err, tt.wantErrSubstr)
+				}
+				if !tt.noAssignment && deps.assignments.latest[userID].HostID != 1 {
+					t.Errorf("assignment moved to host %d despite the error", deps.assignments.latest[userID].HostID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReassignUserHost() error = %v", err)
+			}
+
+			wantRemarks := "home"
+			if tt.input.Remarks != nil {
+				wantRemarks = *tt.input.Remarks
+			}
+			if result.HostID != tt.wantHostID || result.PreviousHostID != 1 || result.RevokedCount != 1 || result.Remarks != wantRemarks {
+				t.Errorf("result = host %d, previous %d, revoked %d, remarks %q; want %d, 1, 1, %q",
+					result.HostID, result.PreviousHostID, result.RevokedCount, result.Remarks, tt.wantHostID, wantRemarks)
+			}
+			if !strings.HasPrefix(result.VlessKey, "vless://"+userID.String()+"@") || result.Config.UUID != userID.String() {
+				t.Errorf("key %q is not a VLESS key for user %s", result.VlessKey, userID)
+			}
+			if assignment := deps.assignments.latest[userID]; assignment.HostID != tt.wantHostID || !assignment.IsActive || assignment.Remarks != wantRemarks {
+				t.Errorf("latest assignment = host %d, active %v, remarks %q; want host %d", assignment.HostID, assignment.IsActive, assignment.Remarks, tt.wantHostID)
+			}
+		})
+	}
+}
+
+func TestGenerateVlessKeySkipsMisconfiguredHosts(t *testing.T) {
+	// misconfigured returns a Reality host without a public key, which the repository query normally excludes,
+	// issued count times so far.
+	misconfigured := func(id uint, count int64) models.Host {
+		host := testHost(id, "DE", true)
+		host.SecurityType, host.IssuedCount = "reality", count
+		return host
+	}
+	valid := func(id uint, count int64) models.Host {
+		host := testHost(id, "DE", true)
+		host.IssuedCount = count
+		return host
+	}
+
+	tests := []struct {
+		name       string
+		hosts      []models.Host
+		wantHostID uint
+		wantSkips  int64
+	}{
+		{name: "only reality host misconfigured, tls host valid", hosts: []models.Host{misconfigured(1, 0), valid(2, 5)}, wantHostID: 2, wantSkips: 1},
+		{name: "valid host preferred without skips", hosts: []models.Host{misconfigured(1, 5), valid(2, 0)}, wantHostID: 2},
+		{name: "only misconfigured hosts", hosts: []models.Host{misconfigured(1, 0)}, wantSkips: 1},
+		{name: "attempts are bounded", hosts: []models.Host{misconfigured(1, 0), misconfigured(2, 0), misconfigured(3, 0), misconfigured(4, 0), valid(5, 9)},
+			wantSkips: maxHostSelectionAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+
+			result, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", dto.HostPreferences{}, false)
+			if tt.wantHostID == 0 {
+				if err == nil {
+					t.Fatalf("GenerateVlessKeyForUser() issued key %q, want an error", result.VlessKey)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+				}
+				if assignment := deps.assignments.latest[userID]; assignment.HostID != tt.wantHostID || !strings.Contains(result.VlessKey, "security=tls") {
+					t.Errorf("key %q issued on host %d, want host %d", result.VlessKey, assignment.HostID, tt.wantHostID)
+				}
+			}
+			if got := svc.invalidHostSkips.Load(); got != tt.wantSkips {
+				t.Errorf("invalid host skips = %d, want %d", got, tt.wantSkips)
+			}
+		})
+	}
+}
+
+func TestGenerateFreeVlessKeys(t *testing.T) {
+	paid := testHost(4, "DE", false)
+	hosts := []models.Host{testHost(1, "DE", true), testHost(2, "DE", true), testHost(3, "NL", true), paid}
+
+	tests := []struct {
+		name          string
+		count         int
+		hosts         []models.Host
+		wantPerHost   map[uint]int
+		wantErrSubstr string
+	}{
+		{name: "spread across free hosts", count: 30, hosts: hosts, wantPerHost: map[uint]int{1: 10, 2: 10, 3: 10}},
+		{name: "uneven count", count: 4, hosts: hosts, wantPerHost: map[uint]int{1: 2, 2: 1, 3: 1}},
+		{name: "at the cap", count: 50, hosts: hosts[:1], wantPerHost: map[uint]int{1: 50}},
+		{name: "above the cap", count: 51, hosts: hosts, wantErrSubstr: "at most 50 keys"},
+		{name: "zero", count: 0, hosts: hosts, wantErrSubstr: "at least one key"},
+		{name: "no free hosts", count: 1, hosts: []models.Host{paid}, wantErrSubstr: "no active free hosts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestKeyService(t, &config.Config{MaxFreeKeyBatchSize: 50})
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+
+			keys, err := svc.GenerateFreeVlessKeys(context.Background(), tt.count, "seed", nil)
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Fatalf("GenerateFreeVlessKeys() error = %v, want it to mention %q", err, tt.wantErrSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateFreeVlessKeys() error = %v", err)
+			}
+			if len(keys) != tt.count {
+				t.Fatalf("got %d keys, want %d", len(keys), tt.count)
+			}
+			perHost := make(map[uint]int)
+			for _, key := range keys {
+				perHost[key.HostID]++
+				if !strings.HasPrefix(key.VlessKey, "vless://"+FreeTierUserUUID.String()+"@") {
+					t.Errorf("key %q is not a free-tier key", key.VlessKey)
+				}
+			}
+			if !maps.Equal(perHost, tt.wantPerHost) {
+				t.Errorf("keys per host = %v, want %v", perHost, tt.wantPerHost)
+			}
+		})
+	}
+}
+
+// fakeKeyMetrics is an interfaces.KeyMetrics counting generated keys by tier.
+type fakeKeyMetrics struct {
+	mu         sync.Mutex
+	free, paid int
+}
+
+func (m *fakeKeyMetrics) KeyGenerated(freeTier bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if freeTier {
+		m.free++
+	} else {
+		m.paid++
+	}
+}
+
+func TestKeyGeneratedMetrics(t *testing.T) {
+	tests := []struct {
+		name               string
+		host               models.Host
+		withMetrics        bool
+		wantFree, wantPaid int
+	}{
+		{name: "free host", host: testHost(1, "DE", true), withMetrics: true, wantFree: 1},
+		{name: "paid host", host: testHost(2, "DE", false), withMetrics: true, wantPaid: 1},
+		{name: "metrics disabled", host: testHost(1, "DE", true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			m := &fakeKeyMetrics{}
+			if tt.withMetrics {
+				svc.metrics = m
+			}
+
+			svc.recordKeyGenerated(context.Background(), &tt.host, &userID)
+			if m.free != tt.wantFree || m.paid != tt.wantPaid {
+				t.Errorf("counted %d free and %d paid keys, want %d and %d", m.free, m.paid, tt.wantFree, tt.wantPaid)
+			}
+			// The generation is logged whether or not metrics are enabled.
+			if len(deps.generations.generations) != 1 {
+				t.Errorf("recorded %d key generations, want 1", len(deps.generations.generations))
+			}
+		})
+	}
+}
+
+func TestGenerateFreeVlessKeyCountsFreeKeys(t *testing.T) {
+	svc, deps, _ := newTestKeyService(t, nil)
+	deps.hosts = newFakeHostRepo(testHost(1, "DE", true))
+	svc.hostRepo = deps.hosts
+	m := &fakeKeyMetrics{}
+	svc.metrics = m
+
+	for range 3 {
+		if _, err := svc.GenerateFreeVlessKey(context.Background(), "", dto.HostPreferences{}); err != nil {
+			t.Fatalf("GenerateFreeVlessKey() error = %v", err)
+		}
+	}
+	if m.free != 3 || m.paid != 0 {
+		t.Errorf("counted %d free and %d paid keys, want 3 and 0", m.free, m.paid)
+	}
+}
+
+func TestGenerateVlessKeyForUserTierFallback(t *testing.T) {
+	tests := []struct {
+		name              string
+		subscribed        bool
+		hosts             []models.Host
+		country           string
+		allowFreeFallback bool
+		wantHostID        uint // Zero if no key can be generated.
+		wantTier          string
+		wantDegraded      bool
+	}{
+		{
+			name:       "free user gets a free host",
+			hosts:      []models.Host{testHost(1, "DE", false), testHost(2, "DE", true)},
+			country:    "DE",
+			wantHostID: 2, wantTier: dto.ServedTierFree,
+		},
+		{
+			name:       "paid host in the requested country",
+			subscribed: true,
+			hosts:      []models.Host{testHost(1, "NL", false), testHost(2, "DE", false), testHost(3, "DE", true)},
+			country:    "DE", allowFreeFallback: true,
+			wantHostID: 2, wantTier: dto.ServedTierPaid,
+		},
+		{
+			name:       "paid host in another country before a free host",
+			subscribed: true,
+			hosts:      []models.Host{testHost(1, "NL", false), testHost(2, "DE", true)},
+			country:    "DE", allowFreeFallback: true,
+			wantHostID: 1, wantTier: dto.ServedTierPaid,
+		},
+		{
+			name:       "no paid host and fallback disabled",
+			subscribed: true,
+			hosts:      []models.Host{testHost(1, "DE", true)},
+			country:    "DE",
+		},
+		{
+			name:       "free host in the requested country when fallback is enabled",
+			subscribed: true,
+			hosts:      []models.Host{testHost(1, "NL", true), testHost(2, "DE", true)},
+			country:    "DE", allowFreeFallback: true,
+			wantHostID: 2, wantTier: dto.ServedTierFree, wantDegraded: true,
+		},
+		{
+			name:       "free host in another country when fallback is enabled",
+			subscribed: true,
+			hosts:      []models.Host{testHost(1, "NL", true)},
+			country:    "DE", allowFreeFallback: true,
+			wantHostID: 1, wantTier: dto.ServedTierFree, wantDegraded: true,
+		},
+		{
+			name:              "free users never fall back to paid hosts",
+			hosts:             []models.Host{testHost(1, "DE", false)},
+			allowFreeFallback: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+			if tt.subscribed {
+				deps.subs.subs[uuid.New()] = &models.Subscription{UserID: userID, IsActive: true, EndDate: time.Now().AddDate(0, 1, 0)}
+			}
+
+			result, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", dto.HostPreferences{Country: tt.country}, tt.allowFreeFallback)
+			if tt.wantHostID == 0 {
+				if err == nil {
+					t.Fatalf("GenerateVlessKeyForUser() = %+v, want an error", result)
+				}
+				if assignment := deps.assignments.latest[userID]; assignment != nil {
+					t.Errorf("assignment = %+v, want none", assignment)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+			}
+			if result.ServedTier != tt.wantTier || result.Degraded != tt.wantDegraded || result.HasActiveSubscription != tt.subscribed {
+				t.Errorf("result tier = %q, degraded = %t, subscribed = %t; want %q, %t, %t",
+					result.ServedTier, result.Degraded, result.HasActiveSubscription, tt.wantTier, tt.wantDegraded, tt.subscribed)
+			}
+			if assignment := deps.assignments.latest[userID]; assignment == nil || assignment.HostID != tt.wantHostID {
+				t.Errorf("assignment = %+v, want one on host %d", assignment, tt.wantHostID)
+			}
+		})
+	}
+}
+
+func TestGenerateVlessKeyForUserDeduplication(t *testing.T) {
+	tests := []struct {
+		name       string
+		window     time.Duration
+		second     func(*dto.HostPreferences, *string, *bool) // Edits the second request; nil repeats the first.
+		wantReused bool
+	}{
+		{name: "duplicate within the window", window: time.Minute, wantReused: true},
+		{name: "deduplication disabled", window: 0},
+		{name: "different remarks", window: time.Minute, second: func(_ *dto.HostPreferences, remarks *string, _ *bool) { *remarks = "Laptop" }},
+		{name: "different country", window: time.Minute, second: func(prefs *dto.HostPreferences, _ *string, _ *bool) { prefs.Country = "NL" }},
+		{name: "different network", window: time.Minute, second: func(prefs *dto.HostPreferences, _ *string, _ *bool) { prefs.Network = "ws" }},
+		{name: "different security type", window: time.Minute, second: func(prefs *dto.HostPreferences, _ *string, _ *bool) { prefs.SecurityType = "reality" }},
+		{name: "different address family", window: time.Minute, second: func(prefs *dto.HostPreferences, _ *string, _ *bool) {
+			prefs.AddressFamily = customTypes.AddressFamilyIPv6
+		}},
+		{name: "different fallback", window: time.Minute, second: func(_ *dto.HostPreferences, _ *string, allow *bool) { *allow = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, &config.Config{KeyRequestDedupWindow: tt.window})
+			deps.hosts = newFakeHostRepo(testHost(1, "DE", true), testHost(2, "NL", true))
+			svc.hostRepo = deps.hosts
+			ctx := context.Background()
+
+			prefs, remarks, allow := dto.HostPreferences{Country: "DE"}, "Phone", false
+			first, err := svc.GenerateVlessKeyForUser(ctx, userID, remarks, prefs, allow)
+			if err != nil {
+				t.Fatalf("first GenerateVlessKeyForUser() error = %v", err)
+			}
+			firstAssignment := deps.assignments.latest[userID]
+
+			if tt.second != nil {
+				tt.second(&prefs, &remarks, &allow)
+			}
+			second, err := svc.GenerateVlessKeyForUser(ctx, userID, remarks, prefs, allow)
+			if err != nil {
+				t.Fatalf("second GenerateVlessKeyForUser() error = %v", err)
+			}
+
+			reused := deps.assignments.latest[userID] == firstAssignment
+			if reused != tt.wantReused {
+				t.Errorf("assignment reused = %t, want %t", reused, tt.wantReused)
+			}
+			var issued int64
+			for _, count := range deps.hosts.issuedCounts() {
+				issued += count
+			}
+			if wantIssued := map[bool]int64{true: 1, false: 2}[tt.wantReused]; issued != wantIssued {
+				t.Errorf("hosts issued %d keys, want %d", issued, wantIssued)
+			}
+			if tt.wantReused && second.VlessKey != first.VlessKey {
+				t.Errorf("second key = %q, want the first one %q", second.VlessKey, first.VlessKey)
+			}
+		})
+	}
+}
+
+// describeFilter renders the country and transport criteria of a host filter, with "*" for any value,
+// followed by "@" and the address family if the filter restricts it.
+func describeFilter(filter customTypes.HostSelectionFilter) string {
+	value := func(v *string) string {
+		if v == nil {
+			return "*"
+		}
+		return *v
+	}
+	described := value(filter.Country) + "/" + value(filter.Network) + "/" + value(filter.SecurityType)
+	if filter.AddressFamily != nil {
+		described += "@" + string(*filter.AddressFamily)
+	}
+	return described
+}
+
+func TestHostSelectionLadder(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs dto.HostPreferences
+		want  []string
+	}{
+		{name: "no preferences", want: []string{"*/*/*"}},
+		{name: "country", prefs: dto.HostPreferences{Country: "DE"}, want: []string{"DE/*/*", "*/*/*"}},
+		{name: "network", prefs: dto.HostPreferences{Network: "ws"}, want: []string{"*/ws/*"}},
+		{name: "security type", prefs: dto.HostPreferences{SecurityType: "reality"}, want: []string{"*/*/reality"}},
+		{
+			name:  "country and network",
+			prefs: dto.HostPreferences{Country: "DE", Network: "ws"},
+			want:  []string{"DE/ws/*", "DE/*/*", "*/ws/*"},
+		},
+		{
+			name:  "country, network and security type",
+			prefs: dto.HostPreferences{Country: "DE", Network: "grpc", SecurityType: "tls"},
+			want:  []string{"DE/grpc/tls", "DE/*/*", "*/grpc/tls"},
+		},
+		{name: "blank values are ignored", prefs: dto.HostPreferences{Country: " ", Network: " ws "}, want: []string{"*/ws/*"}},
+		{
+			name:  "address family",
+			prefs: dto.HostPreferences{AddressFamily: customTypes.AddressFamilyIPv6},
+			want:  []string{"*/*/*@ipv6", "*/*/*"},
+		},
+		{
+			name:  "country, network and address family",
+			prefs: dto.HostPreferences{Country: "DE", Network: "ws", AddressFamily: customTypes.AddressFamilyIPv4},
+			want:  []string{"DE/ws/*@ipv4", "DE/*/*@ipv4", "*/ws/*@ipv4", "DE/ws/*", "DE/*/*", "*/ws/*"},
+		},
+		{name: "hostname is not an address family preference", prefs: dto.HostPreferences{AddressFamily: customTypes.AddressFamilyHostname}, want: []string{"*/*/*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, step := range hostSelectionLadder(tt.prefs) {
+				if step.IsFreeTier != nil {
+					t.Errorf("step %s restricts the tier", describeFilter(step))
+				}
+				got = append(got, describeFilter(step))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("hostSelectionLadder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateVlessKeyForUserHostPreferences(t *testing.T) {
+	host := func(id uint, country, network, securityType string) models.Host {
+		h := testHost(id, country, true)
+		h.Network, h.SecurityType = network, securityType
+		return h
+	}
+
+	tests := []struct {
+		name       string
+		hosts      []models.Host
+		prefs      dto.HostPreferences
+		wantHostID uint // Zero if no key can be generated.
+		wantHost   dto.KeyHost
+	}{
+		{
+			name:       "exact match",
+			hosts:      []models.Host{host(1, "DE", "tcp", "tls"), host(2, "NL", "ws", "tls"), host(3, "DE", "ws", "tls")},
+			prefs:      dto.HostPreferences{Country: "DE", Network: "ws", SecurityType: "tls"},
+			wantHostID: 3, wantHost: dto.KeyHost{Country: "DE", Network: "ws", SecurityType: "tls"},
+		},
+		{
+			name:       "same country with any transport",
+			hosts:      []models.Host{host(1, "NL", "ws", "tls"), host(2, "DE", "tcp", "none")},
+			prefs:      dto.HostPreferences{Country: "DE", Network: "ws"},
+			wantHostID: 2, wantHost: dto.KeyHost{Country: "DE", Network: "tcp", SecurityType: "none"},
+		},
+		{
+			name:       "requested transport in any country",
+			hosts:      []models.Host{host(1, "US", "tcp", "tls"), host(2, "NL", "grpc", "tls")},
+			prefs:      dto.HostPreferences{Country: "DE", Network: "grpc"},
+			wantHostID: 2, wantHost: dto.KeyHost{Country: "NL", Network: "grpc", SecurityType: "tls"},
+		},
+		{
+			name:  "no host with the requested transport",
+			hosts: []models.Host{host(1, "US", "tcp", "tls")},
+			prefs: dto.HostPreferences{Country: "DE", Network: "grpc"},
+		},
+		{
+			name:       "transport is matched case-insensitively",
+			hosts:      []models.Host{host(1, "DE", "tcp", "tls"), host(2, "DE", "ws", "tls")},
+			prefs:      dto.HostPreferences{Network: "WS"},
+			wantHostID: 2, wantHost: dto.KeyHost{Country: "DE", Network: "ws", SecurityType: "tls"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+
+			result, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", tt.prefs, false)
+			if tt.wantHostID == 0 {
+				if err == nil {
+					t.Fatalf("GenerateVlessKeyForUser() = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+			}
+			if result.Host.Country != tt.wantHost.Country || result.Host.Network != tt.wantHost.Network || result.Host.SecurityType != tt.wantHost.SecurityType {
+				t.Errorf("result host = %+v, want %+v", result.Host, tt.wantHost)
+			}
+			if assignment := deps.assignments.latest[userID]; assignment == nil || assignment.HostID != tt.wantHostID {
+				t.Errorf("assignment = %+v, want one on host %d", assignment, tt.wantHostID)
+			}
+		})
+	}
+}
+
+func TestGetKeyGenerationRate(t *testing.T) {
+	userID := uuid.New()
+	// generated returns a log entry of a key generated age ago, for userID or, if forUser is false, anonymously.
+	generated := func(age time.Duration, forUser bool) models.KeyGeneration {
+		generation := models.KeyGeneration{HostID: 1, CreatedAt: time.Now().Add(-age)}
+		if forUser {
+			generation.UserID = &userID
+		}
+		return generation
+	}
+	seeded := []models.KeyGeneration{
+		generated(55*time.Minute, false),  // Bucket 0 of a 1h window in 10m buckets.
+		generated(52*time.Minute, true),   // Bucket 0.
+		generated(25*time.Minute, false),  // Bucket 3.
+		generated(5*time.Minute, true),    // Bucket 5.
+		generated(4*time.Minute, true),    // Bucket 5.
+		generated(150*time.Minute, false), // Before the window.
+	}
+
+	tests := []struct {
+		name        string
+		window      time.Duration
+		bucket      time.Duration
+		wantFree    []int64
+		wantUser    []int64
+		wantBucket  time.Duration
+		wantErr     bool
+		wantNoQuery bool
+	}{
+		{
+			name: "buckets across the window", window: time.Hour, bucket: 10 * time.Minute,
+			wantFree: []int64{1, 0, 0, 1, 0, 0}, wantUser: []int64{1, 0, 0, 0, 0, 2}, wantBucket: 10 * time.Minute,
+		},
+		{
+			name: "uneven last bucket", window: time.Hour, bucket: 25 * time.Minute,
+			wantFree: []int64{1, 1, 0}, wantUser: []int64{1, 0, 2}, wantBucket: 25 * time.Minute,
+		},
+		{
+			name: "bucket wider than the window", window: 30 * time.Minute, bucket: time.Hour,
+			wantFree: []int64{1}, wantUser: []int64{2}, wantBucket: 30 * time.Minute,
+		},
+		{
+			name: "window including older keys", window: 3 * time.Hour, bucket: time.Hour,
+			wantFree: []int64{1, 0, 2}, wantUser: []int64{0, 0, 3}, wantBucket: time.Hour,
+		},
+		{name: "zero window", bucket: time.Minute, wantErr: true},
+		{name: "zero bucket", window: time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestKeyService(t, nil)
+			deps.generations.generations = slices.Clone(seeded)
+
+			rate, err := svc.GetKeyGenerationRate(context.Background(), tt.window, tt.bucket)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetKeyGenerationRate() = %+v, want an error", rate)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetKeyGenerationRate() error = %v", err)
+			}
+			if rate.Bucket != tt.wantBucket || rate.To.Sub(rate.From) != tt.window {
+				t.Errorf("rate spans %s in %s buckets, want %s in %s buckets", rate.To.Sub(rate.From), rate.Bucket, tt.window, tt.wantBucket)
+			}
+			var gotFree, gotUser []int64
+			var totalFree, totalUser int64
+			for i, bucket := range rate.Buckets {
+				if want := rate.From.Add(time.Duration(i) * rate.Bucket); !bucket.Start.Equal(want) {
+					t.Errorf("bucket %d starts at %v, want %v", i, bucket.Start, want)
+				}
+				gotFree, gotUser = append(gotFree, bucket.FreeKeys), append(gotUser, bucket.UserKeys)
+				totalFree, totalUser = totalFree+bucket.FreeKeys, totalUser+bucket.UserKeys
+			}
+			if !slices.Equal(gotFree, tt.wantFree) || !slices.Equal(gotUser, tt.wantUser) {
+				t.Errorf("free keys per bucket = %v, user keys = %v; want %v, %v", gotFree, gotUser, tt.wantFree, tt.wantUser)
+			}
+			if rate.TotalFree != totalFree || rate.TotalUser != totalUser {
+				t.Errorf("totals = %d free, %d user; want the bucket sums %d, %d", rate.TotalFree, rate.TotalUser, totalFree, totalUser)
+			}
+		})
+	}
+
+	t.Run("generated keys are logged", func(t *testing.T) {
+		svc, deps, userID := newTestKeyService(t, nil)
+		deps.hosts = newFakeHostRepo(testHost(1, "DE", true))
+		svc.hostRepo = deps.hosts
+
+		if _, err := svc.GenerateFreeVlessKey(context.Background(), "", dto.HostPreferences{}); err != nil {
+			t.Fatalf("GenerateFreeVlessKey() error = %v", err)
+		}
+		if _, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", dto.HostPreferences{}, false); err != nil {
+			t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+		}
+
+		rate, err := svc.GetKeyGenerationRate(context.Background(), time.Hour, time.Hour)
+		if err != nil {
+			t.Fatalf("GetKeyGenerationRate() error = %v", err)
+		}
+		if rate.TotalFree != 1 || rate.TotalUser != 1 {
+			t.Errorf("totals = %d free, %d user; want 1, 1", rate.TotalFree, rate.TotalUser)
+		}
+	})
+}
+
+func TestGenerateVlessKeyForUserAddressFamily(t *testing.T) {
+	host := func(id uint, address string) models.Host {
+		h := testHost(id, "DE", true)
+		h.Address, h.AddressFamily = address, customTypes.AddressFamilyOf(address)
+		return h
+	}
+	ipv4Pool := []models.Host{host(1, "203.0.113.1"), host(2, "203.0.113.2")}
+	ipv6Pool := []models.Host{host(1, "2001:db8::1"), host(2, "2001:db8::2")}
+	mixedPool := []models.Host{host(1, "203.0.113.1"), host(2, "2001:db8::1"), host(3, "vpn.example.com")}
+
+	tests := []struct {
+		name       string
+		hosts      []models.Host
+		family     customTypes.AddressFamily
+		wantFamily customTypes.AddressFamily
+		wantHosts  []uint // Hosts the key may be issued on.
+	}{
+		{name: "ipv4-only pool, ipv4 requested", hosts: ipv4Pool, family: customTypes.AddressFamilyIPv4, wantFamily: customTypes.AddressFamilyIPv4, wantHosts: []uint{1, 2}},
+		{name: "ipv4-only pool, ipv6 falls back to ipv4", hosts: ipv4Pool, family: customTypes.AddressFamilyIPv6, wantFamily: customTypes.AddressFamilyIPv4, wantHosts: []uint{1, 2}},
+		{name: "ipv6-only pool, ipv6 requested", hosts: ipv6Pool, family: customTypes.AddressFamilyIPv6, wantFamily: customTypes.AddressFamilyIPv6, wantHosts: []uint{1, 2}},
+		{name: "ipv6-only pool, ipv4 falls back to ipv6", hosts: ipv6Pool, family: customTypes.AddressFamilyIPv4, wantFamily: customTypes.AddressFamilyIPv6, wantHosts: []uint{1, 2}},
+		{name: "mixed pool, ipv4 requested", hosts: mixedPool, family: customTypes.AddressFamilyIPv4, wantFamily: customTypes.AddressFamilyIPv4, wantHosts: []uint{1}},
+		{name: "mixed pool, ipv6 requested", hosts: mixedPool, family: customTypes.AddressFamilyIPv6, wantFamily: customTypes.AddressFamilyIPv6, wantHosts: []uint{2}},
+		{name: "mixed pool, any family", hosts: mixedPool, wantHosts: []uint{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, nil)
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+
+			result, err := svc.GenerateVlessKeyForUser(context.Background(), userID, "", dto.HostPreferences{AddressFamily: tt.family}, false)
+			if err != nil {
+				t.Fatalf("GenerateVlessKeyForUser() error = %v", err)
+			}
+			assignment := deps.assignments.latest[userID]
+			if assignment == nil || !slices.Contains(tt.wantHosts, assignment.HostID) {
+				t.Fatalf("assignment = %+v, want one on a host of %v", assignment, tt.wantHosts)
+			}
+			if tt.wantFamily != "" && result.Host.AddressFamily != tt.wantFamily {
+				t.Errorf("result host address family = %q, want %q", result.Host.AddressFamily, tt.wantFamily)
+			}
+			if result.Host.AddressFamily != tt.hosts[assignment.HostID-1].AddressFamily {
+				t.Errorf("result host address family = %q, want that of host %d", result.Host.AddressFamily, assignment.HostID)
+			}
+		})
+	}
+}
+
+func TestGetConversionFunnel(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 12, 0, 0, 0, time.UTC) }
+	var (
+		freeOnly       = uuid.New()
+		subscribedOnly = uuid.New()
+		paid           = uuid.New()
+		subscribedOld  = uuid.New() // Subscribed before their first free key.
+		deletedSub     = uuid.New()
+		returning      = uuid.New() // Free keys before and after subscribing.
+		paidLater      = uuid.New() // Paid after the period.
+		earlierFree    = uuid.New() // Free key before the period.
+		paidTier       = uuid.New() // Paid-tier keys only.
+	)
+	key := func(userID *uuid.UUID, isFreeTier bool, at time.Time) models.KeyGeneration {
+		return models.KeyGeneration{UserID: userID, HostID: 1, IsFreeTier: isFreeTier, CreatedAt: at}
+	}
+	generations := []models.KeyGeneration{
+		key(nil, true, day(time.March, 9)), // Anonymous, not attributable.
+		key(&freeOnly, true, day(time.March, 2)),
+		key(&subscribedOnly, true, day(time.March, 5)),
+		key(&paid, true, day(time.March, 20)),
+		key(&subscribedOld, true, day(time.March, 3)),
+		key(&deletedSub, true, day(time.March, 4)),
+		key(&returning, true, day(time.March, 7)),
+		key(&returning, true, day(time.March, 25)),
+		key(&paidLater, true, day(time.March, 28)),
+		key(&earlierFree, true, day(time.February, 25)),
+		key(&paidTier, false, day(time.March, 8)),
+	}
+	sub := func(userID uuid.UUID, paymentStatus string, createdAt time.Time) models.Subscription {
+		return models.Subscription{ID: uuid.New(), UserID: userID, PlanName: "Basic", PaymentStatus: paymentStatus, CreatedAt: createdAt}
+	}
+	deleted := sub(deletedSub, "paid", day(time.March, 6))
+	deleted.DeletedAt = gorm.DeletedAt{Time: day(time.March, 7), Valid: true}
+	subs := []models.Subscription{
+		sub(subscribedOnly, "pending", day(time.March, 10)),
+		sub(paid, "pending", day(time.March, 21)),
+		sub(paid, "paid", day(time.March, 22)),
+		sub(subscribedOld, "paid", day(time.February, 20)),
+		deleted,
+		sub(returning, "paid", day(time.March, 10)),
+		sub(paidLater, "paid", day(time.April, 5)),
+		sub(earlierFree, "paid", day(time.March, 1)),
+		sub(paidTier, "paid", day(time.March, 9)),
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     dto.ConversionFunnel // From and To are the requested period.
+		wantErr  bool
+	}{
+		{
+			name: "users at each stage", from: day(time.March, 1), to: day(time.April, 1),
+			want: dto.ConversionFunnel{FreeKeyUsers: 7, Subscribed: 4, Paid: 3, ConversionRate: 3.0 / 7},
+		},
+		{
+			// Only the free keys in the period count: the returning user's first one is after their subscription.
+			name: "later period", from: day(time.March, 15), to: day(time.April, 1),
+			want: dto.ConversionFunnel{FreeKeyUsers: 3, Subscribed: 2, Paid: 2, ConversionRate: 2.0 / 3},
+		},
+		{name: "no free keys", from: day(time.May, 1), to: day(time.June, 1)},
+		{name: "empty period", from: day(time.March, 1), to: day(time.March, 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestKeyService(t, nil)
+			deps.generations.generations = slices.Clone(generations)
+			deps.subs = newFakeSubRepo(slices.Clone(subs)...)
+			deps.generations.subs = deps.subs
+
+			got, err := svc.GetConversionFunnel(context.Background(), tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetConversionFunnel() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetConversionFunnel() error = %v", err)
+			}
+			tt.want.From, tt.want.To = tt.from, tt.to
+			if *got != tt.want {
+				t.Errorf("GetConversionFunnel() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGenerateVlessKeysForUserSeededSelection checks that with a seeded selector the hosts picked per country are
+// reproducible, and that they are drawn from the best ranked hosts of the user's tier.
+func TestGenerateVlessKeysForUserSeededSelection(t *testing.T) {
+	withStatus := func(host models.Host, status customTypes.HostStatus, issued int64) models.Host {
+		host.Status, host.IssuedCount = status, issued
+		return host
+	}
+	offline := testHost(9, "US", true)
+	offline.IsOnline = false
+	deleted := testHost(10, "FR", true)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	hosts := []models.Host{
+		testHost(1, "DE", true),
+		testHost(2, "de", true),
+		withStatus(testHost(3, "DE", true), customTypes.StatusActive, 5),      // Issued more than 1 and 2.
+		withStatus(testHost(4, "DE", true), customTypes.StatusInactive, 0),    // Ranked after the active hosts.
+		withStatus(testHost(5, "NL", true), customTypes.StatusMaintenance, 2), // No active NL host: ranked by issue count.
+		withStatus(testHost(6, "NL", true), customTypes.StatusUnknown, 1),
+		testHost(7, "US", false),
+		testHost(8, "US", false),
+		offline,
+		deleted,
+	}
+
+	tests := []struct {
+		name      string
+		paid      bool
+		countries []string
+		want      map[string][]uint // Hosts that may be picked, by country.
+	}{
+		{name: "free tier", want: map[string][]uint{"DE": {1, 2}, "NL": {6}}},
+		{name: "free tier in a requested country", countries: []string{"nl"}, want: map[string][]uint{"NL": {6}}},
+		{name: "paid tier", paid: true, want: map[string][]uint{"US": {7, 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := map[uint]bool{}
+			for seed := range uint64(10) {
+				generate := func() []uint {
+					svc, deps, userID := newTestKeyService(t, &config.Config{MaxKeyCountries: 10})
+					deps.hosts = newFakeHostRepo(slices.Clone(hosts)...)
+					svc.hostRepo = deps.hosts
+					svc.selector = NewHostSelector(rand.NewPCG(seed, 42))
+					if tt.paid {
+						deps.subs.subs[uuid.New()] = &models.Subscription{UserID: userID, IsActive: true, EndDate: time.Now().AddDate(0, 1, 0)}
+					}
+					result, err := svc.GenerateVlessKeysForUser(context.Background(), userID, "", tt.countries)
+					if err != nil {
+						t.Fatalf("GenerateVlessKeysForUser() error = %v", err)
+					}
+					var ids []uint
+					for _, key := range result.Keys {
+						ids = append(ids, key.HostID)
+					}
+					return ids
+				}
+
+				got := generate()
+				if again := generate(); !slices.Equal(got, again) {
+					t.Fatalf("seed %d: picked hosts %v, then %v with the same seed", seed, got, again)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("seed %d: picked hosts %v, want one per country of %v", seed, got, tt.want)
+				}
+				for _, id := range got {
+					country := strings.ToUpper(hosts[id-1].Country)
+					if !slices.Contains(tt.want[country], id) {
+						t.Errorf("seed %d: picked host %d in %s, want one of %v", seed, id, country, tt.want[country])
+					}
+					picked[id] = true
+				}
+			}
+			for _, ids := range tt.want {
+				for _, id := range ids {
+					if !picked[id] {
+						t.Errorf("host %d was never picked over 10 seeds", id)
+					}
+				}
+			}
+		})
+	}
+}
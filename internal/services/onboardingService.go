@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"log/slog"
+)
+
+type onboardingService struct {
+	userService interfaces.UserService
+	subService  interfaces.SubscriptionService
+	tx          interfaces.Transactor
+}
+
+// NewOnboardingService creates a new instance of onboardingService.
+// It composes the user and subscription services, running both inside one transaction per onboarding.
+func NewOnboardingService(
+	userService interfaces.UserService,
+	subService interfaces.SubscriptionService,
+	tx interfaces.Transactor,
+) interfaces.OnboardingService {
+	return &onboardingService{
+		userService: userService,
+		subService:  subService,
+		tx:          tx,
+	}
+}
+
+// Onboard registers a user and creates their first subscription in a single transaction.
+// The services' repository calls join the transaction through the context, so if the subscription cannot be
+// created the user is rolled back as well and no user is left without a subscription.
+// The subscription's domain event is published once the subscription is saved, before the transaction commits.
+func (s *onboardingService) Onboard(ctx context.Context, input dto.OnboardInput) (*dto.OnboardResult, error) {
+	slog.InfoContext(ctx, "Onboard: attempting to onboard user", "email", input.User.Email, "plan", input.Subscription.PlanName, "planID", input.Subscription.PlanID)
+
+	var user *models.User
+	var subscription *models.Subscription
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		userInput := input.User
+		userInput.SkipTrial = true // The user gets the requested subscription instead of a trial.
+		if user, _, err = s.userService.RegisterUser(ctx, userInput); err != nil {
+			return err
+		}
+
+		subInput := input.Subscription
+		subInput.UserID = user.ID
+		subInput.IdempotencyKey = nil // A failed insert aborts the transaction, so the idempotent retry lookup cannot run in it.
+		subscription, _, err = s.subService.CreateSubscription(ctx, subInput)
+		return err
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Onboard: onboarding rolled back", "email", input.User.Email, "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Onboard: user onboarded successfully", "userID", user.ID, "subscriptionID", subscription.ID)
+	return &dto.OnboardResult{User: user, Subscription: subscription}, nil
+}
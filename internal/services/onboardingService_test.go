@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOnboard(t *testing.T) {
+	existing := models.User{ID: uuid.New(), Name: "Existing", Email: "taken@example.com", Role: customTypes.RoleUser}
+	insertErr := errors.New("insert or update on table \"subscriptions\" violates foreign key constraint")
+
+	tests := []struct {
+		name         string
+		email        string
+		subscription func(*dto.CreateSubscriptionInput)
+		createErr    error // Returned by the subscription insert.
+		wantErr      error // Zero if the onboarding succeeds.
+	}{
+		{name: "user and subscription created", email: "new@example.com"},
+		{name: "subscription rejected", email: "new@example.com",
+			subscription: func(input *dto.CreateSubscriptionInput) { input.DurationValue = 0 }, wantErr: ErrValidation},
+		{name: "unknown promo code", email: "new@example.com",
+			subscription: func(input *dto.CreateSubscriptionInput) { input.PromoCode = ptr("NOPE") }, wantErr: ErrValidation},
+		{name: "trial requested", email: "new@example.com",
+			subscription: func(input *dto.CreateSubscriptionInput) { input.PaymentStatus = "trial" }, wantErr: ErrValidation},
+		{name: "subscription insert fails", email: "new@example.com", createErr: insertErr, wantErr: insertErr},
+		{name: "email taken", email: "Taken@example.com", wantErr: ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DefaultCurrency: "USD", TrialEnabled: true, TrialDurationDays: 7, TrialPlanName: "Trial"}
+			users, subs, publisher := newFakeUserRepo(existing), newFakeSubRepo(), &fakePublisher{}
+			subs.promoCodes, subs.createErr = newFakePromoCodeRepo(), tt.createErr
+			tx := &rollbackTx{users: users, subs: subs}
+			svc := NewOnboardingService(
+				NewUserService(users, subs, tx, cfg),
+				NewSubscriptionService(subs, users, nil, subs.promoCodes, &fakeAssignmentRepo{latest: map[uuid.UUID]*models.KeyAssignment{}}, publisher, tx, cfg),
+				tx,
+			)
+
+			subInput := newSubscriptionInput(uuid.New()) // The user ID is replaced by that of the new user.
+			subInput.IdempotencyKey = ptr("retry-1")
+			if tt.subscription != nil {
+				tt.subscription(&subInput)
+			}
+			result, err := svc.Onboard(context.Background(), dto.OnboardInput{
+				User:         dto.CreateUserInput{Name: "New User", Email: tt.email},
+				Subscription: subInput,
+			})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Onboard() error = %v, want %v", err, tt.wantErr)
+				}
+				// Everything is rolled back: no user is left without a subscription.
+				if len(users.users) != 1 || users.users[existing.ID] == nil {
+					t.Errorf("stored users = %v, want only the existing user", users.users)
+				}
+				if len(subs.subs) != 0 || len(subs.events) != 0 {
+					t.Errorf("stored %d subscriptions and %d events, want none", len(subs.subs), len(subs.events))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Onboard() error = %v", err)
+			}
+			if result.User.Email != tt.email || users.users[result.User.ID] == nil {
+				t.Errorf("user = %+v, want one stored with email %q", result.User, tt.email)
+			}
+			// The requested subscription replaces the trial and belongs to the new user.
+			if len(subs.subs) != 1 || subs.subs[result.Subscription.ID] == nil {
+				t.Fatalf("stored subscriptions = %v, want only the onboarding one", subs.subs)
+			}
+			stored := subs.subs[result.Subscription.ID]
+			if stored.UserID != result.User.ID || stored.PlanName != "Basic" || stored.IdempotencyKey != nil {
+				t.Errorf("stored subscription = %+v, want the Basic plan of user %s without an idempotency key", stored, result.User.ID)
+			}
+			if len(publisher.events) != 1 {
+				t.Errorf("published %d events, want 1", len(publisher.events))
+			}
+		})
+	}
+}
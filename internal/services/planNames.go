@@ -0,0 +1,41 @@
+package services
+
+import "strings"
+
+// planNameResolver maps free-text plan names to their canonical form, so that variants such as "pro",
+// "PRO" and "Pro plan" are stored and queried as one plan name.
+type planNameResolver struct {
+	canonical map[string]string // Canonical plan names keyed by planNameKey of each alias and canonical name.
+}
+
+// newPlanNameResolver builds a resolver from a map of aliases to canonical names.
+// Every canonical name also resolves from its own case variants.
+func newPlanNameResolver(aliases map[string]string) *planNameResolver {
+	canonical := make(map[string]string, 2*len(aliases))
+	for _, name := range aliases {
+		canonical[planNameKey(name)] = collapseSpaces(name)
+	}
+	for alias, name := range aliases {
+		canonical[planNameKey(alias)] = collapseSpaces(name)
+	}
+	return &planNameResolver{canonical: canonical}
+}
+
+// resolve returns the canonical name for name. Names without a configured alias are returned with surrounding
+// whitespace trimmed and inner whitespace collapsed to single spaces.
+func (r *planNameResolver) resolve(name string) string {
+	if name, ok := r.canonical[planNameKey(name)]; ok {
+		return name
+	}
+	return collapseSpaces(name)
+}
+
+// planNameKey returns the case- and whitespace-insensitive lookup key of a plan name.
+func planNameKey(name string) string {
+	return strings.ToLower(collapseSpaces(name))
+}
+
+// collapseSpaces trims s and replaces every run of inner whitespace with a single space.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestPlanNameResolver(t *testing.T) {
+	aliases := map[string]string{"pro plan": "Pro", "PREMIUM": "Pro", "basic  monthly": "Basic"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "canonical name", input: "Pro", want: "Pro"},
+		{name: "canonical name in another case", input: "PRO", want: "Pro"},
+		{name: "alias", input: "pro plan", want: "Pro"},
+		{name: "alias in another case", input: "Pro Plan", want: "Pro"},
+		{name: "alias configured in upper case", input: "premium", want: "Pro"},
+		{name: "alias with extra whitespace", input: "  pro   plan ", want: "Pro"},
+		{name: "alias configured with extra whitespace", input: "Basic Monthly", want: "Basic"},
+		{name: "unknown name is kept", input: "Enterprise", want: "Enterprise"},
+		{name: "unknown name has its whitespace collapsed", input: " Team \t Edition ", want: "Team Edition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPlanNameResolver(aliases).resolve(tt.input); got != tt.want {
+				t.Errorf("resolve(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no aliases", func(t *testing.T) {
+		if got := newPlanNameResolver(nil).resolve(" pro  plan "); got != "pro plan" {
+			t.Errorf("resolve() = %q, want %q", got, "pro plan")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type planService struct {
+	planRepo interfaces.PlanRepository
+}
+
+// NewPlanService creates a new instance of planService.
+func NewPlanService(planRepo interfaces.PlanRepository) interfaces.PlanService {
+	return &planService{
+		planRepo: planRepo,
+	}
+}
+
+// CreatePlan validates the input and adds a new plan to the catalog.
+func (s *planService) CreatePlan(ctx context.Context, input dto.CreatePlanInput) (*models.Plan, error) {
+	slog.InfoContext(ctx, "CreatePlan: attempting to create plan", "name", input.Name)
+
+	plan := &models.Plan{
+		Name:          strings.TrimSpace(input.Name),
+		Description:   input.Description,
+		DurationUnit:  input.DurationUnit,
+		DurationValue: input.DurationValue,
+		Price:         input.Price,
+		Currency:      strings.ToUpper(strings.TrimSpace(input.Currency)),
+		IsActive:      true, // New plans are available for subscriptions unless explicitly disabled.
+		IsFreeTier:    input.IsFreeTier,
+	}
+	if input.IsActive != nil {
+		plan.IsActive = *input.IsActive
+	}
+	if err := validatePlan(plan); err != nil {
+		slog.WarnContext(ctx, "CreatePlan: invalid plan input", "name", input.Name, "error", err)
+		return nil, err
+	}
+
+	if err := s.ensurePlanNameAvailable(ctx, plan.Name, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.planRepo.Create(ctx, plan); err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, fmt.Errorf("plan with name '%s' already exists", plan.Name)
+		}
+		slog.ErrorContext(ctx, "CreatePlan: failed to create plan in repository", "name", plan.Name, "error", err)
+		return nil, fmt.Errorf("could not create plan: %w", err)
+	}
+
+	slog.InfoContext(ctx, "CreatePlan: plan created successfully", "planID", plan.ID, "name", plan.Name)
+	return plan, nil
+}
+
+// GetPlan retrieves a plan by its ID.
+func (s *planService) GetPlan(ctx context.Context, id uint) (*models.Plan, error) {
+	plan, err := s.planRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetPlan: plan not found", "planID", id)
+			return nil, fmt.Errorf("plan with ID %d not found: %w", id, err)
+		}
+		slog.ErrorContext(ctx, "GetPlan: failed to retrieve plan", "planID", id, "error", err)
+		return nil, fmt.Errorf("could not retrieve plan: %w", err)
+	}
+	return plan, nil
+}
+
+// UpdatePlan applies the provided changes to an existing plan.
+// Subscriptions copy plan data at creation time, so existing subscriptions are not affected.
+func (s *planService) UpdatePlan(ctx context.Context, id uint, input dto.UpdatePlanInput) (*models.Plan, error) {
+	slog.InfoContext(ctx, "UpdatePlan: attempting to update plan", "planID", id)
+
+	plan, err := s.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		plan.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		plan.Description = *input.Description
+	}
+	if input.DurationUnit != nil {
+		plan.DurationUnit = *input.DurationUnit
+	}
+	if input.DurationValue != nil {
+		plan.DurationValue = *input.DurationValue
+	}
+	if input.Price != nil {
+		plan.Price = *input.Price
+	}
+	if input.Currency != nil {
+		plan.Currency = strings.ToUpper(strings.TrimSpace(*input.Currency))
+	}
+	if input.IsActive != nil {
+		plan.IsActive = *input.IsActive
+	}
+	if input.IsFreeTier != nil {
+		plan.IsFreeTier = *input.IsFreeTier
+	}
+	if err := validatePlan(plan); err != nil {
+		slog.WarnContext(ctx, "UpdatePlan: invalid plan input", "planID", id, "error", err)
+		return nil, err
+	}
+
+	if input.Name != nil {
+		if err := s.ensurePlanNameAvailable(ctx, plan.Name, plan.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, fmt.Errorf("plan with name '%s' already exists", plan.Name)
+		}
+		slog.ErrorContext(ctx, "UpdatePlan: failed to update plan in repository", "planID", id, "error", err)
+		return nil, fmt.Errorf("could not update plan: %w", err)
+	}
+
+	slog.InfoContext(ctx, "UpdatePlan: plan updated successfully", "planID", plan.ID)
+	return plan, nil
+}
+
+// DeletePlan performs a soft delete on a plan.
+func (s *planService) DeletePlan(ctx context.Context, id uint) error {
+	slog.InfoContext(ctx, "DeletePlan: attempting to delete plan", "planID", id)
+
+	if err := s.planRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "DeletePlan: plan to delete not found", "planID", id)
+			return fmt.Errorf("plan with ID %d not found: %w", id, err)
+		}
+		slog.ErrorContext(ctx, "DeletePlan: failed to delete plan in repository", "planID", id, "error", err)
+		return fmt.Errorf("could not delete plan: %w", err)
+	}
+
+	slog.InfoContext(ctx, "DeletePlan: plan deleted successfully", "planID", id)
+	return nil
+}
+
+// ListPlans retrieves a paginated list of plans.
+func (s *planService) ListPlans(ctx context.Context, page, pageSize int, activeOnly bool) ([]models.Plan, int64, error) {
+	slog.InfoContext(ctx, "ListPlans: listing plans", "page", page, "pageSize", pageSize, "activeOnly", activeOnly)
+
+	page, pageSize = normalizePage(page, pageSize)
+	offset := (page - 1) * pageSize
+
+	plans, totalCount, err := s.planRepo.List(ctx, offset, pageSize, activeOnly)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListPlans: failed to list plans from repository", "error", err)
+		return nil, 0, fmt.Errorf("could not list plans: %w", err)
+	}
+	return plans, totalCount, nil
+}
+
+// ensurePlanNameAvailable returns an error if another plan (other than exceptID) already uses the name.
+func (s *planService) ensurePlanNameAvailable(ctx context.Context, name string, exceptID uint) error {
+	existing, err := s.planRepo.GetByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.ErrorContext(ctx, "ensurePlanNameAvailable: failed to check plan name", "name", name, "error", err)
+		return fmt.Errorf("could not verify plan name uniqueness: %w", err)
+	}
+	if existing.ID != exceptID {
+		slog.WarnContext(ctx, "ensurePlanNameAvailable: plan name already in use", "name", name, "existingID", existing.ID)
+		return fmt.Errorf("plan with name '%s' already exists", name)
+	}
+	return nil
+}
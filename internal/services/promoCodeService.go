@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxPromoCodeLength is the maximum accepted length of a promo code.
+const maxPromoCodeLength = 64
+
+type promoCodeService struct {
+	promoRepo interfaces.PromoCodeRepository
+}
+
+// NewPromoCodeService creates a new instance of promoCodeService.
+func NewPromoCodeService(promoRepo interfaces.PromoCodeRepository) interfaces.PromoCodeService {
+	return &promoCodeService{
+		promoRepo: promoRepo,
+	}
+}
+
+// CreatePromoCode validates the input and creates a new promo code.
+func (s *promoCodeService) CreatePromoCode(ctx context.Context, input dto.CreatePromoCodeInput) (*models.PromoCode, error) {
+	code := normalizePromoCode(input.Code)
+	slog.InfoContext(ctx, "CreatePromoCode: attempting to create promo code", "code", code)
+
+	if code == "" || len(code) > maxPromoCodeLength {
+		return nil, fmt.Errorf("invalid promo code: code must be between 1 and %d characters", maxPromoCodeLength)
+	}
+	if (input.DiscountPercent > 0) == (input.DiscountAmount > 0) {
+		return nil, errors.New("invalid promo code: exactly one of discount percent and discount amount must be positive")
+	}
+	if input.DiscountPercent < 0 || input.DiscountPercent > 100 || input.DiscountAmount < 0 {
+		return nil, errors.New("invalid promo code: discount percent must be at most 100 and discounts cannot be negative")
+	}
+	if input.ValidFrom != nil && input.ValidUntil != nil && !input.ValidUntil.After(*input.ValidFrom) {
+		return nil, errors.New("invalid promo code: valid_until must be after valid_from")
+	}
+	if input.MaxUses != nil && *input.MaxUses <= 0 {
+		return nil, errors.New("invalid promo code: max uses must be positive")
+	}
+
+	promoCode := &models.PromoCode{
+		Code:            code,
+		DiscountPercent: input.DiscountPercent,
+		DiscountAmount:  input.DiscountAmount,
+		ValidFrom:       input.ValidFrom,
+		ValidUntil:      input.ValidUntil,
+		MaxUses:         input.MaxUses,
+		IsActive:        true, // New codes are redeemable unless explicitly disabled.
+	}
+	if input.IsActive != nil {
+		promoCode.IsActive = *input.IsActive
+	}
+
+	if err := s.promoRepo.Create(ctx, promoCode); err != nil {
+		if isDuplicateKeyError(err) {
+			slog.WarnContext(ctx, "CreatePromoCode: promo code already exists", "code", code)
+			return nil, fmt.Errorf("promo code '%s' already exists", code)
+		}
+		slog.ErrorContext(ctx, "CreatePromoCode: failed to create promo code in repository", "code", code, "error", err)
+		return nil, fmt.Errorf("could not create promo code: %w", err)
+	}
+
+	slog.InfoContext(ctx, "CreatePromoCode: promo code created successfully", "promoCodeID", promoCode.ID, "code", code)
+	return promoCode, nil
+}
+
+// ValidatePromoCode retrieves a promo code and checks that it can currently be redeemed.
+func (s *promoCodeService) ValidatePromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	code = normalizePromoCode(code)
+	promoCode, err := s.promoRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "ValidatePromoCode: promo code not found", "code", code)
+			return nil, fmt.Errorf("promo code '%s' not found: %w", code, err)
+		}
+		slog.ErrorContext(ctx, "ValidatePromoCode: failed to retrieve promo code", "code", code, "error", err)
+		return nil, fmt.Errorf("could not retrieve promo code: %w", err)
+	}
+	if err := checkPromoCodeRedeemable(promoCode, time.Now()); err != nil {
+		return nil, err
+	}
+	return promoCode, nil
+}
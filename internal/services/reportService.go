@@ -0,0 +1,78 @@
+package services
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/services/dto"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type reportService struct {
+	userRepo       interfaces.UserRepository
+	subRepo        interfaces.SubscriptionRepository
+	hostRepo       interfaces.HostRepository
+	generationRepo interfaces.KeyGenerationRepository
+}
+
+// NewReportService creates a new instance of reportService.
+func NewReportService(
+	userRepo interfaces.UserRepository,
+	subRepo interfaces.SubscriptionRepository,
+	hostRepo interfaces.HostRepository,
+	generationRepo interfaces.KeyGenerationRepository,
+) interfaces.ReportService {
+	return &reportService{
+		userRepo:       userRepo,
+		subRepo:        subRepo,
+		hostRepo:       hostRepo,
+		generationRepo: generationRepo,
+	}
+}
+
+// GetOverview combines the aggregates shown on the admin dashboard. Every aggregate is computed by the database;
+// they are read one after another, so the numbers may be a few moments apart from each other.
+func (s *reportService) GetOverview(ctx context.Context) (*dto.Overview, error) {
+	slog.InfoContext(ctx, "GetOverview: generating overview report")
+
+	now := time.Now()
+	overview := &dto.Overview{GeneratedAt: now}
+
+	userCounts, err := s.userRepo.CountSummary(ctx, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count users", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count users: %w", err))
+	}
+	overview.Users = *userCounts
+
+	if overview.UsersWithActivePaidSubscription, err = s.subRepo.CountUsersWithActivePaidSubscription(ctx, now); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count users with an active paid subscription", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count users with an active paid subscription: %w", err))
+	}
+	if overview.SubscriptionsByPaymentStatus, err = s.subRepo.CountByPaymentStatus(ctx); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count subscriptions by payment status", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count subscriptions by payment status: %w", err))
+	}
+	if overview.ActiveSubscriptionsByPlan, err = s.subRepo.CountActiveByPlan(ctx, now); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count active subscriptions by plan", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count active subscriptions by plan: %w", err))
+	}
+	if overview.HostsByStatus, err = s.hostRepo.CountByStatus(ctx); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count hosts by status", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count hosts by status: %w", err))
+	}
+	if overview.HostsByCountry, err = s.hostRepo.CountByCountry(ctx); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count hosts by country", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count hosts by country: %w", err))
+	}
+
+	startOfDay := now.UTC().Truncate(24 * time.Hour)
+	if overview.KeysGeneratedToday, err = s.generationRepo.CountSince(ctx, startOfDay); err != nil {
+		slog.ErrorContext(ctx, "GetOverview: failed to count keys generated today", "error", err)
+		return nil, contextAware(fmt.Errorf("could not count keys generated today: %w", err))
+	}
+
+	slog.InfoContext(ctx, "GetOverview: overview report generated", "users", overview.Users.Total, "paidUsers", overview.UsersWithActivePaidSubscription, "keysToday", overview.KeysGeneratedToday)
+	return overview, nil
+}
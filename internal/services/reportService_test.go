@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// failingHostRepo is a fakeHostRepo whose host count by status fails.
+type failingHostRepo struct {
+	*fakeHostRepo
+	err error
+}
+
+func (r failingHostRepo) CountByStatus(context.Context) ([]customTypes.HostStatusCount, error) {
+	return nil, r.err
+}
+
+func TestGetOverview(t *testing.T) {
+	now := time.Now()
+	startOfDay := now.UTC().Truncate(24 * time.Hour)
+	deleted := gorm.DeletedAt{Time: now.AddDate(0, 0, -1), Valid: true}
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	user := func(isActive bool, createdAt time.Time) models.User {
+		return models.User{ID: uuid.New(), Name: "User", IsActive: isActive, CreatedAt: createdAt}
+	}
+	recent, older, inactive, longAgo, removed := user(true, daysAgo(2)), user(true, daysAgo(20)), user(false, daysAgo(3)), user(true, daysAgo(90)), user(true, daysAgo(1))
+	removed.DeletedAt = deleted
+
+	sub := func(userID uuid.UUID, planName, paymentStatus string, isActive bool, start, end time.Time) models.Subscription {
+		return models.Subscription{ID: uuid.New(), UserID: userID, PlanName: planName, PaymentStatus: paymentStatus, IsActive: isActive, StartDate: start, EndDate: end}
+	}
+	current := func(userID uuid.UUID, planName, paymentStatus string) models.Subscription {
+		return sub(userID, planName, paymentStatus, true, daysAgo(5), now.AddDate(0, 0, 25))
+	}
+	deletedSub := sub(longAgo.ID, "Basic", "failed", true, daysAgo(5), now.AddDate(0, 0, 25))
+	deletedSub.DeletedAt = deleted
+	subs := []models.Subscription{
+		current(recent.ID, "Premium", "paid"),
+		current(recent.ID, "Basic", "paid"), // The same user is counted once.
+		current(older.ID, "Basic", "pending"),
+		current(older.ID, "Premium", "paid"),
+		current(longAgo.ID, "Premium", "pending"),
+		sub(older.ID, "Basic", "paid", true, daysAgo(60), daysAgo(30)),                       // Expired.
+		sub(inactive.ID, "Premium", "paid", false, daysAgo(5), now.AddDate(0, 0, 25)),        // Cancelled.
+		sub(longAgo.ID, "Premium", "paid", true, now.AddDate(0, 0, 1), now.AddDate(0, 1, 1)), // Not started.
+		deletedSub,
+	}
+
+	host := func(id uint, country string, status customTypes.HostStatus) models.Host {
+		return models.Host{ID: id, Country: country, Status: status}
+	}
+	deletedHost := host(6, "NL", customTypes.StatusActive)
+	deletedHost.DeletedAt = deleted
+	hosts := []models.Host{
+		host(1, "DE", customTypes.StatusActive),
+		host(2, "DE", customTypes.StatusMaintenance),
+		host(3, "DE", customTypes.StatusActive),
+		host(4, "NL", customTypes.StatusInactive),
+		host(5, "", customTypes.StatusUnknown),
+		deletedHost,
+	}
+
+	generations := []models.KeyGeneration{
+		{HostID: 1, CreatedAt: startOfDay},
+		{HostID: 1, CreatedAt: startOfDay.Add(time.Minute), UserID: &recent.ID},
+		{HostID: 3, CreatedAt: now},
+		{HostID: 1, CreatedAt: startOfDay.Add(-time.Second)}, // Yesterday.
+		{HostID: 2, CreatedAt: daysAgo(3)},
+	}
+
+	svc := NewReportService(
+		newFakeUserRepo(recent, older, inactive, longAgo, removed),
+		newFakeSubRepo(subs...),
+		newFakeHostRepo(hosts...),
+		&fakeGenerationRepo{generations: generations},
+	)
+
+	got, err := svc.GetOverview(context.Background())
+	if err != nil {
+		t.Fatalf("GetOverview() error = %v", err)
+	}
+	if time.Since(got.GeneratedAt) > time.Minute {
+		t.Errorf("GeneratedAt = %v, want now", got.GeneratedAt)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "users", got: got.Users, want: customTypes.UserCounts{Total: 4, Active: 3, NewLast7Days: 2, NewLast30Days: 3}},
+		{name: "users with an active paid subscription", got: got.UsersWithActivePaidSubscription, want: int64(2)},
+		{name: "subscriptions by payment status", got: got.SubscriptionsByPaymentStatus, want: []customTypes.PaymentStatusCount{
+			{PaymentStatus: "paid", Count: 6}, {PaymentStatus: "pending", Count: 2},
+		}},
+		{name: "active subscriptions by plan", got: got.ActiveSubscriptionsByPlan, want: []customTypes.PlanSubscriptionCount{
+			{PlanName: "Premium", Count: 3}, {PlanName: "Basic", Count: 2},
+		}},
+		{name: "hosts by status", got: got.HostsByStatus, want: []customTypes.HostStatusCount{
+			{Status: customTypes.StatusActive, Count: 2}, {Status: customTypes.StatusInactive, Count: 1},
+			{Status: customTypes.StatusMaintenance, Count: 1}, {Status: customTypes.StatusUnknown, Count: 1},
+		}},
+		{name: "hosts by country", got: got.HostsByCountry, want: []customTypes.CountryHostCount{
+			{Country: "DE", Count: 3}, {Country: "", Count: 1}, {Country: "NL", Count: 1},
+		}},
+		{name: "keys generated today", got: got.KeysGeneratedToday, want: int64(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s = %+v, want %+v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	t.Run("failing aggregate", func(t *testing.T) {
+		dbErr := errors.New("connection reset")
+		svc := NewReportService(newFakeUserRepo(), newFakeSubRepo(), failingHostRepo{newFakeHostRepo(), dbErr}, &fakeGenerationRepo{})
+
+		if overview, err := svc.GetOverview(context.Background()); !errors.Is(err, dbErr) {
+			t.Errorf("GetOverview() = %+v, %v; want error %v", overview, err, dbErr)
+		}
+	})
+}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"bitback/internal/config"
+	"bitback/internal/events"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"bitback/internal/services/dto"
 	"context"
 	"errors"
@@ -16,67 +19,193 @@ import (
 )
 
 type subscriptionService struct {
-	subRepo  interfaces.SubscriptionRepository
-	userRepo interfaces.UserRepository
+	subRepo        interfaces.SubscriptionRepository
+	userRepo       interfaces.UserRepository
+	planRepo       interfaces.PlanRepository
+	promoRepo      interfaces.PromoCodeRepository
+	assignmentRepo interfaces.KeyAssignmentRepository
+	publisher      interfaces.EventPublisher
+	tx             interfaces.Transactor
+	cfg            *config.Config
+
+	planNames *planNameResolver // Resolves plan name variants to their canonical names.
 }
 
 // NewSubscriptionService creates a new instance of subscriptionService.
+// The publisher is optional; if it is nil, no domain events are published.
 func NewSubscriptionService(
 	subRepo interfaces.SubscriptionRepository,
 	userRepo interfaces.UserRepository,
+	planRepo interfaces.PlanRepository,
+	promoRepo interfaces.PromoCodeRepository,
+	assignmentRepo interfaces.KeyAssignmentRepository,
+	publisher interfaces.EventPublisher,
+	tx interfaces.Transactor,
+	cfg *config.Config,
 ) interfaces.SubscriptionService {
 	return &subscriptionService{
-		subRepo:  subRepo,
-		userRepo: userRepo,
+		subRepo:        subRepo,
+		userRepo:       userRepo,
+		planRepo:       planRepo,
+		promoRepo:      promoRepo,
+		assignmentRepo: assignmentRepo,
+		publisher:      publisher,
+		tx:             tx,
+		cfg:            cfg,
+		planNames:      newPlanNameResolver(cfg.PlanNameAliases),
+	}
+}
+
+// inferUserCurrency infers a user's subscription currency from the country of the host of their latest active key
+// assignment. The country is resolved server-side rather than taken from the request, and CreateSubscription rejects
+// a different currency from non-administrators, so clients cannot pick a cheaper currency.
+// It returns the default currency if the user has no assignment or the country has no mapping.
+func (s *subscriptionService) inferUserCurrency(ctx context.Context, userID uuid.UUID) string {
+	var country *string
+	assignment, err := s.assignmentRepo.GetLatestActiveByUserID(ctx, userID)
+	switch {
+	case err == nil:
+		country = &assignment.Host.Country
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		slog.WarnContext(ctx, "inferUserCurrency: failed to get user's key assignment, using the default currency", "userID", userID, "error", err)
+	}
+	currency := inferCurrency(country, s.cfg.CurrencyByCountry, s.cfg.DefaultCurrency)
+	slog.DebugContext(ctx, "inferUserCurrency: currency inferred", "userID", userID, "country", country, "currency", currency)
+	return currency
+}
+
+// publish sends a domain event if a publisher is configured.
+// Events are published after the change is persisted, so failures are logged rather than returned.
+func (s *subscriptionService) publish(ctx context.Context, event interfaces.Event) {
+	if s.publisher == nil {
+		return
+	}
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish domain event", "event", event.EventName(), "error", err)
 	}
 }
 
 // CreateSubscription handles the creation of a new subscription.
 // It validates input, calculates the end date, determines initial active status,
 // and persists the subscription.
-func (s *subscriptionService) CreateSubscription(ctx context.Context, input dto.CreateSubscriptionInput) (*models.Subscription, error) {
+// If an idempotency key is provided and the user already has a subscription created with that key,
+// the existing subscription is returned instead (created is false), provided the payload matches.
+func (s *subscriptionService) CreateSubscription(ctx context.Context, input dto.CreateSubscriptionInput) (*models.Subscription, bool, error) {
 	slog.InfoContext(ctx, "CreateSubscription: attempting to create subscription", "userID", input.UserID, "plan", input.PlanName)
 
+	// Normalize and validate the optional idempotency key.
+	var idempotencyKey string
+	if input.IdempotencyKey != nil {
+		idempotencyKey = strings.TrimSpace(*input.IdempotencyKey)
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		slog.WarnContext(ctx, "CreateSubscription: idempotency key too long", "length", len(idempotencyKey))
+		return nil, false, invalid(fmt.Errorf("invalid idempotency key: must be at most %d characters", maxIdempotencyKeyLength))
+	}
+
+	// Resolve plan name variants to the canonical name, so subscriptions to the same plan group together.
+	if canonical := s.planNames.resolve(input.PlanName); canonical != input.PlanName {
+		slog.InfoContext(ctx, "CreateSubscription: plan name resolved to canonical name", "planName", input.PlanName, "canonical", canonical)
+		input.PlanName = canonical
+	}
+	// Trials are only granted on registration, which checks that the user never had one; they cannot be requested.
+	if strings.EqualFold(strings.TrimSpace(input.PaymentStatus), "trial") {
+		slog.WarnContext(ctx, "CreateSubscription: trial requested", "userID", input.UserID, "actorRole", input.ActorRole)
+		return nil, false, invalid(errors.New("invalid payment status: trials are granted on registration and cannot be requested"))
+	}
+
+	// Subscriptions created by anyone but an administrator are to a catalog plan at the plan's price, and await
+	// payment; the payment webhook marks them paid.
+	if !input.ActorRole.IsAdmin() {
+		if input.PlanID == nil {
+			slog.WarnContext(ctx, "CreateSubscription: non-administrator did not choose a plan", "userID", input.UserID)
+			return nil, false, invalid(errors.New("invalid plan: a catalog plan ID is required"))
+		}
+		if input.PaymentStatus != "pending" {
+			slog.InfoContext(ctx, "CreateSubscription: payment status set to pending for non-administrator", "userID", input.UserID, "requested", input.PaymentStatus)
+			input.PaymentStatus = "pending"
+		}
+		input.Price = nil
+	}
+
+	// Fingerprint the client input before the plan, currency and promo code values are derived from it.
+	fingerprint := subscriptionFingerprint(input)
+
+	// A retried request with the same key returns the subscription created by the original request. The lookup
+	// comes first, so a retry succeeds even if the plan, the inferred currency or the promo code changed since,
+	// e.g. because the original request used up a single-use promo code.
+	if idempotencyKey != "" {
+		existing, err := s.findIdempotentSubscription(ctx, input.UserID, idempotencyKey, fingerprint)
+		if err != nil || existing != nil {
+			return existing, false, err
+		}
+	}
+
 	// Validate user existence.
 	if _, err := s.userRepo.GetByID(ctx, input.UserID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "CreateSubscription: user not found", "userID", input.UserID)
-			return nil, fmt.Errorf("user with ID %s not found", input.UserID)
+			return nil, false, notFound(fmt.Errorf("user with ID %s not found", input.UserID))
 		}
 		slog.ErrorContext(ctx, "CreateSubscription: failed to verify user", "userID", input.UserID, "error", err)
-		return nil, fmt.Errorf("failed to verify user existence: %w", err)
+		return nil, false, contextAware(fmt.Errorf("failed to verify user existence: %w", err))
+	}
+
+	// Only administrators choose the currency. A currency requested by anyone else is checked against the one
+	// derived from the plan or the user's host once that is known.
+	var requestedCurrency string
+	if !input.ActorRole.IsAdmin() && input.Currency != nil {
+		requestedCurrency = strings.ToUpper(strings.TrimSpace(*input.Currency))
+		input.Currency = nil
+	}
+
+	// When subscribing to a catalog plan, its name, duration and price are copied into the subscription
+	// so that later plan edits do not change existing subscriptions.
+	if input.PlanID != nil {
+		if err := s.applyPlan(ctx, &input); err != nil {
+			return nil, false, err
+		}
 	}
 
 	// Validate subscription parameters.
 	if !input.DurationUnit.IsValid() || input.DurationUnit == "" {
 		slog.WarnContext(ctx, "CreateSubscription: invalid duration unit", "unit", input.DurationUnit)
-		return nil, fmt.Errorf("invalid or empty duration unit: '%s'", input.DurationUnit)
+		return nil, false, invalid(fmt.Errorf("invalid or empty duration unit: '%s'", input.DurationUnit))
 	}
 	if input.DurationValue <= 0 {
 		slog.WarnContext(ctx, "CreateSubscription: non-positive duration value", "value", input.DurationValue)
-		return nil, errors.New("duration value must be positive")
+		return nil, false, invalid(errors.New("duration value must be positive"))
 	}
 	if input.PlanName == "" {
 		slog.WarnContext(ctx, "CreateSubscription: empty plan name")
-		return nil, errors.New("plan name cannot be empty")
+		return nil, false, invalid(errors.New("plan name cannot be empty"))
 	}
 
 	// Calculate the subscription's end date based on the start date and duration.
 	endDate, err := calculateEndDate(input.StartDate, input.DurationUnit, input.DurationValue)
 	if err != nil {
 		slog.ErrorContext(ctx, "CreateSubscription: failed to calculate end date", "error", err)
-		return nil, fmt.Errorf("failed to calculate end date: %w", err)
+		return nil, false, contextAware(fmt.Errorf("failed to calculate end date: %w", err))
 	}
-
-	// Determine if the subscription should be initially active.
-	isActive := false
-	if input.PaymentStatus == "paid" && !endDate.Before(time.Now()) {
-		isActive = true
+	if err := validateSubscriptionPeriod(input.StartDate, endDate); err != nil {
+		slog.ErrorContext(ctx, "CreateSubscription: computed end date is not after start date", "startDate", input.StartDate, "endDate", endDate)
+		return nil, false, err
+	}
+	now := time.Now()
+	if s.cfg.MaxSubscriptionStartAhead > 0 && input.StartDate.After(now.Add(s.cfg.MaxSubscriptionStartAhead)) {
+		slog.WarnContext(ctx, "CreateSubscription: start date too far in the future", "startDate", input.StartDate, "maxStartAhead", s.cfg.MaxSubscriptionStartAhead)
+		return nil, false, invalid(fmt.Errorf("start date %s is too far in the future: subscriptions may start at most %s from now",
+			input.StartDate.Format(time.RFC3339), s.cfg.MaxSubscriptionStartAhead))
 	}
 
+	// Determine if the subscription should be initially active. Paid subscriptions starting in the future
+	// stay inactive until the activation job activates them on their start date.
+	isActive := activatesSubscription(input.PaymentStatus) && !input.StartDate.After(now) && endDate.After(now)
+
 	// Prepare the subscription model.
 	subscription := &models.Subscription{
 		UserID:        input.UserID,
+		PlanID:        input.PlanID,
 		PlanName:      input.PlanName,
 		DurationUnit:  input.DurationUnit,
 		DurationValue: input.DurationValue,
@@ -89,142 +218,476 @@ func (s *subscriptionService) CreateSubscription(ctx context.Context, input dto.
 	if input.Price != nil {
 		subscription.Price = *input.Price
 	}
-	if input.Currency != nil {
-		subscription.Currency = *input.Currency
+	if input.Currency != nil && strings.TrimSpace(*input.Currency) != "" {
+		subscription.Currency = strings.ToUpper(strings.TrimSpace(*input.Currency))
+	} else {
+		// No explicit currency: infer it from the user's current host, falling back to the default currency.
+		subscription.Currency = s.inferUserCurrency(ctx, input.UserID)
+	}
+	if requestedCurrency != "" && requestedCurrency != subscription.Currency {
+		slog.WarnContext(ctx, "CreateSubscription: requested currency does not match the user's currency", "userID", input.UserID, "requested", requestedCurrency, "currency", subscription.Currency)
+		return nil, false, invalid(fmt.Errorf("invalid currency: '%s' does not match the currency '%s' of the user's region", requestedCurrency, subscription.Currency))
+	}
+
+	// Apply the promo code discount. Redemption limits are enforced atomically when the subscription is saved.
+	var promoCode *models.PromoCode
+	if input.PromoCode != nil && strings.TrimSpace(*input.PromoCode) != "" {
+		promoCode, err = s.getRedeemablePromoCode(ctx, *input.PromoCode)
+		if err != nil {
+			return nil, false, err
+		}
+		subscription.PromoCodeID = &promoCode.ID
+		subscription.Price = applyPromoDiscount(subscription.Price, promoCode)
+		slog.InfoContext(ctx, "CreateSubscription: promo code applied", "promoCode", promoCode.Code, "price", subscription.Price)
+	}
+
+	if idempotencyKey != "" {
+		subscription.IdempotencyKey = &idempotencyKey
+		subscription.IdempotencyHash = fingerprint
 	}
 
-	// Save the new subscription to the repository.
-	if err := s.subRepo.Create(ctx, subscription); err != nil {
+	// Save the new subscription to the repository, redeeming the promo code in the same transaction.
+	// The user row is locked first so the user cannot be deleted before the subscription is saved.
+	event := newSubscriptionEvent(customTypes.SubscriptionEventCreated, input.ActorUserID, nil, subscriptionSnapshot(subscription))
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := s.userRepo.GetByIDForUpdate(ctx, input.UserID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFound(fmt.Errorf("user with ID %s not found", input.UserID))
+			}
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+		if promoCode != nil {
+			return s.subRepo.CreateWithPromoCode(ctx, subscription, promoCode.ID, event)
+		}
+		return s.subRepo.Create(ctx, subscription, event)
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			slog.WarnContext(ctx, "CreateSubscription: user deleted before the subscription was saved", "userID", input.UserID)
+			return nil, false, err
+		}
+		if promoCode != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "CreateSubscription: promo code could not be redeemed", "promoCode", promoCode.Code)
+			return nil, false, invalid(fmt.Errorf("invalid promo code: '%s' has reached its maximum number of uses or is no longer valid", promoCode.Code))
+		}
+		// Two identical requests may race past the lookup above; the unique index lets only one of them
+		// insert, and the other returns the winner's subscription.
+		if idempotencyKey != "" && isDuplicateKeyError(err) {
+			slog.InfoContext(ctx, "CreateSubscription: concurrent request with the same idempotency key detected", "userID", input.UserID, "idempotencyKey", idempotencyKey)
+			existing, lookupErr := s.findIdempotentSubscription(ctx, input.UserID, idempotencyKey, subscription.IdempotencyHash)
+			if lookupErr != nil || existing != nil {
+				return existing, false, lookupErr
+			}
+		}
 		slog.ErrorContext(ctx, "CreateSubscription: failed to save subscription", "userID", input.UserID, "error", err)
-		return nil, fmt.Errorf("could not create subscription: %w", err)
+		return nil, false, contextAware(fmt.Errorf("could not create subscription: %w", err))
 	}
 
 	slog.InfoContext(ctx, "CreateSubscription: subscription created successfully", "subscriptionID", subscription.ID, "userID", input.UserID)
-	return subscription, nil
+	s.publish(ctx, newSubscriptionCreatedEvent(subscription))
+	return subscription, true, nil
+}
+
+// applyPlan loads the catalog plan referenced by input.PlanID and copies its name, duration and price into the input.
+// A plan name given alongside the plan ID must match the plan. The plan's currency is used unless one was given explicitly.
+func (s *subscriptionService) applyPlan(ctx context.Context, input *dto.CreateSubscriptionInput) error {
+	plan, err := s.planRepo.GetByID(ctx, *input.PlanID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "CreateSubscription: plan not found", "planID", *input.PlanID)
+			return notFound(fmt.Errorf("plan with ID %d not found", *input.PlanID))
+		}
+		slog.ErrorContext(ctx, "CreateSubscription: failed to retrieve plan", "planID", *input.PlanID, "error", err)
+		return contextAware(fmt.Errorf("failed to retrieve plan: %w", err))
+	}
+	if !plan.IsActive {
+		slog.WarnContext(ctx, "CreateSubscription: plan is not active", "planID", plan.ID)
+		return invalid(fmt.Errorf("invalid plan: plan with ID %d is not active", plan.ID))
+	}
+	if input.PlanName != "" && input.PlanName != plan.Name {
+		slog.WarnContext(ctx, "CreateSubscription: plan name does not match plan ID", "planID", plan.ID, "planName", input.PlanName)
+		return invalid(fmt.Errorf("invalid plan: plan name '%s' does not match plan with ID %d ('%s')", input.PlanName, plan.ID, plan.Name))
+	}
+
+	input.PlanName = plan.Name
+	input.DurationUnit = plan.DurationUnit
+	input.DurationValue = plan.DurationValue
+	price := plan.Price
+	input.Price = &price
+	if (input.Currency == nil || strings.TrimSpace(*input.Currency) == "") && plan.Currency != "" {
+		currency := plan.Currency
+		input.Currency = &currency
+	}
+	return nil
+}
+
+// getRedeemablePromoCode looks up a promo code and checks that it is active and within its validity window.
+// The usage limit is checked here as well, but only enforced atomically when the subscription is created.
+func (s *subscriptionService) getRedeemablePromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	code = normalizePromoCode(code)
+	promoCode, err := s.promoRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "CreateSubscription: promo code does not exist", "promoCode", code)
+			return nil, invalid(fmt.Errorf("invalid promo code: '%s' does not exist", code))
+		}
+		slog.ErrorContext(ctx, "CreateSubscription: failed to retrieve promo code", "promoCode", code, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to retrieve promo code: %w", err))
+	}
+	if err := checkPromoCodeRedeemable(promoCode, time.Now()); err != nil {
+		slog.WarnContext(ctx, "CreateSubscription: promo code cannot be redeemed", "promoCode", code, "error", err)
+		return nil, err
+	}
+	return promoCode, nil
+}
+
+// findIdempotentSubscription looks up a subscription previously created by the user with the given idempotency key.
+// It returns nil without error when no such subscription exists, and a conflict error when the key
+// was used with a different payload.
+func (s *subscriptionService) findIdempotentSubscription(ctx context.Context, userID uuid.UUID, idempotencyKey, fingerprint string) (*models.Subscription, error) {
+	existing, err := s.subRepo.GetByUserIDAndIdempotencyKey(ctx, userID, idempotencyKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		slog.ErrorContext(ctx, "CreateSubscription: failed to look up subscription by idempotency key", "userID", userID, "idempotencyKey", idempotencyKey, "error", err)
+		return nil, contextAware(fmt.Errorf("could not check idempotency key: %w", err))
+	}
+	if existing.IdempotencyHash != fingerprint {
+		slog.WarnContext(ctx, "CreateSubscription: idempotency key reused with a different payload", "userID", userID, "idempotencyKey", idempotencyKey, "subscriptionID", existing.ID)
+		return nil, conflict(fmt.Errorf("subscription with idempotency key '%s' already exists with a different payload", idempotencyKey))
+	}
+	slog.InfoContext(ctx, "CreateSubscription: returning existing subscription for idempotency key", "userID", userID, "idempotencyKey", idempotencyKey, "subscriptionID", existing.ID)
+	return existing, nil
 }
 
 // GetSubscriptionByID retrieves a subscription by its ID.
-// The requestingUserID is used for authorization checks.
-func (s *subscriptionService) GetSubscriptionByID(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) (*models.Subscription, error) {
-	slog.InfoContext(ctx, "GetSubscriptionByID: attempting to get subscription", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID)
+// The requestingUserID and requestingUserRole are used for authorization checks; admins may view any subscription.
+func (s *subscriptionService) GetSubscriptionByID(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*models.Subscription, error) {
+	slog.InfoContext(ctx, "GetSubscriptionByID: attempting to get subscription", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID, "requestingUserRole", requestingUserRole)
 
 	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "GetSubscriptionByID: subscription not found", "subscriptionID", subscriptionID)
-			return nil, fmt.Errorf("subscription with ID %s not found: %w", subscriptionID, err)
+			return nil, notFound(fmt.Errorf("subscription with ID %s not found: %w", subscriptionID, err))
 		}
 		slog.ErrorContext(ctx, "GetSubscriptionByID: failed to get subscription from repo", "subscriptionID", subscriptionID, "error", err)
-		return nil, fmt.Errorf("could not retrieve subscription: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription: %w", err))
 	}
 
-	if sub.UserID != requestingUserID {
-		// TODO: Implement role-based access control for administrators.
+	if sub.UserID != requestingUserID && !requestingUserRole.IsAdmin() {
 		slog.WarnContext(ctx, "GetSubscriptionByID: user not authorized to view this subscription", "subscriptionID", subscriptionID, "subscriptionUserID", sub.UserID, "requestingUserID", requestingUserID)
-		return nil, fmt.Errorf("user not authorized to view subscription %s", subscriptionID)
+		return nil, unauthorized(fmt.Errorf("user not authorized to view subscription %s", subscriptionID))
 	}
 
+	warnInvalidDurationUnits(ctx, "GetSubscriptionByID", *sub)
 	slog.InfoContext(ctx, "GetSubscriptionByID: subscription retrieved successfully", "subscriptionID", sub.ID)
 	return sub, nil
 }
 
-// ListUserSubscriptions retrieves a paginated list of subscriptions for a specific user.
-func (s *subscriptionService) ListUserSubscriptions(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Subscription, int64, error) {
-	slog.InfoContext(ctx, "ListUserSubscriptions: listing subscriptions for user", "userID", userID, "page", page, "pageSize", pageSize)
+// ListUserSubscriptions retrieves a paginated list of subscriptions for a specific user,
+// optionally filtered by status, payment status and plan name and sorted by a whitelisted date column.
+func (s *subscriptionService) ListUserSubscriptions(ctx context.Context, userID uuid.UUID, params dto.ListUserSubscriptionsParams) ([]models.Subscription, int64, error) {
+	slog.InfoContext(ctx, "ListUserSubscriptions: listing subscriptions for user", "userID", userID, "params", fmt.Sprintf("%+v", params))
 
-	// Apply default pagination parameters if necessary.
-	if page < 1 {
-		page = 1
+	if params.Status != nil && !params.Status.IsValid() {
+		slog.WarnContext(ctx, "ListUserSubscriptions: invalid status filter", "userID", userID, "status", *params.Status)
+		return nil, 0, invalid(fmt.Errorf("invalid subscription status filter: %s", *params.Status))
 	}
-	if pageSize < 1 {
-		pageSize = defaultPageSize
+	if params.PlanName != nil {
+		planName := s.planNames.resolve(*params.PlanName)
+		params.PlanName = &planName
 	}
-	if pageSize > maxPageSize {
-		pageSize = maxPageSize
+
+	// Apply default pagination parameters if necessary.
+	params.Page, params.PageSize = normalizePage(params.Page, params.PageSize)
+
+	repoParams := customTypes.ListUserSubscriptionsParams{
+		Offset:        (params.Page - 1) * params.PageSize,
+		Limit:         params.PageSize,
+		Status:        params.Status,
+		PaymentStatus: params.PaymentStatus,
+		PlanName:      params.PlanName,
+		SortBy:        params.SortBy,
+		SortOrder:     params.SortOrder,
 	}
-	offset := (page - 1) * pageSize
 
-	subs, totalCount, err := s.subRepo.ListByUserID(ctx, userID, offset, pageSize)
+	subs, totalCount, err := s.subRepo.ListByUserID(ctx, userID, repoParams)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListUserSubscriptions: failed to list subscriptions from repo", "userID", userID, "error", err)
-		return nil, 0, fmt.Errorf("could not retrieve user subscriptions: %w", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve user subscriptions: %w", err))
 	}
+	warnInvalidDurationUnits(ctx, "ListUserSubscriptions", subs...)
 	slog.InfoContext(ctx, "ListUserSubscriptions: subscriptions listed successfully", "userID", userID, "count", len(subs), "totalCount", totalCount)
 	return subs, totalCount, nil
 }
 
+// ListAllUserSubscriptions retrieves every subscription of a user, fetching them from the repository in pages.
+func (s *subscriptionService) ListAllUserSubscriptions(ctx context.Context, userID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) ([]models.Subscription, error) {
+	if userID != requestingUserID && !requestingUserRole.IsAdmin() {
+		slog.WarnContext(ctx, "ListAllUserSubscriptions: user not authorized to view subscriptions", "userID", userID, "requestingUserID", requestingUserID)
+		return nil, unauthorized(fmt.Errorf("user not authorized to view subscriptions of user %s", userID))
+	}
+
+	var all []models.Subscription
+	for offset := 0; ; offset += maxPageSize {
+		subs, totalCount, err := s.subRepo.ListByUserID(ctx, userID, customTypes.ListUserSubscriptionsParams{Offset: offset, Limit: maxPageSize})
+		if err != nil {
+			slog.ErrorContext(ctx, "ListAllUserSubscriptions: failed to list subscriptions from repo", "userID", userID, "offset", offset, "error", err)
+			return nil, contextAware(fmt.Errorf("could not retrieve user subscriptions: %w", err))
+		}
+		all = append(all, subs...)
+		if len(subs) < maxPageSize || int64(len(all)) >= totalCount {
+			break
+		}
+	}
+
+	warnInvalidDurationUnits(ctx, "ListAllUserSubscriptions", all...)
+	slog.InfoContext(ctx, "ListAllUserSubscriptions: subscriptions listed successfully", "userID", userID, "count", len(all))
+	return all, nil
+}
+
 // CancelSubscription handles the cancellation of a subscription.
 // This typically involves disabling auto-renewal and potentially deactivating the subscription.
-// The requestingUserID is used for authorization.
-func (s *subscriptionService) CancelSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) (*models.Subscription, error) {
-	slog.InfoContext(ctx, "CancelSubscription: attempting to cancel subscription", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID)
+// The requestingUserID and requestingUserRole are used for authorization; admins may cancel any subscription.
+func (s *subscriptionService) CancelSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*models.Subscription, error) {
+	slog.InfoContext(ctx, "CancelSubscription: attempting to cancel subscription", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID, "requestingUserRole", requestingUserRole)
 
 	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("subscription %s not found: %w", subscriptionID, err)
+			return nil, notFound(fmt.Errorf("subscription %s not found: %w", subscriptionID, err))
 		}
-		return nil, fmt.Errorf("could not retrieve subscription to cancel: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription to cancel: %w", err))
 	}
 
-	// Authorization check.
-	if sub.UserID != requestingUserID {
-		// TODO: Implement role-based access control for administrators.
-		return nil, fmt.Errorf("user not authorized to cancel subscription %s", subscriptionID)
+	// Authorization check: only the owner or an administrator may cancel.
+	if sub.UserID != requestingUserID && !requestingUserRole.IsAdmin() {
+		return nil, unauthorized(fmt.Errorf("user not authorized to cancel subscription %s", subscriptionID))
 	}
 
 	if !sub.IsActive && sub.EndDate.Before(time.Now()) {
 		slog.InfoContext(ctx, "CancelSubscription: subscription already inactive and ended", "subscriptionID", subscriptionID)
 	}
 
+	wasAutoRenew := sub.AutoRenew
 	sub.AutoRenew = false
 
-	if err := s.subRepo.Update(ctx, sub); err != nil {
+	event := newSubscriptionEvent(customTypes.SubscriptionEventCancelled, &requestingUserID,
+		map[string]any{"auto_renew": wasAutoRenew}, map[string]any{"auto_renew": false})
+	if err := s.subRepo.Update(ctx, sub, event); err != nil {
 		slog.ErrorContext(ctx, "CancelSubscription: failed to update subscription for cancellation", "subscriptionID", subscriptionID, "error", err)
-		return nil, fmt.Errorf("could not save subscription cancellation: %w", err)
+		return nil, contextAware(fmt.Errorf("could not save subscription cancellation: %w", err))
 	}
 
 	slog.InfoContext(ctx, "CancelSubscription: subscription cancelled (auto-renew disabled)", "subscriptionID", sub.ID)
+	if wasAutoRenew {
+		s.publish(ctx, events.SubscriptionCancelled{
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			CancelledBy:    requestingUserID,
+			EndDate:        sub.EndDate,
+			OccurredAt:     time.Now(),
+		})
+	}
+	return sub, nil
+}
+
+// MarkExpiryNotified sets the subscription's LastExpiryNotifiedAt to the current time.
+// It is called by whatever sends expiry reminders, so clients can show that the user has been notified.
+func (s *subscriptionService) MarkExpiryNotified(ctx context.Context, subscriptionID uuid.UUID) (*models.Subscription, error) {
+	slog.InfoContext(ctx, "MarkExpiryNotified: attempting to record expiry reminder", "subscriptionID", subscriptionID)
+	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "MarkExpiryNotified: subscription not found", "subscriptionID", subscriptionID)
+			return nil, notFound(fmt.Errorf("subscription with ID '%s' not found: %w", subscriptionID, err))
+		}
+		slog.ErrorContext(ctx, "MarkExpiryNotified: failed to retrieve subscription", "subscriptionID", subscriptionID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription: %w", err))
+	}
+
+	now := time.Now()
+	event := newSubscriptionEvent(customTypes.SubscriptionEventExpiryNotified, nil,
+		map[string]any{"last_expiry_notified_at": sub.LastExpiryNotifiedAt}, map[string]any{"last_expiry_notified_at": now})
+	sub.LastExpiryNotifiedAt = &now
+	if err := s.subRepo.Update(ctx, sub, event); err != nil {
+		slog.ErrorContext(ctx, "MarkExpiryNotified: failed to save subscription", "subscriptionID", subscriptionID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not record expiry reminder: %w", err))
+	}
+	slog.InfoContext(ctx, "MarkExpiryNotified: expiry reminder recorded", "subscriptionID", sub.ID, "notifiedAt", now)
 	return sub, nil
 }
 
 // UpdatePaymentStatus updates the payment status of a subscription.
-// This might be invoked by a payment gateway or an administrator.
-func (s *subscriptionService) UpdatePaymentStatus(ctx context.Context, subscriptionID uuid.UUID, paymentStatus string) (*models.Subscription, error) {
+// This might be invoked by a payment gateway or an administrator; actorUserID is nil when no user is authenticated.
+func (s *subscriptionService) UpdatePaymentStatus(ctx context.Context, subscriptionID uuid.UUID, paymentStatus string, actorUserID *uuid.UUID) (*models.Subscription, error) {
 	slog.InfoContext(ctx, "UpdatePaymentStatus: attempting to update payment status", "subscriptionID", subscriptionID, "newStatus", paymentStatus)
 	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
 	if err != nil {
-		return nil, fmt.Errorf("could not retrieve subscription to update payment status: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "UpdatePaymentStatus: subscription not found", "subscriptionID", subscriptionID)
+			return nil, notFound(fmt.Errorf("subscription %s not found: %w", subscriptionID, err))
+		}
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription to update payment status: %w", err))
 	}
 
+	oldStatus := sub.PaymentStatus
+	wasActive := sub.IsActive
 	sub.PaymentStatus = paymentStatus
-	if paymentStatus == "paid" && !sub.StartDate.After(time.Now()) && sub.EndDate.After(time.Now()) {
+	if activatesSubscription(paymentStatus) && !sub.StartDate.After(time.Now()) && sub.EndDate.After(time.Now()) {
 		sub.IsActive = true
 	} else if paymentStatus == "failed" || paymentStatus == "refunded" {
 		sub.IsActive = false
 	}
 
-	if err := s.subRepo.Update(ctx, sub); err != nil {
+	event := newSubscriptionEvent(customTypes.SubscriptionEventPaymentUpdated, actorUserID,
+		map[string]any{"payment_status": oldStatus, "is_active": wasActive},
+		map[string]any{"payment_status": sub.PaymentStatus, "is_active": sub.IsActive})
+	if err := s.subRepo.Update(ctx, sub, event); err != nil {
 		slog.ErrorContext(ctx, "UpdatePaymentStatus: failed to save subscription payment status", "subscriptionID", subscriptionID, "error", err)
-		return nil, fmt.Errorf("could not save subscription payment status: %w", err)
+		return nil, contextAware(fmt.Errorf("could not save subscription payment status: %w", err))
 	}
 	slog.InfoContext(ctx, "UpdatePaymentStatus: payment status updated", "subscriptionID", sub.ID, "newStatus", sub.PaymentStatus)
+	if oldStatus != sub.PaymentStatus {
+		s.publish(ctx, events.PaymentStatusChanged{
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			OldStatus:      oldStatus,
+			NewStatus:      sub.PaymentStatus,
+			IsActive:       sub.IsActive,
+			OccurredAt:     time.Now(),
+		})
+	}
 	return sub, nil
 }
 
+// ApplyPayment marks a subscription as paid by the payment provider's payment input.ExternalPaymentID, stores the
+// payment ID and activates the subscription if it is within its term (later ones are activated by the activation job).
+// The subscription is locked while the payment is applied, so a payment delivered more than once is applied once;
+// replays return the subscription with applied set to false. A payment that does not cover the subscription's
+// price, is in another currency, or targets a subscription already paid by a different payment is rejected.
+func (s *subscriptionService) ApplyPayment(ctx context.Context, input dto.ApplyPaymentInput) (*models.Subscription, bool, error) {
+	slog.InfoContext(ctx, "ApplyPayment: attempting to apply payment", "subscriptionID", input.SubscriptionID, "externalPaymentID", input.ExternalPaymentID, "amount", input.Amount, "currency", input.Currency)
+
+	externalPaymentID := strings.TrimSpace(input.ExternalPaymentID)
+	if externalPaymentID == "" {
+		return nil, false, invalid(errors.New("external payment ID is required"))
+	}
+	if input.Amount < 0 {
+		return nil, false, invalid(errors.New("payment amount cannot be negative"))
+	}
+
+	var sub *models.Subscription
+	var oldStatus string
+	applied := false
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		paid, err := s.subRepo.GetByExternalPaymentID(ctx, externalPaymentID)
+		if err == nil {
+			if paid.ID != input.SubscriptionID {
+				return conflict(fmt.Errorf("payment '%s' was already applied to another subscription", externalPaymentID))
+			}
+			sub = paid
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return contextAware(fmt.Errorf("could not look up payment: %w", err))
+		}
+
+		sub, err = s.subRepo.GetByIDForUpdate(ctx, input.SubscriptionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFound(fmt.Errorf("subscription %s not found: %w", input.SubscriptionID, err))
+			}
+			return contextAware(fmt.Errorf("could not retrieve subscription to apply payment: %w", err))
+		}
+		if sub.ExternalPaymentID != nil {
+			// A concurrent delivery of the same payment may have been applied while waiting for the lock.
+			if *sub.ExternalPaymentID == externalPaymentID {
+				return nil
+			}
+			return conflict(fmt.Errorf("subscription %s was already paid by payment '%s'", sub.ID, *sub.ExternalPaymentID))
+		}
+		// Renewals clear the payment of the previous period, so a redelivered payment for that period
+		// would otherwise pay for the renewal.
+		if sub.LastRenewedAt != nil && !input.PaidAt.IsZero() && input.PaidAt.Before(*sub.LastRenewedAt) {
+			return conflict(fmt.Errorf("payment '%s' was made before subscription %s was renewed", externalPaymentID, sub.ID))
+		}
+		currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+		if currency != "" && sub.Currency != "" && currency != sub.Currency {
+			return invalid(fmt.Errorf("payment currency '%s' does not match subscription currency '%s'", currency, sub.Currency))
+		}
+		if input.Amount < sub.Price {
+			return invalid(fmt.Errorf("payment amount %.2f does not cover subscription price %.2f", input.Amount, sub.Price))
+		}
+
+		oldStatus = sub.PaymentStatus
+		wasActive := sub.IsActive
+		sub.PaymentStatus = "paid"
+		sub.ExternalPaymentID = &externalPaymentID
+		now := time.Now()
+		if !sub.StartDate.After(now) && sub.EndDate.After(now) {
+			sub.IsActive = true
+		}
+
+		newValue := map[string]any{"payment_status": sub.PaymentStatus, "is_active": sub.IsActive, "external_payment_id": externalPaymentID, "amount": input.Amount}
+		if currency != "" {
+			newValue["currency"] = currency
+		}
+		if !input.PaidAt.IsZero() {
+			newValue["paid_at"] = input.PaidAt
+		}
+		event := newSubscriptionEvent(customTypes.SubscriptionEventPaymentReceived, nil,
+			map[string]any{"payment_status": oldStatus, "is_active": wasActive}, newValue)
+		if err := s.subRepo.Update(ctx, sub, event); err != nil {
+			if isDuplicateKeyError(err) {
+				return conflict(fmt.Errorf("payment '%s' was already applied to another subscription: %w", externalPaymentID, err))
+			}
+			return contextAware(fmt.Errorf("could not save subscription payment: %w", err))
+		}
+		applied = true
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "ApplyPayment: payment not applied", "subscriptionID", input.SubscriptionID, "externalPaymentID", externalPaymentID, "error", err)
+		return nil, false, err
+	}
+
+	if !applied {
+		slog.InfoContext(ctx, "ApplyPayment: payment already applied", "subscriptionID", sub.ID, "externalPaymentID", externalPaymentID)
+		return sub, false, nil
+	}
+	slog.InfoContext(ctx, "ApplyPayment: payment applied", "subscriptionID", sub.ID, "externalPaymentID", externalPaymentID, "isActive", sub.IsActive)
+	if oldStatus != sub.PaymentStatus {
+		s.publish(ctx, events.PaymentStatusChanged{
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			OldStatus:      oldStatus,
+			NewStatus:      sub.PaymentStatus,
+			IsActive:       sub.IsActive,
+			OccurredAt:     time.Now(),
+		})
+	}
+	return sub, true, nil
+}
+
 // SetAutoRenew sets the auto-renewal flag for a subscription.
-// The requestingUserID is used for authorization.
-func (s *subscriptionService) SetAutoRenew(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, autoRenew bool) (*models.Subscription, error) {
-	slog.InfoContext(ctx, "SetAutoRenew: setting auto-renew status", "subscriptionID", subscriptionID, "autoRenew", autoRenew, "requestingUserID", requestingUserID)
+// The requestingUserID and requestingUserRole are used for authorization; admins may modify any subscription.
+func (s *subscriptionService) SetAutoRenew(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, autoRenew bool) (*models.Subscription, error) {
+	slog.InfoContext(ctx, "SetAutoRenew: setting auto-renew status", "subscriptionID", subscriptionID, "autoRenew", autoRenew, "requestingUserID", requestingUserID, "requestingUserRole", requestingUserRole)
 	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
 	if err != nil {
-		return nil, fmt.Errorf("could not retrieve subscription: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "SetAutoRenew: subscription not found", "subscriptionID", subscriptionID)
+			return nil, notFound(fmt.Errorf("subscription %s not found: %w", subscriptionID, err))
+		}
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription: %w", err))
 	}
 
-	// Authorization check.
-	if sub.UserID != requestingUserID {
-		// TODO: Implement role-based access control for administrators.
-		return nil, fmt.Errorf("user not authorized to set auto-renew for subscription %s", subscriptionID)
+	// Authorization check: only the owner or an administrator may change auto-renewal.
+	if sub.UserID != requestingUserID && !requestingUserRole.IsAdmin() {
+		return nil, unauthorized(fmt.Errorf("user not authorized to set auto-renew for subscription %s", subscriptionID))
 	}
 
 	if sub.AutoRenew == autoRenew {
@@ -233,14 +696,129 @@ func (s *subscriptionService) SetAutoRenew(ctx context.Context, subscriptionID u
 	}
 
 	sub.AutoRenew = autoRenew
-	if err := s.subRepo.Update(ctx, sub); err != nil {
+	event := newSubscriptionEvent(customTypes.SubscriptionEventAutoRenewChanged, &requestingUserID,
+		map[string]any{"auto_renew": !autoRenew}, map[string]any{"auto_renew": autoRenew})
+	if err := s.subRepo.Update(ctx, sub, event); err != nil {
 		slog.ErrorContext(ctx, "SetAutoRenew: failed to update auto-renew status", "subscriptionID", subscriptionID, "error", err)
-		return nil, fmt.Errorf("could not save auto-renew status: %w", err)
+		return nil, contextAware(fmt.Errorf("could not save auto-renew status: %w", err))
 	}
 	slog.InfoContext(ctx, "SetAutoRenew: auto-renew status updated", "subscriptionID", sub.ID, "autoRenew", sub.AutoRenew)
 	return sub, nil
 }
 
+// RecordUsage adds a usage increment to the subscription's billing period containing input.OccurredAt
+// (now if omitted). Increments for the same metric and period are summed atomically.
+func (s *subscriptionService) RecordUsage(ctx context.Context, subscriptionID uuid.UUID, input dto.RecordUsageInput) (*models.SubscriptionUsage, error) {
+	metric := strings.ToLower(strings.TrimSpace(input.Metric))
+	slog.InfoContext(ctx, "RecordUsage: recording usage", "subscriptionID", subscriptionID, "metric", metric, "quantity", input.Quantity)
+
+	if !isValidUsageMetric(metric) {
+		return nil, invalid(fmt.Errorf("invalid metric '%s': use up to %d lower-case letters, digits, '_', '.' or '-'", input.Metric, maxUsageMetricLength))
+	}
+	if input.Quantity <= 0 {
+		return nil, invalid(errors.New("usage quantity must be positive"))
+	}
+
+	subscription, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RecordUsage: subscription not found", "subscriptionID", subscriptionID)
+			return nil, notFound(fmt.Errorf("subscription with ID %s not found", subscriptionID))
+		}
+		slog.ErrorContext(ctx, "RecordUsage: failed to retrieve subscription", "subscriptionID", subscriptionID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription: %w", err))
+	}
+
+	occurredAt := time.Now()
+	if input.OccurredAt != nil {
+		occurredAt = *input.OccurredAt
+	}
+	periodStart, periodEnd, ok := usagePeriod(subscription, occurredAt)
+	if !ok {
+		slog.WarnContext(ctx, "RecordUsage: usage outside the subscription term", "subscriptionID", subscriptionID, "occurredAt", occurredAt)
+		return nil, invalid(fmt.Errorf("usage at %s is outside the subscription term (%s to %s)",
+			occurredAt.Format(time.RFC3339), subscription.StartDate.Format(time.RFC3339), subscription.EndDate.Format(time.RFC3339)))
+	}
+
+	usage := &models.SubscriptionUsage{
+		SubscriptionID: subscriptionID,
+		Metric:         metric,
+		Quantity:       input.Quantity,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+	}
+	if err := s.subRepo.AddUsage(ctx, usage); err != nil {
+		slog.ErrorContext(ctx, "RecordUsage: failed to save usage", "subscriptionID", subscriptionID, "metric", metric, "error", err)
+		return nil, contextAware(fmt.Errorf("could not record usage: %w", err))
+	}
+
+	slog.InfoContext(ctx, "RecordUsage: usage recorded", "subscriptionID", subscriptionID, "metric", metric, "periodStart", periodStart, "total", usage.Quantity)
+	return usage, nil
+}
+
+// GetUsageReport summarizes the usage recorded for a subscription per billing period, oldest period first.
+// Periods without usage are omitted.
+func (s *subscriptionService) GetUsageReport(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole) (*dto.UsageReport, error) {
+	// Resolves the subscription and enforces the same access rules as viewing it directly.
+	if _, err := s.GetSubscriptionByID(ctx, subscriptionID, requestingUserID, requestingUserRole); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.subRepo.ListUsage(ctx, subscriptionID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUsageReport: failed to list usage from repo", "subscriptionID", subscriptionID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve subscription usage: %w", err))
+	}
+
+	report := &dto.UsageReport{SubscriptionID: subscriptionID, Periods: []dto.UsagePeriod{}}
+	for _, u := range usage {
+		// Rows are ordered by period, so a new period starts whenever the start date changes.
+		if n := len(report.Periods); n == 0 || !report.Periods[n-1].PeriodStart.Equal(u.PeriodStart) {
+			report.Periods = append(report.Periods, dto.UsagePeriod{
+				PeriodStart: u.PeriodStart,
+				PeriodEnd:   u.PeriodEnd,
+				Metrics:     make(map[string]int64),
+			})
+		}
+		report.Periods[len(report.Periods)-1].Metrics[u.Metric] += u.Quantity
+	}
+
+	slog.InfoContext(ctx, "GetUsageReport: usage report generated", "subscriptionID", subscriptionID, "periods", len(report.Periods))
+	return report, nil
+}
+
+// ListSubscriptionEvents retrieves a paginated list of a subscription's history events in the order they happened.
+// The requestingUserID and requestingUserRole are used for authorization: owners and admins may view the history.
+func (s *subscriptionService) ListSubscriptionEvents(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID, requestingUserRole customTypes.UserRole, page, pageSize int) ([]models.SubscriptionEvent, int64, error) {
+	slog.InfoContext(ctx, "ListSubscriptionEvents: listing subscription history", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID, "page", page, "pageSize", pageSize)
+
+	sub, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "ListSubscriptionEvents: subscription not found", "subscriptionID", subscriptionID)
+			return nil, 0, notFound(fmt.Errorf("subscription with ID '%s' not found: %w", subscriptionID, err))
+		}
+		slog.ErrorContext(ctx, "ListSubscriptionEvents: failed to retrieve subscription", "subscriptionID", subscriptionID, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve subscription: %w", err))
+	}
+	if sub.UserID != requestingUserID && !requestingUserRole.IsAdmin() {
+		slog.WarnContext(ctx, "ListSubscriptionEvents: user not authorized to view subscription history", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID)
+		return nil, 0, unauthorized(fmt.Errorf("user not authorized to view history of subscription %s", subscriptionID))
+	}
+
+	page, pageSize = normalizePage(page, pageSize)
+	offset := (page - 1) * pageSize
+
+	subscriptionEvents, totalCount, err := s.subRepo.ListEvents(ctx, subscriptionID, offset, pageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListSubscriptionEvents: failed to list events from repo", "subscriptionID", subscriptionID, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve subscription history: %w", err))
+	}
+
+	slog.InfoContext(ctx, "ListSubscriptionEvents: history listed successfully", "subscriptionID", subscriptionID, "count", len(subscriptionEvents), "totalCount", totalCount)
+	return subscriptionEvents, totalCount, nil
+}
+
 // GetUsersWithExpiringSubscriptions retrieves users and their subscriptions that are nearing expiration.
 // The report is paginated based on the subscriptions, not directly on users.
 func (s *subscriptionService) GetUsersWithExpiringSubscriptions(ctx context.Context, daysInAdvance int, page, pageSize int) ([]dto.UserWithExpiringSubscriptions, int64, error) {
@@ -250,15 +828,7 @@ func (s *subscriptionService) GetUsersWithExpiringSubscriptions(ctx context.Cont
 		daysInAdvance = 0 // Consider subscriptions expiring from now onwards.
 	}
 	// Apply default pagination parameters.
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = defaultPageSize
-	}
-	if pageSize > maxPageSize {
-		pageSize = maxPageSize
-	}
+	page, pageSize = normalizePage(page, pageSize)
 
 	now := time.Now()
 	thresholdDateFrom := now // Subscriptions expiring from the current moment.
@@ -269,7 +839,7 @@ func (s *subscriptionService) GetUsersWithExpiringSubscriptions(ctx context.Cont
 	expiringSubs, totalExpiringSubsCount, err := s.subRepo.ListExpiringSoon(ctx, thresholdDateFrom, thresholdDateTo, offset, pageSize)
 	if err != nil {
 		slog.ErrorContext(ctx, "GetUsersWithExpiringSubscriptions: failed to list expiring subscriptions", "error", err)
-		return nil, 0, fmt.Errorf("could not list expiring subscriptions: %w", err)
+		return nil, 0, contextAware(fmt.Errorf("could not list expiring subscriptions: %w", err))
 	}
 
 	if len(expiringSubs) == 0 {
@@ -290,7 +860,7 @@ func (s *subscriptionService) GetUsersWithExpiringSubscriptions(ctx context.Cont
 	users, err := s.userRepo.GetByIDs(ctx, uniqueUserIDs)
 	if err != nil {
 		slog.ErrorContext(ctx, "GetUsersWithExpiringSubscriptions: failed to get users by IDs", "error", err)
-		return nil, 0, fmt.Errorf("could not fetch users for expiring subscriptions: %w", err)
+		return nil, 0, contextAware(fmt.Errorf("could not fetch users for expiring subscriptions: %w", err))
 	}
 
 	// Group subscriptions by user for the report.
@@ -335,32 +905,89 @@ func (s *subscriptionService) GetUsersWithExpiringSubscriptions(ctx context.Cont
 	return finalReportData, totalExpiringSubsCount, nil
 }
 
+// GetChurnReport calculates subscription churn for the period [from, to).
+// Churned subscriptions are paid subscriptions that ended within the period without being renewed;
+// the churn rate is relative to the paid subscriptions active at the start of the period.
+func (s *subscriptionService) GetChurnReport(ctx context.Context, from, to time.Time) (*dto.ChurnReport, error) {
+	slog.InfoContext(ctx, "GetChurnReport: generating churn report", "from", from, "to", to)
+
+	if !from.Before(to) {
+		slog.WarnContext(ctx, "GetChurnReport: invalid period", "from", from, "to", to)
+		return nil, invalid(errors.New("invalid period: 'from' must be before 'to'"))
+	}
+
+	counts, err := s.subRepo.GetChurnCounts(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetChurnReport: failed to get churn counts from repo", "error", err)
+		return nil, contextAware(fmt.Errorf("could not calculate churn: %w", err))
+	}
+
+	report := &dto.ChurnReport{
+		From:          from,
+		To:            to,
+		ActiveAtStart: counts.ActiveAtStart,
+		Renewed:       counts.Renewed,
+		Expired:       counts.Expired,
+		Cancelled:     counts.Cancelled,
+		Churned:       counts.Expired + counts.Cancelled,
+	}
+	if report.ActiveAtStart > 0 {
+		report.ChurnRate = float64(report.Churned) / float64(report.ActiveAtStart)
+	}
+
+	slog.InfoContext(ctx, "GetChurnReport: churn report generated", "activeAtStart", report.ActiveAtStart, "churned", report.Churned, "churnRate", report.ChurnRate)
+	return report, nil
+}
+
+// GetSubscriptionsByCurrency reports the subscriptions created within [from, to) per currency.
+// Revenue only includes paid subscriptions and is never converted between currencies.
+func (s *subscriptionService) GetSubscriptionsByCurrency(ctx context.Context, from, to time.Time) (*dto.SubscriptionsByCurrency, error) {
+	slog.InfoContext(ctx, "GetSubscriptionsByCurrency: generating currency report", "from", from, "to", to)
+
+	if !from.Before(to) {
+		slog.WarnContext(ctx, "GetSubscriptionsByCurrency: invalid period", "from", from, "to", to)
+		return nil, invalid(errors.New("invalid period: 'from' must be before 'to'"))
+	}
+
+	totals, err := s.subRepo.GetCurrencyTotals(ctx, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetSubscriptionsByCurrency: failed to get currency totals from repo", "error", err)
+		return nil, contextAware(fmt.Errorf("could not aggregate subscriptions by currency: %w", err))
+	}
+
+	report := &dto.SubscriptionsByCurrency{
+		From:       from,
+		To:         to,
+		Currencies: totals,
+	}
+	for _, t := range totals {
+		report.Subscriptions += t.Subscriptions
+	}
+
+	slog.InfoContext(ctx, "GetSubscriptionsByCurrency: currency report generated", "subscriptions", report.Subscriptions, "currencies", len(totals))
+	return report, nil
+}
+
 // ListActiveSubscriptionsByPlan retrieves a paginated list of active subscriptions for a specific plan name.
 func (s *subscriptionService) ListActiveSubscriptionsByPlan(ctx context.Context, planName string, page, pageSize int) ([]models.Subscription, int64, error) {
 	slog.InfoContext(ctx, "ListActiveSubscriptionsByPlan: listing active subscriptions", "planName", planName, "page", page, "pageSize", pageSize)
 
 	if strings.TrimSpace(planName) == "" {
-		return nil, 0, errors.New("plan name cannot be empty")
+		return nil, 0, invalid(errors.New("plan name cannot be empty"))
 	}
+	planName = s.planNames.resolve(planName)
 
 	// Apply default pagination parameters.
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = defaultPageSize
-	}
-	if pageSize > maxPageSize {
-		pageSize = maxPageSize
-	}
+	page, pageSize = normalizePage(page, pageSize)
 	offset := (page - 1) * pageSize
 
 	subs, totalCount, err := s.subRepo.ListActiveByPlanName(ctx, planName, offset, pageSize)
 	if err != nil {
 		slog.ErrorContext(ctx, "ListActiveSubscriptionsByPlan: failed to list subscriptions from repo", "planName", planName, "error", err)
-		return nil, 0, fmt.Errorf("could not retrieve active subscriptions for plan '%s': %w", planName, err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve active subscriptions for plan '%s': %w", planName, err))
 	}
 
+	warnInvalidDurationUnits(ctx, "ListActiveSubscriptionsByPlan", subs...)
 	slog.InfoContext(ctx, "ListActiveSubscriptionsByPlan: subscriptions listed successfully", "planName", planName, "count", len(subs), "totalCount", totalCount)
 	return subs, totalCount, nil
 }
@@ -371,8 +998,220 @@ func (s *subscriptionService) CheckUserActiveSubscription(ctx context.Context, u
 	hasActiveSub, err := s.subRepo.CheckUserActiveSubscription(ctx, userID)
 	if err != nil {
 		slog.ErrorContext(ctx, "CheckUserActiveSubscription: failed to check subscription status from repo", "userID", userID, "error", err)
-		return false, fmt.Errorf("could not check user's active subscription: %w", err)
+		return false, contextAware(fmt.Errorf("could not check user's active subscription: %w", err))
 	}
 	slog.InfoContext(ctx, "CheckUserActiveSubscription: status checked", "userID", userID, "hasActiveSubscription", hasActiveSub)
 	return hasActiveSub, nil
 }
+
+// GetUserSubscriptionStatus reports whether a user has an active subscription and which plans are active.
+func (s *subscriptionService) GetUserSubscriptionStatus(ctx context.Context, userID uuid.UUID) (*dto.UserSubscriptionStatus, error) {
+	slog.InfoContext(ctx, "GetUserSubscriptionStatus: checking subscription status", "userID", userID)
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetUserSubscriptionStatus: user not found", "userID", userID)
+			return nil, notFound(fmt.Errorf("user with ID %s not found", userID))
+		}
+		slog.ErrorContext(ctx, "GetUserSubscriptionStatus: failed to verify user", "userID", userID, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to verify user existence: %w", err))
+	}
+
+	planNames, err := s.subRepo.ListActivePlanNames(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserSubscriptionStatus: failed to list active plans from repo", "userID", userID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not check user's active subscriptions: %w", err))
+	}
+	status := &dto.UserSubscriptionStatus{
+		HasActiveSubscription: len(planNames) > 0,
+		ActivePlans:           planNames,
+	}
+	slog.InfoContext(ctx, "GetUserSubscriptionStatus: status checked", "userID", userID, "hasActiveSubscription", status.HasActiveSubscription, "activePlans", len(planNames))
+	return status, nil
+}
+
+// DeleteSubscription performs a soft delete on a subscription.
+// The requesting user's role is resolved from their stored record; only administrators may delete subscriptions.
+func (s *subscriptionService) DeleteSubscription(ctx context.Context, subscriptionID uuid.UUID, requestingUserID uuid.UUID) error {
+	slog.InfoContext(ctx, "DeleteSubscription: attempting to delete subscription", "subscriptionID", subscriptionID, "requestingUserID", requestingUserID)
+
+	// Authorization check: the requesting user's role is resolved from their stored record.
+	requestingUser, err := s.userRepo.GetByID(ctx, requestingUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "DeleteSubscription: requesting user not found", "requestingUserID", requestingUserID)
+			return unauthorized(fmt.Errorf("user %s not authorized to delete subscriptions", requestingUserID))
+		}
+		slog.ErrorContext(ctx, "DeleteSubscription: failed to retrieve requesting user", "requestingUserID", requestingUserID, "error", err)
+		return contextAware(fmt.Errorf("could not verify requesting user: %w", err))
+	}
+	if !requestingUser.Role.IsAdmin() {
+		slog.WarnContext(ctx, "DeleteSubscription: requesting user is not an administrator", "requestingUserID", requestingUserID, "requestingUserRole", requestingUser.Role)
+		return unauthorized(fmt.Errorf("user %s not authorized to delete subscriptions", requestingUserID))
+	}
+
+	event := newSubscriptionEvent(customTypes.SubscriptionEventDeleted, &requestingUserID, nil, nil)
+	if err := s.subRepo.Delete(ctx, subscriptionID, event); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "DeleteSubscription: subscription not found", "subscriptionID", subscriptionID)
+			return notFound(fmt.Errorf("subscription with ID '%s' not found: %w", subscriptionID, err))
+		}
+		slog.ErrorContext(ctx, "DeleteSubscription: failed to delete subscription in repository", "subscriptionID", subscriptionID, "error", err)
+		return contextAware(fmt.Errorf("failed to delete subscription: %w", err))
+	}
+
+	slog.InfoContext(ctx, "DeleteSubscription: subscription deleted successfully", "subscriptionID", subscriptionID)
+	return nil
+}
+
+// ListSubscriptions retrieves a paginated list of subscriptions across all users, optionally filtered
+// by payment status and active flag.
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, filters customTypes.ListSubscriptionsFilters, page, pageSize int) ([]models.Subscription, int64, error) {
+	slog.InfoContext(ctx, "ListSubscriptions: listing subscriptions", "paymentStatus", filters.PaymentStatus, "isActive", filters.IsActive, "page", page, "pageSize", pageSize)
+
+	// Apply default pagination parameters.
+	page, pageSize = normalizePage(page, pageSize)
+	offset := (page - 1) * pageSize
+
+	subs, totalCount, err := s.subRepo.List(ctx, offset, pageSize, filters)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListSubscriptions: failed to list subscriptions from repo", "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve subscriptions: %w", err))
+	}
+
+	warnInvalidDurationUnits(ctx, "ListSubscriptions", subs...)
+	slog.InfoContext(ctx, "ListSubscriptions: subscriptions listed successfully", "count", len(subs), "totalCount", totalCount)
+	return subs, totalCount, nil
+}
+
+// ProcessRenewals runs the auto-renewal job; it is the entry point for the renewal worker.
+func (s *subscriptionService) ProcessRenewals(ctx context.Context) error {
+	_, err := s.RunRenewals(ctx)
+	return err
+}
+
+// ProcessActivations activates paid subscriptions whose start date has arrived; it is the entry point
+// for the activation worker. Each activation is recorded as an "activated" event. Failures for individual
+// subscriptions are logged and skipped, so they are retried on the next run.
+func (s *subscriptionService) ProcessActivations(ctx context.Context) error {
+	now := time.Now()
+	candidates, err := s.subRepo.ListActivationCandidates(ctx, now, activationBatchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ProcessActivations: failed to list activation candidates", "error", err)
+		return contextAware(fmt.Errorf("could not list activation candidates: %w", err))
+	}
+
+	activatedCount := 0
+	for i := range candidates {
+		sub := &candidates[i]
+		sub.IsActive = true
+		event := newSubscriptionEvent(customTypes.SubscriptionEventActivated, nil,
+			map[string]any{"is_active": false}, map[string]any{"is_active": true})
+		if err := s.subRepo.Update(ctx, sub, event); err != nil {
+			slog.ErrorContext(ctx, "ProcessActivations: failed to activate subscription", "subscriptionID", sub.ID, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "ProcessActivations: subscription activated", "subscriptionID", sub.ID, "userID", sub.UserID, "startDate", sub.StartDate)
+		activatedCount++
+	}
+
+	if len(candidates) > 0 {
+		slog.InfoContext(ctx, "ProcessActivations: activation run completed", "candidates", len(candidates), "activated", activatedCount)
+	}
+	return nil
+}
+
+// RunRenewals finds active, paid, auto-renewing subscriptions that expire within the configured renewal window
+// and extends each one in place by another period, counted from its current end date, with a pending payment.
+// The extension is recorded in the subscription's history and published as a renewal event for the payment system.
+// An extended subscription awaits payment and ends later, so re-running the job does not extend it again.
+// Renewals still unpaid after the configured payment grace period are then deactivated; a late payment
+// reactivates them. Failures for individual subscriptions are logged and skipped.
+func (s *subscriptionService) RunRenewals(ctx context.Context) (*dto.RenewalRunSummary, error) {
+	now := time.Now()
+	windowEnd := now.Add(s.cfg.RenewalWindow)
+	slog.InfoContext(ctx, "ProcessRenewals: looking for subscriptions to renew", "windowStart", now, "windowEnd", windowEnd)
+
+	candidates, err := s.subRepo.ListRenewalCandidates(ctx, now, windowEnd, s.cfg.RenewalBatchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ProcessRenewals: failed to list renewal candidates", "error", err)
+		return nil, contextAware(fmt.Errorf("could not list renewal candidates: %w", err))
+	}
+
+	renewedCount, skippedCount := 0, 0
+	for i := range candidates {
+		sub := &candidates[i]
+		previousEndDate, previousPaymentStatus := sub.EndDate, sub.PaymentStatus
+
+		newEndDate, err := renewalEndDate(sub)
+		if err != nil {
+			slog.ErrorContext(ctx, "ProcessRenewals: failed to compute renewal end date", "subscriptionID", sub.ID, "error", err)
+			skippedCount++
+			continue
+		}
+
+		renewedEvent := newSubscriptionEvent(customTypes.SubscriptionEventRenewed, nil,
+			map[string]any{"end_date": previousEndDate, "payment_status": previousPaymentStatus},
+			map[string]any{"end_date": newEndDate, "payment_status": "pending"})
+		if err := s.subRepo.ExtendForRenewal(ctx, sub, newEndDate, renewedEvent); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.InfoContext(ctx, "ProcessRenewals: subscription already renewed or changed, skipping", "subscriptionID", sub.ID)
+			} else {
+				slog.ErrorContext(ctx, "ProcessRenewals: failed to persist renewal", "subscriptionID", sub.ID, "error", err)
+			}
+			skippedCount++
+			continue
+		}
+
+		slog.InfoContext(ctx, "ProcessRenewals: subscription renewed", "subscriptionID", sub.ID, "userID", sub.UserID, "previousEndDate", previousEndDate, "newEndDate", sub.EndDate, "price", sub.Price, "currency", sub.Currency, "paymentStatus", sub.PaymentStatus)
+		s.publish(ctx, newSubscriptionRenewedEvent(sub, previousEndDate))
+		renewedCount++
+	}
+
+	deactivatedCount, err := s.deactivateUnpaidRenewals(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "ProcessRenewals: renewal run completed", "candidates", len(candidates), "renewed", renewedCount, "skipped", skippedCount, "deactivated", deactivatedCount)
+	return &dto.RenewalRunSummary{
+		WindowStart: now,
+		WindowEnd:   windowEnd,
+		Candidates:  len(candidates),
+		Renewed:     renewedCount,
+		Skipped:     skippedCount,
+		Deactivated: deactivatedCount,
+	}, nil
+}
+
+// deactivateUnpaidRenewals deactivates subscriptions whose renewal is still unpaid once the configured payment
+// grace period has passed since they were renewed, and returns how many were deactivated.
+// Each deactivation is recorded as a "deactivated" event; a grace period of 0 disables deactivation.
+func (s *subscriptionService) deactivateUnpaidRenewals(ctx context.Context, now time.Time) (int, error) {
+	if s.cfg.RenewalPaymentGrace <= 0 {
+		return 0, nil
+	}
+	renewedBefore := now.Add(-s.cfg.RenewalPaymentGrace)
+	unpaid, err := s.subRepo.ListUnpaidRenewals(ctx, renewedBefore, s.cfg.RenewalBatchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ProcessRenewals: failed to list unpaid renewals", "error", err)
+		return 0, contextAware(fmt.Errorf("could not list unpaid renewals: %w", err))
+	}
+
+	deactivatedCount := 0
+	for i := range unpaid {
+		sub := &unpaid[i]
+		event := newSubscriptionEvent(customTypes.SubscriptionEventDeactivated, nil,
+			map[string]any{"is_active": true}, map[string]any{"is_active": false, "payment_status": sub.PaymentStatus})
+		if err := s.subRepo.DeactivateUnpaidRenewal(ctx, sub, event); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				slog.InfoContext(ctx, "ProcessRenewals: renewal paid or changed meanwhile, not deactivating", "subscriptionID", sub.ID)
+			} else {
+				slog.ErrorContext(ctx, "ProcessRenewals: failed to deactivate unpaid renewal", "subscriptionID", sub.ID, "error", err)
+			}
+			continue
+		}
+		slog.InfoContext(ctx, "ProcessRenewals: unpaid renewal deactivated", "subscriptionID", sub.ID, "userID", sub.UserID, "renewedAt", sub.LastRenewedAt)
+		deactivatedCount++
+	}
+	return deactivatedCount, nil
+}
@@ -0,0 +1,1880 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/events"
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionServiceDeps holds the fakes a subscriptionService under test is built from.
+type subscriptionServiceDeps struct {
+	users       *fakeUserRepo
+	subs        *fakeSubRepo
+	assignments *fakeAssignmentRepo
+	publisher   *fakePublisher
+	promoCodes  *fakePromoCodeRepo
+	plans       *fakePlanRepo
+	cfg         *config.Config
+}
+
+// basicPlan is the catalog plan every subscriptionService under test offers.
+var basicPlan = models.Plan{ID: 1, Name: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1, Price: 9.99, IsActive: true}
+
+// newTestSubscriptionService builds a subscriptionService on fresh fakes with a single existing user.
+func newTestSubscriptionService(t *testing.T, cfg *config.Config) (*subscriptionService, *subscriptionServiceDeps, uuid.UUID) {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{DefaultCurrency: "USD"}
+	}
+	userID := uuid.New()
+	deps := &subscriptionServiceDeps{
+		users:       newFakeUserRepo(models.User{ID: userID, Name: "Test User", IsActive: true, Role: customTypes.RoleUser}),
+		subs:        newFakeSubRepo(),
+		assignments: &fakeAssignmentRepo{latest: map[uuid.UUID]*models.KeyAssignment{}},
+		publisher:   &fakePublisher{},
+		promoCodes:  newFakePromoCodeRepo(),
+		plans:       newFakePlanRepo(basicPlan),
+		cfg:         cfg,
+	}
+	deps.subs.promoCodes = deps.promoCodes
+	svc := NewSubscriptionService(deps.subs, deps.users, deps.plans, deps.promoCodes, deps.assignments, deps.publisher, fakeTx{}, cfg).(*subscriptionService)
+	return svc, deps, userID
+}
+
+// newSubscriptionInput returns a valid input for a monthly subscription of userID starting now, made by an administrator.
+func newSubscriptionInput(userID uuid.UUID) dto.CreateSubscriptionInput {
+	return dto.CreateSubscriptionInput{
+		UserID:        userID,
+		PlanName:      "Basic",
+		DurationUnit:  customTypes.UnitMonth,
+		DurationValue: 1,
+		StartDate:     time.Now(),
+		PaymentStatus: "paid",
+		ActorRole:     customTypes.RoleAdmin,
+	}
+}
+
+func TestInferCurrency(t *testing.T) {
+	currencyByCountry := map[string]string{"DE": "EUR", "GB": "GBP"}
+	country := func(c string) *string { return &c }
+
+	tests := []struct {
+		name    string
+		country *string
+		want    string
+	}{
+		{name: "mapped country", country: country("DE"), want: "EUR"},
+		{name: "lower case and padded", country: country(" gb "), want: "GBP"},
+		{name: "unmapped country", country: country("JP"), want: "USD"},
+		{name: "no country", country: nil, want: "USD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferCurrency(tt.country, currencyByCountry, "USD"); got != tt.want {
+				t.Errorf("inferCurrency() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionCurrency(t *testing.T) {
+	explicit := func(c string) *string { return &c }
+
+	tests := []struct {
+		name        string
+		currency    *string
+		hostCountry string // Country of the user's current host; empty if the user has no key assignment.
+		role        customTypes.UserRole
+		want        string
+		wantErr     error
+	}{
+		{name: "explicit currency is normalized", currency: explicit(" gbp "), hostCountry: "DE", role: customTypes.RoleAdmin, want: "GBP"},
+		{name: "inferred from the user's host", hostCountry: "de", role: customTypes.RoleAdmin, want: "EUR"},
+		{name: "unmapped host country falls back", hostCountry: "JP", role: customTypes.RoleAdmin, want: "USD"},
+		{name: "no host falls back", role: customTypes.RoleAdmin, want: "USD"},
+		{name: "blank currency is inferred", currency: explicit("  "), hostCountry: "DE", role: customTypes.RoleAdmin, want: "EUR"},
+		{name: "user inferred from the user's host", hostCountry: "DE", role: customTypes.RoleUser, want: "EUR"},
+		{name: "user repeating the inferred currency", currency: explicit("eur"), hostCountry: "DE", role: customTypes.RoleUser, want: "EUR"},
+		{name: "user choosing another currency", currency: explicit("USD"), hostCountry: "DE", role: customTypes.RoleUser, wantErr: ErrValidation},
+		{name: "caller without a role choosing a currency", currency: explicit("USD"), hostCountry: "DE", wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, &config.Config{
+				DefaultCurrency:   "USD",
+				CurrencyByCountry: map[string]string{"DE": "EUR"},
+			})
+			if tt.hostCountry != "" {
+				deps.assignments.latest[userID] = &models.KeyAssignment{UserID: userID, Host: models.Host{Country: tt.hostCountry}, IsActive: true}
+			}
+
+			input := newSubscriptionInput(userID)
+			input.Currency, input.ActorRole = tt.currency, tt.role
+			if !tt.role.IsAdmin() {
+				input.PlanID = &basicPlan.ID // Anyone but an administrator subscribes to a catalog plan.
+			}
+			sub, created, err := svc.CreateSubscription(context.Background(), input)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CreateSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(deps.subs.subs) != 0 {
+					t.Errorf("%d subscriptions stored, want none", len(deps.subs.subs))
+				}
+				return
+			}
+			if !created {
+				t.Fatalf("CreateSubscription() created = false, want true")
+			}
+			if sub.Currency != tt.want {
+				t.Errorf("currency = %q, want %q", sub.Currency, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionByRole(t *testing.T) {
+	price := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name          string
+		role          customTypes.UserRole
+		planID        *uint
+		price         *float64
+		paymentStatus string
+		wantStatus    string
+		wantActive    bool
+		wantPrice     float64
+		wantErr       error
+	}{
+		{name: "admin records a payment", role: customTypes.RoleAdmin, price: price(5), paymentStatus: "paid", wantStatus: "paid", wantActive: true, wantPrice: 5},
+		{name: "admin creates a pending subscription", role: customTypes.RoleAdmin, paymentStatus: "pending", wantStatus: "pending"},
+		{name: "admin subscribes to a plan", role: customTypes.RoleAdmin, planID: &basicPlan.ID, paymentStatus: "paid", wantStatus: "paid", wantActive: true, wantPrice: basicPlan.Price},
+		{name: "user claiming a payment awaits it", role: customTypes.RoleUser, planID: &basicPlan.ID, paymentStatus: "paid", wantStatus: "pending", wantPrice: basicPlan.Price},
+		{name: "user without a payment status", role: customTypes.RoleUser, planID: &basicPlan.ID, wantStatus: "pending", wantPrice: basicPlan.Price},
+		{name: "user choosing a price pays the plan's", role: customTypes.RoleUser, planID: &basicPlan.ID, price: price(0), wantStatus: "pending", wantPrice: basicPlan.Price},
+		{name: "user without a plan", role: customTypes.RoleUser, price: price(0), wantErr: ErrValidation},
+		{name: "admin requesting a trial", role: customTypes.RoleAdmin, paymentStatus: "trial", wantErr: ErrValidation},
+		{name: "user requesting a trial", role: customTypes.RoleUser, planID: &basicPlan.ID, paymentStatus: " Trial ", wantErr: ErrValidation},
+		{name: "caller without a role awaits payment", planID: &basicPlan.ID, paymentStatus: "paid", wantStatus: "pending", wantPrice: basicPlan.Price},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, userID := newTestSubscriptionService(t, nil)
+			input := newSubscriptionInput(userID)
+			input.ActorRole, input.PlanID, input.Price, input.PaymentStatus = tt.role, tt.planID, tt.price, tt.paymentStatus
+			sub, _, err := svc.CreateSubscription(context.Background(), input)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CreateSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if sub.PaymentStatus != tt.wantStatus || sub.IsActive != tt.wantActive || sub.Price != tt.wantPrice {
+				t.Errorf("payment status = %q, active = %v, price = %v, want %q, %v and %v",
+					sub.PaymentStatus, sub.IsActive, sub.Price, tt.wantStatus, tt.wantActive, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestCalculateEndDate(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		unit    customTypes.DurationUnit
+		value   int
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "days", unit: customTypes.UnitDay, value: 30, want: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "month overflows like time.AddDate", unit: customTypes.UnitMonth, value: 1, want: time.Date(2024, time.March, 2, 12, 0, 0, 0, time.UTC)},
+		{name: "twelve months", unit: customTypes.UnitMonth, value: 12, want: time.Date(2025, time.January, 31, 12, 0, 0, 0, time.UTC)},
+		{name: "year", unit: customTypes.UnitYear, value: 1, want: time.Date(2025, time.January, 31, 12, 0, 0, 0, time.UTC)},
+		{name: "zero value", unit: customTypes.UnitDay, value: 0, wantErr: true},
+		{name: "negative value", unit: customTypes.UnitMonth, value: -1, wantErr: true},
+		{name: "unknown unit", unit: "week", value: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calculateEndDate(start, tt.unit, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("calculateEndDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("calculateEndDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenewalEndDate(t *testing.T) {
+	end := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		unit    customTypes.DurationUnit
+		value   int
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "counted from the end date", unit: customTypes.UnitDay, value: 7, want: time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{name: "monthly", unit: customTypes.UnitMonth, value: 1, want: time.Date(2024, time.March, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day plus a year", unit: customTypes.UnitYear, value: 1, want: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "legacy unit", unit: "fortnight", value: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renewalEndDate(&models.Subscription{EndDate: end, DurationUnit: tt.unit, DurationValue: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renewalEndDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("renewalEndDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSubscriptionPeriod(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		end     time.Time
+		wantErr bool
+	}{
+		{name: "end after start", end: start.AddDate(0, 1, 0)},
+		{name: "end a nanosecond after start", end: start.Add(time.Nanosecond)},
+		{name: "end equal to start", end: start, wantErr: true},
+		{name: "end before start", end: start.AddDate(0, 0, -1), wantErr: true},
+		{name: "zero end", end: time.Time{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubscriptionPeriod(start, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSubscriptionPeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (!errors.Is(err, ErrInvalidSubscriptionPeriod) || !errors.Is(err, ErrValidation)) {
+				t.Errorf("validateSubscriptionPeriod() error = %v, want %v categorized as %v", err, ErrInvalidSubscriptionPeriod, ErrValidation)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPeriodInvariant(t *testing.T) {
+	// Adding a month to the latest representable times overflows, producing an end date before the start date.
+	overflowing := time.Unix(math.MaxInt64-62135596800-10*24*60*60, 0).UTC()
+
+	tests := []struct {
+		name string
+		run  func(svc *subscriptionService, userID uuid.UUID) error
+	}{
+		{
+			name: "create",
+			run: func(svc *subscriptionService, userID uuid.UUID) error {
+				input := newSubscriptionInput(userID)
+				input.StartDate = overflowing
+				_, _, err := svc.CreateSubscription(context.Background(), input)
+				return err
+			},
+		},
+		{
+			name: "renewal",
+			run: func(*subscriptionService, uuid.UUID) error {
+				_, err := renewalEndDate(&models.Subscription{EndDate: overflowing, DurationUnit: customTypes.UnitMonth, DurationValue: 1})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+
+			if err := tt.run(svc, userID); !errors.Is(err, ErrInvalidSubscriptionPeriod) {
+				t.Fatalf("error = %v, want %v", err, ErrInvalidSubscriptionPeriod)
+			}
+			if len(deps.subs.subs) != 0 {
+				t.Errorf("stored %d subscriptions, want none", len(deps.subs.subs))
+			}
+		})
+	}
+}
+
+func TestRunRenewals(t *testing.T) {
+	now := time.Now()
+	candidate := func(mutate func(*models.Subscription)) models.Subscription {
+		sub := models.Subscription{
+			ID:            uuid.New(),
+			UserID:        uuid.New(),
+			PlanName:      "Basic",
+			DurationUnit:  customTypes.UnitMonth,
+			DurationValue: 1,
+			StartDate:     now.AddDate(0, -1, 0),
+			EndDate:       now.Add(12 * time.Hour),
+			IsActive:      true,
+			PaymentStatus: "paid",
+			AutoRenew:     true,
+		}
+		if mutate != nil {
+			mutate(&sub)
+		}
+		return sub
+	}
+
+	tests := []struct {
+		name        string
+		sub         models.Subscription
+		wantRenewed bool
+	}{
+		{name: "due subscription", sub: candidate(nil), wantRenewed: true},
+		{name: "auto-renew disabled", sub: candidate(func(s *models.Subscription) { s.AutoRenew = false })},
+		{name: "inactive", sub: candidate(func(s *models.Subscription) { s.IsActive = false })},
+		{name: "payment failed", sub: candidate(func(s *models.Subscription) { s.PaymentStatus = "failed" })},
+		{name: "ends after the window", sub: candidate(func(s *models.Subscription) { s.EndDate = now.Add(72 * time.Hour) })},
+		{name: "already ended", sub: candidate(func(s *models.Subscription) { s.EndDate = now.Add(-time.Hour) })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestSubscriptionService(t, &config.Config{RenewalWindow: 24 * time.Hour, RenewalBatchSize: 10})
+			deps.subs.subs[tt.sub.ID] = &tt.sub
+			originalEnd := tt.sub.EndDate
+
+			summary, err := svc.RunRenewals(context.Background())
+			if err != nil {
+				t.Fatalf("RunRenewals() error = %v", err)
+			}
+			stored, _ := deps.subs.GetByID(context.Background(), tt.sub.ID)
+			if !tt.wantRenewed {
+				if summary.Renewed != 0 || !stored.EndDate.Equal(originalEnd) {
+					t.Fatalf("subscription renewed: summary %+v, end date %v", summary, stored.EndDate)
+				}
+				return
+			}
+
+			wantEnd := originalEnd.AddDate(0, 1, 0)
+			if summary.Renewed != 1 || !stored.EndDate.Equal(wantEnd) {
+				t.Fatalf("after first run: renewed %d, end date %v; want 1, %v", summary.Renewed, stored.EndDate, wantEnd)
+			}
+			if stored.PaymentStatus != "pending" || stored.LastRenewedAt == nil {
+				t.Errorf("payment status %q, last renewed at %v; want pending and set", stored.PaymentStatus, stored.LastRenewedAt)
+			}
+			if len(deps.subs.events) != 1 || deps.subs.events[0].EventType != customTypes.SubscriptionEventRenewed {
+				t.Errorf("history events = %+v, want one renewed event", deps.subs.events)
+			}
+
+			// A second run must not extend the subscription again.
+			summary, err = svc.RunRenewals(context.Background())
+			if err != nil {
+				t.Fatalf("second RunRenewals() error = %v", err)
+			}
+			stored, _ = deps.subs.GetByID(context.Background(), tt.sub.ID)
+			if summary.Renewed != 0 || !stored.EndDate.Equal(wantEnd) {
+				t.Errorf("after second run: renewed %d, end date %v; want 0, %v", summary.Renewed, stored.EndDate, wantEnd)
+			}
+		})
+	}
+}
+
+func TestRenewalPayment(t *testing.T) {
+	firstPaymentID := "pay_first"
+
+	tests := []struct {
+		name       string
+		pay        *dto.ApplyPaymentInput // Payment delivered after the renewal; nil if none arrives.
+		overdue    bool                   // Whether the payment grace period has passed before the next run.
+		latePay    *dto.ApplyPaymentInput // Payment delivered after the next run.
+		wantStatus string
+		wantActive bool
+		wantErr    error // Expected error of the payment delivered after the renewal.
+	}{
+		{
+			name:       "renewal paid",
+			pay:        &dto.ApplyPaymentInput{ExternalPaymentID: "pay_renewal", Amount: 9.99},
+			overdue:    true,
+			wantStatus: "paid", wantActive: true,
+		},
+		{
+			name:       "unpaid renewal within the grace period",
+			wantStatus: "pending", wantActive: true,
+		},
+		{
+			name:       "unpaid renewal deactivated",
+			overdue:    true,
+			wantStatus: "pending", wantActive: false,
+		},
+		{
+			name:       "late payment reactivates",
+			overdue:    true,
+			latePay:    &dto.ApplyPaymentInput{ExternalPaymentID: "pay_renewal", Amount: 9.99},
+			wantStatus: "paid", wantActive: true,
+		},
+		{
+			name:       "payment of the previous period is not applied to the renewal",
+			pay:        &dto.ApplyPaymentInput{ExternalPaymentID: firstPaymentID, Amount: 9.99, PaidAt: time.Now().AddDate(0, -1, 0)},
+			wantStatus: "pending", wantActive: true,
+			wantErr: ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, &config.Config{RenewalWindow: 24 * time.Hour, RenewalBatchSize: 10, RenewalPaymentGrace: 72 * time.Hour})
+			now := time.Now()
+			sub := &models.Subscription{ID: uuid.New(), UserID: userID, StartDate: now.AddDate(0, -1, 0), EndDate: now.Add(time.Hour),
+				DurationUnit: customTypes.UnitMonth, DurationValue: 1, Price: 9.99, Currency: "USD",
+				PaymentStatus: "paid", ExternalPaymentID: &firstPaymentID, IsActive: true, AutoRenew: true}
+			deps.subs.subs[sub.ID] = sub
+
+			if summary, err := svc.RunRenewals(context.Background()); err != nil || summary.Renewed != 1 {
+				t.Fatalf("RunRenewals() = %+v, %v; want one renewal", summary, err)
+			}
+			if stored := deps.subs.subs[sub.ID]; stored.PaymentStatus != "pending" || stored.ExternalPaymentID != nil {
+				t.Fatalf("after renewal: payment status %q, payment ID %v; want pending and cleared", stored.PaymentStatus, stored.ExternalPaymentID)
+			}
+
+			if tt.pay != nil {
+				tt.pay.SubscriptionID = sub.ID
+				_, applied, err := svc.ApplyPayment(context.Background(), *tt.pay)
+				if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+					t.Fatalf("ApplyPayment() error = %v, want %v", err, tt.wantErr)
+				}
+				if applied != (tt.wantErr == nil) {
+					t.Errorf("ApplyPayment() applied = %v, want %v", applied, tt.wantErr == nil)
+				}
+			}
+			if tt.overdue {
+				renewedAt := deps.subs.subs[sub.ID].LastRenewedAt.Add(-73 * time.Hour)
+				deps.subs.subs[sub.ID].LastRenewedAt = &renewedAt
+			}
+			summary, err := svc.RunRenewals(context.Background())
+			if err != nil {
+				t.Fatalf("second RunRenewals() error = %v", err)
+			}
+			if wantDeactivated := tt.overdue && tt.pay == nil; (summary.Deactivated == 1) != wantDeactivated {
+				t.Errorf("second run deactivated %d, want deactivation = %v", summary.Deactivated, wantDeactivated)
+			}
+			if tt.latePay != nil {
+				tt.latePay.SubscriptionID = sub.ID
+				if _, applied, err := svc.ApplyPayment(context.Background(), *tt.latePay); err != nil || !applied {
+					t.Fatalf("late ApplyPayment() = %v, %v; want applied", applied, err)
+				}
+			}
+
+			stored := deps.subs.subs[sub.ID]
+			if stored.PaymentStatus != tt.wantStatus || stored.IsActive != tt.wantActive {
+				t.Errorf("payment status %q, active %v; want %q, %v", stored.PaymentStatus, stored.IsActive, tt.wantStatus, tt.wantActive)
+			}
+			if _, err := svc.subRepo.GetActiveByUserID(context.Background(), userID); (err == nil) != tt.wantActive {
+				t.Errorf("GetActiveByUserID() error = %v, want an active subscription = %v", err, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      int
+		pageSize  int
+		wantItems int
+	}{
+		{name: "first page", page: 1, pageSize: 10, wantItems: 10},
+		{name: "last partial page", page: 3, pageSize: 10, wantItems: 5},
+		{name: "page past the end", page: 4, pageSize: 10, wantItems: 0},
+		{name: "invalid page and size use defaults", page: 0, pageSize: -1, wantItems: defaultPageSize},
+		{name: "page size is capped", page: 1, pageSize: 1000, wantItems: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			for range 25 {
+				sub := models.Subscription{ID: uuid.New(), UserID: userID, DurationUnit: customTypes.UnitMonth}
+				deps.subs.subs[sub.ID] = &sub
+			}
+
+			subs, total, err := svc.ListSubscriptions(context.Background(), customTypes.ListSubscriptionsFilters{}, tt.page, tt.pageSize)
+			if err != nil {
+				t.Fatalf("ListSubscriptions() error = %v", err)
+			}
+			if len(subs) != tt.wantItems || total != 25 {
+				t.Errorf("got %d items of %d, want %d of 25", len(subs), total, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	existingID := uuid.New()
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		role    customTypes.UserRole
+		wantErr error
+	}{
+		{name: "admin deletes", id: existingID, role: customTypes.RoleAdmin},
+		{name: "missing subscription", id: uuid.New(), role: customTypes.RoleAdmin, wantErr: ErrNotFound},
+		{name: "non-admin", id: existingID, role: customTypes.RoleUser, wantErr: ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestSubscriptionService(t, nil)
+			requesterID := uuid.New()
+			deps.users.users[requesterID] = &models.User{ID: requesterID, Role: tt.role}
+			deps.subs.subs[existingID] = &models.Subscription{ID: existingID}
+
+			err := svc.DeleteSubscription(context.Background(), tt.id, requesterID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("DeleteSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			_, stillThere := deps.subs.subs[existingID]
+			if wantDeleted := tt.wantErr == nil; stillThere == wantDeleted {
+				t.Errorf("subscription present after delete = %v, want %v", stillThere, !wantDeleted)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionIdempotency(t *testing.T) {
+	key := func(k string) *string { return &k }
+
+	tests := []struct {
+		name        string
+		firstKey    *string
+		retryKey    *string
+		retryPrice  float64
+		setup       func(deps *subscriptionServiceDeps, input *dto.CreateSubscriptionInput) // Optional: shapes both requests.
+		beforeRetry func(deps *subscriptionServiceDeps)                                     // Optional: changes server-side state between the requests.
+		wantCreated bool
+		wantSameID  bool
+		wantErr     error
+	}{
+		{name: "retry returns the original", firstKey: key("order-1"), retryKey: key("order-1"), wantSameID: true},
+		{name: "key is trimmed", firstKey: key("order-1"), retryKey: key(" order-1 "), wantSameID: true},
+		{name: "different payload conflicts", firstKey: key("order-1"), retryKey: key("order-1"), retryPrice: 9.99, wantErr: ErrConflict},
+		{name: "inferred currency changed", firstKey: key("order-1"), retryKey: key("order-1"), wantSameID: true,
+			beforeRetry: func(deps *subscriptionServiceDeps) { deps.cfg.DefaultCurrency = "EUR" }},
+		{name: "single-use promo code used up", firstKey: key("order-1"), retryKey: key("order-1"), wantSameID: true,
+			setup: func(deps *subscriptionServiceDeps, input *dto.CreateSubscriptionInput) {
+				maxUses, code := 1, "SPRING"
+				deps.promoCodes.codes = []*models.PromoCode{{ID: 1, Code: code, IsActive: true, DiscountPercent: 20, MaxUses: &maxUses}}
+				input.PromoCode = &code
+			}},
+		{name: "different key creates", firstKey: key("order-1"), retryKey: key("order-2"), wantCreated: true},
+		{name: "no key creates", firstKey: key("order-1"), wantCreated: true},
+		{name: "key too long", firstKey: key("order-1"), retryKey: key(strings.Repeat("k", maxIdempotencyKeyLength+1)), wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			input := newSubscriptionInput(userID)
+			if tt.setup != nil {
+				tt.setup(deps, &input)
+			}
+			input.IdempotencyKey = tt.firstKey
+			first, _, err := svc.CreateSubscription(context.Background(), input)
+			if err != nil {
+				t.Fatalf("first CreateSubscription() error = %v", err)
+			}
+
+			input.IdempotencyKey = tt.retryKey
+			if tt.beforeRetry != nil {
+				tt.beforeRetry(deps)
+			}
+			if tt.retryPrice != 0 {
+				input.Price = &tt.retryPrice
+			}
+			retry, created, err := svc.CreateSubscription(context.Background(), input)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("retried CreateSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if sameID := retry.ID == first.ID; sameID != tt.wantSameID {
+				t.Errorf("retry returned the original subscription = %v, want %v", sameID, tt.wantSameID)
+			}
+		})
+	}
+}
+
+// TestCreateSubscriptionIdempotencyRace holds concurrent requests with the same key until all of them have passed
+// the idempotency lookup, so every one of them attempts the insert and all but one hit the unique index.
+func TestCreateSubscriptionIdempotencyRace(t *testing.T) {
+	const concurrency = 5
+
+	tests := []struct {
+		name          string
+		samePayload   bool
+		wantSuccesses int
+		wantConflicts int
+	}{
+		{name: "identical requests share one subscription", samePayload: true, wantSuccesses: concurrency},
+		{name: "different payloads conflict", samePayload: false, wantSuccesses: 1, wantConflicts: concurrency - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			var arrived sync.WaitGroup
+			arrived.Add(concurrency)
+			deps.subs.beforeCreate = func() {
+				arrived.Done()
+				arrived.Wait()
+			}
+
+			type outcome struct {
+				sub     *models.Subscription
+				created bool
+				err     error
+			}
+			outcomes := make([]outcome, concurrency)
+			var wg sync.WaitGroup
+			for i := range concurrency {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					key := "order-1"
+					input := newSubscriptionInput(userID)
+					input.StartDate = time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+					input.IdempotencyKey = &key
+					if !tt.samePayload {
+						price := float64(i)
+						input.Price = &price
+					}
+					sub, created, err := svc.CreateSubscription(context.Background(), input)
+					outcomes[i] = outcome{sub, created, err}
+				}()
+			}
+			wg.Wait()
+
+			var successes, conflicts, created int
+			ids := map[uuid.UUID]bool{}
+			for _, o := range outcomes {
+				switch {
+				case o.err == nil:
+					successes++
+					ids[o.sub.ID] = true
+					if o.created {
+						created++
+					}
+				case errors.Is(o.err, ErrConflict):
+					conflicts++
+				default:
+					t.Errorf("CreateSubscription() unexpected error = %v", o.err)
+				}
+			}
+			if successes != tt.wantSuccesses || conflicts != tt.wantConflicts {
+				t.Errorf("got %d successes and %d conflicts, want %d and %d", successes, conflicts, tt.wantSuccesses, tt.wantConflicts)
+			}
+			if created != 1 || len(ids) != 1 {
+				t.Errorf("got %d created and %d distinct subscriptions, want exactly one", created, len(ids))
+			}
+			if len(deps.subs.subs) != 1 {
+				t.Errorf("repository holds %d subscriptions, want 1", len(deps.subs.subs))
+			}
+		})
+	}
+}
+
+func TestGetChurnReport(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	paid := func(start, end time.Time, mutate func(*models.Subscription)) models.Subscription {
+		sub := models.Subscription{ID: uuid.New(), StartDate: start, EndDate: end, PaymentStatus: "paid", AutoRenew: true, IsActive: true}
+		if mutate != nil {
+			mutate(&sub)
+		}
+		return sub
+	}
+	renewedAt := day(time.March, 10)
+
+	seeded := []models.Subscription{
+		// Extended in place by the renewal job within the period.
+		paid(day(time.February, 10), day(time.April, 10), func(s *models.Subscription) { s.LastRenewedAt = &renewedAt }),
+		// Extended in place within the period, with the payment for the extension still outstanding.
+		paid(day(time.February, 12), day(time.April, 12), func(s *models.Subscription) { s.PaymentStatus, s.LastRenewedAt = "pending", &renewedAt }),
+		// Auto-renewing but not renewed.
+		paid(day(time.February, 15), day(time.March, 15), nil),
+		// Auto-renewal disabled.
+		paid(day(time.February, 20), day(time.March, 20), func(s *models.Subscription) { s.AutoRenew = false }),
+		// Active throughout the period.
+		paid(day(time.January, 1), day(time.December, 31), nil),
+		// Unpaid subscriptions are ignored.
+		paid(day(time.February, 1), day(time.March, 25), func(s *models.Subscription) { s.PaymentStatus = "pending" }),
+		// Starts and ends after the period.
+		paid(day(time.April, 2), day(time.May, 2), nil),
+	}
+
+	tests := []struct {
+		name    string
+		subs    []models.Subscription
+		from    time.Time
+		to      time.Time
+		want    dto.ChurnReport
+		wantErr error
+	}{
+		{
+			name: "renewals and non-renewals",
+			subs: seeded, from: from, to: to,
+			want: dto.ChurnReport{ActiveAtStart: 5, Renewed: 2, Expired: 1, Cancelled: 1, Churned: 2, ChurnRate: 0.4},
+		},
+		{name: "nothing active", from: from, to: to, want: dto.ChurnReport{}},
+		{name: "empty period", subs: seeded, from: from, to: from, wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestSubscriptionService(t, nil)
+			deps.subs = newFakeSubRepo(tt.subs...)
+			svc.subRepo = deps.subs
+
+			report, err := svc.GetChurnReport(context.Background(), tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetChurnReport() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			tt.want.From, tt.want.To = tt.from, tt.to
+			if *report != tt.want {
+				t.Errorf("GetChurnReport() = %+v, want %+v", *report, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionsByCurrency(t *testing.T) {
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	sub := func(created time.Time, currency, paymentStatus string, price float64) models.Subscription {
+		return models.Subscription{ID: uuid.New(), CreatedAt: created, Currency: currency, PaymentStatus: paymentStatus, Price: price}
+	}
+	inPeriod := from.AddDate(0, 0, 10)
+
+	seeded := []models.Subscription{
+		sub(inPeriod, "USD", "paid", 10),
+		sub(inPeriod, "usd", "paid", 5.5), // Grouped with USD.
+		sub(inPeriod, "USD", "pending", 10),
+		sub(inPeriod, " eur ", "paid", 8),
+		sub(inPeriod, "", "paid", 3),
+		sub(inPeriod, "  ", "trial", 0), // Blank currencies share the empty bucket.
+		sub(from, "GBP", "paid", 7),     // The start of the period is included.
+		sub(to, "GBP", "paid", 7),       // The end is not.
+		sub(from.AddDate(0, 0, -1), "EUR", "paid", 100),
+	}
+
+	tests := []struct {
+		name              string
+		subs              []models.Subscription
+		from              time.Time
+		to                time.Time
+		wantCurrencies    []customTypes.SubscriptionCurrencyTotals
+		wantSubscriptions int64
+		wantErr           error
+	}{
+		{
+			name: "multiple currencies",
+			subs: seeded, from: from, to: to,
+			wantCurrencies: []customTypes.SubscriptionCurrencyTotals{
+				{Currency: "", Subscriptions: 2, Paid: 1, Revenue: 3},
+				{Currency: "EUR", Subscriptions: 1, Paid: 1, Revenue: 8},
+				{Currency: "GBP", Subscriptions: 1, Paid: 1, Revenue: 7},
+				{Currency: "USD", Subscriptions: 3, Paid: 2, Revenue: 15.5},
+			},
+			wantSubscriptions: 7,
+		},
+		{name: "no subscriptions", from: from, to: to},
+		{name: "empty period", subs: seeded, from: to, to: to, wantErr: ErrValidation},
+		{name: "reversed period", subs: seeded, from: to, to: from, wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestSubscriptionService(t, nil)
+			deps.subs = newFakeSubRepo(tt.subs...)
+			svc.subRepo = deps.subs
+
+			report, err := svc.GetSubscriptionsByCurrency(context.Background(), tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetSubscriptionsByCurrency() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !report.From.Equal(tt.from) || !report.To.Equal(tt.to) || report.Subscriptions != tt.wantSubscriptions {
+				t.Errorf("report covers %v to %v with %d subscriptions, want %v to %v with %d",
+					report.From, report.To, report.Subscriptions, tt.from, tt.to, tt.wantSubscriptions)
+			}
+			if !slices.Equal(report.Currencies, tt.wantCurrencies) {
+				t.Errorf("currencies = %+v, want %+v", report.Currencies, tt.wantCurrencies)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionPromoCode(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+	maxUses := 3
+
+	tests := []struct {
+		name          string
+		promoCode     models.PromoCode
+		code          string
+		exhaustBefore bool // Exhaust the code between its lookup and its redemption, as a concurrent request would.
+		wantPrice     float64
+		wantErr       bool
+	}{
+		{name: "percent discount", promoCode: models.PromoCode{DiscountPercent: 20}, code: "SPRING", wantPrice: 8},
+		{name: "amount discount", promoCode: models.PromoCode{DiscountAmount: 2.5}, code: "SPRING", wantPrice: 7.5},
+		{name: "discount above price", promoCode: models.PromoCode{DiscountAmount: 15}, code: "SPRING", wantPrice: 0},
+		{name: "code is case-insensitive", promoCode: models.PromoCode{DiscountPercent: 50}, code: " spring ", wantPrice: 5},
+		{name: "within validity window", promoCode: models.PromoCode{DiscountPercent: 10, ValidFrom: &past, ValidUntil: &future, MaxUses: &maxUses, UsedCount: 2},
+			code: "SPRING", wantPrice: 9},
+		{name: "expired", promoCode: models.PromoCode{DiscountPercent: 20, ValidUntil: &past}, code: "SPRING", wantErr: true},
+		{name: "not yet valid", promoCode: models.PromoCode{DiscountPercent: 20, ValidFrom: &future}, code: "SPRING", wantErr: true},
+		{name: "over-used", promoCode: models.PromoCode{DiscountPercent: 20, MaxUses: &maxUses, UsedCount: 3}, code: "SPRING", wantErr: true},
+		{name: "exhausted concurrently", promoCode: models.PromoCode{DiscountPercent: 20, MaxUses: &maxUses, UsedCount: 2}, code: "SPRING",
+			exhaustBefore: true, wantErr: true},
+		{name: "unknown code", promoCode: models.PromoCode{DiscountPercent: 20}, code: "WINTER", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			promoCode := tt.promoCode
+			promoCode.ID, promoCode.Code, promoCode.IsActive = 1, "SPRING", true
+			deps.promoCodes.codes = []*models.PromoCode{&promoCode}
+			if tt.exhaustBefore {
+				deps.subs.beforeCreate = func() {
+					deps.promoCodes.mu.Lock()
+					defer deps.promoCodes.mu.Unlock()
+					promoCode.UsedCount = *promoCode.MaxUses
+				}
+			}
+			usedBefore := promoCode.UsedCount
+
+			input := newSubscriptionInput(userID)
+			price, code := 10.0, tt.code
+			input.Price, input.PromoCode = &price, &code
+			sub, _, err := svc.CreateSubscription(context.Background(), input)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("CreateSubscription() error = %v, want %v", err, ErrValidation)
+				}
+				if len(deps.subs.subs) != 0 {
+					t.Errorf("%d subscriptions stored, want none", len(deps.subs.subs))
+				}
+				if got := deps.promoCodes.usedCount(promoCode.ID); got != usedBefore && !tt.exhaustBefore {
+					t.Errorf("used count = %d, want %d", got, usedBefore)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+			if sub.Price != tt.wantPrice {
+				t.Errorf("price = %v, want %v", sub.Price, tt.wantPrice)
+			}
+			if sub.PromoCodeID == nil || *sub.PromoCodeID != promoCode.ID {
+				t.Errorf("promo code ID = %v, want %d", sub.PromoCodeID, promoCode.ID)
+			}
+			if got := deps.promoCodes.usedCount(promoCode.ID); got != usedBefore+1 {
+				t.Errorf("used count = %d, want %d", got, usedBefore+1)
+			}
+		})
+	}
+}
+
+func TestListAllUserSubscriptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		requester func(owner uuid.UUID) (uuid.UUID, customTypes.UserRole)
+		wantErr   error
+	}{
+		{name: "owner", count: 3, requester: func(owner uuid.UUID) (uuid.UUID, customTypes.UserRole) { return owner, customTypes.RoleUser }},
+		{name: "admin", count: 3, requester: func(uuid.UUID) (uuid.UUID, customTypes.UserRole) { return uuid.New(), customTypes.RoleAdmin }},
+		{name: "more than one page", count: maxPageSize*2 + 5,
+			requester: func(owner uuid.UUID) (uuid.UUID, customTypes.UserRole) { return owner, customTypes.RoleUser }},
+		{name: "no subscriptions", requester: func(owner uuid.UUID) (uuid.UUID, customTypes.UserRole) { return owner, customTypes.RoleUser }},
+		{name: "another user", count: 3, requester: func(uuid.UUID) (uuid.UUID, customTypes.UserRole) { return uuid.New(), customTypes.RoleUser },
+			wantErr: ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, owner := newTestSubscriptionService(t, nil)
+			start := time.Now()
+			for i := range tt.count {
+				id := uuid.New()
+				deps.subs.subs[id] = &models.Subscription{ID: id, UserID: owner, PlanName: "Basic", DurationUnit: customTypes.UnitMonth,
+					DurationValue: 1, StartDate: start.AddDate(0, -i, 0)}
+			}
+			other := uuid.New()
+			deps.subs.subs[other] = &models.Subscription{ID: other, UserID: uuid.New(), DurationUnit: customTypes.UnitMonth}
+
+			requestingUserID, role := tt.requester(owner)
+			subs, err := svc.ListAllUserSubscriptions(context.Background(), owner, requestingUserID, role)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ListAllUserSubscriptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(subs) != tt.count && tt.wantErr == nil {
+				t.Fatalf("got %d subscriptions, want %d", len(subs), tt.count)
+			}
+			seen := make(map[uuid.UUID]bool)
+			for _, sub := range subs {
+				if sub.UserID != owner || seen[sub.ID] {
+					t.Fatalf("unexpected or repeated subscription %s of user %s", sub.ID, sub.UserID)
+				}
+				seen[sub.ID] = true
+			}
+		})
+	}
+}
+
+func TestSubscriptionLifecycleEvents(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	end := start.AddDate(0, 1, 0)
+	stored := func(userID uuid.UUID, paymentStatus string, autoRenew bool) models.Subscription {
+		return models.Subscription{ID: uuid.New(), UserID: userID, PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+			StartDate: start, EndDate: end, Price: 9.99, Currency: "EUR", PaymentStatus: paymentStatus, IsActive: paymentStatus == "paid", AutoRenew: autoRenew}
+	}
+
+	tests := []struct {
+		name       string
+		existing   func(userID uuid.UUID) models.Subscription
+		updateErr  error
+		operate    func(svc *subscriptionService, userID uuid.UUID, sub models.Subscription) error
+		wantEvents func(userID uuid.UUID, sub models.Subscription) []interfaces.Event
+	}{
+		{
+			name: "created",
+			operate: func(svc *subscriptionService, userID uuid.UUID, _ models.Subscription) error {
+				input := newSubscriptionInput(userID)
+				input.StartDate, input.Price, input.Currency = start, ptr(9.99), ptr("eur")
+				_, _, err := svc.CreateSubscription(context.Background(), input)
+				return err
+			},
+			wantEvents: func(userID uuid.UUID, _ models.Subscription) []interfaces.Event {
+				return []interfaces.Event{events.SubscriptionCreated{UserID: userID, PlanName: "Basic", StartDate: start, EndDate: end,
+					Price: 9.99, Currency: "EUR", PaymentStatus: "paid"}}
+			},
+		},
+		{
+			name:     "cancelled",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "paid", true) },
+			operate: func(svc *subscriptionService, userID uuid.UUID, sub models.Subscription) error {
+				_, err := svc.CancelSubscription(context.Background(), sub.ID, userID, customTypes.RoleUser)
+				return err
+			},
+			wantEvents: func(userID uuid.UUID, sub models.Subscription) []interfaces.Event {
+				return []interfaces.Event{events.SubscriptionCancelled{SubscriptionID: sub.ID, UserID: userID, CancelledBy: userID, EndDate: end}}
+			},
+		},
+		{
+			name:     "cancelled without auto-renewal",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "paid", false) },
+			operate: func(svc *subscriptionService, userID uuid.UUID, sub models.Subscription) error {
+				_, err := svc.CancelSubscription(context.Background(), sub.ID, userID, customTypes.RoleUser)
+				return err
+			},
+		},
+		{
+			name:     "cancellation not authorized",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "paid", true) },
+			operate: func(svc *subscriptionService, _ uuid.UUID, sub models.Subscription) error {
+				_, err := svc.CancelSubscription(context.Background(), sub.ID, uuid.New(), customTypes.RoleUser)
+				return err
+			},
+		},
+		{
+			name:     "payment received",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "pending", true) },
+			operate: func(svc *subscriptionService, _ uuid.UUID, sub models.Subscription) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), sub.ID, "paid", nil)
+				return err
+			},
+			wantEvents: func(userID uuid.UUID, sub models.Subscription) []interfaces.Event {
+				return []interfaces.Event{events.PaymentStatusChanged{SubscriptionID: sub.ID, UserID: userID, OldStatus: "pending", NewStatus: "paid", IsActive: true}}
+			},
+		},
+		{
+			name:     "payment refunded",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "paid", true) },
+			operate: func(svc *subscriptionService, _ uuid.UUID, sub models.Subscription) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), sub.ID, "refunded", nil)
+				return err
+			},
+			wantEvents: func(userID uuid.UUID, sub models.Subscription) []interfaces.Event {
+				return []interfaces.Event{events.PaymentStatusChanged{SubscriptionID: sub.ID, UserID: userID, OldStatus: "paid", NewStatus: "refunded", IsActive: false}}
+			},
+		},
+		{
+			name:     "payment status unchanged",
+			existing: func(userID uuid.UUID) models.Subscription { return stored(userID, "paid", true) },
+			operate: func(svc *subscriptionService, _ uuid.UUID, sub models.Subscription) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), sub.ID, "paid", nil)
+				return err
+			},
+		},
+		{
+			name:      "payment status not persisted",
+			existing:  func(userID uuid.UUID) models.Subscription { return stored(userID, "pending", true) },
+			updateErr: errors.New("connection reset"),
+			operate: func(svc *subscriptionService, _ uuid.UUID, sub models.Subscription) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), sub.ID, "paid", nil)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			var sub models.Subscription
+			if tt.existing != nil {
+				sub = tt.existing(userID)
+				deps.subs.subs[sub.ID] = &sub
+			}
+			deps.subs.updateErr = tt.updateErr
+
+			opErr := tt.operate(svc, userID, sub)
+
+			var want []interfaces.Event
+			if tt.wantEvents != nil {
+				if opErr != nil {
+					t.Fatalf("operation error = %v", opErr)
+				}
+				want = tt.wantEvents(userID, sub)
+			}
+			got := deps.publisher.events
+			if len(got) != len(want) {
+				t.Fatalf("published %d events (%v), want %d", len(got), got, len(want))
+			}
+			for i := range got {
+				if got := normalizeEvent(t, got[i]); !reflect.DeepEqual(got, want[i]) {
+					t.Errorf("event %d = %+v, want %+v", i, got, want[i])
+				}
+			}
+		})
+	}
+}
+
+// normalizeEvent clears the fields of event that vary between runs, such as its time of occurrence and
+// generated IDs of new subscriptions, so it can be compared with an expected event.
+func normalizeEvent(t *testing.T, event interfaces.Event) interfaces.Event {
+	t.Helper()
+	switch e := event.(type) {
+	case events.SubscriptionCreated:
+		if e.SubscriptionID == uuid.Nil || e.OccurredAt.IsZero() {
+			t.Errorf("%s event lacks its subscription ID or time: %+v", e.EventName(), e)
+		}
+		e.SubscriptionID, e.OccurredAt = uuid.Nil, time.Time{}
+		return e
+	case events.SubscriptionCancelled:
+		e.OccurredAt = time.Time{}
+		return e
+	case events.PaymentStatusChanged:
+		e.OccurredAt = time.Time{}
+		return e
+	}
+	return event
+}
+
+func TestMarkExpiryNotified(t *testing.T) {
+	existingID := uuid.New()
+	updateErr := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		id        uuid.UUID
+		updateErr error
+		wantErr   error
+	}{
+		{name: "recorded", id: existingID},
+		{name: "missing subscription", id: uuid.New(), wantErr: ErrNotFound},
+		{name: "save failure", id: existingID, updateErr: updateErr, wantErr: updateErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			deps.subs.subs[existingID] = &models.Subscription{ID: existingID, UserID: userID, DurationUnit: customTypes.UnitMonth}
+			deps.subs.updateErr = tt.updateErr
+			before := time.Now()
+
+			sub, err := svc.MarkExpiryNotified(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("MarkExpiryNotified() error = %v, want %v", err, tt.wantErr)
+			}
+			stored := deps.subs.subs[existingID].LastExpiryNotifiedAt
+			if tt.wantErr != nil {
+				if stored != nil {
+					t.Errorf("LastExpiryNotifiedAt = %v after a failed call, want nil", stored)
+				}
+				return
+			}
+			if stored == nil || stored.Before(before) || !sub.LastExpiryNotifiedAt.Equal(*stored) {
+				t.Errorf("LastExpiryNotifiedAt = %v (returned %v), want the time of the call", stored, sub.LastExpiryNotifiedAt)
+			}
+			if len(deps.subs.events) != 1 || deps.subs.events[0].EventType != customTypes.SubscriptionEventExpiryNotified {
+				t.Errorf("recorded events = %+v, want one %q event", deps.subs.events, customTypes.SubscriptionEventExpiryNotified)
+			}
+		})
+	}
+}
+
+func TestUsagePeriod(t *testing.T) {
+	start := time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{StartDate: start, EndDate: time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantOK    bool
+	}{
+		{name: "start of the first period", at: start, wantStart: start, wantEnd: start.AddDate(0, 1, 0), wantOK: true},
+		{name: "within the first period", at: start.AddDate(0, 0, 10), wantStart: start, wantEnd: start.AddDate(0, 1, 0), wantOK: true},
+		{name: "start of the second period", at: start.AddDate(0, 1, 0), wantStart: start.AddDate(0, 1, 0), wantEnd: start.AddDate(0, 2, 0), wantOK: true},
+		{name: "last period is cut at the end date", at: time.Date(2026, time.March, 20, 0, 0, 0, 0, time.UTC),
+			wantStart: start.AddDate(0, 2, 0), wantEnd: sub.EndDate, wantOK: true},
+		{name: "before the start", at: start.Add(-time.Second)},
+		{name: "at the end date", at: sub.EndDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStart, gotEnd, ok := usagePeriod(sub, tt.at)
+			if ok != tt.wantOK || !gotStart.Equal(tt.wantStart) || !gotEnd.Equal(tt.wantEnd) {
+				t.Errorf("usagePeriod(%s) = %s, %s, %v; want %s, %s, %v", tt.at, gotStart, gotEnd, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day int) *time.Time {
+		t := time.Date(2026, month, day, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+	type report struct {
+		metric     string
+		quantity   int64
+		occurredAt *time.Time
+	}
+
+	tests := []struct {
+		name      string
+		reports   []report // Reported in order; only the last may fail.
+		wantTotal int64    // Period total returned for the last report.
+		wantStart time.Time
+		wantErr   error
+	}{
+		{name: "first increment", reports: []report{{"traffic_gb", 5, at(time.January, 10)}}, wantTotal: 5, wantStart: start},
+		{name: "increments in the same period are summed",
+			reports:   []report{{"traffic_gb", 5, at(time.January, 10)}, {"traffic_gb", 7, at(time.January, 20)}},
+			wantTotal: 12, wantStart: start},
+		{name: "metric names are normalized",
+			reports:   []report{{"traffic_gb", 5, at(time.January, 10)}, {"  Traffic_GB ", 1, at(time.January, 11)}},
+			wantTotal: 6, wantStart: start},
+		{name: "other metrics are counted separately",
+			reports:   []report{{"traffic_gb", 5, at(time.January, 10)}, {"api_calls", 2, at(time.January, 11)}},
+			wantTotal: 2, wantStart: start},
+		{name: "next period starts a new total",
+			reports:   []report{{"traffic_gb", 5, at(time.January, 10)}, {"traffic_gb", 3, at(time.February, 1)}},
+			wantTotal: 3, wantStart: start.AddDate(0, 1, 0)},
+		{name: "zero quantity", reports: []report{{"traffic_gb", 0, at(time.January, 10)}}, wantErr: ErrValidation},
+		{name: "negative quantity", reports: []report{{"traffic_gb", -3, at(time.January, 10)}}, wantErr: ErrValidation},
+		{name: "invalid metric", reports: []report{{"traffic gb!", 1, at(time.January, 10)}}, wantErr: ErrValidation},
+		{name: "empty metric", reports: []report{{" ", 1, at(time.January, 10)}}, wantErr: ErrValidation},
+		{name: "outside the subscription term", reports: []report{{"traffic_gb", 1, at(time.December, 10)}}, wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			sub := models.Subscription{ID: uuid.New(), UserID: userID, StartDate: start, EndDate: start.AddDate(0, 6, 0)}
+			deps.subs.subs[sub.ID] = &sub
+
+			var usage *models.SubscriptionUsage
+			var err error
+			for _, r := range tt.reports {
+				usage, err = svc.RecordUsage(context.Background(), sub.ID, dto.RecordUsageInput{Metric: r.metric, Quantity: r.quantity, OccurredAt: r.occurredAt})
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("RecordUsage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(deps.subs.usage) != 0 {
+					t.Errorf("stored %d usage rows for a rejected report", len(deps.subs.usage))
+				}
+				return
+			}
+			if usage.Quantity != tt.wantTotal || !usage.PeriodStart.Equal(tt.wantStart) || !usage.PeriodEnd.Equal(tt.wantStart.AddDate(0, 1, 0)) {
+				t.Errorf("RecordUsage() = %d in [%s, %s), want %d in the period starting %s", usage.Quantity, usage.PeriodStart, usage.PeriodEnd, tt.wantTotal, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestRecordUsageUnknownSubscription(t *testing.T) {
+	svc, _, _ := newTestSubscriptionService(t, nil)
+
+	_, err := svc.RecordUsage(context.Background(), uuid.New(), dto.RecordUsageInput{Metric: "traffic_gb", Quantity: 1})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordUsage() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestGetUsageReport(t *testing.T) {
+	svc, deps, userID := newTestSubscriptionService(t, nil)
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sub := models.Subscription{ID: uuid.New(), UserID: userID, StartDate: start, EndDate: start.AddDate(0, 3, 0)}
+	unused := models.Subscription{ID: uuid.New(), UserID: userID, StartDate: start, EndDate: start.AddDate(0, 3, 0)}
+	deps.subs.subs[sub.ID], deps.subs.subs[unused.ID] = &sub, &unused
+
+	// Reported out of order; January and March have usage, February has none.
+	for _, r := range []struct {
+		metric   string
+		quantity int64
+		at       time.Time
+	}{
+		{"traffic_gb", 4, start.AddDate(0, 2, 3)},
+		{"traffic_gb", 5, start.AddDate(0, 0, 2)},
+		{"api_calls", 10, start.AddDate(0, 0, 3)},
+		{"traffic_gb", 6, start.AddDate(0, 0, 20)},
+	} {
+		if _, err := svc.RecordUsage(context.Background(), sub.ID, dto.RecordUsageInput{Metric: r.metric, Quantity: r.quantity, OccurredAt: &r.at}); err != nil {
+			t.Fatalf("RecordUsage() error = %v", err)
+		}
+	}
+	wantPeriods := []dto.UsagePeriod{
+		{PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), Metrics: map[string]int64{"traffic_gb": 11, "api_calls": 10}},
+		{PeriodStart: start.AddDate(0, 2, 0), PeriodEnd: start.AddDate(0, 3, 0), Metrics: map[string]int64{"traffic_gb": 4}},
+	}
+
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		requester   uuid.UUID
+		role        customTypes.UserRole
+		wantPeriods []dto.UsagePeriod
+		wantErr     error
+	}{
+		{name: "owner", id: sub.ID, requester: userID, role: customTypes.RoleUser, wantPeriods: wantPeriods},
+		{name: "admin", id: sub.ID, requester: uuid.New(), role: customTypes.RoleAdmin, wantPeriods: wantPeriods},
+		{name: "no usage", id: unused.ID, requester: userID, role: customTypes.RoleUser, wantPeriods: []dto.UsagePeriod{}},
+		{name: "another user", id: sub.ID, requester: uuid.New(), role: customTypes.RoleUser, wantErr: ErrUnauthorized},
+		{name: "unknown subscription", id: uuid.New(), requester: userID, role: customTypes.RoleUser, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := svc.GetUsageReport(context.Background(), tt.id, tt.requester, tt.role)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetUsageReport() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if report.SubscriptionID != tt.id || !reflect.DeepEqual(report.Periods, tt.wantPeriods) {
+				t.Errorf("GetUsageReport() = %+v, want periods %+v", report, tt.wantPeriods)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionStartDate(t *testing.T) {
+	tests := []struct {
+		name          string
+		startAhead    time.Duration
+		maxStartAhead time.Duration
+		paymentStatus string
+		wantErr       error
+		wantActive    bool
+	}{
+		{name: "present start date", maxStartAhead: 30 * 24 * time.Hour, paymentStatus: "paid", wantActive: true},
+		{name: "near-future start date", startAhead: 24 * time.Hour, maxStartAhead: 30 * 24 * time.Hour, paymentStatus: "paid"},
+		{name: "far-future start date", startAhead: 60 * 24 * time.Hour, maxStartAhead: 30 * 24 * time.Hour, paymentStatus: "paid", wantErr: ErrValidation},
+		{name: "far-future start date without a limit", startAhead: 60 * 24 * time.Hour, paymentStatus: "paid"},
+		{name: "present start date awaiting payment", maxStartAhead: 30 * 24 * time.Hour, paymentStatus: "pending"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, &config.Config{DefaultCurrency: "USD", MaxSubscriptionStartAhead: tt.maxStartAhead})
+			input := newSubscriptionInput(userID)
+			input.StartDate = time.Now().Add(tt.startAhead)
+			input.PaymentStatus = tt.paymentStatus
+
+			sub, _, err := svc.CreateSubscription(context.Background(), input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateSubscription() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(deps.subs.subs) != 0 {
+					t.Errorf("stored %d subscriptions, want none", len(deps.subs.subs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+			if sub.IsActive != tt.wantActive {
+				t.Errorf("IsActive = %t, want %t", sub.IsActive, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestProcessActivations(t *testing.T) {
+	now := time.Now()
+	subscription := func(mutate func(*models.Subscription)) models.Subscription {
+		sub := models.Subscription{
+			ID:            uuid.New(),
+			UserID:        uuid.New(),
+			PlanName:      "Basic",
+			DurationUnit:  customTypes.UnitMonth,
+			DurationValue: 1,
+			StartDate:     now.Add(-time.Minute),
+			EndDate:       now.AddDate(0, 1, 0),
+			PaymentStatus: "paid",
+		}
+		if mutate != nil {
+			mutate(&sub)
+		}
+		return sub
+	}
+
+	tests := []struct {
+		name          string
+		sub           models.Subscription
+		wantActivated bool
+	}{
+		{name: "start date reached", sub: subscription(nil), wantActivated: true},
+		{name: "trial start date reached", sub: subscription(func(s *models.Subscription) { s.PaymentStatus = "trial" }), wantActivated: true},
+		{name: "start date in the future", sub: subscription(func(s *models.Subscription) { s.StartDate = now.Add(24 * time.Hour) })},
+		{name: "awaiting payment", sub: subscription(func(s *models.Subscription) { s.PaymentStatus = "pending" })},
+		{name: "already ended", sub: subscription(func(s *models.Subscription) {
+			s.StartDate = now.AddDate(0, -1, 0)
+			s.EndDate = now.Add(-time.Minute)
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, _ := newTestSubscriptionService(t, nil)
+			deps.subs.subs[tt.sub.ID] = &tt.sub
+
+			if err := svc.ProcessActivations(context.Background()); err != nil {
+				t.Fatalf("ProcessActivations() error = %v", err)
+			}
+			stored, _ := deps.subs.GetByID(context.Background(), tt.sub.ID)
+			if stored.IsActive != tt.wantActivated {
+				t.Fatalf("IsActive = %t, want %t", stored.IsActive, tt.wantActivated)
+			}
+			if !tt.wantActivated {
+				if len(deps.subs.events) != 0 {
+					t.Errorf("history events = %+v, want none", deps.subs.events)
+				}
+				return
+			}
+			if len(deps.subs.events) != 1 || deps.subs.events[0].EventType != customTypes.SubscriptionEventActivated {
+				t.Errorf("history events = %+v, want one activated event", deps.subs.events)
+			}
+
+			// An activated subscription is no longer a candidate.
+			if err := svc.ProcessActivations(context.Background()); err != nil {
+				t.Fatalf("second ProcessActivations() error = %v", err)
+			}
+			if len(deps.subs.events) != 1 {
+				t.Errorf("second run recorded %d events, want 1 in total", len(deps.subs.events))
+			}
+		})
+	}
+}
+
+func TestPlanNameAliases(t *testing.T) {
+	cfg := &config.Config{DefaultCurrency: "USD", PlanNameAliases: map[string]string{"pro plan": "Pro", "premium": "Pro"}}
+
+	tests := []struct {
+		name     string
+		planName string
+		want     string
+	}{
+		{name: "canonical name", planName: "Pro", want: "Pro"},
+		{name: "case variant", planName: "PRO", want: "Pro"},
+		{name: "alias", planName: "Pro Plan", want: "Pro"},
+		{name: "another alias", planName: " premium ", want: "Pro"},
+		{name: "unaliased name", planName: "Basic", want: "Basic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, cfg)
+			input := newSubscriptionInput(userID)
+			input.PlanName = tt.planName
+
+			sub, _, err := svc.CreateSubscription(context.Background(), input)
+			if err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+			stored, _ := deps.subs.GetByID(context.Background(), sub.ID)
+			if sub.PlanName != tt.want || stored.PlanName != tt.want {
+				t.Errorf("plan name = %q, stored %q; want %q", sub.PlanName, stored.PlanName, tt.want)
+			}
+
+			// Every variant finds the subscription through the plan name filters.
+			userSubs, total, err := svc.ListUserSubscriptions(context.Background(), userID, dto.ListUserSubscriptionsParams{PlanName: &tt.planName})
+			if err != nil {
+				t.Fatalf("ListUserSubscriptions() error = %v", err)
+			}
+			if total != 1 || len(userSubs) != 1 || userSubs[0].ID != sub.ID {
+				t.Errorf("ListUserSubscriptions(%q) = %d of %d subscriptions, want the created one", tt.planName, len(userSubs), total)
+			}
+			activeSubs, total, err := svc.ListActiveSubscriptionsByPlan(context.Background(), tt.planName, 1, 10)
+			if err != nil {
+				t.Fatalf("ListActiveSubscriptionsByPlan() error = %v", err)
+			}
+			if total != 1 || len(activeSubs) != 1 || activeSubs[0].ID != sub.ID {
+				t.Errorf("ListActiveSubscriptionsByPlan(%q) = %d of %d subscriptions, want the created one", tt.planName, len(activeSubs), total)
+			}
+		})
+	}
+
+	t.Run("variants of one plan are grouped", func(t *testing.T) {
+		svc, deps, userID := newTestSubscriptionService(t, cfg)
+		for _, planName := range []string{"Pro", "pro plan", "PREMIUM"} {
+			input := newSubscriptionInput(userID)
+			input.PlanName = planName
+			if _, _, err := svc.CreateSubscription(context.Background(), input); err != nil {
+				t.Fatalf("CreateSubscription(%q) error = %v", planName, err)
+			}
+		}
+		for _, sub := range deps.subs.subs {
+			if sub.PlanName != "Pro" {
+				t.Errorf("stored plan name %q, want Pro", sub.PlanName)
+			}
+		}
+		if _, total, _ := svc.ListActiveSubscriptionsByPlan(context.Background(), "pro", 1, 10); total != 3 {
+			t.Errorf("ListActiveSubscriptionsByPlan() total = %d, want 3", total)
+		}
+	})
+}
+
+func TestSubscriptionEventPerOperation(t *testing.T) {
+	adminID := uuid.New()
+
+	tests := []struct {
+		name      string
+		autoRenew bool // Of the stored subscription.
+		// op runs the operation on the stored subscription as its owner.
+		op         func(svc *subscriptionService, id, ownerID uuid.UUID) error
+		wantEvent  *models.SubscriptionEvent // Nil if no event may be recorded.
+		ownerActor bool                      // Whether the event is attributed to the owner rather than wantEvent.ActorUserID.
+		wantErr    error
+	}{
+		{
+			name: "payment status updated",
+			op: func(svc *subscriptionService, id, _ uuid.UUID) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), id, "paid", &adminID)
+				return err
+			},
+			wantEvent: &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventPaymentUpdated, ActorUserID: &adminID,
+				OldValue: []byte(`{"is_active":false,"payment_status":"pending"}`), NewValue: []byte(`{"is_active":true,"payment_status":"paid"}`)},
+		},
+		{
+			name: "payment status updated by the system",
+			op: func(svc *subscriptionService, id, _ uuid.UUID) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), id, "failed", nil)
+				return err
+			},
+			wantEvent: &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventPaymentUpdated,
+				OldValue: []byte(`{"is_active":false,"payment_status":"pending"}`), NewValue: []byte(`{"is_active":false,"payment_status":"failed"}`)},
+		},
+		{
+			name:      "cancelled",
+			autoRenew: true,
+			op: func(svc *subscriptionService, id, ownerID uuid.UUID) error {
+				_, err := svc.CancelSubscription(context.Background(), id, ownerID, customTypes.RoleUser)
+				return err
+			},
+			wantEvent: &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventCancelled,
+				OldValue: []byte(`{"auto_renew":true}`), NewValue: []byte(`{"auto_renew":false}`)},
+			ownerActor: true,
+		},
+		{
+			name: "auto-renew enabled",
+			op: func(svc *subscriptionService, id, ownerID uuid.UUID) error {
+				_, err := svc.SetAutoRenew(context.Background(), id, ownerID, customTypes.RoleUser, true)
+				return err
+			},
+			wantEvent: &models.SubscriptionEvent{EventType: customTypes.SubscriptionEventAutoRenewChanged,
+				OldValue: []byte(`{"auto_renew":false}`), NewValue: []byte(`{"auto_renew":true}`)},
+			ownerActor: true,
+		},
+		{
+			name:      "auto-renew unchanged",
+			autoRenew: true,
+			op: func(svc *subscriptionService, id, ownerID uuid.UUID) error {
+				_, err := svc.SetAutoRenew(context.Background(), id, ownerID, customTypes.RoleUser, true)
+				return err
+			},
+		},
+		{
+			name: "cancelled by another user",
+			op: func(svc *subscriptionService, id, _ uuid.UUID) error {
+				_, err := svc.CancelSubscription(context.Background(), id, uuid.New(), customTypes.RoleUser)
+				return err
+			},
+			wantErr: ErrUnauthorized,
+		},
+		{
+			name: "payment status of a missing subscription",
+			op: func(svc *subscriptionService, _, _ uuid.UUID) error {
+				_, err := svc.UpdatePaymentStatus(context.Background(), uuid.New(), "paid", nil)
+				return err
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "auto-renew of a missing subscription",
+			op: func(svc *subscriptionService, _, ownerID uuid.UUID) error {
+				_, err := svc.SetAutoRenew(context.Background(), uuid.New(), ownerID, customTypes.RoleUser, true)
+				return err
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			now := time.Now()
+			id := uuid.New()
+			deps.subs.subs[id] = &models.Subscription{ID: id, UserID: userID, StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 1, 0),
+				DurationUnit: customTypes.UnitMonth, DurationValue: 1, PaymentStatus: "pending", AutoRenew: tt.autoRenew}
+
+			err := tt.op(svc, id, userID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantEvent == nil {
+				if len(deps.subs.events) != 0 {
+					t.Errorf("recorded events = %+v, want none", deps.subs.events)
+				}
+				return
+			}
+			if len(deps.subs.events) != 1 {
+				t.Fatalf("recorded %d events, want 1: %+v", len(deps.subs.events), deps.subs.events)
+			}
+			got := deps.subs.events[0]
+			if got.SubscriptionID != id || got.EventType != tt.wantEvent.EventType ||
+				string(got.OldValue) != string(tt.wantEvent.OldValue) || string(got.NewValue) != string(tt.wantEvent.NewValue) {
+				t.Errorf("event = %s %s: %s -> %s, want %s %s: %s -> %s", got.SubscriptionID, got.EventType, got.OldValue, got.NewValue,
+					id, tt.wantEvent.EventType, tt.wantEvent.OldValue, tt.wantEvent.NewValue)
+			}
+			wantActor := tt.wantEvent.ActorUserID
+			if tt.ownerActor {
+				wantActor = &userID
+			}
+			if (got.ActorUserID == nil) != (wantActor == nil) || deref(got.ActorUserID) != deref(wantActor) {
+				t.Errorf("event actor = %v, want %v", deref(got.ActorUserID), deref(wantActor))
+			}
+		})
+	}
+}
+
+func TestListSubscriptionEvents(t *testing.T) {
+	svc, deps, ownerID := newTestSubscriptionService(t, nil)
+	now := time.Now()
+	id := uuid.New()
+	deps.subs.subs[id] = &models.Subscription{ID: id, UserID: ownerID, StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 1, 0),
+		DurationUnit: customTypes.UnitMonth, DurationValue: 1, PaymentStatus: "pending"}
+	for _, autoRenew := range []bool{true, false, true} {
+		if _, err := svc.SetAutoRenew(context.Background(), id, ownerID, customTypes.RoleUser, autoRenew); err != nil {
+			t.Fatalf("SetAutoRenew(%t) error = %v", autoRenew, err)
+		}
+	}
+	if _, err := svc.UpdatePaymentStatus(context.Background(), id, "paid", nil); err != nil {
+		t.Fatalf("UpdatePaymentStatus() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		id        uuid.UUID
+		userID    uuid.UUID
+		role      customTypes.UserRole
+		page      int
+		pageSize  int
+		wantTypes []customTypes.SubscriptionEventType
+		wantErr   error
+	}{
+		{name: "owner sees the history in order", id: id, userID: ownerID, role: customTypes.RoleUser, page: 1, pageSize: 10,
+			wantTypes: []customTypes.SubscriptionEventType{customTypes.SubscriptionEventAutoRenewChanged, customTypes.SubscriptionEventAutoRenewChanged,
+				customTypes.SubscriptionEventAutoRenewChanged, customTypes.SubscriptionEventPaymentUpdated}},
+		{name: "admin pages through the history", id: id, userID: uuid.New(), role: customTypes.RoleAdmin, page: 2, pageSize: 3,
+			wantTypes: []customTypes.SubscriptionEventType{customTypes.SubscriptionEventPaymentUpdated}},
+		{name: "another user", id: id, userID: uuid.New(), role: customTypes.RoleUser, page: 1, pageSize: 10, wantErr: ErrUnauthorized},
+		{name: "missing subscription", id: uuid.New(), userID: ownerID, role: customTypes.RoleUser, page: 1, pageSize: 10, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, total, err := svc.ListSubscriptionEvents(context.Background(), tt.id, tt.userID, tt.role, tt.page, tt.pageSize)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ListSubscriptionEvents() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var types []customTypes.SubscriptionEventType
+			for _, event := range events {
+				types = append(types, event.EventType)
+			}
+			if !slices.Equal(types, tt.wantTypes) || total != 4 {
+				t.Errorf("ListSubscriptionEvents() = %v of %d, want %v of 4", types, total, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestApplyPayment(t *testing.T) {
+	now := time.Now()
+	pendingID, paidID, futureID := uuid.New(), uuid.New(), uuid.New()
+	paymentID := "pay_123"
+
+	tests := []struct {
+		name        string
+		input       dto.ApplyPaymentInput
+		wantApplied bool
+		wantActive  bool
+		wantErr     error
+	}{
+		{name: "applied", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: " pay_new ", Amount: 9.99, Currency: "usd"},
+			wantApplied: true, wantActive: true},
+		{name: "overpayment", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: "pay_new", Amount: 20},
+			wantApplied: true, wantActive: true},
+		{name: "future subscription is not activated", input: dto.ApplyPaymentInput{SubscriptionID: futureID, ExternalPaymentID: "pay_new", Amount: 9.99},
+			wantApplied: true},
+		{name: "replayed payment", input: dto.ApplyPaymentInput{SubscriptionID: paidID, ExternalPaymentID: paymentID, Amount: 9.99},
+			wantActive: true},
+		{name: "payment of another subscription", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: paymentID, Amount: 9.99},
+			wantErr: ErrConflict},
+		{name: "subscription paid by another payment", input: dto.ApplyPaymentInput{SubscriptionID: paidID, ExternalPaymentID: "pay_new", Amount: 9.99},
+			wantErr: ErrConflict},
+		{name: "currency mismatch", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: "pay_new", Amount: 9.99, Currency: "EUR"},
+			wantErr: ErrValidation},
+		{name: "amount short of the price", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: "pay_new", Amount: 5},
+			wantErr: ErrValidation},
+		{name: "negative amount", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: "pay_new", Amount: -1},
+			wantErr: ErrValidation},
+		{name: "blank payment ID", input: dto.ApplyPaymentInput{SubscriptionID: pendingID, ExternalPaymentID: "  ", Amount: 9.99},
+			wantErr: ErrValidation},
+		{name: "missing subscription", input: dto.ApplyPaymentInput{SubscriptionID: uuid.New(), ExternalPaymentID: "pay_new", Amount: 9.99},
+			wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			subscription := func(id uuid.UUID, start time.Time, status string, externalPaymentID *string) *models.Subscription {
+				return &models.Subscription{ID: id, UserID: userID, StartDate: start, EndDate: start.AddDate(0, 1, 0), DurationUnit: customTypes.UnitMonth,
+					DurationValue: 1, Price: 9.99, Currency: "USD", PaymentStatus: status, IsActive: status == "paid", ExternalPaymentID: externalPaymentID}
+			}
+			deps.subs.subs[pendingID] = subscription(pendingID, now.AddDate(0, 0, -1), "pending", nil)
+			deps.subs.subs[paidID] = subscription(paidID, now.AddDate(0, 0, -1), "paid", &paymentID)
+			deps.subs.subs[futureID] = subscription(futureID, now.AddDate(0, 0, 7), "pending", nil)
+			var before models.Subscription
+			if stored, ok := deps.subs.subs[tt.input.SubscriptionID]; ok {
+				before = *stored
+			}
+
+			sub, applied, err := svc.ApplyPayment(context.Background(), tt.input)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ApplyPayment() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil || !tt.wantApplied {
+				if applied {
+					t.Errorf("ApplyPayment() applied = true, want false")
+				}
+				if len(deps.subs.events) != 0 || len(deps.publisher.events) != 0 {
+					t.Errorf("recorded events %+v and published %+v, want none", deps.subs.events, deps.publisher.events)
+				}
+				if stored, ok := deps.subs.subs[tt.input.SubscriptionID]; ok && !reflect.DeepEqual(*stored, before) {
+					t.Errorf("stored subscription changed to %+v", *stored)
+				}
+				if err == nil && (sub.ID != tt.input.SubscriptionID || sub.IsActive != tt.wantActive) {
+					t.Errorf("ApplyPayment() = %+v, want the subscription as it was", sub)
+				}
+				return
+			}
+
+			stored := deps.subs.subs[tt.input.SubscriptionID]
+			wantPaymentID := strings.TrimSpace(tt.input.ExternalPaymentID)
+			if !applied || stored.PaymentStatus != "paid" || deref(stored.ExternalPaymentID) != wantPaymentID || stored.IsActive != tt.wantActive {
+				t.Errorf("applied %t, stored status %q, payment %v, active %t; want paid by %q, active %t",
+					applied, stored.PaymentStatus, deref(stored.ExternalPaymentID), stored.IsActive, wantPaymentID, tt.wantActive)
+			}
+			if len(deps.subs.events) != 1 || deps.subs.events[0].EventType != customTypes.SubscriptionEventPaymentReceived {
+				t.Errorf("recorded events = %+v, want one %q event", deps.subs.events, customTypes.SubscriptionEventPaymentReceived)
+			}
+			want := events.PaymentStatusChanged{SubscriptionID: stored.ID, UserID: userID, OldStatus: "pending", NewStatus: "paid", IsActive: tt.wantActive}
+			if got := deps.publisher.events; len(got) != 1 || !reflect.DeepEqual(normalizeEvent(t, got[0]), want) {
+				t.Errorf("published %+v, want %+v", got, want)
+			}
+
+			// Delivering the same payment again changes nothing.
+			_, applied, err = svc.ApplyPayment(context.Background(), tt.input)
+			if err != nil || applied || len(deps.subs.events) != 1 {
+				t.Errorf("replayed ApplyPayment() = applied %t, error %v with %d events; want a no-op", applied, err, len(deps.subs.events))
+			}
+		})
+	}
+}
+
+func TestGetUserSubscriptionStatus(t *testing.T) {
+	now := time.Now()
+	sub := func(userID uuid.UUID, planName string, isActive bool, endDate time.Time) models.Subscription {
+		return models.Subscription{ID: uuid.New(), UserID: userID, PlanName: planName, IsActive: isActive, StartDate: now.AddDate(0, -1, 0), EndDate: endDate}
+	}
+
+	tests := []struct {
+		name       string
+		subs       func(userID uuid.UUID) []models.Subscription
+		unknown    bool // Whether the status of a user that does not exist is requested.
+		wantActive bool
+		wantPlans  []string
+		wantErr    error
+	}{
+		{
+			name: "active subscriptions",
+			subs: func(userID uuid.UUID) []models.Subscription {
+				return []models.Subscription{
+					sub(userID, "Premium", true, now.AddDate(0, 1, 0)),
+					sub(userID, "Basic", true, now.AddDate(0, 0, 7)),
+					sub(userID, "Premium", true, now.AddDate(0, 2, 0)),
+					sub(userID, "Family", true, now.Add(-time.Hour)),  // Expired.
+					sub(userID, "Trial", false, now.AddDate(0, 1, 0)), // Cancelled.
+					sub(uuid.New(), "Business", true, now.AddDate(0, 1, 0)),
+				}
+			},
+			wantActive: true, wantPlans: []string{"Basic", "Premium"},
+		},
+		{
+			name: "no active subscription",
+			subs: func(userID uuid.UUID) []models.Subscription {
+				return []models.Subscription{sub(userID, "Basic", true, now.Add(-time.Hour)), sub(userID, "Premium", false, now.AddDate(0, 1, 0))}
+			},
+			wantPlans: []string{},
+		},
+		{name: "no subscriptions", wantPlans: []string{}},
+		{name: "unknown user", unknown: true, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			if tt.subs != nil {
+				deps.subs = newFakeSubRepo(tt.subs(userID)...)
+				svc.subRepo = deps.subs
+			}
+			if tt.unknown {
+				userID = uuid.New()
+			}
+
+			got, err := svc.GetUserSubscriptionStatus(context.Background(), userID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetUserSubscriptionStatus() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.HasActiveSubscription != tt.wantActive || got.ActivePlans == nil || !slices.Equal(got.ActivePlans, tt.wantPlans) {
+				t.Errorf("GetUserSubscriptionStatus() = %+v, want active %t with plans %q", got, tt.wantActive, tt.wantPlans)
+			}
+		})
+	}
+}
+
+// TestInvalidDurationUnitReads checks that a legacy subscription with an unknown duration unit is returned and logged
+// by each read instead of failing it, alongside the valid subscriptions.
+func TestInvalidDurationUnitReads(t *testing.T) {
+	tests := []struct {
+		name   string
+		single bool // Whether the read returns only the legacy subscription.
+		read   func(svc *subscriptionService, userID, legacyID uuid.UUID) ([]models.Subscription, error)
+	}{
+		{name: "get by ID", single: true, read: func(svc *subscriptionService, userID, legacyID uuid.UUID) ([]models.Subscription, error) {
+			sub, err := svc.GetSubscriptionByID(context.Background(), legacyID, userID, customTypes.RoleUser)
+			if err != nil {
+				return nil, err
+			}
+			return []models.Subscription{*sub}, nil
+		}},
+		{name: "list user subscriptions", read: func(svc *subscriptionService, userID, _ uuid.UUID) ([]models.Subscription, error) {
+			subs, _, err := svc.ListUserSubscriptions(context.Background(), userID, dto.ListUserSubscriptionsParams{})
+			return subs, err
+		}},
+		{name: "list all user subscriptions", read: func(svc *subscriptionService, userID, _ uuid.UUID) ([]models.Subscription, error) {
+			return svc.ListAllUserSubscriptions(context.Background(), userID, userID, customTypes.RoleUser)
+		}},
+		{name: "list subscriptions", read: func(svc *subscriptionService, _, _ uuid.UUID) ([]models.Subscription, error) {
+			subs, _, err := svc.ListSubscriptions(context.Background(), customTypes.ListSubscriptionsFilters{}, 1, 10)
+			return subs, err
+		}},
+		{name: "list active subscriptions by plan", read: func(svc *subscriptionService, _, _ uuid.UUID) ([]models.Subscription, error) {
+			subs, _, err := svc.ListActiveSubscriptionsByPlan(context.Background(), "Basic", 1, 10)
+			return subs, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+			svc, deps, userID := newTestSubscriptionService(t, nil)
+			now := time.Now()
+			valid := &models.Subscription{ID: uuid.New(), UserID: userID, PlanName: "Basic", DurationUnit: customTypes.UnitMonth, DurationValue: 1,
+				IsActive: true, StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 1, 0)}
+			legacy := &models.Subscription{ID: uuid.New(), UserID: userID, PlanName: "Basic", DurationUnit: "fortnight", DurationValue: 1,
+				IsActive: true, StartDate: now.AddDate(0, 0, -2), EndDate: now.AddDate(0, 0, 12)}
+			deps.subs.subs[valid.ID] = valid
+			deps.subs.subs[legacy.ID] = legacy
+
+			subs, err := tt.read(svc, userID, legacy.ID)
+			if err != nil {
+				t.Fatalf("read error = %v", err)
+			}
+			var ids []uuid.UUID
+			for _, sub := range subs {
+				ids = append(ids, sub.ID)
+			}
+			if !slices.Contains(ids, legacy.ID) {
+				t.Errorf("read returned %v, want the legacy subscription %s", ids, legacy.ID)
+			}
+			if !tt.single && !slices.Contains(ids, valid.ID) {
+				t.Errorf("read returned %v, want the valid subscription %s too", ids, valid.ID)
+			}
+
+			if got := strings.Count(logs.String(), "invalid duration unit"); got != 1 {
+				t.Errorf("logged %d invalid duration unit warnings, want 1:\n%s", got, logs.String())
+			}
+			if !strings.Contains(logs.String(), legacy.ID.String()) {
+				t.Errorf("logs do not name the legacy subscription %s:\n%s", legacy.ID, logs.String())
+			}
+		})
+	}
+}
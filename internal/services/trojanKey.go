@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GenerateTrojanKeyForUser generates a trojan key string for a given user on a host whose protocol is trojan.
+// It selects the host like GenerateVlessKeyForUser does, restricted to trojan hosts. The key is not recorded as
+// an assignment, so the user's current VLESS key is unchanged.
+func (s *keyService) GenerateTrojanKeyForUser(ctx context.Context, userID uuid.UUID, remarks string, prefs dto.HostPreferences, allowFreeFallback bool) (*dto.GenerateTrojanKeyResult, error) {
+	slog.InfoContext(ctx, "GenerateTrojanKeyForUser: attempting to generate key", "userID", userID, "country", prefs.Country, "network", prefs.Network, "securityType", prefs.SecurityType)
+
+	if s.cfg.TrojanPasswordSecret == "" {
+		slog.WarnContext(ctx, "GenerateTrojanKeyForUser: trojan password secret is not configured")
+		return nil, unavailable(errors.New("trojan keys are not enabled"))
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GenerateTrojanKeyForUser: user not found", "userID", userID)
+			return nil, notFound(fmt.Errorf("user with ID %s not found", userID))
+		}
+		slog.ErrorContext(ctx, "GenerateTrojanKeyForUser: failed to get user", "userID", userID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve user: %w", err))
+	}
+
+	subscription, err := s.subscriptionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "GenerateTrojanKeyForUser: failed to get user's active subscription", "userID", userID, "error", err)
+		}
+		subscription = nil // Default to no subscription if the lookup fails.
+	}
+	hasActiveSubscription := subscription != nil
+	var expiresAt *time.Time
+	if hasActiveSubscription {
+		expiresAt = &subscription.EndDate
+	}
+
+	tiers := []bool{!hasActiveSubscription} // true for free, false for paid.
+	if hasActiveSubscription && allowFreeFallback {
+		tiers = append(tiers, true)
+	}
+	password := trojanPassword(s.cfg.TrojanPasswordSecret, user.ID)
+	host, trojanConfig, err := s.acquireTrojanHostWithConfig(ctx, password, remarks, prefs, tiers)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GenerateTrojanKeyForUser: no active trojan hosts available even after fallback", "hasActiveSubscription", hasActiveSubscription, "allowFreeFallback", allowFreeFallback)
+			return nil, unavailable(errors.New("no active trojan hosts are currently available for the specified criteria"))
+		}
+		slog.ErrorContext(ctx, "GenerateTrojanKeyForUser: failed to get active host", "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve an active host: %w", err))
+	}
+
+	servedTier := dto.ServedTierPaid
+	if host.IsFreeTier {
+		servedTier = dto.ServedTierFree
+	}
+	degraded := hasActiveSubscription && host.IsFreeTier
+	if degraded {
+		slog.WarnContext(ctx, "GenerateTrojanKeyForUser: subscribed user served a free host", "userID", userID, "hostID", host.ID)
+	}
+
+	s.recordKeyGenerated(ctx, host, &user.ID)
+	slog.InfoContext(ctx, "GenerateTrojanKeyForUser: trojan key generated successfully", "userID", userID, "hostID", host.ID, "hasActiveSubscription", hasActiveSubscription)
+	return &dto.GenerateTrojanKeyResult{
+		TrojanKey:             trojanURLFromConfig(trojanConfig),
+		Config:                *trojanConfig,
+		Host:                  keyHostOf(host),
+		HasActiveSubscription: hasActiveSubscription,
+		ExpiresAt:             expiresAt,
+		ServedTier:            servedTier,
+		Degraded:              degraded,
+	}, nil
+}
+
+// acquireTrojanHostWithConfig acquires the least issued trojan host for each tier (true for free) in turn, relaxing
+// the preferences through the steps of hostSelectionLadder, and builds the key's trojan config. A host whose
+// configuration cannot produce a key is skipped and logged, up to maxHostSelectionAttempts hosts per step.
+// It returns gorm.ErrRecordNotFound if every step finds no usable host.
+func (s *keyService) acquireTrojanHostWithConfig(ctx context.Context, password, remarks string, prefs dto.HostPreferences, tiers []bool) (*models.Host, *dto.TrojanConfig, error) {
+	protocol := trojanProtocol
+	for _, isFreeTier := range tiers {
+		for step, filter := range hostSelectionLadder(prefs) {
+			filter.IsFreeTier = &isFreeTier
+			filter.Protocol = &protocol
+			var excluded []uint
+			for attempt := 1; attempt <= maxHostSelectionAttempts; attempt++ {
+				host, err := s.hostRepo.AcquireLeastIssuedHostExcluding(ctx, filter, excluded)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					break
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+				trojanConfig, err := buildTrojanConfig(password, host, remarks)
+				if err == nil {
+					return host, trojanConfig, nil
+				}
+				skipped := s.invalidHostSkips.Add(1)
+				slog.WarnContext(ctx, "acquireTrojanHostWithConfig: skipping misconfigured host", "hostID", host.ID, "attempt", attempt, "invalidHostSkipsTotal", skipped, "error", err)
+				excluded = append(excluded, host.ID)
+			}
+			slog.InfoContext(ctx, "acquireTrojanHostWithConfig: no active trojan hosts available, trying fallback", "tier_is_free", isFreeTier, "step", step,
+				"country", filter.Country, "network", filter.Network, "securityType", filter.SecurityType, "addressFamily", filter.AddressFamily)
+		}
+	}
+	return nil, nil, gorm.ErrRecordNotFound
+}
+
+// trojanPassword derives a user's trojan password from their ID with HMAC-SHA256 keyed by secret, so the same
+// password can be provisioned on trojan servers without storing it. It is hex-encoded and safe to embed in URLs.
+func trojanPassword(secret string, userID uuid.UUID) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(userID[:])
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// buildTrojanConfig collects the trojan key components for the given password and host.
+// Placeholders in remarks are expanded from the host's metadata. Trojan runs over TLS, so hosts without a security
+// type default to tls, and Reality hosts, which trojan clients do not support, are rejected.
+func buildTrojanConfig(password string, host *models.Host, remarks string) (*dto.TrojanConfig, error) {
+	if !strings.EqualFold(host.Protocol, trojanProtocol) {
+		return nil, fmt.Errorf("selected host (ID: %d) uses protocol '%s', not trojan", host.ID, host.Protocol)
+	}
+	if strings.EqualFold(host.SecurityType, "reality") {
+		return nil, fmt.Errorf("selected host (ID: %d) is configured for Reality, which trojan keys do not support", host.ID)
+	}
+	trojanConfig := &dto.TrojanConfig{
+		Address:     host.Address,
+		Port:        host.Port,
+		Password:    password,
+		Network:     host.Network,
+		Security:    host.SecurityType,
+		SNI:         host.SNI,
+		Fingerprint: host.Fingerprint,
+		Remarks:     expandRemarksTemplate(remarks, host),
+	}
+	if trojanConfig.Network == "" {
+		trojanConfig.Network = "tcp"
+	}
+	if trojanConfig.Security == "" {
+		trojanConfig.Security = "tls"
+	}
+	return trojanConfig, nil
+}
+
+// trojanURLFromConfig encodes trojan key components as a trojan:// URL.
+func trojanURLFromConfig(trojanConfig *dto.TrojanConfig) string {
+	queryParams := url.Values{}
+	queryParams.Set("security", trojanConfig.Security)
+	if trojanConfig.SNI != "" {
+		queryParams.Set("sni", trojanConfig.SNI)
+	}
+	if trojanConfig.Fingerprint != "" {
+		queryParams.Set("fp", trojanConfig.Fingerprint)
+	}
+	queryParams.Set("type", trojanConfig.Network)
+
+	trojanURL := fmt.Sprintf("trojan://%s@%s:%s?%s", trojanConfig.Password, trojanConfig.Address, trojanConfig.Port, queryParams.Encode())
+	if trojanConfig.Remarks != "" {
+		trojanURL = fmt.Sprintf("%s#%s", trojanURL, url.PathEscape(trojanConfig.Remarks))
+	}
+	return trojanURL
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTrojanPassword(t *testing.T) {
+	userID, otherUserID := uuid.New(), uuid.New()
+	password := trojanPassword("secret", userID)
+
+	tests := []struct {
+		name     string
+		secret   string
+		userID   uuid.UUID
+		wantSame bool
+	}{
+		{name: "same user and secret", secret: "secret", userID: userID, wantSame: true},
+		{name: "other user", secret: "secret", userID: otherUserID},
+		{name: "other secret", secret: "rotated", userID: userID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trojanPassword(tt.secret, tt.userID)
+			if len(got) != 32 || url.PathEscape(got) != got {
+				t.Errorf("trojanPassword() = %q, want 32 URL-safe hex characters", got)
+			}
+			if (got == password) != tt.wantSame {
+				t.Errorf("trojanPassword() = %q, same as %q: %v, want %v", got, password, got == password, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestBuildTrojanConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    models.Host
+		want    dto.TrojanConfig
+		wantErr bool
+	}{
+		{
+			name: "trojan defaults to tls over tcp",
+			host: models.Host{Address: "1.2.3.4", Port: "443", Protocol: "trojan"},
+			want: dto.TrojanConfig{Address: "1.2.3.4", Port: "443", Password: "pw", Network: "tcp", Security: "tls"},
+		},
+		{
+			name: "trojan over websocket",
+			host: models.Host{Address: "us1.example.com", Port: "8443", Protocol: "Trojan", Network: "ws", SecurityType: "tls", SNI: "cdn.example.com", Fingerprint: "chrome"},
+			want: dto.TrojanConfig{Address: "us1.example.com", Port: "8443", Password: "pw", Network: "ws", Security: "tls", SNI: "cdn.example.com", Fingerprint: "chrome"},
+		},
+		{name: "vless host", host: models.Host{Address: "1.2.3.4", Port: "443", Protocol: "vless"}, wantErr: true},
+		{name: "vmess host", host: models.Host{Address: "1.2.3.4", Port: "443", Protocol: "vmess"}, wantErr: true},
+		{name: "reality host", host: models.Host{Address: "1.2.3.4", Port: "443", Protocol: "trojan", SecurityType: "reality", PublicKey: "pbk"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTrojanConfig("pw", &tt.host, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTrojanConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("buildTrojanConfig() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrojanURLStructure(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       dto.TrojanConfig
+		wantQuery    url.Values
+		wantFragment string
+	}{
+		{
+			name:      "minimal",
+			config:    dto.TrojanConfig{Address: "1.2.3.4", Port: "443", Password: "0123abcd", Network: "tcp", Security: "tls"},
+			wantQuery: url.Values{"security": {"tls"}, "type": {"tcp"}},
+		},
+		{
+			name: "every component",
+			config: dto.TrojanConfig{Address: "us1.example.com", Port: "8443", Password: "0123abcd", Network: "ws", Security: "tls",
+				SNI: "cdn.example.com", Fingerprint: "chrome", Remarks: "BittenVPN | US"},
+			wantQuery:    url.Values{"security": {"tls"}, "sni": {"cdn.example.com"}, "fp": {"chrome"}, "type": {"ws"}},
+			wantFragment: "BittenVPN | US",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := trojanURLFromConfig(&tt.config)
+			parsed, err := url.Parse(key)
+			if err != nil {
+				t.Fatalf("key %q does not parse as a URL: %v", key, err)
+			}
+			if parsed.Scheme != "trojan" || parsed.User.Username() != tt.config.Password ||
+				parsed.Hostname() != tt.config.Address || parsed.Port() != tt.config.Port {
+				t.Errorf("key %q = %s://%s@%s:%s, want trojan://%s@%s:%s", key, parsed.Scheme, parsed.User.Username(), parsed.Hostname(), parsed.Port(),
+					tt.config.Password, tt.config.Address, tt.config.Port)
+			}
+			if got := parsed.Query(); !reflect.DeepEqual(got, tt.wantQuery) {
+				t.Errorf("key query = %v, want %v", got, tt.wantQuery)
+			}
+			if parsed.Fragment != tt.wantFragment {
+				t.Errorf("key fragment = %q, want %q", parsed.Fragment, tt.wantFragment)
+			}
+		})
+	}
+}
+
+func TestGenerateTrojanKeyForUser(t *testing.T) {
+	trojanHost := func(id uint, isFreeTier bool, issued int64) models.Host {
+		host := testHost(id, "US", isFreeTier)
+		host.Protocol, host.IssuedCount = "trojan", issued
+		return host
+	}
+	withProtocol := func(host models.Host, protocol string) models.Host {
+		host.Protocol = protocol
+		return host
+	}
+	reality := trojanHost(3, true, 0)
+	reality.SecurityType, reality.PublicKey = "reality", "pbk123"
+
+	tests := []struct {
+		name         string
+		secret       string
+		paid         bool
+		fallback     bool
+		hosts        []models.Host
+		wantHostID   uint
+		wantSkipped  []uint // Hosts acquired but skipped because they cannot produce a trojan key.
+		wantDegraded bool
+		wantErr      error
+	}{
+		{name: "non-trojan hosts are excluded", secret: "secret",
+			hosts:      []models.Host{testHost(1, "DE", true), withProtocol(testHost(2, "NL", true), "vmess"), trojanHost(4, true, 7)},
+			wantHostID: 4},
+		{name: "reality trojan hosts are skipped", secret: "secret",
+			hosts:      []models.Host{reality, trojanHost(4, true, 3)},
+			wantHostID: 4, wantSkipped: []uint{3}},
+		{name: "paid user", secret: "secret", paid: true,
+			hosts:      []models.Host{trojanHost(4, true, 0), trojanHost(5, false, 2)},
+			wantHostID: 5},
+		{name: "paid user falls back to a free trojan host", secret: "secret", paid: true, fallback: true,
+			hosts:      []models.Host{testHost(1, "DE", false), trojanHost(4, true, 0)},
+			wantHostID: 4, wantDegraded: true},
+		{name: "no trojan host", secret: "secret",
+			hosts:   []models.Host{testHost(1, "DE", true), withProtocol(testHost(2, "NL", true), "vmess")},
+			wantErr: ErrUnavailable},
+		{name: "trojan keys disabled", hosts: []models.Host{trojanHost(4, true, 0)}, wantErr: ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, deps, userID := newTestKeyService(t, &config.Config{TrojanPasswordSecret: tt.secret})
+			deps.hosts = newFakeHostRepo(tt.hosts...)
+			svc.hostRepo = deps.hosts
+			if tt.paid {
+				deps.subs.subs[uuid.New()] = &models.Subscription{UserID: userID, IsActive: true, EndDate: time.Now().AddDate(0, 1, 0)}
+			}
+			before := deps.hosts.issuedCounts()
+
+			result, err := svc.GenerateTrojanKeyForUser(context.Background(), userID, "Trojan {country}", dto.HostPreferences{}, tt.fallback)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GenerateTrojanKeyForUser() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateTrojanKeyForUser() error = %v", err)
+			}
+			if result.Degraded != tt.wantDegraded {
+				t.Errorf("Degraded = %v, want %v", result.Degraded, tt.wantDegraded)
+			}
+
+			parsed, err := url.Parse(result.TrojanKey)
+			if err != nil {
+				t.Fatalf("key %q does not parse as a URL: %v", result.TrojanKey, err)
+			}
+			if want := trojanPassword(tt.secret, userID); parsed.Scheme != "trojan" || parsed.User.Username() != want {
+				t.Errorf("key %q, want a trojan key with the password %q", result.TrojanKey, want)
+			}
+			if parsed.Fragment != "Trojan US" {
+				t.Errorf("key fragment = %q, want the expanded remarks %q", parsed.Fragment, "Trojan US")
+			}
+
+			after := deps.hosts.issuedCounts()
+			for _, host := range tt.hosts {
+				wantIssued := before[host.ID]
+				if host.ID == tt.wantHostID || slices.Contains(tt.wantSkipped, host.ID) {
+					wantIssued++
+				}
+				if after[host.ID] != wantIssued {
+					t.Errorf("host %d (%s) issued count = %d, want %d", host.ID, host.Protocol, after[host.ID], wantIssued)
+				}
+			}
+			if _, ok := deps.assignments.latest[userID]; ok {
+				t.Error("trojan key was recorded as the user's current key")
+			}
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package services
 
 import (
+	"bitback/internal/config"
 	"bitback/internal/interfaces"
 	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
 	"bitback/internal/services/dto"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -16,45 +19,139 @@ import (
 
 type userService struct {
 	userRepo interfaces.UserRepository
+	subRepo  interfaces.SubscriptionRepository
+	tx       interfaces.Transactor
+	cfg      *config.Config
 }
 
 // NewUserService creates a new instance of userService.
-func NewUserService(userRepo interfaces.UserRepository) interfaces.UserService {
+func NewUserService(
+	userRepo interfaces.UserRepository,
+	subRepo interfaces.SubscriptionRepository,
+	tx interfaces.Transactor,
+	cfg *config.Config,
+) interfaces.UserService {
 	return &userService{
 		userRepo: userRepo,
+		subRepo:  subRepo,
+		tx:       tx,
+		cfg:      cfg,
 	}
 }
 
 // RegisterUser handles the registration of a new user.
-// It performs validation and persists the new user to the repository.
-func (s *userService) RegisterUser(ctx context.Context, input dto.CreateUserInput) (*models.User, error) {
-	slog.InfoContext(ctx, "RegisterUser: attempting to register user", "email", input.Email)
+// It performs validation and persists the new user to the repository. When trials are enabled, a trial
+// subscription is created in the same transaction, unless a deleted user had the same email or Telegram ID.
+func (s *userService) RegisterUser(ctx context.Context, input dto.CreateUserInput) (*models.User, *models.Subscription, error) {
+	email := normalizeEmail(input.Email)
+	slog.InfoContext(ctx, "RegisterUser: attempting to register user", "email", email)
 
 	// Validate input data.
 	if strings.TrimSpace(input.Name) == "" {
-		return nil, errors.New("user name cannot be empty")
+		return nil, nil, invalid(errors.New("user name cannot be empty"))
+	}
+
+	var user *models.User
+	var trial *models.Subscription
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.createUser(ctx, email, input); err != nil {
+			return err
+		}
+		if !s.cfg.TrialEnabled || input.SkipTrial {
+			return nil
+		}
+		trial, err = s.createTrial(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.InfoContext(ctx, "RegisterUser: user registered successfully", "userID", user.ID, "email", user.Email, "trial", trial != nil)
+	return user, trial, nil
+}
+
+// createUser checks that the email is available and persists a new user with the given normalized email.
+func (s *userService) createUser(ctx context.Context, email string, input dto.CreateUserInput) (*models.User, error) {
+	// Reject a taken email up front. The unique index on lower(email) still catches registrations
+	// racing past this check, which are handled below.
+	if email != "" {
+		existingUser, err := s.userRepo.GetByEmail(ctx, email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "RegisterUser: failed to check email availability", "email", email, "error", err)
+			return nil, contextAware(fmt.Errorf("could not verify email availability: %w", err))
+		}
+		if existingUser != nil {
+			slog.WarnContext(ctx, "RegisterUser: email already in use", "email", email, "existingUserID", existingUser.ID)
+			return nil, conflict(fmt.Errorf("user with email '%s' already exists: %w", email, interfaces.ErrEmailTaken))
+		}
 	}
 
 	// Create the user model.
 	user := &models.User{
 		Name:       input.Name,
-		Email:      input.Email,
+		Email:      email,
 		TelegramID: input.TelegramID,
+		Role:       customTypes.RoleUser, // New users never receive elevated privileges on registration.
 	}
 
 	// Persist the user in the repository.
 	if err := s.userRepo.Create(ctx, user); err != nil {
-		slog.ErrorContext(ctx, "RegisterUser: failed to create user in repository", "email", input.Email, "error", err)
-		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			return nil, fmt.Errorf("failed to create user: a user with the provided details (e.g., email) may already exist: %w", err)
+		if errors.Is(err, interfaces.ErrEmailTaken) {
+			slog.WarnContext(ctx, "RegisterUser: email taken by a concurrent registration", "email", email)
+			return nil, conflict(fmt.Errorf("user with email '%s' already exists: %w", email, err))
+		}
+		if errors.Is(err, interfaces.ErrTelegramIDTaken) {
+			slog.WarnContext(ctx, "RegisterUser: Telegram ID already in use", "telegramID", input.TelegramID)
+			return nil, conflict(fmt.Errorf("user with Telegram ID %d already exists: %w", input.TelegramID, err))
 		}
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		slog.ErrorContext(ctx, "RegisterUser: failed to create user in repository", "email", email, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to create user: %w", err))
 	}
-
-	slog.InfoContext(ctx, "RegisterUser: user registered successfully", "userID", user.ID, "email", user.Email)
 	return user, nil
 }
 
+// createTrial creates the trial subscription of a newly registered user. Users re-registering after their
+// account was deleted do not get another trial, in which case nil is returned.
+func (s *userService) createTrial(ctx context.Context, user *models.User) (*models.Subscription, error) {
+	registeredBefore, err := s.userRepo.ExistsDeletedByEmailOrTelegramID(ctx, user.Email, user.TelegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "RegisterUser: failed to check for earlier registrations", "userID", user.ID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not check trial eligibility: %w", err))
+	}
+	if registeredBefore {
+		slog.InfoContext(ctx, "RegisterUser: user registered before, no trial granted", "userID", user.ID)
+		return nil, nil
+	}
+
+	now := time.Now()
+	endDate, err := calculateEndDate(now, customTypes.UnitDay, s.cfg.TrialDurationDays)
+	if err != nil {
+		slog.ErrorContext(ctx, "RegisterUser: failed to calculate trial end date", "days", s.cfg.TrialDurationDays, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to calculate trial end date: %w", err))
+	}
+	trial := &models.Subscription{
+		UserID:        user.ID,
+		PlanName:      s.cfg.TrialPlanName,
+		DurationUnit:  customTypes.UnitDay,
+		DurationValue: s.cfg.TrialDurationDays,
+		StartDate:     now,
+		EndDate:       endDate,
+		IsActive:      true,
+		PaymentStatus: "trial",
+		AutoRenew:     false,
+		Currency:      s.cfg.DefaultCurrency,
+	}
+	event := newSubscriptionEvent(customTypes.SubscriptionEventCreated, nil, nil, subscriptionSnapshot(trial))
+	if err := s.subRepo.Create(ctx, trial, event); err != nil {
+		slog.ErrorContext(ctx, "RegisterUser: failed to create trial subscription", "userID", user.ID, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to create trial subscription: %w", err))
+	}
+	slog.InfoContext(ctx, "RegisterUser: trial subscription created", "userID", user.ID, "subscriptionID", trial.ID, "endDate", trial.EndDate)
+	return trial, nil
+}
+
 // GetUser retrieves a user by their ID.
 func (s *userService) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	slog.InfoContext(ctx, "GetUser: attempting to get user by ID", "userID", id)
@@ -62,30 +159,121 @@ func (s *userService) GetUser(ctx context.Context, id uuid.UUID) (*models.User,
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "GetUser: user not found", "userID", id)
-			return nil, fmt.Errorf("user with ID '%s' not found: %w", id, err)
+			return nil, notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
 		}
 		slog.ErrorContext(ctx, "GetUser: failed to get user by ID from repository", "userID", id, "error", err)
-		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+		return nil, contextAware(fmt.Errorf("failed to retrieve user: %w", err))
 	}
 	slog.InfoContext(ctx, "GetUser: user retrieved successfully", "userID", user.ID, "email", user.Email)
 	return user, nil
 }
 
+// GetUserByTelegramID retrieves a user by their Telegram ID.
+// Telegram IDs are positive, so non-positive values never match a user.
+func (s *userService) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	slog.InfoContext(ctx, "GetUserByTelegramID: attempting to get user by Telegram ID", "telegramID", telegramID)
+	if telegramID <= 0 {
+		return nil, notFound(fmt.Errorf("user with Telegram ID %d not found: %w", telegramID, gorm.ErrRecordNotFound))
+	}
+	user, err := s.userRepo.GetByTelegramID(ctx, telegramID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "GetUserByTelegramID: user not found", "telegramID", telegramID)
+			return nil, notFound(fmt.Errorf("user with Telegram ID %d not found: %w", telegramID, err))
+		}
+		slog.ErrorContext(ctx, "GetUserByTelegramID: failed to get user by Telegram ID from repository", "telegramID", telegramID, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to retrieve user: %w", err))
+	}
+	slog.InfoContext(ctx, "GetUserByTelegramID: user retrieved successfully", "userID", user.ID)
+	return user, nil
+}
+
+// RecordLogin sets the user's last login time to now.
+// It updates only the last_login column, so it is safe to call concurrently with other user updates.
+func (s *userService) RecordLogin(ctx context.Context, userID uuid.UUID) error {
+	slog.InfoContext(ctx, "RecordLogin: recording user login", "userID", userID)
+	if err := s.userRepo.UpdateLastLogin(ctx, userID, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RecordLogin: user not found", "userID", userID)
+			return notFound(fmt.Errorf("user with ID '%s' not found: %w", userID, err))
+		}
+		slog.ErrorContext(ctx, "RecordLogin: failed to update last login", "userID", userID, "error", err)
+		return contextAware(fmt.Errorf("could not record login: %w", err))
+	}
+	return nil
+}
+
+// ListInactiveUsers retrieves a paginated list of users whose last login is at least inactiveDays days ago,
+// or who never logged in.
+func (s *userService) ListInactiveUsers(ctx context.Context, inactiveDays, page, pageSize int) ([]models.User, int64, error) {
+	slog.InfoContext(ctx, "ListInactiveUsers: listing inactive users", "inactiveDays", inactiveDays, "page", page, "pageSize", pageSize)
+
+	if inactiveDays < 1 {
+		return nil, 0, invalid(fmt.Errorf("invalid inactivity threshold: %d days (must be positive)", inactiveDays))
+	}
+	page, pageSize = normalizePage(page, pageSize)
+
+	since := time.Now().AddDate(0, 0, -inactiveDays)
+	users, totalCount, err := s.userRepo.ListInactiveSince(ctx, since, (page-1)*pageSize, pageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListInactiveUsers: failed to list inactive users from repository", "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve inactive users: %w", err))
+	}
+	slog.InfoContext(ctx, "ListInactiveUsers: inactive users listed successfully", "count", len(users), "totalCount", totalCount)
+	return users, totalCount, nil
+}
+
+// ListUsersByHostCountry retrieves a paginated list of users whose active key is assigned to a host
+// in the given country. The country is matched case-insensitively.
+func (s *userService) ListUsersByHostCountry(ctx context.Context, country string, page, pageSize int) ([]models.User, int64, error) {
+	slog.InfoContext(ctx, "ListUsersByHostCountry: listing users assigned to hosts in country", "country", country, "page", page, "pageSize", pageSize)
+
+	country = strings.TrimSpace(country)
+	if country == "" {
+		return nil, 0, invalid(errors.New("country is required"))
+	}
+	page, pageSize = normalizePage(page, pageSize)
+
+	users, totalCount, err := s.userRepo.ListWithActiveKeyInCountry(ctx, country, (page-1)*pageSize, pageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListUsersByHostCountry: failed to list users from repository", "country", country, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve users assigned to hosts in %s: %w", country, err))
+	}
+	slog.InfoContext(ctx, "ListUsersByHostCountry: users listed successfully", "country", country, "count", len(users), "totalCount", totalCount)
+	return users, totalCount, nil
+}
+
 // UpdateUser updates an existing user's data.
 // It retrieves the current user, applies provided changes, and persists them.
 func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input dto.UpdateUserInput) (*models.User, error) {
 	slog.InfoContext(ctx, "UpdateUser: attempting to update user", "userID", id)
 
-	// Retrieve the current user to ensure updates are applied to the latest data
+	// The email availability check and the save run in one transaction with the user row locked,
+	// so concurrent updates of the same user are applied one after another.
+	var user *models.User
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.updateUser(ctx, id, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// updateUser applies the update to the user and persists it. It must run within a transaction.
+func (s *userService) updateUser(ctx context.Context, id uuid.UUID, input dto.UpdateUserInput) (*models.User, error) {
+	// Retrieve and lock the current user to ensure updates are applied to the latest data
 	// and that GORM knows which record to update.
-	user, err := s.userRepo.GetByID(ctx, id)
+	user, err := s.userRepo.GetByIDForUpdate(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "UpdateUser: user to update not found in repository", "userID", id)
-			return nil, fmt.Errorf("user with ID '%s' not found: %w", id, err)
+			return nil, notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
 		}
 		slog.ErrorContext(ctx, "UpdateUser: failed to retrieve user for update from repository", "userID", id, "error", err)
-		return nil, fmt.Errorf("could not retrieve user for update: %w", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve user for update: %w", err))
 	}
 
 	changesMade := false
@@ -95,7 +283,7 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input dto.Up
 		trimmedName := strings.TrimSpace(*input.Name)
 		if trimmedName == "" {
 			slog.WarnContext(ctx, "UpdateUser: attempt to set empty user name", "userID", id)
-			return nil, errors.New("user name cannot be empty if provided for update")
+			return nil, invalid(errors.New("user name cannot be empty if provided for update"))
 		}
 		if trimmedName != user.Name {
 			user.Name = trimmedName
@@ -107,22 +295,22 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input dto.Up
 	// Update user's email if provided and different.
 	// Includes a check to ensure the new email isn't already in use by another user.
 	if input.Email != nil {
-		trimmedEmail := strings.ToLower(strings.TrimSpace(*input.Email))
+		trimmedEmail := normalizeEmail(*input.Email)
 		if trimmedEmail == "" {
 			slog.WarnContext(ctx, "UpdateUser: attempt to set empty user email", "userID", id)
-			return nil, errors.New("user email cannot be empty if provided for update")
+			return nil, invalid(errors.New("user email cannot be empty if provided for update"))
 		}
 
 		if trimmedEmail != user.Email {
 			existingUserWithNewEmail, errGetByEmail := s.userRepo.GetByEmail(ctx, trimmedEmail)
 			if errGetByEmail == nil && existingUserWithNewEmail != nil && existingUserWithNewEmail.ID != user.ID {
 				slog.WarnContext(ctx, "UpdateUser: new email already in use by another user", "userID", id, "newEmail", trimmedEmail, "conflictingUserID", existingUserWithNewEmail.ID)
-				return nil, fmt.Errorf("email '%s' is already in use by another user", trimmedEmail)
+				return nil, conflict(fmt.Errorf("email '%s' belongs to another user: %w", trimmedEmail, interfaces.ErrEmailTaken))
 			}
 			// If an error occurred but it's not ErrRecordNotFound, it indicates a DB access issue.
 			if errGetByEmail != nil && !errors.Is(errGetByEmail, gorm.ErrRecordNotFound) {
 				slog.ErrorContext(ctx, "UpdateUser: error checking new email availability", "userID", id, "newEmail", trimmedEmail, "error", errGetByEmail)
-				return nil, fmt.Errorf("could not verify new email availability: %w", errGetByEmail)
+				return nil, contextAware(fmt.Errorf("could not verify new email availability: %w", errGetByEmail))
 			}
 			// If the email is available (errGetByEmail == gorm.ErrRecordNotFound), update it.
 			user.Email = trimmedEmail
@@ -159,7 +347,10 @@ func (s *userService) UpdateUser(ctx context.Context, id uuid.UUID, input dto.Up
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		slog.ErrorContext(ctx, "UpdateUser: failed to update user in repository", "userID", id, "error", err)
 		// Handle potential unique constraint violations that might occur at the DB level due to race conditions.
-		return nil, fmt.Errorf("failed to save user updates: %w", err)
+		if errors.Is(err, interfaces.ErrEmailTaken) || errors.Is(err, interfaces.ErrTelegramIDTaken) {
+			return nil, conflict(fmt.Errorf("failed to save user updates: %w", err))
+		}
+		return nil, contextAware(fmt.Errorf("failed to save user updates: %w", err))
 	}
 
 	slog.InfoContext(ctx, "UpdateUser: user updated successfully", "userID", user.ID, "email", user.Email)
@@ -173,40 +364,249 @@ func (s *userService) DeleteUser(ctx context.Context, id uuid.UUID) error {
 	if err := s.userRepo.Delete(ctx, id); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.WarnContext(ctx, "DeleteUser: user to delete not found in repository", "userID", id)
-			return fmt.Errorf("user with ID '%s' not found: %w", id, err)
+			return notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
 		}
 		slog.ErrorContext(ctx, "DeleteUser: failed to delete user in repository", "userID", id, "error", err)
-		return fmt.Errorf("failed to delete user: %w", err)
+		return contextAware(fmt.Errorf("failed to delete user: %w", err))
 	}
 
 	slog.InfoContext(ctx, "DeleteUser: user deleted successfully", "userID", id)
 	return nil
 }
 
-// ListUsers retrieves a paginated list of users.
-func (s *userService) ListUsers(ctx context.Context, page, pageSize int) ([]models.User, int64, error) {
-	slog.InfoContext(ctx, "ListUsers: attempting to list users", "page", page, "pageSize", pageSize)
+// RestoreUser brings back a soft-deleted user.
+// It fails with a conflict if a newer account has since taken the user's email or Telegram ID.
+func (s *userService) RestoreUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	slog.InfoContext(ctx, "RestoreUser: attempting to restore user", "userID", id)
 
-	// Validate and set default pagination parameters.
-	if page < 1 {
-		page = 1
+	user, err := s.userRepo.GetByIDUnscoped(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "RestoreUser: user not found", "userID", id)
+			return nil, notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
+		}
+		slog.ErrorContext(ctx, "RestoreUser: failed to retrieve user", "userID", id, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve user: %w", err))
+	}
+	if !user.DeletedAt.Valid {
+		slog.WarnContext(ctx, "RestoreUser: user is not deleted", "userID", id)
+		return nil, notFound(fmt.Errorf("deleted user with ID '%s' not found", id))
+	}
+
+	// Check for newer accounts up front so the client gets a clear message; the unique indexes still
+	// guard against a race with a concurrent registration.
+	if user.Email != "" {
+		existingUser, err := s.userRepo.GetByEmail(ctx, user.Email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "RestoreUser: failed to check email availability", "userID", id, "error", err)
+			return nil, contextAware(fmt.Errorf("could not verify email availability: %w", err))
+		}
+		if existingUser != nil {
+			slog.WarnContext(ctx, "RestoreUser: email taken by another user", "userID", id, "existingUserID", existingUser.ID)
+			return nil, conflict(fmt.Errorf("email '%s' now belongs to user %s: %w", user.Email, existingUser.ID, interfaces.ErrEmailTaken))
+		}
+	}
+	if user.TelegramID != 0 {
+		existingUser, err := s.userRepo.GetByTelegramID(ctx, user.TelegramID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.ErrorContext(ctx, "RestoreUser: failed to check Telegram ID availability", "userID", id, "error", err)
+			return nil, contextAware(fmt.Errorf("could not verify Telegram ID availability: %w", err))
+		}
+		if existingUser != nil {
+			slog.WarnContext(ctx, "RestoreUser: Telegram ID taken by another user", "userID", id, "existingUserID", existingUser.ID)
+			return nil, conflict(fmt.Errorf("telegram ID %d now belongs to user %s: %w", user.TelegramID, existingUser.ID, interfaces.ErrTelegramIDTaken))
+		}
+	}
+
+	restored, err := s.userRepo.Restore(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFound(fmt.Errorf("deleted user with ID '%s' not found: %w", id, err))
+		}
+		if errors.Is(err, interfaces.ErrEmailTaken) || errors.Is(err, interfaces.ErrTelegramIDTaken) {
+			slog.WarnContext(ctx, "RestoreUser: user attributes taken concurrently", "userID", id, "error", err)
+			return nil, conflict(fmt.Errorf("cannot restore user '%s': %w", id, err))
+		}
+		slog.ErrorContext(ctx, "RestoreUser: failed to restore user in repository", "userID", id, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to restore user: %w", err))
+	}
+
+	slog.InfoContext(ctx, "RestoreUser: user restored successfully", "userID", id)
+	return restored, nil
+}
+
+// UpdateUserRole changes the role of a user.
+// The requesting user must exist and have the admin role.
+func (s *userService) UpdateUserRole(ctx context.Context, requestingUserID uuid.UUID, userID uuid.UUID, role customTypes.UserRole) (*models.User, error) {
+	slog.InfoContext(ctx, "UpdateUserRole: attempting to update user role", "requestingUserID", requestingUserID, "userID", userID, "role", role)
+
+	if !role.IsValid() {
+		slog.WarnContext(ctx, "UpdateUserRole: invalid role provided", "role", role)
+		return nil, invalid(fmt.Errorf("invalid user role provided: %s", role))
+	}
+
+	// Authorization check: the requesting user's role is resolved from their stored record.
+	requestingUser, err := s.userRepo.GetByID(ctx, requestingUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "UpdateUserRole: requesting user not found", "requestingUserID", requestingUserID)
+			return nil, unauthorized(fmt.Errorf("user %s not authorized to change roles", requestingUserID))
+		}
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to retrieve requesting user", "requestingUserID", requestingUserID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not verify requesting user: %w", err))
+	}
+	if !requestingUser.Role.IsAdmin() {
+		slog.WarnContext(ctx, "UpdateUserRole: requesting user is not an administrator", "requestingUserID", requestingUserID, "requestingUserRole", requestingUser.Role)
+		return nil, unauthorized(fmt.Errorf("user %s not authorized to change roles", requestingUserID))
 	}
-	if pageSize < 1 {
-		pageSize = defaultPageSize
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "UpdateUserRole: user not found", "userID", userID)
+			return nil, notFound(fmt.Errorf("user with ID '%s' not found: %w", userID, err))
+		}
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to retrieve user", "userID", userID, "error", err)
+		return nil, contextAware(fmt.Errorf("could not retrieve user for role update: %w", err))
 	}
-	if pageSize > maxPageSize {
-		pageSize = maxPageSize
+
+	if user.Role == role {
+		slog.InfoContext(ctx, "UpdateUserRole: user already has the requested role", "userID", userID, "role", role)
+		return user, nil
+	}
+
+	user.Role = role
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "UpdateUserRole: failed to update user role in repository", "userID", userID, "error", err)
+		return nil, contextAware(fmt.Errorf("failed to save user role: %w", err))
 	}
 
-	// Calculate the offset for the repository query.
-	offset := (page - 1) * pageSize
+	slog.InfoContext(ctx, "UpdateUserRole: user role updated successfully", "userID", userID, "role", user.Role)
+	return user, nil
+}
+
+// ListUsers retrieves a paginated list of users matching the filters in params.
+func (s *userService) ListUsers(ctx context.Context, params dto.ListUsersServiceParams) ([]models.User, int64, error) {
+	slog.InfoContext(ctx, "ListUsers: attempting to list users", "params", fmt.Sprintf("%+v", params))
+
+	// Validate and set default pagination parameters.
+	params.Page, params.PageSize = normalizePage(params.Page, params.PageSize)
 
-	users, totalCount, err := s.userRepo.List(ctx, offset, pageSize)
+	repoParams := toListUsersRepoParams(params)
+	repoParams.Offset = (params.Page - 1) * params.PageSize
+	repoParams.Limit = params.PageSize
+
+	users, totalCount, err := s.userRepo.List(ctx, repoParams)
 	if err != nil {
-		slog.ErrorContext(ctx, "ListUsers: failed to list users from repository", "page", page, "pageSize", pageSize, "error", err)
-		return nil, 0, fmt.Errorf("could not retrieve users list: %w", err)
+		slog.ErrorContext(ctx, "ListUsers: failed to list users from repository", "page", params.Page, "pageSize", params.PageSize, "error", err)
+		return nil, 0, contextAware(fmt.Errorf("could not retrieve users list: %w", err))
 	}
 
 	slog.InfoContext(ctx, "ListUsers: users listed successfully", "count", len(users), "totalCount", totalCount)
 	return users, totalCount, nil
 }
+
+// ListUsersAfter retrieves a page of filtered users following the cursor, newest first, using keyset pagination.
+// Page and sort parameters are ignored. One extra user is fetched to report whether more users follow the page.
+func (s *userService) ListUsersAfter(ctx context.Context, params dto.ListUsersServiceParams, after *customTypes.UserListCursor) ([]models.User, bool, error) {
+	slog.InfoContext(ctx, "ListUsersAfter: attempting to list users", "params", fmt.Sprintf("%+v", params), "hasCursor", after != nil)
+
+	params.PageSize = normalizePageSize(params.PageSize)
+	repoParams := toListUsersRepoParams(params)
+	repoParams.Limit = params.PageSize + 1
+
+	users, err := s.userRepo.ListAfter(ctx, repoParams, after)
+	if err != nil {
+		slog.ErrorContext(ctx, "ListUsersAfter: failed to list users from repository", "pageSize", params.PageSize, "error", err)
+		return nil, false, contextAware(fmt.Errorf("could not retrieve users list: %w", err))
+	}
+
+	hasMore := len(users) > params.PageSize
+	if hasMore {
+		users = users[:params.PageSize]
+	}
+	slog.InfoContext(ctx, "ListUsersAfter: users listed successfully", "count", len(users), "hasMore", hasMore)
+	return users, hasMore, nil
+}
+
+// toListUsersRepoParams converts the filter and sort parameters of a service-layer list request
+// to repository-layer parameters. Pagination fields are left for the caller to set.
+func toListUsersRepoParams(params dto.ListUsersServiceParams) customTypes.ListUsersParams {
+	return customTypes.ListUsersParams{
+		Query:         params.Query,
+		IsActive:      params.IsActive,
+		HasTelegram:   params.HasTelegram,
+		CreatedAfter:  params.CreatedAfter,
+		CreatedBefore: params.CreatedBefore,
+		SortBy:        params.SortBy,
+		SortOrder:     params.SortOrder,
+
+		IncludeDeleted: params.IncludeDeleted,
+	}
+}
+
+// PurgeUser permanently deletes a user and their subscriptions.
+// Unlike DeleteUser it also applies to users that have already been soft-deleted.
+// A user with an active, paid subscription is only purged when force is true.
+func (s *userService) PurgeUser(ctx context.Context, id uuid.UUID, force bool) error {
+	slog.InfoContext(ctx, "PurgeUser: attempting to purge user", "userID", id, "force", force)
+
+	if _, err := s.userRepo.GetByIDUnscoped(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "PurgeUser: user to purge not found in repository", "userID", id)
+			return notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
+		}
+		slog.ErrorContext(ctx, "PurgeUser: failed to retrieve user from repository", "userID", id, "error", err)
+		return contextAware(fmt.Errorf("failed to retrieve user: %w", err))
+	}
+
+	if !force {
+		hasPaidSubscription, err := s.subRepo.CheckUserActivePaidSubscription(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "PurgeUser: failed to check active paid subscriptions", "userID", id, "error", err)
+			return contextAware(fmt.Errorf("failed to check active paid subscriptions: %w", err))
+		}
+		if hasPaidSubscription {
+			slog.WarnContext(ctx, "PurgeUser: user has an active paid subscription, refusing to purge", "userID", id)
+			return conflict(fmt.Errorf("user with ID '%s' has an active paid subscription; purge must be forced", id))
+		}
+	}
+
+	if err := s.userRepo.Purge(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "PurgeUser: user disappeared before purge", "userID", id)
+			return notFound(fmt.Errorf("user with ID '%s' not found: %w", id, err))
+		}
+		slog.ErrorContext(ctx, "PurgeUser: failed to purge user in repository", "userID", id, "error", err)
+		return contextAware(fmt.Errorf("failed to purge user: %w", err))
+	}
+
+	slog.InfoContext(ctx, "PurgeUser: user purged successfully", "userID", id)
+	return nil
+}
+
+// PurgeDeletedUsers permanently deletes users that were soft-deleted longer ago than the configured retention period.
+// Users that still have an active, paid subscription are skipped and retried on the next run.
+func (s *userService) PurgeDeletedUsers(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-s.cfg.UserRetentionPeriod)
+	slog.InfoContext(ctx, "PurgeDeletedUsers: looking for soft-deleted users to purge", "deletedBefore", deletedBefore)
+
+	users, err := s.userRepo.ListDeletedBefore(ctx, deletedBefore, s.cfg.UserPurgeBatchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "PurgeDeletedUsers: failed to list soft-deleted users", "error", err)
+		return contextAware(fmt.Errorf("could not list soft-deleted users: %w", err))
+	}
+
+	purgedCount, skippedCount := 0, 0
+	for _, user := range users {
+		if err := s.PurgeUser(ctx, user.ID, false); err != nil {
+			slog.WarnContext(ctx, "PurgeDeletedUsers: skipping user", "userID", user.ID, "error", err)
+			skippedCount++
+			continue
+		}
+		purgedCount++
+	}
+
+	slog.InfoContext(ctx, "PurgeDeletedUsers: purge run completed", "candidates", len(users), "purged", purgedCount, "skipped", skippedCount)
+	return nil
+}
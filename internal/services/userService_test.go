@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/services/dto"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestGetUserByTelegramID(t *testing.T) {
+	user := models.User{ID: uuid.New(), Name: "Telegram User", TelegramID: 123456789}
+	withoutTelegram := models.User{ID: uuid.New(), Name: "Web User"}
+
+	tests := []struct {
+		name       string
+		telegramID int64
+		wantUser   uuid.UUID
+		wantErr    error
+	}{
+		{name: "found", telegramID: 123456789, wantUser: user.ID},
+		{name: "not found", telegramID: 987654321, wantErr: ErrNotFound},
+		{name: "zero does not match users without Telegram", telegramID: 0, wantErr: ErrNotFound},
+		{name: "negative", telegramID: -5, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewUserService(newFakeUserRepo(user, withoutTelegram), newFakeSubRepo(), fakeTx{}, &config.Config{})
+
+			got, err := svc.GetUserByTelegramID(context.Background(), tt.telegramID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("GetUserByTelegramID() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.ID != tt.wantUser {
+				t.Errorf("GetUserByTelegramID() = user %s, want %s", got.ID, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestRegisterUserEmailUniqueness(t *testing.T) {
+	existing := models.User{ID: uuid.New(), Name: "Existing", Email: "foo@bar.com"}
+
+	tests := []struct {
+		name      string
+		email     string
+		racing    *models.User // Registered between the availability check and the insert.
+		wantEmail string
+		wantErr   error
+	}{
+		{name: "available", email: "new@bar.com", wantEmail: "new@bar.com"},
+		{name: "normalized before storing", email: "  New@Bar.COM ", wantEmail: "new@bar.com"},
+		{name: "taken", email: "foo@bar.com", wantErr: ErrConflict},
+		{name: "taken with different case and spacing", email: "  Foo@Bar.com ", wantErr: ErrConflict},
+		{name: "taken by a concurrent registration", email: "race@bar.com",
+			racing: &models.User{ID: uuid.New(), Name: "Racer", Email: "race@bar.com"}, wantErr: ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newFakeUserRepo(existing)
+			if tt.racing != nil {
+				users.beforeCreate = func() {
+					users.mu.Lock()
+					defer users.mu.Unlock()
+					users.users[tt.racing.ID] = tt.racing
+				}
+			}
+			svc := NewUserService(users, newFakeSubRepo(), fakeTx{}, &config.Config{})
+
+			user, trial, err := svc.RegisterUser(context.Background(), dto.CreateUserInput{Name: "Alice", Email: tt.email})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("RegisterUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, interfaces.ErrEmailTaken) {
+					t.Errorf("RegisterUser() error = %v, want it to wrap %v", err, interfaces.ErrEmailTaken)
+				}
+				return
+			}
+			if user.Email != tt.wantEmail {
+				t.Errorf("RegisterUser() email = %q, want %q", user.Email, tt.wantEmail)
+			}
+			if trial != nil {
+				t.Errorf("RegisterUser() created a trial with trials disabled")
+			}
+			if stored, err := users.GetByEmail(context.Background(), tt.wantEmail); err != nil || stored.ID != user.ID {
+				t.Errorf("stored user = %v, %v; want %s", stored, err, user.ID)
+			}
+		})
+	}
+}
+
+func TestRegisterUserEquivalentEmails(t *testing.T) {
+	svc := NewUserService(newFakeUserRepo(), newFakeSubRepo(), fakeTx{}, &config.Config{})
+
+	if _, _, err := svc.RegisterUser(context.Background(), dto.CreateUserInput{Name: "Foo", Email: "  Foo@Bar.com "}); err != nil {
+		t.Fatalf("first RegisterUser() error = %v", err)
+	}
+	_, _, err := svc.RegisterUser(context.Background(), dto.CreateUserInput{Name: "Foo again", Email: "foo@bar.com"})
+	if !errors.Is(err, ErrConflict) || !errors.Is(err, interfaces.ErrEmailTaken) {
+		t.Errorf("second RegisterUser() error = %v, want a conflict on the email", err)
+	}
+}
+
+func TestListUsersByHostCountry(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	user := func(n int) models.User {
+		return models.User{ID: uuid.New(), Name: fmt.Sprintf("User %d", n), CreatedAt: base.Add(time.Duration(n) * time.Hour)}
+	}
+	de, nl, deDeleted := models.Host{ID: 1, Country: "DE"}, models.Host{ID: 2, Country: "NL"}, models.Host{ID: 3, Country: "DE", DeletedAt: gorm.DeletedAt{Time: base, Valid: true}}
+	deUser, nlUser, bothUser, revokedUser, deletedHostUser := user(1), user(2), user(3), user(4), user(5)
+	assignments := []models.KeyAssignment{
+		{UserID: deUser.ID, Host: de, HostID: de.ID, IsActive: true},
+		{UserID: nlUser.ID, Host: nl, HostID: nl.ID, IsActive: true},
+		{UserID: bothUser.ID, Host: de, HostID: de.ID, IsActive: true},
+		{UserID: bothUser.ID, Host: nl, HostID: nl.ID, IsActive: true},
+		{UserID: bothUser.ID, Host: de, HostID: de.ID, IsActive: true}, // A second active key is listed once.
+		{UserID: revokedUser.ID, Host: de, HostID: de.ID, IsActive: false},
+		{UserID: deletedHostUser.ID, Host: deDeleted, HostID: deDeleted.ID, IsActive: true},
+	}
+
+	tests := []struct {
+		name      string
+		country   string
+		page      int
+		pageSize  int
+		wantUsers []uuid.UUID
+		wantTotal int64
+		wantErr   error
+	}{
+		{name: "DE", country: "DE", page: 1, pageSize: 10, wantUsers: []uuid.UUID{deUser.ID, bothUser.ID}, wantTotal: 2},
+		{name: "NL", country: "NL", page: 1, pageSize: 10, wantUsers: []uuid.UUID{nlUser.ID, bothUser.ID}, wantTotal: 2},
+		{name: "case-insensitive", country: " de ", page: 1, pageSize: 10, wantUsers: []uuid.UUID{deUser.ID, bothUser.ID}, wantTotal: 2},
+		{name: "second page", country: "DE", page: 2, pageSize: 1, wantUsers: []uuid.UUID{bothUser.ID}, wantTotal: 2},
+		{name: "no hosts in country", country: "US", page: 1, pageSize: 10, wantTotal: 0},
+		{name: "country required", country: "  ", page: 1, pageSize: 10, wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newFakeUserRepo(deUser, nlUser, bothUser, revokedUser, deletedHostUser)
+			users.assignments = assignments
+			svc := NewUserService(users, newFakeSubRepo(), fakeTx{}, &config.Config{})
+
+			got, total, err := svc.ListUsersByHostCountry(context.Background(), tt.country, tt.page, tt.pageSize)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ListUsersByHostCountry() error = %v, want %v", err, tt.wantErr)
+			}
+			var ids []uuid.UUID
+			for _, u := range got {
+				ids = append(ids, u.ID)
+			}
+			if !slices.Equal(ids, tt.wantUsers) || total != tt.wantTotal {
+				t.Errorf("ListUsersByHostCountry() = %v (total %d), want %v (total %d)", ids, total, tt.wantUsers, tt.wantTotal)
+			}
+		})
+	}
+}
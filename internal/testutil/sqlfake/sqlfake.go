@@ -0,0 +1,192 @@
+// Package sqlfake provides a GORM database backed by a scripted database/sql driver, so repository and
+// transaction code can be tested without a PostgreSQL server. Every statement sent to the driver is recorded,
+// including BEGIN, COMMIT and ROLLBACK, and answered by a caller-supplied function.
+package sqlfake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Statement is a statement received by the driver, with its arguments after conversion to driver values.
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+// Result is the scripted outcome of a statement. Rows are returned for queries; RowsAffected for executions.
+// If Err is set, the statement fails with it.
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Responder returns the outcome of a statement. Transaction control statements are not passed to it.
+type Responder func(stmt Statement) Result
+
+// DB records the statements sent through the fake driver.
+type DB struct {
+	mu         sync.Mutex
+	statements []Statement
+	respond    Responder
+}
+
+// Open returns a GORM database using the postgres dialector on the fake driver, and the DB recording its
+// statements. A nil respond answers every statement with an empty result.
+func Open(respond Responder) (*gorm.DB, *DB, error) {
+	if respond == nil {
+		respond = func(Statement) Result { return Result{} }
+	}
+	fake := &DB{respond: respond}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(connector{fake})}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return gormDB, fake, nil
+}
+
+// Statements returns the statements received so far, in order.
+func (d *DB) Statements() []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Statement(nil), d.statements...)
+}
+
+// Queries returns the statements received so far other than BEGIN, COMMIT and ROLLBACK, in order.
+func (d *DB) Queries() []Statement {
+	var queries []Statement
+	for _, stmt := range d.Statements() {
+		if !isTxControl(stmt.SQL) {
+			queries = append(queries, stmt)
+		}
+	}
+	return queries
+}
+
+// SQL returns the text of the statements received so far, in order.
+func (d *DB) SQL() []string {
+	statements := d.Statements()
+	texts := make([]string, len(statements))
+	for i, stmt := range statements {
+		texts[i] = stmt.SQL
+	}
+	return texts
+}
+
+// handle records stmt and returns its scripted outcome. Transaction control statements always succeed.
+func (d *DB) handle(query string, args []driver.NamedValue) Result {
+	stmt := Statement{SQL: query, Args: make([]any, len(args))}
+	for i, arg := range args {
+		stmt.Args[i] = arg.Value
+	}
+	d.mu.Lock()
+	d.statements = append(d.statements, stmt)
+	d.mu.Unlock()
+	if isTxControl(query) {
+		return Result{}
+	}
+	return d.respond(stmt)
+}
+
+func isTxControl(query string) bool {
+	return query == "BEGIN" || query == "COMMIT" || query == "ROLLBACK"
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{db: c.db}, nil }
+func (c connector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqlfake: connections are only available through sqlfake.Open")
+}
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return &stmt{conn: c, query: query}, nil }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error)                 { return c.BeginTx(context.Background(), driver.TxOptions{}) }
+func (c *conn) Ping(context.Context) error                { return nil }
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.handle("BEGIN", nil)
+	return tx{db: c.db}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.db.handle(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.handle(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{columns: result.Columns, values: result.Rows}, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct{ db *DB }
+
+func (t tx) Commit() error   { t.db.handle("COMMIT", nil); return nil }
+func (t tx) Rollback() error { t.db.handle("ROLLBACK", nil); return nil }
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,50 @@
+// Package testdb provides a migrated, in-memory SQLite database for tests that need to run repository
+// queries against a real database engine instead of scripted driver responses.
+package testdb
+
+import (
+	"bitback/internal/models"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a GORM database backed by a private in-memory SQLite database with the schema of all models.
+// The database is limited to a single connection, so every statement, including those inside a transaction,
+// sees the same data; it is closed when the test finishes.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_foreign_keys=1", name)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to obtain sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Host{},
+		&models.Subscription{},
+		&models.KeyAssignment{},
+		&models.KeyGeneration{},
+		&models.HostCheck{},
+		&models.Plan{},
+		&models.PromoCode{},
+		&models.SubscriptionEvent{},
+		&models.SubscriptionUsage{},
+	)
+	if err != nil {
+		t.Fatalf("failed to migrate sqlite database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,194 @@
+package workers
+
+import (
+	"bitback/internal/config"
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// hostCheckPageSize is the number of hosts loaded per page when collecting the hosts to check.
+const hostCheckPageSize = 100
+
+// hostHealthChecker dials every host's Address:Port and records whether the host was reachable.
+type hostHealthChecker struct {
+	hostService interfaces.HostService
+	metrics     interfaces.HostMetrics
+	timeout     time.Duration
+	concurrency int
+	skipPrivate bool
+	dialer      net.Dialer
+
+	// startupRamp is the window over which the first run spreads its checks, so a restart
+	// does not dial every host at the same moment. It is cleared once the first run has started.
+	startupRamp time.Duration
+}
+
+// NewHostHealthWorker creates a BackgroundWorker that periodically health-checks all hosts with a TCP dial
+// and records each result through HostService.RecordHostCheck.
+// The metrics recorder is optional; if it is not nil, it receives the host counts after every run.
+func NewHostHealthWorker(hostService interfaces.HostService, metrics interfaces.HostMetrics, cfg *config.Config) interfaces.BackgroundWorker {
+	checker := &hostHealthChecker{
+		hostService: hostService,
+		metrics:     metrics,
+		timeout:     cfg.HostCheckTimeout,
+		concurrency: cfg.HostCheckConcurrency,
+		skipPrivate: cfg.HostCheckSkipPrivate,
+		startupRamp: min(cfg.HostCheckStartupRamp, cfg.HostCheckInterval), // A ramp longer than the interval would overlap the next run.
+	}
+	return NewPeriodicWorker("host-health-check", cfg.HostCheckInterval, checker.checkAll)
+}
+
+// checkAll checks every non-deleted host, at most concurrency hosts at a time.
+// On the first run, checks are additionally spread evenly over the startup ramp.
+// Failures to record individual results are logged and do not stop the run.
+func (c *hostHealthChecker) checkAll(ctx context.Context) error {
+	hosts, err := c.listHosts(ctx)
+	if err != nil {
+		return err
+	}
+
+	ramp := c.startupRamp
+	c.startupRamp = 0 // Only the first run is ramped; runs are never concurrent.
+	if ramp > 0 {
+		slog.InfoContext(ctx, "Spreading first host health check over the startup ramp.", "hosts", len(hosts), "ramp", ramp.String())
+	}
+
+	startedAt := time.Now()
+	sem := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	online := 0
+	for i := range hosts {
+		if delay := time.Until(startedAt.Add(rampOffset(ramp, i, len(hosts)))); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				wg.Wait()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(host *models.Host) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if c.checkHost(ctx, host) {
+				mu.Lock()
+				online++
+				mu.Unlock()
+			}
+		}(&hosts[i])
+	}
+	wg.Wait()
+
+	if c.metrics != nil {
+		c.metrics.SetHostCounts(countHosts(hosts))
+	}
+	slog.InfoContext(ctx, "Host health check completed.", "checked", len(hosts), "online", online)
+	return nil
+}
+
+// countHosts aggregates hosts by country, status and reachability.
+func countHosts(hosts []models.Host) []interfaces.HostCount {
+	index := make(map[interfaces.HostCount]int)
+	for _, host := range hosts {
+		index[interfaces.HostCount{Country: host.Country, Status: string(host.Status), Online: host.IsOnline}]++
+	}
+	counts := make([]interfaces.HostCount, 0, len(index))
+	for key, count := range index {
+		key.Count = count
+		counts = append(counts, key)
+	}
+	return counts
+}
+
+// rampOffset returns how long after the start of a ramped run the i-th of n checks should begin,
+// spacing the checks evenly across the ramp.
+func rampOffset(ramp time.Duration, i, n int) time.Duration {
+	if ramp <= 0 || n <= 1 {
+		return 0
+	}
+	return time.Duration(int64(ramp) * int64(i) / int64(n))
+}
+
+// listHosts loads all hosts to check, excluding private hosts if configured.
+func (c *hostHealthChecker) listHosts(ctx context.Context) ([]models.Host, error) {
+	params := dto.ListHostsServiceParams{PageSize: hostCheckPageSize, SortBy: "created_at", SortOrder: "asc"}
+	if c.skipPrivate {
+		isPrivate := false
+		params.IsPrivate = &isPrivate
+	}
+
+	var hosts []models.Host
+	for page := 1; ; page++ {
+		params.Page = page
+		batch, totalCount, err := c.hostService.ListHosts(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("could not list hosts to check: %w", err)
+		}
+		hosts = append(hosts, batch...)
+		if len(batch) < hostCheckPageSize || int64(len(hosts)) >= totalCount {
+			return hosts, nil
+		}
+	}
+}
+
+// checkHost dials the host and records the result. Hosts in maintenance keep their status;
+// all others become active when reachable and inactive otherwise. The host is updated with the result,
+// and checkHost reports whether it was reachable.
+func (c *hostHealthChecker) checkHost(ctx context.Context, host *models.Host) bool {
+	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	conn, dialErr := c.dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(host.Address, host.Port))
+	if dialErr == nil {
+		_ = conn.Close()
+	}
+	if ctx.Err() != nil {
+		return false // Shutting down; the dial was interrupted and says nothing about the host.
+	}
+
+	result := dto.HostCheckResult{
+		IsOnline:  dialErr == nil,
+		CheckedAt: &startedAt,
+	}
+	if host.Status != customTypes.StatusMaintenance {
+		status := customTypes.StatusInactive
+		if result.IsOnline {
+			status = customTypes.StatusActive
+		}
+		result.Status = &status
+	}
+	if result.IsOnline {
+		latencyMs := int(time.Since(startedAt).Milliseconds())
+		result.LatencyMs = &latencyMs
+	} else {
+		result.Error = dialErr.Error()
+	}
+
+	if _, err := c.hostService.RecordHostCheck(ctx, host.ID, result); err != nil {
+		slog.ErrorContext(ctx, "Failed to record host health check.", "hostID", host.ID, "error", err)
+	}
+	host.IsOnline = result.IsOnline
+	if result.Status != nil {
+		host.Status = *result.Status
+	}
+	return result.IsOnline
+}
@@ -0,0 +1,245 @@
+package workers
+
+import (
+	"bitback/internal/interfaces"
+	"bitback/internal/models"
+	"bitback/internal/models/customTypes"
+	"bitback/internal/services/dto"
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHostService is an interfaces.HostService serving a fixed list of hosts and recording the checks reported for them.
+// Calling any other method panics through the nil embedded interface.
+type fakeHostService struct {
+	interfaces.HostService
+
+	hosts []models.Host
+
+	mu     sync.Mutex
+	checks map[uint]time.Time // Start time of the recorded check per host ID.
+}
+
+func (s *fakeHostService) ListHosts(_ context.Context, params dto.ListHostsServiceParams) ([]models.Host, int64, error) {
+	start := min((params.Page-1)*params.PageSize, len(s.hosts))
+	return slices.Clone(s.hosts[start:min(start+params.PageSize, len(s.hosts))]), int64(len(s.hosts)), nil
+}
+
+func (s *fakeHostService) RecordHostCheck(_ context.Context, hostID uint, result dto.HostCheckResult) (*models.HostCheck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[hostID] = *result.CheckedAt
+	return &models.HostCheck{HostID: hostID}, nil
+}
+
+// listenLocal starts a TCP listener accepting connections on a loopback port, and returns the port.
+func listenLocal(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestRampOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		ramp time.Duration
+		i, n int
+		want time.Duration
+	}{
+		{name: "first check starts immediately", ramp: time.Minute, i: 0, n: 4, want: 0},
+		{name: "evenly spaced", ramp: time.Minute, i: 1, n: 4, want: 15 * time.Second},
+		{name: "last check before the end of the ramp", ramp: time.Minute, i: 3, n: 4, want: 45 * time.Second},
+		{name: "single host", ramp: time.Minute, i: 0, n: 1, want: 0},
+		{name: "no ramp", ramp: 0, i: 3, n: 4, want: 0},
+		{name: "more hosts than nanoseconds", ramp: 3 * time.Nanosecond, i: 5, n: 10, want: time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rampOffset(tt.ramp, tt.i, tt.n); got != tt.want {
+				t.Errorf("rampOffset(%v, %d, %d) = %v, want %v", tt.ramp, tt.i, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAllStartupRamp(t *testing.T) {
+	const hostCount = 5
+	// tolerance absorbs scheduling delays; it is well below the 60ms spacing of ramped checks.
+	const tolerance = 25 * time.Millisecond
+	port := listenLocal(t)
+
+	tests := []struct {
+		name        string
+		ramp        time.Duration
+		wantSpacing time.Duration // Minimum time between consecutive checks of the first run.
+	}{
+		{name: "ramped", ramp: 300 * time.Millisecond, wantSpacing: 300 * time.Millisecond / hostCount},
+		{name: "no ramp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeHostService{}
+			for i := range hostCount {
+				svc.hosts = append(svc.hosts, models.Host{ID: uint(i + 1), Address: "127.0.0.1", Port: port})
+			}
+			checker := &hostHealthChecker{hostService: svc, timeout: time.Second, concurrency: hostCount, startupRamp: tt.ramp}
+
+			for run := 1; run <= 2; run++ {
+				svc.checks = make(map[uint]time.Time)
+				startedAt := time.Now()
+				if err := checker.checkAll(context.Background()); err != nil {
+					t.Fatalf("run %d: checkAll() error = %v", run, err)
+				}
+				if len(svc.checks) != hostCount {
+					t.Fatalf("run %d: recorded %d checks, want %d", run, len(svc.checks), hostCount)
+				}
+
+				// Only the first run is ramped.
+				wantSpacing := tt.wantSpacing
+				if run > 1 {
+					wantSpacing = 0
+				}
+				for i := range hostCount {
+					offset := svc.checks[uint(i+1)].Sub(startedAt)
+					wantOffset := time.Duration(i) * wantSpacing
+					if offset < wantOffset-tolerance || offset > wantOffset+tolerance {
+						t.Errorf("run %d: host %d checked %v after the start, want about %v", run, i+1, offset, wantOffset)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAllStartupRampCanceled(t *testing.T) {
+	port := listenLocal(t)
+	svc := &fakeHostService{checks: make(map[uint]time.Time)}
+	for i := range 3 {
+		svc.hosts = append(svc.hosts, models.Host{ID: uint(i + 1), Address: "127.0.0.1", Port: port})
+	}
+	checker := &hostHealthChecker{hostService: svc, timeout: time.Second, concurrency: 3, startupRamp: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	startedAt := time.Now()
+	if err := checker.checkAll(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("checkAll() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Errorf("checkAll() returned %v after the start, want it to stop waiting on cancellation", elapsed)
+	}
+	if len(svc.checks) != 1 {
+		t.Errorf("recorded %d checks, want only the first host's", len(svc.checks))
+	}
+}
+
+// fakeHostMetrics is an interfaces.HostMetrics keeping the last host counts it was given.
+type fakeHostMetrics struct {
+	counts []interfaces.HostCount
+	calls  int
+}
+
+func (m *fakeHostMetrics) SetHostCounts(counts []interfaces.HostCount) {
+	m.counts = counts
+	m.calls++
+}
+
+// sortHostCounts orders counts by country, status and reachability, so they can be compared.
+func sortHostCounts(counts []interfaces.HostCount) []interfaces.HostCount {
+	slices.SortFunc(counts, func(a, b interfaces.HostCount) int {
+		return cmp.Or(cmp.Compare(a.Country, b.Country), cmp.Compare(a.Status, b.Status), cmp.Compare(fmt.Sprint(a.Online), fmt.Sprint(b.Online)))
+	})
+	return counts
+}
+
+func TestCountHosts(t *testing.T) {
+	host := func(country string, status customTypes.HostStatus, online bool) models.Host {
+		return models.Host{Country: country, Status: status, IsOnline: online}
+	}
+
+	tests := []struct {
+		name  string
+		hosts []models.Host
+		want  []interfaces.HostCount
+	}{
+		{name: "no hosts", want: []interfaces.HostCount{}},
+		{
+			name: "grouped by country, status and reachability",
+			hosts: []models.Host{
+				host("DE", customTypes.StatusActive, true),
+				host("DE", customTypes.StatusActive, true),
+				host("DE", customTypes.StatusInactive, false),
+				host("NL", customTypes.StatusActive, true),
+				host("NL", customTypes.StatusMaintenance, false),
+			},
+			want: []interfaces.HostCount{
+				{Country: "DE", Status: "active", Online: true, Count: 2},
+				{Country: "DE", Status: "inactive", Online: false, Count: 1},
+				{Country: "NL", Status: "active", Online: true, Count: 1},
+				{Country: "NL", Status: "maintenance", Online: false, Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sortHostCounts(countHosts(tt.hosts)); !slices.Equal(got, tt.want) {
+				t.Errorf("countHosts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAllHostMetrics(t *testing.T) {
+	port := listenLocal(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	_ = closed.Close()
+
+	svc := &fakeHostService{
+		checks: make(map[uint]time.Time),
+		hosts: []models.Host{
+			{ID: 1, Country: "DE", Address: "127.0.0.1", Port: port, Status: customTypes.StatusInactive},
+			{ID: 2, Country: "DE", Address: "127.0.0.1", Port: port, Status: customTypes.StatusActive},
+			{ID: 3, Country: "NL", Address: "127.0.0.1", Port: closedPort, Status: customTypes.StatusActive},
+			{ID: 4, Country: "NL", Address: "127.0.0.1", Port: port, Status: customTypes.StatusMaintenance},
+		},
+	}
+	m := &fakeHostMetrics{}
+	checker := &hostHealthChecker{hostService: svc, metrics: m, timeout: time.Second, concurrency: 4}
+
+	if err := checker.checkAll(context.Background()); err != nil {
+		t.Fatalf("checkAll() error = %v", err)
+	}
+	// The counts reflect the results of the run, not the statuses listed before it.
+	want := []interfaces.HostCount{
+		{Country: "DE", Status: "active", Online: true, Count: 2},
+		{Country: "NL", Status: "inactive", Online: false, Count: 1},
+		{Country: "NL", Status: "maintenance", Online: true, Count: 1},
+	}
+	if got := sortHostCounts(m.counts); m.calls != 1 || !slices.Equal(got, want) {
+		t.Errorf("SetHostCounts() called %d times with %+v, want once with %+v", m.calls, got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package workers
+
+import (
+	"bitback/internal/interfaces"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// periodicWorker runs a job at a fixed interval until it is stopped.
+type periodicWorker struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPeriodicWorker creates a BackgroundWorker that invokes job every interval.
+// The job is first run immediately after Start is called.
+func NewPeriodicWorker(name string, interval time.Duration, job func(ctx context.Context) error) interfaces.BackgroundWorker {
+	return &periodicWorker{
+		name:     name,
+		interval: interval,
+		job:      job,
+	}
+}
+
+// Start launches the worker loop in a separate goroutine.
+func (w *periodicWorker) Start(ctx context.Context) {
+	workerCtx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		slog.InfoContext(workerCtx, "Background worker started.", "worker", w.name, "interval", w.interval.String())
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			w.runOnce(workerCtx)
+			select {
+			case <-workerCtx.Done():
+				slog.Info("Background worker stopped.", "worker", w.name)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels the worker loop and waits for the current run to finish.
+func (w *periodicWorker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// runOnce executes the job a single time, logging any error it returns.
+func (w *periodicWorker) runOnce(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	startedAt := time.Now()
+	if err := w.job(ctx); err != nil {
+		slog.ErrorContext(ctx, "Background worker run failed.", "worker", w.name, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Background worker run completed.", "worker", w.name, "duration", time.Since(startedAt).String())
+}
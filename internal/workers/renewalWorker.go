@@ -0,0 +1,11 @@
+package workers
+
+import (
+	"bitback/internal/interfaces"
+	"time"
+)
+
+// NewRenewalWorker creates a BackgroundWorker that periodically processes subscription auto-renewals.
+func NewRenewalWorker(subscriptionService interfaces.SubscriptionService, interval time.Duration) interfaces.BackgroundWorker {
+	return NewPeriodicWorker("subscription-renewal", interval, subscriptionService.ProcessRenewals)
+}
@@ -0,0 +1,12 @@
+package workers
+
+import (
+	"bitback/internal/interfaces"
+	"time"
+)
+
+// NewSubscriptionActivationWorker creates a BackgroundWorker that periodically activates paid subscriptions
+// whose start date has arrived.
+func NewSubscriptionActivationWorker(subscriptionService interfaces.SubscriptionService, interval time.Duration) interfaces.BackgroundWorker {
+	return NewPeriodicWorker("subscription-activation", interval, subscriptionService.ProcessActivations)
+}
@@ -0,0 +1,11 @@
+package workers
+
+import (
+	"bitback/internal/interfaces"
+	"time"
+)
+
+// NewUserPurgeWorker creates a BackgroundWorker that periodically purges users soft-deleted beyond the retention period.
+func NewUserPurgeWorker(userService interfaces.UserService, interval time.Duration) interfaces.BackgroundWorker {
+	return NewPeriodicWorker("user-purge", interval, userService.PurgeDeletedUsers)
+}